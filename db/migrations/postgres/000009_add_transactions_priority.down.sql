@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE transactions DROP COLUMN priority;
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE transactions ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE transactions ADD COLUMN not_before BIGINT;
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE transactions ADD COLUMN expiry BIGINT;
+COMMIT;
\ No newline at end of file
@@ -44,8 +44,12 @@ type Manager interface {
 	Stop()
 	NewBlockHashes() chan<- *ffcapi.BlockHashEvent
 	CheckInFlight(listenerID *fftypes.UUID) bool
+	SetNewBlockCallback(cb NewBlockCallback) // must be called before Start
 }
 
+// NewBlockCallback is called each time the confirmation manager processes a block higher than any it has seen before
+type NewBlockCallback func(blockNumber uint64)
+
 type NotificationType int
 
 const (
@@ -93,6 +97,7 @@ type blockConfirmationManager struct {
 	staleReceiptTimeout   time.Duration
 	bcmNotifications      chan *Notification
 	highestBlockSeen      uint64
+	newBlockCallback      NewBlockCallback
 	pending               map[string]*pendingItem
 	pendingMux            sync.Mutex
 	receiptChecker        *receiptChecker
@@ -212,7 +217,7 @@ type blockState struct {
 
 func (bcm *blockConfirmationManager) Start() {
 	bcm.done = make(chan struct{})
-	bcm.receiptChecker = newReceiptChecker(bcm, config.GetInt(tmconfig.ConfirmationsReceiptWorkers))
+	bcm.receiptChecker = newReceiptChecker(bcm, config.GetInt(tmconfig.ConfirmationsReceiptWorkers), config.GetInt(tmconfig.ConfirmationsReceiptBatchSize))
 	go bcm.confirmationsListener()
 }
 
@@ -232,6 +237,12 @@ func (bcm *blockConfirmationManager) NewBlockHashes() chan<- *ffcapi.BlockHashEv
 	return bcm.newBlockHashes
 }
 
+// SetNewBlockCallback registers a callback to be informed of each new highest block, such as for a transaction
+// handler to track how many blocks have passed without a receipt
+func (bcm *blockConfirmationManager) SetNewBlockCallback(cb NewBlockCallback) {
+	bcm.newBlockCallback = cb
+}
+
 // Notify is used to notify the confirmation manager of detection of a new logEntry addition or removal
 func (bcm *blockConfirmationManager) Notify(n *Notification) error {
 	switch n.NotificationType {
@@ -487,6 +498,9 @@ func (bcm *blockConfirmationManager) processBlockHashes(blockHashes []string) {
 		// Update the highest block (used for efficiency in chain walks)
 		if block.BlockNumber.Uint64() > bcm.highestBlockSeen {
 			bcm.highestBlockSeen = block.BlockNumber.Uint64()
+			if bcm.newBlockCallback != nil {
+				bcm.newBlockCallback(bcm.highestBlockSeen)
+			}
 		}
 	}
 }
@@ -32,18 +32,18 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
-func newTestBlockConfirmationManager(t *testing.T, enabled bool) (*blockConfirmationManager, *ffcapimocks.API) {
+func newTestBlockConfirmationManager(t *testing.T, enabled bool) (*blockConfirmationManager, *ffcapimocks.ExtendedAPI) {
 	tmconfig.Reset()
 	config.Set(tmconfig.ConfirmationsRequired, 3)
 	config.Set(tmconfig.ConfirmationsNotificationQueueLength, 1)
 	return newTestBlockConfirmationManagerCustomConfig(t)
 }
 
-func newTestBlockConfirmationManagerCustomConfig(t *testing.T) (*blockConfirmationManager, *ffcapimocks.API) {
+func newTestBlockConfirmationManagerCustomConfig(t *testing.T) (*blockConfirmationManager, *ffcapimocks.ExtendedAPI) {
 	logrus.SetLevel(logrus.DebugLevel)
-	mca := &ffcapimocks.API{}
+	mca := &ffcapimocks.ExtendedAPI{}
 	bcm := NewBlockConfirmationManager(context.Background(), mca, "ut").(*blockConfirmationManager)
-	bcm.receiptChecker = newReceiptChecker(bcm, 0) // no workers, but non-nil
+	bcm.receiptChecker = newReceiptChecker(bcm, 0, 0) // no workers, but non-nil
 	return bcm, mca
 }
 
@@ -1049,6 +1049,33 @@ func TestProcessBlockHashesLookupFail(t *testing.T) {
 	mca.AssertExpectations(t)
 }
 
+func TestProcessBlockHashesNewBlockCallback(t *testing.T) {
+
+	bcm, mca := newTestBlockConfirmationManager(t, false)
+	newBlocks := []uint64{}
+	bcm.SetNewBlockCallback(func(blockNumber uint64) {
+		newBlocks = append(newBlocks, blockNumber)
+	})
+
+	for _, b := range []struct {
+		hash   string
+		number int64
+	}{{"0x01", 1001}, {"0x02", 1002}, {"0x03", 1001}} {
+		mca.On("BlockInfoByHash", mock.Anything, &ffcapi.BlockInfoByHashRequest{BlockHash: b.hash}).Return(&ffcapi.BlockInfoByHashResponse{
+			BlockInfo: ffcapi.BlockInfo{
+				BlockNumber: fftypes.NewFFBigInt(b.number),
+				BlockHash:   b.hash,
+			},
+		}, ffcapi.ErrorReason(""), nil).Once()
+	}
+
+	// Only blocks higher than any seen before are notified
+	bcm.processBlockHashes([]string{"0x01", "0x02", "0x03"})
+	assert.Equal(t, []uint64{1001, 1002}, newBlocks)
+
+	mca.AssertExpectations(t)
+}
+
 func TestProcessNotificationsSwallowsUnknownType(t *testing.T) {
 
 	bcm, _ := newTestBlockConfirmationManager(t, false)
@@ -1177,7 +1204,7 @@ func TestCheckReceiptWalkFail(t *testing.T) {
 func TestStaleReceiptCheck(t *testing.T) {
 
 	bcm, _ := newTestBlockConfirmationManager(t, false)
-	bcm.receiptChecker = newReceiptChecker(bcm, 0)
+	bcm.receiptChecker = newReceiptChecker(bcm, 0, 0)
 
 	txHash := "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"
 	pending := &pendingItem{
@@ -18,6 +18,7 @@ package confirmations
 
 import (
 	"container/list"
+	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -34,21 +35,33 @@ import (
 //
 // When receipt checkers hit errors (excluding a null result of course), they simply
 // block in indefinite retry until they succeed or are shut down.
+//
+// If a batch size greater than one is configured, each worker takes up to that many
+// items off the queue at a time and queries them with a single TransactionReceipts call.
+// Connectors that do not implement ffcapi.ReceiptBatcher, or that return ErrorReasonNotSupported,
+// are queried with individual TransactionReceipt calls by all workers.
 type receiptChecker struct {
-	bcm         *blockConfirmationManager
-	workerCount int
-	workersDone []chan struct{}
-	closed      bool
-	cond        *sync.Cond
-	entries     *list.List
-	notify      func(*pendingItem, *ffcapi.TransactionReceiptResponse)
+	bcm              *blockConfirmationManager
+	batcher          ffcapi.ReceiptBatcher
+	workerCount      int
+	batchSize        int
+	batchUnsupported bool // protected by cond.L
+	workersDone      []chan struct{}
+	closed           bool
+	cond             *sync.Cond
+	entries          *list.List
+	notify           func(*pendingItem, *ffcapi.TransactionReceiptResponse)
 }
 
-func newReceiptChecker(bcm *blockConfirmationManager, workerCount int) *receiptChecker {
+func newReceiptChecker(bcm *blockConfirmationManager, workerCount, batchSize int) *receiptChecker {
+	batcher, ok := bcm.connector.(ffcapi.ReceiptBatcher)
 	rc := &receiptChecker{
-		bcm:         bcm,
-		workerCount: workerCount,
-		workersDone: make([]chan struct{}, workerCount),
+		bcm:              bcm,
+		batcher:          batcher,
+		workerCount:      workerCount,
+		batchSize:        batchSize,
+		batchUnsupported: !ok,
+		workersDone:      make([]chan struct{}, workerCount),
 		notify: func(pending *pendingItem, receipt *ffcapi.TransactionReceiptResponse) {
 			_ = bcm.Notify(&Notification{
 				NotificationType: receiptArrived,
@@ -66,22 +79,29 @@ func newReceiptChecker(bcm *blockConfirmationManager, workerCount int) *receiptC
 	return rc
 }
 
-func (rc *receiptChecker) waitNext() (p *pendingItem) {
+// waitNext blocks until there is at least one entry in the queue, then removes up to
+// batchSize entries (or just one if batching is disabled or unsupported)
+func (rc *receiptChecker) waitNext() (batch []*pendingItem) {
 	rc.cond.L.Lock()
 	defer rc.cond.L.Unlock()
-	var entry *list.Element
-	for entry == nil {
+	for rc.entries.Len() == 0 {
 		if rc.closed {
 			return nil
 		}
-		entry = rc.entries.Front()
-		if entry == nil {
-			rc.cond.Wait()
-		}
+		rc.cond.Wait()
+	}
+	if rc.closed {
+		return nil
+	}
+	maxItems := 1
+	if rc.batchSize > 1 && !rc.batchUnsupported {
+		maxItems = rc.batchSize
+	}
+	for entry := rc.entries.Front(); entry != nil && len(batch) < maxItems; entry = rc.entries.Front() {
+		batch = append(batch, entry.Value.(*pendingItem))
+		_ = rc.entries.Remove(entry) // remove from the list, but don't unset entry.queuedStale yet
 	}
-	p = entry.Value.(*pendingItem)
-	_ = rc.entries.Remove(entry) // remove from the list, but don't unset entry.queuedStale yet
-	return p
+	return batch
 }
 
 func (rc *receiptChecker) run(i int) {
@@ -92,38 +112,14 @@ func (rc *receiptChecker) run(i int) {
 		// but in the case of errors we re-queue the individual item to the back of the
 		// queue so individual queued items do not get stuck for unrecoverable errors.
 		err := rc.bcm.retry.Do(ctx, "receipt check", func(attempt int) (bool, error) {
-			pending := rc.waitNext()
-			if pending == nil {
+			batch := rc.waitNext()
+			if batch == nil {
 				return false /* exit the retry loop with err */, i18n.NewError(ctx, tmmsgs.MsgShuttingDown)
 			}
-
-			res, reason, receiptErr := rc.bcm.connector.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{
-				TransactionHash: pending.transactionHash,
-			})
-			if receiptErr != nil || res == nil {
-				if receiptErr != nil && reason != ffcapi.ErrorReasonNotFound {
-					log.L(ctx).Debugf("Failed to query receipt for transaction %s: %s", pending.transactionHash, receiptErr)
-					// It's possible though that the node will return a non-recoverable error for this item.
-					// So we push it to the back of the queue (we already removed it in waitNext, but left
-					// queuedStale set on there to prevent it being re-queued externally).
-					rc.cond.L.Lock()
-					pending.queuedStale = rc.entries.PushBack(pending)
-					rc.cond.L.Unlock()
-					return true /* drive the retry delay mechanism before next de-queue */, receiptErr
-				}
-				log.L(ctx).Debugf("Receipt for transaction %s not yet available: %v", pending.transactionHash, receiptErr)
+			if len(batch) == 1 {
+				return rc.checkSingle(ctx, batch[0])
 			}
-			// Regardless of whether we got a receipt, update the pending item
-			rc.cond.L.Lock()
-			pending.queuedStale = nil // only unmark the entry now (even though we popped it in waitNext)
-			pending.lastReceiptCheck = time.Now()
-			rc.cond.L.Unlock()
-
-			// Dispatch the receipt back to the main routine.
-			if res != nil {
-				rc.notify(pending, res)
-			}
-			return false, nil
+			return rc.checkBatch(ctx, batch)
 		})
 		// Error means the context has closed
 		if err != nil {
@@ -133,6 +129,89 @@ func (rc *receiptChecker) run(i int) {
 	}
 }
 
+func (rc *receiptChecker) checkSingle(ctx context.Context, pending *pendingItem) (bool, error) {
+	res, reason, receiptErr := rc.bcm.connector.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{
+		TransactionHash: pending.transactionHash,
+	})
+	if receiptErr != nil || res == nil {
+		if receiptErr != nil && reason != ffcapi.ErrorReasonNotFound {
+			log.L(ctx).Debugf("Failed to query receipt for transaction %s: %s", pending.transactionHash, receiptErr)
+			// It's possible though that the node will return a non-recoverable error for this item.
+			// So we push it to the back of the queue (we already removed it in waitNext, but left
+			// queuedStale set on there to prevent it being re-queued externally).
+			rc.requeue([]*pendingItem{pending}, false)
+			return true /* drive the retry delay mechanism before next de-queue */, receiptErr
+		}
+		log.L(ctx).Debugf("Receipt for transaction %s not yet available: %v", pending.transactionHash, receiptErr)
+	}
+	rc.complete(pending, res)
+	return false, nil
+}
+
+func (rc *receiptChecker) checkBatch(ctx context.Context, batch []*pendingItem) (bool, error) {
+	txHashes := make([]string, len(batch))
+	for i, pending := range batch {
+		txHashes[i] = pending.transactionHash
+	}
+	res, reason, receiptErr := rc.batcher.TransactionReceipts(ctx, &ffcapi.TransactionReceiptsRequest{
+		TransactionHashes: txHashes,
+	})
+	if receiptErr != nil {
+		if reason == ffcapi.ErrorReasonNotSupported {
+			// Put the items back at the front of the queue, in their original order, to be processed individually
+			log.L(ctx).Infof("Connector does not support batch receipt queries - falling back to individual queries: %s", receiptErr)
+			rc.requeue(batch, true)
+			return false, nil
+		}
+		log.L(ctx).Debugf("Failed to query receipts for batch of %d transactions: %s", len(batch), receiptErr)
+		rc.requeue(batch, false)
+		return true /* drive the retry delay mechanism before next de-queue */, receiptErr
+	}
+	for _, pending := range batch {
+		var receipt *ffcapi.TransactionReceiptResponse
+		if res != nil {
+			receipt = res.Receipts[pending.transactionHash]
+		}
+		if receipt == nil {
+			log.L(ctx).Debugf("Receipt for transaction %s not yet available", pending.transactionHash)
+		}
+		rc.complete(pending, receipt)
+	}
+	return false, nil
+}
+
+// requeue puts items that have been taken off the queue by waitNext back on the queue.
+// When falling back from a batch, the items go back to the front with batching disabled.
+func (rc *receiptChecker) requeue(items []*pendingItem, batchUnsupported bool) {
+	rc.cond.L.Lock()
+	defer rc.cond.L.Unlock()
+	if batchUnsupported {
+		rc.batchUnsupported = true
+		for i := len(items) - 1; i >= 0; i-- {
+			items[i].queuedStale = rc.entries.PushFront(items[i])
+		}
+		rc.cond.Broadcast()
+		return
+	}
+	for _, pending := range items {
+		pending.queuedStale = rc.entries.PushBack(pending)
+	}
+}
+
+// complete updates the pending item after a successful check, and dispatches the receipt if there is one
+func (rc *receiptChecker) complete(pending *pendingItem, res *ffcapi.TransactionReceiptResponse) {
+	// Regardless of whether we got a receipt, update the pending item
+	rc.cond.L.Lock()
+	pending.queuedStale = nil // only unmark the entry now (even though we popped it in waitNext)
+	pending.lastReceiptCheck = time.Now()
+	rc.cond.L.Unlock()
+
+	// Dispatch the receipt back to the main routine.
+	if res != nil {
+		rc.notify(pending, res)
+	}
+}
+
 func (rc *receiptChecker) schedule(pending *pendingItem, suspectedTimeout bool) {
 	rc.cond.L.Lock()
 	// Do a locked check again on the time, and check not already queued
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -132,3 +133,154 @@ func TestCheckReceiptDoubleQueueProtection(t *testing.T) {
 	assert.Zero(t, bcm.receiptChecker.entries.Len())
 
 }
+
+func TestCheckReceiptBatch(t *testing.T) {
+
+	bcm, mca := newTestBlockConfirmationManager(t, false)
+	bcm.receiptChecker.batchSize = 2
+
+	txHash1 := "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"
+	txHash2 := "0x2dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"
+	txHash3 := "0x3dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"
+	receipt1 := &ffcapi.TransactionReceiptResponse{BlockHash: "0x12345"}
+	receipt3 := &ffcapi.TransactionReceiptResponse{BlockHash: "0x67890"}
+
+	mca.On("TransactionReceipts", mock.Anything, &ffcapi.TransactionReceiptsRequest{
+		TransactionHashes: []string{txHash1, txHash2},
+	}).Return(&ffcapi.TransactionReceiptsResponse{
+		Receipts: map[string]*ffcapi.TransactionReceiptResponse{txHash1: receipt1},
+	}, ffcapi.ErrorReason(""), nil).Once()
+	// A single remaining item uses the individual call
+	mca.On("TransactionReceipt", mock.Anything, &ffcapi.TransactionReceiptRequest{
+		TransactionHash: txHash3,
+	}).
+		Run(func(args mock.Arguments) {
+			bcm.receiptChecker.closed = true // to exit
+		}).
+		Return(receipt3, ffcapi.ErrorReason(""), nil).Once()
+
+	notified := map[string]*ffcapi.TransactionReceiptResponse{}
+	bcm.receiptChecker.notify = func(pi *pendingItem, receipt *ffcapi.TransactionReceiptResponse) {
+		notified[pi.transactionHash] = receipt
+	}
+
+	pending := []*pendingItem{
+		{pType: pendingTypeTransaction, transactionHash: txHash1},
+		{pType: pendingTypeTransaction, transactionHash: txHash2},
+		{pType: pendingTypeTransaction, transactionHash: txHash3},
+	}
+	for _, p := range pending {
+		bcm.receiptChecker.schedule(p, false)
+	}
+
+	bcm.receiptChecker.workersDone = []chan struct{}{make(chan struct{})}
+	bcm.receiptChecker.run(0)
+
+	assert.Zero(t, bcm.receiptChecker.entries.Len())
+	assert.Equal(t, map[string]*ffcapi.TransactionReceiptResponse{txHash1: receipt1, txHash3: receipt3}, notified)
+	for _, p := range pending {
+		assert.Nil(t, p.queuedStale)
+		assert.False(t, p.lastReceiptCheck.IsZero())
+	}
+	mca.AssertExpectations(t)
+
+}
+
+func TestCheckReceiptBatchNotSupportedFallback(t *testing.T) {
+
+	bcm, mca := newTestBlockConfirmationManager(t, false)
+	bcm.receiptChecker.batchSize = 10
+
+	txHash1 := "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"
+	txHash2 := "0x2dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"
+
+	mca.On("TransactionReceipts", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReasonNotSupported, fmt.Errorf("not supported")).Once()
+	checked := []string{}
+	mca.On("TransactionReceipt", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			checked = append(checked, args[1].(*ffcapi.TransactionReceiptRequest).TransactionHash)
+			if len(checked) == 2 {
+				bcm.receiptChecker.closed = true // to exit
+			}
+		}).
+		Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found"))
+
+	bcm.receiptChecker.schedule(&pendingItem{pType: pendingTypeTransaction, transactionHash: txHash1}, false)
+	bcm.receiptChecker.schedule(&pendingItem{pType: pendingTypeTransaction, transactionHash: txHash2}, false)
+
+	bcm.receiptChecker.workersDone = []chan struct{}{make(chan struct{})}
+	bcm.receiptChecker.run(0)
+
+	// Order is preserved on fallback, and we do not try the batch again
+	assert.True(t, bcm.receiptChecker.batchUnsupported)
+	assert.Equal(t, []string{txHash1, txHash2}, checked)
+	assert.Zero(t, bcm.receiptChecker.entries.Len())
+	mca.AssertExpectations(t)
+
+}
+
+func TestCheckReceiptBatchNotImplemented(t *testing.T) {
+
+	bcm, _ := newTestBlockConfirmationManager(t, false)
+	mca := &ffcapimocks.API{}
+	bcm.connector = mca
+	bcm.receiptChecker = newReceiptChecker(bcm, 0, 10)
+
+	txHash1 := "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"
+	txHash2 := "0x2dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"
+
+	// The connector does not implement batching, so we go straight to individual queries
+	checked := []string{}
+	mca.On("TransactionReceipt", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			checked = append(checked, args[1].(*ffcapi.TransactionReceiptRequest).TransactionHash)
+			if len(checked) == 2 {
+				bcm.receiptChecker.closed = true // to exit
+			}
+		}).
+		Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found"))
+
+	bcm.receiptChecker.schedule(&pendingItem{pType: pendingTypeTransaction, transactionHash: txHash1}, false)
+	bcm.receiptChecker.schedule(&pendingItem{pType: pendingTypeTransaction, transactionHash: txHash2}, false)
+
+	bcm.receiptChecker.workersDone = []chan struct{}{make(chan struct{})}
+	bcm.receiptChecker.run(0)
+
+	assert.True(t, bcm.receiptChecker.batchUnsupported)
+	assert.Equal(t, []string{txHash1, txHash2}, checked)
+	mca.AssertExpectations(t)
+
+}
+
+func TestCheckReceiptBatchFail(t *testing.T) {
+
+	bcm, mca := newTestBlockConfirmationManager(t, false)
+	bcm.receiptChecker.batchSize = 10
+
+	count := 0
+	mca.On("TransactionReceipts", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			count++
+			if count == 2 {
+				bcm.receiptChecker.closed = true // to exit
+			}
+		}).
+		Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	pending1 := &pendingItem{pType: pendingTypeTransaction, transactionHash: "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"}
+	pending2 := &pendingItem{pType: pendingTypeTransaction, transactionHash: "0x2dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"}
+	bcm.receiptChecker.schedule(pending1, false)
+	bcm.receiptChecker.schedule(pending2, false)
+
+	// Run the worker loop, and it should go round twice - driving the retry logic.
+	bcm.receiptChecker.workersDone = []chan struct{}{make(chan struct{})}
+	bcm.receiptChecker.run(0)
+
+	// We should have re-queued both, in order
+	assert.Equal(t, 2, bcm.receiptChecker.entries.Len())
+	assert.Equal(t, pending1, bcm.receiptChecker.entries.Front().Value)
+	assert.Equal(t, pending2, bcm.receiptChecker.entries.Back().Value)
+	assert.False(t, bcm.receiptChecker.batchUnsupported)
+
+}
@@ -33,18 +33,29 @@ const metricsTransactionManagerComponentName = "transaction_manager"
 // REST api-server and transaction handler are sub-subsystem
 var metricsTransactionHandlerSubsystemName = "th"
 var metricsRESTAPIServerSubSystemName = "api_server_rest"
+var metricsConnectorSubsystemName = "connector"
+
+const metricsGaugeCircuitBreakerOpen = "circuit_breaker_open"
+const metricsGaugeCircuitBreakerOpenDescription = "Set to 1 while the connector circuit breaker is open, and calls to the connector are being rejected"
+const metricsCounterCircuitBreakerRejected = "circuit_breaker_rejected_total"
+const metricsCounterCircuitBreakerRejectedDescription = "Number of connector calls rejected because the circuit breaker was open"
+const metricsCounterSpendPolicyRejected = "spend_policy_rejected_total"
+const metricsCounterSpendPolicyRejectedDescription = "Number of new transactions rejected by the spend policy grouped by rule"
+const metricsLabelNameRule = "rule"
 
 type metricsManager struct {
 	ctx                     context.Context
 	metricsEnabled          bool
 	metricsRegistry         metric.MetricsRegistry
 	txHandlerMetricsManager metric.MetricsManager
+	connectorMetricsManager metric.MetricsManager
 	timeMap                 map[string]time.Time
 }
 
 func NewMetricsManager(ctx context.Context) Metrics {
 	metricsRegistry := metric.NewPrometheusMetricsRegistry(metricsTransactionManagerComponentName)
 	txHandlerMetricsManager, _ := metricsRegistry.NewMetricsManagerForSubsystem(ctx, metricsTransactionHandlerSubsystemName)
+	connectorMetricsManager, _ := metricsRegistry.NewMetricsManagerForSubsystem(ctx, metricsConnectorSubsystemName)
 	_ = metricsRegistry.NewHTTPMetricsInstrumentationsForSubsystem(
 		ctx,
 		metricsRESTAPIServerSubSystemName,
@@ -58,6 +69,7 @@ func NewMetricsManager(ctx context.Context) Metrics {
 		timeMap:                 make(map[string]time.Time),
 		metricsRegistry:         metricsRegistry,
 		txHandlerMetricsManager: txHandlerMetricsManager,
+		connectorMetricsManager: connectorMetricsManager,
 	}
 
 	return mm
@@ -87,6 +99,60 @@ type Metrics interface {
 
 	// functions for transaction handler to define and emit metrics
 	TransactionHandlerMetrics
+
+	// functions for the circuit breaker in front of the connector
+	ConnectorMetrics
+
+	// functions for the spend policy checked for new transactions
+	SpendPolicyMetrics
+}
+
+// Connector metrics are emitted by the circuit breaker in front of the connector, when enabled
+type ConnectorMetrics interface {
+	InitConnectorCircuitBreakerMetrics(ctx context.Context)
+	SetConnectorCircuitBreakerOpen(ctx context.Context, open bool)
+	IncConnectorCircuitBreakerRejected(ctx context.Context)
+}
+
+func (mm *metricsManager) InitConnectorCircuitBreakerMetrics(ctx context.Context) {
+	if mm.metricsEnabled {
+		mm.connectorMetricsManager.NewGaugeMetric(ctx, metricsGaugeCircuitBreakerOpen, metricsGaugeCircuitBreakerOpenDescription, false)
+		mm.connectorMetricsManager.NewCounterMetric(ctx, metricsCounterCircuitBreakerRejected, metricsCounterCircuitBreakerRejectedDescription, false)
+	}
+}
+
+func (mm *metricsManager) SetConnectorCircuitBreakerOpen(ctx context.Context, open bool) {
+	if mm.metricsEnabled {
+		var value float64
+		if open {
+			value = 1
+		}
+		mm.connectorMetricsManager.SetGaugeMetric(ctx, metricsGaugeCircuitBreakerOpen, value, nil)
+	}
+}
+
+func (mm *metricsManager) IncConnectorCircuitBreakerRejected(ctx context.Context) {
+	if mm.metricsEnabled {
+		mm.connectorMetricsManager.IncCounterMetric(ctx, metricsCounterCircuitBreakerRejected, nil)
+	}
+}
+
+// Spend policy metrics are emitted when new transactions are rejected, when a spend policy is enabled
+type SpendPolicyMetrics interface {
+	InitSpendPolicyMetrics(ctx context.Context)
+	IncSpendPolicyRejected(ctx context.Context, rule string)
+}
+
+func (mm *metricsManager) InitSpendPolicyMetrics(ctx context.Context) {
+	if mm.metricsEnabled {
+		mm.txHandlerMetricsManager.NewCounterMetricWithLabels(ctx, metricsCounterSpendPolicyRejected, metricsCounterSpendPolicyRejectedDescription, []string{metricsLabelNameRule}, false)
+	}
+}
+
+func (mm *metricsManager) IncSpendPolicyRejected(ctx context.Context, rule string) {
+	if mm.metricsEnabled {
+		mm.txHandlerMetricsManager.IncCounterMetricWithLabels(ctx, metricsCounterSpendPolicyRejected, map[string]string{metricsLabelNameRule: rule}, nil)
+	}
 }
 
 // Transaction handler metrics are defined and emitted by transaction handlers
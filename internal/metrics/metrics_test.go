@@ -97,3 +97,23 @@ func TestIsMetricsEnabledFalse(t *testing.T) {
 	mm.metricsEnabled = false
 	assert.Equal(t, mm.IsMetricsEnabled(), false)
 }
+
+func TestConnectorMetrics(t *testing.T) {
+	ctx := context.Background()
+	mm, cancel := newTestMetricsManager(t)
+	defer cancel()
+	mm.metricsEnabled = true
+	mm.InitConnectorCircuitBreakerMetrics(ctx)
+	mm.SetConnectorCircuitBreakerOpen(ctx, true)
+	mm.SetConnectorCircuitBreakerOpen(ctx, false)
+	mm.IncConnectorCircuitBreakerRejected(ctx)
+}
+
+func TestSpendPolicyMetrics(t *testing.T) {
+	ctx := context.Background()
+	mm, cancel := newTestMetricsManager(t)
+	defer cancel()
+	mm.metricsEnabled = true
+	mm.InitSpendPolicyMetrics(ctx)
+	mm.IncSpendPolicyRejected(ctx, "max_value")
+}
@@ -493,6 +493,51 @@ func (p *leveldbPersistence) InsertTransactionWithNextNonce(ctx context.Context,
 
 }
 
+func (p *leveldbPersistence) InsertTransactionsWithNextNonce(ctx context.Context, txs []*apitypes.ManagedTX, nextNonceCB persistence.NextNonceCallback) []error {
+	errs := make([]error, len(txs))
+	signers := []string{}
+	txIndexesBySigner := make(map[string][]int)
+	for i, tx := range txs {
+		if _, ok := txIndexesBySigner[tx.From]; !ok {
+			signers = append(signers, tx.From)
+		}
+		txIndexesBySigner[tx.From] = append(txIndexesBySigner[tx.From], i)
+	}
+	for _, signer := range signers {
+		p.insertSignerTransactions(ctx, txs, txIndexesBySigner[signer], nextNonceCB, errs)
+	}
+	return errs
+}
+
+// insertSignerTransactions holds the nonce lock for the signer across all of its transactions, so they are
+// assigned consecutive nonces. A nonce is only moved past once a transaction has been written with it.
+func (p *leveldbPersistence) insertSignerTransactions(ctx context.Context, txs []*apitypes.ManagedTX, txIndexes []int, nextNonceCB persistence.NextNonceCallback, errs []error) {
+	first := txs[txIndexes[0]]
+	lockedNonce, err := p.assignAndLockNonce(ctx, first.ID, first.From, nextNonceCB)
+	if err != nil {
+		for _, i := range txIndexes {
+			errs[i] = err
+		}
+		return
+	}
+	defer lockedNonce.complete(ctx)
+
+	nextNonce := lockedNonce.nonce
+	for _, i := range txIndexes {
+		tx := txs[i]
+		tx.Nonce = fftypes.NewFFBigInt(int64(nextNonce))
+		if errs[i] = p.writeTransaction(ctx, &apitypes.TXWithStatus{
+			ManagedTX: tx,
+		}, true); errs[i] != nil {
+			tx.Nonce = nil
+			continue
+		}
+		lockedNonce.nonce = nextNonce
+		lockedNonce.spent = true
+		nextNonce++
+	}
+}
+
 func (p *leveldbPersistence) InsertTransactionPreAssignedNonce(ctx context.Context, tx *apitypes.ManagedTX) (err error) {
 	return p.writeTransaction(ctx, &apitypes.TXWithStatus{
 		ManagedTX: tx,
@@ -600,7 +645,7 @@ func (p *leveldbPersistence) writeTransaction(ctx context.Context, tx *apitypes.
 	tx.DeprecatedTransactionHeaders = nil
 
 	if tx.From == "" ||
-		tx.Nonce == nil ||
+		(new && tx.Nonce == nil) || // the nonce is cleared if it is released
 		tx.Created == nil ||
 		tx.ID == "" ||
 		tx.Status == "" {
@@ -652,12 +697,16 @@ func (p *leveldbPersistence) DeleteTransaction(ctx context.Context, txID string)
 	if err != nil || tx == nil {
 		return err
 	}
-	return p.deleteKeys(ctx,
+	keys := [][]byte{
 		txDataKey(txID),
 		txCreatedIndexKey(tx),
 		txPendingIndexKey(tx.SequenceID),
-		txNonceAllocationKey(tx.TransactionHeaders.From, tx.TransactionHeaders.Nonce),
-	)
+	}
+	if tx.Nonce != nil {
+		// A released nonce might now be allocated to another transaction
+		keys = append(keys, txNonceAllocationKey(tx.TransactionHeaders.From, tx.TransactionHeaders.Nonce))
+	}
+	return p.deleteKeys(ctx, keys...)
 }
 
 func (p *leveldbPersistence) setSubStatusInStruct(ctx context.Context, tx *apitypes.TXWithStatus, subStatus apitypes.TxSubStatus) {
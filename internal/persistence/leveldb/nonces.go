@@ -49,10 +49,26 @@ func (ln *lockedNonce) complete(ctx context.Context) {
 
 func (p *leveldbPersistence) assignAndLockNonce(ctx context.Context, nsOpID, signer string, nextNonceCB persistence.NextNonceCallback) (*lockedNonce, error) {
 
+	locked := p.lockNonce(ctx, nsOpID, signer)
+
+	// We have to ensure we either successfully return a nonce,
+	// or otherwise we unlock when we send the error
+	nextNonce, err := p.calcNextNonce(ctx, signer, nextNonceCB)
+	if err != nil {
+		locked.complete(ctx)
+		return nil, err
+	}
+	locked.nonce = nextNonce
+	return locked, nil
+
+}
+
+// lockNonce blocks until it holds the nonce lock for the signer - complete() must be called to release it
+func (p *leveldbPersistence) lockNonce(ctx context.Context, nsOpID, signer string) *lockedNonce {
+
 	for {
 		// Take the lock to query our nonce cache, and check if we are already locked
 		p.nonceMux.Lock()
-		doLookup := false
 		locked, isLocked := p.lockedNonces[signer]
 		if !isLocked {
 			locked = &lockedNonce{
@@ -62,25 +78,15 @@ func (p *leveldbPersistence) assignAndLockNonce(ctx context.Context, nsOpID, sig
 				unlocked: make(chan struct{}),
 			}
 			p.lockedNonces[signer] = locked
-			doLookup = true
 		}
 		p.nonceMux.Unlock()
 
-		// If we're locked, then wait
-		if isLocked {
-			log.L(ctx).Debugf("Contention for next nonce for signer %s", signer)
-			<-locked.unlocked
-		} else if doLookup {
-			// We have to ensure we either successfully return a nonce,
-			// or otherwise we unlock when we send the error
-			nextNonce, err := p.calcNextNonce(ctx, signer, nextNonceCB)
-			if err != nil {
-				locked.complete(ctx)
-				return nil, err
-			}
-			locked.nonce = nextNonce
-			return locked, nil
+		if !isLocked {
+			return locked
 		}
+		// If we're locked, then wait
+		log.L(ctx).Debugf("Contention for next nonce for signer %s", signer)
+		<-locked.unlocked
 	}
 
 }
@@ -121,3 +127,33 @@ func (p *leveldbPersistence) calcNextNonce(ctx context.Context, signer string, n
 	return nextNonce, nil
 
 }
+
+// ReleaseTransactionNonce returns the nonce of a transaction that was never submitted, so that it is allocated
+// to the next transaction for the signer. This is only possible when it is the highest nonce we have allocated.
+func (p *leveldbPersistence) ReleaseTransactionNonce(ctx context.Context, signer, txID string) (bool, error) {
+
+	// Hold the nonce lock for the signer, so we cannot race with the allocation of the next nonce
+	locked := p.lockNonce(ctx, txID, signer)
+	defer locked.complete(ctx)
+
+	tx, err := p.getPersistedTX(ctx, txID)
+	var laterTxns []*apitypes.ManagedTX
+	if err == nil && tx.From == signer && tx.Nonce != nil {
+		laterTxns, err = p.ListTransactionsByNonce(ctx, signer, tx.Nonce, 1, persistence.SortDirectionAscending)
+	}
+	if err != nil || tx.From != signer || tx.Nonce == nil || len(laterTxns) > 0 {
+		log.L(ctx).Debugf("Nonce of TX '%s' cannot be released for signer '%s' (err=%v)", txID, signer, err)
+		return false, err
+	}
+	locked.nonce = tx.Nonce.Uint64()
+	log.L(ctx).Infof("Releasing nonce '%s' / '%d' from TX '%s'", signer, locked.nonce, txID)
+
+	// Remove the allocation index first, so the nonce calculation cannot see this transaction
+	err = p.deleteKeys(ctx, txNonceAllocationKey(signer, tx.Nonce))
+	if err == nil {
+		tx.Nonce = nil
+		err = p.writeTransaction(ctx, tx, false)
+	}
+	return err == nil, err
+
+}
@@ -207,3 +207,142 @@ func TestNonceListStaleBehind(t *testing.T) {
 	assert.Equal(t, int64(1002), tx2.Nonce.Int64())
 
 }
+
+func TestReleaseTransactionNonce(t *testing.T) {
+
+	ctx, p, done := newTestLevelDBPersistence(t)
+	defer done()
+	p.nonceStateTimeout = 1 * time.Hour
+
+	newTX := func() *apitypes.ManagedTX {
+		return &apitypes.ManagedTX{
+			ID:      "ns1:" + fftypes.NewUUID().String(),
+			Created: fftypes.Now(),
+			Status:  apitypes.TxStatusPending,
+			TransactionHeaders: ffcapi.TransactionHeaders{
+				From: "0x12345",
+			},
+		}
+	}
+	nextNonce := func(ctx context.Context, signer string) (uint64, error) { return 1000, nil }
+
+	tx1 := newTX()
+	err := p.InsertTransactionWithNextNonce(ctx, tx1, nextNonce)
+	assert.NoError(t, err)
+	tx2 := newTX()
+	err = p.InsertTransactionWithNextNonce(ctx, tx2, nextNonce)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1001), tx2.Nonce.Int64())
+
+	// Cannot release a nonce with a later nonce allocated
+	released, err := p.ReleaseTransactionNonce(ctx, "0x12345", tx1.ID)
+	assert.NoError(t, err)
+	assert.False(t, released)
+
+	released, err = p.ReleaseTransactionNonce(ctx, "0x12345", tx2.ID)
+	assert.NoError(t, err)
+	assert.True(t, released)
+	tx, err := p.GetTransactionByID(ctx, tx2.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, tx.Nonce)
+
+	// The nonce is allocated to the next transaction
+	tx3 := newTX()
+	err = p.InsertTransactionWithNextNonce(ctx, tx3, nextNonce)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1001), tx3.Nonce.Int64())
+
+	// Deleting the released transaction does not affect the allocation
+	err = p.DeleteTransaction(ctx, tx2.ID)
+	assert.NoError(t, err)
+	tx, err = p.GetTransactionByNonce(ctx, "0x12345", fftypes.NewFFBigInt(1001))
+	assert.NoError(t, err)
+	assert.Equal(t, tx3.ID, tx.ID)
+
+	// Nothing to release for a different signer, or once released
+	released, err = p.ReleaseTransactionNonce(ctx, "0x23456", tx3.ID)
+	assert.NoError(t, err)
+	assert.False(t, released)
+	released, err = p.ReleaseTransactionNonce(ctx, "0x12345", tx2.ID)
+	assert.Regexp(t, "FF21067", err)
+	assert.False(t, released)
+
+}
+
+func TestReleaseTransactionNonceFail(t *testing.T) {
+
+	ctx, p, done := newTestLevelDBPersistence(t)
+	done()
+
+	_, err := p.ReleaseTransactionNonce(ctx, "0x12345", "tx1")
+	assert.Regexp(t, "FF21055", err)
+
+}
+
+func TestInsertTransactionsWithNextNonce(t *testing.T) {
+
+	ctx, p, done := newTestLevelDBPersistence(t)
+	defer done()
+
+	newTX := func(id, signer string) *apitypes.ManagedTX {
+		return &apitypes.ManagedTX{
+			ID:      id,
+			Created: fftypes.Now(),
+			Status:  apitypes.TxStatusPending,
+			TransactionHeaders: ffcapi.TransactionHeaders{
+				From: signer,
+			},
+		}
+	}
+	err := p.writeTransaction(ctx, &apitypes.TXWithStatus{ManagedTX: &apitypes.ManagedTX{
+		ID:      "existing",
+		Created: fftypes.Now(),
+		Status:  apitypes.TxStatusPending,
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From:  "0xaaaaa",
+			Nonce: fftypes.NewFFBigInt(1),
+		},
+	}}, true)
+	assert.NoError(t, err)
+
+	txs := []*apitypes.ManagedTX{
+		newTX("tx1", "0xaaaaa"),
+		newTX("tx2", "0xbbbbb"),
+		newTX("existing", "0xaaaaa"), // duplicate
+		newTX("tx3", "0xaaaaa"),
+		newTX("tx4", "0xbbbbb"),
+	}
+	errs := p.InsertTransactionsWithNextNonce(ctx, txs, func(ctx context.Context, signer string) (uint64, error) {
+		assert.Equal(t, "0xbbbbb", signer)
+		return 100, nil
+	})
+	assert.Len(t, errs, 5)
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.Regexp(t, "FF21065", errs[2])
+	assert.NoError(t, errs[3])
+	assert.NoError(t, errs[4])
+	assert.Equal(t, int64(2), txs[0].Nonce.Int64())
+	assert.Equal(t, int64(100), txs[1].Nonce.Int64())
+	assert.Nil(t, txs[2].Nonce)
+	assert.Equal(t, int64(3), txs[3].Nonce.Int64())
+	assert.Equal(t, int64(101), txs[4].Nonce.Int64())
+
+}
+
+func TestInsertTransactionsWithNextNonceFail(t *testing.T) {
+
+	ctx, p, done := newTestLevelDBPersistence(t)
+	defer done()
+
+	errs := p.InsertTransactionsWithNextNonce(ctx, []*apitypes.ManagedTX{
+		{ID: "tx1", TransactionHeaders: ffcapi.TransactionHeaders{From: "0x12345"}},
+		{ID: "tx2", TransactionHeaders: ffcapi.TransactionHeaders{From: "0x12345"}},
+	}, func(ctx context.Context, signer string) (uint64, error) {
+		return 0, fmt.Errorf("pop")
+	})
+	assert.Len(t, errs, 2)
+	assert.Regexp(t, "pop", errs[0])
+	assert.Regexp(t, "pop", errs[1])
+
+}
@@ -97,6 +97,11 @@ var TransactionFilters = &ffapi.QueryFields{
 	"firstsubmit":     &ffapi.TimeField{},
 	"lastsubmit":      &ffapi.TimeField{},
 	"errormessage":    &ffapi.StringField{},
+	"priority":        &ffapi.Int64Field{},
+	"notbefore":       &ffapi.TimeField{},
+	"expiry":          &ffapi.TimeField{},
+	"dependson":       &ffapi.StringField{},
+	"simulate":        &ffapi.BoolField{},
 }
 
 var ConfirmationFilters = &ffapi.QueryFields{
@@ -183,8 +188,10 @@ type TransactionPersistence interface {
 	GetTransactionByNonce(ctx context.Context, signer string, nonce *fftypes.FFBigInt) (*apitypes.ManagedTX, error)
 	InsertTransactionPreAssignedNonce(ctx context.Context, tx *apitypes.ManagedTX) error
 	InsertTransactionWithNextNonce(ctx context.Context, tx *apitypes.ManagedTX, lookupNextNonce NextNonceCallback) error
+	InsertTransactionsWithNextNonce(ctx context.Context, txs []*apitypes.ManagedTX, lookupNextNonce NextNonceCallback) []error // consecutive nonces are assigned to the transactions of each signer, in order - an error inserting one might fail the others from the same signer
 	UpdateTransaction(ctx context.Context, txID string, updates *apitypes.TXUpdates) error
 	DeleteTransaction(ctx context.Context, txID string) error
+	ReleaseTransactionNonce(ctx context.Context, signer, txID string) (released bool, err error) // only succeeds for the highest nonce of the signer
 
 	GetTransactionReceipt(ctx context.Context, txID string) (receipt *ffcapi.TransactionReceiptResponse, err error)
 	SetTransactionReceipt(ctx context.Context, txID string, receipt *ffcapi.TransactionReceiptResponse) error
@@ -22,6 +22,7 @@ import (
 	"hash/fnv"
 	"time"
 
+	sq "github.com/Masterminds/squirrel"
 	lru "github.com/hashicorp/golang-lru/v2"
 
 	"github.com/hyperledger/firefly-common/pkg/config"
@@ -45,10 +46,13 @@ type transactionOperation struct {
 	nextNonceCB        persistence.NextNonceCallback
 	txUpdate           *apitypes.TXUpdates
 	txDelete           *string
+	nonceRelease       string // the signer
+	nonceReleased      bool
 	clearConfirmations bool
 	confirmation       *apitypes.ConfirmationRecord
 	receipt            *apitypes.ReceiptRecord
 	historyRecord      *apitypes.TXHistoryRecord
+	group              []*transactionOperation // inserts for a single signer, that must be added to the same batch
 }
 
 type txCacheEntry struct {
@@ -83,6 +87,7 @@ type transactionWriterBatch struct {
 	txInsertsByFrom     map[string][]*transactionOperation
 	txUpdates           []*transactionOperation
 	txDeletes           []string
+	nonceReleases       []*transactionOperation
 	receiptInserts      map[string]*apitypes.ReceiptRecord
 	historyInserts      []*apitypes.TXHistoryRecord
 	compressionChecks   map[string]bool
@@ -151,9 +156,15 @@ func (tw *transactionWriter) queue(ctx context.Context, op *transactionOperation
 	// then there is no deterministic ordering guarantee possible regardless.
 
 	var hashKey string
-	if op.txInsert != nil {
+	switch {
+	case op.group != nil:
+		hashKey = op.group[0].txInsert.From
+	case op.txInsert != nil:
 		hashKey = op.txInsert.From
-	} else {
+	case op.nonceRelease != "":
+		// Nonces are released on the same routine that allocates them for the signer
+		hashKey = op.nonceRelease
+	default:
 		hashKey = op.txID
 	}
 	if hashKey == "" {
@@ -175,6 +186,22 @@ func (tw *transactionWriter) queue(ctx context.Context, op *transactionOperation
 	}
 }
 
+// queueGroup queues a set of insert operations for a single signer as one unit, so they are added to
+// the same batch with no other inserts for the signer interleaved - and hence are assigned consecutive nonces.
+func (tw *transactionWriter) queueGroup(ctx context.Context, ops []*transactionOperation) {
+	groupOp := newTransactionOperation(ops[0].txID)
+	groupOp.group = ops
+	tw.queue(ctx, groupOp)
+	// Any error from queuing belongs to each of the operations in the group
+	select {
+	case err := <-groupOp.done:
+		for _, op := range ops {
+			op.done <- err
+		}
+	default:
+	}
+}
+
 func (tw *transactionWriter) worker(i int) {
 	defer close(tw.workersDone[i])
 	workerID := fmt.Sprintf("tx_writer_%.4d", i)
@@ -206,7 +233,11 @@ func (tw *transactionWriter) worker(i int) {
 				batch.timeoutContext, batch.timeoutCancel = context.WithTimeout(ctx, tw.batchTimeout)
 				batchCount++
 			}
-			batch.ops = append(batch.ops, op)
+			if op.group != nil {
+				batch.ops = append(batch.ops, op.group...)
+			} else {
+				batch.ops = append(batch.ops, op)
+			}
 		case <-timeoutContext.Done():
 			timedOut = true
 			select {
@@ -242,6 +273,8 @@ func (tw *transactionWriter) runBatch(ctx context.Context, b *transactionWriterB
 				b.txInsertsByFrom[op.txInsert.From] = append(b.txInsertsByFrom[op.txInsert.From], op)
 			case op.txUpdate != nil:
 				b.txUpdates = append(b.txUpdates, op)
+			case op.nonceRelease != "":
+				b.nonceReleases = append(b.nonceReleases, op)
 			case op.txDelete != nil:
 				b.txDeletes = append(b.txDeletes, *op.txDelete)
 				delete(b.compressionChecks, op.txID)
@@ -322,7 +355,8 @@ func (tw *transactionWriter) assignNonces(ctx context.Context, txInsertsByFrom m
 					log.L(ctx).Tracef("Using the cached existing nonce %s / %d to compare with the queried next %d for transaction %s", signer, internalNextNonce, nextNonce, op.txInsert.ID)
 				} else {
 					// when there is no cached nonce we need to fetch the highest nonce in our DB
-					filter := persistence.TransactionFilters.NewFilterLimit(ctx, 1).Eq("from", signer).Sort("-nonce")
+					fb := persistence.TransactionFilters.NewFilterLimit(ctx, 1)
+					filter := fb.And(fb.Eq("from", signer), fb.Neq("nonce", nil)).Sort("-nonce")
 					existingTXs, _, err := tw.p.transactions.GetMany(ctx, filter)
 					if err != nil {
 						log.L(ctx).Errorf("Failed to query highest persisted nonce for '%s': %s", signer, err)
@@ -352,6 +386,38 @@ func (tw *transactionWriter) assignNonces(ctx context.Context, txInsertsByFrom m
 	return nil
 }
 
+func (tw *transactionWriter) releaseNonces(ctx context.Context, nonceReleases []*transactionOperation) error {
+	for _, op := range nonceReleases {
+		signer := op.nonceRelease
+		txns, err := tw.p.ListTransactionsByNonce(ctx, signer, nil, 1, persistence.SortDirectionDescending)
+		if err != nil {
+			log.L(ctx).Errorf("Failed to query highest persisted nonce for '%s': %s", signer, err)
+			return err
+		}
+		if len(txns) == 0 || txns[0].ID != op.txID {
+			log.L(ctx).Debugf("Nonce of TX '%s' is not the highest allocated for signer '%s' and cannot be released", op.txID, signer)
+			continue
+		}
+		log.L(ctx).Infof("Releasing nonce '%s' / '%s' from TX '%s'", signer, txns[0].Nonce, op.txID)
+		// The unique index on the nonce means we must clear it on the transaction (which we cannot
+		// do with a typed update), before the next transaction can be allocated the same nonce
+		ctx, tx, _, err := tw.p.db.BeginOrUseTx(ctx) // we are always within the batch DB transaction
+		if err != nil {
+			return err
+		}
+		if _, err := tw.p.db.UpdateTx(ctx, tw.p.transactions.Table, tx,
+			sq.Update(tw.p.transactions.Table).Set("tx_nonce", nil).Where(sq.Eq{"id": op.txID}),
+			nil,
+		); err != nil {
+			log.L(ctx).Errorf("Failed to release nonce from TX '%s': %s", op.txID, err)
+			return err
+		}
+		_ = tw.nextNonceCache.Remove(signer)
+		op.nonceReleased = true
+	}
+	return nil
+}
+
 func (tw *transactionWriter) clearCachedNonces(ctx context.Context, txInsertsByFrom map[string][]*transactionOperation) {
 	for signer := range txInsertsByFrom {
 		log.L(ctx).Warnf("Clearing cache for '%s' after insert failure", signer)
@@ -396,6 +462,10 @@ func (tw *transactionWriter) executeBatchOps(ctx context.Context, b *transaction
 		return err
 	}
 
+	// Release any nonces, before we allocate nonces to new transactions
+	if err := tw.releaseNonces(ctx, b.nonceReleases); err != nil {
+		return err
+	}
 	// Insert all the transactions
 	if len(txInserts) > 0 {
 		if err := tw.assignNonces(ctx, b.txInsertsByFrom); err != nil {
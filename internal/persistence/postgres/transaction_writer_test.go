@@ -46,6 +46,19 @@ func TestExecuteBatchOpsInsertBadOp(t *testing.T) {
 	assert.Regexp(t, "FF21086", err)
 }
 
+func TestQueueGroupBadOp(t *testing.T) {
+	ctx, p, _, done := newMockSQLPersistence(t)
+	defer done()
+
+	errs := p.InsertTransactionsWithNextNonce(ctx, []*apitypes.ManagedTX{
+		{ID: "1" /* missing from */},
+		{ID: "2" /* missing from */},
+	}, func(ctx context.Context, signer string) (uint64, error) { return 0, nil })
+	assert.Len(t, errs, 2)
+	assert.Regexp(t, "FF21086", errs[0])
+	assert.Regexp(t, "FF21086", errs[1])
+}
+
 func TestExecuteBatchOpsInsertTXFailWrapped(t *testing.T) {
 	ctx, p, mdb, done := newMockSQLPersistence(t)
 	defer done()
@@ -128,6 +141,47 @@ func TestExecuteBatchOpsInsertTXFailQueryExistingNonce(t *testing.T) {
 	assert.NoError(t, mdb.ExpectationsWereMet())
 }
 
+func TestExecuteBatchOpsReleaseNonceQueryFail(t *testing.T) {
+	ctx, p, mdb, done := newMockSQLPersistence(t)
+	defer done()
+
+	mdb.ExpectBegin()
+	mdb.ExpectQuery("SELECT.*").WillReturnError(fmt.Errorf("pop"))
+	mdb.ExpectRollback()
+
+	p.writer.runBatch(ctx, &transactionWriterBatch{
+		ops: []*transactionOperation{
+			{
+				txID:         "1",
+				nonceRelease: "0x12345",
+				done:         make(chan error, 1)},
+		},
+	})
+
+	assert.NoError(t, mdb.ExpectationsWereMet())
+}
+
+func TestExecuteBatchOpsReleaseNonceUpdateFail(t *testing.T) {
+	ctx, p, mdb, done := newMockSQLPersistence(t)
+	defer done()
+
+	mdb.ExpectBegin()
+	mdb.ExpectQuery("SELECT.*").WillReturnRows(newTXRow(p))
+	mdb.ExpectExec("UPDATE.*").WillReturnError(fmt.Errorf("pop"))
+	mdb.ExpectRollback()
+
+	p.writer.runBatch(ctx, &transactionWriterBatch{
+		ops: []*transactionOperation{
+			{
+				txID:         "tx1",
+				nonceRelease: "0x12345",
+				done:         make(chan error, 1)},
+		},
+	})
+
+	assert.NoError(t, mdb.ExpectationsWereMet())
+}
+
 func TestExecuteBatchOpsInsertTXFailOverrideNonceBelowTx(t *testing.T) {
 	ctx, p, mdb, done := newMockSQLPersistence(t)
 	defer done()
@@ -50,6 +50,11 @@ func (p *sqlPersistence) newTransactionCollection(forMigration bool) *dbsql.Crud
 			"first_submit",
 			"last_submit",
 			"error_message",
+			"priority",
+			"not_before",
+			"expiry",
+			"depends_on",
+			"simulate",
 		},
 		FilterFieldMap: map[string]string{
 			"sequence":        p.db.SequenceColumn(),
@@ -66,6 +71,8 @@ func (p *sqlPersistence) newTransactionCollection(forMigration bool) *dbsql.Crud
 			"firstsubmit":     "first_submit",
 			"lastsubmit":      "last_submit",
 			"errormessage":    "error_message",
+			"notbefore":       "not_before",
+			"dependson":       "depends_on",
 		},
 		PatchDisabled: true,
 		TimesDisabled: forMigration,
@@ -107,6 +114,16 @@ func (p *sqlPersistence) newTransactionCollection(forMigration bool) *dbsql.Crud
 				return &inst.LastSubmit
 			case "error_message":
 				return &inst.ErrorMessage
+			case "priority":
+				return &inst.Priority
+			case "not_before":
+				return &inst.NotBefore
+			case "expiry":
+				return &inst.Expiry
+			case "depends_on":
+				return &inst.DependsOn
+			case "simulate":
+				return &inst.Simulate
 			}
 			return nil
 		},
@@ -143,6 +160,7 @@ func (p *sqlPersistence) ListTransactionsByNonce(ctx context.Context, signer str
 	fb := persistence.TransactionFilters.NewFilterLimit(ctx, uint64(limit))
 	conditions := []ffapi.Filter{
 		fb.Eq("from", signer),
+		fb.Neq("nonce", nil), // excludes transactions with a released nonce
 	}
 	if after != nil {
 		if dir == persistence.SortDirectionDescending {
@@ -244,6 +262,31 @@ func (p *sqlPersistence) InsertTransactionWithNextNonce(ctx context.Context, tx
 	return op.flush(ctx) // wait for completion
 }
 
+func (p *sqlPersistence) InsertTransactionsWithNextNonce(ctx context.Context, txs []*apitypes.ManagedTX, nextNonceCB persistence.NextNonceCallback) []error {
+	// Dispatch to TX writer, with the inserts for each signer queued together so they get consecutive nonces
+	ops := make([]*transactionOperation, len(txs))
+	signers := []string{}
+	opsBySigner := make(map[string][]*transactionOperation)
+	for i, tx := range txs {
+		op := newTransactionOperation(tx.ID)
+		op.txInsert = tx
+		op.nextNonceCB = nextNonceCB
+		ops[i] = op
+		if _, ok := opsBySigner[tx.From]; !ok {
+			signers = append(signers, tx.From)
+		}
+		opsBySigner[tx.From] = append(opsBySigner[tx.From], op)
+	}
+	for _, signer := range signers {
+		p.writer.queueGroup(ctx, opsBySigner[signer])
+	}
+	errs := make([]error, len(txs))
+	for i, op := range ops {
+		errs[i] = op.flush(ctx) // wait for completion
+	}
+	return errs
+}
+
 func (p *sqlPersistence) UpdateTransaction(ctx context.Context, txID string, updates *apitypes.TXUpdates) error {
 	// Dispatch to TX writer
 	op := newTransactionOperation(txID)
@@ -260,6 +303,17 @@ func (p *sqlPersistence) DeleteTransaction(ctx context.Context, txID string) err
 	return op.flush(ctx) // wait for completion
 }
 
+func (p *sqlPersistence) ReleaseTransactionNonce(ctx context.Context, signer, txID string) (bool, error) {
+	// Dispatch to TX writer, on the same routine that allocates nonces for the signer
+	op := newTransactionOperation(txID)
+	op.nonceRelease = signer
+	p.writer.queue(ctx, op)
+	if err := op.flush(ctx); err != nil {
+		return false, err
+	}
+	return op.nonceReleased, nil
+}
+
 func (p *sqlPersistence) updateTransaction(ctx context.Context, txID string, updates *apitypes.TXUpdates) error {
 	sqlUpdate := persistence.TransactionFilters.NewUpdate(ctx).S()
 	if updates.Status != nil {
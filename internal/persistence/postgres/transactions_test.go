@@ -349,6 +349,54 @@ func TestTransactionListByNoncePSQL(t *testing.T) {
 
 }
 
+func TestTransactionReleaseNoncePSQL(t *testing.T) {
+	logrus.SetLevel(logrus.TraceLevel)
+
+	ctx, p, _, done := initTestPSQL(t)
+	defer done()
+
+	newTX := func() *apitypes.ManagedTX {
+		tx := &apitypes.ManagedTX{
+			ID:     fmt.Sprintf("ns1:%s", fftypes.NewUUID()),
+			Status: apitypes.TxStatusPending,
+			TransactionHeaders: ffcapi.TransactionHeaders{
+				From: "0x12345",
+			},
+		}
+		err := p.InsertTransactionWithNextNonce(ctx, tx, func(ctx context.Context, signer string) (uint64, error) {
+			return 1000, nil
+		})
+		assert.NoError(t, err)
+		return tx
+	}
+
+	tx1 := newTX()
+	tx2 := newTX()
+	assert.Equal(t, int64(1001), tx2.Nonce.Int64())
+
+	// Cannot release a nonce with a later nonce allocated
+	released, err := p.ReleaseTransactionNonce(ctx, "0x12345", tx1.ID)
+	assert.NoError(t, err)
+	assert.False(t, released)
+
+	released, err = p.ReleaseTransactionNonce(ctx, "0x12345", tx2.ID)
+	assert.NoError(t, err)
+	assert.True(t, released)
+	tx, err := p.GetTransactionByID(ctx, tx2.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, tx.Nonce)
+
+	// The nonce is allocated to the next transaction
+	tx3 := newTX()
+	assert.Equal(t, int64(1001), tx3.Nonce.Int64())
+	list, err := p.ListTransactionsByNonce(ctx, "0x12345", nil, 0, persistence.SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	assert.Equal(t, tx3.ID, list[0].ID)
+	assert.Equal(t, tx1.ID, list[1].ID)
+
+}
+
 func TestTransactionPendingPSQL(t *testing.T) {
 	logrus.SetLevel(logrus.TraceLevel)
 
@@ -460,6 +508,58 @@ func TestTransactionMixConflictAndOkPSQL(t *testing.T) {
 
 }
 
+func TestInsertTransactionsWithNextNoncePSQL(t *testing.T) {
+	// Use a tiny batch size, so the group for each signer would be split without being queued together
+	ctx, p, _, done := initTestPSQL(t, func(dbconf config.Section) {
+		dbconf.Set(ConfigTXWriterCount, 1)
+		dbconf.Set(ConfigTXWriterBatchSize, 1)
+	})
+	defer done()
+
+	newTX := func(id, signer string) *apitypes.ManagedTX {
+		return &apitypes.ManagedTX{
+			ID:      id,
+			Created: fftypes.Now(),
+			Status:  apitypes.TxStatusPending,
+			TransactionHeaders: ffcapi.TransactionHeaders{
+				From: signer,
+			},
+		}
+	}
+	txs := []*apitypes.ManagedTX{
+		newTX("ns1:tx1", "signer_a"),
+		newTX("ns1:tx2", "signer_b"),
+		newTX("ns1:tx3", "signer_a"),
+		newTX("ns1:tx4", "signer_a"),
+	}
+	errs := p.InsertTransactionsWithNextNonce(ctx, txs, func(ctx context.Context, signer string) (uint64, error) {
+		if signer == "signer_a" {
+			return 10, nil
+		}
+		return 20, nil
+	})
+	assert.Len(t, errs, 4)
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int64(10), txs[0].Nonce.Int64())
+	assert.Equal(t, int64(20), txs[1].Nonce.Int64())
+	assert.Equal(t, int64(11), txs[2].Nonce.Int64())
+	assert.Equal(t, int64(12), txs[3].Nonce.Int64())
+
+	// A repeat of an ID fails just that transaction
+	txs = []*apitypes.ManagedTX{
+		newTX("ns1:tx1", "signer_a"),
+		newTX("ns1:tx5", "signer_a"),
+	}
+	errs = p.InsertTransactionsWithNextNonce(ctx, txs, func(ctx context.Context, signer string) (uint64, error) {
+		panic("nonce is cached")
+	})
+	assert.Regexp(t, "FF21065", errs[0])
+	assert.NoError(t, errs[1])
+	assert.Equal(t, int64(13), txs[1].Nonce.Int64())
+}
+
 func newTXRow(p *sqlPersistence) *sqlmock.Rows {
 	return sqlmock.NewRows(append([]string{p.db.SequenceColumn()}, p.transactions.Columns...)).AddRow(
 		12345,                  // seq
@@ -480,6 +580,11 @@ func newTXRow(p *sqlPersistence) *sqlmock.Rows {
 		nil,                    // "first_submit",
 		nil,                    // "last_submit",
 		"",                     // "error_message",
+		0,                      // "priority",
+		nil,                    // "not_before",
+		nil,                    // "expiry",
+		"",                     // "depends_on",
+		nil,                    // "simulate",
 	)
 }
 
@@ -27,11 +27,16 @@ import (
 var ffc = config.AddRootKey
 
 var (
+	ConnectorCircuitBreakerEnabled                = ffc("connector.circuitBreaker.enabled")
+	ConnectorCircuitBreakerFailureThreshold       = ffc("connector.circuitBreaker.failureThreshold")
+	ConnectorCircuitBreakerResetTimeout           = ffc("connector.circuitBreaker.resetTimeout")
+	ConnectorRecordingFile                        = ffc("connector.recording.file")
 	ConfirmationsRequired                         = ffc("confirmations.required")
 	ConfirmationsBlockQueueLength                 = ffc("confirmations.blockQueueLength")
 	ConfirmationsStaleReceiptTimeout              = ffc("confirmations.staleReceiptTimeout")
 	ConfirmationsNotificationQueueLength          = ffc("confirmations.notificationQueueLength")
 	ConfirmationsReceiptWorkers                   = ffc("confirmations.receiptWorkers")
+	ConfirmationsReceiptBatchSize                 = ffc("confirmations.receiptBatchSize")
 	ConfirmationsRetryInitDelay                   = ffc("confirmations.retry.initialDelay")
 	ConfirmationsRetryMaxDelay                    = ffc("confirmations.retry.maxDelay")
 	ConfirmationsRetryFactor                      = ffc("confirmations.retry.factor")
@@ -61,6 +66,13 @@ var (
 	TransactionsHandlerName                       = ffc("transactions.handler.name")
 	TransactionsMaxHistoryCount                   = ffc("transactions.maxHistoryCount")
 	TransactionsNonceStateTimeout                 = ffc("transactions.nonceStateTimeout")
+	TransactionsSpendPolicyEnabled                = ffc("transactions.spendPolicy.enabled")
+	TransactionsSpendPolicyNamespaceSigners       = ffc("transactions.spendPolicy.namespaceSigners")
+	TransactionsSpendPolicyAllowedDestinations    = ffc("transactions.spendPolicy.allowedDestinations")
+	TransactionsSpendPolicyMaxValue               = ffc("transactions.spendPolicy.maxValue")
+	TransactionsSpendPolicyWindow                 = ffc("transactions.spendPolicy.window")
+	TransactionsSpendPolicyWindowMaxValue         = ffc("transactions.spendPolicy.windowMaxValue")
+	TransactionsSpendPolicyWindowMaxGasLimit      = ffc("transactions.spendPolicy.windowMaxGasLimit")
 
 	// Deprecated Configurations for transaction handling
 	DeprecatedTransactionsMaxInFlight  = ffc("transactions.maxInFlight")
@@ -89,11 +101,17 @@ var MetricsConfig config.Section
 
 func setDefaults() {
 	viper.SetDefault(string(TransactionsMaxHistoryCount), 50)
+	viper.SetDefault(string(ConnectorCircuitBreakerEnabled), false)
+	viper.SetDefault(string(TransactionsSpendPolicyEnabled), false)
+	viper.SetDefault(string(TransactionsSpendPolicyWindow), "1h")
+	viper.SetDefault(string(ConnectorCircuitBreakerFailureThreshold), 5)
+	viper.SetDefault(string(ConnectorCircuitBreakerResetTimeout), "30s")
 	viper.SetDefault(string(ConfirmationsRequired), 20)
 	viper.SetDefault(string(ConfirmationsBlockQueueLength), 50)
 	viper.SetDefault(string(ConfirmationsNotificationQueueLength), 50)
 	viper.SetDefault(string(ConfirmationsStaleReceiptTimeout), "1m")
 	viper.SetDefault(string(ConfirmationsReceiptWorkers), 10)
+	viper.SetDefault(string(ConfirmationsReceiptBatchSize), 0)
 	viper.SetDefault(string(ConfirmationsRetryInitDelay), "100ms")
 	viper.SetDefault(string(ConfirmationsRetryMaxDelay), "15s")
 	viper.SetDefault(string(ConfirmationsRetryFactor), 2.0)
@@ -60,6 +60,9 @@ var (
 	APIEndpointPostSubscriptions            = ffm("api.endpoints.post.subscriptions", "Create new listener - route deprecated in favor of /eventstreams/{streamId}/listeners")
 	APIEndpointPostTransactionSuspend       = ffm("api.endpoints.post.transactions.suspend", "Suspend processing on a pending transaction (no-op for completed transactions)")
 	APIEndpointPostTransactionResume        = ffm("api.endpoints.post.transactions.resume", "Resume processing on a suspended transaction")
+	APIEndpointPatchTransaction             = ffm("api.endpoints.patch.transactions", "Change the gas price and/or gas limit of a pending transaction, replacing it at the same nonce if it has been submitted")
+	APIEndpointPostTransactionCancel        = ffm("api.endpoints.post.transactions.cancel", "Cancel a pending transaction, by replacing it at the same nonce with a transaction that does nothing")
+	APIEndpointPostTransactionBatch         = ffm("api.endpoints.post.transactions.batch", "Submit a batch of SendTransaction and DeployContract requests, with consecutive nonces assigned to the transactions of each signer in the order of the batch. A result is returned for each request, in the same order")
 
 	APIParamStreamID      = ffm("api.params.streamId", "Event Stream ID")
 	APIParamListenerID    = ffm("api.params.listenerId", "Listener ID")
@@ -38,6 +38,11 @@ var (
 	ConfigAPIPassthroughHeaders    = ffc("config.api.passthroughHeaders", "A list of HTTP request headers to pass through to dependency microservices", i18n.ArrayStringType)
 	ConfigAPISimpleQuery           = ffc("config.api.simpleQuery", "Force use of original limited API query syntax, even if rich query is supported in the database", i18n.BooleanType)
 
+	ConfigConnectorCircuitBreakerEnabled          = ffc("config.connector.circuitBreaker.enabled", "Enables a circuit breaker in front of the connector, which rejects calls while the downstream node is unavailable", i18n.BooleanType)
+	ConfigConnectorCircuitBreakerFailureThreshold = ffc("config.connector.circuitBreaker.failureThreshold", "Number of consecutive downstream_down failures that open the circuit breaker", i18n.IntType)
+	ConfigConnectorCircuitBreakerResetTimeout     = ffc("config.connector.circuitBreaker.resetTimeout", "How long the circuit breaker stays open before checking if the connector is ready again", i18n.TimeDurationType)
+	ConfigConnectorRecordingFile                  = ffc("config.connector.recording.file", "When set, every request, response and event exchanged with the connector is appended to this file as JSONL, so the session can be replayed", i18n.StringType)
+
 	ConfigDebugPort = ffc("config.debug.port", "An HTTP port on which to enable the go debugger", i18n.IntType)
 
 	ConfigConfirmationsBlockCacheSize           = ffc("config.confirmations.blockCacheSize", "The maximum number of block headers to keep in the cache", i18n.IntType)
@@ -46,9 +51,17 @@ var (
 	ConfigConfirmationsRequired                 = ffc("config.confirmations.required", "Number of confirmations required to consider a transaction/event final", i18n.IntType)
 	ConfigConfirmationsStaleReceiptTimeout      = ffc("config.confirmations.staleReceiptTimeout", "Duration after which to force a receipt check for a pending transaction", i18n.TimeDurationType)
 	ConfigConfirmationsReceiptWorkers           = ffc("config.confirmations.receiptWorkers", "Number of workers to use to query in parallel for receipts", i18n.IntType)
+	ConfigConfirmationsReceiptBatchSize         = ffc("config.confirmations.receiptBatchSize", "Maximum number of receipts each worker queries in a single batched call to the connector. Values less than 2 disable batching. Connectors that do not support batching fall back to individual queries", i18n.IntType)
 
-	ConfigTransactionsNonceStateTimeout = ffc("config.transactions.nonceStateTimeout", "How old the most recently submitted transaction record in our local state needs to be, before we make a request to the node to query the next nonce for a signing address", i18n.TimeDurationType)
-	ConfigTransactionsMaxHistoryCount   = ffc("config.transactions.maxHistoryCount", "The number of historical status updates to retain in the operation", i18n.IntType)
+	ConfigTransactionsNonceStateTimeout              = ffc("config.transactions.nonceStateTimeout", "How old the most recently submitted transaction record in our local state needs to be, before we make a request to the node to query the next nonce for a signing address", i18n.TimeDurationType)
+	ConfigTransactionsMaxHistoryCount                = ffc("config.transactions.maxHistoryCount", "The number of historical status updates to retain in the operation", i18n.IntType)
+	ConfigTransactionsSpendPolicyEnabled             = ffc("config.transactions.spendPolicy.enabled", "Enables checking each new transaction against the spend policy rules before it is accepted", i18n.BooleanType)
+	ConfigTransactionsSpendPolicyNamespaceSigners    = ffc("config.transactions.spendPolicy.namespaceSigners", "Map of namespace to the list of signers allowed to submit transactions in it. The namespace is the prefix of the transaction ID, and the '*' entry applies to namespaces without their own entry", "`map[string][]string`")
+	ConfigTransactionsSpendPolicyAllowedDestinations = ffc("config.transactions.spendPolicy.allowedDestinations", "The addresses transactions can be sent to. If empty, transactions can be sent to any address", i18n.ArrayStringType)
+	ConfigTransactionsSpendPolicyMaxValue            = ffc("config.transactions.spendPolicy.maxValue", "The maximum value of a single transaction", i18n.StringType)
+	ConfigTransactionsSpendPolicyWindow              = ffc("config.transactions.spendPolicy.window", "The rolling window for the limits on the total value and gas limit of the transactions of each signer", i18n.TimeDurationType)
+	ConfigTransactionsSpendPolicyWindowMaxValue      = ffc("config.transactions.spendPolicy.windowMaxValue", "The maximum total value of the transactions of a signer within the window", i18n.StringType)
+	ConfigTransactionsSpendPolicyWindowMaxGasLimit   = ffc("config.transactions.spendPolicy.windowMaxGasLimit", "The maximum total gas limit of the transactions of a signer within the window", i18n.StringType)
 
 	DeprecatedConfigTransactionsMaxInflight                  = ffc("config.transactions.maxInFlight", "Deprecated: Please use 'transactions.handler.simple.maxInFlight' instead", i18n.IntType)
 	DeprecatedConfigPolicyEngineName                         = ffc("config.policyengine.name", "Deprecated: Please use 'transactions.handler.name' instead", i18n.StringType)
@@ -66,21 +79,101 @@ var (
 	DeprecatedConfigLoopRetryFactor                          = ffc("config.policyloop.retry.factor", "Deprecated: Please use 'transactions.handler.simple.interval' instead", i18n.TimeDurationType)
 	DeprecatedConfigTXHandlerNonceStateTimeout               = ffc("config.transactions.handler.simple.nonceStateTimeout", "Deprecated: Please use 'transactions.handler.simple.nonceStateTimeout' instead", i18n.TimeDurationType)
 
-	ConfigTXHandlerName        = ffc("config.transactions.handler.name", "The name of the transaction handler to use", i18n.StringType)
-	ConfigTXHandlerMaxInflight = ffc("config.transactions.handler.simple.maxInFlight", "The maximum number of transactions to have in-flight with the transaction handler / blockchain transaction pool", i18n.IntType)
+	ConfigTXHandlerName                  = ffc("config.transactions.handler.name", "The name of the transaction handler to use", i18n.StringType)
+	ConfigTXHandlerMaxInflight           = ffc("config.transactions.handler.simple.maxInFlight", "The maximum number of transactions to have in-flight with the transaction handler / blockchain transaction pool", i18n.IntType)
+	ConfigTXHandlerMaxInflightPerSigner  = ffc("config.transactions.handler.simple.maxInFlightPerSigner", "The maximum number of transactions from a single signer to have in-flight. 0 means no limit other than maxInFlight", i18n.IntType)
+	ConfigTXHandlerSignerSelection       = ffc("config.transactions.handler.simple.signerSelection", "How pending transactions are selected across signers when filling the in-flight set", "'sequence', 'roundRobin' or 'weighted'")
+	ConfigTXHandlerDependencyFailure     = ffc("config.transactions.handler.simple.dependencyFailure", "What happens to a transaction when a transaction it depends on does not succeed. 'fail' cancels and fails the transaction, and 'hold' suspends it for intervention, with later transactions from the signer waiting behind it - resuming it submits the transaction regardless", "'fail' or 'hold'")
+	ConfigTXHandlerSimulate              = ffc("config.transactions.handler.simple.simulate", "Simulate each transaction before it is first submitted, and fail it without sending if it would revert - releasing its nonce for the next transaction from the signer where possible. Can be overridden per transaction with the simulate request header", i18n.BooleanType)
+	ConfigTXHandlerBatchConcurrency      = ffc("config.transactions.handler.simple.batchConcurrency", "The number of requests in a batch submitted to the API that are prepared concurrently", i18n.IntType)
+	ConfigTXHandlerPolicyWorkers         = ffc("config.transactions.handler.simple.policyWorkers", "The number of workers that execute the policy engine against the in-flight transactions concurrently. Each signer is owned by one worker, so the transactions from a signer are still processed in nonce order", i18n.IntType)
+	ConfigTXHandlerFundsCheckInterval    = ffc("config.transactions.handler.simple.insufficientFunds.balanceCheckInterval", "How often the balance of a signer is checked, while transactions from that signer are suspended because it could not pay for them. Suspended transactions are resumed in nonce order once the balance covers their estimated cost", i18n.TimeDurationType)
+	ConfigTXHandlerFundsSuspendLater     = ffc("config.transactions.handler.simple.insufficientFunds.suspendLaterNonces", "When a transaction is suspended because its signer could not pay for it, also suspend the transactions from that signer with later nonces that have not been submitted yet", i18n.BooleanType)
+	ConfigTXHandlerPriorityEnabled       = ffc("config.transactions.handler.simple.priority.enabled", "Select pending transactions with a higher priority in their request headers ahead of those with a lower priority when filling the in-flight set. Transactions from the same signer are always submitted in nonce order", i18n.BooleanType)
+	ConfigTXHandlerPriorityGasPriceTiers = ffc("config.transactions.handler.simple.priority.gasPriceTiers", "Map of minimum transaction priority, to the percentage to increase the gas price by for transactions at or above that priority", i18n.MapStringStringType)
+	ConfigTXHandlerSignerWeights         = ffc("config.transactions.handler.simple.signerWeights", "Weighted signer selection: the number of transactions to select from each signer address (lower case) in each round. Signers not listed have a weight of 1", i18n.MapStringStringType)
 
 	ConfigTXHandlerSimpleInterval               = ffc("config.transactions.handler.simple.interval", "Interval at which to invoke the transaction handler loop to evaluate outstanding transactions", i18n.TimeDurationType)
 	ConfigTXHandlerSimpleFixedGasPrice          = ffc("config.transactions.handler.simple.fixedGasPrice", "A fixed gasPrice value/structure to pass to the connector", "Raw JSON")
 	ConfigTXHandlerSimpleResubmitInterval       = ffc("config.transactions.handler.simple.resubmitInterval", "The time between warning and re-sending a transaction (same nonce) when a blockchain transaction has not been allocated a receipt", i18n.TimeDurationType)
+	ConfigTXHandlerSimpleStaleBlocks            = ffc("config.transactions.handler.simple.staleBlocks", "The number of new blocks without a receipt after which a transaction is treated as stale, in the same way as after the resubmitInterval. Whichever happens first applies. 0 disables", i18n.IntType)
 	ConfigTXHandlerSimpleRetryInitDelay         = ffc("config.transactions.handler.simple.retry.initialDelay", "Initial retry delay for retrieving transactions from the persistence", i18n.TimeDurationType)
 	ConfigTXHandlerSimpleRetryMaxDelay          = ffc("config.transactions.handler.simple.retry.maxDelay", "Maximum delay between retries for retrieving transactions from the persistence", i18n.TimeDurationType)
 	ConfigTXHandlerSimpleRetryFactor            = ffc("config.transactions.handler.simple.retry.factor", "Factor to increase the delay by, between each retry for retrieving transactions from the persistence", i18n.FloatType)
-	ConfigTXHandlerSimpleGasOracleEnabled       = ffc("config.transactions.handler.simple.gasOracle.mode", "The gas oracle mode", "'connector', 'restapi', 'fixed', or 'disabled'")
+	ConfigTXHandlerSimpleSubmitBackoffInitDelay = ffc("config.transactions.handler.simple.submitBackoff.initialDelay", "Initial delay before retrying submission of a transaction that the connector rejected as rate_limited or nonce_too_high", i18n.TimeDurationType)
+	ConfigTXHandlerSimpleSubmitBackoffMaxDelay  = ffc("config.transactions.handler.simple.submitBackoff.maxDelay", "Maximum delay between retries of submission of a transaction that the connector rejected as rate_limited or nonce_too_high", i18n.TimeDurationType)
+	ConfigTXHandlerSimpleSubmitBackoffFactor    = ffc("config.transactions.handler.simple.submitBackoff.factor", "Factor to increase the delay by, between each retry of submission of a transaction that the connector rejected as rate_limited or nonce_too_high", i18n.FloatType)
+	ConfigTXHandlerSimpleReplacementGasBump     = ffc("config.transactions.handler.simple.replacementGasPriceBump", "The percentage to increase the gas price by when the connector rejects a resubmission as replacement_underpriced", i18n.IntType)
+	ConfigTXHandlerSimpleMaxDroppedResubmits    = ffc("config.transactions.handler.simple.maxDroppedResubmits", "The number of times a stale transaction that the blockchain node no longer knows about will be resubmitted, before it is marked as failed. 0 means no limit", i18n.IntType)
+	ConfigTXHandlerSimpleGasEscalationPercent   = ffc("config.transactions.handler.simple.gasEscalation.percentage", "The percentage to increase the gas price by each time a stale transaction is resubmitted. 0 disables gas price escalation", i18n.IntType)
+	ConfigTXHandlerSimpleGasEscalationMinPct    = ffc("config.transactions.handler.simple.gasEscalation.minimumPercentage", "The minimum percentage increase over the last submitted gas price when escalating, so that the blockchain node accepts the resubmission as a replacement", i18n.IntType)
+	ConfigTXHandlerSimpleGasEscalationMax       = ffc("config.transactions.handler.simple.gasEscalation.maxGasPrice", "The maximum gasPrice value/structure that escalation will raise the gas price to. Each numeric field is capped separately", "Raw JSON")
+	ConfigTXHandlerSimpleGasOracleEnabled       = ffc("config.transactions.handler.simple.gasOracle.mode", "The gas oracle mode", "'connector', 'restapi', 'aggregate', 'fixed', or 'disabled'")
 	ConfigTXHandlerSimpleGasOracleGoTemplate    = ffc("config.transactions.handler.simple.gasOracle.template", "REST API Gas Oracle: A go template to execute against the result from the Gas Oracle, to create a JSON block that will be passed as the gas price to the connector", i18n.GoTemplateType)
 	ConfigTXHandlerSimpleGasOracleURL           = ffc("config.transactions.handler.simple.gasOracle.url", "REST API Gas Oracle: The URL of a Gas Oracle REST API to call", i18n.StringType)
 	ConfigTXHandlerSimpleGasOracleProxyURL      = ffc("config.transactions.handler.simple.gasOracle.proxy.url", "Optional HTTP proxy URL to use for the Gas Oracle REST API", i18n.StringType)
 	ConfigPTXHandlerSimpleGasOracleMethod       = ffc("config.transactions.handler.simple.gasOracle.method", "The HTTP Method to use when invoking the Gas Oracle REST API", i18n.StringType)
 	ConfigTXHandlerSimpleGasOracleQueryInterval = ffc("config.transactions.handler.simple.gasOracle.queryInterval", "The minimum interval between queries to the Gas Oracle", i18n.TimeDurationType)
+	ConfigTXHandlerSimpleGasOracleAggregation   = ffc("config.transactions.handler.simple.gasOracle.aggregate.function", "Aggregate Gas Oracle: How the gas prices returned by the sources are combined. Each numeric field of an EIP-1559 gas price is combined separately", "'median', 'mean', 'min', 'max' or 'percentile'")
+	ConfigTXHandlerSimpleGasOraclePercentile    = ffc("config.transactions.handler.simple.gasOracle.aggregate.percentile", "Aggregate Gas Oracle: The percentile of the gas prices returned by the sources to use, when the function is 'percentile'", i18n.IntType)
+	ConfigTXHandlerSimpleGasOracleMaxDeviation  = ffc("config.transactions.handler.simple.gasOracle.aggregate.maxDeviation", "Aggregate Gas Oracle: The percentage difference from the median beyond which a gas price is discarded as an outlier, when at least three sources return one. 0 to disable", i18n.IntType)
+	ConfigTXHandlerSimpleGasOracleMinSources    = ffc("config.transactions.handler.simple.gasOracle.aggregate.minSources", "Aggregate Gas Oracle: The number of sources that must return a gas price for the result to be used", i18n.IntType)
+	ConfigTXHandlerSimpleGasOracleConnector     = ffc("config.transactions.handler.simple.gasOracle.aggregate.connector", "Aggregate Gas Oracle: Whether the gas price estimate of the connector is one of the sources", i18n.BooleanType)
+	ConfigTXHandlerSimpleGasOracleSourceMethod  = ffc("config.transactions.handler.simple.gasOracle.aggregate.sources[].method", "The HTTP Method to use when invoking this Gas Oracle REST API", i18n.StringType)
+	ConfigTXHandlerSimpleGasOracleSourceTmpl    = ffc("config.transactions.handler.simple.gasOracle.aggregate.sources[].template", "A go template to execute against the result from this Gas Oracle REST API, to create a JSON block with the gas price", i18n.GoTemplateType)
+	ConfigTXHandlerSimpleGasOracleSourceURL     = ffc("config.transactions.handler.simple.gasOracle.aggregate.sources[].http.url", "The URL of this Gas Oracle REST API", i18n.StringType)
+	ConfigTXHandlerSimpleGasOracleSourceProxy   = ffc("config.transactions.handler.simple.gasOracle.aggregate.sources[].http.proxy.url", "Optional HTTP proxy URL to use for this Gas Oracle REST API", i18n.StringType)
+
+	ConfigTXHandlerEIP1559MaxInflight            = ffc("config.transactions.handler.eip1559.maxInFlight", "The maximum number of transactions to have in-flight with the transaction handler / blockchain transaction pool", i18n.IntType)
+	ConfigTXHandlerEIP1559MaxInflightPerSigner   = ffc("config.transactions.handler.eip1559.maxInFlightPerSigner", "The maximum number of transactions from a single signer to have in-flight. 0 means no limit other than maxInFlight", i18n.IntType)
+	ConfigTXHandlerEIP1559SignerSelection        = ffc("config.transactions.handler.eip1559.signerSelection", "How pending transactions are selected across signers when filling the in-flight set", "'sequence', 'roundRobin' or 'weighted'")
+	ConfigTXHandlerEIP1559DependencyFailure      = ffc("config.transactions.handler.eip1559.dependencyFailure", "What happens to a transaction when a transaction it depends on does not succeed. 'fail' cancels and fails the transaction, and 'hold' suspends it for intervention, with later transactions from the signer waiting behind it - resuming it submits the transaction regardless", "'fail' or 'hold'")
+	ConfigTXHandlerEIP1559Simulate               = ffc("config.transactions.handler.eip1559.simulate", "Simulate each transaction before it is first submitted, and fail it without sending if it would revert - releasing its nonce for the next transaction from the signer where possible. Can be overridden per transaction with the simulate request header", i18n.BooleanType)
+	ConfigTXHandlerEIP1559BatchConcurrency       = ffc("config.transactions.handler.eip1559.batchConcurrency", "The number of requests in a batch submitted to the API that are prepared concurrently", i18n.IntType)
+	ConfigTXHandlerEIP1559PolicyWorkers          = ffc("config.transactions.handler.eip1559.policyWorkers", "The number of workers that execute the policy engine against the in-flight transactions concurrently. Each signer is owned by one worker, so the transactions from a signer are still processed in nonce order", i18n.IntType)
+	ConfigTXHandlerEIP1559FundsCheckInterval     = ffc("config.transactions.handler.eip1559.insufficientFunds.balanceCheckInterval", "How often the balance of a signer is checked, while transactions from that signer are suspended because it could not pay for them. Suspended transactions are resumed in nonce order once the balance covers their estimated cost", i18n.TimeDurationType)
+	ConfigTXHandlerEIP1559FundsSuspendLater      = ffc("config.transactions.handler.eip1559.insufficientFunds.suspendLaterNonces", "When a transaction is suspended because its signer could not pay for it, also suspend the transactions from that signer with later nonces that have not been submitted yet", i18n.BooleanType)
+	ConfigTXHandlerEIP1559PriorityEnabled        = ffc("config.transactions.handler.eip1559.priority.enabled", "Select pending transactions with a higher priority in their request headers ahead of those with a lower priority when filling the in-flight set. Transactions from the same signer are always submitted in nonce order", i18n.BooleanType)
+	ConfigTXHandlerEIP1559PriorityGasPriceTiers  = ffc("config.transactions.handler.eip1559.priority.gasPriceTiers", "Map of minimum transaction priority, to the percentage to increase the gas price by for transactions at or above that priority", i18n.MapStringStringType)
+	ConfigTXHandlerEIP1559SignerWeights          = ffc("config.transactions.handler.eip1559.signerWeights", "Weighted signer selection: the number of transactions to select from each signer address (lower case) in each round. Signers not listed have a weight of 1", i18n.MapStringStringType)
+	ConfigTXHandlerEIP1559Interval               = ffc("config.transactions.handler.eip1559.interval", "Interval at which to invoke the transaction handler loop to evaluate outstanding transactions", i18n.TimeDurationType)
+	ConfigTXHandlerEIP1559FixedGasPrice          = ffc("config.transactions.handler.eip1559.fixedGasPrice", "A fixed gasPrice value/structure to pass to the connector", "Raw JSON")
+	ConfigTXHandlerEIP1559ResubmitInterval       = ffc("config.transactions.handler.eip1559.resubmitInterval", "The time between warning and re-sending a transaction (same nonce) when a blockchain transaction has not been allocated a receipt", i18n.TimeDurationType)
+	ConfigTXHandlerEIP1559StaleBlocks            = ffc("config.transactions.handler.eip1559.staleBlocks", "The number of new blocks without a receipt after which a transaction is treated as stale, in the same way as after the resubmitInterval. Whichever happens first applies. 0 disables", i18n.IntType)
+	ConfigTXHandlerEIP1559RetryInitDelay         = ffc("config.transactions.handler.eip1559.retry.initialDelay", "Initial retry delay for retrieving transactions from the persistence", i18n.TimeDurationType)
+	ConfigTXHandlerEIP1559RetryMaxDelay          = ffc("config.transactions.handler.eip1559.retry.maxDelay", "Maximum delay between retries for retrieving transactions from the persistence", i18n.TimeDurationType)
+	ConfigTXHandlerEIP1559RetryFactor            = ffc("config.transactions.handler.eip1559.retry.factor", "Factor to increase the delay by, between each retry for retrieving transactions from the persistence", i18n.FloatType)
+	ConfigTXHandlerEIP1559SubmitBackoffInitDelay = ffc("config.transactions.handler.eip1559.submitBackoff.initialDelay", "Initial delay before retrying submission of a transaction that the connector rejected as rate_limited or nonce_too_high", i18n.TimeDurationType)
+	ConfigTXHandlerEIP1559SubmitBackoffMaxDelay  = ffc("config.transactions.handler.eip1559.submitBackoff.maxDelay", "Maximum delay between retries of submission of a transaction that the connector rejected as rate_limited or nonce_too_high", i18n.TimeDurationType)
+	ConfigTXHandlerEIP1559SubmitBackoffFactor    = ffc("config.transactions.handler.eip1559.submitBackoff.factor", "Factor to increase the delay by, between each retry of submission of a transaction that the connector rejected as rate_limited or nonce_too_high", i18n.FloatType)
+	ConfigTXHandlerEIP1559ReplacementGasBump     = ffc("config.transactions.handler.eip1559.replacementGasPriceBump", "The percentage to increase the gas price by when the connector rejects a resubmission as replacement_underpriced", i18n.IntType)
+	ConfigTXHandlerEIP1559MaxDroppedResubmits    = ffc("config.transactions.handler.eip1559.maxDroppedResubmits", "The number of times a stale transaction that the blockchain node no longer knows about will be resubmitted, before it is marked as failed. 0 means no limit", i18n.IntType)
+	ConfigTXHandlerEIP1559GasEscalationPercent   = ffc("config.transactions.handler.eip1559.gasEscalation.percentage", "The percentage to increase the gas price by each time a stale transaction is resubmitted. 0 disables gas price escalation", i18n.IntType)
+	ConfigTXHandlerEIP1559GasEscalationMinPct    = ffc("config.transactions.handler.eip1559.gasEscalation.minimumPercentage", "The minimum percentage increase over the last submitted gas price when escalating, so that the blockchain node accepts the resubmission as a replacement", i18n.IntType)
+	ConfigTXHandlerEIP1559GasEscalationMax       = ffc("config.transactions.handler.eip1559.gasEscalation.maxGasPrice", "The maximum gasPrice value/structure that escalation will raise the gas price to. Each numeric field is capped separately", "Raw JSON")
+	ConfigTXHandlerEIP1559GasOracleEnabled       = ffc("config.transactions.handler.eip1559.gasOracle.mode", "The gas oracle mode", "'connector', 'restapi', 'aggregate', 'fixed', or 'disabled'")
+	ConfigTXHandlerEIP1559GasOracleGoTemplate    = ffc("config.transactions.handler.eip1559.gasOracle.template", "REST API Gas Oracle: A go template to execute against the result from the Gas Oracle, to create a JSON block that will be passed as the gas price to the connector", i18n.GoTemplateType)
+	ConfigTXHandlerEIP1559GasOracleURL           = ffc("config.transactions.handler.eip1559.gasOracle.url", "REST API Gas Oracle: The URL of a Gas Oracle REST API to call", i18n.StringType)
+	ConfigTXHandlerEIP1559GasOracleProxyURL      = ffc("config.transactions.handler.eip1559.gasOracle.proxy.url", "Optional HTTP proxy URL to use for the Gas Oracle REST API", i18n.StringType)
+	ConfigTXHandlerEIP1559GasOracleMethod        = ffc("config.transactions.handler.eip1559.gasOracle.method", "The HTTP Method to use when invoking the Gas Oracle REST API", i18n.StringType)
+	ConfigTXHandlerEIP1559GasOracleQueryInterval = ffc("config.transactions.handler.eip1559.gasOracle.queryInterval", "The minimum interval between queries to the Gas Oracle", i18n.TimeDurationType)
+	ConfigTXHandlerEIP1559GasOracleAggregation   = ffc("config.transactions.handler.eip1559.gasOracle.aggregate.function", "Aggregate Gas Oracle: How the gas prices returned by the sources are combined. Each numeric field of an EIP-1559 gas price is combined separately", "'median', 'mean', 'min', 'max' or 'percentile'")
+	ConfigTXHandlerEIP1559GasOraclePercentile    = ffc("config.transactions.handler.eip1559.gasOracle.aggregate.percentile", "Aggregate Gas Oracle: The percentile of the gas prices returned by the sources to use, when the function is 'percentile'", i18n.IntType)
+	ConfigTXHandlerEIP1559GasOracleMaxDeviation  = ffc("config.transactions.handler.eip1559.gasOracle.aggregate.maxDeviation", "Aggregate Gas Oracle: The percentage difference from the median beyond which a gas price is discarded as an outlier, when at least three sources return one. 0 to disable", i18n.IntType)
+	ConfigTXHandlerEIP1559GasOracleMinSources    = ffc("config.transactions.handler.eip1559.gasOracle.aggregate.minSources", "Aggregate Gas Oracle: The number of sources that must return a gas price for the result to be used", i18n.IntType)
+	ConfigTXHandlerEIP1559GasOracleConnector     = ffc("config.transactions.handler.eip1559.gasOracle.aggregate.connector", "Aggregate Gas Oracle: Whether the gas price estimate of the connector is one of the sources", i18n.BooleanType)
+	ConfigTXHandlerEIP1559GasOracleSourceMethod  = ffc("config.transactions.handler.eip1559.gasOracle.aggregate.sources[].method", "The HTTP Method to use when invoking this Gas Oracle REST API", i18n.StringType)
+	ConfigTXHandlerEIP1559GasOracleSourceTmpl    = ffc("config.transactions.handler.eip1559.gasOracle.aggregate.sources[].template", "A go template to execute against the result from this Gas Oracle REST API, to create a JSON block with the gas price", i18n.GoTemplateType)
+	ConfigTXHandlerEIP1559GasOracleSourceURL     = ffc("config.transactions.handler.eip1559.gasOracle.aggregate.sources[].http.url", "The URL of this Gas Oracle REST API", i18n.StringType)
+	ConfigTXHandlerEIP1559GasOracleSourceProxy   = ffc("config.transactions.handler.eip1559.gasOracle.aggregate.sources[].http.proxy.url", "Optional HTTP proxy URL to use for this Gas Oracle REST API", i18n.StringType)
+	ConfigTXHandlerEIP1559BlockSamples           = ffc("config.transactions.handler.eip1559.blockSamples", "The number of recent blocks the base fee and priority fee are sampled from", i18n.IntType)
+	ConfigTXHandlerEIP1559BaseFeePercentile      = ffc("config.transactions.handler.eip1559.baseFee.percentile", "The percentile of the base fees of the sampled blocks to use", i18n.IntType)
+	ConfigTXHandlerEIP1559BaseFeeMaxFeePct       = ffc("config.transactions.handler.eip1559.baseFee.maxFeePercentage", "The percentage of the base fee allowed for in maxFeePerGas, on top of the priority fee, so the transaction remains valid while the base fee rises", i18n.IntType)
+	ConfigTXHandlerEIP1559PriorityFeePercentile  = ffc("config.transactions.handler.eip1559.priorityFee.percentile", "The percentile of the priority fees sampled from the gas price estimate of the connector to use", i18n.IntType)
+	ConfigTXHandlerEIP1559PriorityFeeMinimum     = ffc("config.transactions.handler.eip1559.priorityFee.minimum", "The minimum maxPriorityFeePerGas, which is also used before any priority fees have been sampled", i18n.StringType)
+	ConfigTXHandlerEIP1559PriorityFeeMaximum     = ffc("config.transactions.handler.eip1559.priorityFee.maximum", "The maximum maxPriorityFeePerGas, including when it is escalated", i18n.StringType)
+	ConfigTXHandlerEIP1559PriorityFeeEscalation  = ffc("config.transactions.handler.eip1559.priorityFee.escalationPercentage", "The percentage to increase maxPriorityFeePerGas and maxFeePerGas by each time a stale transaction is resubmitted. 0 disables escalation", i18n.IntType)
+	ConfigTXHandlerEIP1559MaxFeePerGas           = ffc("config.transactions.handler.eip1559.maxFeePerGas", "The maximum maxFeePerGas, including when it is escalated", i18n.StringType)
 
 	ConfigEventStreamsDefaultsBatchSize                 = ffc("config.eventstreams.defaults.batchSize", "Default batch size for newly created event streams", i18n.IntType)
 	ConfigEventStreamsDefaultsBatchTimeout              = ffc("config.eventstreams.defaults.batchTimeout", "Default batch timeout for newly created event streams", i18n.TimeDurationType)
@@ -101,4 +101,71 @@ var (
 	MsgTransactionPersistenceError             = ffe("FF21084", "Failed to persist transaction data", 500)
 	MsgOpNotSupportedWithoutRichQuery          = ffe("FF21085", "Not supported: The connector must be configured with a rich query database to support this operation", 501)
 	MsgTransactionOpInvalid                    = ffe("FF21086", "Transaction operation is missing required fields", 400)
+	MsgSimulatorDownstreamDown                 = ffe("FF21087", "Simulated blockchain node is unavailable")
+	MsgSimulatorInvalidTransactionData         = ffe("FF21088", "Invalid simulated transaction data: %s", http.StatusBadRequest)
+	MsgSimulatorMissingNonce                   = ffe("FF21089", "A nonce must be supplied to submit a transaction", http.StatusBadRequest)
+	MsgSimulatorNonceTooLow                    = ffe("FF21090", "Nonce %d too low for signer '%s' (next nonce %d)")
+	MsgSimulatorKnownTransaction               = ffe("FF21091", "Transaction '%s' is already known")
+	MsgSimulatorInsufficientFunds              = ffe("FF21092", "Insufficient funds for signer '%s' (balance=%s required=%s)")
+	MsgSimulatorNotFound                       = ffe("FF21093", "%s '%s' not found", http.StatusNotFound)
+	MsgSimulatorReverted                       = ffe("FF21094", "Execution reverted: %s")
+	MsgSimulatorInvalidFilter                  = ffe("FF21095", "Invalid listener filter: %s", http.StatusBadRequest)
+	MsgSimulatorInvalidFromBlock               = ffe("FF21096", "Invalid fromBlock '%s'", http.StatusBadRequest)
+	MsgSimulatorInvalidCheckpoint              = ffe("FF21097", "Invalid checkpoint type %T", http.StatusBadRequest)
+	MsgSimulatorReorgTooDeep                   = ffe("FF21098", "Cannot remove %d blocks from a chain with head block %d")
+	MsgRemoteFFCAPIUnknownOperation            = ffe("FF21099", "Unknown FFCAPI operation '%s'", http.StatusNotFound)
+	MsgRemoteFFCAPIInvalidRequest              = ffe("FF21100", "Invalid FFCAPI '%s' request: %s", http.StatusBadRequest)
+	MsgRemoteFFCAPIInvalidCheckpoint           = ffe("FF21101", "Invalid FFCAPI checkpoint: %s", http.StatusBadRequest)
+	MsgRemoteFFCAPIWebSocketConnect            = ffe("FF21102", "Failed to connect WebSocket to connector at '%s'")
+	MsgRemoteFFCAPIWebSocketClosed             = ffe("FF21103", "WebSocket to connector closed before %s '%s' started")
+	MsgMultiplexerNoBackends                   = ffe("FF21104", "At least one backend connector must be supplied to the multiplexer")
+	MsgCircuitBreakerOpen                      = ffe("FF21105", "Connector circuit breaker is open - calls are suspended until the downstream node recovers")
+	MsgRecordingInvalidRecord                  = ffe("FF21106", "Invalid record at line %d of recording: %s")
+	MsgReplayNoRecordedCall                    = ffe("FF21107", "No recorded '%s' call remains to replay")
+	MsgReplayInvalidResponse                   = ffe("FF21108", "Invalid recorded '%s' response at line %d: %s")
+	MsgRecordingUnknownRecordType              = ffe("FF21109", "Unknown record type '%s'")
+	MsgRecordingFileOpenFailed                 = ffe("FF21110", "Failed to open connector recording file '%s'")
+	MsgConformanceEventOutOfOrder              = ffe("FF21111", "Event %s was delivered after event %s on the same listener")
+	MsgConformanceMissingCheckpoint            = ffe("FF21112", "Event %s was delivered without a checkpoint")
+	MsgConformanceCheckpointRegressed          = ffe("FF21113", "Checkpoint %s of event %s is before checkpoint %s of the previous event")
+	MsgConformanceCheckpointLessThanSelf       = ffe("FF21114", "Checkpoint %s is less than itself")
+	MsgConformanceCheckpointRestore            = ffe("FF21115", "Checkpoint %s does not compare equal to itself once restored from JSON as %s")
+	MsgConformanceRemovedUnknownEvent          = ffe("FF21116", "Removed event %s does not match an event that was previously delivered")
+	MsgConformanceHWMRegressed                 = ffe("FF21117", "High water mark checkpoint %s is before checkpoint %s of event %s, which has already been delivered")
+	MsgConformanceUnexpectedReason             = ffe("FF21118", "%s returned reason '%s' when '%s' was expected: %v")
+	MsgConformanceEmptyBlockHash               = ffe("FF21119", "Block hash notification must contain at least one block hash, and no empty block hashes: %v")
+	MsgConformanceEventBeforeResume            = ffe("FF21120", "Event %s with checkpoint %s was delivered before the checkpoint %s the listener resumed from")
+	MsgConformanceMissingHWM                   = ffe("FF21121", "No high water mark checkpoint was returned for listener %s")
+	MsgTransactionLost                         = ffe("FF21122", "Transaction %s is no longer known to the blockchain node, and has already been resubmitted after being dropped %d times")
+	MsgInvalidSignerSelection                  = ffe("FF21123", "Invalid signer selection mode '%s'")
+	MsgInvalidPriorityGasPriceTier             = ffe("FF21124", "Invalid priority gas price tier '%s'='%v'")
+	MsgTransactionExpired                      = ffe("FF21125", "Transaction %s expired at %s before it was mined")
+	MsgInvalidDependencyFailure                = ffe("FF21126", "Invalid dependency failure mode '%s'")
+	MsgDependencyFailed                        = ffe("FF21127", "Transaction %s depends on transaction %s, which did not succeed")
+	MsgDependencyNotFound                      = ffe("FF21128", "Transaction %s depends on unknown transaction '%s'", http.StatusBadRequest)
+	MsgTransactionSimulationReverted           = ffe("FF21129", "Transaction %s was not submitted, as it reverted in simulation: %s")
+	MsgTransactionUnderfunded                  = ffe("FF21130", "Transaction %s suspended until signer %s has the funds to pay for it: %s")
+	MsgSignerUnderfunded                       = ffe("FF21131", "Transaction %s suspended, as earlier transaction %s from signer %s has insufficient funds")
+	MsgInvalidGasOracleAggregation             = ffe("FF21132", "Invalid gas oracle aggregation function '%s'")
+	MsgInvalidGasOraclePercentile              = ffe("FF21133", "Invalid gas oracle percentile %d - must be between 0 and 100")
+	MsgNoGasOracleSources                      = ffe("FF21134", "No gas oracle sources configured for aggregation, and the connector is not included")
+	MsgGasOracleAggregateInsufficient          = ffe("FF21135", "Only %d of the %d gas oracle sources that responded returned a usable gas price, and %d are required")
+	MsgTransactionUpdateEmpty                  = ffe("FF21136", "A transaction update must set the gasPrice, the gas limit, or both", http.StatusBadRequest)
+	MsgTransactionNotPending                   = ffe("FF21137", "Transaction %s cannot be replaced, as it is %s", http.StatusConflict)
+	MsgTransactionAlreadyMined                 = ffe("FF21138", "Transaction %s cannot be replaced, as it has been mined with hash %s", http.StatusConflict)
+	MsgTransactionNonceUsed                    = ffe("FF21139", "Transaction %s cannot be cancelled, as nonce %s has already been used - waiting for the receipt of %s", http.StatusConflict)
+	MsgTransactionCancelled                    = ffe("FF21140", "Transaction %s was cancelled")
+	MsgBatchRequestInvalid                     = ffe("FF21141", "Request %d in the batch is invalid: %s", http.StatusBadRequest)
+	MsgSpendPolicyInvalidLimit                 = ffe("FF21142", "Invalid spend policy limit %s: '%s'")
+	MsgSpendPolicySignerNotAllowed             = ffe("FF21143", "Signer '%s' is not allowed to submit transactions in namespace '%s'", http.StatusForbidden)
+	MsgSpendPolicyDestinationNotAllowed        = ffe("FF21144", "Transactions cannot be sent to '%s', as it is not an allowed destination", http.StatusForbidden)
+	MsgSpendPolicyMaxValue                     = ffe("FF21145", "Value %s exceeds the maximum value of a transaction %s", http.StatusForbidden)
+	MsgSpendPolicyWindowValue                  = ffe("FF21146", "Signer '%s' cannot send a total value of %s within %s, as it exceeds the limit %s", http.StatusForbidden)
+	MsgSpendPolicyWindowGasLimit               = ffe("FF21147", "Signer '%s' cannot use a total gas limit of %s within %s, as it exceeds the limit %s", http.StatusForbidden)
+	MsgEIP1559InvalidPercentile                = ffe("FF21148", "Invalid EIP-1559 %s percentile %d - must be between 0 and 100")
+	MsgEIP1559InvalidFee                       = ffe("FF21149", "Invalid EIP-1559 fee %s: '%s'")
+	MsgEIP1559NoBaseFee                        = ffe("FF21150", "No base fee is available for EIP-1559 transactions, from recent blocks or the gas price estimate of the connector")
+	MsgConnectorNotSupported                   = ffe("FF21151", "The connector does not support %s", http.StatusNotImplemented)
+	MsgBatchDependencyFailed                   = ffe("FF21152", "Transaction %s depends on transaction %s in the same batch, which failed", http.StatusBadRequest)
+	MsgTransactionHandlerNotSupported          = ffe("FF21153", "The transaction handler does not support %s", http.StatusNotImplemented)
 )
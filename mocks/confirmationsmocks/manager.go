@@ -60,6 +60,11 @@ func (_m *Manager) Notify(n *confirmations.Notification) error {
 	return r0
 }
 
+// SetNewBlockCallback provides a mock function with given fields: cb
+func (_m *Manager) SetNewBlockCallback(cb confirmations.NewBlockCallback) {
+	_m.Called(cb)
+}
+
 // Start provides a mock function with given fields:
 func (_m *Manager) Start() {
 	_m.Called()
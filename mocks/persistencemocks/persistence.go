@@ -353,6 +353,22 @@ func (_m *Persistence) InsertTransactionWithNextNonce(ctx context.Context, tx *a
 	return r0
 }
 
+// InsertTransactionsWithNextNonce provides a mock function with given fields: ctx, txs, lookupNextNonce
+func (_m *Persistence) InsertTransactionsWithNextNonce(ctx context.Context, txs []*apitypes.ManagedTX, lookupNextNonce persistence.NextNonceCallback) []error {
+	ret := _m.Called(ctx, txs, lookupNextNonce)
+
+	var r0 []error
+	if rf, ok := ret.Get(0).(func(context.Context, []*apitypes.ManagedTX, persistence.NextNonceCallback) []error); ok {
+		r0 = rf(ctx, txs, lookupNextNonce)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]error)
+		}
+	}
+
+	return r0
+}
+
 // ListListenersByCreateTime provides a mock function with given fields: ctx, after, limit, dir
 func (_m *Persistence) ListListenersByCreateTime(ctx context.Context, after *fftypes.UUID, limit int, dir persistence.SortDirection) ([]*apitypes.Listener, error) {
 	ret := _m.Called(ctx, after, limit, dir)
@@ -509,6 +525,30 @@ func (_m *Persistence) ListTransactionsPending(ctx context.Context, afterSequenc
 	return r0, r1
 }
 
+// ReleaseTransactionNonce provides a mock function with given fields: ctx, signer, txID
+func (_m *Persistence) ReleaseTransactionNonce(ctx context.Context, signer string, txID string) (bool, error) {
+	ret := _m.Called(ctx, signer, txID)
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (bool, error)); ok {
+		return rf(ctx, signer, txID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) bool); ok {
+		r0 = rf(ctx, signer, txID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, signer, txID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // RichQuery provides a mock function with given fields:
 func (_m *Persistence) RichQuery() persistence.RichQuery {
 	ret := _m.Called()
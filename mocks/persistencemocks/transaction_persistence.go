@@ -214,6 +214,22 @@ func (_m *TransactionPersistence) InsertTransactionWithNextNonce(ctx context.Con
 	return r0
 }
 
+// InsertTransactionsWithNextNonce provides a mock function with given fields: ctx, txs, lookupNextNonce
+func (_m *TransactionPersistence) InsertTransactionsWithNextNonce(ctx context.Context, txs []*apitypes.ManagedTX, lookupNextNonce persistence.NextNonceCallback) []error {
+	ret := _m.Called(ctx, txs, lookupNextNonce)
+
+	var r0 []error
+	if rf, ok := ret.Get(0).(func(context.Context, []*apitypes.ManagedTX, persistence.NextNonceCallback) []error); ok {
+		r0 = rf(ctx, txs, lookupNextNonce)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]error)
+		}
+	}
+
+	return r0
+}
+
 // ListTransactionsByCreateTime provides a mock function with given fields: ctx, after, limit, dir
 func (_m *TransactionPersistence) ListTransactionsByCreateTime(ctx context.Context, after *apitypes.ManagedTX, limit int, dir persistence.SortDirection) ([]*apitypes.ManagedTX, error) {
 	ret := _m.Called(ctx, after, limit, dir)
@@ -292,6 +308,30 @@ func (_m *TransactionPersistence) ListTransactionsPending(ctx context.Context, a
 	return r0, r1
 }
 
+// ReleaseTransactionNonce provides a mock function with given fields: ctx, signer, txID
+func (_m *TransactionPersistence) ReleaseTransactionNonce(ctx context.Context, signer string, txID string) (bool, error) {
+	ret := _m.Called(ctx, signer, txID)
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (bool, error)); ok {
+		return rf(ctx, signer, txID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) bool); ok {
+		r0 = rf(ctx, signer, txID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, signer, txID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // SetTransactionReceipt provides a mock function with given fields: ctx, txID, receipt
 func (_m *TransactionPersistence) SetTransactionReceipt(ctx context.Context, txID string, receipt *ffcapi.TransactionReceiptResponse) error {
 	ret := _m.Called(ctx, txID, receipt)
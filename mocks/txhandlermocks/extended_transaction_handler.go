@@ -0,0 +1,297 @@
+// Code generated by mockery v2.26.1. DO NOT EDIT.
+
+package txhandlermocks
+
+import (
+	context "context"
+
+	apitypes "github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+
+	ffcapi "github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+
+	mock "github.com/stretchr/testify/mock"
+
+	txhandler "github.com/hyperledger/firefly-transaction-manager/pkg/txhandler"
+)
+
+// ExtendedTransactionHandler is an autogenerated mock type for the ExtendedTransactionHandler type
+type ExtendedTransactionHandler struct {
+	mock.Mock
+}
+
+// HandleCancelByReplacement provides a mock function with given fields: ctx, txID
+func (_m *ExtendedTransactionHandler) HandleCancelByReplacement(ctx context.Context, txID string) (*apitypes.ManagedTX, error) {
+	ret := _m.Called(ctx, txID)
+
+	var r0 *apitypes.ManagedTX
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*apitypes.ManagedTX, error)); ok {
+		return rf(ctx, txID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *apitypes.ManagedTX); ok {
+		r0 = rf(ctx, txID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*apitypes.ManagedTX)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, txID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// HandleCancelTransaction provides a mock function with given fields: ctx, txID
+func (_m *ExtendedTransactionHandler) HandleCancelTransaction(ctx context.Context, txID string) (*apitypes.ManagedTX, error) {
+	ret := _m.Called(ctx, txID)
+
+	var r0 *apitypes.ManagedTX
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*apitypes.ManagedTX, error)); ok {
+		return rf(ctx, txID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *apitypes.ManagedTX); ok {
+		r0 = rf(ctx, txID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*apitypes.ManagedTX)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, txID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// HandleNewBlock provides a mock function with given fields: ctx, blockNumber
+func (_m *ExtendedTransactionHandler) HandleNewBlock(ctx context.Context, blockNumber uint64) {
+	_m.Called(ctx, blockNumber)
+}
+
+// HandleNewContractDeployment provides a mock function with given fields: ctx, txReq
+func (_m *ExtendedTransactionHandler) HandleNewContractDeployment(ctx context.Context, txReq *apitypes.ContractDeployRequest) (*apitypes.ManagedTX, error) {
+	ret := _m.Called(ctx, txReq)
+
+	var r0 *apitypes.ManagedTX
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *apitypes.ContractDeployRequest) (*apitypes.ManagedTX, error)); ok {
+		return rf(ctx, txReq)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *apitypes.ContractDeployRequest) *apitypes.ManagedTX); ok {
+		r0 = rf(ctx, txReq)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*apitypes.ManagedTX)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *apitypes.ContractDeployRequest) error); ok {
+		r1 = rf(ctx, txReq)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// HandleNewTransaction provides a mock function with given fields: ctx, txReq
+func (_m *ExtendedTransactionHandler) HandleNewTransaction(ctx context.Context, txReq *apitypes.TransactionRequest) (*apitypes.ManagedTX, error) {
+	ret := _m.Called(ctx, txReq)
+
+	var r0 *apitypes.ManagedTX
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *apitypes.TransactionRequest) (*apitypes.ManagedTX, error)); ok {
+		return rf(ctx, txReq)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *apitypes.TransactionRequest) *apitypes.ManagedTX); ok {
+		r0 = rf(ctx, txReq)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*apitypes.ManagedTX)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *apitypes.TransactionRequest) error); ok {
+		r1 = rf(ctx, txReq)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// HandleNewTransactionBatch provides a mock function with given fields: ctx, txReqs
+func (_m *ExtendedTransactionHandler) HandleNewTransactionBatch(ctx context.Context, txReqs []*apitypes.TransactionBatchItem) []*apitypes.TransactionBatchResult {
+	ret := _m.Called(ctx, txReqs)
+
+	var r0 []*apitypes.TransactionBatchResult
+	if rf, ok := ret.Get(0).(func(context.Context, []*apitypes.TransactionBatchItem) []*apitypes.TransactionBatchResult); ok {
+		r0 = rf(ctx, txReqs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*apitypes.TransactionBatchResult)
+		}
+	}
+
+	return r0
+}
+
+// HandleResumeTransaction provides a mock function with given fields: ctx, txID
+func (_m *ExtendedTransactionHandler) HandleResumeTransaction(ctx context.Context, txID string) (*apitypes.ManagedTX, error) {
+	ret := _m.Called(ctx, txID)
+
+	var r0 *apitypes.ManagedTX
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*apitypes.ManagedTX, error)); ok {
+		return rf(ctx, txID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *apitypes.ManagedTX); ok {
+		r0 = rf(ctx, txID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*apitypes.ManagedTX)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, txID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// HandleSuspendTransaction provides a mock function with given fields: ctx, txID
+func (_m *ExtendedTransactionHandler) HandleSuspendTransaction(ctx context.Context, txID string) (*apitypes.ManagedTX, error) {
+	ret := _m.Called(ctx, txID)
+
+	var r0 *apitypes.ManagedTX
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*apitypes.ManagedTX, error)); ok {
+		return rf(ctx, txID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *apitypes.ManagedTX); ok {
+		r0 = rf(ctx, txID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*apitypes.ManagedTX)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, txID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// HandleTransactionConfirmations provides a mock function with given fields: ctx, txID, notification
+func (_m *ExtendedTransactionHandler) HandleTransactionConfirmations(ctx context.Context, txID string, notification *apitypes.ConfirmationsNotification) error {
+	ret := _m.Called(ctx, txID, notification)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *apitypes.ConfirmationsNotification) error); ok {
+		r0 = rf(ctx, txID, notification)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// HandleTransactionReceiptReceived provides a mock function with given fields: ctx, txID, receipt
+func (_m *ExtendedTransactionHandler) HandleTransactionReceiptReceived(ctx context.Context, txID string, receipt *ffcapi.TransactionReceiptResponse) error {
+	ret := _m.Called(ctx, txID, receipt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *ffcapi.TransactionReceiptResponse) error); ok {
+		r0 = rf(ctx, txID, receipt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// HandleUpdateTransaction provides a mock function with given fields: ctx, txID, update
+func (_m *ExtendedTransactionHandler) HandleUpdateTransaction(ctx context.Context, txID string, update *apitypes.TransactionUpdateRequest) (*apitypes.ManagedTX, error) {
+	ret := _m.Called(ctx, txID, update)
+
+	var r0 *apitypes.ManagedTX
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *apitypes.TransactionUpdateRequest) (*apitypes.ManagedTX, error)); ok {
+		return rf(ctx, txID, update)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, *apitypes.TransactionUpdateRequest) *apitypes.ManagedTX); ok {
+		r0 = rf(ctx, txID, update)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*apitypes.ManagedTX)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, *apitypes.TransactionUpdateRequest) error); ok {
+		r1 = rf(ctx, txID, update)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Init provides a mock function with given fields: ctx, toolkit
+func (_m *ExtendedTransactionHandler) Init(ctx context.Context, toolkit *txhandler.Toolkit) {
+	_m.Called(ctx, toolkit)
+}
+
+// Start provides a mock function with given fields: ctx
+func (_m *ExtendedTransactionHandler) Start(ctx context.Context) (<-chan struct{}, error) {
+	ret := _m.Called(ctx)
+
+	var r0 <-chan struct{}
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (<-chan struct{}, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) <-chan struct{}); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan struct{})
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewExtendedTransactionHandler interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewExtendedTransactionHandler creates a new instance of ExtendedTransactionHandler. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewExtendedTransactionHandler(t mockConstructorTestingTNewExtendedTransactionHandler) *ExtendedTransactionHandler {
+	mock := &ExtendedTransactionHandler{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -244,6 +244,15 @@ type LiveStatus struct {
 
 type ReadyStatus struct {
 	ffcapi.ReadyResponse
+	CircuitBreaker *CircuitBreakerStatus `json:"circuitBreaker,omitempty"`
+}
+
+// CircuitBreakerStatus is the state of the circuit breaker in front of the connector, when enabled
+type CircuitBreakerStatus struct {
+	State               string          `json:"state"`
+	ConsecutiveFailures int             `json:"consecutiveFailures"`
+	LastOpened          *fftypes.FFTime `json:"lastOpened,omitempty"`
+	RejectedCalls       int64           `json:"rejectedCalls"`
 }
 
 type LiveAddressBalance struct {
@@ -369,7 +378,18 @@ type EventWithContext struct {
 
 func (e *EventWithContext) MarshalJSON() ([]byte, error) {
 	m := make(map[string]interface{})
-	if e.Info != nil {
+	switch info := e.Info.(type) {
+	case nil:
+	case map[string]interface{}:
+		// Info that has been through JSON already (such as from a remote connector) is a generic map
+		for k, v := range info {
+			m[k] = v
+		}
+	case fftypes.JSONObject:
+		for k, v := range info {
+			m[k] = v
+		}
+	default:
 		jsonmap.AddJSONFieldsToMap(reflect.ValueOf(e.Info), m)
 	}
 	jsonmap.AddJSONFieldsToMap(reflect.ValueOf(&e.ID), m)
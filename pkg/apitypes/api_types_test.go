@@ -244,6 +244,24 @@ func TestMarshalUnmarshalEmptyInfoOk(t *testing.T) {
 
 }
 
+func TestMarshalMapInfoOk(t *testing.T) {
+
+	e := &EventWithContext{
+		Event: ffcapi.Event{
+			Info: map[string]interface{}{"key1": "val1"},
+		},
+	}
+	b, err := json.Marshal(&e)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"key1":"val1"`)
+
+	e.Info = fftypes.JSONObject{"key2": "val2"}
+	b, err = json.Marshal(&e)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"key2":"val2"`)
+
+}
+
 func TestUnmarshalFail(t *testing.T) {
 
 	e := &EventWithContext{}
@@ -16,7 +16,11 @@
 
 package apitypes
 
-import "encoding/json"
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+)
 
 // BaseRequest is the common headers to all requests, and captures the full input payload for later decoding to a specific type
 type BaseRequest struct {
@@ -38,8 +42,13 @@ func (br *BaseRequest) UnmarshalTo(o interface{}) error {
 }
 
 type RequestHeaders struct {
-	ID   string      `ffstruct:"fftmrequest" json:"id"`
-	Type RequestType `json:"type"`
+	ID        string          `ffstruct:"fftmrequest" json:"id"`
+	Type      RequestType     `json:"type"`
+	Priority  int             `json:"priority,omitempty"`  // higher values are preferred when selecting transactions to submit - only applies to SendTransaction and DeployContract
+	NotBefore *fftypes.FFTime `json:"notBefore,omitempty"` // the transaction is assigned a nonce immediately, but not submitted before this time - only applies to SendTransaction and DeployContract
+	Expiry    *fftypes.FFTime `json:"expiry,omitempty"`    // the transaction is no longer submitted after this time, and fails if it has not been mined - only applies to SendTransaction and DeployContract
+	DependsOn []string        `json:"dependsOn,omitempty"` // IDs of existing transactions that must succeed before this transaction is submitted - only applies to SendTransaction and DeployContract
+	Simulate  *bool           `json:"simulate,omitempty"`  // overrides whether the transaction is simulated before it is first submitted, to fail it without sending if it would revert - only applies to SendTransaction and DeployContract
 }
 
 type RequestType string
@@ -44,12 +44,20 @@ type TxSubStatus string
 const (
 	// TxSubStatusReceived indicates the transaction has been received by the connector
 	TxSubStatusReceived TxSubStatus = "Received"
+	// TxSubStatusScheduled indicates the transaction is being held until its notBefore time, or that of an earlier transaction from the same signer
+	TxSubStatusScheduled TxSubStatus = "Scheduled"
 	// TxSubStatusStale indicates the transaction is now in stale
 	TxSubStatusStale TxSubStatus = "Stale"
 	// TxSubStatusTracking indicates we are tracking progress of the transaction
 	TxSubStatusTracking TxSubStatus = "Tracking"
 	// TxSubStatusConfirmed indicates we have confirmed that the transaction has been fully processed
 	TxSubStatusConfirmed TxSubStatus = "Confirmed"
+	// TxSubStatusDropped indicates the transaction was submitted, but is no longer known to the blockchain node
+	TxSubStatusDropped TxSubStatus = "Dropped"
+	// TxSubStatusWaiting indicates the transaction is waiting for the transactions it depends on to succeed
+	TxSubStatusWaiting TxSubStatus = "Waiting"
+	// TxSubStatusUnderfunded indicates the transaction is suspended until its signer has the funds to pay for it
+	TxSubStatusUnderfunded TxSubStatus = "Underfunded"
 	// TxSubStatusFailed indicates we have failed to process the transaction and it will no longer be tracked
 	TxSubStatusFailed TxSubStatus = "Failed"
 )
@@ -81,12 +89,38 @@ const (
 	TxActionStateTransition TxAction = "StateTransition"
 	// TxActionAssignNonce indicates that a nonce has been assigned to the transaction
 	TxActionAssignNonce TxAction = "AssignNonce"
+	// TxActionSchedule indicates that the transaction will not be submitted before its notBefore time
+	TxActionSchedule TxAction = "Schedule"
 	// TxActionRetrieveGasPrice indicates the operation is getting a gas price
 	TxActionRetrieveGasPrice TxAction = "RetrieveGasPrice"
 	// TxActionTimeout indicates that the transaction has timed out may need intervention to progress it
 	TxActionTimeout TxAction = "Timeout"
+	// TxActionLookupTransaction indicates the blockchain node has been asked whether it still knows about a submitted transaction
+	TxActionLookupTransaction TxAction = "LookupTransaction"
+	// TxActionSignTransaction indicates the connector has been asked for the hash the transaction will have once submitted
+	TxActionSignTransaction TxAction = "SignTransaction"
 	// TxActionSubmitTransaction indicates that the transaction has been submitted
 	TxActionSubmitTransaction TxAction = "SubmitTransaction"
+	// TxActionSubmitBackoff indicates that the connector asked for submission to be retried later, so the transaction handler is backing off
+	TxActionSubmitBackoff TxAction = "SubmitBackoff"
+	// TxActionBumpGasPrice indicates the gas price has been increased, so that a resubmission can replace the transaction already in the transaction pool
+	TxActionBumpGasPrice TxAction = "BumpGasPrice"
+	// TxActionFailTransaction indicates the connector rejected the transaction in a way that cannot be resolved by resubmitting it, so it has been marked failed
+	TxActionFailTransaction TxAction = "FailTransaction"
+	// TxActionCheckDependencies indicates a transaction this transaction depends on did not succeed
+	TxActionCheckDependencies TxAction = "CheckDependencies"
+	// TxActionSimulateTransaction indicates the transaction has been simulated before it was first submitted
+	TxActionSimulateTransaction TxAction = "SimulateTransaction"
+	// TxActionReleaseNonce indicates the nonce of a transaction that was never submitted has been released, to be allocated to the next transaction from the signer
+	TxActionReleaseNonce TxAction = "ReleaseNonce"
+	// TxActionInsufficientFunds indicates the transaction has been suspended, as its signer does not have the funds to pay for it
+	TxActionInsufficientFunds TxAction = "InsufficientFunds"
+	// TxActionFundsAvailable indicates a transaction suspended for insufficient funds has been resumed
+	TxActionFundsAvailable TxAction = "FundsAvailable"
+	// TxActionUpdateTransaction indicates the gas price or gas limit of the transaction has been changed through the API
+	TxActionUpdateTransaction TxAction = "UpdateTransaction"
+	// TxActionCancelTransaction indicates the connector has been asked to fill the nonce of a transaction that is no longer wanted
+	TxActionCancelTransaction TxAction = "CancelTransaction"
 	// TxActionReceiveReceipt indicates that we have received a receipt for the transaction
 	TxActionReceiveReceipt TxAction = "ReceiveReceipt"
 	// TxActionConfirmTransaction indicates that the transaction has been confirmed
@@ -145,12 +179,17 @@ func (r *TXHistoryRecord) SetUpdated(_ *fftypes.FFTime) {}
 //   - When listing back entries, the persistence layer will automatically clean up indexes if the underlying
 //     TX they refer to is not available. For this reason the index records are written first.
 type ManagedTX struct {
-	ID              string          `json:"id"`
-	Created         *fftypes.FFTime `json:"created"`
-	Updated         *fftypes.FFTime `json:"updated"`
-	Status          TxStatus        `json:"status"`
-	DeleteRequested *fftypes.FFTime `json:"deleteRequested,omitempty"`
-	SequenceID      string          `json:"sequenceId,omitempty"`
+	ID              string                `json:"id"`
+	Created         *fftypes.FFTime       `json:"created"`
+	Updated         *fftypes.FFTime       `json:"updated"`
+	Status          TxStatus              `json:"status"`
+	DeleteRequested *fftypes.FFTime       `json:"deleteRequested,omitempty"`
+	SequenceID      string                `json:"sequenceId,omitempty"`
+	Priority        int                   `json:"priority,omitempty"`
+	NotBefore       *fftypes.FFTime       `json:"notBefore,omitempty"`
+	Expiry          *fftypes.FFTime       `json:"expiry,omitempty"`
+	DependsOn       fftypes.FFStringArray `json:"dependsOn,omitempty"`
+	Simulate        *bool                 `json:"simulate,omitempty"`
 	ffcapi.TransactionHeaders
 	GasPrice                     *fftypes.JSONAny           `json:"gasPrice"`
 	TransactionData              string                     `json:"transactionData"`
@@ -255,6 +294,7 @@ type TransactionUpdateReply struct {
 	ProtocolID       string           `json:"protocolId"`
 	TransactionHash  string           `json:"transactionHash,omitempty"`
 	ContractLocation *fftypes.JSONAny `json:"contractLocation,omitempty"`
+	ErrorMessage     string           `json:"errorMessage,omitempty"`
 }
 
 // ManagedTransactionEventType is a enum type that contains all types of transaction process events
@@ -17,6 +17,7 @@
 package apitypes
 
 import (
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 )
 
@@ -31,3 +32,25 @@ type ContractDeployRequest struct {
 	Headers RequestHeaders `json:"headers"`
 	ffcapi.ContractDeployPrepareRequest
 }
+
+// TransactionUpdateRequest is the payload sent to change the gas price and/or gas limit of a pending transaction.
+// If the transaction has already been submitted, it is replaced with a new submission at the same nonce.
+type TransactionUpdateRequest struct {
+	GasPrice *fftypes.JSONAny  `json:"gasPrice,omitempty"`
+	Gas      *fftypes.FFBigInt `json:"gas,omitempty"`
+}
+
+// TransactionBatchItem is a single request within a batch - exactly one of the SendTransaction
+// or DeployContract requests is set
+type TransactionBatchItem struct {
+	Transaction *TransactionRequest
+	Deploy      *ContractDeployRequest
+}
+
+// TransactionBatchResult is the outcome of a single request within a batch, returned in the same order as the requests.
+// Either the transaction was accepted and is now being managed, or it failed with an error and no state was written for it.
+type TransactionBatchResult struct {
+	ID          string     `json:"id,omitempty"`
+	Transaction *ManagedTX `json:"transaction,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
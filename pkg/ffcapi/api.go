@@ -92,6 +92,47 @@ type API interface {
 	IsReady(ctx context.Context) (*ReadyResponse, ErrorReason, error)
 }
 
+// TransactionSigner is an optional interface for connectors that can calculate the hash of a transaction without submitting it
+type TransactionSigner interface {
+	// TransactionSign calculates the hash a prepared transaction will have once submitted with the supplied gas price and nonce, without submitting it. Typically this involves signing the transaction.
+	TransactionSign(ctx context.Context, req *TransactionSignRequest) (*TransactionSignResponse, ErrorReason, error)
+}
+
+// ReceiptBatcher is an optional interface for connectors that can query the receipts for multiple transactions in one call
+type ReceiptBatcher interface {
+	// TransactionReceipts queries receipts for a batch of transaction hashes in one call. Hashes without a receipt are omitted from the response
+	TransactionReceipts(ctx context.Context, req *TransactionReceiptsRequest) (*TransactionReceiptsResponse, ErrorReason, error)
+}
+
+// TransactionFinder is an optional interface for connectors that can look up a transaction by its hash, whether it is pending in the transaction pool or mined
+type TransactionFinder interface {
+	// TransactionByHash looks up whether a transaction is pending in the transaction pool, mined, or unknown to the node (ErrorReasonNotFound)
+	TransactionByHash(ctx context.Context, req *TransactionByHashRequest) (*TransactionByHashResponse, ErrorReason, error)
+}
+
+// TransactionCanceller is an optional interface for connectors that can fill the nonce of a transaction that is no longer wanted
+type TransactionCanceller interface {
+	// TransactionCancel submits a transaction that does nothing at the nonce of a transaction that is no longer wanted, to fill the nonce gap
+	TransactionCancel(ctx context.Context, req *TransactionCancelRequest) (*TransactionCancelResponse, ErrorReason, error)
+}
+
+// TransactionSimulator is an optional interface for connectors that can execute a prepared transaction without submitting it
+type TransactionSimulator interface {
+	// TransactionSimulate executes the pre-encoded data of a prepared transaction against the current state of the chain, returning ErrorReasonTransactionReverted if it would revert
+	TransactionSimulate(ctx context.Context, req *TransactionSimulateRequest) (*TransactionSimulateResponse, ErrorReason, error)
+}
+
+// ExtendedAPI is implemented by connectors that implement all of the optional interfaces. The wrappers around a connector
+// in this module implement it, and return ErrorReasonNotSupported when the connector they wrap does not implement a method.
+type ExtendedAPI interface {
+	API
+	TransactionSigner
+	ReceiptBatcher
+	TransactionFinder
+	TransactionCanceller
+	TransactionSimulator
+}
+
 type BlockHashEvent struct {
 	BlockHashes  []string `json:"blockHash"`              // zero or more hashes (can be nil)
 	GapPotential bool     `json:"gapPotential,omitempty"` // when true, the caller cannot be sure if blocks have been missed (use on reconnect of a websocket for example)
@@ -188,6 +229,18 @@ const (
 	ErrorReasonNotFound ErrorReason = "not_found"
 	// ErrorKnownTransaction if the exact transaction is already known
 	ErrorKnownTransaction ErrorReason = "known_transaction"
+	// ErrorReasonReplacementUnderpriced if there is already a transaction in the transaction pool with the same nonce, and the gas price is not high enough above it to replace it
+	ErrorReasonReplacementUnderpriced ErrorReason = "replacement_underpriced"
+	// ErrorReasonGasLimitExceeded if the gas supplied for the transaction exceeds the maximum allowed in a block
+	ErrorReasonGasLimitExceeded ErrorReason = "gas_limit_exceeded"
+	// ErrorReasonChainIDMismatch if the transaction was signed for a different chain to the one the node is connected to
+	ErrorReasonChainIDMismatch ErrorReason = "chain_id_mismatch"
+	// ErrorReasonRateLimited if the node rejected the request because too many requests have been made, and the request should be retried later
+	ErrorReasonRateLimited ErrorReason = "rate_limited"
+	// ErrorReasonNonceTooHigh on transaction submission, if the nonce is too far ahead of the next nonce the node expects for the signer for it to be accepted into the transaction pool
+	ErrorReasonNonceTooHigh ErrorReason = "nonce_too_high"
+	// ErrorReasonNotSupported if the connector does not implement an optional method
+	ErrorReasonNotSupported ErrorReason = "not_supported"
 	// ErrorReasonDownstreamDown if the downstream JSONRPC endpoint is down
 	ErrorReasonDownstreamDown = "downstream_down"
 )
@@ -218,4 +271,5 @@ type BlockInfo struct {
 	BlockHash         string            `json:"blockHash"`
 	ParentHash        string            `json:"parentHash"`
 	TransactionHashes []string          `json:"transactionHashes"`
+	BaseFeePerGas     *fftypes.FFBigInt `json:"baseFeePerGas,omitempty"` // set by connectors to chains with an EIP-1559 base fee
 }
@@ -0,0 +1,250 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package circuitbreaker
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+func (cb *circuitBreaker) AddressBalance(ctx context.Context, req *ffcapi.AddressBalanceRequest) (res *ffcapi.AddressBalanceResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = cb.connector.AddressBalance(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) BlockInfoByHash(ctx context.Context, req *ffcapi.BlockInfoByHashRequest) (res *ffcapi.BlockInfoByHashResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = cb.connector.BlockInfoByHash(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) BlockInfoByNumber(ctx context.Context, req *ffcapi.BlockInfoByNumberRequest) (res *ffcapi.BlockInfoByNumberResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = cb.connector.BlockInfoByNumber(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) NextNonceForSigner(ctx context.Context, req *ffcapi.NextNonceForSignerRequest) (res *ffcapi.NextNonceForSignerResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = cb.connector.NextNonceForSigner(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) GasEstimate(ctx context.Context, req *ffcapi.TransactionInput) (res *ffcapi.GasEstimateResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = cb.connector.GasEstimate(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) GasPriceEstimate(ctx context.Context, req *ffcapi.GasPriceEstimateRequest) (res *ffcapi.GasPriceEstimateResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = cb.connector.GasPriceEstimate(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) QueryInvoke(ctx context.Context, req *ffcapi.QueryInvokeRequest) (res *ffcapi.QueryInvokeResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = cb.connector.QueryInvoke(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) TransactionReceipt(ctx context.Context, req *ffcapi.TransactionReceiptRequest) (res *ffcapi.TransactionReceiptResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = cb.connector.TransactionReceipt(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) TransactionReceipts(ctx context.Context, req *ffcapi.TransactionReceiptsRequest) (res *ffcapi.TransactionReceiptsResponse, reason ffcapi.ErrorReason, err error) {
+	batcher, ok := cb.connector.(ffcapi.ReceiptBatcher)
+	if !ok {
+		return nil, ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionReceipts")
+	}
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = batcher.TransactionReceipts(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) TransactionByHash(ctx context.Context, req *ffcapi.TransactionByHashRequest) (res *ffcapi.TransactionByHashResponse, reason ffcapi.ErrorReason, err error) {
+	finder, ok := cb.connector.(ffcapi.TransactionFinder)
+	if !ok {
+		return nil, ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionByHash")
+	}
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = finder.TransactionByHash(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) TransactionCancel(ctx context.Context, req *ffcapi.TransactionCancelRequest) (res *ffcapi.TransactionCancelResponse, reason ffcapi.ErrorReason, err error) {
+	canceller, ok := cb.connector.(ffcapi.TransactionCanceller)
+	if !ok {
+		return nil, ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionCancel")
+	}
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = canceller.TransactionCancel(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) TransactionSimulate(ctx context.Context, req *ffcapi.TransactionSimulateRequest) (res *ffcapi.TransactionSimulateResponse, reason ffcapi.ErrorReason, err error) {
+	simulator, ok := cb.connector.(ffcapi.TransactionSimulator)
+	if !ok {
+		return nil, ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionSimulate")
+	}
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = simulator.TransactionSimulate(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) TransactionPrepare(ctx context.Context, req *ffcapi.TransactionPrepareRequest) (res *ffcapi.TransactionPrepareResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = cb.connector.TransactionPrepare(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) TransactionSend(ctx context.Context, req *ffcapi.TransactionSendRequest) (res *ffcapi.TransactionSendResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = cb.connector.TransactionSend(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) TransactionSign(ctx context.Context, req *ffcapi.TransactionSignRequest) (res *ffcapi.TransactionSignResponse, reason ffcapi.ErrorReason, err error) {
+	signer, ok := cb.connector.(ffcapi.TransactionSigner)
+	if !ok {
+		return nil, ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionSign")
+	}
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = signer.TransactionSign(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) DeployContractPrepare(ctx context.Context, req *ffcapi.ContractDeployPrepareRequest) (res *ffcapi.TransactionPrepareResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = cb.connector.DeployContractPrepare(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) EventStreamStart(ctx context.Context, req *ffcapi.EventStreamStartRequest) (res *ffcapi.EventStreamStartResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = cb.connector.EventStreamStart(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) EventListenerVerifyOptions(ctx context.Context, req *ffcapi.EventListenerVerifyOptionsRequest) (res *ffcapi.EventListenerVerifyOptionsResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = cb.connector.EventListenerVerifyOptions(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) EventListenerAdd(ctx context.Context, req *ffcapi.EventListenerAddRequest) (res *ffcapi.EventListenerAddResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = cb.connector.EventListenerAdd(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) EventListenerRemove(ctx context.Context, req *ffcapi.EventListenerRemoveRequest) (res *ffcapi.EventListenerRemoveResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = cb.connector.EventListenerRemove(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) EventListenerHWM(ctx context.Context, req *ffcapi.EventListenerHWMRequest) (res *ffcapi.EventListenerHWMResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = cb.connector.EventListenerHWM(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cb *circuitBreaker) NewBlockListener(ctx context.Context, req *ffcapi.NewBlockListenerRequest) (res *ffcapi.NewBlockListenerResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cb.invoke(ctx, func() (ffcapi.ErrorReason, error) {
+		res, reason, err = cb.connector.NewBlockListener(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+// EventStreamStopped is never rejected, as it allows the connector to clean up its state
+func (cb *circuitBreaker) EventStreamStopped(ctx context.Context, req *ffcapi.EventStreamStoppedRequest) (*ffcapi.EventStreamStoppedResponse, ffcapi.ErrorReason, error) {
+	return cb.connector.EventStreamStopped(ctx, req)
+}
+
+func (cb *circuitBreaker) EventStreamNewCheckpointStruct() ffcapi.EventListenerCheckpoint {
+	return cb.connector.EventStreamNewCheckpointStruct()
+}
+
+func (cb *circuitBreaker) IsLive(ctx context.Context) (*ffcapi.LiveResponse, ffcapi.ErrorReason, error) {
+	return cb.connector.IsLive(ctx)
+}
+
+// IsReady is never rejected. The result counts as a failure if the connector is not ready, and closes
+// the breaker if the connector is ready.
+func (cb *circuitBreaker) IsReady(ctx context.Context) (*ffcapi.ReadyResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := cb.connector.IsReady(ctx)
+	if err == nil && res != nil && res.Ready {
+		cb.mux.Lock()
+		cb.failures = 0
+		notify := cb.setState(StateClosed)
+		cb.mux.Unlock()
+		notify()
+	} else {
+		cb.record(ffcapi.ErrorReasonDownstreamDown)
+	}
+	return res, reason, err
+}
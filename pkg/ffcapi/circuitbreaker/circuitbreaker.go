@@ -0,0 +1,213 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package circuitbreaker provides an ffcapi.API wrapper that stops calls reaching the connector
+// while the downstream blockchain node is unavailable, so that the many components sharing the
+// connector do not each retry independently against a node that is down.
+package circuitbreaker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+type State string
+
+const (
+	// StateClosed is normal operation - calls pass through to the connector
+	StateClosed State = "closed"
+	// StateOpen means calls are rejected with ErrorReasonDownstreamDown without being passed to the connector
+	StateOpen State = "open"
+	// StateHalfOpen means the reset timeout has passed, and a single IsReady probe is in progress to check if the node has recovered
+	StateHalfOpen State = "half_open"
+)
+
+// CircuitBreaker wraps a connector. After a configured number of consecutive calls fail with
+// ErrorReasonDownstreamDown, or the connector reports it is not ready, the breaker opens and all
+// calls are rejected. Once the reset timeout has passed, the next call first probes the connector
+// with IsReady, and the breaker closes if the connector reports it is ready.
+//
+// IsLive, IsReady, EventStreamNewCheckpointStruct and EventStreamStopped are never rejected.
+// A successful IsReady closes the breaker immediately.
+type CircuitBreaker interface {
+	ffcapi.ExtendedAPI
+
+	// IsOpen returns true if calls are currently being rejected
+	IsOpen() bool
+	// Status returns a summary of the state of the breaker
+	Status() *apitypes.CircuitBreakerStatus
+}
+
+// Options configures the behavior of the circuit breaker
+type Options struct {
+	FailureThreshold int                                                 // number of consecutive downstream failures to open the breaker (defaults to 5)
+	ResetTimeout     time.Duration                                       // how long the breaker stays open, before probing the connector (defaults to 30s)
+	OnStateChange    func(ctx context.Context, oldState, newState State) // optional callback, such as for metrics
+	OnRejected       func(ctx context.Context)                           // optional callback for each call that is rejected
+}
+
+const (
+	defaultFailureThreshold = 5
+	defaultResetTimeout     = 30 * time.Second
+)
+
+type circuitBreaker struct {
+	ctx       context.Context
+	connector ffcapi.API
+	options   Options
+
+	mux        sync.Mutex
+	state      State
+	failures   int
+	lastOpened *fftypes.FFTime
+	rejected   int64
+}
+
+func NewCircuitBreaker(ctx context.Context, connector ffcapi.API, options *Options) CircuitBreaker {
+	cb := &circuitBreaker{
+		ctx:       log.WithLogField(ctx, "role", "circuit_breaker"),
+		connector: connector,
+		state:     StateClosed,
+	}
+	if options != nil {
+		cb.options = *options
+	}
+	if cb.options.FailureThreshold <= 0 {
+		cb.options.FailureThreshold = defaultFailureThreshold
+	}
+	if cb.options.ResetTimeout <= 0 {
+		cb.options.ResetTimeout = defaultResetTimeout
+	}
+	return cb
+}
+
+func (cb *circuitBreaker) IsOpen() bool {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	return cb.state != StateClosed
+}
+
+func (cb *circuitBreaker) Status() *apitypes.CircuitBreakerStatus {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	return &apitypes.CircuitBreakerStatus{
+		State:               string(cb.state),
+		ConsecutiveFailures: cb.failures,
+		LastOpened:          cb.lastOpened,
+		RejectedCalls:       cb.rejected,
+	}
+}
+
+// setState must be called with the lock held. The callback is returned to be called outside the lock.
+func (cb *circuitBreaker) setState(newState State) func() {
+	oldState := cb.state
+	if oldState == newState {
+		return func() {}
+	}
+	cb.state = newState
+	if newState == StateOpen {
+		cb.lastOpened = fftypes.Now()
+	}
+	return func() {
+		switch newState {
+		case StateOpen:
+			log.L(cb.ctx).Warnf("Circuit breaker opened after %d consecutive failures - rejecting connector calls for %s", cb.options.FailureThreshold, cb.options.ResetTimeout)
+		case StateClosed:
+			log.L(cb.ctx).Infof("Circuit breaker closed - connector calls resumed")
+		}
+		if cb.options.OnStateChange != nil {
+			cb.options.OnStateChange(cb.ctx, oldState, newState)
+		}
+	}
+}
+
+// allow checks if a call can proceed, probing the connector with IsReady if the breaker has been
+// open for longer than the reset timeout
+func (cb *circuitBreaker) allow(ctx context.Context) (ffcapi.ErrorReason, error) {
+	cb.mux.Lock()
+	if cb.state == StateClosed {
+		cb.mux.Unlock()
+		return "", nil
+	}
+	if cb.state == StateHalfOpen || time.Since(*cb.lastOpened.Time()) < cb.options.ResetTimeout {
+		return cb.reject(ctx)
+	}
+	notify := cb.setState(StateHalfOpen)
+	cb.mux.Unlock()
+	notify()
+
+	res, _, err := cb.connector.IsReady(ctx)
+	ready := err == nil && res != nil && res.Ready
+
+	cb.mux.Lock()
+	if !ready {
+		log.L(ctx).Debugf("Circuit breaker probe failed (err=%v)", err)
+		notify = cb.setState(StateOpen)
+		cb.mux.Unlock()
+		notify()
+		cb.mux.Lock()
+		return cb.reject(ctx)
+	}
+	cb.failures = 0
+	notify = cb.setState(StateClosed)
+	cb.mux.Unlock()
+	notify()
+	return "", nil
+}
+
+// reject must be called with the lock held, and releases it
+func (cb *circuitBreaker) reject(ctx context.Context) (ffcapi.ErrorReason, error) {
+	cb.rejected++
+	cb.mux.Unlock()
+	if cb.options.OnRejected != nil {
+		cb.options.OnRejected(ctx)
+	}
+	return ffcapi.ErrorReasonDownstreamDown, i18n.NewError(ctx, tmmsgs.MsgCircuitBreakerOpen)
+}
+
+// record updates the failure count based on the outcome of a call to the connector.
+// Any response other than ErrorReasonDownstreamDown means the node was reachable.
+func (cb *circuitBreaker) record(reason ffcapi.ErrorReason) {
+	cb.mux.Lock()
+	notify := func() {}
+	if reason == ffcapi.ErrorReasonDownstreamDown {
+		cb.failures++
+		if cb.state == StateClosed && cb.failures >= cb.options.FailureThreshold {
+			notify = cb.setState(StateOpen)
+		}
+	} else {
+		cb.failures = 0
+	}
+	cb.mux.Unlock()
+	notify()
+}
+
+func (cb *circuitBreaker) invoke(ctx context.Context, fn func() (ffcapi.ErrorReason, error)) (ffcapi.ErrorReason, error) {
+	if reason, err := cb.allow(ctx); err != nil {
+		return reason, err
+	}
+	reason, err := fn()
+	cb.record(reason)
+	return reason, err
+}
@@ -0,0 +1,333 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package circuitbreaker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi/simulator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestCircuitBreaker(t *testing.T, options *Options) (*circuitBreaker, simulator.Simulator) {
+	sim := simulator.NewSimulator(context.Background(), nil)
+	t.Cleanup(sim.Close)
+	return NewCircuitBreaker(context.Background(), sim, options).(*circuitBreaker), sim
+}
+
+// expire makes the breaker behave as if the reset timeout has passed
+func (cb *circuitBreaker) expire() {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	expired := fftypes.FFTime(time.Now().Add(-cb.options.ResetTimeout))
+	cb.lastOpened = &expired
+}
+
+func TestNewCircuitBreakerDefaults(t *testing.T) {
+	cb, _ := newTestCircuitBreaker(t, nil)
+	assert.Equal(t, defaultFailureThreshold, cb.options.FailureThreshold)
+	assert.Equal(t, defaultResetTimeout, cb.options.ResetTimeout)
+	assert.False(t, cb.IsOpen())
+	assert.Equal(t, "closed", cb.Status().State)
+}
+
+func TestOpenRejectProbeAndClose(t *testing.T) {
+	ctx := context.Background()
+	stateChanges := []State{}
+	rejected := 0
+	cb, sim := newTestCircuitBreaker(t, &Options{
+		FailureThreshold: 2,
+		ResetTimeout:     1 * time.Hour,
+		OnStateChange: func(ctx context.Context, oldState, newState State) {
+			stateChanges = append(stateChanges, newState)
+		},
+		OnRejected: func(ctx context.Context) { rejected++ },
+	})
+
+	sim.SetDownstreamDown(true)
+	for i := 0; i < 2; i++ {
+		_, reason, err := cb.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+		assert.Regexp(t, "FF21087", err)
+		assert.Equal(t, ffcapi.ErrorReason(ffcapi.ErrorReasonDownstreamDown), reason)
+	}
+	assert.True(t, cb.IsOpen())
+
+	// Now calls are rejected, without reaching the connector - even once it recovers
+	sim.SetDownstreamDown(false)
+	_, reason, err := cb.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+	assert.Regexp(t, "FF21105", err)
+	assert.Equal(t, ffcapi.ErrorReason(ffcapi.ErrorReasonDownstreamDown), reason)
+	status := cb.Status()
+	assert.Equal(t, "open", status.State)
+	assert.Equal(t, 2, status.ConsecutiveFailures)
+	assert.Equal(t, int64(1), status.RejectedCalls)
+	assert.NotNil(t, status.LastOpened)
+	assert.Equal(t, 1, rejected)
+
+	// After the reset timeout, the next call probes and then proceeds
+	cb.expire()
+	res, _, err := cb.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+	assert.NoError(t, err)
+	assert.NotNil(t, res.GasPrice)
+	assert.False(t, cb.IsOpen())
+	assert.Zero(t, cb.Status().ConsecutiveFailures)
+	assert.Equal(t, []State{StateOpen, StateHalfOpen, StateClosed}, stateChanges)
+}
+
+func TestProbeFailsReopens(t *testing.T) {
+	ctx := context.Background()
+	cb, sim := newTestCircuitBreaker(t, &Options{FailureThreshold: 1})
+
+	sim.SetDownstreamDown(true)
+	_, _, err := cb.TransactionSend(ctx, &ffcapi.TransactionSendRequest{})
+	assert.Regexp(t, "FF21087", err)
+	assert.True(t, cb.IsOpen())
+
+	cb.expire()
+	firstOpened := cb.Status().LastOpened
+	_, _, err = cb.TransactionSend(ctx, &ffcapi.TransactionSendRequest{})
+	assert.Regexp(t, "FF21105", err)
+	assert.Equal(t, "open", cb.Status().State)
+	assert.NotEqual(t, firstOpened, cb.Status().LastOpened)
+}
+
+func TestHalfOpenRejectsConcurrentCalls(t *testing.T) {
+	ctx := context.Background()
+	mca := &ffcapimocks.ExtendedAPI{}
+	cb := NewCircuitBreaker(ctx, mca, &Options{FailureThreshold: 1}).(*circuitBreaker)
+	mca.On("AddressBalance", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(ffcapi.ErrorReasonDownstreamDown), fmt.Errorf("pop")).Once()
+	mca.On("IsReady", mock.Anything).Run(func(args mock.Arguments) {
+		// While the probe is in flight, other calls are rejected
+		assert.Equal(t, "half_open", cb.Status().State)
+		_, _, err := cb.AddressBalance(ctx, &ffcapi.AddressBalanceRequest{})
+		assert.Regexp(t, "FF21105", err)
+	}).Return(&ffcapi.ReadyResponse{Ready: true}, ffcapi.ErrorReason(""), nil)
+	mca.On("AddressBalance", mock.Anything, mock.Anything).Return(&ffcapi.AddressBalanceResponse{}, ffcapi.ErrorReason(""), nil).Once()
+
+	_, _, err := cb.AddressBalance(ctx, &ffcapi.AddressBalanceRequest{})
+	assert.Regexp(t, "pop", err)
+	cb.expire()
+	_, _, err = cb.AddressBalance(ctx, &ffcapi.AddressBalanceRequest{})
+	assert.NoError(t, err)
+	assert.False(t, cb.IsOpen())
+	mca.AssertExpectations(t)
+}
+
+func TestOtherErrorsResetFailures(t *testing.T) {
+	ctx := context.Background()
+	cb, sim := newTestCircuitBreaker(t, &Options{FailureThreshold: 2})
+
+	sim.SetDownstreamDown(true)
+	_, _, err := cb.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{BlockNumber: fftypes.NewFFBigInt(1)})
+	assert.Regexp(t, "FF21087", err)
+	assert.Equal(t, 1, cb.Status().ConsecutiveFailures)
+
+	sim.SetDownstreamDown(false)
+	_, reason, err := cb.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{BlockNumber: fftypes.NewFFBigInt(1)})
+	assert.Regexp(t, "FF21093", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+	assert.Zero(t, cb.Status().ConsecutiveFailures)
+}
+
+func TestIsReadyDrivesBreaker(t *testing.T) {
+	ctx := context.Background()
+	cb, sim := newTestCircuitBreaker(t, &Options{FailureThreshold: 1, ResetTimeout: 1 * time.Hour})
+
+	sim.SetDownstreamDown(true)
+	res, _, err := cb.IsReady(ctx)
+	assert.NoError(t, err)
+	assert.False(t, res.Ready)
+	assert.True(t, cb.IsOpen())
+
+	// Never rejected, and closes the breaker as soon as the connector reports ready
+	sim.SetDownstreamDown(false)
+	res, _, err = cb.IsReady(ctx)
+	assert.NoError(t, err)
+	assert.True(t, res.Ready)
+	assert.False(t, cb.IsOpen())
+}
+
+func TestPassThroughWhileOpen(t *testing.T) {
+	ctx := context.Background()
+	cb, sim := newTestCircuitBreaker(t, &Options{FailureThreshold: 1, ResetTimeout: 1 * time.Hour})
+	sim.SetDownstreamDown(true)
+	_, _, _ = cb.IsReady(ctx)
+	assert.True(t, cb.IsOpen())
+
+	live, _, err := cb.IsLive(ctx)
+	assert.NoError(t, err)
+	assert.True(t, live.Up)
+	_, _, err = cb.EventStreamStopped(ctx, &ffcapi.EventStreamStoppedRequest{ID: fftypes.NewUUID()})
+	assert.NoError(t, err)
+	assert.Equal(t, sim.EventStreamNewCheckpointStruct(), cb.EventStreamNewCheckpointStruct())
+}
+
+func TestAllMethodsRejectedWhileOpen(t *testing.T) {
+	ctx := context.Background()
+	mca := &ffcapimocks.ExtendedAPI{}
+	cb := NewCircuitBreaker(ctx, mca, &Options{FailureThreshold: 1, ResetTimeout: 1 * time.Hour}).(*circuitBreaker)
+
+	calls := []func() (ffcapi.ErrorReason, error){
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.AddressBalance(ctx, &ffcapi.AddressBalanceRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.BlockInfoByHash(ctx, &ffcapi.BlockInfoByHashRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.GasEstimate(ctx, &ffcapi.TransactionInput{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.QueryInvoke(ctx, &ffcapi.QueryInvokeRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.TransactionReceipts(ctx, &ffcapi.TransactionReceiptsRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.TransactionByHash(ctx, &ffcapi.TransactionByHashRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.TransactionCancel(ctx, &ffcapi.TransactionCancelRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.TransactionSimulate(ctx, &ffcapi.TransactionSimulateRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.TransactionPrepare(ctx, &ffcapi.TransactionPrepareRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.TransactionSend(ctx, &ffcapi.TransactionSendRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.TransactionSign(ctx, &ffcapi.TransactionSignRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.DeployContractPrepare(ctx, &ffcapi.ContractDeployPrepareRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.EventListenerVerifyOptions(ctx, &ffcapi.EventListenerVerifyOptionsRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.EventListenerAdd(ctx, &ffcapi.EventListenerAddRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.EventListenerRemove(ctx, &ffcapi.EventListenerRemoveRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.EventListenerHWM(ctx, &ffcapi.EventListenerHWMRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := cb.NewBlockListener(ctx, &ffcapi.NewBlockListenerRequest{})
+			return r, err
+		},
+	}
+	// While closed, every call is passed to the connector
+	for _, method := range []string{
+		"AddressBalance", "BlockInfoByHash", "BlockInfoByNumber", "NextNonceForSigner", "GasEstimate", "GasPriceEstimate",
+		"QueryInvoke", "TransactionReceipt", "TransactionReceipts", "TransactionByHash", "TransactionCancel", "TransactionSimulate", "TransactionPrepare", "TransactionSend", "TransactionSign",
+		"DeployContractPrepare", "EventStreamStart", "EventListenerVerifyOptions", "EventListenerAdd", "EventListenerRemove",
+		"EventListenerHWM", "NewBlockListener",
+	} {
+		mca.On(method, mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), nil).Once()
+	}
+	for i, call := range calls {
+		_, err := call()
+		assert.NoError(t, err, i)
+	}
+	mca.AssertExpectations(t)
+
+	mca.On("IsReady", mock.Anything).Return(&ffcapi.ReadyResponse{Ready: false}, ffcapi.ErrorReason(""), nil)
+	_, _, _ = cb.IsReady(ctx)
+	assert.True(t, cb.IsOpen())
+	for i, call := range calls {
+		reason, err := call()
+		assert.Regexp(t, "FF21105", err, i)
+		assert.Equal(t, ffcapi.ErrorReason(ffcapi.ErrorReasonDownstreamDown), reason, i)
+	}
+	assert.Equal(t, int64(len(calls)), cb.Status().RejectedCalls)
+}
+
+func TestOptionalMethodsNotSupported(t *testing.T) {
+	ctx := context.Background()
+	cb := NewCircuitBreaker(ctx, &ffcapimocks.API{}, nil)
+
+	_, reason, err := cb.TransactionSign(ctx, &ffcapi.TransactionSignRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionSign", err)
+
+	_, reason, err = cb.TransactionReceipts(ctx, &ffcapi.TransactionReceiptsRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionReceipts", err)
+
+	_, reason, err = cb.TransactionCancel(ctx, &ffcapi.TransactionCancelRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionCancel", err)
+
+	_, reason, err = cb.TransactionSimulate(ctx, &ffcapi.TransactionSimulateRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionSimulate", err)
+
+	_, reason, err = cb.TransactionByHash(ctx, &ffcapi.TransactionByHashRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionByHash", err)
+
+	// Unsupported methods do not count as failures
+	assert.False(t, cb.IsOpen())
+}
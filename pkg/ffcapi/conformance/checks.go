@@ -0,0 +1,173 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// The checks in this file are used by Run, and are exported so that connectors can apply
+// them to events they collect in their own tests.
+
+func checkpointString(cp ffcapi.EventListenerCheckpoint) string {
+	b, _ := json.Marshal(cp)
+	return string(b)
+}
+
+func listenerKey(ev *ffcapi.ListenerEvent) string {
+	if ev.Event == nil || ev.Event.ID.ListenerID == nil {
+		return ""
+	}
+	return ev.Event.ID.ListenerID.String()
+}
+
+// CheckEventOrder verifies the events delivered for each listener are in strictly increasing
+// block number, transaction index and log index order. Once a removed event is delivered for
+// a listener, the order is reset so the replacement events can be delivered.
+func CheckEventOrder(ctx context.Context, events []*ffcapi.ListenerEvent) error {
+	last := make(map[string]*ffcapi.ListenerEvent)
+	for _, ev := range events {
+		if ev.Event == nil {
+			continue
+		}
+		key := listenerKey(ev)
+		if ev.Removed {
+			delete(last, key)
+			continue
+		}
+		if prev := last[key]; prev != nil && !(ffcapi.ListenerEvents{prev, ev}).Less(0, 1) {
+			return i18n.NewError(ctx, tmmsgs.MsgConformanceEventOutOfOrder, ev.Event, prev.Event)
+		}
+		last[key] = ev
+	}
+	return nil
+}
+
+// CheckCheckpoints verifies every event that is not a removal has a checkpoint, that no checkpoint
+// is less than itself, and that the checkpoints for each listener never go backwards (other than
+// after a removed event).
+func CheckCheckpoints(ctx context.Context, events []*ffcapi.ListenerEvent) error {
+	last := make(map[string]*ffcapi.ListenerEvent)
+	for _, ev := range events {
+		key := listenerKey(ev)
+		if ev.Removed {
+			delete(last, key)
+			continue
+		}
+		if ev.Checkpoint == nil {
+			return i18n.NewError(ctx, tmmsgs.MsgConformanceMissingCheckpoint, ev.Event)
+		}
+		if ev.Checkpoint.LessThan(ev.Checkpoint) {
+			return i18n.NewError(ctx, tmmsgs.MsgConformanceCheckpointLessThanSelf, checkpointString(ev.Checkpoint))
+		}
+		if prev := last[key]; prev != nil && ev.Checkpoint.LessThan(prev.Checkpoint) {
+			return i18n.NewError(ctx, tmmsgs.MsgConformanceCheckpointRegressed, checkpointString(ev.Checkpoint), ev.Event, checkpointString(prev.Checkpoint))
+		}
+		last[key] = ev
+	}
+	return nil
+}
+
+// CheckCheckpointRestore verifies a checkpoint can be serialized to JSON, and restored using
+// EventStreamNewCheckpointStruct to a checkpoint that is neither less than nor greater than the original.
+// This is how FFTM restores persisted checkpoints when it restarts.
+func CheckCheckpointRestore(ctx context.Context, connector ffcapi.API, cp ffcapi.EventListenerCheckpoint) error {
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	restored := connector.EventStreamNewCheckpointStruct()
+	if err := json.Unmarshal(b, &restored); err != nil {
+		return err
+	}
+	if restored == nil || restored.LessThan(cp) || cp.LessThan(restored) {
+		return i18n.NewError(ctx, tmmsgs.MsgConformanceCheckpointRestore, b, checkpointString(restored))
+	}
+	return nil
+}
+
+// CheckHighWaterMark verifies the high water mark for a listener, queried once all events have been
+// delivered, is not before the checkpoint of any of those events
+func CheckHighWaterMark(ctx context.Context, listenerID string, hwm ffcapi.EventListenerCheckpoint, events []*ffcapi.ListenerEvent) error {
+	if hwm == nil {
+		return i18n.NewError(ctx, tmmsgs.MsgConformanceMissingHWM, listenerID)
+	}
+	for _, ev := range events {
+		if !ev.Removed && ev.Checkpoint != nil && hwm.LessThan(ev.Checkpoint) {
+			return i18n.NewError(ctx, tmmsgs.MsgConformanceHWMRegressed, checkpointString(hwm), checkpointString(ev.Checkpoint), ev.Event)
+		}
+	}
+	return nil
+}
+
+// CheckResumedEvents verifies that no event delivered to a listener that was added with a checkpoint
+// is before that checkpoint
+func CheckResumedEvents(ctx context.Context, resumedFrom ffcapi.EventListenerCheckpoint, events []*ffcapi.ListenerEvent) error {
+	for _, ev := range events {
+		if !ev.Removed && ev.Checkpoint != nil && ev.Checkpoint.LessThan(resumedFrom) {
+			return i18n.NewError(ctx, tmmsgs.MsgConformanceEventBeforeResume, ev.Event, checkpointString(ev.Checkpoint), checkpointString(resumedFrom))
+		}
+	}
+	return nil
+}
+
+// CheckRemovedEvents verifies each removed event identifies an event that was previously delivered,
+// by listener, block hash and position in the block
+func CheckRemovedEvents(ctx context.Context, delivered, removed []*ffcapi.ListenerEvent) error {
+	for _, r := range removed {
+		found := false
+		for _, ev := range delivered {
+			if !ev.Removed && ev.Event != nil && r.Event != nil && ev.Event.ID.String() == r.Event.ID.String() {
+				found = true
+				break
+			}
+		}
+		if !found || !r.Removed {
+			return i18n.NewError(ctx, tmmsgs.MsgConformanceRemovedUnknownEvent, r.Event)
+		}
+	}
+	return nil
+}
+
+// CheckBlockHashEvent verifies a new block notification contains at least one block hash, and no empty hashes
+func CheckBlockHashEvent(ctx context.Context, ev *ffcapi.BlockHashEvent) error {
+	valid := ev != nil && len(ev.BlockHashes) > 0
+	if valid {
+		for _, h := range ev.BlockHashes {
+			if h == "" {
+				valid = false
+			}
+		}
+	}
+	if !valid {
+		return i18n.NewError(ctx, tmmsgs.MsgConformanceEmptyBlockHash, ev)
+	}
+	return nil
+}
+
+// CheckErrorReason verifies a call failed, with the expected reason
+func CheckErrorReason(ctx context.Context, method string, expected, reason ffcapi.ErrorReason, err error) error {
+	if err == nil || reason != expected {
+		return i18n.NewError(ctx, tmmsgs.MsgConformanceUnexpectedReason, method, reason, expected, err)
+	}
+	return nil
+}
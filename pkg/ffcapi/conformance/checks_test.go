@@ -0,0 +1,151 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi/simulator"
+	"github.com/stretchr/testify/assert"
+)
+
+func testEvent(listenerID *fftypes.UUID, block, txIndex, logIndex int64) *ffcapi.ListenerEvent {
+	return &ffcapi.ListenerEvent{
+		Checkpoint: &simulator.Checkpoint{Block: uint64(block), TransactionIndex: txIndex, LogIndex: logIndex},
+		Event: &ffcapi.Event{
+			ID: ffcapi.EventID{
+				ListenerID:       listenerID,
+				BlockHash:        fmt.Sprintf("0x%d", block),
+				BlockNumber:      fftypes.FFuint64(block),
+				TransactionIndex: fftypes.FFuint64(txIndex),
+				LogIndex:         fftypes.FFuint64(logIndex),
+			},
+		},
+	}
+}
+
+func removedEvent(ev *ffcapi.ListenerEvent) *ffcapi.ListenerEvent {
+	return &ffcapi.ListenerEvent{Checkpoint: ev.Checkpoint, Event: ev.Event, Removed: true}
+}
+
+type selfLessCheckpoint struct{}
+
+func (selfLessCheckpoint) LessThan(ffcapi.EventListenerCheckpoint) bool { return true }
+
+func TestCheckEventOrder(t *testing.T) {
+	ctx := context.Background()
+	l1, l2 := fftypes.NewUUID(), fftypes.NewUUID()
+	e1 := testEvent(l1, 1, 0, 0)
+	e2 := testEvent(l1, 1, 0, 1)
+	e3 := testEvent(l1, 2, 0, 0)
+
+	// listeners are ordered independently, and checkpoint-only events are ignored
+	assert.NoError(t, CheckEventOrder(ctx, []*ffcapi.ListenerEvent{
+		e1, testEvent(l2, 5, 0, 0), e2, {Checkpoint: e2.Checkpoint}, e3,
+	}))
+	// redelivery at the same position after a removal
+	assert.NoError(t, CheckEventOrder(ctx, []*ffcapi.ListenerEvent{e1, e3, removedEvent(e3), e3}))
+
+	assert.Regexp(t, "FF21111", CheckEventOrder(ctx, []*ffcapi.ListenerEvent{e1, e3, e2}))
+	assert.Regexp(t, "FF21111", CheckEventOrder(ctx, []*ffcapi.ListenerEvent{e1, e1}))
+}
+
+func TestCheckCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	l1 := fftypes.NewUUID()
+	e1 := testEvent(l1, 1, 0, 0)
+	e2 := testEvent(l1, 2, 0, 0)
+
+	assert.NoError(t, CheckCheckpoints(ctx, []*ffcapi.ListenerEvent{e1, {Checkpoint: e1.Checkpoint}, e2, removedEvent(e2), e2}))
+
+	assert.Regexp(t, "FF21112", CheckCheckpoints(ctx, []*ffcapi.ListenerEvent{{Event: e1.Event}}))
+	assert.Regexp(t, "FF21113", CheckCheckpoints(ctx, []*ffcapi.ListenerEvent{e2, e1}))
+	assert.Regexp(t, "FF21114", CheckCheckpoints(ctx, []*ffcapi.ListenerEvent{{Event: e1.Event, Checkpoint: selfLessCheckpoint{}}}))
+}
+
+func TestCheckCheckpointRestore(t *testing.T) {
+	ctx := context.Background()
+	sim := simulator.NewSimulator(ctx, nil)
+	cp := &simulator.Checkpoint{Block: 1, TransactionIndex: 2, LogIndex: 3}
+	assert.NoError(t, CheckCheckpointRestore(ctx, sim, cp))
+
+	// restored in the wrong format
+	mca := &ffcapimocks.API{}
+	mca.On("EventStreamNewCheckpointStruct").Return(&simulator.Checkpoint{Block: 99}).Once()
+	mca.On("EventStreamNewCheckpointStruct").Return(&selfLessCheckpoint{}).Once()
+	err := CheckCheckpointRestore(ctx, mca, &struct {
+		ffcapi.EventListenerCheckpoint
+		Block string `json:"block"`
+	}{EventListenerCheckpoint: cp, Block: "wrong"})
+	assert.Error(t, err)
+	assert.Regexp(t, "FF21115", CheckCheckpointRestore(ctx, mca, cp))
+
+	assert.Error(t, CheckCheckpointRestore(ctx, sim, selfLessCheckpointWithBadJSON{}))
+}
+
+type selfLessCheckpointWithBadJSON struct{ selfLessCheckpoint }
+
+func (selfLessCheckpointWithBadJSON) MarshalJSON() ([]byte, error) { return nil, fmt.Errorf("pop") }
+
+func TestCheckHighWaterMark(t *testing.T) {
+	ctx := context.Background()
+	l1 := fftypes.NewUUID()
+	events := []*ffcapi.ListenerEvent{testEvent(l1, 1, 0, 0), testEvent(l1, 2, 0, 0)}
+	assert.NoError(t, CheckHighWaterMark(ctx, l1.String(), &simulator.Checkpoint{Block: 3, TransactionIndex: -1, LogIndex: -1}, events))
+	assert.NoError(t, CheckHighWaterMark(ctx, l1.String(), events[1].Checkpoint, events))
+	assert.Regexp(t, "FF21117", CheckHighWaterMark(ctx, l1.String(), events[0].Checkpoint, events))
+	assert.Regexp(t, "FF21121", CheckHighWaterMark(ctx, l1.String(), nil, events))
+}
+
+func TestCheckResumedEvents(t *testing.T) {
+	ctx := context.Background()
+	l1 := fftypes.NewUUID()
+	e1 := testEvent(l1, 1, 0, 0)
+	e2 := testEvent(l1, 2, 0, 0)
+	assert.NoError(t, CheckResumedEvents(ctx, e1.Checkpoint, []*ffcapi.ListenerEvent{e1, e2}))
+	assert.Regexp(t, "FF21120", CheckResumedEvents(ctx, e2.Checkpoint, []*ffcapi.ListenerEvent{e1, e2}))
+}
+
+func TestCheckRemovedEvents(t *testing.T) {
+	ctx := context.Background()
+	l1 := fftypes.NewUUID()
+	e1 := testEvent(l1, 1, 0, 0)
+	e2 := testEvent(l1, 2, 0, 0)
+	assert.NoError(t, CheckRemovedEvents(ctx, []*ffcapi.ListenerEvent{e1, e2}, []*ffcapi.ListenerEvent{removedEvent(e2)}))
+	assert.Regexp(t, "FF21116", CheckRemovedEvents(ctx, []*ffcapi.ListenerEvent{e1}, []*ffcapi.ListenerEvent{removedEvent(e2)}))
+	assert.Regexp(t, "FF21116", CheckRemovedEvents(ctx, []*ffcapi.ListenerEvent{e1, e2}, []*ffcapi.ListenerEvent{e2}))
+}
+
+func TestCheckBlockHashEvent(t *testing.T) {
+	ctx := context.Background()
+	assert.NoError(t, CheckBlockHashEvent(ctx, &ffcapi.BlockHashEvent{BlockHashes: []string{"0x1"}}))
+	assert.Regexp(t, "FF21119", CheckBlockHashEvent(ctx, nil))
+	assert.Regexp(t, "FF21119", CheckBlockHashEvent(ctx, &ffcapi.BlockHashEvent{}))
+	assert.Regexp(t, "FF21119", CheckBlockHashEvent(ctx, &ffcapi.BlockHashEvent{BlockHashes: []string{"0x1", ""}}))
+}
+
+func TestCheckErrorReason(t *testing.T) {
+	ctx := context.Background()
+	assert.NoError(t, CheckErrorReason(ctx, "TransactionReceipt", ffcapi.ErrorReasonNotFound, ffcapi.ErrorReasonNotFound, fmt.Errorf("pop")))
+	assert.Regexp(t, "FF21118.*TransactionReceipt", CheckErrorReason(ctx, "TransactionReceipt", ffcapi.ErrorReasonNotFound, "", fmt.Errorf("pop")))
+	assert.Regexp(t, "FF21118", CheckErrorReason(ctx, "TransactionReceipt", ffcapi.ErrorReasonNotFound, ffcapi.ErrorReasonNotFound, nil))
+}
@@ -0,0 +1,356 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package conformance is a test suite that a connector can run against itself, to verify it meets
+// the contracts of ffcapi.API that the transaction manager relies on, but that cannot be expressed
+// in the Go interface. Such as the ordering of events, checkpoint comparison and restore, high
+// water marks, removed events, new block notifications, and the classification of errors.
+//
+// A connector runs the suite from its own tests, with a Harness that connects it to a chain
+// (such as a development node) the test can control:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, &conformance.Harness{
+//			Connector:       myConnector,
+//			ListenerOptions: ffcapi.EventListenerOptions{Filters: ...},
+//			GenerateEvents:  func(t *testing.T) int { ... },
+//			MineBlock:       func(t *testing.T) { ... },
+//		})
+//	}
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Harness supplies the connector under test, and the hooks the suite uses to drive the chain
+type Harness struct {
+	// Connector is the connector under test
+	Connector ffcapi.API
+	// ListenerOptions must match the events created by GenerateEvents, and no other events on the chain.
+	// The FromBlock is set by the suite.
+	ListenerOptions ffcapi.EventListenerOptions
+	// GenerateEvents must mine at least two matching events, across at least two blocks, with at least
+	// one event in the last block mined. It returns the number of events it mined.
+	GenerateEvents func(t *testing.T) int
+	// MineBlock must mine at least one new block
+	MineBlock func(t *testing.T)
+	// Reorg (optional) must replace the specified number of blocks at the head of the chain, so that
+	// the events in those blocks are removed. The next call to MineBlock must include the transactions
+	// from the removed blocks. The removed events tests are skipped if this is not set.
+	Reorg func(t *testing.T, depth int)
+	// SetDownstreamDown (optional) must make the blockchain node unavailable to the connector, or
+	// available again. The downstream_down tests are skipped if this is not set.
+	SetDownstreamDown func(t *testing.T, down bool)
+	// UnknownTransactionHash must be a validly formatted transaction hash with no receipt (defaults to a 32 byte hex string)
+	UnknownTransactionHash string
+	// UnknownBlockHash must be a validly formatted block hash that is not on the chain (defaults to a 32 byte hex string)
+	UnknownBlockHash string
+	// Timeout is the maximum time to wait for each event (defaults to 10s)
+	Timeout time.Duration
+}
+
+const (
+	defaultTimeout     = 10 * time.Second
+	defaultUnknownHash = "0x00000000000000000000000000000000000000000000000000000000deadbeef"
+)
+
+type suite struct {
+	ctx       context.Context
+	h         Harness
+	generated int
+}
+
+type testStream struct {
+	id         *fftypes.UUID
+	listenerID *fftypes.UUID
+	events     chan *ffcapi.ListenerEvent
+	cancelCtx  func()
+	stopped    bool
+}
+
+// Run executes the conformance suite against the connector, as a set of sub-tests of the supplied test.
+// The tests are run in order, and share the chain - so the connector must be the only consumer of
+// the events created by the harness.
+func Run(t *testing.T, harness *Harness) {
+	s := &suite{
+		ctx: context.Background(),
+		h:   *harness,
+	}
+	if s.h.Timeout <= 0 {
+		s.h.Timeout = defaultTimeout
+	}
+	if s.h.UnknownTransactionHash == "" {
+		s.h.UnknownTransactionHash = defaultUnknownHash
+	}
+	if s.h.UnknownBlockHash == "" {
+		s.h.UnknownBlockHash = defaultUnknownHash
+	}
+	t.Run("Liveness", s.testLiveness)
+	t.Run("ErrorReasons", s.testErrorReasons)
+	t.Run("EventOrdering", s.testEventOrdering)
+	t.Run("ResumeFromCheckpoint", s.testResumeFromCheckpoint)
+	t.Run("BlockListener", s.testBlockListener)
+	t.Run("RemovedEvents", s.testRemovedEvents)
+	t.Run("DownstreamDown", s.testDownstreamDown)
+}
+
+func (s *suite) generate(t *testing.T) int {
+	count := s.h.GenerateEvents(t)
+	require.GreaterOrEqual(t, count, 2, "GenerateEvents must generate at least two events")
+	s.generated += count
+	return count
+}
+
+func (s *suite) startStream(t *testing.T, checkpoint ffcapi.EventListenerCheckpoint) *testStream {
+	ctx, cancelCtx := context.WithCancel(s.ctx)
+	ts := &testStream{
+		id:         fftypes.NewUUID(),
+		listenerID: fftypes.NewUUID(),
+		events:     make(chan *ffcapi.ListenerEvent),
+		cancelCtx:  cancelCtx,
+	}
+	// The stream must always consume new block notifications
+	blocks := make(chan *ffcapi.BlockHashEvent)
+	go func() {
+		for {
+			select {
+			case <-blocks:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	options := s.h.ListenerOptions
+	options.FromBlock = ffcapi.FromBlockEarliest
+	_, _, err := s.h.Connector.EventStreamStart(s.ctx, &ffcapi.EventStreamStartRequest{
+		ID:            ts.id,
+		StreamContext: ctx,
+		EventStream:   ts.events,
+		BlockListener: blocks,
+		InitialListeners: []*ffcapi.EventListenerAddRequest{{
+			EventListenerOptions: options,
+			ListenerID:           ts.listenerID,
+			StreamID:             ts.id,
+			Name:                 "conformance",
+			Checkpoint:           checkpoint,
+		}},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { s.stopStream(t, ts) })
+	return ts
+}
+
+func (s *suite) stopStream(t *testing.T, ts *testStream) {
+	if ts.stopped {
+		return
+	}
+	ts.stopped = true
+	ts.cancelCtx()
+	_, _, err := s.h.Connector.EventStreamStopped(s.ctx, &ffcapi.EventStreamStoppedRequest{ID: ts.id})
+	assert.NoError(t, err)
+}
+
+// receive waits for the specified number of events that are not checkpoint-only, returning all events received
+func (s *suite) receive(t *testing.T, ts *testStream, count int) []*ffcapi.ListenerEvent {
+	events := []*ffcapi.ListenerEvent{}
+	for received := 0; received < count; {
+		select {
+		case ev := <-ts.events:
+			events = append(events, ev)
+			if ev.Event != nil {
+				require.Equal(t, ts.listenerID, ev.Event.ID.ListenerID, "event delivered with the wrong listener ID")
+				received++
+			}
+		case <-time.After(s.h.Timeout):
+			require.FailNow(t, fmt.Sprintf("timed out waiting for event %d of %d", received+1, count))
+		}
+	}
+	return events
+}
+
+func (s *suite) testLiveness(t *testing.T) {
+	live, _, err := s.h.Connector.IsLive(s.ctx)
+	require.NoError(t, err)
+	assert.True(t, live.Up)
+
+	ready, _, err := s.h.Connector.IsReady(s.ctx)
+	require.NoError(t, err)
+	assert.True(t, ready.Ready)
+}
+
+func (s *suite) testErrorReasons(t *testing.T) {
+	c := s.h.Connector
+
+	_, reason, err := c.TransactionReceipt(s.ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: s.h.UnknownTransactionHash})
+	assert.NoError(t, CheckErrorReason(s.ctx, "TransactionReceipt", ffcapi.ErrorReasonNotFound, reason, err))
+
+	// Batched receipts are optional, but hashes without receipts must be omitted
+	if batcher, ok := c.(ffcapi.ReceiptBatcher); ok {
+		receipts, reason, err := batcher.TransactionReceipts(s.ctx, &ffcapi.TransactionReceiptsRequest{TransactionHashes: []string{s.h.UnknownTransactionHash}})
+		if err != nil {
+			assert.NoError(t, CheckErrorReason(s.ctx, "TransactionReceipts", ffcapi.ErrorReasonNotSupported, reason, err))
+		} else {
+			assert.Empty(t, receipts.Receipts)
+		}
+	}
+
+	// Transaction lookup is optional, but unknown transactions must be reported as not found
+	if finder, ok := c.(ffcapi.TransactionFinder); ok {
+		_, reason, err = finder.TransactionByHash(s.ctx, &ffcapi.TransactionByHashRequest{TransactionHash: s.h.UnknownTransactionHash})
+		if reason != ffcapi.ErrorReasonNotSupported {
+			assert.NoError(t, CheckErrorReason(s.ctx, "TransactionByHash", ffcapi.ErrorReasonNotFound, reason, err))
+		}
+	}
+
+	_, reason, err = c.BlockInfoByHash(s.ctx, &ffcapi.BlockInfoByHashRequest{BlockHash: s.h.UnknownBlockHash})
+	assert.NoError(t, CheckErrorReason(s.ctx, "BlockInfoByHash", ffcapi.ErrorReasonNotFound, reason, err))
+
+	_, reason, err = c.BlockInfoByNumber(s.ctx, &ffcapi.BlockInfoByNumberRequest{BlockNumber: (*fftypes.FFBigInt)(big.NewInt(1 << 52))})
+	assert.NoError(t, CheckErrorReason(s.ctx, "BlockInfoByNumber", ffcapi.ErrorReasonNotFound, reason, err))
+
+	options := s.h.ListenerOptions
+	options.FromBlock = "not a block"
+	_, reason, err = c.EventListenerVerifyOptions(s.ctx, &ffcapi.EventListenerVerifyOptionsRequest{EventListenerOptions: options})
+	assert.NoError(t, CheckErrorReason(s.ctx, "EventListenerVerifyOptions", ffcapi.ErrorReasonInvalidInputs, reason, err))
+}
+
+func (s *suite) testEventOrdering(t *testing.T) {
+	// Events that exist before the listener starts are caught up from the start of the chain,
+	// then events are delivered as they are mined
+	s.generate(t)
+	ts := s.startStream(t, nil)
+	events := s.receive(t, ts, s.generated)
+	events = append(events, s.receive(t, ts, s.generate(t))...)
+
+	require.NoError(t, CheckEventOrder(s.ctx, events))
+	require.NoError(t, CheckCheckpoints(s.ctx, events))
+	for _, ev := range events {
+		require.NoError(t, CheckCheckpointRestore(s.ctx, s.h.Connector, ev.Checkpoint))
+	}
+
+	hwm, _, err := s.h.Connector.EventListenerHWM(s.ctx, &ffcapi.EventListenerHWMRequest{StreamID: ts.id, ListenerID: ts.listenerID})
+	require.NoError(t, err)
+	require.NoError(t, CheckHighWaterMark(s.ctx, ts.listenerID.String(), hwm.Checkpoint, events))
+	require.NoError(t, CheckCheckpointRestore(s.ctx, s.h.Connector, hwm.Checkpoint))
+}
+
+func (s *suite) testResumeFromCheckpoint(t *testing.T) {
+	ts := s.startStream(t, nil)
+	events := s.receive(t, ts, s.generated)
+	s.stopStream(t, ts)
+
+	// Resume from the last checkpoint, restored from JSON as FFTM does on restart
+	last := events[len(events)-1].Checkpoint
+	require.NoError(t, CheckCheckpointRestore(s.ctx, s.h.Connector, last))
+	ts = s.startStream(t, last)
+	resumed := s.receive(t, ts, s.generate(t))
+	require.NoError(t, CheckResumedEvents(s.ctx, last, resumed))
+	require.NoError(t, CheckEventOrder(s.ctx, resumed))
+	require.NoError(t, CheckCheckpoints(s.ctx, resumed))
+}
+
+func (s *suite) testBlockListener(t *testing.T) {
+	ctx, cancelCtx := context.WithCancel(s.ctx)
+	defer cancelCtx()
+	blocks := make(chan *ffcapi.BlockHashEvent)
+	_, _, err := s.h.Connector.NewBlockListener(s.ctx, &ffcapi.NewBlockListenerRequest{
+		ID:              fftypes.NewUUID(),
+		ListenerContext: ctx,
+		BlockListener:   blocks,
+	})
+	require.NoError(t, err)
+
+	s.h.MineBlock(t)
+	select {
+	case ev := <-blocks:
+		require.NoError(t, CheckBlockHashEvent(s.ctx, ev))
+		_, _, err := s.h.Connector.BlockInfoByHash(s.ctx, &ffcapi.BlockInfoByHashRequest{BlockHash: ev.BlockHashes[len(ev.BlockHashes)-1]})
+		assert.NoError(t, err)
+	case <-time.After(s.h.Timeout):
+		require.FailNow(t, "timed out waiting for new block notification")
+	}
+}
+
+func (s *suite) testRemovedEvents(t *testing.T) {
+	if s.h.Reorg == nil {
+		t.Skip("Reorg not supported by the harness")
+	}
+	ts := s.startStream(t, nil)
+	events := s.receive(t, ts, s.generated)
+	events = append(events, s.receive(t, ts, s.generate(t))...)
+
+	// Every event in the last block must be removed by the reorg
+	var lastBlock fftypes.FFuint64
+	inLastBlock := 0
+	for _, ev := range events {
+		if ev.Event != nil && ev.Event.ID.BlockNumber > lastBlock {
+			lastBlock = ev.Event.ID.BlockNumber
+			inLastBlock = 0
+		}
+		if ev.Event != nil && ev.Event.ID.BlockNumber == lastBlock {
+			inLastBlock++
+		}
+	}
+	s.h.Reorg(t, 1)
+	removed := s.receive(t, ts, inLastBlock)
+	var removals []*ffcapi.ListenerEvent
+	for _, ev := range removed {
+		if ev.Event != nil {
+			removals = append(removals, ev)
+		}
+	}
+	require.NoError(t, CheckRemovedEvents(s.ctx, events, removals))
+
+	// The events are delivered again once the transactions are mined in the replacement block
+	s.h.MineBlock(t)
+	redelivered := s.receive(t, ts, inLastBlock)
+	all := append(append(events, removed...), redelivered...)
+	require.NoError(t, CheckEventOrder(s.ctx, all))
+	require.NoError(t, CheckCheckpoints(s.ctx, all))
+	for _, ev := range redelivered {
+		assert.False(t, ev.Removed)
+	}
+}
+
+func (s *suite) testDownstreamDown(t *testing.T) {
+	if s.h.SetDownstreamDown == nil {
+		t.Skip("SetDownstreamDown not supported by the harness")
+	}
+	s.h.SetDownstreamDown(t, true)
+	defer s.h.SetDownstreamDown(t, false)
+	c := s.h.Connector
+
+	ready, _, err := c.IsReady(s.ctx)
+	if err == nil {
+		assert.False(t, ready.Ready)
+	}
+
+	_, reason, err := c.BlockInfoByNumber(s.ctx, &ffcapi.BlockInfoByNumberRequest{BlockNumber: fftypes.NewFFBigInt(0)})
+	assert.NoError(t, CheckErrorReason(s.ctx, "BlockInfoByNumber", ffcapi.ErrorReasonDownstreamDown, reason, err))
+
+	_, reason, err = c.TransactionReceipt(s.ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: s.h.UnknownTransactionHash})
+	assert.NoError(t, CheckErrorReason(s.ctx, "TransactionReceipt", ffcapi.ErrorReasonDownstreamDown, reason, err))
+}
@@ -0,0 +1,114 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi/remote"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi/simulator"
+	"github.com/stretchr/testify/require"
+)
+
+func newSimulatorHarness(t *testing.T, sim simulator.Simulator, connector ffcapi.API) *Harness {
+	ctx := context.Background()
+	nonce := int64(0)
+	send := func() {
+		prepared, _, err := sim.TransactionPrepare(ctx, &ffcapi.TransactionPrepareRequest{
+			TransactionInput: ffcapi.TransactionInput{
+				TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa", To: "0xcontract"},
+				Method:             fftypes.JSONAnyPtr(`"set"`),
+				Params:             []*fftypes.JSONAny{fftypes.JSONAnyPtr(`"hello"`)},
+			},
+		})
+		require.NoError(t, err)
+		_, _, err = sim.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+			TransactionHeaders: ffcapi.TransactionHeaders{
+				From:  "0xaaaa",
+				To:    "0xcontract",
+				Nonce: fftypes.NewFFBigInt(nonce),
+				Gas:   prepared.Gas,
+			},
+			GasPrice:        fftypes.JSONAnyPtr(`"100"`),
+			TransactionData: prepared.TransactionData,
+		})
+		require.NoError(t, err)
+		nonce++
+	}
+	return &Harness{
+		Connector: connector,
+		ListenerOptions: ffcapi.EventListenerOptions{
+			Filters: []fftypes.JSONAny{`{"event":"set","address":"0xcontract"}`},
+		},
+		GenerateEvents: func(t *testing.T) int {
+			send()
+			sim.MineBlock()
+			send()
+			send()
+			sim.MineBlock()
+			return 3
+		},
+		MineBlock: func(t *testing.T) {
+			sim.MineBlock()
+		},
+		Reorg: func(t *testing.T, depth int) {
+			require.NoError(t, sim.Reorg(depth))
+		},
+		SetDownstreamDown: func(t *testing.T, down bool) {
+			sim.SetDownstreamDown(down)
+		},
+	}
+}
+
+func TestSimulatorConformance(t *testing.T) {
+	sim := simulator.NewSimulator(context.Background(), nil)
+	defer sim.Close()
+	Run(t, newSimulatorHarness(t, sim, sim))
+}
+
+func TestRemoteSimulatorConformance(t *testing.T) {
+	sim := simulator.NewSimulator(context.Background(), nil)
+	defer sim.Close()
+	server := remote.NewServer(context.Background(), sim)
+	defer server.Close()
+	hs := httptest.NewServer(server)
+	defer hs.Close()
+
+	config.RootConfigReset()
+	conf := config.RootSection("ut_conformance")
+	remote.InitConfig(conf)
+	conf.Set(ffresty.HTTPConfigURL, hs.URL)
+	client, err := remote.NewClient(context.Background(), conf)
+	require.NoError(t, err)
+
+	Run(t, newSimulatorHarness(t, sim, client))
+}
+
+func TestOptionalHooksSkipped(t *testing.T) {
+	sim := simulator.NewSimulator(context.Background(), nil)
+	defer sim.Close()
+	h := newSimulatorHarness(t, sim, sim)
+	h.Reorg = nil
+	h.SetDownstreamDown = nil
+	Run(t, h)
+}
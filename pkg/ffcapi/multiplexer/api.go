@@ -0,0 +1,280 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multiplexer
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+func (m *multiplexer) AddressBalance(ctx context.Context, req *ffcapi.AddressBalanceRequest) (res *ffcapi.AddressBalanceResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = m.invoke(ctx, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		res, reason, err = api.AddressBalance(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (m *multiplexer) BlockInfoByHash(ctx context.Context, req *ffcapi.BlockInfoByHashRequest) (res *ffcapi.BlockInfoByHashResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = m.invoke(ctx, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		res, reason, err = api.BlockInfoByHash(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (m *multiplexer) BlockInfoByNumber(ctx context.Context, req *ffcapi.BlockInfoByNumberRequest) (res *ffcapi.BlockInfoByNumberResponse, reason ffcapi.ErrorReason, err error) {
+	served, reason, err := m.invoke(ctx, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		res, reason, err = api.BlockInfoByNumber(ctx, req)
+		return reason, err
+	})
+	if m.options.CrossCheckReads && len(m.backends) > 1 {
+		return m.crossCheckBlockInfo(ctx, served, res, reason, err, req)
+	}
+	return res, reason, err
+}
+
+func (m *multiplexer) NextNonceForSigner(ctx context.Context, req *ffcapi.NextNonceForSignerRequest) (res *ffcapi.NextNonceForSignerResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = m.invoke(ctx, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		res, reason, err = api.NextNonceForSigner(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (m *multiplexer) GasEstimate(ctx context.Context, req *ffcapi.TransactionInput) (res *ffcapi.GasEstimateResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = m.invoke(ctx, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		res, reason, err = api.GasEstimate(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (m *multiplexer) GasPriceEstimate(ctx context.Context, req *ffcapi.GasPriceEstimateRequest) (res *ffcapi.GasPriceEstimateResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = m.invoke(ctx, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		res, reason, err = api.GasPriceEstimate(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (m *multiplexer) QueryInvoke(ctx context.Context, req *ffcapi.QueryInvokeRequest) (res *ffcapi.QueryInvokeResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = m.invoke(ctx, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		res, reason, err = api.QueryInvoke(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (m *multiplexer) TransactionReceipt(ctx context.Context, req *ffcapi.TransactionReceiptRequest) (res *ffcapi.TransactionReceiptResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = m.invoke(ctx, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		res, reason, err = api.TransactionReceipt(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (m *multiplexer) TransactionReceipts(ctx context.Context, req *ffcapi.TransactionReceiptsRequest) (res *ffcapi.TransactionReceiptsResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = m.invoke(ctx, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		batcher, ok := api.(ffcapi.ReceiptBatcher)
+		if !ok {
+			return ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionReceipts")
+		}
+		res, reason, err = batcher.TransactionReceipts(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (m *multiplexer) TransactionByHash(ctx context.Context, req *ffcapi.TransactionByHashRequest) (res *ffcapi.TransactionByHashResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = m.invoke(ctx, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		finder, ok := api.(ffcapi.TransactionFinder)
+		if !ok {
+			return ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionByHash")
+		}
+		res, reason, err = finder.TransactionByHash(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (m *multiplexer) TransactionCancel(ctx context.Context, req *ffcapi.TransactionCancelRequest) (res *ffcapi.TransactionCancelResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = m.invoke(ctx, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		canceller, ok := api.(ffcapi.TransactionCanceller)
+		if !ok {
+			return ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionCancel")
+		}
+		res, reason, err = canceller.TransactionCancel(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (m *multiplexer) TransactionSimulate(ctx context.Context, req *ffcapi.TransactionSimulateRequest) (res *ffcapi.TransactionSimulateResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = m.invoke(ctx, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		simulator, ok := api.(ffcapi.TransactionSimulator)
+		if !ok {
+			return ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionSimulate")
+		}
+		res, reason, err = simulator.TransactionSimulate(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (m *multiplexer) TransactionPrepare(ctx context.Context, req *ffcapi.TransactionPrepareRequest) (res *ffcapi.TransactionPrepareResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = m.invoke(ctx, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		res, reason, err = api.TransactionPrepare(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (m *multiplexer) TransactionSend(ctx context.Context, req *ffcapi.TransactionSendRequest) (res *ffcapi.TransactionSendResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = m.invoke(ctx, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		res, reason, err = api.TransactionSend(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (m *multiplexer) TransactionSign(ctx context.Context, req *ffcapi.TransactionSignRequest) (res *ffcapi.TransactionSignResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = m.invoke(ctx, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		signer, ok := api.(ffcapi.TransactionSigner)
+		if !ok {
+			return ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionSign")
+		}
+		res, reason, err = signer.TransactionSign(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (m *multiplexer) DeployContractPrepare(ctx context.Context, req *ffcapi.ContractDeployPrepareRequest) (res *ffcapi.TransactionPrepareResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = m.invoke(ctx, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		res, reason, err = api.DeployContractPrepare(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (m *multiplexer) EventStreamStart(ctx context.Context, req *ffcapi.EventStreamStartRequest) (res *ffcapi.EventStreamStartResponse, reason ffcapi.ErrorReason, err error) {
+	b, reason, err := m.invoke(ctx, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		res, reason, err = api.EventStreamStart(ctx, req)
+		return reason, err
+	})
+	if err == nil {
+		m.pinStream(req.ID, b)
+	}
+	return res, reason, err
+}
+
+func (m *multiplexer) EventStreamStopped(ctx context.Context, req *ffcapi.EventStreamStoppedRequest) (res *ffcapi.EventStreamStoppedResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = m.invokeStream(ctx, req.ID, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		res, reason, err = api.EventStreamStopped(ctx, req)
+		return reason, err
+	})
+	if req.ID != nil {
+		m.pinStream(req.ID, nil)
+	}
+	return res, reason, err
+}
+
+func (m *multiplexer) EventListenerVerifyOptions(ctx context.Context, req *ffcapi.EventListenerVerifyOptionsRequest) (res *ffcapi.EventListenerVerifyOptionsResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = m.invoke(ctx, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		res, reason, err = api.EventListenerVerifyOptions(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (m *multiplexer) EventListenerAdd(ctx context.Context, req *ffcapi.EventListenerAddRequest) (res *ffcapi.EventListenerAddResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = m.invokeStream(ctx, req.StreamID, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		res, reason, err = api.EventListenerAdd(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (m *multiplexer) EventListenerRemove(ctx context.Context, req *ffcapi.EventListenerRemoveRequest) (res *ffcapi.EventListenerRemoveResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = m.invokeStream(ctx, req.StreamID, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		res, reason, err = api.EventListenerRemove(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (m *multiplexer) EventListenerHWM(ctx context.Context, req *ffcapi.EventListenerHWMRequest) (res *ffcapi.EventListenerHWMResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = m.invokeStream(ctx, req.StreamID, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		res, reason, err = api.EventListenerHWM(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+// EventStreamNewCheckpointStruct uses the first backend, as all backends must be the same type of connector
+func (m *multiplexer) EventStreamNewCheckpointStruct() ffcapi.EventListenerCheckpoint {
+	return m.backends[0].api.EventStreamNewCheckpointStruct()
+}
+
+func (m *multiplexer) NewBlockListener(ctx context.Context, req *ffcapi.NewBlockListenerRequest) (res *ffcapi.NewBlockListenerResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = m.invoke(ctx, func(api ffcapi.API) (ffcapi.ErrorReason, error) {
+		res, reason, err = api.NewBlockListener(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+// IsLive reports live if any backend is live
+func (m *multiplexer) IsLive(ctx context.Context) (res *ffcapi.LiveResponse, reason ffcapi.ErrorReason, err error) {
+	for _, b := range m.ordered() {
+		res, reason, err = b.api.IsLive(ctx)
+		if err == nil && res != nil && res.Up {
+			return res, "", nil
+		}
+	}
+	return res, reason, err
+}
+
+// IsReady checks the health of every backend, and reports ready if any backend is ready.
+// The downstream details include the status of each backend, in order.
+func (m *multiplexer) IsReady(ctx context.Context) (*ffcapi.ReadyResponse, ffcapi.ErrorReason, error) {
+	results := m.checkHealth(ctx)
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	ready := false
+	details := make([]fftypes.JSONObject, len(m.backends))
+	for i, b := range m.backends {
+		status := fftypes.JSONObject{
+			"ready":   b.healthy,
+			"lagging": b.lagging,
+		}
+		if results[i] != nil && results[i].DownstreamDetails != nil {
+			status["downstreamDetails"] = results[i].DownstreamDetails
+		}
+		details[i] = status
+		ready = ready || b.healthy
+	}
+	return &ffcapi.ReadyResponse{
+		Ready:             ready,
+		DownstreamDetails: fftypes.JSONAnyPtr(fftypes.JSONObject{"backends": details}.String()),
+	}, "", nil
+}
@@ -0,0 +1,298 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package multiplexer provides an ffcapi.API that spreads a single transaction manager
+// across an ordered list of backend connectors (typically one per blockchain node),
+// failing over between them when a node becomes unavailable.
+package multiplexer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// Multiplexer is an ffcapi.API that routes each call to the first healthy backend, in the order
+// the backends were supplied. A backend is marked down when a call to it returns ErrorReasonDownstreamDown
+// (the call is then retried on the next backend), or when IsReady reports it is not ready.
+// Backends that are down are re-checked in the background, and preferred again once they recover.
+//
+// Event streams are pinned to the backend on which they were started, so that listener
+// operations and checkpoints are always handled by the same connector.
+type Multiplexer interface {
+	ffcapi.ExtendedAPI
+
+	// Primary returns the index of the backend that currently receives calls (including transaction submission)
+	Primary() int
+	// Close stops the background health checking
+	Close()
+}
+
+// Options configures the behavior of the multiplexer
+type Options struct {
+	HealthCheckInterval time.Duration // how often IsReady is called on every backend to update its health (defaults to 5s)
+	CrossCheckReads     bool          // when true, BlockInfoByNumber is queried on all healthy backends, to detect nodes that are lagging behind the others
+}
+
+const defaultHealthCheckInterval = 5 * time.Second
+
+type backend struct {
+	index   int
+	api     ffcapi.API
+	healthy bool // protected by multiplexer mux
+	lagging bool // protected by multiplexer mux
+}
+
+type multiplexer struct {
+	ctx        context.Context
+	cancelCtx  func()
+	options    Options
+	backends   []*backend
+	healthDone chan struct{}
+
+	mux     sync.Mutex
+	streams map[fftypes.UUID]*backend // event streams are pinned to the backend they started on
+}
+
+// NewMultiplexer creates a multiplexer over the supplied backends, which are tried in order.
+// The backends must all be connectors for the same blockchain, of the same type.
+func NewMultiplexer(ctx context.Context, backends []ffcapi.API, options *Options) (Multiplexer, error) {
+	if len(backends) == 0 {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgMultiplexerNoBackends)
+	}
+	m := &multiplexer{
+		backends:   make([]*backend, len(backends)),
+		streams:    make(map[fftypes.UUID]*backend),
+		healthDone: make(chan struct{}),
+	}
+	if options != nil {
+		m.options = *options
+	}
+	if m.options.HealthCheckInterval <= 0 {
+		m.options.HealthCheckInterval = defaultHealthCheckInterval
+	}
+	for i, api := range backends {
+		m.backends[i] = &backend{index: i, api: api, healthy: true}
+	}
+	m.ctx, m.cancelCtx = context.WithCancel(log.WithLogField(ctx, "role", "ffcapi_multiplexer"))
+	go m.healthChecker()
+	return m, nil
+}
+
+func (m *multiplexer) Close() {
+	m.cancelCtx()
+	<-m.healthDone
+}
+
+func (m *multiplexer) Primary() int {
+	return m.ordered()[0].index
+}
+
+// ordered returns the backends in the order they should be tried. Healthy backends come first,
+// then healthy backends that have been detected lagging behind the others, and finally the backends
+// that are down (as a last resort, as the health information might be stale).
+func (m *multiplexer) ordered() []*backend {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	ordered := make([]*backend, 0, len(m.backends))
+	for _, b := range m.backends {
+		if b.healthy && !b.lagging {
+			ordered = append(ordered, b)
+		}
+	}
+	for _, b := range m.backends {
+		if b.healthy && b.lagging {
+			ordered = append(ordered, b)
+		}
+	}
+	for _, b := range m.backends {
+		if !b.healthy {
+			ordered = append(ordered, b)
+		}
+	}
+	return ordered
+}
+
+func (m *multiplexer) setHealthy(ctx context.Context, b *backend, healthy bool, err error) {
+	m.mux.Lock()
+	changed := b.healthy != healthy
+	b.healthy = healthy
+	m.mux.Unlock()
+	if changed && healthy {
+		log.L(ctx).Infof("Backend %d has recovered", b.index)
+	} else if changed {
+		log.L(ctx).Warnf("Backend %d is down - failing over: %v", b.index, err)
+	}
+}
+
+func (m *multiplexer) setLagging(ctx context.Context, b *backend, lagging bool) {
+	m.mux.Lock()
+	changed := b.lagging != lagging
+	b.lagging = lagging
+	m.mux.Unlock()
+	if changed && lagging {
+		log.L(ctx).Warnf("Backend %d is lagging behind the other backends", b.index)
+	} else if changed {
+		log.L(ctx).Infof("Backend %d has caught up with the other backends", b.index)
+	}
+}
+
+// invoke calls fn on each backend in turn until one succeeds, or returns an error that is not
+// ErrorReasonDownstreamDown. The backend that handled the call is returned.
+func (m *multiplexer) invoke(ctx context.Context, fn func(api ffcapi.API) (ffcapi.ErrorReason, error)) (*backend, ffcapi.ErrorReason, error) {
+	var b *backend
+	var reason ffcapi.ErrorReason
+	var err error
+	for _, b = range m.ordered() {
+		reason, err = fn(b.api)
+		if reason != ffcapi.ErrorReasonDownstreamDown {
+			// Any response other than downstream down means we reached the node
+			m.setHealthy(ctx, b, true, nil)
+			return b, reason, err
+		}
+		m.setHealthy(ctx, b, false, err)
+	}
+	return b, reason, err
+}
+
+// invokeStream routes calls for an event stream to the backend it was started on
+func (m *multiplexer) invokeStream(ctx context.Context, streamID *fftypes.UUID, fn func(api ffcapi.API) (ffcapi.ErrorReason, error)) (ffcapi.ErrorReason, error) {
+	var b *backend
+	if streamID != nil {
+		m.mux.Lock()
+		b = m.streams[*streamID]
+		m.mux.Unlock()
+	}
+	if b == nil {
+		_, reason, err := m.invoke(ctx, fn)
+		return reason, err
+	}
+	reason, err := fn(b.api)
+	if reason == ffcapi.ErrorReasonDownstreamDown {
+		m.setHealthy(ctx, b, false, err)
+	}
+	return reason, err
+}
+
+func (m *multiplexer) pinStream(streamID *fftypes.UUID, b *backend) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if b == nil {
+		delete(m.streams, *streamID)
+	} else {
+		m.streams[*streamID] = b
+	}
+}
+
+func (m *multiplexer) healthChecker() {
+	defer close(m.healthDone)
+	ticker := time.NewTicker(m.options.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.checkHealth(m.ctx)
+		case <-m.ctx.Done():
+			log.L(m.ctx).Debugf("Health checker exiting")
+			return
+		}
+	}
+}
+
+func (m *multiplexer) checkHealth(ctx context.Context) []*ffcapi.ReadyResponse {
+	results := make([]*ffcapi.ReadyResponse, len(m.backends))
+	for i, b := range m.backends {
+		res, _, err := b.api.IsReady(ctx)
+		if err == nil && res == nil {
+			res = &ffcapi.ReadyResponse{}
+		}
+		results[i] = res
+		m.setHealthy(ctx, b, err == nil && res.Ready, err)
+	}
+	return results
+}
+
+// crossCheckBlockInfo compares the result of a block lookup from the backend that served it, with the
+// results from the other healthy backends. A backend that does not have a block the others have is lagging.
+// If the serving backend was the one lagging, the result from the first backend that has the block is returned.
+func (m *multiplexer) crossCheckBlockInfo(ctx context.Context, served *backend, res *ffcapi.BlockInfoByNumberResponse, reason ffcapi.ErrorReason, err error, req *ffcapi.BlockInfoByNumberRequest) (*ffcapi.BlockInfoByNumberResponse, ffcapi.ErrorReason, error) {
+	if err != nil && reason != ffcapi.ErrorReasonNotFound {
+		return res, reason, err
+	}
+
+	type result struct {
+		b      *backend
+		res    *ffcapi.BlockInfoByNumberResponse
+		reason ffcapi.ErrorReason
+		err    error
+	}
+	var others []*result
+	m.mux.Lock()
+	for _, b := range m.backends {
+		if b != served && b.healthy {
+			others = append(others, &result{b: b})
+		}
+	}
+	m.mux.Unlock()
+	var wg sync.WaitGroup
+	for _, r := range others {
+		wg.Add(1)
+		go func(r *result) {
+			defer wg.Done()
+			r.res, r.reason, r.err = r.b.api.BlockInfoByNumber(ctx, req)
+		}(r)
+	}
+	wg.Wait()
+
+	var alternative *result
+	for _, r := range others {
+		switch {
+		case r.err == nil && r.res != nil:
+			if err != nil {
+				// The backend that served the request is behind this one
+				if alternative == nil {
+					alternative = r
+				}
+				continue
+			}
+			if r.res.BlockHash != res.BlockHash {
+				log.L(ctx).Warnf("Backend %d returned block hash %s for block %s, but backend %d returned %s", r.b.index, r.res.BlockHash, req.BlockNumber, served.index, res.BlockHash)
+			}
+			m.setLagging(ctx, r.b, false)
+		case r.reason == ffcapi.ErrorReasonNotFound:
+			if err == nil {
+				m.setLagging(ctx, r.b, true)
+			}
+		case r.reason == ffcapi.ErrorReasonDownstreamDown:
+			m.setHealthy(ctx, r.b, false, r.err)
+		}
+	}
+	if alternative != nil {
+		m.setLagging(ctx, served, true)
+		m.setLagging(ctx, alternative.b, false)
+		return alternative.res, "", nil
+	}
+	if err == nil {
+		m.setLagging(ctx, served, false)
+	}
+	return res, reason, err
+}
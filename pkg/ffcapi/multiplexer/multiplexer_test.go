@@ -0,0 +1,424 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multiplexer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi/simulator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestMultiplexer(t *testing.T, options *Options, backends ...ffcapi.API) *multiplexer {
+	if options == nil {
+		options = &Options{HealthCheckInterval: 1 * time.Hour}
+	}
+	m, err := NewMultiplexer(context.Background(), backends, options)
+	assert.NoError(t, err)
+	t.Cleanup(m.Close)
+	return m.(*multiplexer)
+}
+
+func newTestSimulators(t *testing.T, count int) []simulator.Simulator {
+	sims := make([]simulator.Simulator, count)
+	for i := range sims {
+		sims[i] = simulator.NewSimulator(context.Background(), nil)
+		t.Cleanup(sims[i].Close)
+	}
+	return sims
+}
+
+func newReadyMock() *ffcapimocks.ExtendedAPI {
+	mca := &ffcapimocks.ExtendedAPI{}
+	mca.On("IsReady", mock.Anything).Return(&ffcapi.ReadyResponse{Ready: true}, ffcapi.ErrorReason(""), nil).Maybe()
+	return mca
+}
+
+func TestNewMultiplexerNoBackends(t *testing.T) {
+	_, err := NewMultiplexer(context.Background(), nil, nil)
+	assert.Regexp(t, "FF21104", err)
+}
+
+func TestNewMultiplexerDefaults(t *testing.T) {
+	sims := newTestSimulators(t, 1)
+	m, err := NewMultiplexer(context.Background(), []ffcapi.API{sims[0]}, nil)
+	assert.NoError(t, err)
+	defer m.Close()
+	assert.Equal(t, defaultHealthCheckInterval, m.(*multiplexer).options.HealthCheckInterval)
+	assert.Equal(t, 0, m.Primary())
+}
+
+func TestFailoverAndRecovery(t *testing.T) {
+	ctx := context.Background()
+	sims := newTestSimulators(t, 2)
+	m := newTestMultiplexer(t, nil, sims[0], sims[1])
+
+	sims[0].SetDownstreamDown(true)
+	res, _, err := m.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{Signer: "0xaaaa"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), res.Nonce.Int64())
+	assert.Equal(t, 1, m.Primary())
+
+	// Subsequent writes go straight to the new primary
+	prepared, _, err := m.TransactionPrepare(ctx, &ffcapi.TransactionPrepareRequest{
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa", To: "0xcontract"},
+			Method:             fftypes.JSONAnyPtr(`"set"`),
+		},
+	})
+	assert.NoError(t, err)
+	sent, _, err := m.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa", To: "0xcontract", Nonce: fftypes.NewFFBigInt(0), Gas: prepared.Gas},
+		GasPrice:           fftypes.JSONAnyPtr(`"100"`),
+		TransactionData:    prepared.TransactionData,
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, sent.TransactionHash)
+	assert.Equal(t, 0, sims[0].PendingTransactionCount())
+	assert.Equal(t, 1, sims[1].PendingTransactionCount())
+
+	// The health check restores the original primary
+	sims[0].SetDownstreamDown(false)
+	m.checkHealth(ctx)
+	assert.Equal(t, 0, m.Primary())
+}
+
+func TestAllBackendsDown(t *testing.T) {
+	sims := newTestSimulators(t, 2)
+	m := newTestMultiplexer(t, nil, sims[0], sims[1])
+	sims[0].SetDownstreamDown(true)
+	sims[1].SetDownstreamDown(true)
+
+	_, reason, err := m.GasPriceEstimate(context.Background(), &ffcapi.GasPriceEstimateRequest{})
+	assert.Regexp(t, "FF21087", err)
+	assert.Equal(t, ffcapi.ErrorReason(ffcapi.ErrorReasonDownstreamDown), reason)
+
+	ready, _, err := m.IsReady(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, ready.Ready)
+	assert.JSONEq(t, `{"backends":[
+		{"ready":false,"lagging":false,"downstreamDetails":{"blockNumber":0}},
+		{"ready":false,"lagging":false,"downstreamDetails":{"blockNumber":0}}
+	]}`, ready.DownstreamDetails.String())
+}
+
+func TestOtherErrorsDoNotFailOver(t *testing.T) {
+	mca1 := newReadyMock()
+	mca1.On("TransactionSend", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNonceTooLow, fmt.Errorf("nonce too low"))
+	mca2 := newReadyMock()
+	m := newTestMultiplexer(t, nil, mca1, mca2)
+
+	_, reason, err := m.TransactionSend(context.Background(), &ffcapi.TransactionSendRequest{})
+	assert.Regexp(t, "nonce too low", err)
+	assert.Equal(t, ffcapi.ErrorReasonNonceTooLow, reason)
+	assert.Equal(t, 0, m.Primary())
+	mca1.AssertExpectations(t)
+	mca2.AssertExpectations(t)
+}
+
+func TestHealthCheckerMarksNotReady(t *testing.T) {
+	mca1 := &ffcapimocks.ExtendedAPI{}
+	checked := make(chan struct{}, 1)
+	mca1.On("IsReady", mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Run(func(args mock.Arguments) {
+		select {
+		case checked <- struct{}{}:
+		default:
+		}
+	})
+	mca2 := newReadyMock()
+	m := newTestMultiplexer(t, &Options{HealthCheckInterval: 1 * time.Millisecond}, mca1, mca2)
+
+	<-checked
+	<-checked
+	assert.Equal(t, 1, m.Primary())
+}
+
+func TestIsLive(t *testing.T) {
+	mca1 := newReadyMock()
+	mca1.On("IsLive", mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+	mca2 := newReadyMock()
+	mca2.On("IsLive", mock.Anything).Return(&ffcapi.LiveResponse{Up: true}, ffcapi.ErrorReason(""), nil)
+	m := newTestMultiplexer(t, nil, mca1, mca2)
+
+	res, _, err := m.IsLive(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, res.Up)
+
+	mca2.ExpectedCalls = nil
+	mca2.On("IsLive", mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+	_, _, err = m.IsLive(context.Background())
+	assert.Regexp(t, "pop", err)
+}
+
+func TestEventStreamsPinnedToBackend(t *testing.T) {
+	ctx := context.Background()
+	streamID := fftypes.NewUUID()
+	listenerID := fftypes.NewUUID()
+
+	mca1 := newReadyMock()
+	mca1.On("EventStreamStart", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(ffcapi.ErrorReasonDownstreamDown), fmt.Errorf("pop")).Once()
+	mca2 := newReadyMock()
+	mca2.On("EventStreamStart", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil)
+	mca2.On("EventListenerAdd", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerAddResponse{}, ffcapi.ErrorReason(""), nil)
+	mca2.On("EventListenerHWM", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerHWMResponse{}, ffcapi.ErrorReason(""), nil)
+	mca2.On("EventListenerRemove", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(ffcapi.ErrorReasonDownstreamDown), fmt.Errorf("pop"))
+	mca2.On("EventStreamStopped", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStoppedResponse{}, ffcapi.ErrorReason(""), nil)
+	m := newTestMultiplexer(t, nil, mca1, mca2)
+
+	_, _, err := m.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{ID: streamID})
+	assert.NoError(t, err)
+
+	// Even once the first backend is healthy again, the stream stays on the second
+	m.checkHealth(ctx)
+	assert.Equal(t, 0, m.Primary())
+	_, _, err = m.EventListenerAdd(ctx, &ffcapi.EventListenerAddRequest{StreamID: streamID, ListenerID: listenerID})
+	assert.NoError(t, err)
+	_, _, err = m.EventListenerHWM(ctx, &ffcapi.EventListenerHWMRequest{StreamID: streamID, ListenerID: listenerID})
+	assert.NoError(t, err)
+
+	// Errors are not retried on another backend
+	_, reason, err := m.EventListenerRemove(ctx, &ffcapi.EventListenerRemoveRequest{StreamID: streamID, ListenerID: listenerID})
+	assert.Regexp(t, "pop", err)
+	assert.Equal(t, ffcapi.ErrorReason(ffcapi.ErrorReasonDownstreamDown), reason)
+	assert.False(t, m.backends[1].healthy)
+
+	_, _, err = m.EventStreamStopped(ctx, &ffcapi.EventStreamStoppedRequest{ID: streamID})
+	assert.NoError(t, err)
+	assert.Empty(t, m.streams)
+
+	mca1.AssertExpectations(t)
+	mca2.AssertExpectations(t)
+}
+
+func TestUnpinnedStreamCallsUsePrimary(t *testing.T) {
+	ctx := context.Background()
+	mca := newReadyMock()
+	mca.On("EventListenerAdd", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerAddResponse{}, ffcapi.ErrorReason(""), nil)
+	mca.On("EventStreamStopped", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStoppedResponse{}, ffcapi.ErrorReason(""), nil)
+	m := newTestMultiplexer(t, nil, mca)
+
+	_, _, err := m.EventListenerAdd(ctx, &ffcapi.EventListenerAddRequest{StreamID: fftypes.NewUUID()})
+	assert.NoError(t, err)
+	_, _, err = m.EventStreamStopped(ctx, &ffcapi.EventStreamStoppedRequest{})
+	assert.NoError(t, err)
+	mca.AssertExpectations(t)
+}
+
+func TestCrossCheckLaggingPrimary(t *testing.T) {
+	ctx := context.Background()
+	sims := newTestSimulators(t, 3)
+	m := newTestMultiplexer(t, &Options{HealthCheckInterval: 1 * time.Hour, CrossCheckReads: true}, sims[0], sims[1], sims[2])
+
+	sims[1].MineBlock()
+	block := sims[2].MineBlock()
+
+	// The primary does not have block 1 yet, so we get it from the next backend and demote the primary
+	res, _, err := m.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{BlockNumber: fftypes.NewFFBigInt(1)})
+	assert.NoError(t, err)
+	assert.Equal(t, block.BlockHash, res.BlockHash)
+	assert.True(t, m.backends[0].lagging)
+	assert.Equal(t, 1, m.Primary())
+
+	// Once it catches up, it is restored
+	sims[0].MineBlock()
+	res, _, err = m.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{BlockNumber: fftypes.NewFFBigInt(1)})
+	assert.NoError(t, err)
+	assert.Equal(t, block.BlockHash, res.BlockHash)
+	assert.False(t, m.backends[0].lagging)
+	assert.Equal(t, 0, m.Primary())
+
+	// Blocks nobody has are simply not found
+	_, reason, err := m.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{BlockNumber: fftypes.NewFFBigInt(2)})
+	assert.Regexp(t, "FF21093", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+	assert.Equal(t, 0, m.Primary())
+}
+
+func TestCrossCheckLaggingSecondary(t *testing.T) {
+	ctx := context.Background()
+	sims := newTestSimulators(t, 3)
+	m := newTestMultiplexer(t, &Options{HealthCheckInterval: 1 * time.Hour, CrossCheckReads: true}, sims[0], sims[1], sims[2])
+
+	sims[0].MineBlock()
+	sims[2].MineBlock()
+	sims[2].SetDownstreamDown(true)
+
+	_, _, err := m.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{BlockNumber: fftypes.NewFFBigInt(1)})
+	assert.NoError(t, err)
+	assert.False(t, m.backends[0].lagging)
+	assert.True(t, m.backends[1].lagging)
+	assert.False(t, m.backends[2].healthy)
+	assert.Equal(t, []int{0, 1, 2}, backendIndexes(m.ordered()))
+}
+
+func TestCrossCheckHashMismatchAndErrors(t *testing.T) {
+	ctx := context.Background()
+	mca1 := newReadyMock()
+	mca1.On("BlockInfoByNumber", mock.Anything, mock.Anything).Return(&ffcapi.BlockInfoByNumberResponse{
+		BlockInfo: ffcapi.BlockInfo{BlockHash: "0x111"},
+	}, ffcapi.ErrorReason(""), nil)
+	mca2 := newReadyMock()
+	mca2.On("BlockInfoByNumber", mock.Anything, mock.Anything).Return(&ffcapi.BlockInfoByNumberResponse{
+		BlockInfo: ffcapi.BlockInfo{BlockHash: "0x222"},
+	}, ffcapi.ErrorReason(""), nil)
+	m := newTestMultiplexer(t, &Options{HealthCheckInterval: 1 * time.Hour, CrossCheckReads: true}, mca1, mca2)
+
+	// A mismatch is logged, but we return the answer from the primary
+	res, _, err := m.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{BlockNumber: fftypes.NewFFBigInt(1)})
+	assert.NoError(t, err)
+	assert.Equal(t, "0x111", res.BlockHash)
+	assert.Equal(t, 0, m.Primary())
+
+	// Other errors from the primary are returned without cross-checking
+	mca1.ExpectedCalls = nil
+	mca1.On("BlockInfoByNumber", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonInvalidInputs, fmt.Errorf("pop"))
+	_, reason, err := m.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{BlockNumber: fftypes.NewFFBigInt(1)})
+	assert.Regexp(t, "pop", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+	mca2.AssertNumberOfCalls(t, "BlockInfoByNumber", 1)
+}
+
+func backendIndexes(backends []*backend) []int {
+	indexes := make([]int, len(backends))
+	for i, b := range backends {
+		indexes[i] = b.index
+	}
+	return indexes
+}
+
+func TestAllMethodsFailOver(t *testing.T) {
+	ctx := context.Background()
+	mca1 := newReadyMock()
+	mca2 := newReadyMock()
+	m := newTestMultiplexer(t, nil, mca1, mca2)
+
+	calls := map[string]func() error{
+		"AddressBalance": func() error {
+			_, _, err := m.AddressBalance(ctx, &ffcapi.AddressBalanceRequest{})
+			return err
+		},
+		"BlockInfoByHash": func() error {
+			_, _, err := m.BlockInfoByHash(ctx, &ffcapi.BlockInfoByHashRequest{})
+			return err
+		},
+		"BlockInfoByNumber": func() error {
+			_, _, err := m.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{})
+			return err
+		},
+		"GasEstimate": func() error {
+			_, _, err := m.GasEstimate(ctx, &ffcapi.TransactionInput{})
+			return err
+		},
+		"QueryInvoke": func() error {
+			_, _, err := m.QueryInvoke(ctx, &ffcapi.QueryInvokeRequest{})
+			return err
+		},
+		"TransactionReceipt": func() error {
+			_, _, err := m.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{})
+			return err
+		},
+		"TransactionReceipts": func() error {
+			_, _, err := m.TransactionReceipts(ctx, &ffcapi.TransactionReceiptsRequest{})
+			return err
+		},
+		"TransactionByHash": func() error {
+			_, _, err := m.TransactionByHash(ctx, &ffcapi.TransactionByHashRequest{})
+			return err
+		},
+		"TransactionCancel": func() error {
+			_, _, err := m.TransactionCancel(ctx, &ffcapi.TransactionCancelRequest{})
+			return err
+		},
+		"TransactionSimulate": func() error {
+			_, _, err := m.TransactionSimulate(ctx, &ffcapi.TransactionSimulateRequest{})
+			return err
+		},
+		"TransactionSign": func() error {
+			_, _, err := m.TransactionSign(ctx, &ffcapi.TransactionSignRequest{})
+			return err
+		},
+		"DeployContractPrepare": func() error {
+			_, _, err := m.DeployContractPrepare(ctx, &ffcapi.ContractDeployPrepareRequest{})
+			return err
+		},
+		"EventListenerVerifyOptions": func() error {
+			_, _, err := m.EventListenerVerifyOptions(ctx, &ffcapi.EventListenerVerifyOptionsRequest{})
+			return err
+		},
+		"NewBlockListener": func() error {
+			_, _, err := m.NewBlockListener(ctx, &ffcapi.NewBlockListenerRequest{})
+			return err
+		},
+	}
+	for method, call := range calls {
+		mca1.On(method, mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(ffcapi.ErrorReasonDownstreamDown), fmt.Errorf("pop")).Once()
+		mca2.On(method, mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), nil).Once()
+		assert.NoError(t, call(), method)
+		m.checkHealth(ctx)
+	}
+	mca1.AssertExpectations(t)
+	mca2.AssertExpectations(t)
+}
+
+func TestEventStreamNewCheckpointStruct(t *testing.T) {
+	sims := newTestSimulators(t, 2)
+	m := newTestMultiplexer(t, nil, sims[0], sims[1])
+	assert.Equal(t, sims[0].EventStreamNewCheckpointStruct(), m.EventStreamNewCheckpointStruct())
+}
+
+func TestIsReadyNilResponse(t *testing.T) {
+	mca := &ffcapimocks.ExtendedAPI{}
+	mca.On("IsReady", mock.Anything).Return(nil, ffcapi.ErrorReason(""), nil)
+	m := newTestMultiplexer(t, nil, mca)
+
+	res, _, err := m.IsReady(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, res.Ready)
+	assert.JSONEq(t, `{"backends":[{"ready":false,"lagging":false}]}`, res.DownstreamDetails.String())
+}
+
+func TestOptionalMethodsNotSupported(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMultiplexer(t, nil, &ffcapimocks.API{})
+
+	_, reason, err := m.TransactionSign(ctx, &ffcapi.TransactionSignRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionSign", err)
+
+	_, reason, err = m.TransactionReceipts(ctx, &ffcapi.TransactionReceiptsRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionReceipts", err)
+
+	_, reason, err = m.TransactionCancel(ctx, &ffcapi.TransactionCancelRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionCancel", err)
+
+	_, reason, err = m.TransactionSimulate(ctx, &ffcapi.TransactionSimulateRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionSimulate", err)
+
+	_, reason, err = m.TransactionByHash(ctx, &ffcapi.TransactionByHashRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionByHash", err)
+}
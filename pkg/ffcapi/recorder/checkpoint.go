@@ -0,0 +1,60 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// replayCheckpoint holds a recorded checkpoint as raw JSON, when the connector's own checkpoint format
+// is not available. Checkpoints are ordered by the position in the recording where they first appear,
+// and checkpoints that do not appear in the recording are before all those that do.
+type replayCheckpoint struct {
+	r   *replay
+	raw json.RawMessage
+}
+
+func (cp *replayCheckpoint) MarshalJSON() ([]byte, error) {
+	if len(cp.raw) == 0 {
+		return []byte("null"), nil
+	}
+	return cp.raw, nil
+}
+
+func (cp *replayCheckpoint) UnmarshalJSON(b []byte) error {
+	cp.raw = append(json.RawMessage{}, b...)
+	return nil
+}
+
+func (cp *replayCheckpoint) position() int {
+	pos, ok := cp.r.checkpointPos[checkpointKey(cp.raw)]
+	if !ok {
+		return -1
+	}
+	return pos
+}
+
+func (cp *replayCheckpoint) LessThan(b ffcapi.EventListenerCheckpoint) bool {
+	bcp, ok := b.(*replayCheckpoint)
+	if !ok || bytes.Equal(cp.raw, bcp.raw) {
+		return false
+	}
+	return cp.position() < bcp.position()
+}
@@ -0,0 +1,189 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recorder
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// Recorder wraps a connector, and records all interactions with it.
+// Failures to write the recording are logged, and never fail the call to the connector.
+type Recorder interface {
+	ffcapi.ExtendedAPI
+
+	// Close closes the underlying file, if the recorder was created with NewFileRecorder
+	Close() error
+}
+
+type recorder struct {
+	ctx       context.Context
+	connector ffcapi.API
+	closer    io.Closer
+
+	mux sync.Mutex
+	enc *json.Encoder
+	seq int64
+}
+
+// NewRecorder returns a recorder that writes a JSONL record for every interaction with the connector to the supplied writer
+func NewRecorder(ctx context.Context, connector ffcapi.API, w io.Writer) Recorder {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &recorder{
+		ctx:       log.WithLogField(ctx, "role", "ffcapi_recorder"),
+		connector: connector,
+		enc:       enc,
+	}
+}
+
+// NewFileRecorder returns a recorder that appends to the specified file, creating it if it does not exist
+func NewFileRecorder(ctx context.Context, connector ffcapi.API, filename string) (Recorder, error) {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	r := NewRecorder(ctx, connector, f).(*recorder)
+	r.closer = f
+	return r, nil
+}
+
+func (r *recorder) Close() error {
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+	return nil
+}
+
+func (r *recorder) write(rec *Record) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.seq++
+	rec.Seq = r.seq
+	rec.Time = fftypes.Now()
+	if err := r.enc.Encode(rec); err != nil {
+		log.L(r.ctx).Errorf("Failed to write record %d (%s %s): %s", rec.Seq, rec.Type, rec.Method, err)
+	}
+}
+
+func (r *recorder) recordCall(method string, streamID *fftypes.UUID, req, res interface{}, reason ffcapi.ErrorReason, err error) {
+	rec := &Record{
+		Type:     RecordTypeCall,
+		Method:   method,
+		StreamID: streamID,
+		Request:  toJSON(r.ctx, req),
+		Reason:   reason,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	} else {
+		rec.Response = toJSON(r.ctx, res)
+	}
+	r.write(rec)
+}
+
+func (r *recorder) recordEvent(recType RecordType, streamID *fftypes.UUID, event interface{}) {
+	r.write(&Record{
+		Type:     recType,
+		StreamID: streamID,
+		Event:    toJSON(r.ctx, event),
+	})
+}
+
+// EventStreamStart passes the connector its own channels, so that each event can be recorded before
+// it is forwarded to the channels supplied by the caller.
+func (r *recorder) EventStreamStart(ctx context.Context, req *ffcapi.EventStreamStartRequest) (*ffcapi.EventStreamStartResponse, ffcapi.ErrorReason, error) {
+	stop := make(chan struct{})
+	connectorReq := *req
+	if req.EventStream != nil {
+		events := make(chan *ffcapi.ListenerEvent)
+		connectorReq.EventStream = events
+		go r.forwardListenerEvents(req.StreamContext, stop, req.ID, events, req.EventStream)
+	}
+	if req.BlockListener != nil {
+		blocks := make(chan *ffcapi.BlockHashEvent)
+		connectorReq.BlockListener = blocks
+		go r.forwardBlockHashEvents(req.StreamContext, stop, req.ID, blocks, req.BlockListener)
+	}
+	res, reason, err := r.connector.EventStreamStart(ctx, &connectorReq)
+	if err != nil {
+		close(stop)
+	}
+	r.recordCall(MethodEventStreamStart, req.ID, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) NewBlockListener(ctx context.Context, req *ffcapi.NewBlockListenerRequest) (*ffcapi.NewBlockListenerResponse, ffcapi.ErrorReason, error) {
+	stop := make(chan struct{})
+	connectorReq := *req
+	if req.BlockListener != nil {
+		blocks := make(chan *ffcapi.BlockHashEvent)
+		connectorReq.BlockListener = blocks
+		go r.forwardBlockHashEvents(req.ListenerContext, stop, req.ID, blocks, req.BlockListener)
+	}
+	res, reason, err := r.connector.NewBlockListener(ctx, &connectorReq)
+	if err != nil {
+		close(stop)
+	}
+	r.recordCall(MethodNewBlockListener, req.ID, req, res, reason, err)
+	return res, reason, err
+}
+
+// forwardListenerEvents records and forwards events, until the stream context closes or the stream fails to start
+func (r *recorder) forwardListenerEvents(ctx context.Context, stop <-chan struct{}, streamID *fftypes.UUID, in <-chan *ffcapi.ListenerEvent, out chan<- *ffcapi.ListenerEvent) {
+	for {
+		select {
+		case event := <-in:
+			r.recordEvent(RecordTypeListenerEvent, streamID, event)
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		}
+	}
+}
+
+// forwardBlockHashEvents records and forwards new block notifications, until the context closes or the stream/listener fails to start
+func (r *recorder) forwardBlockHashEvents(ctx context.Context, stop <-chan struct{}, streamID *fftypes.UUID, in <-chan *ffcapi.BlockHashEvent, out chan<- *ffcapi.BlockHashEvent) {
+	for {
+		select {
+		case event := <-in:
+			r.recordEvent(RecordTypeBlockHashEvent, streamID, event)
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recorder
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+func (r *recorder) AddressBalance(ctx context.Context, req *ffcapi.AddressBalanceRequest) (*ffcapi.AddressBalanceResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := r.connector.AddressBalance(ctx, req)
+	r.recordCall(MethodAddressBalance, nil, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) BlockInfoByHash(ctx context.Context, req *ffcapi.BlockInfoByHashRequest) (*ffcapi.BlockInfoByHashResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := r.connector.BlockInfoByHash(ctx, req)
+	r.recordCall(MethodBlockInfoByHash, nil, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) BlockInfoByNumber(ctx context.Context, req *ffcapi.BlockInfoByNumberRequest) (*ffcapi.BlockInfoByNumberResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := r.connector.BlockInfoByNumber(ctx, req)
+	r.recordCall(MethodBlockInfoByNumber, nil, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) NextNonceForSigner(ctx context.Context, req *ffcapi.NextNonceForSignerRequest) (*ffcapi.NextNonceForSignerResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := r.connector.NextNonceForSigner(ctx, req)
+	r.recordCall(MethodNextNonceForSigner, nil, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) GasEstimate(ctx context.Context, req *ffcapi.TransactionInput) (*ffcapi.GasEstimateResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := r.connector.GasEstimate(ctx, req)
+	r.recordCall(MethodGasEstimate, nil, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) GasPriceEstimate(ctx context.Context, req *ffcapi.GasPriceEstimateRequest) (*ffcapi.GasPriceEstimateResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := r.connector.GasPriceEstimate(ctx, req)
+	r.recordCall(MethodGasPriceEstimate, nil, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) QueryInvoke(ctx context.Context, req *ffcapi.QueryInvokeRequest) (*ffcapi.QueryInvokeResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := r.connector.QueryInvoke(ctx, req)
+	r.recordCall(MethodQueryInvoke, nil, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) TransactionReceipt(ctx context.Context, req *ffcapi.TransactionReceiptRequest) (*ffcapi.TransactionReceiptResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := r.connector.TransactionReceipt(ctx, req)
+	r.recordCall(MethodTransactionReceipt, nil, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) TransactionReceipts(ctx context.Context, req *ffcapi.TransactionReceiptsRequest) (*ffcapi.TransactionReceiptsResponse, ffcapi.ErrorReason, error) {
+	var res *ffcapi.TransactionReceiptsResponse
+	reason, err := ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionReceipts")
+	if batcher, ok := r.connector.(ffcapi.ReceiptBatcher); ok {
+		res, reason, err = batcher.TransactionReceipts(ctx, req)
+	}
+	r.recordCall(MethodTransactionReceipts, nil, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) TransactionByHash(ctx context.Context, req *ffcapi.TransactionByHashRequest) (*ffcapi.TransactionByHashResponse, ffcapi.ErrorReason, error) {
+	var res *ffcapi.TransactionByHashResponse
+	reason, err := ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionByHash")
+	if finder, ok := r.connector.(ffcapi.TransactionFinder); ok {
+		res, reason, err = finder.TransactionByHash(ctx, req)
+	}
+	r.recordCall(MethodTransactionByHash, nil, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) TransactionCancel(ctx context.Context, req *ffcapi.TransactionCancelRequest) (*ffcapi.TransactionCancelResponse, ffcapi.ErrorReason, error) {
+	var res *ffcapi.TransactionCancelResponse
+	reason, err := ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionCancel")
+	if canceller, ok := r.connector.(ffcapi.TransactionCanceller); ok {
+		res, reason, err = canceller.TransactionCancel(ctx, req)
+	}
+	r.recordCall(MethodTransactionCancel, nil, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) TransactionSimulate(ctx context.Context, req *ffcapi.TransactionSimulateRequest) (*ffcapi.TransactionSimulateResponse, ffcapi.ErrorReason, error) {
+	var res *ffcapi.TransactionSimulateResponse
+	reason, err := ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionSimulate")
+	if simulator, ok := r.connector.(ffcapi.TransactionSimulator); ok {
+		res, reason, err = simulator.TransactionSimulate(ctx, req)
+	}
+	r.recordCall(MethodTransactionSimulate, nil, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) TransactionPrepare(ctx context.Context, req *ffcapi.TransactionPrepareRequest) (*ffcapi.TransactionPrepareResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := r.connector.TransactionPrepare(ctx, req)
+	r.recordCall(MethodTransactionPrepare, nil, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) TransactionSend(ctx context.Context, req *ffcapi.TransactionSendRequest) (*ffcapi.TransactionSendResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := r.connector.TransactionSend(ctx, req)
+	r.recordCall(MethodTransactionSend, nil, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) TransactionSign(ctx context.Context, req *ffcapi.TransactionSignRequest) (*ffcapi.TransactionSignResponse, ffcapi.ErrorReason, error) {
+	var res *ffcapi.TransactionSignResponse
+	reason, err := ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionSign")
+	if signer, ok := r.connector.(ffcapi.TransactionSigner); ok {
+		res, reason, err = signer.TransactionSign(ctx, req)
+	}
+	r.recordCall(MethodTransactionSign, nil, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) DeployContractPrepare(ctx context.Context, req *ffcapi.ContractDeployPrepareRequest) (*ffcapi.TransactionPrepareResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := r.connector.DeployContractPrepare(ctx, req)
+	r.recordCall(MethodDeployContractPrepare, nil, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) EventStreamStopped(ctx context.Context, req *ffcapi.EventStreamStoppedRequest) (*ffcapi.EventStreamStoppedResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := r.connector.EventStreamStopped(ctx, req)
+	r.recordCall(MethodEventStreamStopped, req.ID, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) EventListenerVerifyOptions(ctx context.Context, req *ffcapi.EventListenerVerifyOptionsRequest) (*ffcapi.EventListenerVerifyOptionsResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := r.connector.EventListenerVerifyOptions(ctx, req)
+	r.recordCall(MethodEventListenerVerifyOptions, nil, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) EventListenerAdd(ctx context.Context, req *ffcapi.EventListenerAddRequest) (*ffcapi.EventListenerAddResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := r.connector.EventListenerAdd(ctx, req)
+	r.recordCall(MethodEventListenerAdd, req.StreamID, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) EventListenerRemove(ctx context.Context, req *ffcapi.EventListenerRemoveRequest) (*ffcapi.EventListenerRemoveResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := r.connector.EventListenerRemove(ctx, req)
+	r.recordCall(MethodEventListenerRemove, req.StreamID, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) EventListenerHWM(ctx context.Context, req *ffcapi.EventListenerHWMRequest) (*ffcapi.EventListenerHWMResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := r.connector.EventListenerHWM(ctx, req)
+	r.recordCall(MethodEventListenerHWM, req.StreamID, req, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) IsLive(ctx context.Context) (*ffcapi.LiveResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := r.connector.IsLive(ctx)
+	r.recordCall(MethodIsLive, nil, nil, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) IsReady(ctx context.Context) (*ffcapi.ReadyResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := r.connector.IsReady(ctx)
+	r.recordCall(MethodIsReady, nil, nil, res, reason, err)
+	return res, reason, err
+}
+
+func (r *recorder) EventStreamNewCheckpointStruct() ffcapi.EventListenerCheckpoint {
+	return r.connector.EventStreamNewCheckpointStruct()
+}
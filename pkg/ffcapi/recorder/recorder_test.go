@@ -0,0 +1,512 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recorder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi/simulator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type testSessionIDs struct {
+	streamID        *fftypes.UUID
+	listenerID      *fftypes.UUID
+	extraListenerID *fftypes.UUID
+	blockListenerID *fftypes.UUID
+}
+
+// runTestSession exercises every method of the API, returning the JSON of each result in order.
+// It is run against a recorder over a simulator, and then against a replay of the recording.
+func runTestSession(t *testing.T, api ffcapi.ExtendedAPI, ids *testSessionIDs, mine func()) []string {
+	ctx := context.Background()
+	var results []string
+	add := func(res interface{}, reason ffcapi.ErrorReason, err error) {
+		b, _ := json.Marshal(res)
+		errString := ""
+		if err != nil {
+			errString = err.Error()
+		}
+		results = append(results, fmt.Sprintf("%s reason=%s err=%s", b, reason, errString))
+	}
+	txInput := ffcapi.TransactionInput{
+		TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa", To: "0xcontract"},
+		Method:             fftypes.JSONAnyPtr(`"set"`),
+		Params:             []*fftypes.JSONAny{fftypes.JSONAnyPtr(`"hello"`)},
+	}
+
+	add(api.IsLive(ctx))
+	add(api.IsReady(ctx))
+	add(api.AddressBalance(ctx, &ffcapi.AddressBalanceRequest{Address: "0xaaaa"}))
+	add(api.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{Signer: "0xaaaa"}))
+	add(api.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{}))
+	add(api.GasEstimate(ctx, &txInput))
+	add(api.QueryInvoke(ctx, &ffcapi.QueryInvokeRequest{TransactionInput: txInput}))
+	add(api.DeployContractPrepare(ctx, &ffcapi.ContractDeployPrepareRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa"},
+		Contract:           fftypes.JSONAnyPtr(`"0xbytecode"`),
+	}))
+	// a reverted call is recorded with its error and reason
+	add(api.GasEstimate(ctx, &ffcapi.TransactionInput{
+		TransactionHeaders: txInput.TransactionHeaders,
+		Method:             fftypes.JSONAnyPtr(`"fail"`),
+	}))
+
+	prepared, _, err := api.TransactionPrepare(ctx, &ffcapi.TransactionPrepareRequest{TransactionInput: txInput})
+	assert.NoError(t, err)
+	add(prepared, "", err)
+	headers := ffcapi.TransactionHeaders{From: "0xaaaa", To: "0xcontract", Nonce: fftypes.NewFFBigInt(0), Gas: prepared.Gas}
+	add(api.TransactionSign(ctx, &ffcapi.TransactionSignRequest{
+		TransactionHeaders: headers,
+		GasPrice:           fftypes.JSONAnyPtr(`"100"`),
+		TransactionData:    prepared.TransactionData,
+	}))
+	add(api.TransactionSimulate(ctx, &ffcapi.TransactionSimulateRequest{
+		TransactionHeaders: headers,
+		TransactionData:    prepared.TransactionData,
+	}))
+	sent, _, err := api.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		TransactionHeaders: headers,
+		GasPrice:           fftypes.JSONAnyPtr(`"100"`),
+		TransactionData:    prepared.TransactionData,
+	})
+	assert.NoError(t, err)
+	add(sent, "", err)
+	mine()
+	add(api.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: sent.TransactionHash}))
+	add(api.TransactionReceipts(ctx, &ffcapi.TransactionReceiptsRequest{TransactionHashes: []string{sent.TransactionHash, "0xunknown"}}))
+	add(api.TransactionByHash(ctx, &ffcapi.TransactionByHashRequest{TransactionHash: sent.TransactionHash}))
+	add(api.TransactionCancel(ctx, &ffcapi.TransactionCancelRequest{TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa", Nonce: fftypes.NewFFBigInt(0)}}))
+	add(api.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{BlockNumber: fftypes.NewFFBigInt(1)}))
+	add(api.BlockInfoByHash(ctx, &ffcapi.BlockInfoByHashRequest{BlockHash: "0xunknown"}))
+
+	// Event stream, with a listener catching up from the start of the chain
+	options := ffcapi.EventListenerOptions{
+		FromBlock: ffcapi.FromBlockEarliest,
+		Filters:   []fftypes.JSONAny{`{"event":"set"}`},
+	}
+	add(api.EventListenerVerifyOptions(ctx, &ffcapi.EventListenerVerifyOptionsRequest{EventListenerOptions: options}))
+	streamCtx, stopStream := context.WithCancel(ctx)
+	events := make(chan *ffcapi.ListenerEvent)
+	add(api.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{
+		ID:            ids.streamID,
+		StreamContext: streamCtx,
+		EventStream:   events,
+		BlockListener: make(chan *ffcapi.BlockHashEvent, 10),
+		InitialListeners: []*ffcapi.EventListenerAddRequest{
+			{ListenerID: ids.listenerID, StreamID: ids.streamID, Name: "listener1", EventListenerOptions: options},
+		},
+	}))
+	ev1 := <-events
+	add(ev1, "", nil)
+	add(api.EventListenerAdd(ctx, &ffcapi.EventListenerAddRequest{
+		ListenerID: ids.extraListenerID, StreamID: ids.streamID, Name: "listener2",
+		EventListenerOptions: ffcapi.EventListenerOptions{FromBlock: ffcapi.FromBlockLatest},
+		Checkpoint:           api.EventStreamNewCheckpointStruct(),
+	}))
+	add(api.EventListenerRemove(ctx, &ffcapi.EventListenerRemoveRequest{ListenerID: ids.extraListenerID, StreamID: ids.streamID}))
+	hwm, reason, err := api.EventListenerHWM(ctx, &ffcapi.EventListenerHWMRequest{ListenerID: ids.listenerID, StreamID: ids.streamID})
+	add(hwm, reason, err)
+	assert.True(t, ev1.Checkpoint.LessThan(hwm.Checkpoint))
+	assert.False(t, hwm.Checkpoint.LessThan(ev1.Checkpoint))
+
+	// Standalone block listener
+	blockCtx, stopBlocks := context.WithCancel(ctx)
+	blocks := make(chan *ffcapi.BlockHashEvent)
+	add(api.NewBlockListener(ctx, &ffcapi.NewBlockListenerRequest{
+		ID:              ids.blockListenerID,
+		ListenerContext: blockCtx,
+		BlockListener:   blocks,
+	}))
+	mine()
+	add(<-blocks, "", nil)
+	stopBlocks()
+
+	stopStream()
+	add(api.EventStreamStopped(ctx, &ffcapi.EventStreamStoppedRequest{ID: ids.streamID}))
+	return results
+}
+
+func newTestSessionIDs() *testSessionIDs {
+	return &testSessionIDs{
+		streamID:        fftypes.NewUUID(),
+		listenerID:      fftypes.NewUUID(),
+		extraListenerID: fftypes.NewUUID(),
+		blockListenerID: fftypes.NewUUID(),
+	}
+}
+
+func recordTestSession(t *testing.T, ids *testSessionIDs) (simulator.Simulator, []string, *bytes.Buffer) {
+	sim := simulator.NewSimulator(context.Background(), nil)
+	t.Cleanup(sim.Close)
+	sim.SetRevert("fail", "pop")
+
+	var buff bytes.Buffer
+	r := NewRecorder(context.Background(), sim, &buff)
+	recorded := runTestSession(t, r, ids, func() { sim.MineBlock() })
+	assert.NoError(t, r.Close())
+	return sim, recorded, &buff
+}
+
+func TestRecordAndReplaySession(t *testing.T) {
+	ids := newTestSessionIDs()
+	sim, recorded, buff := recordTestSession(t, ids)
+
+	replay, err := NewReplay(context.Background(), buff, &ReplayOptions{
+		NewCheckpoint: sim.EventStreamNewCheckpointStruct,
+	})
+	assert.NoError(t, err)
+	assert.Greater(t, replay.Remaining(), 0)
+
+	replayed := runTestSession(t, replay, ids, func() {})
+	assert.Equal(t, recorded, replayed)
+	assert.Zero(t, replay.Remaining())
+
+	// The reverted call is replayed with the recorded reason
+	assert.Contains(t, replayed[8], "reason="+string(ffcapi.ErrorReasonTransactionReverted))
+	assert.Contains(t, replayed[8], "pop")
+
+	// Everything has been replayed
+	_, _, err = replay.IsLive(context.Background())
+	assert.Regexp(t, "FF21107.*IsLive", err)
+}
+
+func TestReplayDefaultCheckpoints(t *testing.T) {
+	// Checkpoints are compared on their position in the recording, and the stream IDs
+	// can differ from those in the recording
+	_, recorded, buff := recordTestSession(t, newTestSessionIDs())
+
+	replay, err := NewReplay(context.Background(), buff, nil)
+	assert.NoError(t, err)
+	replayed := runTestSession(t, replay, newTestSessionIDs(), func() {})
+	assert.Equal(t, len(recorded), len(replayed))
+	assert.Zero(t, replay.Remaining())
+
+	cp1 := replay.EventStreamNewCheckpointStruct()
+	err = json.Unmarshal([]byte(`{"block": 1, "transactionIndex": 0, "logIndex": 0}`), &cp1)
+	assert.NoError(t, err)
+	unknown := replay.EventStreamNewCheckpointStruct()
+	err = json.Unmarshal([]byte(`{"block":99,"transactionIndex":0,"logIndex":0}`), &unknown)
+	assert.NoError(t, err)
+	assert.True(t, unknown.LessThan(cp1))
+	assert.False(t, cp1.LessThan(unknown))
+	assert.False(t, cp1.LessThan(cp1))
+	assert.False(t, cp1.LessThan(&simulator.Checkpoint{}))
+
+	b, err := json.Marshal(cp1)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"block":1,"transactionIndex":0,"logIndex":0}`, string(b))
+	b, err = json.Marshal(replay.EventStreamNewCheckpointStruct())
+	assert.NoError(t, err)
+	assert.Equal(t, "null", string(b))
+}
+
+func TestFileRecorderAndReplay(t *testing.T) {
+	ctx := context.Background()
+	filename := path.Join(t.TempDir(), "recording.jsonl")
+	mca := &ffcapimocks.API{}
+	mca.On("IsLive", mock.Anything).Return(&ffcapi.LiveResponse{Up: true}, ffcapi.ErrorReason(""), nil)
+
+	r, err := NewFileRecorder(ctx, mca, filename)
+	assert.NoError(t, err)
+	_, _, err = r.IsLive(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+
+	replay, err := NewFileReplay(ctx, filename, nil)
+	assert.NoError(t, err)
+	res, _, err := replay.IsLive(ctx)
+	assert.NoError(t, err)
+	assert.True(t, res.Up)
+
+	_, err = NewFileRecorder(ctx, mca, path.Join(filename, "not", "a", "dir"))
+	assert.Error(t, err)
+	_, err = NewFileReplay(ctx, path.Join(t.TempDir(), "missing.jsonl"), nil)
+	assert.Error(t, err)
+}
+
+type errorWriter struct{}
+
+func (errorWriter) Write([]byte) (int, error) { return 0, fmt.Errorf("pop") }
+
+func TestRecorderWriteFailure(t *testing.T) {
+	mca := &ffcapimocks.API{}
+	mca.On("IsReady", mock.Anything).Return(&ffcapi.ReadyResponse{Ready: true}, ffcapi.ErrorReason(""), nil)
+	mca.On("EventStreamNewCheckpointStruct").Return(&simulator.Checkpoint{})
+
+	r := NewRecorder(context.Background(), mca, errorWriter{})
+	res, _, err := r.IsReady(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, res.Ready)
+	assert.Equal(t, &simulator.Checkpoint{}, r.EventStreamNewCheckpointStruct())
+}
+
+func TestRecorderSerializeFailure(t *testing.T) {
+	assert.Nil(t, toJSON(context.Background(), map[bool]bool{true: true}))
+}
+
+func TestRecorderStartFailures(t *testing.T) {
+	ctx := context.Background()
+	mca := &ffcapimocks.API{}
+	mca.On("EventStreamStart", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(ffcapi.ErrorReasonDownstreamDown), fmt.Errorf("pop"))
+	mca.On("NewBlockListener", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(ffcapi.ErrorReasonDownstreamDown), fmt.Errorf("pop"))
+
+	var buff bytes.Buffer
+	r := NewRecorder(ctx, mca, &buff)
+	_, reason, err := r.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{
+		ID:            fftypes.NewUUID(),
+		StreamContext: ctx,
+		EventStream:   make(chan *ffcapi.ListenerEvent),
+		BlockListener: make(chan *ffcapi.BlockHashEvent),
+	})
+	assert.Regexp(t, "pop", err)
+	assert.Equal(t, ffcapi.ErrorReason(ffcapi.ErrorReasonDownstreamDown), reason)
+	_, _, err = r.NewBlockListener(ctx, &ffcapi.NewBlockListenerRequest{
+		ID:              fftypes.NewUUID(),
+		ListenerContext: ctx,
+		BlockListener:   make(chan *ffcapi.BlockHashEvent),
+	})
+	assert.Regexp(t, "pop", err)
+
+	// Failures replay with the same error and reason
+	replay, err := NewReplay(ctx, &buff, nil)
+	assert.NoError(t, err)
+	_, reason, err = replay.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{ID: fftypes.NewUUID(), StreamContext: ctx})
+	assert.Equal(t, "pop", err.Error())
+	assert.Equal(t, ffcapi.ErrorReason(ffcapi.ErrorReasonDownstreamDown), reason)
+	_, _, err = replay.NewBlockListener(ctx, &ffcapi.NewBlockListenerRequest{ID: fftypes.NewUUID(), ListenerContext: ctx})
+	assert.Equal(t, "pop", err.Error())
+}
+
+func TestRecorderStopsForwardingOnContextClose(t *testing.T) {
+	ctx := context.Background()
+	var connectorEvents chan<- *ffcapi.ListenerEvent
+	var connectorBlocks chan<- *ffcapi.BlockHashEvent
+	var listenerBlocks chan<- *ffcapi.BlockHashEvent
+	mca := &ffcapimocks.API{}
+	mca.On("EventStreamStart", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil).Run(func(args mock.Arguments) {
+		req := args[1].(*ffcapi.EventStreamStartRequest)
+		connectorEvents = req.EventStream
+		connectorBlocks = req.BlockListener
+	})
+	mca.On("NewBlockListener", mock.Anything, mock.Anything).Return(&ffcapi.NewBlockListenerResponse{}, ffcapi.ErrorReason(""), nil).Run(func(args mock.Arguments) {
+		listenerBlocks = args[1].(*ffcapi.NewBlockListenerRequest).BlockListener
+	})
+
+	r := NewRecorder(ctx, mca, &bytes.Buffer{})
+	streamCtx, stopStream := context.WithCancel(ctx)
+	_, _, err := r.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{
+		ID:            fftypes.NewUUID(),
+		StreamContext: streamCtx,
+		EventStream:   make(chan *ffcapi.ListenerEvent),
+		BlockListener: make(chan *ffcapi.BlockHashEvent),
+	})
+	assert.NoError(t, err)
+	listenerCtx, stopListener := context.WithCancel(ctx)
+	_, _, err = r.NewBlockListener(ctx, &ffcapi.NewBlockListenerRequest{
+		ID:              fftypes.NewUUID(),
+		ListenerContext: listenerCtx,
+		BlockListener:   make(chan *ffcapi.BlockHashEvent),
+	})
+	assert.NoError(t, err)
+
+	// Nobody consumes the events, so the forwarders block until the contexts close
+	connectorEvents <- &ffcapi.ListenerEvent{}
+	connectorBlocks <- &ffcapi.BlockHashEvent{}
+	listenerBlocks <- &ffcapi.BlockHashEvent{}
+	stopStream()
+	stopListener()
+}
+
+func TestReplayMatchesIdenticalRequests(t *testing.T) {
+	ctx := context.Background()
+	recording := `{"seq":1,"type":"call","method":"NextNonceForSigner","request":{"signer":"0xaaaa"},"response":{"nonce":"1"}}
+{"seq":2,"type":"call","method":"NextNonceForSigner","request":{"signer":"0xbbbb"},"response":{"nonce":"2"}}
+
+{"seq":3,"type":"call","method":"NextNonceForSigner","request":{"signer":"0xaaaa"},"response":null}
+{"seq":4,"type":"blockHashEvent","event":{"blockHashes":["0x1"]}}
+`
+	replay, err := NewReplay(ctx, bytes.NewBufferString(recording), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, replay.Remaining())
+
+	// identical request, out of order
+	res, _, err := replay.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{Signer: "0xbbbb"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), res.Nonce.Int64())
+
+	// no identical request, so the first that has not been replayed
+	res, _, err = replay.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{Signer: "0xcccc"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), res.Nonce.Int64())
+
+	res, _, err = replay.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{Signer: "0xaaaa"})
+	assert.NoError(t, err)
+	assert.Nil(t, res)
+
+	_, _, err = replay.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{Signer: "0xaaaa"})
+	assert.Regexp(t, "FF21107", err)
+	assert.Zero(t, replay.Remaining())
+}
+
+func TestReplayInvalidResponse(t *testing.T) {
+	ctx := context.Background()
+	recording := `{"seq":1,"type":"call","method":"NextNonceForSigner","response":"wrong"}
+{"seq":2,"type":"call","method":"EventListenerHWM","response":"wrong"}
+`
+	replay, err := NewReplay(ctx, bytes.NewBufferString(recording), nil)
+	assert.NoError(t, err)
+	_, _, err = replay.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{})
+	assert.Regexp(t, "FF21108.*NextNonceForSigner.*line 1", err)
+	res, _, err := replay.EventListenerHWM(ctx, &ffcapi.EventListenerHWMRequest{})
+	assert.Regexp(t, "FF21108.*EventListenerHWM.*line 2", err)
+	assert.Nil(t, res)
+}
+
+type errorReader struct{}
+
+func (errorReader) Read([]byte) (int, error) { return 0, fmt.Errorf("pop") }
+
+func TestNewReplayErrors(t *testing.T) {
+	ctx := context.Background()
+	_, err := NewReplay(ctx, bytes.NewBufferString("\n!json"), nil)
+	assert.Regexp(t, "FF21106.*line 2", err)
+	_, err = NewReplay(ctx, bytes.NewBufferString(`{"type":"unknown"}`), nil)
+	assert.Regexp(t, "FF21106.*FF21109", err)
+	_, err = NewReplay(ctx, bytes.NewBufferString(`{"type":"listenerEvent","event":"wrong"}`), nil)
+	assert.Regexp(t, "FF21106", err)
+	_, err = NewReplay(ctx, bytes.NewBufferString(`{"type":"blockHashEvent","event":"wrong"}`), nil)
+	assert.Regexp(t, "FF21106", err)
+	_, err = NewReplay(ctx, errorReader{}, nil)
+	assert.Regexp(t, "pop", err)
+}
+
+func TestReplayHoldsEventsUntilListenerAdded(t *testing.T) {
+	ctx := context.Background()
+	streamID := fftypes.NewUUID()
+	recording := fmt.Sprintf(`{"seq":1,"type":"call","method":"EventStreamStart","streamId":"%[1]s","response":{}}
+{"seq":2,"type":"blockHashEvent","streamId":"%[1]s","event":{"blockHashes":["0x1"]}}
+{"seq":3,"type":"call","method":"EventListenerAdd","streamId":"%[1]s","response":{}}
+{"seq":4,"type":"listenerEvent","streamId":"%[1]s","event":{"checkpoint":{"block":1},"event":{"id":{"blockHash":"0x1"}}}}
+{"seq":5,"type":"listenerEvent","streamId":"%[1]s","event":{"checkpoint":null,"event":{"id":{"blockHash":"0x1"}},"removed":true}}
+`, streamID)
+	replay, err := NewReplay(ctx, bytes.NewBufferString(recording), nil)
+	assert.NoError(t, err)
+
+	events := make(chan *ffcapi.ListenerEvent, 10)
+	_, _, err = replay.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{
+		ID:            fftypes.NewUUID(),
+		StreamContext: ctx,
+		EventStream:   events,
+		// block notifications are dropped, as the stream has no block listener
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	_, _, err = replay.EventListenerAdd(ctx, &ffcapi.EventListenerAddRequest{StreamID: streamID})
+	assert.NoError(t, err)
+	ev := <-events
+	assert.Equal(t, "0x1", ev.Event.ID.BlockHash)
+	assert.NotNil(t, ev.Checkpoint)
+	ev = <-events
+	assert.True(t, ev.Removed)
+	assert.Nil(t, ev.Checkpoint)
+}
+
+func TestReplayStopsDeliveryOnContextClose(t *testing.T) {
+	ctx := context.Background()
+	streamID := fftypes.NewUUID()
+	recording := fmt.Sprintf(`{"seq":1,"type":"call","method":"EventStreamStart","streamId":"%[1]s","response":{}}
+{"seq":2,"type":"blockHashEvent","streamId":"%[1]s","event":{"blockHashes":["0x1"]}}
+{"seq":3,"type":"listenerEvent","streamId":"%[1]s","event":{"checkpoint":{"block":1},"event":{"id":{"blockHash":"0x1"}}}}
+{"seq":4,"type":"call","method":"EventListenerAdd","streamId":"%[1]s","response":{}}
+{"seq":5,"type":"listenerEvent","streamId":"%[1]s","event":{"checkpoint":{"block":2},"event":{"id":{"blockHash":"0x2"}}}}
+{"seq":6,"type":"call","method":"EventStreamStart","streamId":"%[1]s","response":{}}
+`, streamID)
+	replay, err := NewReplay(ctx, bytes.NewBufferString(recording), nil)
+	assert.NoError(t, err)
+
+	// Blocked sending to the channels
+	for i := 0; i < 2; i++ {
+		streamCtx, stopStream := context.WithCancel(ctx)
+		blocks := make(chan *ffcapi.BlockHashEvent)
+		events := make(chan *ffcapi.ListenerEvent)
+		_, _, err = replay.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{
+			StreamContext: streamCtx,
+			EventStream:   events,
+			BlockListener: blocks,
+		})
+		assert.NoError(t, err)
+		if i == 0 {
+			<-blocks
+			<-events
+		}
+		// second time round, blocked waiting for the EventListenerAdd
+		stopStream()
+	}
+}
+
+func TestRecordAndReplayOptionalMethodsNotSupported(t *testing.T) {
+	ctx := context.Background()
+	buff := new(bytes.Buffer)
+	r := NewRecorder(ctx, &ffcapimocks.API{}, buff)
+
+	_, reason, err := r.TransactionSign(ctx, &ffcapi.TransactionSignRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionSign", err)
+
+	_, reason, err = r.TransactionReceipts(ctx, &ffcapi.TransactionReceiptsRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionReceipts", err)
+	_, reason, err = r.TransactionCancel(ctx, &ffcapi.TransactionCancelRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionCancel", err)
+	_, reason, err = r.TransactionSimulate(ctx, &ffcapi.TransactionSimulateRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionSimulate", err)
+	_, reason, err = r.TransactionByHash(ctx, &ffcapi.TransactionByHashRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionByHash", err)
+
+	// The rejection is recorded, so it is replayed
+	rp, err := NewReplay(ctx, buff, nil)
+	assert.NoError(t, err)
+	_, reason, err = rp.TransactionSign(ctx, &ffcapi.TransactionSignRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionSign", err)
+	_, reason, err = rp.TransactionReceipts(ctx, &ffcapi.TransactionReceiptsRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionReceipts", err)
+	_, reason, err = rp.TransactionCancel(ctx, &ffcapi.TransactionCancelRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionCancel", err)
+	_, reason, err = rp.TransactionSimulate(ctx, &ffcapi.TransactionSimulateRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionSimulate", err)
+	_, reason, err = rp.TransactionByHash(ctx, &ffcapi.TransactionByHashRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionByHash", err)
+}
@@ -0,0 +1,119 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package recorder provides an ffcapi.API wrapper that records every interaction with a connector
+// to a JSONL file, and a replay connector that serves a recorded session back deterministically.
+//
+// Each line of a recording is a Record. Calls are recorded once they complete, with the request,
+// response, ErrorReason and error. Each ListenerEvent and BlockHashEvent delivered on the channels
+// of EventStreamStart and NewBlockListener is recorded as it passes to the transaction manager,
+// against the ID of the stream or block listener it was delivered on.
+//
+// Records are numbered with a sequence that gives the total order in which they were written.
+package recorder
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+type RecordType string
+
+const (
+	// RecordTypeCall is a completed call to a method of the connector
+	RecordTypeCall RecordType = "call"
+	// RecordTypeListenerEvent is an event delivered on an event stream
+	RecordTypeListenerEvent RecordType = "listenerEvent"
+	// RecordTypeBlockHashEvent is a new block notification delivered on an event stream or block listener
+	RecordTypeBlockHashEvent RecordType = "blockHashEvent"
+)
+
+// Method names, as recorded for each call
+const (
+	MethodAddressBalance             = "AddressBalance"
+	MethodBlockInfoByHash            = "BlockInfoByHash"
+	MethodBlockInfoByNumber          = "BlockInfoByNumber"
+	MethodNextNonceForSigner         = "NextNonceForSigner"
+	MethodGasEstimate                = "GasEstimate"
+	MethodGasPriceEstimate           = "GasPriceEstimate"
+	MethodQueryInvoke                = "QueryInvoke"
+	MethodTransactionReceipt         = "TransactionReceipt"
+	MethodTransactionReceipts        = "TransactionReceipts"
+	MethodTransactionByHash          = "TransactionByHash"
+	MethodTransactionCancel          = "TransactionCancel"
+	MethodTransactionSimulate        = "TransactionSimulate"
+	MethodTransactionPrepare         = "TransactionPrepare"
+	MethodTransactionSend            = "TransactionSend"
+	MethodTransactionSign            = "TransactionSign"
+	MethodDeployContractPrepare      = "DeployContractPrepare"
+	MethodEventStreamStart           = "EventStreamStart"
+	MethodEventStreamStopped         = "EventStreamStopped"
+	MethodEventListenerVerifyOptions = "EventListenerVerifyOptions"
+	MethodEventListenerAdd           = "EventListenerAdd"
+	MethodEventListenerRemove        = "EventListenerRemove"
+	MethodEventListenerHWM           = "EventListenerHWM"
+	MethodNewBlockListener           = "NewBlockListener"
+	MethodIsLive                     = "IsLive"
+	MethodIsReady                    = "IsReady"
+)
+
+// Record is a single line in a recording
+type Record struct {
+	Seq      int64              `json:"seq"`
+	Time     *fftypes.FFTime    `json:"time"`
+	Type     RecordType         `json:"type"`
+	Method   string             `json:"method,omitempty"`
+	StreamID *fftypes.UUID      `json:"streamId,omitempty"` // the event stream or block listener, for stream related calls and all events
+	Request  json.RawMessage    `json:"request,omitempty"`
+	Response json.RawMessage    `json:"response,omitempty"`
+	Reason   ffcapi.ErrorReason `json:"reason,omitempty"`
+	Error    string             `json:"error,omitempty"`
+	Event    json.RawMessage    `json:"event,omitempty"` // the ListenerEvent or BlockHashEvent
+}
+
+// eventStreamStartRequest is the recorded form of ffcapi.EventStreamStartRequest, without the context and channels
+type eventStreamStartRequest struct {
+	ID               *fftypes.UUID
+	InitialListeners []*ffcapi.EventListenerAddRequest
+}
+
+// newBlockListenerRequest is the recorded form of ffcapi.NewBlockListenerRequest, without the context and channel
+type newBlockListenerRequest struct {
+	ID *fftypes.UUID
+}
+
+// toJSON serializes a request or response for the recording. Requests are serialized the same way
+// for recording and replay, so that replay can match a call to the recorded call with the same request.
+func toJSON(ctx context.Context, v interface{}) json.RawMessage {
+	switch vt := v.(type) {
+	case nil:
+		return nil
+	case *ffcapi.EventStreamStartRequest:
+		v = &eventStreamStartRequest{ID: vt.ID, InitialListeners: vt.InitialListeners}
+	case *ffcapi.NewBlockListenerRequest:
+		v = &newBlockListenerRequest{ID: vt.ID}
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.L(ctx).Errorf("Failed to serialize %T for recording: %s", v, err)
+		return nil
+	}
+	return b
+}
@@ -0,0 +1,333 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// Replay is a connector that serves a recorded session.
+//
+// Each call is answered with the first recorded call of the same method, with an identical request,
+// that has not already been replayed. If there is no identical request, the first recorded call of
+// that method that has not been replayed is used. So a session replays exactly when the calls are
+// made with the same requests, and in the same order per method, as when it was recorded.
+//
+// When an event stream or block listener is started, the events recorded against the stream or
+// listener of the replayed EventStreamStart or NewBlockListener call are delivered in the recorded
+// order. Each event is held back until all the EventStreamStart, EventListenerAdd and EventListenerRemove
+// calls for that stream that were recorded before it have been replayed.
+type Replay interface {
+	ffcapi.ExtendedAPI
+
+	// Remaining returns the number of recorded calls that have not yet been replayed
+	Remaining() int
+}
+
+// ReplayOptions configures the behavior of the replay connector
+type ReplayOptions struct {
+	// NewCheckpoint returns an empty checkpoint in the format of the connector that was recorded. If not set,
+	// checkpoints are held as raw JSON, and ordered by the position in the recording where they first appear.
+	NewCheckpoint func() ffcapi.EventListenerCheckpoint
+}
+
+type replay struct {
+	ctx     context.Context
+	options ReplayOptions
+
+	// these are built when the recording is loaded, and are not modified after
+	calls         map[string][]*replayCall  // by method, in recorded order
+	streamCalls   map[string][]*replayCall  // the calls that start a stream and add/remove its listeners, by stream
+	events        map[string][]*replayEvent // by stream or block listener, in recorded order
+	checkpointPos map[string]int
+
+	mux       sync.Mutex
+	changed   chan struct{}
+	remaining int
+}
+
+type replayCall struct {
+	*Record
+	line     int
+	replayed bool
+}
+
+type replayEvent struct {
+	line           int
+	listenerEvent  *ffcapi.ListenerEvent
+	blockHashEvent *ffcapi.BlockHashEvent
+}
+
+// NewReplay loads a recording from the supplied reader
+func NewReplay(ctx context.Context, reader io.Reader, options *ReplayOptions) (Replay, error) {
+	r := &replay{
+		ctx:           log.WithLogField(ctx, "role", "ffcapi_replay"),
+		calls:         make(map[string][]*replayCall),
+		streamCalls:   make(map[string][]*replayCall),
+		events:        make(map[string][]*replayEvent),
+		checkpointPos: make(map[string]int),
+		changed:       make(chan struct{}),
+	}
+	if options != nil {
+		r.options = *options
+	}
+	br := bufio.NewReader(reader)
+	for line := 1; ; line++ {
+		b, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(b)) > 0 {
+			if loadErr := r.load(line, b); loadErr != nil {
+				return nil, i18n.NewError(ctx, tmmsgs.MsgRecordingInvalidRecord, line, loadErr)
+			}
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// NewFileReplay loads a recording from the specified file
+func NewFileReplay(ctx context.Context, filename string, options *ReplayOptions) (Replay, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewReplay(ctx, f, options)
+}
+
+func (r *replay) load(line int, b []byte) error {
+	var rec Record
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return err
+	}
+	switch rec.Type {
+	case RecordTypeCall:
+		call := &replayCall{Record: &rec, line: line}
+		r.calls[rec.Method] = append(r.calls[rec.Method], call)
+		r.remaining++
+		switch rec.Method {
+		case MethodEventStreamStart, MethodEventListenerAdd, MethodEventListenerRemove:
+			key := streamKey(rec.StreamID)
+			r.streamCalls[key] = append(r.streamCalls[key], call)
+		case MethodEventListenerHWM:
+			r.indexCheckpoint(line, rec.Response)
+		}
+	case RecordTypeListenerEvent:
+		event := &ffcapi.ListenerEvent{Checkpoint: r.EventStreamNewCheckpointStruct()}
+		if err := json.Unmarshal(rec.Event, event); err != nil {
+			return err
+		}
+		r.indexCheckpoint(line, rec.Event)
+		r.addEvent(rec.StreamID, &replayEvent{line: line, listenerEvent: event})
+	case RecordTypeBlockHashEvent:
+		event := &ffcapi.BlockHashEvent{}
+		if err := json.Unmarshal(rec.Event, event); err != nil {
+			return err
+		}
+		r.addEvent(rec.StreamID, &replayEvent{line: line, blockHashEvent: event})
+	default:
+		return i18n.NewError(r.ctx, tmmsgs.MsgRecordingUnknownRecordType, rec.Type)
+	}
+	return nil
+}
+
+func (r *replay) addEvent(streamID *fftypes.UUID, event *replayEvent) {
+	key := streamKey(streamID)
+	r.events[key] = append(r.events[key], event)
+}
+
+// indexCheckpoint records the first position in the recording of each checkpoint, which gives
+// the order of checkpoints when the connector's own checkpoint format is not available
+func (r *replay) indexCheckpoint(line int, b json.RawMessage) {
+	var withCheckpoint struct {
+		Checkpoint json.RawMessage `json:"checkpoint"`
+	}
+	_ = json.Unmarshal(b, &withCheckpoint)
+	if key := checkpointKey(withCheckpoint.Checkpoint); key != "" {
+		if _, exists := r.checkpointPos[key]; !exists {
+			r.checkpointPos[key] = line
+		}
+	}
+}
+
+func streamKey(id *fftypes.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
+func checkpointKey(raw json.RawMessage) string {
+	var buff bytes.Buffer
+	if len(raw) == 0 || json.Compact(&buff, raw) != nil || buff.String() == "null" {
+		return ""
+	}
+	return buff.String()
+}
+
+func (r *replay) Remaining() int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.remaining
+}
+
+// next finds the recorded call to replay for a request, and marks it replayed
+func (r *replay) next(ctx context.Context, method string, req json.RawMessage) (*replayCall, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	var first, identical *replayCall
+	for _, call := range r.calls[method] {
+		if !call.replayed {
+			if first == nil {
+				first = call
+			}
+			if bytes.Equal(call.Request, req) {
+				identical = call
+				break
+			}
+		}
+	}
+	call := identical
+	if call == nil {
+		call = first
+	}
+	if call == nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgReplayNoRecordedCall, method)
+	}
+	call.replayed = true
+	r.remaining--
+	close(r.changed)
+	r.changed = make(chan struct{})
+	return call, nil
+}
+
+// replayCall returns the recorded result for a call, restoring the recorded response into res
+func (r *replay) replayCall(ctx context.Context, method string, req interface{}, res interface{}) (*replayCall, ffcapi.ErrorReason, error) {
+	call, err := r.next(ctx, method, toJSON(ctx, req))
+	if err != nil {
+		return nil, "", err
+	}
+	log.L(ctx).Debugf("Replaying %s from line %d", method, call.line)
+	if call.Error != "" {
+		// The recorded message is returned as-is, so the caller sees exactly what it saw when the session was recorded
+		return call, call.Reason, errors.New(call.Error)
+	}
+	if len(call.Response) > 0 {
+		if err := json.Unmarshal(call.Response, res); err != nil {
+			return nil, "", i18n.NewError(ctx, tmmsgs.MsgReplayInvalidResponse, method, call.line, err)
+		}
+	}
+	return call, call.Reason, nil
+}
+
+// waitStreamCalls blocks until all the calls that set up the stream, that were recorded before the specified line, have been replayed
+func (r *replay) waitStreamCalls(ctx context.Context, key string, line int) bool {
+	for {
+		r.mux.Lock()
+		ready := true
+		for _, call := range r.streamCalls[key] {
+			if call.line < line && !call.replayed {
+				ready = false
+				break
+			}
+		}
+		changed := r.changed
+		r.mux.Unlock()
+		if ready {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func (r *replay) deliverEvents(ctx context.Context, streamID *fftypes.UUID, events chan<- *ffcapi.ListenerEvent, blocks chan<- *ffcapi.BlockHashEvent) {
+	key := streamKey(streamID)
+	for _, event := range r.events[key] {
+		if !r.waitStreamCalls(ctx, key, event.line) {
+			return
+		}
+		switch {
+		case event.listenerEvent != nil && events != nil:
+			select {
+			case events <- event.listenerEvent:
+			case <-ctx.Done():
+				return
+			}
+		case event.blockHashEvent != nil && blocks != nil:
+			select {
+			case blocks <- event.blockHashEvent:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+	log.L(r.ctx).Debugf("All recorded events for '%s' have been replayed", key)
+}
+
+func (r *replay) EventStreamStart(ctx context.Context, req *ffcapi.EventStreamStartRequest) (res *ffcapi.EventStreamStartResponse, reason ffcapi.ErrorReason, err error) {
+	var call *replayCall
+	call, reason, err = r.replayCall(ctx, MethodEventStreamStart, req, &res)
+	if err == nil {
+		go r.deliverEvents(req.StreamContext, call.StreamID, req.EventStream, req.BlockListener)
+	}
+	return res, reason, err
+}
+
+func (r *replay) NewBlockListener(ctx context.Context, req *ffcapi.NewBlockListenerRequest) (res *ffcapi.NewBlockListenerResponse, reason ffcapi.ErrorReason, err error) {
+	var call *replayCall
+	call, reason, err = r.replayCall(ctx, MethodNewBlockListener, req, &res)
+	if err == nil {
+		go r.deliverEvents(req.ListenerContext, call.StreamID, nil, req.BlockListener)
+	}
+	return res, reason, err
+}
+
+func (r *replay) EventListenerHWM(ctx context.Context, req *ffcapi.EventListenerHWMRequest) (*ffcapi.EventListenerHWMResponse, ffcapi.ErrorReason, error) {
+	res := &ffcapi.EventListenerHWMResponse{Checkpoint: r.EventStreamNewCheckpointStruct()}
+	_, reason, err := r.replayCall(ctx, MethodEventListenerHWM, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return res, reason, nil
+}
+
+func (r *replay) EventStreamNewCheckpointStruct() ffcapi.EventListenerCheckpoint {
+	if r.options.NewCheckpoint != nil {
+		return r.options.NewCheckpoint()
+	}
+	return &replayCheckpoint{r: r}
+}
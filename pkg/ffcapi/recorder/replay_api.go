@@ -0,0 +1,133 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recorder
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+func (r *replay) AddressBalance(ctx context.Context, req *ffcapi.AddressBalanceRequest) (res *ffcapi.AddressBalanceResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodAddressBalance, req, &res)
+	return res, reason, err
+}
+
+func (r *replay) BlockInfoByHash(ctx context.Context, req *ffcapi.BlockInfoByHashRequest) (res *ffcapi.BlockInfoByHashResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodBlockInfoByHash, req, &res)
+	return res, reason, err
+}
+
+func (r *replay) BlockInfoByNumber(ctx context.Context, req *ffcapi.BlockInfoByNumberRequest) (res *ffcapi.BlockInfoByNumberResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodBlockInfoByNumber, req, &res)
+	return res, reason, err
+}
+
+func (r *replay) NextNonceForSigner(ctx context.Context, req *ffcapi.NextNonceForSignerRequest) (res *ffcapi.NextNonceForSignerResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodNextNonceForSigner, req, &res)
+	return res, reason, err
+}
+
+func (r *replay) GasEstimate(ctx context.Context, req *ffcapi.TransactionInput) (res *ffcapi.GasEstimateResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodGasEstimate, req, &res)
+	return res, reason, err
+}
+
+func (r *replay) GasPriceEstimate(ctx context.Context, req *ffcapi.GasPriceEstimateRequest) (res *ffcapi.GasPriceEstimateResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodGasPriceEstimate, req, &res)
+	return res, reason, err
+}
+
+func (r *replay) QueryInvoke(ctx context.Context, req *ffcapi.QueryInvokeRequest) (res *ffcapi.QueryInvokeResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodQueryInvoke, req, &res)
+	return res, reason, err
+}
+
+func (r *replay) TransactionReceipt(ctx context.Context, req *ffcapi.TransactionReceiptRequest) (res *ffcapi.TransactionReceiptResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodTransactionReceipt, req, &res)
+	return res, reason, err
+}
+
+func (r *replay) TransactionReceipts(ctx context.Context, req *ffcapi.TransactionReceiptsRequest) (res *ffcapi.TransactionReceiptsResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodTransactionReceipts, req, &res)
+	return res, reason, err
+}
+
+func (r *replay) TransactionByHash(ctx context.Context, req *ffcapi.TransactionByHashRequest) (res *ffcapi.TransactionByHashResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodTransactionByHash, req, &res)
+	return res, reason, err
+}
+
+func (r *replay) TransactionCancel(ctx context.Context, req *ffcapi.TransactionCancelRequest) (res *ffcapi.TransactionCancelResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodTransactionCancel, req, &res)
+	return res, reason, err
+}
+
+func (r *replay) TransactionSimulate(ctx context.Context, req *ffcapi.TransactionSimulateRequest) (res *ffcapi.TransactionSimulateResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodTransactionSimulate, req, &res)
+	return res, reason, err
+}
+
+func (r *replay) TransactionPrepare(ctx context.Context, req *ffcapi.TransactionPrepareRequest) (res *ffcapi.TransactionPrepareResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodTransactionPrepare, req, &res)
+	return res, reason, err
+}
+
+func (r *replay) TransactionSend(ctx context.Context, req *ffcapi.TransactionSendRequest) (res *ffcapi.TransactionSendResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodTransactionSend, req, &res)
+	return res, reason, err
+}
+
+func (r *replay) TransactionSign(ctx context.Context, req *ffcapi.TransactionSignRequest) (res *ffcapi.TransactionSignResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodTransactionSign, req, &res)
+	return res, reason, err
+}
+
+func (r *replay) DeployContractPrepare(ctx context.Context, req *ffcapi.ContractDeployPrepareRequest) (res *ffcapi.TransactionPrepareResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodDeployContractPrepare, req, &res)
+	return res, reason, err
+}
+
+func (r *replay) EventStreamStopped(ctx context.Context, req *ffcapi.EventStreamStoppedRequest) (res *ffcapi.EventStreamStoppedResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodEventStreamStopped, req, &res)
+	return res, reason, err
+}
+
+func (r *replay) EventListenerVerifyOptions(ctx context.Context, req *ffcapi.EventListenerVerifyOptionsRequest) (res *ffcapi.EventListenerVerifyOptionsResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodEventListenerVerifyOptions, req, &res)
+	return res, reason, err
+}
+
+func (r *replay) EventListenerAdd(ctx context.Context, req *ffcapi.EventListenerAddRequest) (res *ffcapi.EventListenerAddResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodEventListenerAdd, req, &res)
+	return res, reason, err
+}
+
+func (r *replay) EventListenerRemove(ctx context.Context, req *ffcapi.EventListenerRemoveRequest) (res *ffcapi.EventListenerRemoveResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodEventListenerRemove, req, &res)
+	return res, reason, err
+}
+
+func (r *replay) IsLive(ctx context.Context) (res *ffcapi.LiveResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodIsLive, nil, &res)
+	return res, reason, err
+}
+
+func (r *replay) IsReady(ctx context.Context) (res *ffcapi.ReadyResponse, reason ffcapi.ErrorReason, err error) {
+	_, reason, err = r.replayCall(ctx, MethodIsReady, nil, &res)
+	return res, reason, err
+}
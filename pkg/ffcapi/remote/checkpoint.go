@@ -0,0 +1,74 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// remoteCheckpoint holds a checkpoint in the connector's own JSON format, which the client cannot
+// interpret. Comparisons are performed by the server.
+type remoteCheckpoint struct {
+	c   *client
+	raw json.RawMessage
+}
+
+func (c *client) newCheckpoint(raw json.RawMessage) ffcapi.EventListenerCheckpoint {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	return &remoteCheckpoint{c: c, raw: raw}
+}
+
+func checkpointJSON(cp ffcapi.EventListenerCheckpoint) json.RawMessage {
+	if cp == nil {
+		return nil
+	}
+	b, _ := json.Marshal(cp)
+	return b
+}
+
+func (cp *remoteCheckpoint) MarshalJSON() ([]byte, error) {
+	if len(cp.raw) == 0 {
+		return []byte("null"), nil
+	}
+	return cp.raw, nil
+}
+
+func (cp *remoteCheckpoint) UnmarshalJSON(b []byte) error {
+	cp.raw = append(json.RawMessage{}, b...)
+	return nil
+}
+
+func (cp *remoteCheckpoint) LessThan(b ffcapi.EventListenerCheckpoint) bool {
+	bcp := b.(*remoteCheckpoint)
+	if bytes.Equal(cp.raw, bcp.raw) {
+		return false
+	}
+	var res CheckpointLessThanResponse
+	if _, err := cp.c.invoke(cp.c.ctx, OpCheckpointLessThan, &CheckpointLessThanRequest{A: cp.raw, B: bcp.raw}, &res); err != nil {
+		// We cannot return an error, and we must not drop an event as a re-detection if it might be new.
+		// So we err on the side of at-least-once delivery.
+		log.L(cp.c.ctx).Errorf("Failed to compare checkpoints %s and %s: %s", cp.raw, bcp.raw, err)
+		return true
+	}
+	return res.LessThan
+}
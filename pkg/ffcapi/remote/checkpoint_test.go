@@ -0,0 +1,64 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpointJSON(t *testing.T) {
+	c := newTestClient(t, "http://localhost:12345")
+
+	assert.Nil(t, c.newCheckpoint(nil))
+	assert.Nil(t, c.newCheckpoint(json.RawMessage(`null`)))
+	assert.Nil(t, checkpointJSON(nil))
+
+	b, err := json.Marshal(c.EventStreamNewCheckpointStruct())
+	assert.NoError(t, err)
+	assert.Equal(t, "null", string(b))
+
+	cp := c.newCheckpoint(json.RawMessage(`{"block":1}`))
+	b, err = json.Marshal(cp)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"block":1}`, string(b))
+}
+
+func TestCheckpointLessThanEqual(t *testing.T) {
+	c := newTestClient(t, "http://localhost:12345")
+	cp1 := c.newCheckpoint(json.RawMessage(`{"block":1}`))
+	cp2 := c.newCheckpoint(json.RawMessage(`{"block":1}`))
+	assert.False(t, cp1.LessThan(cp2))
+}
+
+func TestCheckpointLessThanFailureAssumesNewer(t *testing.T) {
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"error":"pop"}`))
+	}))
+	defer hs.Close()
+	c := newTestClient(t, hs.URL)
+
+	cp1 := c.newCheckpoint(json.RawMessage(`{"block":2}`))
+	cp2 := c.newCheckpoint(json.RawMessage(`{"block":1}`))
+	assert.True(t, cp1.LessThan(cp2))
+}
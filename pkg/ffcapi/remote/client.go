@@ -0,0 +1,339 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/gorilla/websocket"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffapi"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-common/pkg/retry"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+type client struct {
+	ctx      context.Context
+	rest     *resty.Client
+	wsURL    string
+	wsHeader http.Header
+	wsDialer *websocket.Dialer
+	retry    *retry.Retry
+
+	mux     sync.Mutex
+	streams map[fftypes.UUID]*clientStream
+}
+
+// NewClient returns an ffcapi.ExtendedAPI implementation that invokes a connector running in another process,
+// which exposes the API using the Server from this package. The optional methods return ErrorReasonNotSupported
+// if the remote connector does not implement them.
+// The configuration section must have been initialized with InitConfig.
+func NewClient(ctx context.Context, conf config.Section) (ffcapi.ExtendedAPI, error) {
+	restConf, err := ffresty.GenerateConfig(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	if restConf.URL == "" {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgConfigParamNotSet, conf.Resolve(ffresty.HTTPConfigURL))
+	}
+	c := &client{
+		ctx:  log.WithLogField(ctx, "role", "ffcapi_client"),
+		rest: ffresty.NewWithConfig(ctx, *restConf),
+		wsDialer: &websocket.Dialer{
+			Proxy:            http.ProxyFromEnvironment,
+			HandshakeTimeout: restConf.HTTPConnectionTimeout,
+			TLSClientConfig:  restConf.TLSClientConfig,
+		},
+		wsHeader: http.Header{},
+		retry: &retry.Retry{
+			InitialDelay: conf.GetDuration(WebSocketRetryInitialDelay),
+			MaximumDelay: conf.GetDuration(WebSocketRetryMaxDelay),
+			Factor:       conf.GetFloat64(WebSocketRetryFactor),
+		},
+		streams: make(map[fftypes.UUID]*clientStream),
+	}
+	baseURL := strings.TrimSuffix(restConf.URL, "/")
+	c.wsURL = "ws" + strings.TrimPrefix(baseURL, "http") + PathPrefix + WebSocketPath
+	for k, v := range restConf.HTTPHeaders {
+		if s, ok := v.(string); ok {
+			c.wsHeader.Set(k, s)
+		}
+	}
+	if restConf.AuthUsername != "" {
+		req, _ := http.NewRequest(http.MethodGet, baseURL, nil)
+		req.SetBasicAuth(restConf.AuthUsername, restConf.AuthPassword)
+		c.wsHeader.Set("Authorization", req.Header.Get("Authorization"))
+	}
+	return c, nil
+}
+
+// invoke performs a single request/response operation against the server, mapping errors back
+// to the ErrorReason supplied by the connector
+func (c *client) invoke(ctx context.Context, op string, req, res interface{}) (ffcapi.ErrorReason, error) {
+	requestID, _ := ctx.Value(ffapi.CtxFFRequestIDKey{}).(string)
+	if requestID == "" {
+		requestID = fftypes.ShortID()
+	}
+	var errRes ErrorResponse
+	r := c.rest.R().
+		SetContext(ctx).
+		SetHeader(ffapi.FFRequestIDHeader, requestID).
+		SetResult(res).
+		SetError(&errRes)
+	if req != nil {
+		r.SetBody(req)
+	}
+	httpRes, err := r.Post(PathPrefix + "/" + op)
+	if err != nil {
+		// We could not reach the connector at all
+		return ffcapi.ErrorReasonDownstreamDown, i18n.WrapError(ctx, err, tmmsgs.MsgConnectorError, requestID, ffcapi.ErrorReasonDownstreamDown, err)
+	}
+	if httpRes.IsError() {
+		contentType := httpRes.Header().Get("Content-Type")
+		if !strings.HasPrefix(contentType, "application/json") {
+			return "", i18n.NewError(ctx, tmmsgs.MsgConnectorInvalidContentType, requestID, contentType)
+		}
+		return errRes.Reason, i18n.NewError(ctx, tmmsgs.MsgConnectorError, requestID, errRes.Reason, errRes.Error)
+	}
+	return "", nil
+}
+
+func (c *client) AddressBalance(ctx context.Context, req *ffcapi.AddressBalanceRequest) (*ffcapi.AddressBalanceResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.AddressBalanceResponse
+	reason, err := c.invoke(ctx, OpAddressBalance, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res, "", nil
+}
+
+func (c *client) BlockInfoByHash(ctx context.Context, req *ffcapi.BlockInfoByHashRequest) (*ffcapi.BlockInfoByHashResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.BlockInfoByHashResponse
+	reason, err := c.invoke(ctx, OpBlockInfoByHash, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res, "", nil
+}
+
+func (c *client) BlockInfoByNumber(ctx context.Context, req *ffcapi.BlockInfoByNumberRequest) (*ffcapi.BlockInfoByNumberResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.BlockInfoByNumberResponse
+	reason, err := c.invoke(ctx, OpBlockInfoByNumber, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res, "", nil
+}
+
+func (c *client) NextNonceForSigner(ctx context.Context, req *ffcapi.NextNonceForSignerRequest) (*ffcapi.NextNonceForSignerResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.NextNonceForSignerResponse
+	reason, err := c.invoke(ctx, OpNextNonceForSigner, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res, "", nil
+}
+
+func (c *client) GasEstimate(ctx context.Context, req *ffcapi.TransactionInput) (*ffcapi.GasEstimateResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.GasEstimateResponse
+	reason, err := c.invoke(ctx, OpGasEstimate, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res, "", nil
+}
+
+func (c *client) GasPriceEstimate(ctx context.Context, req *ffcapi.GasPriceEstimateRequest) (*ffcapi.GasPriceEstimateResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.GasPriceEstimateResponse
+	reason, err := c.invoke(ctx, OpGasPriceEstimate, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res, "", nil
+}
+
+func (c *client) QueryInvoke(ctx context.Context, req *ffcapi.QueryInvokeRequest) (*ffcapi.QueryInvokeResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.QueryInvokeResponse
+	reason, err := c.invoke(ctx, OpQueryInvoke, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res, "", nil
+}
+
+func (c *client) TransactionReceipt(ctx context.Context, req *ffcapi.TransactionReceiptRequest) (*ffcapi.TransactionReceiptResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.TransactionReceiptResponse
+	reason, err := c.invoke(ctx, OpTransactionReceipt, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res, "", nil
+}
+
+func (c *client) TransactionReceipts(ctx context.Context, req *ffcapi.TransactionReceiptsRequest) (*ffcapi.TransactionReceiptsResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.TransactionReceiptsResponse
+	reason, err := c.invoke(ctx, OpTransactionReceipts, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res, "", nil
+}
+
+func (c *client) TransactionByHash(ctx context.Context, req *ffcapi.TransactionByHashRequest) (*ffcapi.TransactionByHashResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.TransactionByHashResponse
+	reason, err := c.invoke(ctx, OpTransactionByHash, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res, "", nil
+}
+
+func (c *client) TransactionCancel(ctx context.Context, req *ffcapi.TransactionCancelRequest) (*ffcapi.TransactionCancelResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.TransactionCancelResponse
+	reason, err := c.invoke(ctx, OpTransactionCancel, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res, "", nil
+}
+
+func (c *client) TransactionSimulate(ctx context.Context, req *ffcapi.TransactionSimulateRequest) (*ffcapi.TransactionSimulateResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.TransactionSimulateResponse
+	reason, err := c.invoke(ctx, OpTransactionSimulate, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res, "", nil
+}
+
+func (c *client) TransactionPrepare(ctx context.Context, req *ffcapi.TransactionPrepareRequest) (*ffcapi.TransactionPrepareResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.TransactionPrepareResponse
+	reason, err := c.invoke(ctx, OpTransactionPrepare, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res, "", nil
+}
+
+func (c *client) TransactionSend(ctx context.Context, req *ffcapi.TransactionSendRequest) (*ffcapi.TransactionSendResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.TransactionSendResponse
+	reason, err := c.invoke(ctx, OpTransactionSend, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res, "", nil
+}
+
+func (c *client) TransactionSign(ctx context.Context, req *ffcapi.TransactionSignRequest) (*ffcapi.TransactionSignResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.TransactionSignResponse
+	reason, err := c.invoke(ctx, OpTransactionSign, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res, "", nil
+}
+
+func (c *client) DeployContractPrepare(ctx context.Context, req *ffcapi.ContractDeployPrepareRequest) (*ffcapi.TransactionPrepareResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.TransactionPrepareResponse
+	reason, err := c.invoke(ctx, OpDeployContractPrepare, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res, "", nil
+}
+
+func (c *client) EventListenerVerifyOptions(ctx context.Context, req *ffcapi.EventListenerVerifyOptionsRequest) (*ffcapi.EventListenerVerifyOptionsResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.EventListenerVerifyOptionsResponse
+	reason, err := c.invoke(ctx, OpEventListenerVerifyOptions, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res, "", nil
+}
+
+func (c *client) EventListenerAdd(ctx context.Context, req *ffcapi.EventListenerAddRequest) (*ffcapi.EventListenerAddResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.EventListenerAddResponse
+	reason, err := c.invoke(ctx, OpEventListenerAdd, &EventListenerAddRequest{
+		EventListenerAddRequest: req,
+		Checkpoint:              checkpointJSON(req.Checkpoint),
+	}, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	c.trackListener(req)
+	return &res, "", nil
+}
+
+func (c *client) EventListenerRemove(ctx context.Context, req *ffcapi.EventListenerRemoveRequest) (*ffcapi.EventListenerRemoveResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.EventListenerRemoveResponse
+	reason, err := c.invoke(ctx, OpEventListenerRemove, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	c.untrackListener(req.StreamID, req.ListenerID)
+	return &res, "", nil
+}
+
+func (c *client) EventListenerHWM(ctx context.Context, req *ffcapi.EventListenerHWMRequest) (*ffcapi.EventListenerHWMResponse, ffcapi.ErrorReason, error) {
+	res := EventListenerHWMResponse{EventListenerHWMResponse: &ffcapi.EventListenerHWMResponse{}}
+	reason, err := c.invoke(ctx, OpEventListenerHWM, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	res.EventListenerHWMResponse.Checkpoint = c.newCheckpoint(res.Checkpoint)
+	return res.EventListenerHWMResponse, "", nil
+}
+
+func (c *client) EventStreamStopped(ctx context.Context, req *ffcapi.EventStreamStoppedRequest) (*ffcapi.EventStreamStoppedResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.EventStreamStoppedResponse
+	reason, err := c.invoke(ctx, OpEventStreamStopped, req, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res, "", nil
+}
+
+func (c *client) EventStreamNewCheckpointStruct() ffcapi.EventListenerCheckpoint {
+	return &remoteCheckpoint{c: c}
+}
+
+func (c *client) IsLive(ctx context.Context) (*ffcapi.LiveResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.LiveResponse
+	reason, err := c.invoke(ctx, OpIsLive, nil, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res, "", nil
+}
+
+func (c *client) IsReady(ctx context.Context) (*ffcapi.ReadyResponse, ffcapi.ErrorReason, error) {
+	var res ffcapi.ReadyResponse
+	reason, err := c.invoke(ctx, OpIsReady, nil, &res)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res, "", nil
+}
@@ -0,0 +1,222 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+
+	"github.com/gorilla/websocket"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// clientStream is the client side of a WebSocket session for an event stream or block listener.
+// For event streams we track the listeners, and the checkpoint of the last event delivered for each,
+// so we can restart the stream on the server if the WebSocket reconnects.
+type clientStream struct {
+	c         *client
+	id        *fftypes.UUID
+	startType string
+	ctx       context.Context
+	events    chan<- *ffcapi.ListenerEvent
+	blocks    chan<- *ffcapi.BlockHashEvent
+	listeners map[fftypes.UUID]*ffcapi.EventListenerAddRequest
+}
+
+func (c *client) EventStreamStart(ctx context.Context, req *ffcapi.EventStreamStartRequest) (*ffcapi.EventStreamStartResponse, ffcapi.ErrorReason, error) {
+	cs := &clientStream{
+		c:         c,
+		id:        req.ID,
+		startType: MsgTypeStartEventStream,
+		ctx:       log.WithLogField(req.StreamContext, "eventstream", req.ID.String()),
+		events:    req.EventStream,
+		blocks:    req.BlockListener,
+		listeners: make(map[fftypes.UUID]*ffcapi.EventListenerAddRequest),
+	}
+	for _, l := range req.InitialListeners {
+		lCopy := *l
+		cs.listeners[*l.ListenerID] = &lCopy
+	}
+	conn, reason, err := cs.connect(ctx)
+	if err != nil {
+		return nil, reason, err
+	}
+	c.mux.Lock()
+	c.streams[*req.ID] = cs
+	c.mux.Unlock()
+	go cs.run(conn)
+	return &ffcapi.EventStreamStartResponse{}, "", nil
+}
+
+func (c *client) NewBlockListener(ctx context.Context, req *ffcapi.NewBlockListenerRequest) (*ffcapi.NewBlockListenerResponse, ffcapi.ErrorReason, error) {
+	cs := &clientStream{
+		c:         c,
+		id:        req.ID,
+		startType: MsgTypeStartBlockListener,
+		ctx:       log.WithLogField(req.ListenerContext, "blocklistener", req.ID.String()),
+		blocks:    req.BlockListener,
+	}
+	conn, reason, err := cs.connect(ctx)
+	if err != nil {
+		return nil, reason, err
+	}
+	go cs.run(conn)
+	return &ffcapi.NewBlockListenerResponse{}, "", nil
+}
+
+func (c *client) trackListener(req *ffcapi.EventListenerAddRequest) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if cs := c.streams[*req.StreamID]; cs != nil {
+		lCopy := *req
+		cs.listeners[*req.ListenerID] = &lCopy
+	}
+}
+
+func (c *client) untrackListener(streamID, listenerID *fftypes.UUID) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if cs := c.streams[*streamID]; cs != nil {
+		delete(cs.listeners, *listenerID)
+	}
+}
+
+func (c *client) updateListenerCheckpoint(cs *clientStream, listenerID *fftypes.UUID, cp ffcapi.EventListenerCheckpoint) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if l := cs.listeners[*listenerID]; l != nil {
+		l.Checkpoint = cp
+	}
+}
+
+func (c *client) removeStream(cs *clientStream) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.streams[*cs.id] == cs {
+		delete(c.streams, *cs.id)
+	}
+}
+
+// connect establishes the WebSocket, and waits for the server to confirm the stream/listener has started
+func (cs *clientStream) connect(ctx context.Context) (*websocket.Conn, ffcapi.ErrorReason, error) {
+	conn, _, err := cs.c.wsDialer.DialContext(ctx, cs.c.wsURL, cs.c.wsHeader)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonDownstreamDown, i18n.WrapError(ctx, err, tmmsgs.MsgRemoteFFCAPIWebSocketConnect, cs.c.wsURL)
+	}
+
+	start := &WebSocketMessage{Type: cs.startType, ID: cs.id}
+	cs.c.mux.Lock()
+	for _, l := range cs.listeners {
+		start.InitialListeners = append(start.InitialListeners, &EventListenerAddRequest{
+			EventListenerAddRequest: l,
+			Checkpoint:              checkpointJSON(l.Checkpoint),
+		})
+	}
+	cs.c.mux.Unlock()
+
+	var reply WebSocketMessage
+	err = conn.WriteJSON(start)
+	if err == nil {
+		err = conn.ReadJSON(&reply)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, ffcapi.ErrorReasonDownstreamDown, i18n.WrapError(ctx, err, tmmsgs.MsgRemoteFFCAPIWebSocketClosed, cs.startType, cs.id)
+	}
+	if reply.Type != MsgTypeStarted {
+		_ = conn.Close()
+		return nil, reply.Reason, i18n.NewError(ctx, tmmsgs.MsgConnectorError, cs.id, reply.Reason, reply.Error)
+	}
+	return conn, "", nil
+}
+
+// run delivers events until the context is cancelled, reconnecting the WebSocket as required
+func (cs *clientStream) run(conn *websocket.Conn) {
+	defer cs.c.removeStream(cs)
+	for {
+		cs.readLoop(conn)
+		if cs.ctx.Err() != nil {
+			log.L(cs.ctx).Debugf("WebSocket closed")
+			return
+		}
+		log.L(cs.ctx).Warnf("WebSocket disconnected from connector - reconnecting")
+		err := cs.c.retry.Do(cs.ctx, "reconnect", func(attempt int) (bool, error) {
+			var err error
+			conn, _, err = cs.connect(cs.ctx)
+			return true, err
+		})
+		if err != nil {
+			log.L(cs.ctx).Debugf("Reconnect cancelled: %s", err)
+			return
+		}
+		// We cannot know if we missed any blocks while disconnected
+		if cs.blocks == nil {
+			continue
+		}
+		select {
+		case cs.blocks <- &ffcapi.BlockHashEvent{GapPotential: true}:
+		case <-cs.ctx.Done():
+			_ = conn.Close()
+			return
+		}
+	}
+}
+
+func (cs *clientStream) readLoop(conn *websocket.Conn) {
+	// Closing the connection when the context ends unblocks the read
+	readerDone := make(chan struct{})
+	defer close(readerDone)
+	go func() {
+		select {
+		case <-cs.ctx.Done():
+		case <-readerDone:
+		}
+		_ = conn.Close()
+	}()
+
+	for {
+		var msg WebSocketMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			log.L(cs.ctx).Debugf("WebSocket read failed: %s", err)
+			return
+		}
+		switch {
+		case msg.Type == MsgTypeListenerEvent && msg.ListenerEvent != nil && msg.ListenerEvent.ListenerEvent != nil:
+			lev := msg.ListenerEvent.ListenerEvent
+			lev.Checkpoint = cs.c.newCheckpoint(msg.ListenerEvent.Checkpoint)
+			select {
+			case cs.events <- lev:
+			case <-cs.ctx.Done():
+				return
+			}
+			if !lev.Removed && lev.Checkpoint != nil && lev.Event != nil && lev.Event.ID.ListenerID != nil {
+				cs.c.updateListenerCheckpoint(cs, lev.Event.ID.ListenerID, lev.Checkpoint)
+			}
+		case msg.Type == MsgTypeBlockHashEvent && msg.BlockHashEvent != nil:
+			select {
+			case cs.blocks <- msg.BlockHashEvent:
+			case <-cs.ctx.Done():
+				return
+			}
+		default:
+			log.L(cs.ctx).Warnf("Unexpected WebSocket message type '%s'", msg.Type)
+		}
+	}
+}
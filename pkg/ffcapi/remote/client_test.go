@@ -0,0 +1,553 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffapi"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi/simulator"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClientConfig(url string) config.Section {
+	config.RootConfigReset()
+	conf := config.RootSection("ut_remote")
+	InitConfig(conf)
+	conf.Set(ffresty.HTTPConfigURL, url)
+	conf.Set(WebSocketRetryInitialDelay, "1ms")
+	conf.Set(WebSocketRetryMaxDelay, "10ms")
+	return conf
+}
+
+func newTestClient(t *testing.T, url string) *client {
+	c, err := NewClient(context.Background(), newTestClientConfig(url))
+	assert.NoError(t, err)
+	return c.(*client)
+}
+
+func newTestRemoteSimulator(t *testing.T) (simulator.Simulator, *client) {
+	sim := simulator.NewSimulator(context.Background(), nil)
+	s := NewServer(context.Background(), sim)
+	hs := httptest.NewServer(s)
+	t.Cleanup(func() {
+		hs.Close()
+		s.Close()
+		sim.Close()
+	})
+	return sim, newTestClient(t, hs.URL)
+}
+
+func prepareAndSend(t *testing.T, c ffcapi.API, from string, nonce int64, method string) string {
+	ctx := context.Background()
+	prepared, _, err := c.TransactionPrepare(ctx, &ffcapi.TransactionPrepareRequest{
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: ffcapi.TransactionHeaders{From: from, To: "0xcontract"},
+			Method:             fftypes.JSONAnyPtr(`"` + method + `"`),
+			Params:             []*fftypes.JSONAny{fftypes.JSONAnyPtr(`"hello"`)},
+		},
+	})
+	assert.NoError(t, err)
+	res, _, err := c.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From:  from,
+			To:    "0xcontract",
+			Nonce: fftypes.NewFFBigInt(nonce),
+			Gas:   prepared.Gas,
+		},
+		GasPrice:        fftypes.JSONAnyPtr(`"100"`),
+		TransactionData: prepared.TransactionData,
+	})
+	assert.NoError(t, err)
+	return res.TransactionHash
+}
+
+func TestNewClientMissingURL(t *testing.T) {
+	_, err := NewClient(context.Background(), newTestClientConfig(""))
+	assert.Regexp(t, "FF21018", err)
+}
+
+func TestNewClientBadTLSConfig(t *testing.T) {
+	conf := newTestClientConfig("https://localhost:12345")
+	conf.SubSection("tls").Set("enabled", true)
+	conf.SubSection("tls").Set("caFile", "!!!missing")
+	_, err := NewClient(context.Background(), conf)
+	assert.Error(t, err)
+}
+
+func TestNewClientWebSocketHeaders(t *testing.T) {
+	conf := newTestClientConfig("https://localhost:12345/")
+	conf.Set(ffresty.HTTPConfigHeaders, map[string]interface{}{"x-custom": "value1"})
+	conf.Set(ffresty.HTTPConfigAuthUsername, "user1")
+	conf.Set(ffresty.HTTPConfigAuthPassword, "pass1")
+	api, err := NewClient(context.Background(), conf)
+	assert.NoError(t, err)
+	c := api.(*client)
+	assert.Equal(t, "wss://localhost:12345/ffcapi/ws", c.wsURL)
+	assert.Equal(t, "value1", c.wsHeader.Get("x-custom"))
+	assert.Equal(t, "Basic dXNlcjE6cGFzczE=", c.wsHeader.Get("Authorization"))
+}
+
+func TestClientOperations(t *testing.T) {
+	sim, c := newTestRemoteSimulator(t)
+	ctx := context.WithValue(context.Background(), ffapi.CtxFFRequestIDKey{}, "req1")
+
+	live, _, err := c.IsLive(ctx)
+	assert.NoError(t, err)
+	assert.True(t, live.Up)
+
+	ready, _, err := c.IsReady(ctx)
+	assert.NoError(t, err)
+	assert.True(t, ready.Ready)
+
+	sim.SetBalance("0xaaaa", big.NewInt(12345))
+	bal, _, err := c.AddressBalance(ctx, &ffcapi.AddressBalanceRequest{Address: "0xaaaa"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12345), bal.Balance.Int64())
+
+	signed, _, err := c.TransactionSign(ctx, &ffcapi.TransactionSignRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa", Nonce: fftypes.NewFFBigInt(0)},
+		TransactionData:    "0x7b7d",
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, signed.TransactionHash)
+
+	_, _, err = c.TransactionSimulate(ctx, &ffcapi.TransactionSimulateRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa"},
+		TransactionData:    "0x7b7d",
+	})
+	assert.NoError(t, err)
+
+	hash := prepareAndSend(t, c, "0xaaaa", 0, "set")
+	nonce, _, err := c.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{Signer: "0xaaaa"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), nonce.Nonce.Int64())
+
+	block := sim.MineBlock()
+	receipt, _, err := c.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: hash})
+	assert.NoError(t, err)
+	assert.True(t, receipt.Success)
+	assert.Equal(t, block.BlockHash, receipt.BlockHash)
+
+	receipts, _, err := c.TransactionReceipts(ctx, &ffcapi.TransactionReceiptsRequest{TransactionHashes: []string{hash, "0xunknown"}})
+	assert.NoError(t, err)
+	assert.Len(t, receipts.Receipts, 1)
+	assert.Equal(t, block.BlockHash, receipts.Receipts[hash].BlockHash)
+
+	txInfo, _, err := c.TransactionByHash(ctx, &ffcapi.TransactionByHashRequest{TransactionHash: hash})
+	assert.NoError(t, err)
+	assert.Equal(t, ffcapi.TransactionStateMined, txInfo.State)
+	assert.Equal(t, block.BlockHash, txInfo.BlockHash)
+
+	_, reason, err := c.TransactionCancel(ctx, &ffcapi.TransactionCancelRequest{TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa", Nonce: fftypes.NewFFBigInt(0)}})
+	assert.Regexp(t, "FF21090", err)
+	assert.Equal(t, ffcapi.ErrorReasonNonceTooLow, reason)
+
+	byNumber, _, err := c.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{BlockNumber: fftypes.NewFFBigInt(1)})
+	assert.NoError(t, err)
+	assert.Equal(t, block.BlockHash, byNumber.BlockHash)
+
+	byHash, _, err := c.BlockInfoByHash(ctx, &ffcapi.BlockInfoByHashRequest{BlockHash: block.BlockHash})
+	assert.NoError(t, err)
+	assert.Equal(t, block.BlockNumber.Int64(), byHash.BlockNumber.Int64())
+
+	gas, _, err := c.GasEstimate(ctx, &ffcapi.TransactionInput{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(21000), gas.GasEstimate.Int64())
+
+	gasPrice, _, err := c.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, `"1000000000"`, gasPrice.GasPrice.String())
+
+	query, _, err := c.QueryInvoke(ctx, &ffcapi.QueryInvokeRequest{
+		TransactionInput: ffcapi.TransactionInput{
+			Method: fftypes.JSONAnyPtr(`"get"`),
+			Params: []*fftypes.JSONAny{fftypes.JSONAnyPtr(`"hello"`)},
+		},
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, query.Outputs)
+
+	deploy, _, err := c.DeployContractPrepare(ctx, &ffcapi.ContractDeployPrepareRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa"},
+		Contract:           fftypes.JSONAnyPtr(`"0xbytecode"`),
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, deploy.TransactionData)
+
+	verify, _, err := c.EventListenerVerifyOptions(ctx, &ffcapi.EventListenerVerifyOptionsRequest{
+		EventListenerOptions: ffcapi.EventListenerOptions{
+			Filters: []fftypes.JSONAny{`{"event":"set"}`},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "set", verify.ResolvedSignature)
+}
+
+func TestClientErrorReasons(t *testing.T) {
+	sim, c := newTestRemoteSimulator(t)
+	ctx := context.Background()
+
+	_, reason, err := c.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa"},
+	})
+	assert.Regexp(t, "FF21012", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	_, reason, err = c.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: "0xunknown"})
+	assert.Regexp(t, "FF21012", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+	sim.SetDownstreamDown(true)
+	ready, _, err := c.IsReady(ctx)
+	assert.NoError(t, err)
+	assert.False(t, ready.Ready)
+
+	checks := []func() (ffcapi.ErrorReason, error){
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.AddressBalance(ctx, &ffcapi.AddressBalanceRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.BlockInfoByHash(ctx, &ffcapi.BlockInfoByHashRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.GasEstimate(ctx, &ffcapi.TransactionInput{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.QueryInvoke(ctx, &ffcapi.QueryInvokeRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.TransactionReceipts(ctx, &ffcapi.TransactionReceiptsRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.TransactionByHash(ctx, &ffcapi.TransactionByHashRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.TransactionCancel(ctx, &ffcapi.TransactionCancelRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.TransactionSimulate(ctx, &ffcapi.TransactionSimulateRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.TransactionPrepare(ctx, &ffcapi.TransactionPrepareRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.TransactionSend(ctx, &ffcapi.TransactionSendRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.TransactionSign(ctx, &ffcapi.TransactionSignRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.DeployContractPrepare(ctx, &ffcapi.ContractDeployPrepareRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.EventListenerAdd(ctx, &ffcapi.EventListenerAddRequest{StreamID: fftypes.NewUUID(), ListenerID: fftypes.NewUUID()})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.EventListenerHWM(ctx, &ffcapi.EventListenerHWMRequest{StreamID: fftypes.NewUUID(), ListenerID: fftypes.NewUUID()})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{ID: fftypes.NewUUID(), StreamContext: ctx})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.NewBlockListener(ctx, &ffcapi.NewBlockListenerRequest{ID: fftypes.NewUUID(), ListenerContext: ctx})
+			return r, err
+		},
+	}
+	for _, check := range checks {
+		reason, err := check()
+		assert.Regexp(t, "FF21087", err)
+		assert.Equal(t, ffcapi.ErrorReason(ffcapi.ErrorReasonDownstreamDown), reason)
+	}
+}
+
+func TestClientConnectionFailures(t *testing.T) {
+	hs := httptest.NewServer(http.NotFoundHandler())
+	hs.Close()
+	c := newTestClient(t, hs.URL)
+	ctx := context.Background()
+
+	checks := []func() (ffcapi.ErrorReason, error){
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.IsLive(ctx)
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.IsReady(ctx)
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.EventStreamStopped(ctx, &ffcapi.EventStreamStoppedRequest{ID: fftypes.NewUUID()})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := c.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{ID: fftypes.NewUUID(), StreamContext: ctx})
+			return r, err
+		},
+	}
+	for _, check := range checks {
+		reason, err := check()
+		assert.Error(t, err)
+		assert.Equal(t, ffcapi.ErrorReason(ffcapi.ErrorReasonDownstreamDown), reason)
+	}
+}
+
+func TestClientNonJSONError(t *testing.T) {
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEmpty(t, r.Header.Get(ffapi.FFRequestIDHeader))
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte("bad gateway"))
+	}))
+	defer hs.Close()
+	c := newTestClient(t, hs.URL)
+
+	_, _, err := c.IsLive(context.Background())
+	assert.Regexp(t, "FF21013.*text/plain", err)
+}
+
+func TestClientEventStream(t *testing.T) {
+	sim, c := newTestRemoteSimulator(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	hash1 := prepareAndSend(t, c, "0xaaaa", 0, "set")
+	sim.MineBlock()
+
+	streamID := fftypes.NewUUID()
+	listener1 := fftypes.NewUUID()
+	events := make(chan *ffcapi.ListenerEvent)
+	blocks := make(chan *ffcapi.BlockHashEvent, 10)
+	_, _, err := c.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{
+		ID:            streamID,
+		StreamContext: ctx,
+		EventStream:   events,
+		BlockListener: blocks,
+		InitialListeners: []*ffcapi.EventListenerAddRequest{{
+			StreamID:   streamID,
+			ListenerID: listener1,
+			EventListenerOptions: ffcapi.EventListenerOptions{
+				FromBlock: "earliest",
+				Filters:   []fftypes.JSONAny{`{"event":"set"}`},
+			},
+		}},
+	})
+	assert.NoError(t, err)
+
+	ev1 := <-events
+	assert.Equal(t, hash1, ev1.Event.ID.TransactionHash)
+	assert.Equal(t, listener1, ev1.Event.ID.ListenerID)
+	assert.JSONEq(t, `{"block":1,"transactionIndex":0,"logIndex":0}`, string(checkpointJSON(ev1.Checkpoint)))
+	assert.Equal(t, "0xcontract", ev1.Event.Info.(map[string]interface{})["address"])
+
+	// Add a second listener with an explicit checkpoint
+	listener2 := fftypes.NewUUID()
+	cp := c.EventStreamNewCheckpointStruct()
+	err = json.Unmarshal([]byte(`{"block":1,"transactionIndex":-1,"logIndex":-1}`), &cp)
+	assert.NoError(t, err)
+	_, _, err = c.EventListenerAdd(ctx, &ffcapi.EventListenerAddRequest{
+		StreamID:   streamID,
+		ListenerID: listener2,
+		Checkpoint: cp,
+	})
+	assert.NoError(t, err)
+	ev2 := <-events
+	assert.Equal(t, hash1, ev2.Event.ID.TransactionHash)
+	assert.Equal(t, listener2, ev2.Event.ID.ListenerID)
+
+	// Checkpoints compare via the server
+	hash2 := prepareAndSend(t, c, "0xaaaa", 1, "set")
+	sim.MineBlock()
+	ev3 := <-events
+	ev4 := <-events
+	assert.Equal(t, hash2, ev3.Event.ID.TransactionHash)
+	assert.Equal(t, hash2, ev4.Event.ID.TransactionHash)
+	assert.True(t, ev1.Checkpoint.LessThan(ev3.Checkpoint))
+	assert.False(t, ev3.Checkpoint.LessThan(ev1.Checkpoint))
+	assert.False(t, ev3.Checkpoint.LessThan(ev4.Checkpoint))
+
+	hwm, _, err := c.EventListenerHWM(ctx, &ffcapi.EventListenerHWMRequest{StreamID: streamID, ListenerID: listener1})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"block":3,"transactionIndex":-1,"logIndex":-1}`, string(checkpointJSON(hwm.Checkpoint)))
+
+	_, _, err = c.EventListenerRemove(ctx, &ffcapi.EventListenerRemoveRequest{StreamID: streamID, ListenerID: listener2})
+	assert.NoError(t, err)
+	c.mux.Lock()
+	assert.Len(t, c.streams[*streamID].listeners, 1)
+	assert.Equal(t, ev3.Checkpoint, c.streams[*streamID].listeners[*listener1].Checkpoint)
+	c.mux.Unlock()
+
+	cancelCtx()
+	_, _, err = c.EventStreamStopped(context.Background(), &ffcapi.EventStreamStoppedRequest{ID: streamID})
+	assert.NoError(t, err)
+}
+
+func TestClientBlockListener(t *testing.T) {
+	sim, c := newTestRemoteSimulator(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	blocks := make(chan *ffcapi.BlockHashEvent)
+	_, _, err := c.NewBlockListener(ctx, &ffcapi.NewBlockListenerRequest{
+		ID:              fftypes.NewUUID(),
+		ListenerContext: ctx,
+		BlockListener:   blocks,
+	})
+	assert.NoError(t, err)
+
+	block := sim.MineBlock()
+	bhe := <-blocks
+	for len(bhe.BlockHashes) == 0 || bhe.BlockHashes[len(bhe.BlockHashes)-1] != block.BlockHash {
+		bhe = <-blocks
+	}
+}
+
+func TestClientEventStreamReconnect(t *testing.T) {
+	sim := simulator.NewSimulator(context.Background(), nil)
+	defer sim.Close()
+
+	// We switch between two servers, to simulate the connector restarting
+	s1 := NewServer(context.Background(), sim)
+	var current http.Handler = s1
+	var currentMux sync.Mutex
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		currentMux.Lock()
+		h := current
+		currentMux.Unlock()
+		h.ServeHTTP(w, r)
+	}))
+	defer hs.Close()
+	c := newTestClient(t, hs.URL)
+
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+	streamID := fftypes.NewUUID()
+	listenerID := fftypes.NewUUID()
+	events := make(chan *ffcapi.ListenerEvent)
+	blocks := make(chan *ffcapi.BlockHashEvent, 10)
+	_, _, err := c.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{
+		ID:            streamID,
+		StreamContext: ctx,
+		EventStream:   events,
+		BlockListener: blocks,
+	})
+	assert.NoError(t, err)
+	_, _, err = c.EventListenerAdd(ctx, &ffcapi.EventListenerAddRequest{
+		StreamID:   streamID,
+		ListenerID: listenerID,
+		EventListenerOptions: ffcapi.EventListenerOptions{
+			FromBlock: "earliest",
+		},
+	})
+	assert.NoError(t, err)
+
+	prepareAndSend(t, c, "0xaaaa", 0, "set")
+	sim.MineBlock()
+	ev1 := <-events
+	assert.Equal(t, listenerID, ev1.Event.ID.ListenerID)
+
+	s2 := NewServer(context.Background(), sim)
+	defer s2.Close()
+	currentMux.Lock()
+	current = s2
+	currentMux.Unlock()
+	s1.Close()
+
+	// We get a gap notification, and then only new events after the checkpoint
+	bhe := <-blocks
+	for !bhe.GapPotential {
+		bhe = <-blocks
+	}
+	hash2 := prepareAndSend(t, c, "0xaaaa", 1, "set")
+	sim.MineBlock()
+	ev2 := <-events
+	assert.Equal(t, hash2, ev2.Event.ID.TransactionHash)
+}
+
+func TestClientEventStreamCancelDuringReconnect(t *testing.T) {
+	sim := simulator.NewSimulator(context.Background(), nil)
+	defer sim.Close()
+	s := NewServer(context.Background(), sim)
+	hs := httptest.NewServer(s)
+	defer hs.Close()
+	c := newTestClient(t, hs.URL)
+
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	streamID := fftypes.NewUUID()
+	_, _, err := c.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{
+		ID:            streamID,
+		StreamContext: ctx,
+		EventStream:   make(chan *ffcapi.ListenerEvent),
+		BlockListener: make(chan *ffcapi.BlockHashEvent),
+	})
+	assert.NoError(t, err)
+
+	// The server going away causes the client to retry indefinitely, until it is cancelled
+	s.Close()
+	cancelCtx()
+	for {
+		c.mux.Lock()
+		remaining := len(c.streams)
+		c.mux.Unlock()
+		if remaining == 0 {
+			break
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+)
+
+const (
+	WebSocketRetryInitialDelay = "ws.retry.initialDelay"
+	WebSocketRetryMaxDelay     = "ws.retry.maxDelay"
+	WebSocketRetryFactor       = "ws.retry.factor"
+)
+
+const (
+	defaultWebSocketRetryInitialDelay = "250ms"
+	defaultWebSocketRetryMaxDelay     = "30s"
+	defaultWebSocketRetryFactor       = 2.0
+)
+
+// InitConfig registers the configuration for the client, which is the standard HTTP client
+// configuration for the connector URL, plus the reconnect behavior of WebSockets
+func InitConfig(conf config.Section) {
+	ffresty.InitConfig(conf)
+	conf.AddKnownKey(WebSocketRetryInitialDelay, defaultWebSocketRetryInitialDelay)
+	conf.AddKnownKey(WebSocketRetryMaxDelay, defaultWebSocketRetryMaxDelay)
+	conf.AddKnownKey(WebSocketRetryFactor, defaultWebSocketRetryFactor)
+}
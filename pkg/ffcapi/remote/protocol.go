@@ -0,0 +1,134 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package remote provides an HTTP/JSON transport for the FFCAPI, so that a connector can run
+// in a separate process (and be written in a different language) to the transaction manager.
+//
+// Each ffcapi.API method is a POST to <prefix>/<operation>, where the request and response bodies are
+// the JSON serialization of the ffcapi request/response structures. Errors are returned with a non-2xx
+// status, and a body containing the error message and the ffcapi.ErrorReason.
+//
+// The long-lived channels of EventStreamStart and NewBlockListener are carried over a WebSocket at
+// <prefix>/ws, where the first message from the client starts the stream or block listener, and the
+// stream is stopped when the WebSocket closes.
+//
+// Checkpoints are opaque to the client. They are passed as raw JSON, and comparisons are delegated
+// to the server via the checkpointLessThan operation.
+package remote
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+const (
+	// PathPrefix is the path under which the server registers its routes
+	PathPrefix = "/ffcapi"
+	// WebSocketPath is the path, under the prefix, for the WebSocket carrying event stream and block listener channels
+	WebSocketPath = "/ws"
+)
+
+// Operation names, which form the last segment of the path for each POST request
+const (
+	OpAddressBalance             = "addressBalance"
+	OpBlockInfoByHash            = "blockInfoByHash"
+	OpBlockInfoByNumber          = "blockInfoByNumber"
+	OpNextNonceForSigner         = "nextNonceForSigner"
+	OpGasEstimate                = "gasEstimate"
+	OpGasPriceEstimate           = "gasPriceEstimate"
+	OpQueryInvoke                = "queryInvoke"
+	OpTransactionReceipt         = "transactionReceipt"
+	OpTransactionReceipts        = "transactionReceipts"
+	OpTransactionByHash          = "transactionByHash"
+	OpTransactionCancel          = "transactionCancel"
+	OpTransactionSimulate        = "transactionSimulate"
+	OpTransactionPrepare         = "transactionPrepare"
+	OpTransactionSend            = "transactionSend"
+	OpTransactionSign            = "transactionSign"
+	OpDeployContractPrepare      = "deployContractPrepare"
+	OpEventStreamStopped         = "eventStreamStopped"
+	OpEventListenerVerifyOptions = "eventListenerVerifyOptions"
+	OpEventListenerAdd           = "eventListenerAdd"
+	OpEventListenerRemove        = "eventListenerRemove"
+	OpEventListenerHWM           = "eventListenerHWM"
+	OpCheckpointLessThan         = "checkpointLessThan"
+	OpIsLive                     = "isLive"
+	OpIsReady                    = "isReady"
+)
+
+// WebSocket message types
+const (
+	// MsgTypeStartEventStream is sent by the client to start an event stream
+	MsgTypeStartEventStream = "startEventStream"
+	// MsgTypeStartBlockListener is sent by the client to start a block listener
+	MsgTypeStartBlockListener = "startBlockListener"
+	// MsgTypeStarted is sent by the server once the stream/listener has started
+	MsgTypeStarted = "started"
+	// MsgTypeError is sent by the server if the stream/listener failed to start, before it closes the WebSocket
+	MsgTypeError = "error"
+	// MsgTypeListenerEvent is sent by the server for each event on an event stream
+	MsgTypeListenerEvent = "listenerEvent"
+	// MsgTypeBlockHashEvent is sent by the server for each notification of new blocks
+	MsgTypeBlockHashEvent = "blockHashEvent"
+)
+
+// ErrorResponse is the body returned on a non-2xx status
+type ErrorResponse struct {
+	Error  string             `json:"error"`
+	Reason ffcapi.ErrorReason `json:"reason,omitempty"`
+}
+
+// CheckpointLessThanRequest asks the server to compare two checkpoints in the connector's own format
+type CheckpointLessThanRequest struct {
+	A json.RawMessage `json:"a"`
+	B json.RawMessage `json:"b"`
+}
+
+// CheckpointLessThanResponse is the result of comparing two checkpoints
+type CheckpointLessThanResponse struct {
+	LessThan bool `json:"lessThan"`
+}
+
+// EventListenerAddRequest is the serialized form of ffcapi.EventListenerAddRequest, with the checkpoint as raw JSON
+type EventListenerAddRequest struct {
+	*ffcapi.EventListenerAddRequest
+	Checkpoint json.RawMessage `json:"Checkpoint,omitempty"`
+}
+
+// EventListenerHWMResponse is the serialized form of ffcapi.EventListenerHWMResponse, with the checkpoint as raw JSON
+type EventListenerHWMResponse struct {
+	*ffcapi.EventListenerHWMResponse
+	Checkpoint json.RawMessage `json:"checkpoint,omitempty"`
+}
+
+// ListenerEvent is the serialized form of ffcapi.ListenerEvent, with the checkpoint as raw JSON
+type ListenerEvent struct {
+	*ffcapi.ListenerEvent
+	Checkpoint json.RawMessage `json:"checkpoint,omitempty"`
+}
+
+// WebSocketMessage is the envelope for all messages in both directions on the WebSocket
+type WebSocketMessage struct {
+	Type             string                     `json:"type"`
+	ID               *fftypes.UUID              `json:"id,omitempty"`
+	InitialListeners []*EventListenerAddRequest `json:"initialListeners,omitempty"`
+	Error            string                     `json:"error,omitempty"`
+	Reason           ffcapi.ErrorReason         `json:"reason,omitempty"`
+	ListenerEvent    *ListenerEvent             `json:"listenerEvent,omitempty"`
+	BlockHashEvent   *ffcapi.BlockHashEvent     `json:"blockHashEvent,omitempty"`
+}
@@ -0,0 +1,349 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// Server exposes an ffcapi.API implementation over HTTP/JSON and WebSockets
+type Server interface {
+	http.Handler
+	// Close stops all active event streams and block listeners
+	Close()
+}
+
+type operation struct {
+	newRequest func() interface{}
+	invoke     func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error)
+}
+
+type server struct {
+	ctx        context.Context
+	cancelCtx  func()
+	connector  ffcapi.API
+	router     *mux.Router
+	upgrader   *websocket.Upgrader
+	operations map[string]*operation
+	wg         sync.WaitGroup
+
+	streamsMux sync.Mutex
+	streams    map[fftypes.UUID]*serverStream
+}
+
+// NewServer returns an http.Handler that serves the FFCAPI protocol under PathPrefix, for the supplied connector
+func NewServer(ctx context.Context, connector ffcapi.API) Server {
+	s := &server{
+		connector: connector,
+		router:    mux.NewRouter(),
+		streams:   make(map[fftypes.UUID]*serverStream),
+		upgrader: &websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+		},
+	}
+	s.ctx, s.cancelCtx = context.WithCancel(log.WithLogField(ctx, "role", "ffcapi_server"))
+	s.operations = s.buildOperations()
+	s.router.HandleFunc(PathPrefix+WebSocketPath, s.webSocketHandler)
+	s.router.HandleFunc(PathPrefix+"/{op}", s.operationHandler).Methods(http.MethodPost)
+	return s
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+func (s *server) Close() {
+	s.cancelCtx()
+	s.wg.Wait()
+}
+
+func (s *server) buildOperations() map[string]*operation {
+	c := s.connector
+	return map[string]*operation{
+		OpAddressBalance: {
+			newRequest: func() interface{} { return &ffcapi.AddressBalanceRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				return c.AddressBalance(ctx, req.(*ffcapi.AddressBalanceRequest))
+			},
+		},
+		OpBlockInfoByHash: {
+			newRequest: func() interface{} { return &ffcapi.BlockInfoByHashRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				return c.BlockInfoByHash(ctx, req.(*ffcapi.BlockInfoByHashRequest))
+			},
+		},
+		OpBlockInfoByNumber: {
+			newRequest: func() interface{} { return &ffcapi.BlockInfoByNumberRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				return c.BlockInfoByNumber(ctx, req.(*ffcapi.BlockInfoByNumberRequest))
+			},
+		},
+		OpNextNonceForSigner: {
+			newRequest: func() interface{} { return &ffcapi.NextNonceForSignerRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				return c.NextNonceForSigner(ctx, req.(*ffcapi.NextNonceForSignerRequest))
+			},
+		},
+		OpGasEstimate: {
+			newRequest: func() interface{} { return &ffcapi.TransactionInput{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				return c.GasEstimate(ctx, req.(*ffcapi.TransactionInput))
+			},
+		},
+		OpGasPriceEstimate: {
+			newRequest: func() interface{} { return &ffcapi.GasPriceEstimateRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				return c.GasPriceEstimate(ctx, req.(*ffcapi.GasPriceEstimateRequest))
+			},
+		},
+		OpQueryInvoke: {
+			newRequest: func() interface{} { return &ffcapi.QueryInvokeRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				return c.QueryInvoke(ctx, req.(*ffcapi.QueryInvokeRequest))
+			},
+		},
+		OpTransactionReceipt: {
+			newRequest: func() interface{} { return &ffcapi.TransactionReceiptRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				return c.TransactionReceipt(ctx, req.(*ffcapi.TransactionReceiptRequest))
+			},
+		},
+		OpTransactionReceipts: {
+			newRequest: func() interface{} { return &ffcapi.TransactionReceiptsRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				batcher, ok := c.(ffcapi.ReceiptBatcher)
+				if !ok {
+					return nil, ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionReceipts")
+				}
+				return batcher.TransactionReceipts(ctx, req.(*ffcapi.TransactionReceiptsRequest))
+			},
+		},
+		OpTransactionByHash: {
+			newRequest: func() interface{} { return &ffcapi.TransactionByHashRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				finder, ok := c.(ffcapi.TransactionFinder)
+				if !ok {
+					return nil, ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionByHash")
+				}
+				return finder.TransactionByHash(ctx, req.(*ffcapi.TransactionByHashRequest))
+			},
+		},
+		OpTransactionCancel: {
+			newRequest: func() interface{} { return &ffcapi.TransactionCancelRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				canceller, ok := c.(ffcapi.TransactionCanceller)
+				if !ok {
+					return nil, ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionCancel")
+				}
+				return canceller.TransactionCancel(ctx, req.(*ffcapi.TransactionCancelRequest))
+			},
+		},
+		OpTransactionSimulate: {
+			newRequest: func() interface{} { return &ffcapi.TransactionSimulateRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				simulator, ok := c.(ffcapi.TransactionSimulator)
+				if !ok {
+					return nil, ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionSimulate")
+				}
+				return simulator.TransactionSimulate(ctx, req.(*ffcapi.TransactionSimulateRequest))
+			},
+		},
+		OpTransactionPrepare: {
+			newRequest: func() interface{} { return &ffcapi.TransactionPrepareRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				return c.TransactionPrepare(ctx, req.(*ffcapi.TransactionPrepareRequest))
+			},
+		},
+		OpTransactionSend: {
+			newRequest: func() interface{} { return &ffcapi.TransactionSendRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				return c.TransactionSend(ctx, req.(*ffcapi.TransactionSendRequest))
+			},
+		},
+		OpTransactionSign: {
+			newRequest: func() interface{} { return &ffcapi.TransactionSignRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				signer, ok := c.(ffcapi.TransactionSigner)
+				if !ok {
+					return nil, ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionSign")
+				}
+				return signer.TransactionSign(ctx, req.(*ffcapi.TransactionSignRequest))
+			},
+		},
+		OpDeployContractPrepare: {
+			newRequest: func() interface{} { return &ffcapi.ContractDeployPrepareRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				return c.DeployContractPrepare(ctx, req.(*ffcapi.ContractDeployPrepareRequest))
+			},
+		},
+		OpEventStreamStopped: {
+			newRequest: func() interface{} { return &ffcapi.EventStreamStoppedRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				return c.EventStreamStopped(ctx, req.(*ffcapi.EventStreamStoppedRequest))
+			},
+		},
+		OpEventListenerVerifyOptions: {
+			newRequest: func() interface{} { return &ffcapi.EventListenerVerifyOptionsRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				return c.EventListenerVerifyOptions(ctx, req.(*ffcapi.EventListenerVerifyOptionsRequest))
+			},
+		},
+		OpEventListenerAdd: {
+			newRequest: func() interface{} { return &EventListenerAddRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				addReq, err := s.restoreListenerAddRequest(ctx, req.(*EventListenerAddRequest))
+				if err != nil {
+					return nil, ffcapi.ErrorReasonInvalidInputs, err
+				}
+				return c.EventListenerAdd(ctx, addReq)
+			},
+		},
+		OpEventListenerRemove: {
+			newRequest: func() interface{} { return &ffcapi.EventListenerRemoveRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				return c.EventListenerRemove(ctx, req.(*ffcapi.EventListenerRemoveRequest))
+			},
+		},
+		OpEventListenerHWM: {
+			newRequest: func() interface{} { return &ffcapi.EventListenerHWMRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				return c.EventListenerHWM(ctx, req.(*ffcapi.EventListenerHWMRequest))
+			},
+		},
+		OpCheckpointLessThan: {
+			newRequest: func() interface{} { return &CheckpointLessThanRequest{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				return s.checkpointLessThan(ctx, req.(*CheckpointLessThanRequest))
+			},
+		},
+		OpIsLive: {
+			newRequest: func() interface{} { return &struct{}{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				return c.IsLive(ctx)
+			},
+		},
+		OpIsReady: {
+			newRequest: func() interface{} { return &struct{}{} },
+			invoke: func(ctx context.Context, req interface{}) (interface{}, ffcapi.ErrorReason, error) {
+				return c.IsReady(ctx)
+			},
+		},
+	}
+}
+
+func (s *server) restoreCheckpoint(ctx context.Context, raw json.RawMessage) (ffcapi.EventListenerCheckpoint, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	cp := s.connector.EventStreamNewCheckpointStruct()
+	if err := json.Unmarshal(raw, &cp); err != nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgRemoteFFCAPIInvalidCheckpoint, err)
+	}
+	return cp, nil
+}
+
+func (s *server) restoreListenerAddRequest(ctx context.Context, req *EventListenerAddRequest) (*ffcapi.EventListenerAddRequest, error) {
+	addReq := req.EventListenerAddRequest
+	if addReq == nil {
+		addReq = &ffcapi.EventListenerAddRequest{}
+	}
+	cp, err := s.restoreCheckpoint(ctx, req.Checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	addReq.Checkpoint = cp
+	return addReq, nil
+}
+
+func (s *server) checkpointLessThan(ctx context.Context, req *CheckpointLessThanRequest) (*CheckpointLessThanResponse, ffcapi.ErrorReason, error) {
+	a, err := s.restoreCheckpoint(ctx, req.A)
+	if err == nil {
+		var b ffcapi.EventListenerCheckpoint
+		if b, err = s.restoreCheckpoint(ctx, req.B); err == nil && a != nil && b != nil {
+			return &CheckpointLessThanResponse{LessThan: a.LessThan(b)}, "", nil
+		}
+	}
+	if err == nil {
+		err = i18n.NewError(ctx, tmmsgs.MsgRemoteFFCAPIInvalidCheckpoint, "null")
+	}
+	return nil, ffcapi.ErrorReasonInvalidInputs, err
+}
+
+func (s *server) writeJSON(ctx context.Context, w http.ResponseWriter, status int, body interface{}) {
+	b, _ := json.Marshal(body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(b); err != nil {
+		log.L(ctx).Warnf("Failed to write response: %s", err)
+	}
+}
+
+func (s *server) operationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	opName := mux.Vars(r)["op"]
+	op := s.operations[opName]
+	if op == nil {
+		s.writeJSON(ctx, w, http.StatusNotFound, &ErrorResponse{
+			Error: i18n.NewError(ctx, tmmsgs.MsgRemoteFFCAPIUnknownOperation, opName).Error(),
+		})
+		return
+	}
+
+	req := op.newRequest()
+	body, err := io.ReadAll(r.Body)
+	if err == nil && len(body) > 0 {
+		err = json.Unmarshal(body, req)
+	}
+	if err != nil {
+		s.writeJSON(ctx, w, http.StatusBadRequest, &ErrorResponse{
+			Error:  i18n.NewError(ctx, tmmsgs.MsgRemoteFFCAPIInvalidRequest, opName, err).Error(),
+			Reason: ffcapi.ErrorReasonInvalidInputs,
+		})
+		return
+	}
+
+	res, reason, err := op.invoke(ctx, req)
+	if err != nil {
+		log.L(ctx).Debugf("FFCAPI %s failed (reason=%s): %s", opName, reason, err)
+		s.writeJSON(ctx, w, http.StatusInternalServerError, &ErrorResponse{
+			Error:  err.Error(),
+			Reason: reason,
+		})
+		return
+	}
+	if hwm, ok := res.(*ffcapi.EventListenerHWMResponse); ok {
+		wireHWM := &EventListenerHWMResponse{EventListenerHWMResponse: hwm}
+		if hwm.Checkpoint != nil {
+			wireHWM.Checkpoint, _ = json.Marshal(hwm.Checkpoint)
+		}
+		res = wireHWM
+	}
+	s.writeJSON(ctx, w, http.StatusOK, res)
+}
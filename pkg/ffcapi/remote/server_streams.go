@@ -0,0 +1,204 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// serverStream tracks the WebSocket session currently serving an event stream, so that a client
+// reconnecting after a network failure takes over the stream cleanly from the stale session
+type serverStream struct {
+	cancelCtx func()
+	done      chan struct{}
+}
+
+func (s *server) webSocketHandler(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already written the error response
+		log.L(s.ctx).Errorf("WebSocket upgrade failed: %s", err)
+		return
+	}
+	s.wg.Add(1)
+	go s.webSocketSession(conn)
+}
+
+func (s *server) webSocketSession(conn *websocket.Conn) {
+	defer s.wg.Done()
+	ctx, cancelCtx := context.WithCancel(log.WithLogField(s.ctx, "wsconn", conn.RemoteAddr().String()))
+
+	// Closing the connection when the context ends unblocks any in-flight read or write
+	closed := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+		close(closed)
+	}()
+	defer func() { cancelCtx(); <-closed }()
+
+	var start WebSocketMessage
+	if err := conn.ReadJSON(&start); err != nil {
+		log.L(ctx).Errorf("Failed to read start message: %s", err)
+		return
+	}
+
+	// The only thing we expect from the client after the start message, is for it to close the connection
+	go func() {
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				log.L(ctx).Debugf("WebSocket closed: %s", err)
+				cancelCtx()
+				return
+			}
+		}
+	}()
+
+	switch start.Type {
+	case MsgTypeStartEventStream:
+		s.runEventStream(ctx, cancelCtx, conn, &start)
+	case MsgTypeStartBlockListener:
+		s.runBlockListener(ctx, conn, &start)
+	default:
+		s.sendStartError(ctx, conn, "", i18n.NewError(ctx, tmmsgs.MsgRemoteFFCAPIInvalidRequest, start.Type, "unknown message type"))
+	}
+}
+
+func (s *server) sendStartError(ctx context.Context, conn *websocket.Conn, reason ffcapi.ErrorReason, err error) {
+	log.L(ctx).Errorf("Failed to start (reason=%s): %s", reason, err)
+	_ = conn.WriteJSON(&WebSocketMessage{
+		Type:   MsgTypeError,
+		Error:  err.Error(),
+		Reason: reason,
+	})
+}
+
+func (s *server) runEventStream(ctx context.Context, cancelCtx func(), conn *websocket.Conn, start *WebSocketMessage) {
+	if start.ID == nil {
+		s.sendStartError(ctx, conn, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, tmmsgs.MsgRemoteFFCAPIInvalidRequest, start.Type, "missing id"))
+		return
+	}
+	ss := s.claimStream(start.ID, cancelCtx)
+	defer s.releaseStream(start.ID, ss)
+
+	req := &ffcapi.EventStreamStartRequest{
+		ID:            start.ID,
+		StreamContext: ctx,
+	}
+	for _, l := range start.InitialListeners {
+		addReq, err := s.restoreListenerAddRequest(ctx, l)
+		if err != nil {
+			s.sendStartError(ctx, conn, ffcapi.ErrorReasonInvalidInputs, err)
+			return
+		}
+		req.InitialListeners = append(req.InitialListeners, addReq)
+	}
+	events := make(chan *ffcapi.ListenerEvent)
+	blocks := make(chan *ffcapi.BlockHashEvent)
+	req.EventStream = events
+	req.BlockListener = blocks
+	if _, reason, err := s.connector.EventStreamStart(ctx, req); err != nil {
+		s.sendStartError(ctx, conn, reason, err)
+		return
+	}
+	defer func() {
+		// The stream context is now cancelled, so we use a fresh one to inform the connector
+		stopCtx := log.WithLogger(context.Background(), log.L(ctx))
+		if _, _, err := s.connector.EventStreamStopped(stopCtx, &ffcapi.EventStreamStoppedRequest{ID: start.ID}); err != nil {
+			log.L(ctx).Warnf("Failed to notify connector of stopped stream %s: %s", start.ID, err)
+		}
+	}()
+	log.L(ctx).Infof("Started event stream %s", start.ID)
+	s.pumpEvents(ctx, conn, events, blocks)
+}
+
+// claimStream stops any existing session for the stream (waiting for the connector to be told it has stopped),
+// before registering the new session
+func (s *server) claimStream(id *fftypes.UUID, cancelCtx func()) *serverStream {
+	ss := &serverStream{cancelCtx: cancelCtx, done: make(chan struct{})}
+	for {
+		s.streamsMux.Lock()
+		existing := s.streams[*id]
+		if existing == nil {
+			s.streams[*id] = ss
+			s.streamsMux.Unlock()
+			return ss
+		}
+		s.streamsMux.Unlock()
+		existing.cancelCtx()
+		<-existing.done
+	}
+}
+
+func (s *server) releaseStream(id *fftypes.UUID, ss *serverStream) {
+	s.streamsMux.Lock()
+	if s.streams[*id] == ss {
+		delete(s.streams, *id)
+	}
+	s.streamsMux.Unlock()
+	close(ss.done)
+}
+
+func (s *server) runBlockListener(ctx context.Context, conn *websocket.Conn, start *WebSocketMessage) {
+	blocks := make(chan *ffcapi.BlockHashEvent)
+	if _, reason, err := s.connector.NewBlockListener(ctx, &ffcapi.NewBlockListenerRequest{
+		ID:              start.ID,
+		ListenerContext: ctx,
+		BlockListener:   blocks,
+	}); err != nil {
+		s.sendStartError(ctx, conn, reason, err)
+		return
+	}
+	log.L(ctx).Infof("Started block listener %s", start.ID)
+	s.pumpEvents(ctx, conn, nil, blocks)
+}
+
+func (s *server) pumpEvents(ctx context.Context, conn *websocket.Conn, events chan *ffcapi.ListenerEvent, blocks chan *ffcapi.BlockHashEvent) {
+	if err := conn.WriteJSON(&WebSocketMessage{Type: MsgTypeStarted}); err != nil {
+		log.L(ctx).Errorf("Failed to send started message: %s", err)
+		return
+	}
+	for {
+		var msg *WebSocketMessage
+		select {
+		case lev := <-events:
+			wireEvent := &ListenerEvent{ListenerEvent: lev}
+			if lev.Checkpoint != nil {
+				wireEvent.Checkpoint, _ = json.Marshal(lev.Checkpoint)
+			}
+			msg = &WebSocketMessage{Type: MsgTypeListenerEvent, ListenerEvent: wireEvent}
+		case bhe := <-blocks:
+			msg = &WebSocketMessage{Type: MsgTypeBlockHashEvent, BlockHashEvent: bhe}
+		case <-ctx.Done():
+			log.L(ctx).Debugf("WebSocket session ending")
+			return
+		}
+		if err := conn.WriteJSON(msg); err != nil {
+			log.L(ctx).Errorf("WebSocket send failed: %s", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/gorilla/websocket"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi/simulator"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer(t *testing.T) (simulator.Simulator, *httptest.Server) {
+	sim := simulator.NewSimulator(context.Background(), nil)
+	s := NewServer(context.Background(), sim)
+	hs := httptest.NewServer(s)
+	t.Cleanup(func() {
+		hs.Close()
+		s.Close()
+		sim.Close()
+	})
+	return sim, hs
+}
+
+func dialTestServer(t *testing.T, hs *httptest.Server) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(hs.URL, "http")+PathPrefix+WebSocketPath, nil)
+	assert.NoError(t, err)
+	return conn
+}
+
+func TestServerUnknownOperation(t *testing.T) {
+	_, hs := newTestServer(t)
+	var errRes ErrorResponse
+	res, err := resty.New().R().SetError(&errRes).Post(hs.URL + PathPrefix + "/unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode())
+	assert.Regexp(t, "FF21099", errRes.Error)
+}
+
+func TestServerBadRequestBody(t *testing.T) {
+	_, hs := newTestServer(t)
+	var errRes ErrorResponse
+	res, err := resty.New().R().SetBody("!json").SetError(&errRes).Post(hs.URL + PathPrefix + "/" + OpAddressBalance)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode())
+	assert.Regexp(t, "FF21100", errRes.Error)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, errRes.Reason)
+}
+
+func TestServerBadCheckpoints(t *testing.T) {
+	_, hs := newTestServer(t)
+
+	var errRes ErrorResponse
+	res, err := resty.New().R().
+		SetBody(`{"StreamID":"` + fftypes.NewUUID().String() + `","Checkpoint":"not an object"}`).
+		SetError(&errRes).
+		Post(hs.URL + PathPrefix + "/" + OpEventListenerAdd)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, res.StatusCode())
+	assert.Regexp(t, "FF21101", errRes.Error)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, errRes.Reason)
+
+	res, err = resty.New().R().
+		SetBody(`{"a":{"block":1},"b":null}`).
+		SetError(&errRes).
+		Post(hs.URL + PathPrefix + "/" + OpCheckpointLessThan)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, res.StatusCode())
+	assert.Regexp(t, "FF21101", errRes.Error)
+
+	res, err = resty.New().R().
+		SetBody(`{"a":[],"b":{"block":1}}`).
+		SetError(&errRes).
+		Post(hs.URL + PathPrefix + "/" + OpCheckpointLessThan)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, res.StatusCode())
+	assert.Regexp(t, "FF21101", errRes.Error)
+
+	var lessThan CheckpointLessThanResponse
+	res, err = resty.New().R().
+		SetBody(`{"a":{"block":1},"b":{"block":2}}`).
+		SetResult(&lessThan).
+		Post(hs.URL + PathPrefix + "/" + OpCheckpointLessThan)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode())
+	assert.True(t, lessThan.LessThan)
+}
+
+func TestServerWebSocketUpgradeFail(t *testing.T) {
+	_, hs := newTestServer(t)
+	res, err := resty.New().R().Get(hs.URL + PathPrefix + WebSocketPath)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode())
+}
+
+func TestServerWebSocketBadStart(t *testing.T) {
+	_, hs := newTestServer(t)
+
+	conn := dialTestServer(t, hs)
+	defer conn.Close()
+	err := conn.WriteJSON(&WebSocketMessage{Type: "wrong"})
+	assert.NoError(t, err)
+	var reply WebSocketMessage
+	err = conn.ReadJSON(&reply)
+	assert.NoError(t, err)
+	assert.Equal(t, MsgTypeError, reply.Type)
+	assert.Regexp(t, "FF21100", reply.Error)
+
+	conn2 := dialTestServer(t, hs)
+	defer conn2.Close()
+	err = conn2.WriteJSON(&WebSocketMessage{Type: MsgTypeStartEventStream})
+	assert.NoError(t, err)
+	err = conn2.ReadJSON(&reply)
+	assert.NoError(t, err)
+	assert.Equal(t, MsgTypeError, reply.Type)
+	assert.Regexp(t, "FF21100.*missing id", reply.Error)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reply.Reason)
+
+	conn3 := dialTestServer(t, hs)
+	defer conn3.Close()
+	err = conn3.WriteJSON(&WebSocketMessage{
+		Type: MsgTypeStartEventStream,
+		ID:   fftypes.NewUUID(),
+		InitialListeners: []*EventListenerAddRequest{{
+			EventListenerAddRequest: &ffcapi.EventListenerAddRequest{ListenerID: fftypes.NewUUID()},
+			Checkpoint:              []byte(`"bad"`),
+		}},
+	})
+	assert.NoError(t, err)
+	err = conn3.ReadJSON(&reply)
+	assert.NoError(t, err)
+	assert.Equal(t, MsgTypeError, reply.Type)
+	assert.Regexp(t, "FF21101", reply.Error)
+
+	// Closing before sending a start message is handled
+	conn4 := dialTestServer(t, hs)
+	conn4.Close()
+}
+
+func TestServerWebSocketTakeOverStream(t *testing.T) {
+	_, hs := newTestServer(t)
+	streamID := fftypes.NewUUID()
+
+	conn1 := dialTestServer(t, hs)
+	defer conn1.Close()
+	err := conn1.WriteJSON(&WebSocketMessage{Type: MsgTypeStartEventStream, ID: streamID})
+	assert.NoError(t, err)
+	var reply WebSocketMessage
+	err = conn1.ReadJSON(&reply)
+	assert.NoError(t, err)
+	assert.Equal(t, MsgTypeStarted, reply.Type)
+
+	// A second connection for the same stream closes the first
+	conn2 := dialTestServer(t, hs)
+	defer conn2.Close()
+	err = conn2.WriteJSON(&WebSocketMessage{Type: MsgTypeStartEventStream, ID: streamID})
+	assert.NoError(t, err)
+	err = conn2.ReadJSON(&reply)
+	assert.NoError(t, err)
+	assert.Equal(t, MsgTypeStarted, reply.Type)
+
+	for err == nil {
+		_, _, err = conn1.NextReader()
+	}
+}
+
+func TestServerOptionalMethodsNotSupported(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(ctx, &ffcapimocks.API{})
+	hs := httptest.NewServer(s)
+	defer hs.Close()
+	defer s.Close()
+	c := newTestClient(t, hs.URL)
+
+	_, reason, err := c.TransactionSign(ctx, &ffcapi.TransactionSignRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionSign", err)
+
+	_, reason, err = c.TransactionReceipts(ctx, &ffcapi.TransactionReceiptsRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionReceipts", err)
+
+	_, reason, err = c.TransactionCancel(ctx, &ffcapi.TransactionCancelRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionCancel", err)
+
+	_, reason, err = c.TransactionSimulate(ctx, &ffcapi.TransactionSimulateRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionSimulate", err)
+
+	_, reason, err = c.TransactionByHash(ctx, &ffcapi.TransactionByHashRequest{})
+	assert.Equal(t, ffcapi.ErrorReasonNotSupported, reason)
+	assert.Regexp(t, "FF21151.*TransactionByHash", err)
+}
@@ -0,0 +1,241 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+type simBlock struct {
+	number       uint64
+	hash         string
+	parentHash   string
+	timestamp    *fftypes.FFTime
+	transactions []*minedTX
+}
+
+type blockListener struct {
+	id           *fftypes.UUID
+	ctx          context.Context
+	updates      chan<- *ffcapi.BlockHashEvent
+	kick         chan struct{}
+	lastNotified uint64 // the highest block number we have notified
+}
+
+func (b *simBlock) info() *ffcapi.BlockInfo {
+	txHashes := make([]string, len(b.transactions))
+	for i, mtx := range b.transactions {
+		txHashes[i] = mtx.hash
+	}
+	return &ffcapi.BlockInfo{
+		BlockNumber:       fftypes.NewFFBigInt(int64(b.number)),
+		BlockHash:         b.hash,
+		ParentHash:        b.parentHash,
+		TransactionHashes: txHashes,
+	}
+}
+
+func (s *simulator) MineBlock() *ffcapi.BlockInfo {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	parent := s.head()
+	block := &simBlock{
+		number:     parent.number + 1,
+		parentHash: parent.hash,
+		timestamp:  fftypes.Now(),
+	}
+
+	// Repeatedly pick the earliest arriving transaction that is executable next for its signer,
+	// which preserves nonce order for each signer, and arrival order across signers.
+	for s.options.MaxTransactionsPerBlock <= 0 || len(block.transactions) < s.options.MaxTransactionsPerBlock {
+		var next *simTX
+		for signer, byNonce := range s.mempool {
+			if tx := byNonce[s.nextNonce[signer]]; tx != nil && (next == nil || tx.seq < next.seq) {
+				next = tx
+			}
+		}
+		if next == nil {
+			break
+		}
+		s.removeFromMempool(next)
+		block.transactions = append(block.transactions, s.execute(block, next))
+	}
+
+	block.hash = s.blockHash(block)
+	for _, mtx := range block.transactions {
+		mtx.blockHash = block.hash
+		s.minedTXs[mtx.hash] = mtx
+	}
+	s.blocks = append(s.blocks, block)
+	s.blocksByHash[block.hash] = block
+	log.L(s.ctx).Debugf("Mined block %d (%s) with %d transactions", block.number, block.hash, len(block.transactions))
+
+	s.kickAll()
+	return block.info()
+}
+
+func (s *simulator) Reorg(depth int) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	head := s.head().number
+	if depth <= 0 || uint64(depth) > head {
+		return i18n.NewError(s.ctx, tmmsgs.MsgSimulatorReorgTooDeep, depth, head)
+	}
+	firstRemoved := head - uint64(depth) + 1
+	removed := s.blocks[firstRemoved:]
+	s.blocks = s.blocks[:firstRemoved]
+
+	// Unwind the blocks from the top down, so balances and nonces are restored in reverse order
+	for i := len(removed) - 1; i >= 0; i-- {
+		block := removed[i]
+		delete(s.blocksByHash, block.hash)
+		for j := len(block.transactions) - 1; j >= 0; j-- {
+			s.unexecute(block.transactions[j])
+		}
+	}
+	s.forkCount++
+
+	for _, es := range s.streams {
+		for _, l := range es.listeners {
+			l.removeEvents(removed)
+		}
+	}
+	for _, bl := range s.blockListeners {
+		if bl.lastNotified >= firstRemoved {
+			bl.lastNotified = firstRemoved - 1
+		}
+	}
+	log.L(s.ctx).Infof("Reorg removed %d blocks from %d to %d", depth, firstRemoved, head)
+
+	s.kickAll()
+	return nil
+}
+
+// kickAll must be called with the lock held
+func (s *simulator) kickAll() {
+	for _, es := range s.streams {
+		kick(es.kick)
+	}
+	for _, bl := range s.blockListeners {
+		kick(bl.kick)
+	}
+}
+
+func kick(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+func (s *simulator) BlockInfoByNumber(ctx context.Context, req *ffcapi.BlockInfoByNumberRequest) (*ffcapi.BlockInfoByNumberResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if reason, err := s.checkDown(ctx); err != nil {
+		return nil, reason, err
+	}
+	if req.BlockNumber == nil || req.BlockNumber.Int().Sign() < 0 || req.BlockNumber.Int().Cmp(new(big.Int).SetUint64(s.head().number)) > 0 {
+		return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, tmmsgs.MsgSimulatorNotFound, "Block", req.BlockNumber)
+	}
+	return &ffcapi.BlockInfoByNumberResponse{
+		BlockInfo: *s.blocks[req.BlockNumber.Uint64()].info(),
+	}, "", nil
+}
+
+func (s *simulator) BlockInfoByHash(ctx context.Context, req *ffcapi.BlockInfoByHashRequest) (*ffcapi.BlockInfoByHashResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if reason, err := s.checkDown(ctx); err != nil {
+		return nil, reason, err
+	}
+	block := s.blocksByHash[req.BlockHash]
+	if block == nil {
+		return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, tmmsgs.MsgSimulatorNotFound, "Block", req.BlockHash)
+	}
+	return &ffcapi.BlockInfoByHashResponse{
+		BlockInfo: *block.info(),
+	}, "", nil
+}
+
+func (s *simulator) NewBlockListener(ctx context.Context, req *ffcapi.NewBlockListenerRequest) (*ffcapi.NewBlockListenerResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if reason, err := s.checkDown(ctx); err != nil {
+		return nil, reason, err
+	}
+	s.addBlockListener(req.ID, req.ListenerContext, req.BlockListener)
+	return &ffcapi.NewBlockListenerResponse{}, "", nil
+}
+
+// addBlockListener must be called with the lock held
+func (s *simulator) addBlockListener(id *fftypes.UUID, ctx context.Context, updates chan<- *ffcapi.BlockHashEvent) {
+	if id == nil {
+		id = fftypes.NewUUID()
+	}
+	bl := &blockListener{
+		id:           id,
+		ctx:          ctx,
+		updates:      updates,
+		kick:         make(chan struct{}, 1),
+		lastNotified: s.head().number,
+	}
+	s.blockListeners[*id] = bl
+	go s.blockListenerLoop(bl)
+}
+
+func (s *simulator) blockListenerLoop(bl *blockListener) {
+	defer func() {
+		s.mux.Lock()
+		delete(s.blockListeners, *bl.id)
+		s.mux.Unlock()
+	}()
+	for {
+		select {
+		case <-bl.kick:
+		case <-bl.ctx.Done():
+			log.L(bl.ctx).Debugf("Simulator block listener %s exiting", bl.id)
+			return
+		}
+
+		s.mux.Lock()
+		var hashes []string
+		head := s.head().number
+		for n := bl.lastNotified + 1; n <= head; n++ {
+			hashes = append(hashes, s.blocks[n].hash)
+		}
+		bl.lastNotified = head
+		s.mux.Unlock()
+
+		if len(hashes) > 0 {
+			select {
+			case bl.updates <- &ffcapi.BlockHashEvent{BlockHashes: hashes}:
+			case <-bl.ctx.Done():
+				log.L(bl.ctx).Debugf("Simulator block listener %s exiting", bl.id)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockInfo(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx := context.Background()
+
+	block1 := s.MineBlock()
+	block2 := s.MineBlock()
+	assert.Equal(t, block1.BlockHash, block2.ParentHash)
+
+	byNumber, _, err := s.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{BlockNumber: fftypes.NewFFBigInt(1)})
+	assert.NoError(t, err)
+	assert.Equal(t, *block1, byNumber.BlockInfo)
+
+	byHash, _, err := s.BlockInfoByHash(ctx, &ffcapi.BlockInfoByHashRequest{BlockHash: block2.BlockHash})
+	assert.NoError(t, err)
+	assert.Equal(t, *block2, byHash.BlockInfo)
+
+	_, reason, err := s.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{BlockNumber: fftypes.NewFFBigInt(3)})
+	assert.Regexp(t, "FF21093", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+	_, reason, err = s.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{})
+	assert.Regexp(t, "FF21093", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+	_, reason, err = s.BlockInfoByHash(ctx, &ffcapi.BlockInfoByHashRequest{BlockHash: "0x12345"})
+	assert.Regexp(t, "FF21093", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+}
+
+func TestReorg(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx := context.Background()
+
+	s.MineBlock()
+	txHash := prepareAndSend(t, s, "0xaaaa", 0, "set")
+	oldBlock := s.MineBlock()
+
+	err := s.Reorg(0)
+	assert.Regexp(t, "FF21098", err)
+	err = s.Reorg(3)
+	assert.Regexp(t, "FF21098", err)
+
+	err = s.Reorg(1)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), s.BlockNumber())
+	assert.Equal(t, 1, s.PendingTransactionCount())
+
+	_, _, err = s.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: txHash})
+	assert.Regexp(t, "FF21093", err)
+	_, _, err = s.BlockInfoByHash(ctx, &ffcapi.BlockInfoByHashRequest{BlockHash: oldBlock.BlockHash})
+	assert.Regexp(t, "FF21093", err)
+
+	// The replacement block has the same transaction, but a different hash
+	newBlock := s.MineBlock()
+	assert.Equal(t, oldBlock.BlockNumber, newBlock.BlockNumber)
+	assert.Equal(t, oldBlock.TransactionHashes, newBlock.TransactionHashes)
+	assert.NotEqual(t, oldBlock.BlockHash, newBlock.BlockHash)
+
+	receipt, _, err := s.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: txHash})
+	assert.NoError(t, err)
+	assert.Equal(t, newBlock.BlockHash, receipt.BlockHash)
+}
+
+func TestNewBlockListener(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	updates := make(chan *ffcapi.BlockHashEvent)
+	_, _, err := s.NewBlockListener(ctx, &ffcapi.NewBlockListenerRequest{
+		ID:              fftypes.NewUUID(),
+		ListenerContext: ctx,
+		BlockListener:   updates,
+	})
+	assert.NoError(t, err)
+
+	block1 := s.MineBlock()
+	bhe := <-updates
+	assert.Equal(t, []string{block1.BlockHash}, bhe.BlockHashes)
+
+	err = s.Reorg(1)
+	assert.NoError(t, err)
+	block1b := s.MineBlock()
+	block2 := s.MineBlock()
+	var hashes []string
+	for len(hashes) < 2 {
+		bhe = <-updates
+		hashes = append(hashes, bhe.BlockHashes...)
+	}
+	assert.Equal(t, []string{block1b.BlockHash, block2.BlockHash}, hashes)
+}
+
+func TestNewBlockListenerCancelWhileSending(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+
+	_, _, err := s.NewBlockListener(ctx, &ffcapi.NewBlockListenerRequest{
+		ListenerContext: ctx,
+		BlockListener:   make(chan *ffcapi.BlockHashEvent),
+	})
+	assert.NoError(t, err)
+	s.MineBlock()
+	cancelCtx()
+
+	for {
+		sim := s.(*simulator)
+		sim.mux.Lock()
+		count := len(sim.blockListeners)
+		sim.mux.Unlock()
+		if count == 0 {
+			break
+		}
+	}
+}
@@ -0,0 +1,401 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// Checkpoint is the position of a listener on the simulated chain. A value of -1 for the
+// TransactionIndex and LogIndex means the start of the block, before any events.
+type Checkpoint struct {
+	Block            uint64 `json:"block"`
+	TransactionIndex int64  `json:"transactionIndex"`
+	LogIndex         int64  `json:"logIndex"`
+}
+
+// EventInfo is the connector specific information included with each event
+type EventInfo struct {
+	Address string `json:"address"` // the address of the contract that emitted the event
+}
+
+// Filter is the format of each entry in the filters of a listener on the simulated chain.
+// Events match if all the supplied fields match, and an event matches the listener if it matches any filter.
+type Filter struct {
+	Address string `json:"address,omitempty"` // the address of the contract emitting the event
+	Event   string `json:"event,omitempty"`   // the name of the method that was invoked to emit the event
+}
+
+func (cp *Checkpoint) LessThan(b ffcapi.EventListenerCheckpoint) bool {
+	bcp := b.(*Checkpoint)
+	return cp.Block < bcp.Block ||
+		(cp.Block == bcp.Block &&
+			(cp.TransactionIndex < bcp.TransactionIndex ||
+				(cp.TransactionIndex == bcp.TransactionIndex && cp.LogIndex < bcp.LogIndex)))
+}
+
+type eventStream struct {
+	id        *fftypes.UUID
+	ctx       context.Context
+	updates   chan<- *ffcapi.ListenerEvent
+	kick      chan struct{}
+	listeners map[fftypes.UUID]*listener
+}
+
+type listener struct {
+	id             *fftypes.UUID
+	filters        []*Filter
+	after          Checkpoint // all matching events after this checkpoint are yet to be delivered
+	pendingRemoved []*ffcapi.ListenerEvent
+}
+
+func (f *Filter) matches(ev *simEvent) bool {
+	return (f.Address == "" || f.Address == ev.address) &&
+		(f.Event == "" || f.Event == ev.signature)
+}
+
+func (l *listener) matches(ev *simEvent) bool {
+	if len(l.filters) == 0 {
+		return true
+	}
+	for _, f := range l.filters {
+		if f.matches(ev) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *listener) buildEvent(block *simBlock, mtx *minedTX, logIndex int) (*Checkpoint, *ffcapi.Event) {
+	ev := mtx.events[logIndex]
+	return &Checkpoint{
+		Block:            block.number,
+		TransactionIndex: mtx.txIndex,
+		LogIndex:         int64(logIndex),
+	}, &ffcapi.Event{
+		ID: ffcapi.EventID{
+			ListenerID:       l.id,
+			Signature:        ev.signature,
+			BlockHash:        block.hash,
+			BlockNumber:      fftypes.FFuint64(block.number),
+			TransactionHash:  mtx.hash,
+			TransactionIndex: fftypes.FFuint64(mtx.txIndex),
+			LogIndex:         fftypes.FFuint64(logIndex),
+			Timestamp:        block.timestamp,
+		},
+		Info: &EventInfo{
+			Address: ev.address,
+		},
+		Data: ev.data,
+	}
+}
+
+// removeEvents must be called with the lock held. Queues removed events for any events the
+// listener has been passed from the supplied blocks, and rewinds the listener to re-detect from the
+// first of those blocks.
+func (l *listener) removeEvents(blocks []*simBlock) {
+	for _, block := range blocks {
+		for _, mtx := range block.transactions {
+			for i, ev := range mtx.events {
+				if !l.matches(ev) {
+					continue
+				}
+				cp, event := l.buildEvent(block, mtx, i)
+				if !l.after.LessThan(cp) {
+					l.pendingRemoved = append(l.pendingRemoved, &ffcapi.ListenerEvent{
+						Checkpoint: cp,
+						Event:      event,
+						Removed:    true,
+					})
+				}
+			}
+		}
+	}
+	rewindTo := &Checkpoint{Block: blocks[0].number, TransactionIndex: -1, LogIndex: -1}
+	if rewindTo.LessThan(&l.after) {
+		l.after = *rewindTo
+	}
+}
+
+// pendingEvents must be called with the lock held, and returns the removed and new events to deliver
+// to the listener, advancing the position of the listener past them
+func (s *simulator) pendingEvents(l *listener) []*ffcapi.ListenerEvent {
+	events := l.pendingRemoved
+	l.pendingRemoved = nil
+	for n := l.after.Block; n < uint64(len(s.blocks)); n++ {
+		block := s.blocks[n]
+		for _, mtx := range block.transactions {
+			for i, ev := range mtx.events {
+				if !l.matches(ev) {
+					continue
+				}
+				cp, event := l.buildEvent(block, mtx, i)
+				if l.after.LessThan(cp) {
+					events = append(events, &ffcapi.ListenerEvent{
+						Checkpoint: cp,
+						Event:      event,
+					})
+					l.after = *cp
+				}
+			}
+		}
+	}
+	return events
+}
+
+func (s *simulator) eventStreamLoop(es *eventStream) {
+	for {
+		select {
+		case <-es.kick:
+		case <-es.ctx.Done():
+			log.L(es.ctx).Debugf("Simulator event stream %s exiting", es.id)
+			return
+		}
+
+		s.mux.Lock()
+		var events []*ffcapi.ListenerEvent
+		for _, l := range es.listeners {
+			events = append(events, s.pendingEvents(l)...)
+		}
+		s.mux.Unlock()
+
+		for _, lev := range events {
+			select {
+			case es.updates <- lev:
+			case <-es.ctx.Done():
+				log.L(es.ctx).Debugf("Simulator event stream %s exiting", es.id)
+				return
+			}
+		}
+	}
+}
+
+func parseFilters(ctx context.Context, filters []fftypes.JSONAny) ([]*Filter, error) {
+	parsed := make([]*Filter, len(filters))
+	for i, f := range filters {
+		decoder := json.NewDecoder(bytes.NewReader(f.Bytes()))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&parsed[i]); err != nil || parsed[i] == nil {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgSimulatorInvalidFilter, f.String())
+		}
+	}
+	return parsed, nil
+}
+
+// parseFromBlock must be called with the lock held
+func (s *simulator) parseFromBlock(ctx context.Context, fromBlock string) (*Checkpoint, error) {
+	switch fromBlock {
+	case "earliest":
+		return &Checkpoint{Block: 0, TransactionIndex: -1, LogIndex: -1}, nil
+	case "", "latest":
+		return &Checkpoint{Block: s.head().number + 1, TransactionIndex: -1, LogIndex: -1}, nil
+	default:
+		blockNumber, err := strconv.ParseUint(fromBlock, 10, 64)
+		if err != nil {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgSimulatorInvalidFromBlock, fromBlock)
+		}
+		return &Checkpoint{Block: blockNumber, TransactionIndex: -1, LogIndex: -1}, nil
+	}
+}
+
+func (s *simulator) EventStreamNewCheckpointStruct() ffcapi.EventListenerCheckpoint {
+	return &Checkpoint{}
+}
+
+func (s *simulator) EventListenerVerifyOptions(ctx context.Context, req *ffcapi.EventListenerVerifyOptionsRequest) (*ffcapi.EventListenerVerifyOptionsResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	filters, err := parseFilters(ctx, req.Filters)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, err
+	}
+	if _, err := s.parseFromBlock(ctx, req.FromBlock); err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, err
+	}
+	signatures := make([]string, len(filters))
+	for i, f := range filters {
+		signatures[i] = f.Event
+		if signatures[i] == "" {
+			signatures[i] = "*"
+		}
+	}
+	options := fftypes.JSONAny("{}")
+	if req.Options != nil {
+		options = *req.Options
+	}
+	return &ffcapi.EventListenerVerifyOptionsResponse{
+		ResolvedSignature: strings.Join(signatures, ";"),
+		ResolvedOptions:   options,
+	}, "", nil
+}
+
+func (s *simulator) EventStreamStart(ctx context.Context, req *ffcapi.EventStreamStartRequest) (*ffcapi.EventStreamStartResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if reason, err := s.checkDown(ctx); err != nil {
+		return nil, reason, err
+	}
+	es := &eventStream{
+		id:        req.ID,
+		ctx:       req.StreamContext,
+		updates:   req.EventStream,
+		kick:      make(chan struct{}, 1),
+		listeners: make(map[fftypes.UUID]*listener),
+	}
+	for _, lReq := range req.InitialListeners {
+		l, err := s.newListener(ctx, lReq)
+		if err != nil {
+			return nil, ffcapi.ErrorReasonInvalidInputs, err
+		}
+		es.listeners[*l.id] = l
+	}
+	s.streams[*req.ID] = es
+	if req.BlockListener != nil {
+		s.addBlockListener(fftypes.NewUUID(), req.StreamContext, req.BlockListener)
+	}
+	go s.eventStreamLoop(es)
+	kick(es.kick)
+	return &ffcapi.EventStreamStartResponse{}, "", nil
+}
+
+func (s *simulator) EventStreamStopped(ctx context.Context, req *ffcapi.EventStreamStoppedRequest) (*ffcapi.EventStreamStoppedResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	delete(s.streams, *req.ID)
+	return &ffcapi.EventStreamStoppedResponse{}, "", nil
+}
+
+// newListener must be called with the lock held
+func (s *simulator) newListener(ctx context.Context, req *ffcapi.EventListenerAddRequest) (*listener, error) {
+	filters, err := parseFilters(ctx, req.Filters)
+	if err != nil {
+		return nil, err
+	}
+	var after *Checkpoint
+	if req.Checkpoint != nil {
+		cp, ok := req.Checkpoint.(*Checkpoint)
+		if !ok {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgSimulatorInvalidCheckpoint, req.Checkpoint)
+		}
+		after = cp
+	} else if after, err = s.parseFromBlock(ctx, req.FromBlock); err != nil {
+		return nil, err
+	}
+	return &listener{
+		id:      req.ListenerID,
+		filters: filters,
+		after:   *after,
+	}, nil
+}
+
+// getStream must be called with the lock held
+func (s *simulator) getStream(ctx context.Context, streamID *fftypes.UUID) (*eventStream, error) {
+	var es *eventStream
+	if streamID != nil {
+		es = s.streams[*streamID]
+	}
+	if es == nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgStreamNotFound, streamID)
+	}
+	return es, nil
+}
+
+func (s *simulator) EventListenerAdd(ctx context.Context, req *ffcapi.EventListenerAddRequest) (*ffcapi.EventListenerAddResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if reason, err := s.checkDown(ctx); err != nil {
+		return nil, reason, err
+	}
+	es, err := s.getStream(ctx, req.StreamID)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonNotFound, err
+	}
+	l, err := s.newListener(ctx, req)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, err
+	}
+	es.listeners[*l.id] = l
+	kick(es.kick)
+	return &ffcapi.EventListenerAddResponse{}, "", nil
+}
+
+func (s *simulator) EventListenerRemove(ctx context.Context, req *ffcapi.EventListenerRemoveRequest) (*ffcapi.EventListenerRemoveResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	es, err := s.getStream(ctx, req.StreamID)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonNotFound, err
+	}
+	if req.ListenerID == nil || es.listeners[*req.ListenerID] == nil {
+		return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, tmmsgs.MsgListenerNotFound, req.ListenerID)
+	}
+	delete(es.listeners, *req.ListenerID)
+	return &ffcapi.EventListenerRemoveResponse{}, "", nil
+}
+
+func (s *simulator) EventListenerHWM(ctx context.Context, req *ffcapi.EventListenerHWMRequest) (*ffcapi.EventListenerHWMResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if reason, err := s.checkDown(ctx); err != nil {
+		return nil, reason, err
+	}
+	es, err := s.getStream(ctx, req.StreamID)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonNotFound, err
+	}
+	var l *listener
+	if req.ListenerID != nil {
+		l = es.listeners[*req.ListenerID]
+	}
+	if l == nil {
+		return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, tmmsgs.MsgListenerNotFound, req.ListenerID)
+	}
+	// If there's nothing left to deliver, the listener is at the head of the chain
+	hwm := l.after
+	headCP := Checkpoint{Block: s.head().number + 1, TransactionIndex: -1, LogIndex: -1}
+	if len(l.pendingRemoved) == 0 && hwm.LessThan(&headCP) && !s.hasPendingEvents(l) {
+		hwm = headCP
+	}
+	return &ffcapi.EventListenerHWMResponse{
+		Checkpoint: &hwm,
+	}, "", nil
+}
+
+// hasPendingEvents must be called with the lock held
+func (s *simulator) hasPendingEvents(l *listener) bool {
+	for n := l.after.Block; n < uint64(len(s.blocks)); n++ {
+		for _, mtx := range s.blocks[n].transactions {
+			for i, ev := range mtx.events {
+				cp := &Checkpoint{Block: n, TransactionIndex: mtx.txIndex, LogIndex: int64(i)}
+				if l.matches(ev) && l.after.LessThan(cp) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,316 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func startTestStream(t *testing.T, s Simulator, listeners ...*ffcapi.EventListenerAddRequest) (*fftypes.UUID, chan *ffcapi.ListenerEvent, chan *ffcapi.BlockHashEvent, func()) {
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	streamID := fftypes.NewUUID()
+	events := make(chan *ffcapi.ListenerEvent)
+	blocks := make(chan *ffcapi.BlockHashEvent, 10)
+	for _, l := range listeners {
+		l.StreamID = streamID
+	}
+	_, _, err := s.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{
+		ID:               streamID,
+		StreamContext:    ctx,
+		EventStream:      events,
+		BlockListener:    blocks,
+		InitialListeners: listeners,
+	})
+	assert.NoError(t, err)
+	return streamID, events, blocks, cancelCtx
+}
+
+func TestCheckpointLessThan(t *testing.T) {
+	assert.True(t, (&Checkpoint{Block: 1, TransactionIndex: 5, LogIndex: 5}).LessThan(&Checkpoint{Block: 2, TransactionIndex: -1, LogIndex: -1}))
+	assert.True(t, (&Checkpoint{Block: 1, TransactionIndex: -1, LogIndex: -1}).LessThan(&Checkpoint{Block: 1, TransactionIndex: 0, LogIndex: 0}))
+	assert.True(t, (&Checkpoint{Block: 1, TransactionIndex: 0, LogIndex: 0}).LessThan(&Checkpoint{Block: 1, TransactionIndex: 0, LogIndex: 1}))
+	assert.False(t, (&Checkpoint{Block: 1, TransactionIndex: 0, LogIndex: 1}).LessThan(&Checkpoint{Block: 1, TransactionIndex: 0, LogIndex: 1}))
+	assert.False(t, (&Checkpoint{Block: 2, TransactionIndex: -1, LogIndex: -1}).LessThan(&Checkpoint{Block: 1, TransactionIndex: 0, LogIndex: 1}))
+
+	// Checkpoints restore from JSON into the struct supplied
+	s := newTestSimulator(t, nil)
+	cp := s.EventStreamNewCheckpointStruct()
+	err := json.Unmarshal([]byte(`{"block":10,"transactionIndex":1,"logIndex":-1}`), &cp)
+	assert.NoError(t, err)
+	assert.Equal(t, &Checkpoint{Block: 10, TransactionIndex: 1, LogIndex: -1}, cp)
+}
+
+func TestEventListenerVerifyOptions(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx := context.Background()
+
+	res, _, err := s.EventListenerVerifyOptions(ctx, &ffcapi.EventListenerVerifyOptionsRequest{
+		EventListenerOptions: ffcapi.EventListenerOptions{
+			Filters: []fftypes.JSONAny{`{"event":"set"}`, `{"address":"0xcontract"}`},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "set;*", res.ResolvedSignature)
+	assert.Equal(t, "{}", res.ResolvedOptions.String())
+
+	res, _, err = s.EventListenerVerifyOptions(ctx, &ffcapi.EventListenerVerifyOptionsRequest{
+		EventListenerOptions: ffcapi.EventListenerOptions{
+			FromBlock: "earliest",
+			Options:   fftypes.JSONAnyPtr(`{"some":"option"}`),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"some":"option"}`, res.ResolvedOptions.String())
+
+	_, reason, err := s.EventListenerVerifyOptions(ctx, &ffcapi.EventListenerVerifyOptionsRequest{
+		EventListenerOptions: ffcapi.EventListenerOptions{
+			Filters: []fftypes.JSONAny{`{"unknown":"field"}`},
+		},
+	})
+	assert.Regexp(t, "FF21095", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	_, reason, err = s.EventListenerVerifyOptions(ctx, &ffcapi.EventListenerVerifyOptionsRequest{
+		EventListenerOptions: ffcapi.EventListenerOptions{
+			Filters: []fftypes.JSONAny{`null`},
+		},
+	})
+	assert.Regexp(t, "FF21095", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	_, reason, err = s.EventListenerVerifyOptions(ctx, &ffcapi.EventListenerVerifyOptionsRequest{
+		EventListenerOptions: ffcapi.EventListenerOptions{
+			FromBlock: "wrong",
+		},
+	})
+	assert.Regexp(t, "FF21096", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+}
+
+func TestEventStreamDeliversEventsInOrder(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx := context.Background()
+
+	// Events before the stream starts are caught up from earliest
+	hash1 := prepareAndSend(t, s, "0xaaaa", 0, "set")
+	prepareAndSend(t, s, "0xaaaa", 1, "ignored")
+	s.MineBlock()
+
+	listenerID := fftypes.NewUUID()
+	streamID, events, blocks, done := startTestStream(t, s, &ffcapi.EventListenerAddRequest{
+		ListenerID: listenerID,
+		Name:       "listener1",
+		EventListenerOptions: ffcapi.EventListenerOptions{
+			FromBlock: "earliest",
+			Filters:   []fftypes.JSONAny{`{"event":"set","address":"0xcontract"}`},
+		},
+	})
+	defer done()
+
+	ev := <-events
+	assert.Equal(t, hash1, ev.Event.ID.TransactionHash)
+	assert.Equal(t, "set", ev.Event.ID.Signature)
+	assert.Equal(t, listenerID, ev.Event.ID.ListenerID)
+	assert.Equal(t, &Checkpoint{Block: 1, TransactionIndex: 0, LogIndex: 0}, ev.Checkpoint)
+	assert.JSONEq(t, `{"params":["hello"]}`, ev.Event.Data.String())
+
+	hwm, _, err := s.EventListenerHWM(ctx, &ffcapi.EventListenerHWMRequest{StreamID: streamID, ListenerID: listenerID})
+	assert.NoError(t, err)
+	assert.Equal(t, &Checkpoint{Block: 2, TransactionIndex: -1, LogIndex: -1}, hwm.Checkpoint)
+
+	hash2 := prepareAndSend(t, s, "0xbbbb", 0, "set")
+	hash3 := prepareAndSend(t, s, "0xbbbb", 1, "set")
+	block := s.MineBlock()
+	ev2 := <-events
+	ev3 := <-events
+	assert.Equal(t, hash2, ev2.Event.ID.TransactionHash)
+	assert.Equal(t, hash3, ev3.Event.ID.TransactionHash)
+	assert.True(t, ev2.Checkpoint.LessThan(ev3.Checkpoint))
+
+	bhe := <-blocks
+	for len(bhe.BlockHashes) == 0 || bhe.BlockHashes[len(bhe.BlockHashes)-1] != block.BlockHash {
+		bhe = <-blocks
+	}
+
+	_, _, err = s.EventStreamStopped(ctx, &ffcapi.EventStreamStoppedRequest{ID: streamID})
+	assert.NoError(t, err)
+}
+
+func TestEventListenerAddRemoveHWM(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx := context.Background()
+
+	streamID, events, _, done := startTestStream(t, s)
+	defer done()
+
+	listenerID := fftypes.NewUUID()
+	_, _, err := s.EventListenerAdd(ctx, &ffcapi.EventListenerAddRequest{
+		StreamID:   streamID,
+		ListenerID: listenerID,
+		Checkpoint: &Checkpoint{Block: 0, TransactionIndex: -1, LogIndex: -1},
+	})
+	assert.NoError(t, err)
+
+	prepareAndSend(t, s, "0xaaaa", 0, "set")
+	s.MineBlock()
+	<-events
+	hwm, _, err := s.EventListenerHWM(ctx, &ffcapi.EventListenerHWMRequest{StreamID: streamID, ListenerID: listenerID})
+	assert.NoError(t, err)
+	assert.Equal(t, &Checkpoint{Block: 2, TransactionIndex: -1, LogIndex: -1}, hwm.Checkpoint)
+
+	_, _, err = s.EventListenerRemove(ctx, &ffcapi.EventListenerRemoveRequest{StreamID: streamID, ListenerID: listenerID})
+	assert.NoError(t, err)
+
+	_, reason, err := s.EventListenerRemove(ctx, &ffcapi.EventListenerRemoveRequest{StreamID: streamID, ListenerID: listenerID})
+	assert.Regexp(t, "FF21046", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+	_, reason, err = s.EventListenerHWM(ctx, &ffcapi.EventListenerHWMRequest{StreamID: streamID, ListenerID: listenerID})
+	assert.Regexp(t, "FF21046", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+}
+
+func TestEventListenerErrors(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx := context.Background()
+
+	_, reason, err := s.EventListenerAdd(ctx, &ffcapi.EventListenerAddRequest{StreamID: fftypes.NewUUID()})
+	assert.Regexp(t, "FF21045", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+	_, reason, err = s.EventListenerRemove(ctx, &ffcapi.EventListenerRemoveRequest{})
+	assert.Regexp(t, "FF21045", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+	_, reason, err = s.EventListenerHWM(ctx, &ffcapi.EventListenerHWMRequest{StreamID: fftypes.NewUUID()})
+	assert.Regexp(t, "FF21045", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+	streamID, _, _, done := startTestStream(t, s)
+	defer done()
+
+	_, reason, err = s.EventListenerAdd(ctx, &ffcapi.EventListenerAddRequest{
+		StreamID:             streamID,
+		ListenerID:           fftypes.NewUUID(),
+		EventListenerOptions: ffcapi.EventListenerOptions{Filters: []fftypes.JSONAny{`[]`}},
+	})
+	assert.Regexp(t, "FF21095", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	_, reason, err = s.EventListenerAdd(ctx, &ffcapi.EventListenerAddRequest{
+		StreamID:             streamID,
+		ListenerID:           fftypes.NewUUID(),
+		EventListenerOptions: ffcapi.EventListenerOptions{FromBlock: "wrong"},
+	})
+	assert.Regexp(t, "FF21096", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	_, reason, err = s.EventListenerAdd(ctx, &ffcapi.EventListenerAddRequest{
+		StreamID:   streamID,
+		ListenerID: fftypes.NewUUID(),
+		Checkpoint: &wrongCheckpoint{},
+	})
+	assert.Regexp(t, "FF21097", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	_, reason, err = s.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{
+		ID:            fftypes.NewUUID(),
+		StreamContext: ctx,
+		InitialListeners: []*ffcapi.EventListenerAddRequest{
+			{EventListenerOptions: ffcapi.EventListenerOptions{FromBlock: "wrong"}},
+		},
+	})
+	assert.Regexp(t, "FF21096", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+}
+
+func TestEventListenerHWMPendingEvents(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx := context.Background()
+
+	prepareAndSend(t, s, "0xaaaa", 0, "set")
+	s.MineBlock()
+
+	// Register a stream with no delivery loop, so the events stay pending
+	streamID := fftypes.NewUUID()
+	listenerID := fftypes.NewUUID()
+	sim := s.(*simulator)
+	sim.streams[*streamID] = &eventStream{
+		id: streamID,
+		listeners: map[fftypes.UUID]*listener{
+			*listenerID: {id: listenerID, after: Checkpoint{Block: 0, TransactionIndex: -1, LogIndex: -1}},
+		},
+	}
+
+	hwm, _, err := s.EventListenerHWM(ctx, &ffcapi.EventListenerHWMRequest{StreamID: streamID, ListenerID: listenerID})
+	assert.NoError(t, err)
+	assert.Equal(t, &Checkpoint{Block: 0, TransactionIndex: -1, LogIndex: -1}, hwm.Checkpoint)
+}
+
+type wrongCheckpoint struct{}
+
+func (wc *wrongCheckpoint) LessThan(b ffcapi.EventListenerCheckpoint) bool { return false }
+
+func TestReorgDeliversRemovedEvents(t *testing.T) {
+	s := newTestSimulator(t, nil)
+
+	listenerID := fftypes.NewUUID()
+	_, events, _, done := startTestStream(t, s, &ffcapi.EventListenerAddRequest{
+		ListenerID: listenerID,
+	})
+	defer done()
+
+	s.MineBlock()
+	txHash := prepareAndSend(t, s, "0xaaaa", 0, "set")
+	s.MineBlock()
+	ev := <-events
+	assert.False(t, ev.Removed)
+	assert.Equal(t, txHash, ev.Event.ID.TransactionHash)
+
+	err := s.Reorg(1)
+	assert.NoError(t, err)
+	removed := <-events
+	assert.True(t, removed.Removed)
+	assert.Equal(t, ev.Event.ID.BlockHash, removed.Event.ID.BlockHash)
+	assert.Equal(t, ev.Checkpoint, removed.Checkpoint)
+
+	// The event is re-detected in the new block at the same position
+	newBlock := s.MineBlock()
+	redetected := <-events
+	assert.False(t, redetected.Removed)
+	assert.Equal(t, txHash, redetected.Event.ID.TransactionHash)
+	assert.Equal(t, newBlock.BlockHash, redetected.Event.ID.BlockHash)
+	assert.Equal(t, ev.Checkpoint, redetected.Checkpoint)
+}
+
+func TestEventStreamCancelWhileSending(t *testing.T) {
+	s := newTestSimulator(t, nil)
+
+	_, _, _, done := startTestStream(t, s, &ffcapi.EventListenerAddRequest{
+		ListenerID: fftypes.NewUUID(),
+	})
+
+	prepareAndSend(t, s, "0xaaaa", 0, "set")
+	s.MineBlock()
+	done()
+}
@@ -0,0 +1,240 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package simulator provides a deterministic in-memory blockchain that implements
+// the full ffcapi.API interface, so that the transaction manager, event streams and
+// transaction handlers can be exercised end-to-end in unit tests without a node.
+package simulator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// Simulator is an in-memory blockchain that can be plugged in anywhere an ffcapi.API
+// connector is required. In addition to the connector interface, it exposes functions
+// for tests to drive block production and script failure conditions.
+type Simulator interface {
+	ffcapi.ExtendedAPI
+
+	// MineBlock produces a new block on the head of the canonical chain, containing
+	// the transactions from the mempool that are ready for execution (in nonce order for each signer)
+	MineBlock() *ffcapi.BlockInfo
+	// Reorg removes the top depth blocks from the canonical chain. Transactions in those blocks are
+	// returned to the mempool, and removed events are delivered to any listener that received events
+	// from the removed blocks. The replacement blocks are created by subsequent calls to MineBlock.
+	Reorg(depth int) error
+	// DropPendingTransaction removes a transaction from the mempool without mining it
+	DropPendingTransaction(txHash string) bool
+	// SetBalance sets the gas token balance of an address
+	SetBalance(address string, balance *big.Int)
+	// SetRevert configures all invocations of the named method to revert with the supplied reason.
+	// An empty reason clears the configuration.
+	SetRevert(methodName string, reason string)
+	// SetDownstreamDown simulates the node becoming unavailable, so that all calls fail with ErrorReasonDownstreamDown
+	SetDownstreamDown(down bool)
+	// BlockNumber returns the number of the block at the head of the canonical chain
+	BlockNumber() uint64
+	// PendingTransactionCount returns the number of transactions waiting in the mempool
+	PendingTransactionCount() int
+	// Close stops any automatic block production
+	Close()
+}
+
+// Options configures the behavior of the simulated chain
+type Options struct {
+	BlockInterval           time.Duration    // when non-zero, a block is mined on this interval (even if it is empty)
+	AutoMine                bool             // when true, a block is mined immediately each time a transaction is accepted into the mempool
+	MaxTransactionsPerBlock int              // zero means unlimited
+	GasPrice                *fftypes.JSONAny // returned from GasPriceEstimate
+	GasEstimate             int64            // returned from GasEstimate, and used by TransactionPrepare when no gas is supplied
+}
+
+const (
+	defaultGasEstimate = 21000
+	defaultGasPrice    = `"1000000000"`
+)
+
+type simulator struct {
+	ctx       context.Context
+	cancelCtx func()
+	options   Options
+	autoDone  chan struct{}
+
+	mux            sync.Mutex
+	down           bool
+	blocks         []*simBlock                     // the canonical chain, indexed by block number
+	blocksByHash   map[string]*simBlock            // canonical blocks only
+	minedTXs       map[string]*minedTX             // canonical transactions only
+	mempool        map[string]map[uint64]*simTX    // signer -> nonce -> transaction
+	nextNonce      map[string]uint64               // signer -> next nonce to be mined
+	balances       map[string]*big.Int             // address -> balance
+	reverts        map[string]string               // method name -> revert reason
+	txSequence     int64                           // arrival order for transactions in the mempool
+	forkCount      int64                           // incremented on each reorg, so replacement blocks get new hashes
+	streams        map[fftypes.UUID]*eventStream   // event streams started by FFTM
+	blockListeners map[fftypes.UUID]*blockListener // block listeners (both standalone, and those associated with streams)
+}
+
+// NewSimulator creates a new simulated chain, with a genesis block at number zero.
+// Automatic block production (if configured) stops when the supplied context is cancelled, or Close is called.
+func NewSimulator(ctx context.Context, options *Options) Simulator {
+	s := &simulator{
+		blocksByHash:   make(map[string]*simBlock),
+		minedTXs:       make(map[string]*minedTX),
+		mempool:        make(map[string]map[uint64]*simTX),
+		nextNonce:      make(map[string]uint64),
+		balances:       make(map[string]*big.Int),
+		reverts:        make(map[string]string),
+		streams:        make(map[fftypes.UUID]*eventStream),
+		blockListeners: make(map[fftypes.UUID]*blockListener),
+	}
+	if options != nil {
+		s.options = *options
+	}
+	if s.options.GasPrice == nil {
+		s.options.GasPrice = fftypes.JSONAnyPtr(defaultGasPrice)
+	}
+	if s.options.GasEstimate <= 0 {
+		s.options.GasEstimate = defaultGasEstimate
+	}
+	s.ctx, s.cancelCtx = context.WithCancel(log.WithLogField(ctx, "role", "simulator"))
+
+	genesis := &simBlock{
+		number:    0,
+		timestamp: fftypes.Now(),
+	}
+	genesis.hash = s.blockHash(genesis)
+	s.blocks = append(s.blocks, genesis)
+	s.blocksByHash[genesis.hash] = genesis
+
+	if s.options.BlockInterval > 0 {
+		s.autoDone = make(chan struct{})
+		go s.blockProducer()
+	}
+	return s
+}
+
+func (s *simulator) blockProducer() {
+	defer close(s.autoDone)
+	ticker := time.NewTicker(s.options.BlockInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.MineBlock()
+		case <-s.ctx.Done():
+			log.L(s.ctx).Debugf("Block producer exiting")
+			return
+		}
+	}
+}
+
+func (s *simulator) Close() {
+	s.cancelCtx()
+	if s.autoDone != nil {
+		<-s.autoDone
+	}
+}
+
+func (s *simulator) SetDownstreamDown(down bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.down = down
+}
+
+func (s *simulator) SetBalance(address string, balance *big.Int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.balances[address] = new(big.Int).Set(balance)
+}
+
+func (s *simulator) SetRevert(methodName string, reason string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if reason == "" {
+		delete(s.reverts, methodName)
+	} else {
+		s.reverts[methodName] = reason
+	}
+}
+
+func (s *simulator) BlockNumber() uint64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.head().number
+}
+
+func (s *simulator) PendingTransactionCount() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	count := 0
+	for _, byNonce := range s.mempool {
+		count += len(byNonce)
+	}
+	return count
+}
+
+// checkDown must be called with the lock held
+func (s *simulator) checkDown(ctx context.Context) (ffcapi.ErrorReason, error) {
+	if s.down {
+		return ffcapi.ErrorReasonDownstreamDown, i18n.NewError(ctx, tmmsgs.MsgSimulatorDownstreamDown)
+	}
+	return "", nil
+}
+
+func (s *simulator) head() *simBlock {
+	return s.blocks[len(s.blocks)-1]
+}
+
+func hashOf(v interface{}) string {
+	b, _ := json.Marshal(v)
+	h := sha256.Sum256(b)
+	return "0x" + hex.EncodeToString(h[:])
+}
+
+func (s *simulator) blockHash(b *simBlock) string {
+	txHashes := make([]string, len(b.transactions))
+	for i, mtx := range b.transactions {
+		txHashes[i] = mtx.hash
+	}
+	return hashOf([]interface{}{b.number, b.parentHash, txHashes, s.forkCount})
+}
+
+func (s *simulator) IsLive(ctx context.Context) (*ffcapi.LiveResponse, ffcapi.ErrorReason, error) {
+	return &ffcapi.LiveResponse{Up: true}, "", nil
+}
+
+func (s *simulator) IsReady(ctx context.Context) (*ffcapi.ReadyResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return &ffcapi.ReadyResponse{
+		Ready:             !s.down,
+		DownstreamDetails: fftypes.JSONAnyPtr(fmt.Sprintf(`{"blockNumber":%d}`, s.head().number)),
+	}, "", nil
+}
@@ -0,0 +1,265 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/httpserver"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/fftm"
+	txRegistry "github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/registry"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/simple"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSimulator(t *testing.T, options *Options) Simulator {
+	s := NewSimulator(context.Background(), options)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestLiveReadyAndDownstreamDown(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx := context.Background()
+
+	live, _, err := s.IsLive(ctx)
+	assert.NoError(t, err)
+	assert.True(t, live.Up)
+
+	ready, _, err := s.IsReady(ctx)
+	assert.NoError(t, err)
+	assert.True(t, ready.Ready)
+	assert.JSONEq(t, `{"blockNumber":0}`, ready.DownstreamDetails.String())
+
+	s.SetDownstreamDown(true)
+	ready, _, err = s.IsReady(ctx)
+	assert.NoError(t, err)
+	assert.False(t, ready.Ready)
+
+	checks := []func() (ffcapi.ErrorReason, error){
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := s.AddressBalance(ctx, &ffcapi.AddressBalanceRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := s.BlockInfoByHash(ctx, &ffcapi.BlockInfoByHashRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := s.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := s.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := s.GasEstimate(ctx, &ffcapi.TransactionInput{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := s.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := s.QueryInvoke(ctx, &ffcapi.QueryInvokeRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := s.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := s.TransactionReceipts(ctx, &ffcapi.TransactionReceiptsRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := s.TransactionByHash(ctx, &ffcapi.TransactionByHashRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := s.TransactionCancel(ctx, &ffcapi.TransactionCancelRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := s.TransactionSimulate(ctx, &ffcapi.TransactionSimulateRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := s.TransactionPrepare(ctx, &ffcapi.TransactionPrepareRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := s.TransactionSend(ctx, &ffcapi.TransactionSendRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := s.DeployContractPrepare(ctx, &ffcapi.ContractDeployPrepareRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := s.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := s.EventListenerAdd(ctx, &ffcapi.EventListenerAddRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := s.EventListenerHWM(ctx, &ffcapi.EventListenerHWMRequest{})
+			return r, err
+		},
+		func() (ffcapi.ErrorReason, error) {
+			_, r, err := s.NewBlockListener(ctx, &ffcapi.NewBlockListenerRequest{})
+			return r, err
+		},
+	}
+	for _, check := range checks {
+		reason, err := check()
+		assert.Regexp(t, "FF21087", err)
+		assert.Equal(t, ffcapi.ErrorReason(ffcapi.ErrorReasonDownstreamDown), reason)
+	}
+
+	s.SetDownstreamDown(false)
+	ready, _, err = s.IsReady(ctx)
+	assert.NoError(t, err)
+	assert.True(t, ready.Ready)
+}
+
+func TestBlockProducer(t *testing.T) {
+	s := newTestSimulator(t, &Options{BlockInterval: 1 * time.Millisecond})
+	for s.BlockNumber() < 3 {
+		time.Sleep(1 * time.Millisecond)
+	}
+	s.Close()
+	blockNumber := s.BlockNumber()
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, blockNumber, s.BlockNumber())
+}
+
+func TestSimulatorWithManager(t *testing.T) {
+	s := newTestSimulator(t, &Options{BlockInterval: 10 * time.Millisecond})
+
+	// Collect events delivered to a webhook
+	webhookEvents := make(chan *apitypes.EventWithContext, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		var batch []*apitypes.EventWithContext
+		b, _ := io.ReadAll(req.Body)
+		err := json.Unmarshal(b, &batch)
+		assert.NoError(t, err)
+		for _, e := range batch {
+			webhookEvents <- e
+		}
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer webhook.Close()
+
+	fftm.InitConfig()
+	txRegistry.RegisterHandler(&simple.TransactionHandlerFactory{})
+	config.Set(tmconfig.TransactionsHandlerName, "simple")
+	config.Set(tmconfig.ConfirmationsRequired, 1)
+	simpleConf := tmconfig.TransactionHandlerBaseConfig.SubSection("simple")
+	simpleConf.SubSection(simple.GasOracleConfig).Set(simple.GasOracleMode, simple.GasOracleModeDisabled)
+	simpleConf.Set(simple.FixedGasPrice, "223344556677")
+	simpleConf.Set(simple.Interval, "10ms")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	port := strings.Split(ln.Addr().String(), ":")[1]
+	ln.Close()
+	tmconfig.APIConfig.Set(httpserver.HTTPConfPort, port)
+	tmconfig.APIConfig.Set(httpserver.HTTPConfAddress, "127.0.0.1")
+	dir, err := os.MkdirTemp("", "ldb_*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	config.Set(tmconfig.PersistenceLevelDBPath, dir)
+
+	m, err := fftm.NewManager(context.Background(), s)
+	assert.NoError(t, err)
+	err = m.Start()
+	assert.NoError(t, err)
+	defer m.Close()
+
+	client := resty.New().SetBaseURL(fmt.Sprintf("http://127.0.0.1:%s", port))
+
+	var es apitypes.EventStream
+	res, err := client.R().
+		SetBody(fftypes.JSONObject{
+			"name":         "stream1",
+			"type":         "webhook",
+			"batchTimeout": "10ms",
+			"webhook":      fftypes.JSONObject{"url": webhook.URL},
+		}).
+		SetResult(&es).
+		Post("/eventstreams")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode(), res.String())
+
+	res, err = client.R().
+		SetBody(fftypes.JSONObject{
+			"name":      "listener1",
+			"fromBlock": "earliest",
+			"filters":   []fftypes.JSONObject{{"event": "set"}},
+		}).
+		Post(fmt.Sprintf("/eventstreams/%s/listeners", es.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode(), res.String())
+
+	var mtx apitypes.ManagedTX
+	res, err = client.R().
+		SetBody(&apitypes.TransactionRequest{
+			Headers: apitypes.RequestHeaders{
+				ID:   "ns1:tx1",
+				Type: apitypes.RequestTypeSendTransaction,
+			},
+			TransactionInput: ffcapi.TransactionInput{
+				TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa", To: "0xcontract"},
+				Method:             fftypes.JSONAnyPtr(`"set"`),
+				Params:             []*fftypes.JSONAny{fftypes.JSONAnyPtr(`"hello"`)},
+			},
+		}).
+		SetResult(&mtx).
+		Post("/")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, res.StatusCode(), res.String())
+
+	for mtx.Status != apitypes.TxStatusSucceeded {
+		time.Sleep(10 * time.Millisecond)
+		_, err = client.R().SetResult(&mtx).Get("/transactions/ns1:tx1")
+		assert.NoError(t, err)
+	}
+	assert.NotEmpty(t, mtx.TransactionHash)
+
+	event := <-webhookEvents
+	assert.Equal(t, mtx.TransactionHash, event.Event.ID.TransactionHash)
+	assert.Equal(t, "set", event.Event.ID.Signature)
+}
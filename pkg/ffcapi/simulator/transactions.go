@@ -0,0 +1,526 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// txPayload is the JSON structure hex encoded into the TransactionData returned from
+// TransactionPrepare and DeployContractPrepare, and decoded again on TransactionSend
+type txPayload struct {
+	Method   string             `json:"method,omitempty"`
+	Params   []*fftypes.JSONAny `json:"params,omitempty"`
+	Deploy   bool               `json:"deploy,omitempty"`
+	Contract *fftypes.JSONAny   `json:"contract,omitempty"`
+}
+
+type simTX struct {
+	hash     string
+	seq      int64
+	from     string
+	to       string
+	nonce    uint64
+	value    *big.Int
+	gas      *big.Int
+	gasPrice *fftypes.JSONAny
+	payload  *txPayload
+}
+
+type minedTX struct {
+	*simTX
+	blockNumber     uint64
+	blockHash       string
+	txIndex         int64
+	success         bool
+	revertReason    string
+	contractAddress string
+	events          []*simEvent
+}
+
+type simEvent struct {
+	address   string
+	signature string
+	data      *fftypes.JSONAny
+}
+
+func methodName(method *fftypes.JSONAny) string {
+	if method == nil {
+		return ""
+	}
+	// We accept either a simple JSON string, or an object with a name (such as an ABI entry)
+	var name string
+	if err := json.Unmarshal(method.Bytes(), &name); err == nil {
+		return name
+	}
+	return method.JSONObjectNowarn().GetString("name")
+}
+
+func encodePayload(p *txPayload) string {
+	b, _ := json.Marshal(p)
+	return "0x" + hex.EncodeToString(b)
+}
+
+func decodePayload(ctx context.Context, data string) (*txPayload, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(data, "0x"))
+	if err != nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgSimulatorInvalidTransactionData, err)
+	}
+	var p txPayload
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgSimulatorInvalidTransactionData, err)
+	}
+	return &p, nil
+}
+
+func contractAddress(from string, nonce uint64) string {
+	return hashOf([]interface{}{from, nonce})[0:42]
+}
+
+func bigOrZero(i *fftypes.FFBigInt) *big.Int {
+	if i == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(i.Int())
+}
+
+// balance must be called with the lock held
+func (s *simulator) balance(address string) *big.Int {
+	if b := s.balances[address]; b != nil {
+		return b
+	}
+	return new(big.Int)
+}
+
+// checkRevert must be called with the lock held
+func (s *simulator) checkRevert(ctx context.Context, method string) (ffcapi.ErrorReason, error) {
+	if reason, ok := s.reverts[method]; ok {
+		return ffcapi.ErrorReasonTransactionReverted, i18n.NewError(ctx, tmmsgs.MsgSimulatorReverted, reason)
+	}
+	return "", nil
+}
+
+// removeFromMempool must be called with the lock held
+func (s *simulator) removeFromMempool(tx *simTX) {
+	byNonce := s.mempool[tx.from]
+	delete(byNonce, tx.nonce)
+	if len(byNonce) == 0 {
+		delete(s.mempool, tx.from)
+	}
+}
+
+// execute must be called with the lock held
+func (s *simulator) execute(block *simBlock, tx *simTX) *minedTX {
+	mtx := &minedTX{
+		simTX:       tx,
+		blockNumber: block.number,
+		txIndex:     int64(len(block.transactions)),
+	}
+	s.nextNonce[tx.from] = tx.nonce + 1
+
+	fromBalance := s.balance(tx.from)
+	switch {
+	case fromBalance.Cmp(tx.value) < 0:
+		mtx.revertReason = "insufficient funds for transfer"
+	case s.reverts[tx.payload.Method] != "":
+		mtx.revertReason = s.reverts[tx.payload.Method]
+	default:
+		mtx.success = true
+	}
+	if !mtx.success {
+		return mtx
+	}
+
+	if tx.value.Sign() > 0 {
+		s.balances[tx.from] = new(big.Int).Sub(fromBalance, tx.value)
+		s.balances[tx.to] = new(big.Int).Add(s.balance(tx.to), tx.value)
+	}
+	if tx.payload.Deploy {
+		mtx.contractAddress = contractAddress(tx.from, tx.nonce)
+	} else if tx.payload.Method != "" {
+		mtx.events = []*simEvent{{
+			address:   tx.to,
+			signature: tx.payload.Method,
+			data:      fftypes.JSONAnyPtr(fftypes.JSONObject{"params": tx.payload.Params}.String()),
+		}}
+	}
+	return mtx
+}
+
+// unexecute must be called with the lock held, reversing execute when a block is removed in a reorg
+func (s *simulator) unexecute(mtx *minedTX) {
+	delete(s.minedTXs, mtx.hash)
+	if mtx.success && mtx.value.Sign() > 0 {
+		s.balances[mtx.to] = new(big.Int).Sub(s.balance(mtx.to), mtx.value)
+		s.balances[mtx.from] = new(big.Int).Add(s.balance(mtx.from), mtx.value)
+	}
+	s.nextNonce[mtx.from] = mtx.nonce
+	byNonce := s.mempool[mtx.from]
+	if byNonce == nil {
+		byNonce = make(map[uint64]*simTX)
+		s.mempool[mtx.from] = byNonce
+	}
+	if byNonce[mtx.nonce] == nil {
+		byNonce[mtx.nonce] = mtx.simTX
+	}
+}
+
+func (s *simulator) TransactionPrepare(ctx context.Context, req *ffcapi.TransactionPrepareRequest) (*ffcapi.TransactionPrepareResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if reason, err := s.checkDown(ctx); err != nil {
+		return nil, reason, err
+	}
+	method := methodName(req.Method)
+	if method == "" {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, tmmsgs.MsgSimulatorInvalidTransactionData, "method name missing")
+	}
+	gas := req.Gas
+	if gas == nil {
+		// Like a real connector, we have to estimate gas if it is not supplied - which might revert
+		if reason, err := s.checkRevert(ctx, method); err != nil {
+			return nil, reason, err
+		}
+		gas = fftypes.NewFFBigInt(s.options.GasEstimate)
+	}
+	return &ffcapi.TransactionPrepareResponse{
+		Gas: gas,
+		TransactionData: encodePayload(&txPayload{
+			Method: method,
+			Params: req.Params,
+		}),
+	}, "", nil
+}
+
+func (s *simulator) DeployContractPrepare(ctx context.Context, req *ffcapi.ContractDeployPrepareRequest) (*ffcapi.TransactionPrepareResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if reason, err := s.checkDown(ctx); err != nil {
+		return nil, reason, err
+	}
+	if req.Contract == nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, tmmsgs.MsgSimulatorInvalidTransactionData, "contract missing")
+	}
+	gas := req.Gas
+	if gas == nil {
+		gas = fftypes.NewFFBigInt(s.options.GasEstimate)
+	}
+	return &ffcapi.TransactionPrepareResponse{
+		Gas: gas,
+		TransactionData: encodePayload(&txPayload{
+			Deploy:   true,
+			Contract: req.Contract,
+			Params:   req.Params,
+		}),
+	}, "", nil
+}
+
+func (s *simulator) TransactionSend(ctx context.Context, req *ffcapi.TransactionSendRequest) (*ffcapi.TransactionSendResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := s.transactionSend(ctx, req)
+	if err == nil && s.options.AutoMine {
+		s.MineBlock()
+	}
+	return res, reason, err
+}
+
+// TransactionCancel replaces the transaction at the nonce with an empty transaction from the signer to itself
+func (s *simulator) TransactionCancel(ctx context.Context, req *ffcapi.TransactionCancelRequest) (*ffcapi.TransactionCancelResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := s.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From:  req.From,
+			To:    req.From,
+			Nonce: req.Nonce,
+			Gas:   req.Gas,
+		},
+		GasPrice:        req.GasPrice,
+		TransactionData: encodePayload(&txPayload{}),
+	})
+	if err != nil {
+		return nil, reason, err
+	}
+	return &ffcapi.TransactionCancelResponse{
+		TransactionHash: res.TransactionHash,
+	}, "", nil
+}
+
+// buildTX decodes a transaction, and calculates the hash it has when submitted with the supplied nonce and gas price
+func (s *simulator) buildTX(ctx context.Context, headers *ffcapi.TransactionHeaders, gasPriceJSON *fftypes.JSONAny, transactionData string) (*simTX, ffcapi.ErrorReason, error) {
+	payload, err := decodePayload(ctx, transactionData)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, err
+	}
+	if headers.Nonce == nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, tmmsgs.MsgSimulatorMissingNonce)
+	}
+
+	tx := &simTX{
+		from:     headers.From,
+		to:       headers.To,
+		nonce:    headers.Nonce.Uint64(),
+		value:    bigOrZero(headers.Value),
+		gas:      bigOrZero(headers.Gas),
+		gasPrice: gasPriceJSON,
+		payload:  payload,
+	}
+	gasPrice := ""
+	if gasPriceJSON != nil {
+		gasPrice = gasPriceJSON.String()
+	}
+	tx.hash = hashOf([]interface{}{tx.from, tx.to, tx.nonce, tx.value.String(), transactionData, gasPrice})
+	return tx, "", nil
+}
+
+func (s *simulator) TransactionSign(ctx context.Context, req *ffcapi.TransactionSignRequest) (*ffcapi.TransactionSignResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if reason, err := s.checkDown(ctx); err != nil {
+		return nil, reason, err
+	}
+	tx, reason, err := s.buildTX(ctx, &req.TransactionHeaders, req.GasPrice, req.TransactionData)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &ffcapi.TransactionSignResponse{
+		TransactionHash: tx.hash,
+	}, "", nil
+}
+
+func (s *simulator) transactionSend(ctx context.Context, req *ffcapi.TransactionSendRequest) (*ffcapi.TransactionSendResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if reason, err := s.checkDown(ctx); err != nil {
+		return nil, reason, err
+	}
+	tx, reason, err := s.buildTX(ctx, &req.TransactionHeaders, req.GasPrice, req.TransactionData)
+	if err != nil {
+		return nil, reason, err
+	}
+
+	if _, mined := s.minedTXs[tx.hash]; mined {
+		return nil, ffcapi.ErrorKnownTransaction, i18n.NewError(ctx, tmmsgs.MsgSimulatorKnownTransaction, tx.hash)
+	}
+	if nextNonce := s.nextNonce[tx.from]; tx.nonce < nextNonce {
+		return nil, ffcapi.ErrorReasonNonceTooLow, i18n.NewError(ctx, tmmsgs.MsgSimulatorNonceTooLow, tx.nonce, tx.from, nextNonce)
+	}
+	byNonce := s.mempool[tx.from]
+	if existing := byNonce[tx.nonce]; existing != nil && existing.hash == tx.hash {
+		return nil, ffcapi.ErrorKnownTransaction, i18n.NewError(ctx, tmmsgs.MsgSimulatorKnownTransaction, tx.hash)
+	}
+	if balance := s.balance(tx.from); tx.value.Sign() > 0 && balance.Cmp(tx.value) < 0 {
+		return nil, ffcapi.ErrorReasonInsufficientFunds, i18n.NewError(ctx, tmmsgs.MsgSimulatorInsufficientFunds, tx.from, balance.String(), tx.value.String())
+	}
+
+	// Any different transaction at the same nonce is replaced
+	if byNonce == nil {
+		byNonce = make(map[uint64]*simTX)
+		s.mempool[tx.from] = byNonce
+	}
+	s.txSequence++
+	tx.seq = s.txSequence
+	byNonce[tx.nonce] = tx
+	log.L(ctx).Debugf("Simulator accepted transaction %s from %s at nonce %d", tx.hash, tx.from, tx.nonce)
+	return &ffcapi.TransactionSendResponse{
+		TransactionHash: tx.hash,
+	}, "", nil
+}
+
+func (s *simulator) DropPendingTransaction(txHash string) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for _, byNonce := range s.mempool {
+		for _, tx := range byNonce {
+			if tx.hash == txHash {
+				s.removeFromMempool(tx)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *simulator) TransactionReceipt(ctx context.Context, req *ffcapi.TransactionReceiptRequest) (*ffcapi.TransactionReceiptResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if reason, err := s.checkDown(ctx); err != nil {
+		return nil, reason, err
+	}
+	mtx := s.minedTXs[req.TransactionHash]
+	if mtx == nil {
+		return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, tmmsgs.MsgSimulatorNotFound, "Receipt", req.TransactionHash)
+	}
+	return mtx.receipt(), "", nil
+}
+
+func (s *simulator) TransactionReceipts(ctx context.Context, req *ffcapi.TransactionReceiptsRequest) (*ffcapi.TransactionReceiptsResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if reason, err := s.checkDown(ctx); err != nil {
+		return nil, reason, err
+	}
+	res := &ffcapi.TransactionReceiptsResponse{
+		Receipts: make(map[string]*ffcapi.TransactionReceiptResponse),
+	}
+	for _, txHash := range req.TransactionHashes {
+		if mtx := s.minedTXs[txHash]; mtx != nil {
+			res.Receipts[txHash] = mtx.receipt()
+		}
+	}
+	return res, "", nil
+}
+
+func (s *simulator) TransactionByHash(ctx context.Context, req *ffcapi.TransactionByHashRequest) (*ffcapi.TransactionByHashResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if reason, err := s.checkDown(ctx); err != nil {
+		return nil, reason, err
+	}
+	if mtx := s.minedTXs[req.TransactionHash]; mtx != nil {
+		return &ffcapi.TransactionByHashResponse{
+			TransactionHash: mtx.hash,
+			State:           ffcapi.TransactionStateMined,
+			BlockNumber:     fftypes.NewFFBigInt(int64(mtx.blockNumber)),
+			BlockHash:       mtx.blockHash,
+		}, "", nil
+	}
+	for _, byNonce := range s.mempool {
+		for _, tx := range byNonce {
+			if tx.hash == req.TransactionHash {
+				return &ffcapi.TransactionByHashResponse{
+					TransactionHash: tx.hash,
+					State:           ffcapi.TransactionStatePending,
+				}, "", nil
+			}
+		}
+	}
+	return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, tmmsgs.MsgSimulatorNotFound, "Transaction", req.TransactionHash)
+}
+
+func (mtx *minedTX) receipt() *ffcapi.TransactionReceiptResponse {
+	res := &ffcapi.TransactionReceiptResponse{
+		BlockNumber:      fftypes.NewFFBigInt(int64(mtx.blockNumber)),
+		TransactionIndex: fftypes.NewFFBigInt(mtx.txIndex),
+		BlockHash:        mtx.blockHash,
+		Success:          mtx.success,
+		ProtocolID:       fmt.Sprintf("%.12d/%.6d", mtx.blockNumber, mtx.txIndex),
+	}
+	if mtx.revertReason != "" {
+		res.ExtraInfo = fftypes.JSONAnyPtr(fftypes.JSONObject{"revertReason": mtx.revertReason}.String())
+	}
+	if mtx.contractAddress != "" {
+		res.ContractLocation = fftypes.JSONAnyPtr(fftypes.JSONObject{"address": mtx.contractAddress}.String())
+	}
+	return res
+}
+
+func (s *simulator) NextNonceForSigner(ctx context.Context, req *ffcapi.NextNonceForSignerRequest) (*ffcapi.NextNonceForSignerResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if reason, err := s.checkDown(ctx); err != nil {
+		return nil, reason, err
+	}
+	// Like the "pending" nonce on a real node, we include consecutive transactions in the mempool
+	nonce := s.nextNonce[req.Signer]
+	for s.mempool[req.Signer][nonce] != nil {
+		nonce++
+	}
+	return &ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(int64(nonce)),
+	}, "", nil
+}
+
+func (s *simulator) AddressBalance(ctx context.Context, req *ffcapi.AddressBalanceRequest) (*ffcapi.AddressBalanceResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if reason, err := s.checkDown(ctx); err != nil {
+		return nil, reason, err
+	}
+	return &ffcapi.AddressBalanceResponse{
+		Balance: (*fftypes.FFBigInt)(new(big.Int).Set(s.balance(req.Address))),
+	}, "", nil
+}
+
+func (s *simulator) GasEstimate(ctx context.Context, req *ffcapi.TransactionInput) (*ffcapi.GasEstimateResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if reason, err := s.checkDown(ctx); err != nil {
+		return nil, reason, err
+	}
+	if reason, err := s.checkRevert(ctx, methodName(req.Method)); err != nil {
+		return nil, reason, err
+	}
+	return &ffcapi.GasEstimateResponse{
+		GasEstimate: fftypes.NewFFBigInt(s.options.GasEstimate),
+	}, "", nil
+}
+
+func (s *simulator) GasPriceEstimate(ctx context.Context, req *ffcapi.GasPriceEstimateRequest) (*ffcapi.GasPriceEstimateResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if reason, err := s.checkDown(ctx); err != nil {
+		return nil, reason, err
+	}
+	return &ffcapi.GasPriceEstimateResponse{
+		GasPrice: s.options.GasPrice,
+	}, "", nil
+}
+
+func (s *simulator) QueryInvoke(ctx context.Context, req *ffcapi.QueryInvokeRequest) (*ffcapi.QueryInvokeResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if reason, err := s.checkDown(ctx); err != nil {
+		return nil, reason, err
+	}
+	if reason, err := s.checkRevert(ctx, methodName(req.Method)); err != nil {
+		return nil, reason, err
+	}
+	// The simulated contract simply echoes back its inputs
+	outputs, _ := json.Marshal(req.Params)
+	return &ffcapi.QueryInvokeResponse{
+		Outputs: fftypes.JSONAnyPtrBytes(outputs),
+	}, "", nil
+}
+
+// TransactionSimulate executes a prepared transaction as a query, so it reverts if its method has been set to revert
+func (s *simulator) TransactionSimulate(ctx context.Context, req *ffcapi.TransactionSimulateRequest) (*ffcapi.TransactionSimulateResponse, ffcapi.ErrorReason, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if reason, err := s.checkDown(ctx); err != nil {
+		return nil, reason, err
+	}
+	payload, err := decodePayload(ctx, req.TransactionData)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, err
+	}
+	if reason, err := s.checkRevert(ctx, payload.Method); err != nil {
+		return nil, reason, err
+	}
+	outputs, _ := json.Marshal(payload.Params)
+	return &ffcapi.TransactionSimulateResponse{
+		Outputs: fftypes.JSONAnyPtrBytes(outputs),
+	}, "", nil
+}
@@ -0,0 +1,451 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func prepareAndSend(t *testing.T, s Simulator, from string, nonce int64, method string) string {
+	ctx := context.Background()
+	prepared, _, err := s.TransactionPrepare(ctx, &ffcapi.TransactionPrepareRequest{
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: ffcapi.TransactionHeaders{From: from, To: "0xcontract"},
+			Method:             fftypes.JSONAnyPtr(`"` + method + `"`),
+			Params:             []*fftypes.JSONAny{fftypes.JSONAnyPtr(`"hello"`)},
+		},
+	})
+	assert.NoError(t, err)
+	res, _, err := s.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From:  from,
+			To:    "0xcontract",
+			Nonce: fftypes.NewFFBigInt(nonce),
+			Gas:   prepared.Gas,
+		},
+		GasPrice:        fftypes.JSONAnyPtr(`"100"`),
+		TransactionData: prepared.TransactionData,
+	})
+	assert.NoError(t, err)
+	return res.TransactionHash
+}
+
+func TestTransactionPrepareSendReceipt(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx := context.Background()
+
+	txHash := prepareAndSend(t, s, "0xaaaa", 0, "set")
+	assert.Equal(t, 1, s.PendingTransactionCount())
+
+	_, reason, err := s.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: txHash})
+	assert.Regexp(t, "FF21093", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+	block := s.MineBlock()
+	assert.Equal(t, []string{txHash}, block.TransactionHashes)
+	assert.Equal(t, 0, s.PendingTransactionCount())
+
+	receipt, _, err := s.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: txHash})
+	assert.NoError(t, err)
+	assert.True(t, receipt.Success)
+	assert.Equal(t, int64(1), receipt.BlockNumber.Int64())
+	assert.Equal(t, block.BlockHash, receipt.BlockHash)
+	assert.Equal(t, "000000000001/000000", receipt.ProtocolID)
+}
+
+func TestTransactionReceiptsBatch(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx := context.Background()
+
+	txHash1 := prepareAndSend(t, s, "0xaaaa", 0, "set")
+	s.MineBlock()
+	txHash2 := prepareAndSend(t, s, "0xaaaa", 1, "set")
+
+	res, _, err := s.TransactionReceipts(ctx, &ffcapi.TransactionReceiptsRequest{
+		TransactionHashes: []string{txHash1, txHash2},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, res.Receipts, 1)
+	assert.True(t, res.Receipts[txHash1].Success)
+	assert.Equal(t, "000000000001/000000", res.Receipts[txHash1].ProtocolID)
+	assert.Nil(t, res.Receipts[txHash2])
+}
+
+func TestTransactionByHash(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx := context.Background()
+
+	txHash1 := prepareAndSend(t, s, "0xaaaa", 0, "set")
+	s.MineBlock()
+	txHash2 := prepareAndSend(t, s, "0xaaaa", 1, "set")
+	txHash3 := prepareAndSend(t, s, "0xaaaa", 2, "set")
+
+	res, _, err := s.TransactionByHash(ctx, &ffcapi.TransactionByHashRequest{TransactionHash: txHash1})
+	assert.NoError(t, err)
+	assert.Equal(t, ffcapi.TransactionStateMined, res.State)
+	assert.Equal(t, int64(1), res.BlockNumber.Int64())
+	assert.NotEmpty(t, res.BlockHash)
+
+	res, _, err = s.TransactionByHash(ctx, &ffcapi.TransactionByHashRequest{TransactionHash: txHash2})
+	assert.NoError(t, err)
+	assert.Equal(t, ffcapi.TransactionStatePending, res.State)
+	assert.Nil(t, res.BlockNumber)
+
+	assert.True(t, s.DropPendingTransaction(txHash3))
+	_, reason, err := s.TransactionByHash(ctx, &ffcapi.TransactionByHashRequest{TransactionHash: txHash3})
+	assert.Regexp(t, "FF21093", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+}
+
+func TestTransactionPrepareMethodObject(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	res, _, err := s.TransactionPrepare(context.Background(), &ffcapi.TransactionPrepareRequest{
+		TransactionInput: ffcapi.TransactionInput{
+			Method: fftypes.JSONAnyPtr(`{"type":"function","name":"set"}`),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(defaultGasEstimate), res.Gas.Int64())
+	p, err := decodePayload(context.Background(), res.TransactionData)
+	assert.NoError(t, err)
+	assert.Equal(t, "set", p.Method)
+}
+
+func TestTransactionPrepareErrors(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx := context.Background()
+
+	_, reason, err := s.TransactionPrepare(ctx, &ffcapi.TransactionPrepareRequest{})
+	assert.Regexp(t, "FF21088", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	s.SetRevert("set", "pop")
+	_, reason, err = s.TransactionPrepare(ctx, &ffcapi.TransactionPrepareRequest{
+		TransactionInput: ffcapi.TransactionInput{Method: fftypes.JSONAnyPtr(`"set"`)},
+	})
+	assert.Regexp(t, "FF21094.*pop", err)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionReverted, reason)
+
+	// Supplying gas skips estimation
+	_, _, err = s.TransactionPrepare(ctx, &ffcapi.TransactionPrepareRequest{
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: ffcapi.TransactionHeaders{Gas: fftypes.NewFFBigInt(100)},
+			Method:             fftypes.JSONAnyPtr(`"set"`),
+		},
+	})
+	assert.NoError(t, err)
+}
+
+func TestTransactionSendErrors(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx := context.Background()
+
+	_, reason, err := s.TransactionSend(ctx, &ffcapi.TransactionSendRequest{TransactionData: "not hex"})
+	assert.Regexp(t, "FF21088", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	_, reason, err = s.TransactionSend(ctx, &ffcapi.TransactionSendRequest{TransactionData: "0x00"})
+	assert.Regexp(t, "FF21088", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	_, reason, err = s.TransactionSend(ctx, &ffcapi.TransactionSendRequest{TransactionData: encodePayload(&txPayload{Method: "set"})})
+	assert.Regexp(t, "FF21089", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	_, reason, err = s.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa", To: "0xbbbb", Nonce: fftypes.NewFFBigInt(0), Value: fftypes.NewFFBigInt(10)},
+		TransactionData:    encodePayload(&txPayload{}),
+	})
+	assert.Regexp(t, "FF21092", err)
+	assert.Equal(t, ffcapi.ErrorReasonInsufficientFunds, reason)
+}
+
+func TestTransactionSignMatchesSend(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx := context.Background()
+
+	headers := ffcapi.TransactionHeaders{From: "0xaaaa", To: "0xcontract", Nonce: fftypes.NewFFBigInt(0)}
+	data := encodePayload(&txPayload{Method: "set"})
+	signed, _, err := s.TransactionSign(ctx, &ffcapi.TransactionSignRequest{
+		TransactionHeaders: headers,
+		GasPrice:           fftypes.JSONAnyPtr(`"100"`),
+		TransactionData:    data,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, s.PendingTransactionCount())
+
+	sent, _, err := s.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		TransactionHeaders: headers,
+		GasPrice:           fftypes.JSONAnyPtr(`"100"`),
+		TransactionData:    data,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, signed.TransactionHash, sent.TransactionHash)
+
+	_, reason, err := s.TransactionSign(ctx, &ffcapi.TransactionSignRequest{TransactionData: data})
+	assert.Regexp(t, "FF21089", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	s.SetDownstreamDown(true)
+	_, reason, err = s.TransactionSign(ctx, &ffcapi.TransactionSignRequest{})
+	assert.Regexp(t, "FF21087", err)
+	assert.Equal(t, ffcapi.ErrorReason(ffcapi.ErrorReasonDownstreamDown), reason)
+}
+
+func TestTransactionSendKnownAndNonceTooLow(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx := context.Background()
+
+	req := &ffcapi.TransactionSendRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa", Nonce: fftypes.NewFFBigInt(0)},
+		TransactionData:    encodePayload(&txPayload{Method: "set"}),
+	}
+	_, _, err := s.TransactionSend(ctx, req)
+	assert.NoError(t, err)
+
+	// Resubmitting the same transaction to the mempool
+	_, reason, err := s.TransactionSend(ctx, req)
+	assert.Regexp(t, "FF21091", err)
+	assert.Equal(t, ffcapi.ErrorKnownTransaction, reason)
+
+	s.MineBlock()
+
+	// Resubmitting the same transaction once mined
+	_, reason, err = s.TransactionSend(ctx, req)
+	assert.Regexp(t, "FF21091", err)
+	assert.Equal(t, ffcapi.ErrorKnownTransaction, reason)
+
+	// A different transaction at the same nonce
+	req.GasPrice = fftypes.JSONAnyPtr(`"12345"`)
+	_, reason, err = s.TransactionSend(ctx, req)
+	assert.Regexp(t, "FF21090", err)
+	assert.Equal(t, ffcapi.ErrorReasonNonceTooLow, reason)
+}
+
+func TestTransactionReplacementAndDrop(t *testing.T) {
+	s := newTestSimulator(t, nil)
+
+	hash1 := prepareAndSend(t, s, "0xaaaa", 0, "set")
+	hash2 := prepareAndSend(t, s, "0xaaaa", 0, "other")
+	assert.NotEqual(t, hash1, hash2)
+	assert.Equal(t, 1, s.PendingTransactionCount())
+
+	assert.False(t, s.DropPendingTransaction(hash1))
+	assert.True(t, s.DropPendingTransaction(hash2))
+	assert.Equal(t, 0, s.PendingTransactionCount())
+
+	block := s.MineBlock()
+	assert.Empty(t, block.TransactionHashes)
+}
+
+func TestTransactionCancel(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx := context.Background()
+
+	txHash := prepareAndSend(t, s, "0xaaaa", 0, "set")
+	res, _, err := s.TransactionCancel(ctx, &ffcapi.TransactionCancelRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa", Nonce: fftypes.NewFFBigInt(0)},
+		GasPrice:           fftypes.JSONAnyPtr(`"200"`),
+	})
+	assert.NoError(t, err)
+	assert.NotEqual(t, txHash, res.TransactionHash)
+	assert.Equal(t, 1, s.PendingTransactionCount())
+
+	block := s.MineBlock()
+	assert.Equal(t, []string{res.TransactionHash}, block.TransactionHashes)
+	receipt, _, err := s.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: res.TransactionHash})
+	assert.NoError(t, err)
+	assert.True(t, receipt.Success)
+
+	_, reason, err := s.TransactionCancel(ctx, &ffcapi.TransactionCancelRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa", Nonce: fftypes.NewFFBigInt(0)},
+	})
+	assert.Regexp(t, "FF21090", err)
+	assert.Equal(t, ffcapi.ErrorReasonNonceTooLow, reason)
+}
+
+func TestMiningNonceOrderAcrossSigners(t *testing.T) {
+	s := newTestSimulator(t, &Options{MaxTransactionsPerBlock: 3})
+	ctx := context.Background()
+
+	// Nonce 1 arrives before nonce 0, so cannot be mined first
+	a1 := prepareAndSend(t, s, "0xaaaa", 1, "set")
+	b0 := prepareAndSend(t, s, "0xbbbb", 0, "set")
+	a0 := prepareAndSend(t, s, "0xaaaa", 0, "set")
+	a3 := prepareAndSend(t, s, "0xaaaa", 3, "set") // gap at nonce 2
+	b1 := prepareAndSend(t, s, "0xbbbb", 1, "set")
+
+	nonce, _, err := s.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{Signer: "0xaaaa"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), nonce.Nonce.Int64())
+
+	block1 := s.MineBlock()
+	assert.Equal(t, []string{b0, a0, a1}, block1.TransactionHashes)
+	block2 := s.MineBlock()
+	assert.Equal(t, []string{b1}, block2.TransactionHashes)
+	assert.Equal(t, 1, s.PendingTransactionCount())
+
+	prepareAndSend(t, s, "0xaaaa", 2, "set")
+	block3 := s.MineBlock()
+	assert.Len(t, block3.TransactionHashes, 2)
+	assert.Equal(t, a3, block3.TransactionHashes[1])
+}
+
+func TestAutoMine(t *testing.T) {
+	s := newTestSimulator(t, &Options{AutoMine: true})
+	txHash := prepareAndSend(t, s, "0xaaaa", 0, "set")
+	assert.Equal(t, uint64(1), s.BlockNumber())
+	_, _, err := s.TransactionReceipt(context.Background(), &ffcapi.TransactionReceiptRequest{TransactionHash: txHash})
+	assert.NoError(t, err)
+}
+
+func TestRevertOnMining(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx := context.Background()
+
+	txHash := prepareAndSend(t, s, "0xaaaa", 0, "set")
+	s.SetRevert("set", "pop")
+	s.MineBlock()
+	s.SetRevert("set", "")
+
+	receipt, _, err := s.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: txHash})
+	assert.NoError(t, err)
+	assert.False(t, receipt.Success)
+	assert.JSONEq(t, `{"revertReason":"pop"}`, receipt.ExtraInfo.String())
+}
+
+func TestDeployContract(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx := context.Background()
+
+	_, reason, err := s.DeployContractPrepare(ctx, &ffcapi.ContractDeployPrepareRequest{})
+	assert.Regexp(t, "FF21088", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	prepared, _, err := s.DeployContractPrepare(ctx, &ffcapi.ContractDeployPrepareRequest{
+		Contract: fftypes.JSONAnyPtr(`"0xfeedbeef"`),
+	})
+	assert.NoError(t, err)
+	res, _, err := s.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa", Nonce: fftypes.NewFFBigInt(0)},
+		TransactionData:    prepared.TransactionData,
+	})
+	assert.NoError(t, err)
+	s.MineBlock()
+
+	receipt, _, err := s.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: res.TransactionHash})
+	assert.NoError(t, err)
+	assert.True(t, receipt.Success)
+	assert.Equal(t, contractAddress("0xaaaa", 0), receipt.ContractLocation.JSONObject().GetString("address"))
+}
+
+func TestValueTransferAndBalance(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx := context.Background()
+
+	s.SetBalance("0xaaaa", big.NewInt(100))
+	_, _, err := s.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa", To: "0xbbbb", Nonce: fftypes.NewFFBigInt(0), Value: fftypes.NewFFBigInt(60)},
+		TransactionData:    encodePayload(&txPayload{}),
+	})
+	assert.NoError(t, err)
+	s.MineBlock()
+
+	balance, _, err := s.AddressBalance(ctx, &ffcapi.AddressBalanceRequest{Address: "0xaaaa"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(40), balance.Balance.Int64())
+	balance, _, err = s.AddressBalance(ctx, &ffcapi.AddressBalanceRequest{Address: "0xbbbb"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(60), balance.Balance.Int64())
+
+	// The balance is restored if the block is removed
+	err = s.Reorg(1)
+	assert.NoError(t, err)
+	balance, _, err = s.AddressBalance(ctx, &ffcapi.AddressBalanceRequest{Address: "0xaaaa"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), balance.Balance.Int64())
+}
+
+func TestInsufficientFundsOnMining(t *testing.T) {
+	s := newTestSimulator(t, nil)
+	ctx := context.Background()
+
+	s.SetBalance("0xaaaa", big.NewInt(100))
+	res, _, err := s.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa", To: "0xbbbb", Nonce: fftypes.NewFFBigInt(0), Value: fftypes.NewFFBigInt(60)},
+		TransactionData:    encodePayload(&txPayload{}),
+	})
+	assert.NoError(t, err)
+	s.SetBalance("0xaaaa", big.NewInt(10))
+	s.MineBlock()
+
+	receipt, _, err := s.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: res.TransactionHash})
+	assert.NoError(t, err)
+	assert.False(t, receipt.Success)
+}
+
+func TestGasAndQuery(t *testing.T) {
+	s := newTestSimulator(t, &Options{GasEstimate: 12345, GasPrice: fftypes.JSONAnyPtr(`{"maxFeePerGas":10}`)})
+	ctx := context.Background()
+
+	gas, _, err := s.GasEstimate(ctx, &ffcapi.TransactionInput{Method: fftypes.JSONAnyPtr(`"set"`)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12345), gas.GasEstimate.Int64())
+
+	gasPrice, _, err := s.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"maxFeePerGas":10}`, gasPrice.GasPrice.String())
+
+	query, _, err := s.QueryInvoke(ctx, &ffcapi.QueryInvokeRequest{
+		TransactionInput: ffcapi.TransactionInput{
+			Method: fftypes.JSONAnyPtr(`"get"`),
+			Params: []*fftypes.JSONAny{fftypes.JSONAnyPtr(`1`), fftypes.JSONAnyPtr(`"two"`)},
+		},
+	})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[1,"two"]`, query.Outputs.String())
+
+	s.SetRevert("get", "pop")
+	_, reason, err := s.QueryInvoke(ctx, &ffcapi.QueryInvokeRequest{
+		TransactionInput: ffcapi.TransactionInput{Method: fftypes.JSONAnyPtr(`"get"`)},
+	})
+	assert.Regexp(t, "FF21094", err)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionReverted, reason)
+	_, reason, err = s.GasEstimate(ctx, &ffcapi.TransactionInput{Method: fftypes.JSONAnyPtr(`"get"`)})
+	assert.Regexp(t, "FF21094", err)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionReverted, reason)
+
+	prepared := encodePayload(&txPayload{Method: "get", Params: []*fftypes.JSONAny{fftypes.JSONAnyPtr(`3`)}})
+	_, reason, err = s.TransactionSimulate(ctx, &ffcapi.TransactionSimulateRequest{TransactionData: prepared})
+	assert.Regexp(t, "FF21094", err)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionReverted, reason)
+
+	s.SetRevert("get", "")
+	simulated, _, err := s.TransactionSimulate(ctx, &ffcapi.TransactionSimulateRequest{TransactionData: prepared})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[3]`, simulated.Outputs.String())
+
+	_, reason, err = s.TransactionSimulate(ctx, &ffcapi.TransactionSimulateRequest{TransactionData: "not hex"})
+	assert.Regexp(t, "FF21088", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+}
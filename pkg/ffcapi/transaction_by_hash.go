@@ -0,0 +1,45 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffcapi
+
+import (
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+)
+
+// TransactionState is the state of a transaction as known to the blockchain node
+type TransactionState string
+
+const (
+	// TransactionStatePending the transaction is in the transaction pool of the node, waiting to be mined
+	TransactionStatePending TransactionState = "pending"
+	// TransactionStateMined the transaction has been included in a block on the canonical chain known to the node
+	TransactionStateMined TransactionState = "mined"
+)
+
+// TransactionByHashRequest looks up a transaction the node knows about, whether pending or mined.
+// If the node does not know the transaction at all, the connector should return ErrorReasonNotFound.
+// Connectors that cannot look up transactions should return ErrorReasonNotSupported.
+type TransactionByHashRequest struct {
+	TransactionHash string `json:"transactionHash"`
+}
+
+type TransactionByHashResponse struct {
+	TransactionHash string            `json:"transactionHash"`
+	State           TransactionState  `json:"state"`
+	BlockNumber     *fftypes.FFBigInt `json:"blockNumber,omitempty"` // only set when mined
+	BlockHash       string            `json:"blockHash,omitempty"`   // only set when mined
+}
@@ -0,0 +1,36 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffcapi
+
+import (
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+)
+
+// TransactionCancelRequest asks the connector to use up the nonce of a transaction that is no longer wanted, by submitting
+// a transaction that does nothing (such as a zero value transfer from the signer to itself) with the same nonce.
+// This fills the gap in the nonce sequence, so later transactions from the same signer can be mined.
+// If the original transaction is still in the transaction pool of the node, the gas price must be high enough to replace it.
+// If the nonce has already been used by a mined transaction, the connector should return ErrorReasonNonceTooLow.
+// Connectors that cannot cancel transactions should return ErrorReasonNotSupported.
+type TransactionCancelRequest struct {
+	TransactionHeaders
+	GasPrice *fftypes.JSONAny `json:"gasPrice,omitempty"`
+}
+
+type TransactionCancelResponse struct {
+	TransactionHash string `json:"transactionHash"`
+}
@@ -33,3 +33,14 @@ type TransactionReceiptResponse struct {
 	ExtraInfo        *fftypes.JSONAny  `json:"extraInfo"`
 	ContractLocation *fftypes.JSONAny  `json:"contractLocation"`
 }
+
+// TransactionReceiptsRequest is an optional batched form of TransactionReceiptRequest, allowing a connector
+// to look up receipts for many transactions in a single round trip to the blockchain node.
+// Connectors that do not implement batching should return ErrorReasonNotSupported.
+type TransactionReceiptsRequest struct {
+	TransactionHashes []string `json:"transactionHashes"`
+}
+
+type TransactionReceiptsResponse struct {
+	Receipts map[string]*TransactionReceiptResponse `json:"receipts"` // keyed by transaction hash - hashes without a receipt yet are omitted
+}
@@ -0,0 +1,36 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffcapi
+
+import (
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+)
+
+// TransactionSignRequest takes exactly the same inputs as TransactionSendRequest, but the connector
+// must only sign the transaction (or otherwise calculate the hash it would have on the blockchain),
+// without submitting it. This allows a policy engine to know the hash of a transaction before
+// sending it, so that it can recover if the outcome of the send is ambiguous (such as a timeout).
+type TransactionSignRequest struct {
+	GasPrice *fftypes.JSONAny `json:"gasPrice,omitempty"` // must be the same gas price that will be used on the TransactionSend
+	TransactionHeaders
+	TransactionData string `json:"transactionData"`
+}
+
+type TransactionSignResponse struct {
+	TransactionHash       string `json:"transactionHash"`                 // the hash the transaction will have once submitted
+	SignedTransactionData string `json:"signedTransactionData,omitempty"` // the signed payload, if the connector performs signing (can be submitted with PreSigned=true)
+}
@@ -0,0 +1,33 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffcapi
+
+import (
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+)
+
+// TransactionSimulateRequest asks the connector to execute a prepared transaction against the current state of the chain,
+// without submitting it. The TransactionData is the pre-encoded data returned by TransactionPrepare.
+// If the transaction would revert, the connector should return ErrorReasonTransactionReverted with the revert reason.
+type TransactionSimulateRequest struct {
+	TransactionHeaders
+	TransactionData string `json:"transactionData"`
+}
+
+type TransactionSimulateResponse struct {
+	Outputs *fftypes.JSONAny `json:"outputs"` // The data output from executing the transaction
+}
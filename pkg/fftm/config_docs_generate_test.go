@@ -26,6 +26,9 @@ import (
 	"testing"
 
 	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/eip1559"
+	txRegistry "github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/registry"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/simple"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -34,6 +37,8 @@ const configDocHeader = "# Configuration reference"
 func TestGenerateConfigDocs(t *testing.T) {
 	// Initialize config of all plugins
 	InitConfig()
+	txRegistry.RegisterHandler(&simple.TransactionHandlerFactory{})
+	txRegistry.RegisterHandler(&eip1559.TransactionHandlerFactory{})
 	f, err := os.Create(filepath.Join("..", "..", "config.md"))
 	assert.NoError(t, err)
 	generatedConfig, err := config.GenerateConfigMarkdown(context.Background(), configDocHeader, config.GetKnownKeys())
@@ -27,6 +27,9 @@ import (
 	"testing"
 
 	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/eip1559"
+	txRegistry "github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/registry"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/simple"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -35,6 +38,8 @@ const configDocHeader = "# Configuration reference"
 func TestConfigDocsUpToDate(t *testing.T) {
 	// Initialize config of all plugins
 	InitConfig()
+	txRegistry.RegisterHandler(&simple.TransactionHandlerFactory{})
+	txRegistry.RegisterHandler(&eip1559.TransactionHandlerFactory{})
 	generatedConfig, err := config.GenerateConfigMarkdown(context.Background(), configDocHeader, config.GetKnownKeys())
 	assert.NoError(t, err)
 	configOnDisk, err := os.ReadFile(filepath.Join("..", "..", "config.md"))
@@ -18,6 +18,7 @@ package fftm
 
 import (
 	"context"
+	"math/big"
 	"net/http"
 	"sync"
 
@@ -37,8 +38,11 @@ import (
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
 	"github.com/hyperledger/firefly-transaction-manager/internal/ws"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi/circuitbreaker"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi/recorder"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler"
 	txRegistry "github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/registry"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/spendpolicy"
 )
 
 type Manager interface {
@@ -57,8 +61,10 @@ type manager struct {
 	persistence      persistence.Persistence
 	richQueryEnabled bool
 
-	connector ffcapi.API
-	toolkit   *txhandler.Toolkit
+	connector      ffcapi.API
+	circuitBreaker circuitbreaker.CircuitBreaker
+	recorder       recorder.Recorder
+	toolkit        *txhandler.Toolkit
 
 	mux               sync.Mutex
 	eventStreams      map[fftypes.UUID]events.Stream
@@ -81,7 +87,26 @@ func InitConfig() {
 
 func NewManager(ctx context.Context, connector ffcapi.API) (Manager, error) {
 	var err error
+	var rec recorder.Recorder
+	if recordingFile := config.GetString(tmconfig.ConnectorRecordingFile); recordingFile != "" {
+		// The recording is of the connector itself, so it sits inside any circuit breaker
+		if rec, err = recorder.NewFileRecorder(ctx, connector, recordingFile); err != nil {
+			return nil, i18n.WrapError(ctx, err, tmmsgs.MsgRecordingFileOpenFailed, recordingFile)
+		}
+		connector = rec
+		defer func() {
+			if err != nil {
+				_ = rec.Close()
+			}
+		}()
+	}
 	m := newManager(ctx, connector)
+	m.recorder = rec
+	if config.GetBool(tmconfig.TransactionsSpendPolicyEnabled) {
+		if err = m.initSpendPolicy(ctx); err != nil {
+			return nil, err
+		}
+	}
 	if err = m.initPersistence(ctx); err != nil {
 		return nil, err
 	}
@@ -102,13 +127,69 @@ func newManager(ctx context.Context, connector ffcapi.API) *manager {
 		metricsManager:    metrics.NewMetricsManager(ctx),
 	}
 	m.toolkit = &txhandler.Toolkit{
-		Connector:      m.connector,
 		MetricsManager: m.metricsManager,
 	}
+	if config.GetBool(tmconfig.ConnectorCircuitBreakerEnabled) {
+		m.initCircuitBreaker(ctx)
+	}
+	m.toolkit.Connector = m.connector
 	m.ctx, m.cancelCtx = context.WithCancel(ctx)
 	return m
 }
 
+// initCircuitBreaker wraps the connector, so that all components share a single view of
+// whether the downstream node is available
+func (m *manager) initCircuitBreaker(ctx context.Context) {
+	m.metricsManager.InitConnectorCircuitBreakerMetrics(ctx)
+	m.circuitBreaker = circuitbreaker.NewCircuitBreaker(ctx, m.connector, &circuitbreaker.Options{
+		FailureThreshold: config.GetInt(tmconfig.ConnectorCircuitBreakerFailureThreshold),
+		ResetTimeout:     config.GetDuration(tmconfig.ConnectorCircuitBreakerResetTimeout),
+		OnStateChange: func(ctx context.Context, oldState, newState circuitbreaker.State) {
+			m.metricsManager.SetConnectorCircuitBreakerOpen(ctx, newState != circuitbreaker.StateClosed)
+		},
+		OnRejected: m.metricsManager.IncConnectorCircuitBreakerRejected,
+	})
+	m.connector = m.circuitBreaker
+	m.toolkit.CircuitBreaker = m.circuitBreaker
+}
+
+// initSpendPolicy creates the spend policy the transaction handler checks new transactions against
+func (m *manager) initSpendPolicy(ctx context.Context) error {
+	options := &spendpolicy.Options{
+		AllowedDestinations: config.GetStringSlice(tmconfig.TransactionsSpendPolicyAllowedDestinations),
+		Window:              config.GetDuration(tmconfig.TransactionsSpendPolicyWindow),
+		OnRejected: func(ctx context.Context, rule spendpolicy.Rule) {
+			m.metricsManager.IncSpendPolicyRejected(ctx, string(rule))
+		},
+	}
+	namespaceSigners := config.GetObject(tmconfig.TransactionsSpendPolicyNamespaceSigners)
+	if len(namespaceSigners) > 0 {
+		options.NamespaceSigners = make(map[string][]string, len(namespaceSigners))
+		for ns := range namespaceSigners {
+			options.NamespaceSigners[ns] = namespaceSigners.GetStringArray(ns)
+		}
+	}
+	for _, limit := range []struct {
+		key   config.RootKey
+		value **big.Int
+	}{
+		{tmconfig.TransactionsSpendPolicyMaxValue, &options.MaxValue},
+		{tmconfig.TransactionsSpendPolicyWindowMaxValue, &options.WindowMaxValue},
+		{tmconfig.TransactionsSpendPolicyWindowMaxGasLimit, &options.WindowMaxGasLimit},
+	} {
+		if s := config.GetString(limit.key); s != "" {
+			v, ok := new(big.Int).SetString(s, 0)
+			if !ok || v.Sign() < 0 {
+				return i18n.NewError(ctx, tmmsgs.MsgSpendPolicyInvalidLimit, limit.key, s)
+			}
+			*limit.value = v
+		}
+	}
+	m.metricsManager.InitSpendPolicyMetrics(ctx)
+	m.toolkit.SpendPolicy = spendpolicy.NewSpendPolicy(options)
+	return nil
+}
+
 func (m *manager) initServices(ctx context.Context) (err error) {
 	m.confirmations = confirmations.NewBlockConfirmationManager(ctx, m.connector, "receipts")
 	m.wsServer = ws.NewWebSocketServer(ctx)
@@ -131,6 +212,7 @@ func (m *manager) initServices(ctx context.Context) (err error) {
 	}
 	m.toolkit.EventHandler = NewManagedTransactionEventHandler(ctx, m.confirmations, m.wsServer, m.txHandler)
 	m.txHandler.Init(ctx, m.toolkit)
+	m.confirmations.SetNewBlockCallback(m.newBlock)
 
 	// metrics service must be initialized after transaction handler
 	// in case the transaction handler has logic in the Init function
@@ -144,6 +226,13 @@ func (m *manager) initServices(ctx context.Context) (err error) {
 	return nil
 }
 
+// newBlock passes on the new highest block from the confirmation manager to the transaction handler, if it acts on new blocks
+func (m *manager) newBlock(blockNumber uint64) {
+	if blockHandler, ok := m.txHandler.(txhandler.BlockHandler); ok {
+		blockHandler.HandleNewBlock(m.ctx, blockNumber)
+	}
+}
+
 func (m *manager) initPersistence(ctx context.Context) (err error) {
 	pType := config.GetString(tmconfig.PersistenceType)
 	nonceStateTimeout := config.GetDuration(tmconfig.TransactionsNonceStateTimeout)
@@ -222,4 +311,7 @@ func (m *manager) Close() {
 		}
 	}
 	m.persistence.Close(m.ctx)
+	if m.recorder != nil {
+		_ = m.recorder.Close()
+	}
 }
@@ -22,11 +22,13 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
+	"path"
 	"strings"
 	"testing"
 
 	"github.com/hyperledger/firefly-common/pkg/config"
 	"github.com/hyperledger/firefly-common/pkg/dbsql"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/httpserver"
 	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
@@ -34,6 +36,7 @@ import (
 	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/txhandlermocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 	txRegistry "github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/registry"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/simple"
@@ -218,6 +221,95 @@ func TestNewManagerBadPersistencePathConfig(t *testing.T) {
 
 }
 
+func TestNewManagerWithRecording(t *testing.T) {
+
+	testManagerCommonInit(t, false)
+	dir := t.TempDir()
+	config.Set(tmconfig.PersistenceLevelDBPath, path.Join(dir, "leveldb"))
+	recordingFile := path.Join(dir, "recording.jsonl")
+	config.Set(tmconfig.ConnectorRecordingFile, recordingFile)
+
+	mca := &ffcapimocks.API{}
+	mca.On("IsLive", mock.Anything).Return(&ffcapi.LiveResponse{Up: true}, ffcapi.ErrorReason(""), nil)
+	mm, err := NewManager(context.Background(), mca)
+	assert.NoError(t, err)
+	m := mm.(*manager)
+	assert.NotNil(t, m.recorder)
+
+	_, _, err = m.toolkit.Connector.IsLive(m.ctx)
+	assert.NoError(t, err)
+	m.Close()
+
+	b, err := os.ReadFile(recordingFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"method":"IsLive"`)
+	mca.AssertExpectations(t)
+
+}
+
+func TestNewManagerBadRecordingFile(t *testing.T) {
+
+	tmconfig.Reset()
+	config.Set(tmconfig.ConnectorRecordingFile, path.Join(t.TempDir(), "missing", "recording.jsonl"))
+
+	_, err := NewManager(context.Background(), nil)
+	assert.Regexp(t, "FF21110", err)
+
+}
+
+func TestNewManagerWithRecordingBadPersistenceConfig(t *testing.T) {
+
+	tmconfig.Reset()
+	config.Set(tmconfig.ConnectorRecordingFile, path.Join(t.TempDir(), "recording.jsonl"))
+	config.Set(tmconfig.PersistenceType, "wrong")
+
+	_, err := NewManager(context.Background(), nil)
+	assert.Error(t, err)
+
+}
+
+func TestNewManagerWithSpendPolicy(t *testing.T) {
+
+	testManagerCommonInit(t, false)
+	config.Set(tmconfig.PersistenceLevelDBPath, t.TempDir())
+	config.Set(tmconfig.TransactionsSpendPolicyEnabled, true)
+	config.Set(tmconfig.TransactionsSpendPolicyNamespaceSigners, map[string]interface{}{
+		"ns1": []interface{}{"0xaaaa"},
+	})
+	config.Set(tmconfig.TransactionsSpendPolicyAllowedDestinations, []string{"0xdddd"})
+	config.Set(tmconfig.TransactionsSpendPolicyMaxValue, "1000")
+	config.Set(tmconfig.TransactionsSpendPolicyWindowMaxValue, "0x2710")
+	config.Set(tmconfig.TransactionsSpendPolicyWindowMaxGasLimit, "500000")
+
+	mm, err := NewManager(context.Background(), &ffcapimocks.API{})
+	assert.NoError(t, err)
+	m := mm.(*manager)
+	defer m.Close()
+	sp := m.toolkit.SpendPolicy
+	assert.NotNil(t, sp)
+
+	newTX := func(id, from string, value int64) *apitypes.ManagedTX {
+		return &apitypes.ManagedTX{ID: id, TransactionHeaders: ffcapi.TransactionHeaders{
+			From: from, To: "0xdddd", Value: fftypes.NewFFBigInt(value),
+		}}
+	}
+	assert.NoError(t, sp.Admit(m.ctx, newTX("ns1:tx1", "0xaaaa", 1000)))
+	assert.Regexp(t, "FF21143", sp.Admit(m.ctx, newTX("ns1:tx2", "0xbbbb", 1000)))
+	assert.Regexp(t, "FF21145", sp.Admit(m.ctx, newTX("ns1:tx3", "0xaaaa", 1001)))
+
+}
+
+func TestNewManagerSpendPolicyBadLimit(t *testing.T) {
+
+	testManagerCommonInit(t, false)
+	config.Set(tmconfig.TransactionsSpendPolicyEnabled, true)
+	config.Set(tmconfig.TransactionsSpendPolicyWindowMaxGasLimit, "-1")
+
+	_, err := NewManager(context.Background(), &ffcapimocks.API{})
+	assert.Regexp(t, "FF21142.*windowMaxGasLimit", err)
+
+}
+
 func TestNewManagerWithLegacyConfiguration(t *testing.T) {
 
 	InitConfig()
@@ -369,6 +461,25 @@ func TestStartTransactionHandlerFail(t *testing.T) {
 
 }
 
+func TestNewBlockPassedToTransactionHandler(t *testing.T) {
+	_, m, close := newTestManager(t)
+	defer close()
+	mth := &txhandlermocks.ExtendedTransactionHandler{}
+	mth.On("HandleNewBlock", m.ctx, uint64(12345)).Return()
+	m.txHandler = mth
+	m.newBlock(12345)
+	mth.AssertExpectations(t)
+}
+
+func TestNewBlockSkippedForTransactionHandler(t *testing.T) {
+	_, m, close := newTestManager(t)
+	defer close()
+	mth := &txhandlermocks.TransactionHandler{}
+	m.txHandler = mth
+	m.newBlock(12345)
+	mth.AssertExpectations(t)
+}
+
 func TestStartBlockListenerFail(t *testing.T) {
 	_, m, close := newTestManagerMockPersistence(t)
 	defer close()
@@ -0,0 +1,100 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/hyperledger/firefly-common/pkg/ffapi"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler"
+)
+
+var postTransactionBatch = func(m *manager) *ffapi.Route {
+	return &ffapi.Route{
+		Name:           "postTransactionBatch",
+		Path:           "/transactions/batch",
+		Method:         http.MethodPost,
+		PathParams:     nil,
+		QueryParams:    nil,
+		Description:    tmmsgs.APIEndpointPostTransactionBatch,
+		JSONInputValue: func() interface{} { return &[]*apitypes.BaseRequest{} },
+		JSONInputSchema: func(_ context.Context, schemaGen ffapi.SchemaGenerator) (*openapi3.SchemaRef, error) {
+			schemas := []*openapi3.SchemaRef{}
+			txRequest, err := schemaGen(&apitypes.TransactionRequest{})
+			if err == nil {
+				schemas = append(schemas, txRequest)
+			}
+			deployRequest, err := schemaGen(&apitypes.ContractDeployRequest{})
+			if err == nil {
+				schemas = append(schemas, deployRequest)
+			}
+			return &openapi3.SchemaRef{
+				Value: &openapi3.Schema{
+					Type: "array",
+					Items: &openapi3.SchemaRef{
+						Value: &openapi3.Schema{
+							AnyOf: schemas,
+						},
+					},
+				},
+			}, err
+		},
+		JSONOutputValue: func() interface{} { return []*apitypes.TransactionBatchResult{} },
+		JSONOutputCodes: []int{http.StatusAccepted},
+		JSONHandler: func(r *ffapi.APIRequest) (output interface{}, err error) {
+			batcher, ok := m.txHandler.(txhandler.TransactionBatcher)
+			if !ok {
+				return nil, i18n.NewError(r.Req.Context(), tmmsgs.MsgTransactionHandlerNotSupported, "HandleNewTransactionBatch")
+			}
+			baseReqs := *r.Input.(*[]*apitypes.BaseRequest)
+			txReqs := make([]*apitypes.TransactionBatchItem, len(baseReqs))
+			for i, baseReq := range baseReqs {
+				if txReqs[i], err = parseBatchItem(r.Req.Context(), baseReq); err != nil {
+					return nil, i18n.NewError(r.Req.Context(), tmmsgs.MsgBatchRequestInvalid, i, err)
+				}
+			}
+			return batcher.HandleNewTransactionBatch(r.Req.Context(), txReqs), nil
+		},
+	}
+}
+
+func parseBatchItem(ctx context.Context, baseReq *apitypes.BaseRequest) (*apitypes.TransactionBatchItem, error) {
+	if baseReq == nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgUnsupportedRequestType, "")
+	}
+	switch baseReq.Headers.Type {
+	case apitypes.RequestTypeSendTransaction:
+		var tReq apitypes.TransactionRequest
+		if err := baseReq.UnmarshalTo(&tReq); err != nil {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidRequestErr, baseReq.Headers.Type, err)
+		}
+		return &apitypes.TransactionBatchItem{Transaction: &tReq}, nil
+	case apitypes.RequestTypeDeploy:
+		var tReq apitypes.ContractDeployRequest
+		if err := baseReq.UnmarshalTo(&tReq); err != nil {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidRequestErr, baseReq.Headers.Type, err)
+		}
+		return &apitypes.TransactionBatchItem{Deploy: &tReq}, nil
+	default:
+		return nil, i18n.NewError(ctx, tmmsgs.MsgUnsupportedRequestType, baseReq.Headers.Type)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/txhandlermocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPostTransactionBatch(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+
+	err := m.Start()
+	assert.NoError(t, err)
+	mth := txhandlermocks.ExtendedTransactionHandler{}
+	mth.On("HandleNewTransactionBatch", mock.Anything, mock.MatchedBy(func(txReqs []*apitypes.TransactionBatchItem) bool {
+		return len(txReqs) == 2 &&
+			txReqs[0].Transaction.Headers.ID == "tx1" &&
+			txReqs[0].Transaction.From == "0xaaaaa" &&
+			txReqs[1].Deploy.Headers.ID == "tx2" &&
+			txReqs[1].Deploy.From == "0xaaaaa"
+	})).Return([]*apitypes.TransactionBatchResult{
+		{ID: "tx1", Transaction: &apitypes.ManagedTX{ID: "tx1"}},
+		{ID: "tx2", Error: "pop"},
+	}).Once()
+	m.txHandler = &mth
+
+	var results []*apitypes.TransactionBatchResult
+	res, err := resty.New().R().
+		SetResult(&results).
+		SetBody(strings.NewReader(`[
+			{"headers": {"id": "tx1", "type": "SendTransaction"}, "from": "0xaaaaa"},
+			{"headers": {"id": "tx2", "type": "DeployContract"}, "from": "0xaaaaa"}
+		]`)).
+		Post(url + "/transactions/batch")
+	assert.NoError(t, err)
+	assert.Equal(t, 202, res.StatusCode())
+	assert.Len(t, results, 2)
+	assert.Equal(t, "tx1", results[0].Transaction.ID)
+	assert.Equal(t, "pop", results[1].Error)
+	mth.AssertExpectations(t)
+}
+
+func TestPostTransactionBatchInvalid(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	for _, body := range []string{
+		`[{"headers": {"type": "SendTransaction"}}, {"headers": {"type": "SendTransaction"}, "from": {"Not": "a string"}}]`,
+		`[{"headers": {"type": "SendTransaction"}}, {"headers": {"type": "DeployContract"}, "from": {"Not": "a string"}}]`,
+		`[{"headers": {"type": "SendTransaction"}}, {"headers": {"type": "Query"}}]`,
+		`[{"headers": {"type": "SendTransaction"}}, null]`,
+	} {
+		var errRes fftypes.RESTError
+		res, err := resty.New().R().
+			SetBody(strings.NewReader(body)).
+			SetError(&errRes).
+			Post(url + "/transactions/batch")
+		assert.NoError(t, err)
+		assert.Equal(t, 400, res.StatusCode())
+		assert.Regexp(t, "FF21141.*1", errRes.Error)
+	}
+}
+
+func TestPostTransactionBatchNotSupported(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+
+	err := m.Start()
+	assert.NoError(t, err)
+	mth := txhandlermocks.TransactionHandler{}
+	m.txHandler = &mth
+
+	var errRes fftypes.RESTError
+	res, err := resty.New().R().
+		SetBody(strings.NewReader(`[{"headers": {"type": "SendTransaction"}, "from": "0xaaaaa"}]`)).
+		SetError(&errRes).
+		Post(url + "/transactions/batch")
+	assert.NoError(t, err)
+	assert.Equal(t, 501, res.StatusCode())
+	assert.Regexp(t, "FF21153.*HandleNewTransactionBatch", errRes.Error)
+	mth.AssertExpectations(t)
+}
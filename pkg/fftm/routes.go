@@ -53,5 +53,8 @@ func (m *manager) routes() []*ffapi.Route {
 		getGasPrice(m),
 		postTransactionSuspend(m),
 		postTransactionResume(m),
+		patchTransaction(m),
+		postTransactionCancel(m),
+		postTransactionBatch(m),
 	}
 }
@@ -40,6 +40,9 @@ func (m *manager) getReadyStatus(ctx context.Context) (resp *apitypes.ReadyStatu
 	status, _, err := m.connector.IsReady(ctx)
 	if err == nil {
 		resp.ReadyResponse = *status
+		if m.circuitBreaker != nil {
+			resp.CircuitBreaker = m.circuitBreaker.Status()
+		}
 	} else {
 		log.L(ctx).Warnf("Failed to fetch ready status: %s", err)
 		return nil, err
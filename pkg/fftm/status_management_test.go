@@ -17,12 +17,16 @@
 package fftm
 
 import (
+	"context"
 	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
-	"testing"
 )
 
 func TestGetLiveStatusError(t *testing.T) {
@@ -50,3 +54,28 @@ func TestGetReadyStatusError(t *testing.T) {
 
 	mfc.AssertExpectations(t)
 }
+
+func TestGetReadyStatusCircuitBreaker(t *testing.T) {
+	tmconfig.Reset()
+	config.Set(tmconfig.MetricsEnabled, true)
+	config.Set(tmconfig.ConnectorCircuitBreakerEnabled, true)
+	config.Set(tmconfig.ConnectorCircuitBreakerFailureThreshold, 1)
+
+	mfc := &ffcapimocks.API{}
+	mfc.On("IsReady", mock.Anything).Return(&ffcapi.ReadyResponse{Ready: false}, ffcapi.ErrorReason(""), nil)
+	m := newManager(context.Background(), mfc)
+	assert.Equal(t, m.circuitBreaker, m.toolkit.Connector)
+	assert.Equal(t, m.circuitBreaker, m.toolkit.CircuitBreaker)
+
+	status, err := m.getReadyStatus(m.ctx)
+	assert.NoError(t, err)
+	assert.False(t, status.Ready)
+	assert.Equal(t, "open", status.CircuitBreaker.State)
+
+	// Calls from all components are now rejected
+	_, _, err = m.connector.GasPriceEstimate(m.ctx, &ffcapi.GasPriceEstimateRequest{})
+	assert.Regexp(t, "FF21105", err)
+	assert.True(t, m.toolkit.CircuitBreaker.IsOpen())
+
+	mfc.AssertExpectations(t)
+}
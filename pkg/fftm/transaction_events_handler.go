@@ -115,6 +115,7 @@ func (eh *ManagedTransactionEventHandler) sendWSReply(mtx *apitypes.ManagedTX, r
 		wsr.Headers.Type = apitypes.TransactionUpdateSuccess
 	case apitypes.TxStatusFailed:
 		wsr.Headers.Type = apitypes.TransactionUpdateFailure
+		wsr.ErrorMessage = mtx.ErrorMessage
 	}
 	// Notify on the websocket - this is best-effort (there is no subscription/acknowledgement)
 	eh.WsServer.SendReply(wsr)
@@ -79,6 +79,7 @@ func TestHandleTransactionProcessFailEvent(t *testing.T) {
 			Nonce: fftypes.NewFFBigInt(1),
 		},
 		TransactionHash: "0x1111",
+		ErrorMessage:    "pop",
 	}
 	receipt := &ffcapi.TransactionReceiptResponse{
 		ProtocolID:       fmt.Sprintf("%.12d/%.6d", fftypes.NewFFBigInt(12345).Int64(), fftypes.NewFFBigInt(10).Int64()),
@@ -89,6 +90,7 @@ func TestHandleTransactionProcessFailEvent(t *testing.T) {
 	mws.On("SendReply", mock.MatchedBy(func(r *apitypes.TransactionUpdateReply) bool {
 		return r.Headers.RequestID == testTx.ID &&
 			r.Headers.Type == apitypes.TransactionUpdateFailure &&
+			r.ErrorMessage == testTx.ErrorMessage &&
 			r.Status == testTx.Status &&
 			r.TransactionHash == testTx.TransactionHash &&
 			r.ContractLocation == receipt.ContractLocation &&
@@ -26,6 +26,7 @@ import (
 	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler"
 )
 
 func (m *manager) getTransactionByIDWithStatus(ctx context.Context, txID string, withHistory bool) (transaction *apitypes.TXWithStatus, err error) {
@@ -120,3 +121,37 @@ func (m *manager) requestTransactionResume(ctx context.Context, txID string) (st
 	return http.StatusAccepted, canceledTx, nil
 
 }
+
+func (m *manager) requestTransactionUpdate(ctx context.Context, txID string, update *apitypes.TransactionUpdateRequest) (status int, transaction *apitypes.ManagedTX, err error) {
+
+	replacer, ok := m.txHandler.(txhandler.TransactionReplacer)
+	if !ok {
+		return http.StatusNotImplemented, nil, i18n.NewError(ctx, tmmsgs.MsgTransactionHandlerNotSupported, "HandleUpdateTransaction")
+	}
+
+	updatedTx, err := replacer.HandleUpdateTransaction(ctx, txID, update)
+
+	if err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+
+	return http.StatusOK, updatedTx, nil
+
+}
+
+func (m *manager) requestTransactionCancel(ctx context.Context, txID string) (status int, transaction *apitypes.ManagedTX, err error) {
+
+	replacer, ok := m.txHandler.(txhandler.TransactionReplacer)
+	if !ok {
+		return http.StatusNotImplemented, nil, i18n.NewError(ctx, tmmsgs.MsgTransactionHandlerNotSupported, "HandleCancelByReplacement")
+	}
+
+	canceledTx, err := replacer.HandleCancelByReplacement(ctx, txID)
+
+	if err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+
+	return http.StatusOK, canceledTx, nil
+
+}
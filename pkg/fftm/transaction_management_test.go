@@ -23,6 +23,7 @@ import (
 
 	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/txhandlermocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -92,3 +93,20 @@ func TestDeleteTransactionError(t *testing.T) {
 	mth.AssertExpectations(t)
 
 }
+
+func TestTransactionReplacementNotSupported(t *testing.T) {
+	_, m, done := newTestManager(t)
+	defer done()
+	mth := &txhandlermocks.TransactionHandler{}
+	m.txHandler = mth
+
+	status, _, err := m.requestTransactionUpdate(m.ctx, "1234", &apitypes.TransactionUpdateRequest{})
+	assert.Equal(t, http.StatusNotImplemented, status)
+	assert.Regexp(t, "FF21153.*HandleUpdateTransaction", err)
+
+	status, _, err = m.requestTransactionCancel(m.ctx, "1234")
+	assert.Equal(t, http.StatusNotImplemented, status)
+	assert.Regexp(t, "FF21153.*HandleCancelByReplacement", err)
+
+	mth.AssertExpectations(t)
+}
@@ -0,0 +1,138 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eip1559
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// blockListener samples the fees each time there is a new head block, until the context is closed
+func (eth *eip1559TransactionHandler) blockListener(ctx context.Context, blocks <-chan *ffcapi.BlockHashEvent) {
+	defer close(eth.blockListenerDone)
+	for {
+		select {
+		case <-ctx.Done():
+			log.L(ctx).Debugf("EIP-1559 block listener exiting")
+			return
+		case bhe := <-blocks:
+			// Only the most recent block is needed, as any earlier blocks we have not sampled are fetched by number
+			if len(bhe.BlockHashes) > 0 {
+				eth.sampleBlock(ctx, bhe.BlockHashes[len(bhe.BlockHashes)-1])
+			}
+		}
+	}
+}
+
+// sampleBlock records the base fee of the new head block, and of any of the blocks before it that we
+// have not sampled (such as on startup), along with the current priority fee
+func (eth *eip1559TransactionHandler) sampleBlock(ctx context.Context, blockHash string) {
+	block, _, err := eth.toolkit.Connector.BlockInfoByHash(ctx, &ffcapi.BlockInfoByHashRequest{
+		BlockHash: blockHash,
+	})
+	if err != nil {
+		log.L(ctx).Warnf("Unable to sample the fees of block %s: %s", blockHash, err)
+		return
+	}
+	head := block.BlockNumber.Uint64()
+	eth.recordBaseFee(head, &block.BlockInfo)
+
+	// There is no point looking for base fees in earlier blocks if the head block does not have one
+	if block.BaseFeePerGas != nil {
+		for _, blockNumber := range eth.missingBlocks(head) {
+			res, _, err := eth.toolkit.Connector.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{
+				BlockNumber: fftypes.NewFFBigInt(int64(blockNumber)),
+			})
+			if err != nil {
+				log.L(ctx).Warnf("Unable to sample the fees of block %d: %s", blockNumber, err)
+				break
+			}
+			eth.recordBaseFee(blockNumber, &res.BlockInfo)
+		}
+	}
+
+	estimate, _, err := eth.toolkit.Connector.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+	if err != nil {
+		log.L(ctx).Warnf("Unable to sample the priority fee at block %d: %s", head, err)
+		return
+	}
+	if priorityFee := priorityFeeFromEstimate(estimate.GasPrice, block.BaseFeePerGas); priorityFee != nil {
+		eth.recordPriorityFee(priorityFee)
+	}
+}
+
+// missingBlocks discards the samples outside of the window of blocks ending at the head, and returns
+// the numbers of the blocks in the window that have not been sampled
+func (eth *eip1559TransactionHandler) missingBlocks(head uint64) []uint64 {
+	eth.mux.Lock()
+	defer eth.mux.Unlock()
+	// This includes samples from above the head after a re-org
+	for blockNumber := range eth.baseFees {
+		if blockNumber > head || head-blockNumber >= uint64(eth.blockSamples) {
+			delete(eth.baseFees, blockNumber)
+		}
+	}
+	missing := []uint64{}
+	for i := uint64(1); i < uint64(eth.blockSamples) && i <= head; i++ {
+		if _, ok := eth.baseFees[head-i]; !ok {
+			missing = append(missing, head-i)
+		}
+	}
+	return missing
+}
+
+func (eth *eip1559TransactionHandler) recordBaseFee(blockNumber uint64, blockInfo *ffcapi.BlockInfo) {
+	if blockInfo.BaseFeePerGas == nil {
+		// The connector does not return base fees, or the block is from before EIP-1559
+		return
+	}
+	eth.mux.Lock()
+	defer eth.mux.Unlock()
+	eth.baseFees[blockNumber] = blockInfo.BaseFeePerGas.Int()
+}
+
+func (eth *eip1559TransactionHandler) recordPriorityFee(priorityFee *big.Int) {
+	eth.mux.Lock()
+	defer eth.mux.Unlock()
+	eth.priorityFees = append(eth.priorityFees, priorityFee)
+	if len(eth.priorityFees) > eth.blockSamples {
+		eth.priorityFees = eth.priorityFees[len(eth.priorityFees)-eth.blockSamples:]
+	}
+}
+
+// priorityFeeFromEstimate returns the maxPriorityFeePerGas if the connector estimates EIP-1559 fees, or the
+// amount a legacy gas price is above the base fee. Returns nil if neither is available.
+func priorityFeeFromEstimate(gasPrice *fftypes.JSONAny, baseFee *fftypes.FFBigInt) *big.Int {
+	var fees eip1559Fees
+	if err := json.Unmarshal(gasPrice.Bytes(), &fees); err == nil && fees.MaxPriorityFeePerGas != nil {
+		return fees.MaxPriorityFeePerGas.Int()
+	}
+	var legacyGasPrice fftypes.FFBigInt
+	if err := json.Unmarshal(gasPrice.Bytes(), &legacyGasPrice); err != nil || baseFee == nil {
+		return nil
+	}
+	priorityFee := new(big.Int).Sub(legacyGasPrice.Int(), baseFee.Int())
+	if priorityFee.Sign() < 0 {
+		priorityFee.SetInt64(0)
+	}
+	return priorityFee
+}
@@ -0,0 +1,169 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eip1559
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func blockInfo(blockNumber int64, baseFee int64) ffcapi.BlockInfo {
+	bi := ffcapi.BlockInfo{
+		BlockNumber: fftypes.NewFFBigInt(blockNumber),
+		BlockHash:   fmt.Sprintf("0x%064x", blockNumber),
+	}
+	if baseFee >= 0 {
+		bi.BaseFeePerGas = fftypes.NewFFBigInt(baseFee)
+	}
+	return bi
+}
+
+func TestStartSamplesBlocks(t *testing.T) {
+	_, conf := newTestTransactionHandlerConfig(t)
+	conf.Set(BlockSamples, 3)
+	eth, mockFFCAPI, mockPersistence := newTestTransactionHandler(t, conf)
+
+	var blocks chan<- *ffcapi.BlockHashEvent
+	mockFFCAPI.On("NewBlockListener", mock.Anything, mock.Anything).Return(&ffcapi.NewBlockListenerResponse{}, ffcapi.ErrorReason(""), nil).Run(func(args mock.Arguments) {
+		blocks = args[1].(*ffcapi.NewBlockListenerRequest).BlockListener
+	}).Once()
+	mockFFCAPI.On("BlockInfoByHash", mock.Anything, &ffcapi.BlockInfoByHashRequest{BlockHash: "0x0b"}).Return(&ffcapi.BlockInfoByHashResponse{
+		BlockInfo: blockInfo(10, 100),
+	}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("BlockInfoByNumber", mock.Anything, &ffcapi.BlockInfoByNumberRequest{BlockNumber: fftypes.NewFFBigInt(9)}).Return(&ffcapi.BlockInfoByNumberResponse{
+		BlockInfo: blockInfo(9, 90),
+	}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("BlockInfoByNumber", mock.Anything, &ffcapi.BlockInfoByNumberRequest{BlockNumber: fftypes.NewFFBigInt(8)}).Return(&ffcapi.BlockInfoByNumberResponse{
+		BlockInfo: blockInfo(8, 80),
+	}, ffcapi.ErrorReason(""), nil)
+	sampled := make(chan struct{})
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`"105"`),
+	}, ffcapi.ErrorReason(""), nil).Run(func(args mock.Arguments) {
+		close(sampled)
+	})
+	mockPersistence.On("ListTransactionsPending", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*apitypes.ManagedTX{}, nil).Maybe()
+
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	done, err := eth.Start(ctx)
+	assert.NoError(t, err)
+	// Starting again does not create another block listener
+	_, err = eth.Start(ctx)
+	assert.NoError(t, err)
+
+	blocks <- &ffcapi.BlockHashEvent{}
+	blocks <- &ffcapi.BlockHashEvent{BlockHashes: []string{"0x0a", "0x0b"}}
+	<-sampled
+
+	cancelCtx()
+	<-done
+	<-eth.blockListenerDone
+
+	assert.Len(t, eth.baseFees, 3)
+	assert.Equal(t, int64(80), eth.baseFees[8].Int64())
+	assert.Equal(t, []*big.Int{big.NewInt(5)}, eth.priorityFees)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestStartBlockListenerFail(t *testing.T) {
+	_, conf := newTestTransactionHandlerConfig(t)
+	eth, mockFFCAPI, _ := newTestTransactionHandler(t, conf)
+
+	mockFFCAPI.On("NewBlockListener", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	_, err := eth.Start(context.Background())
+	assert.Regexp(t, "pop", err)
+	assert.Nil(t, eth.blockListenerDone)
+}
+
+func TestSampleBlockErrors(t *testing.T) {
+	_, conf := newTestTransactionHandlerConfig(t)
+	conf.Set(BlockSamples, 5)
+	eth, mockFFCAPI, _ := newTestTransactionHandler(t, conf)
+	ctx := context.Background()
+
+	mockFFCAPI.On("BlockInfoByHash", mock.Anything, &ffcapi.BlockInfoByHashRequest{BlockHash: "0x01"}).Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found"))
+	eth.sampleBlock(ctx, "0x01")
+	assert.Empty(t, eth.baseFees)
+
+	// No base fee, so we do not look at earlier blocks - and cannot derive a priority fee from a legacy gas price
+	mockFFCAPI.On("BlockInfoByHash", mock.Anything, &ffcapi.BlockInfoByHashRequest{BlockHash: "0x02"}).Return(&ffcapi.BlockInfoByHashResponse{
+		BlockInfo: blockInfo(2, -1),
+	}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`12345`),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	eth.sampleBlock(ctx, "0x02")
+	assert.Empty(t, eth.baseFees)
+	assert.Empty(t, eth.priorityFees)
+
+	// Failure fetching an earlier block stops us looking, and failure to estimate means no priority fee sample
+	mockFFCAPI.On("BlockInfoByHash", mock.Anything, &ffcapi.BlockInfoByHashRequest{BlockHash: "0x03"}).Return(&ffcapi.BlockInfoByHashResponse{
+		BlockInfo: blockInfo(3, 30),
+	}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("BlockInfoByNumber", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	eth.sampleBlock(ctx, "0x03")
+	assert.Len(t, eth.baseFees, 1)
+	assert.Empty(t, eth.priorityFees)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestMissingBlocks(t *testing.T) {
+	_, conf := newTestTransactionHandlerConfig(t)
+	conf.Set(BlockSamples, 4)
+	eth, _, _ := newTestTransactionHandler(t, conf)
+
+	// Near the start of the chain
+	assert.Equal(t, []uint64{1, 0}, eth.missingBlocks(2))
+
+	// Samples outside of the window are discarded, including those above the head after a re-org
+	eth.baseFees = map[uint64]*big.Int{5: big.NewInt(1), 7: big.NewInt(1), 9: big.NewInt(1), 10: big.NewInt(1), 11: big.NewInt(1)}
+	assert.Equal(t, []uint64{8}, eth.missingBlocks(10))
+	assert.Len(t, eth.baseFees, 3)
+	assert.Nil(t, eth.baseFees[11])
+}
+
+func TestRecordPriorityFee(t *testing.T) {
+	_, conf := newTestTransactionHandlerConfig(t)
+	conf.Set(BlockSamples, 2)
+	eth, _, _ := newTestTransactionHandler(t, conf)
+
+	for i := int64(1); i <= 3; i++ {
+		eth.recordPriorityFee(big.NewInt(i))
+	}
+	assert.Equal(t, []*big.Int{big.NewInt(2), big.NewInt(3)}, eth.priorityFees)
+}
+
+func TestPriorityFeeFromEstimate(t *testing.T) {
+	baseFee := fftypes.NewFFBigInt(100)
+	assert.Equal(t, int64(7), priorityFeeFromEstimate(fftypes.JSONAnyPtr(`{"maxFeePerGas":200,"maxPriorityFeePerGas":"7"}`), baseFee).Int64())
+	assert.Equal(t, int64(20), priorityFeeFromEstimate(fftypes.JSONAnyPtr(`"0x78"`), baseFee).Int64())
+	assert.Zero(t, priorityFeeFromEstimate(fftypes.JSONAnyPtr(`90`), baseFee).Sign())
+	assert.Nil(t, priorityFeeFromEstimate(fftypes.JSONAnyPtr(`{"gasPrice":90}`), baseFee))
+	assert.Nil(t, priorityFeeFromEstimate(fftypes.JSONAnyPtr(`90`), nil))
+}
@@ -0,0 +1,59 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eip1559
+
+import (
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/simple"
+)
+
+const (
+	BlockSamples = "blockSamples" // the number of recent blocks the base fee and priority fee are sampled from
+
+	BaseFeePercentile       = "baseFee.percentile"       // the percentile of the sampled base fees to use
+	BaseFeeMaxFeePercentage = "baseFee.maxFeePercentage" // the percentage of the base fee allowed for in maxFeePerGas, on top of the priority fee
+
+	PriorityFeePercentile           = "priorityFee.percentile"           // the percentile of the sampled priority fees to use
+	PriorityFeeMinimum              = "priorityFee.minimum"              // the minimum priority fee, also used before any have been sampled
+	PriorityFeeMaximum              = "priorityFee.maximum"              // the maximum priority fee, including when escalated
+	PriorityFeeEscalationPercentage = "priorityFee.escalationPercentage" // percentage increase in the fees for each stale resubmission (0 to disable)
+
+	MaxFeePerGas = "maxFeePerGas" // the maximum maxFeePerGas, including when escalated
+)
+
+const (
+	defaultBlockSamples                    = 20
+	defaultBaseFeePercentile               = 50
+	defaultBaseFeeMaxFeePercentage         = 200
+	defaultPriorityFeePercentile           = 50
+	defaultPriorityFeeMinimum              = "0"
+	defaultPriorityFeeEscalationPercentage = 10
+)
+
+// InitConfig adds the configuration of the simple transaction handler this handler is built on, along with its own
+func (f *TransactionHandlerFactory) InitConfig(conf config.Section) {
+	(&simple.TransactionHandlerFactory{}).InitConfig(conf)
+
+	conf.AddKnownKey(BlockSamples, defaultBlockSamples)
+	conf.AddKnownKey(BaseFeePercentile, defaultBaseFeePercentile)
+	conf.AddKnownKey(BaseFeeMaxFeePercentage, defaultBaseFeeMaxFeePercentage)
+	conf.AddKnownKey(PriorityFeePercentile, defaultPriorityFeePercentile)
+	conf.AddKnownKey(PriorityFeeMinimum, defaultPriorityFeeMinimum)
+	conf.AddKnownKey(PriorityFeeMaximum)
+	conf.AddKnownKey(PriorityFeeEscalationPercentage, defaultPriorityFeeEscalationPercentage)
+	conf.AddKnownKey(MaxFeePerGas)
+}
@@ -0,0 +1,248 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eip1559
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/simple"
+)
+
+type TransactionHandlerFactory struct{}
+
+func (f *TransactionHandlerFactory) Name() string {
+	return "eip1559"
+}
+
+// eip1559TransactionHandler extends the simple transaction handler with an EIP-1559 gas model:
+// - It samples the base fee of recent blocks, and the priority fee from the gas price estimate of the connector
+// - It submits transactions with a maxFeePerGas and maxPriorityFeePerGas calculated from configured percentiles of the samples
+// - It escalates both fees each time a stale transaction is resubmitted
+func (f *TransactionHandlerFactory) NewTransactionHandler(ctx context.Context, conf config.Section) (txhandler.TransactionHandler, error) {
+	eth := &eip1559TransactionHandler{
+		blockSamples:                    conf.GetInt(BlockSamples),
+		baseFeePercentile:               conf.GetInt(BaseFeePercentile),
+		baseFeeMaxFeePercentage:         conf.GetInt(BaseFeeMaxFeePercentage),
+		priorityFeePercentile:           conf.GetInt(PriorityFeePercentile),
+		priorityFeeEscalationPercentage: conf.GetInt(PriorityFeeEscalationPercentage),
+		baseFees:                        make(map[uint64]*big.Int),
+	}
+	if eth.blockSamples < 1 {
+		eth.blockSamples = 1
+	}
+	for name, percentile := range map[string]int{BaseFeePercentile: eth.baseFeePercentile, PriorityFeePercentile: eth.priorityFeePercentile} {
+		if percentile < 0 || percentile > 100 {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgEIP1559InvalidPercentile, name, percentile)
+		}
+	}
+	var err error
+	if eth.minPriorityFee, err = parseFee(ctx, conf, PriorityFeeMinimum); err != nil {
+		return nil, err
+	}
+	if eth.minPriorityFee == nil {
+		eth.minPriorityFee = new(big.Int)
+	}
+	if eth.maxPriorityFee, err = parseFee(ctx, conf, PriorityFeeMaximum); err != nil {
+		return nil, err
+	}
+	if eth.maxFeePerGas, err = parseFee(ctx, conf, MaxFeePerGas); err != nil {
+		return nil, err
+	}
+
+	// The simple transaction handler does all the work other than calculating the fees
+	eth.ExtendedTransactionHandler, err = simple.NewTransactionHandlerWithGasPricer(ctx, conf, eth)
+	if err != nil {
+		return nil, err
+	}
+	return eth, nil
+}
+
+// parseFee returns the fee in the configuration, or nil if it is not set
+func parseFee(ctx context.Context, conf config.Section, key string) (*big.Int, error) {
+	s := conf.GetString(key)
+	if s == "" {
+		return nil, nil
+	}
+	fee, ok := new(big.Int).SetString(s, 0)
+	if !ok || fee.Sign() < 0 {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgEIP1559InvalidFee, key, s)
+	}
+	return fee, nil
+}
+
+type eip1559TransactionHandler struct {
+	txhandler.ExtendedTransactionHandler // the simple transaction handler, using this handler as its gas pricer
+
+	toolkit *txhandler.Toolkit
+
+	blockSamples                    int
+	baseFeePercentile               int
+	baseFeeMaxFeePercentage         int
+	priorityFeePercentile           int
+	priorityFeeEscalationPercentage int
+	minPriorityFee                  *big.Int
+	maxPriorityFee                  *big.Int // nil for no maximum
+	maxFeePerGas                    *big.Int // nil for no maximum
+
+	mux               sync.Mutex
+	baseFees          map[uint64]*big.Int // the base fees of the recent blocks, by block number
+	priorityFees      []*big.Int          // the recent priority fee samples, oldest first
+	blockListenerDone chan struct{}
+}
+
+// eip1559Fees is the gas price passed to the connector
+type eip1559Fees struct {
+	MaxFeePerGas         *fftypes.FFBigInt `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *fftypes.FFBigInt `json:"maxPriorityFeePerGas"`
+}
+
+func (eth *eip1559TransactionHandler) Init(ctx context.Context, toolkit *txhandler.Toolkit) {
+	eth.toolkit = toolkit
+	eth.initEIP1559Metrics(ctx)
+	eth.ExtendedTransactionHandler.Init(ctx, toolkit)
+}
+
+func (eth *eip1559TransactionHandler) Start(ctx context.Context) (done <-chan struct{}, err error) {
+	if eth.blockListenerDone == nil { // only start once
+		blocks := make(chan *ffcapi.BlockHashEvent)
+		_, _, err := eth.toolkit.Connector.NewBlockListener(ctx, &ffcapi.NewBlockListenerRequest{
+			ID:              fftypes.NewUUID(),
+			ListenerContext: ctx,
+			BlockListener:   blocks,
+		})
+		if err != nil {
+			return nil, err
+		}
+		eth.blockListenerDone = make(chan struct{})
+		go eth.blockListener(ctx, blocks)
+	}
+	return eth.ExtendedTransactionHandler.Start(ctx)
+}
+
+// GasPrice calculates the fees for a new submission from the recent samples
+func (eth *eip1559TransactionHandler) GasPrice(ctx context.Context) (*fftypes.JSONAny, error) {
+	eth.mux.Lock()
+	baseFees := make([]*big.Int, 0, len(eth.baseFees))
+	for _, baseFee := range eth.baseFees {
+		baseFees = append(baseFees, baseFee)
+	}
+	baseFee := percentile(baseFees, eth.baseFeePercentile)
+	priorityFee := percentile(eth.priorityFees, eth.priorityFeePercentile)
+	eth.mux.Unlock()
+
+	if baseFee == nil {
+		// No blocks with a base fee have been sampled yet, so we fall back to the gas price estimate of the connector.
+		// That includes a priority fee, so overestimates the base fee - but only the actual base fee is paid.
+		var err error
+		if baseFee, err = eth.estimateBaseFee(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if priorityFee == nil || priorityFee.Cmp(eth.minPriorityFee) < 0 {
+		priorityFee = eth.minPriorityFee
+	}
+	priorityFee = capFee(priorityFee, eth.maxPriorityFee)
+	maxFee := new(big.Int).Mul(baseFee, big.NewInt(int64(eth.baseFeeMaxFeePercentage)))
+	maxFee.Div(maxFee, big.NewInt(100))
+	maxFee.Add(maxFee, priorityFee)
+	maxFee = capFee(maxFee, eth.maxFeePerGas)
+	priorityFee = capFee(priorityFee, maxFee)
+
+	eth.setFeeMetrics(ctx, baseFee, priorityFee)
+	log.L(ctx).Debugf("EIP-1559 fees calculated: baseFee=%s maxFeePerGas=%s maxPriorityFeePerGas=%s", baseFee, maxFee, priorityFee)
+	return serializeFees(maxFee, priorityFee), nil
+}
+
+// EscalateGasPrice increases both fees of the last submission by the escalation percentage, as the node
+// only accepts a replacement transaction if both are increased
+func (eth *eip1559TransactionHandler) EscalateGasPrice(ctx context.Context, previous *fftypes.JSONAny) *fftypes.JSONAny {
+	if eth.priorityFeeEscalationPercentage <= 0 {
+		return nil
+	}
+	var fees eip1559Fees
+	bumped := simple.BumpGasPrice(previous, eth.priorityFeeEscalationPercentage)
+	if err := json.Unmarshal(bumped.Bytes(), &fees); err != nil || fees.MaxFeePerGas == nil || fees.MaxPriorityFeePerGas == nil {
+		log.L(ctx).Warnf("Unable to escalate gas price %s, as it does not contain EIP-1559 fees", previous)
+		return nil
+	}
+	maxFee := capFee(fees.MaxFeePerGas.Int(), eth.maxFeePerGas)
+	priorityFee := capFee(fees.MaxPriorityFeePerGas.Int(), eth.maxPriorityFee)
+	priorityFee = capFee(priorityFee, maxFee)
+	return serializeFees(maxFee, priorityFee)
+}
+
+// estimateBaseFee uses the legacy gas price estimate of the connector as the base fee, or the maxFeePerGas if the
+// connector returns EIP-1559 fees
+func (eth *eip1559TransactionHandler) estimateBaseFee(ctx context.Context) (*big.Int, error) {
+	res, _, err := eth.toolkit.Connector.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+	if err != nil {
+		return nil, err
+	}
+	var fees eip1559Fees
+	if err := json.Unmarshal(res.GasPrice.Bytes(), &fees); err == nil && fees.MaxFeePerGas != nil {
+		return fees.MaxFeePerGas.Int(), nil
+	}
+	var gasPrice fftypes.FFBigInt
+	if err := json.Unmarshal(res.GasPrice.Bytes(), &gasPrice); err == nil {
+		return gasPrice.Int(), nil
+	}
+	return nil, i18n.NewError(ctx, tmmsgs.MsgEIP1559NoBaseFee)
+}
+
+func serializeFees(maxFee, priorityFee *big.Int) *fftypes.JSONAny {
+	b, _ := json.Marshal(&eip1559Fees{
+		MaxFeePerGas:         (*fftypes.FFBigInt)(maxFee),
+		MaxPriorityFeePerGas: (*fftypes.FFBigInt)(priorityFee),
+	})
+	return fftypes.JSONAnyPtrBytes(b)
+}
+
+// capFee returns the fee reduced to at most the maximum, if there is one
+func capFee(fee, max *big.Int) *big.Int {
+	if max != nil && fee.Cmp(max) > 0 {
+		return max
+	}
+	return fee
+}
+
+// percentile returns the nearest rank percentile of the values, or nil if there are none
+func percentile(values []*big.Int, p int) *big.Int {
+	n := len(values)
+	if n == 0 {
+		return nil
+	}
+	sorted := make([]*big.Int, n)
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+	rank := (p*n + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,205 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eip1559
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/metrics"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/simple"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestTransactionHandlerConfig(t *testing.T) (*TransactionHandlerFactory, config.Section) {
+	tmconfig.Reset()
+	conf := config.RootSection("unittest.eip1559")
+	viper.SetDefault(string(tmconfig.TransactionsHandlerName), "eip1559")
+
+	f := &TransactionHandlerFactory{}
+	f.InitConfig(conf)
+	assert.Equal(t, "eip1559", f.Name())
+	return f, conf
+}
+
+func newTestTransactionHandler(t *testing.T, conf config.Section) (*eip1559TransactionHandler, *ffcapimocks.API, *persistencemocks.Persistence) {
+	f := &TransactionHandlerFactory{}
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockPersistence := &persistencemocks.Persistence{}
+	th.Init(context.Background(), &txhandler.Toolkit{
+		Connector:      mockFFCAPI,
+		TXHistory:      mockPersistence,
+		TXPersistence:  mockPersistence,
+		MetricsManager: metrics.NewMetricsManager(context.Background()),
+	})
+	return th.(*eip1559TransactionHandler), mockFFCAPI, mockPersistence
+}
+
+func TestNewTransactionHandlerDefaults(t *testing.T) {
+	_, conf := newTestTransactionHandlerConfig(t)
+	conf.Set(BlockSamples, 0)
+	conf.Set(PriorityFeeMinimum, "")
+	eth, _, _ := newTestTransactionHandler(t, conf)
+	assert.Equal(t, 1, eth.blockSamples)
+	assert.Equal(t, 50, eth.baseFeePercentile)
+	assert.Equal(t, 200, eth.baseFeeMaxFeePercentage)
+	assert.Zero(t, eth.minPriorityFee.Sign())
+	assert.Nil(t, eth.maxPriorityFee)
+	assert.Nil(t, eth.maxFeePerGas)
+}
+
+func TestNewTransactionHandlerBadConfig(t *testing.T) {
+	for key, tc := range map[string]struct {
+		value interface{}
+		err   string
+	}{
+		BaseFeePercentile:        {101, "FF21148.*baseFee.percentile"},
+		PriorityFeePercentile:    {-1, "FF21148.*priorityFee.percentile"},
+		PriorityFeeMinimum:       {"wrong", "FF21149.*priorityFee.minimum"},
+		PriorityFeeMaximum:       {"-1", "FF21149.*priorityFee.maximum"},
+		MaxFeePerGas:             {"0xzz", "FF21149.*maxFeePerGas"},
+		simple.SignerSelection:   {"wrong", "FF21"},
+		simple.DependencyFailure: {"wrong", "FF21"},
+	} {
+		f, conf := newTestTransactionHandlerConfig(t)
+		conf.Set(key, tc.value)
+		_, err := f.NewTransactionHandler(context.Background(), conf)
+		assert.Regexp(t, tc.err, err, key)
+	}
+}
+
+func TestGasPriceFromSamples(t *testing.T) {
+	_, conf := newTestTransactionHandlerConfig(t)
+	eth, _, _ := newTestTransactionHandler(t, conf)
+	eth.baseFees = map[uint64]*big.Int{10: big.NewInt(300), 11: big.NewInt(100), 12: big.NewInt(200)}
+	eth.priorityFees = []*big.Int{big.NewInt(10), big.NewInt(1), big.NewInt(5)}
+
+	gasPrice, err := eth.GasPrice(context.Background())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"maxFeePerGas":"405","maxPriorityFeePerGas":"5"}`, gasPrice.String())
+
+	// The samples are not re-ordered by the calculation
+	assert.Equal(t, int64(10), eth.priorityFees[0].Int64())
+
+	// The lowest samples
+	eth.baseFeePercentile = 0
+	eth.priorityFeePercentile = 0
+	gasPrice, err = eth.GasPrice(context.Background())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"maxFeePerGas":"201","maxPriorityFeePerGas":"1"}`, gasPrice.String())
+}
+
+func TestGasPriceLimits(t *testing.T) {
+	_, conf := newTestTransactionHandlerConfig(t)
+	conf.Set(BaseFeePercentile, 100)
+	conf.Set(BaseFeeMaxFeePercentage, 100)
+	conf.Set(PriorityFeeMinimum, "20")
+	eth, _, _ := newTestTransactionHandler(t, conf)
+	eth.baseFees = map[uint64]*big.Int{10: big.NewInt(300), 11: big.NewInt(100)}
+	eth.priorityFees = []*big.Int{big.NewInt(10)}
+
+	// The priority fee is raised to the minimum
+	gasPrice, err := eth.GasPrice(context.Background())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"maxFeePerGas":"320","maxPriorityFeePerGas":"20"}`, gasPrice.String())
+
+	// The priority fee is reduced to the maximum
+	eth.minPriorityFee = big.NewInt(0)
+	eth.maxPriorityFee = big.NewInt(5)
+	gasPrice, err = eth.GasPrice(context.Background())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"maxFeePerGas":"305","maxPriorityFeePerGas":"5"}`, gasPrice.String())
+
+	// The priority fee can never be more than the max fee
+	eth.maxFeePerGas = big.NewInt(3)
+	gasPrice, err = eth.GasPrice(context.Background())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"maxFeePerGas":"3","maxPriorityFeePerGas":"3"}`, gasPrice.String())
+}
+
+func TestGasPriceFromEstimate(t *testing.T) {
+	_, conf := newTestTransactionHandlerConfig(t)
+	eth, mockFFCAPI, _ := newTestTransactionHandler(t, conf)
+
+	// A legacy gas price is used as the base fee
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`1000`),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	gasPrice, err := eth.GasPrice(context.Background())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"maxFeePerGas":"2000","maxPriorityFeePerGas":"0"}`, gasPrice.String())
+
+	// As is the maxFeePerGas from EIP-1559 fees
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`{"maxFeePerGas":"0x10","maxPriorityFeePerGas":"0x01"}`),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	gasPrice, err = eth.GasPrice(context.Background())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"maxFeePerGas":"32","maxPriorityFeePerGas":"0"}`, gasPrice.String())
+
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`{"gasPrice":"unknown"}`),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	_, err = eth.GasPrice(context.Background())
+	assert.Regexp(t, "FF21150", err)
+
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	_, err = eth.GasPrice(context.Background())
+	assert.Regexp(t, "pop", err)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestEscalateGasPrice(t *testing.T) {
+	_, conf := newTestTransactionHandlerConfig(t)
+	eth, _, _ := newTestTransactionHandler(t, conf)
+	ctx := context.Background()
+
+	escalated := eth.EscalateGasPrice(ctx, fftypes.JSONAnyPtr(`{"maxFeePerGas":100,"maxPriorityFeePerGas":"0x0a"}`))
+	assert.JSONEq(t, `{"maxFeePerGas":"110","maxPriorityFeePerGas":"11"}`, escalated.String())
+
+	// A zero fee is still increased
+	escalated = eth.EscalateGasPrice(ctx, fftypes.JSONAnyPtr(`{"maxFeePerGas":100,"maxPriorityFeePerGas":0}`))
+	assert.JSONEq(t, `{"maxFeePerGas":"110","maxPriorityFeePerGas":"1"}`, escalated.String())
+
+	// Escalation is capped
+	eth.maxPriorityFee = big.NewInt(10)
+	eth.maxFeePerGas = big.NewInt(8)
+	escalated = eth.EscalateGasPrice(ctx, fftypes.JSONAnyPtr(`{"maxFeePerGas":100,"maxPriorityFeePerGas":10}`))
+	assert.JSONEq(t, `{"maxFeePerGas":"8","maxPriorityFeePerGas":"8"}`, escalated.String())
+
+	// Only EIP-1559 fees are escalated
+	assert.Nil(t, eth.EscalateGasPrice(ctx, fftypes.JSONAnyPtr(`12345`)))
+	assert.Nil(t, eth.EscalateGasPrice(ctx, fftypes.JSONAnyPtr(`{"maxFeePerGas":100}`)))
+
+	eth.priorityFeeEscalationPercentage = 0
+	assert.Nil(t, eth.EscalateGasPrice(ctx, fftypes.JSONAnyPtr(`{"maxFeePerGas":100,"maxPriorityFeePerGas":10}`)))
+}
@@ -0,0 +1,40 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eip1559
+
+import (
+	"context"
+	"math/big"
+)
+
+const metricsGaugeBaseFee = "eip1559_base_fee_per_gas"
+const metricsGaugeBaseFeeDescription = "Base fee per gas used in the last calculation of EIP-1559 fees"
+
+const metricsGaugePriorityFee = "eip1559_max_priority_fee_per_gas"
+const metricsGaugePriorityFeeDescription = "Max priority fee per gas from the last calculation of EIP-1559 fees"
+
+func (eth *eip1559TransactionHandler) initEIP1559Metrics(ctx context.Context) {
+	eth.toolkit.MetricsManager.InitTxHandlerGaugeMetric(ctx, metricsGaugeBaseFee, metricsGaugeBaseFeeDescription, false)
+	eth.toolkit.MetricsManager.InitTxHandlerGaugeMetric(ctx, metricsGaugePriorityFee, metricsGaugePriorityFeeDescription, false)
+}
+
+func (eth *eip1559TransactionHandler) setFeeMetrics(ctx context.Context, baseFee, priorityFee *big.Int) {
+	baseFeeValue, _ := new(big.Float).SetInt(baseFee).Float64()
+	priorityFeeValue, _ := new(big.Float).SetInt(priorityFee).Float64()
+	eth.toolkit.MetricsManager.SetTxHandlerGaugeMetric(ctx, metricsGaugeBaseFee, baseFeeValue, nil)
+	eth.toolkit.MetricsManager.SetTxHandlerGaugeMetric(ctx, metricsGaugePriorityFee, priorityFeeValue, nil)
+}
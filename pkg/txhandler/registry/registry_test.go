@@ -20,7 +20,10 @@ import (
 	"context"
 	"testing"
 
+	"github.com/hyperledger/firefly-common/pkg/config"
+
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/eip1559"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/simple"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
@@ -55,3 +58,14 @@ func TestRegistry(t *testing.T) {
 	assert.Regexp(t, "FF21070", err)
 
 }
+
+func TestRegistryEIP1559(t *testing.T) {
+	tmconfig.Reset()
+	viper.SetDefault(string(tmconfig.TransactionsHandlerName), "eip1559")
+	RegisterHandler(&simple.TransactionHandlerFactory{})
+	RegisterHandler(&eip1559.TransactionHandlerFactory{})
+	tmconfig.TransactionHandlerBaseConfig.SubSection("eip1559").Set(eip1559.BlockSamples, 10)
+	p, err := NewTransactionHandler(context.Background(), tmconfig.TransactionHandlerBaseConfig, config.GetString(tmconfig.TransactionsHandlerName))
+	assert.NotNil(t, p)
+	assert.NoError(t, err)
+}
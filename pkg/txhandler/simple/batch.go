@@ -0,0 +1,227 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simple
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// HandleNewTransactionBatch prepares the requests in the batch concurrently, then inserts all those that
+// prepared successfully together in calls to persistence - so the transactions from each signer get consecutive
+// nonces in the order of the batch. A failure only affects the individual request, the requests that depend
+// on it, and any others that persistence fails along with it.
+func (sth *simpleTransactionHandler) HandleNewTransactionBatch(ctx context.Context, txReqs []*apitypes.TransactionBatchItem) []*apitypes.TransactionBatchResult {
+	results := make([]*apitypes.TransactionBatchResult, len(txReqs))
+	prepared := make([]*apitypes.ManagedTX, len(txReqs))
+
+	// The IDs supplied in the batch must be unique, and a request can depend on an earlier request in the batch
+	batchIndexes := make(map[string]int)
+	for i, txReq := range txReqs {
+		reqHeaders, _ := batchItemHeaders(txReq)
+		results[i] = &apitypes.TransactionBatchResult{ID: reqHeaders.ID}
+		if reqHeaders.ID != "" {
+			if _, exists := batchIndexes[reqHeaders.ID]; exists {
+				results[i].Error = i18n.NewError(ctx, tmmsgs.MsgDuplicateID, reqHeaders.ID).Error()
+				continue
+			}
+			batchIndexes[reqHeaders.ID] = i
+		}
+	}
+
+	// Prepare concurrently, with a bounded number of workers
+	work := make(chan int)
+	workers := sth.batchConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				mtx, err := sth.prepareBatchItem(ctx, i, txReqs[i], batchIndexes)
+				if err != nil {
+					results[i].Error = err.Error()
+					continue
+				}
+				results[i].ID = mtx.ID
+				prepared[i] = mtx
+			}
+		}()
+	}
+	for i := range txReqs {
+		if results[i].Error == "" {
+			work <- i
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	// Transactions are admitted by the spend policy in the order of the batch. A request that depends on earlier
+	// requests in the batch is inserted in a later round than them, so it is never persisted if one of them fails.
+	// The later requests from the same signer follow it into that round, to keep their nonces in batch order.
+	batchDeps := make([][]int, len(txReqs))
+	rounds := make([]int, len(txReqs))
+	signerRounds := make(map[string]int) // by lowercased signer, as the casing of an address does not change the account
+	lastRound := 0
+	for i, mtx := range prepared {
+		if mtx == nil {
+			continue
+		}
+		batchDeps[i] = batchDependencies(i, txReqs[i], batchIndexes)
+		if err := sth.checkBatchDependencies(ctx, i, results, batchDeps[i]); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		if err := sth.admitNewTx(ctx, mtx); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		signer := strings.ToLower(mtx.From)
+		round := signerRounds[signer]
+		for _, depIdx := range batchDeps[i] {
+			if rounds[depIdx] >= round {
+				round = rounds[depIdx] + 1
+			}
+		}
+		rounds[i], signerRounds[signer] = round, round
+		if round > lastRound {
+			lastRound = round
+		}
+	}
+
+	inserted := false
+	for round := 0; round <= lastRound; round++ {
+		toInsert := make([]*apitypes.ManagedTX, 0, len(txReqs))
+		resultIndexes := make([]int, 0, len(txReqs))
+		for i, mtx := range prepared {
+			if mtx == nil || results[i].Error != "" || rounds[i] != round {
+				continue
+			}
+			if err := sth.checkBatchDependencies(ctx, i, results, batchDeps[i]); err != nil {
+				sth.releaseNewTx(ctx, mtx)
+				results[i].Error = err.Error()
+				continue
+			}
+			toInsert = append(toInsert, mtx)
+			resultIndexes = append(resultIndexes, i)
+		}
+		if len(toInsert) == 0 {
+			continue
+		}
+
+		// On some persistence implementations, a failure inserting any transaction fails all of those from
+		// the same signer - each of which is reported as failed
+		errs := sth.toolkit.TXPersistence.InsertTransactionsWithNextNonce(ctx, toInsert, sth.nextNonceForSigner)
+		for j, mtx := range toInsert {
+			result := results[resultIndexes[j]]
+			err := errs[j]
+			if err != nil {
+				sth.releaseNewTx(ctx, mtx)
+			} else {
+				inserted = true
+				err = sth.trackNewTx(ctx, mtx)
+			}
+			if err != nil {
+				result.Error = err.Error()
+				continue
+			}
+			result.Transaction = mtx
+		}
+	}
+	if inserted {
+		sth.markInflightStale()
+	}
+
+	return results
+}
+
+// batchDependencies returns the indexes of the earlier requests in the batch that a request depends on
+func batchDependencies(idx int, txReq *apitypes.TransactionBatchItem, batchIndexes map[string]int) []int {
+	reqHeaders, _ := batchItemHeaders(txReq)
+	var deps []int
+	for _, depID := range reqHeaders.DependsOn {
+		if depIdx, inBatch := batchIndexes[depID]; inBatch && depIdx < idx {
+			deps = append(deps, depIdx)
+		}
+	}
+	return deps
+}
+
+// checkBatchDependencies returns an error if any of the earlier requests in the batch that a request depends on has failed
+func (sth *simpleTransactionHandler) checkBatchDependencies(ctx context.Context, idx int, results []*apitypes.TransactionBatchResult, deps []int) error {
+	for _, depIdx := range deps {
+		if results[depIdx].Error != "" {
+			return i18n.NewError(ctx, tmmsgs.MsgBatchDependencyFailed, results[idx].ID, results[depIdx].ID)
+		}
+	}
+	return nil
+}
+
+func batchItemHeaders(txReq *apitypes.TransactionBatchItem) (*apitypes.RequestHeaders, *ffcapi.TransactionHeaders) {
+	if txReq.Deploy != nil {
+		return &txReq.Deploy.Headers, &txReq.Deploy.TransactionHeaders
+	}
+	return &txReq.Transaction.Headers, &txReq.Transaction.TransactionHeaders
+}
+
+func (sth *simpleTransactionHandler) prepareBatchItem(ctx context.Context, idx int, txReq *apitypes.TransactionBatchItem, batchIndexes map[string]int) (*apitypes.ManagedTX, error) {
+	reqHeaders, txHeaders := batchItemHeaders(txReq)
+	txID, err := sth.requestIDPreCheck(ctx, reqHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	// Dependencies on earlier requests in the batch are satisfied by the batch itself
+	dependsOn := make([]string, 0, len(reqHeaders.DependsOn))
+	for _, depID := range reqHeaders.DependsOn {
+		if depIdx, inBatch := batchIndexes[depID]; !inBatch || depIdx >= idx {
+			dependsOn = append(dependsOn, depID)
+		}
+	}
+	if err := sth.checkDependenciesExist(ctx, txID, dependsOn); err != nil {
+		return nil, err
+	}
+
+	var gas *fftypes.FFBigInt
+	var transactionData string
+	if txReq.Deploy != nil {
+		prepared, _, err := sth.toolkit.Connector.DeployContractPrepare(ctx, &txReq.Deploy.ContractDeployPrepareRequest)
+		if err != nil {
+			return nil, err
+		}
+		gas, transactionData = prepared.Gas, prepared.TransactionData
+	} else {
+		prepared, _, err := sth.toolkit.Connector.TransactionPrepare(ctx, &ffcapi.TransactionPrepareRequest{
+			TransactionInput: txReq.Transaction.TransactionInput,
+		})
+		if err != nil {
+			return nil, err
+		}
+		gas, transactionData = prepared.Gas, prepared.TransactionData
+	}
+	return newManagedTx(txID, reqHeaders, txHeaders, gas, transactionData), nil
+}
@@ -0,0 +1,384 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simple
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/spendpolicy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func batchTX(id, signer string, dependsOn ...string) *apitypes.TransactionBatchItem {
+	return &apitypes.TransactionBatchItem{
+		Transaction: &apitypes.TransactionRequest{
+			Headers: apitypes.RequestHeaders{
+				ID:        id,
+				Type:      apitypes.RequestTypeSendTransaction,
+				DependsOn: dependsOn,
+			},
+			TransactionInput: ffcapi.TransactionInput{
+				TransactionHeaders: ffcapi.TransactionHeaders{
+					From: signer,
+				},
+			},
+		},
+	}
+}
+
+func batchDeploy(id, signer string) *apitypes.TransactionBatchItem {
+	return &apitypes.TransactionBatchItem{
+		Deploy: &apitypes.ContractDeployRequest{
+			Headers: apitypes.RequestHeaders{
+				ID:   id,
+				Type: apitypes.RequestTypeDeploy,
+			},
+			ContractDeployPrepareRequest: ffcapi.ContractDeployPrepareRequest{
+				TransactionHeaders: ffcapi.TransactionHeaders{
+					From: signer,
+				},
+			},
+		},
+	}
+}
+
+func mockBatchPrepare(mfc *ffcapimocks.ExtendedAPI) {
+	mfc.On("TransactionPrepare", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionPrepareRequest) bool {
+		return req.From != "0xbad"
+	})).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(100000),
+		TransactionData: "0x123456",
+	}, ffcapi.ErrorReason(""), nil)
+	mfc.On("TransactionPrepare", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+	mfc.On("DeployContractPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(200000),
+		TransactionData: "0xabcdef",
+	}, ffcapi.ErrorReason(""), nil)
+}
+
+func TestHandleNewTransactionBatchConsecutiveNonces(t *testing.T) {
+	sth, mfc, _, cleanup := newTestReplacementHandler(t)
+	defer cleanup()
+	sth.batchConcurrency = 3
+
+	mockBatchPrepare(mfc)
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(func(_ context.Context, req *ffcapi.NextNonceForSignerRequest) *ffcapi.NextNonceForSignerResponse {
+		if req.Signer == "0xaaaaa" {
+			return &ffcapi.NextNonceForSignerResponse{Nonce: fftypes.NewFFBigInt(10)}
+		}
+		return &ffcapi.NextNonceForSignerResponse{Nonce: fftypes.NewFFBigInt(20)}
+	}, ffcapi.ErrorReason(""), nil)
+
+	results := sth.HandleNewTransactionBatch(sth.ctx, []*apitypes.TransactionBatchItem{
+		batchTX("tx1", "0xaaaaa"),
+		batchTX("tx2", "0xbbbbb", "tx1"), // depends on an earlier request in the batch
+		batchTX("tx1", "0xaaaaa"),        // duplicate within the batch
+		batchTX("tx3", "0xbad"),          // prepare fails, so no nonce is used
+		batchDeploy("", "0xaaaaa"),       // ID is generated
+		batchTX("tx4", "0xaaaaa", "tx5"), // depends on a later request in the batch
+		batchTX("tx5", "0xaaaaa"),
+	})
+	assert.Len(t, results, 7)
+
+	assert.Empty(t, results[0].Error)
+	assert.Equal(t, "tx1", results[0].ID)
+	assert.Equal(t, int64(10), results[0].Transaction.Nonce.Int64())
+	assert.Empty(t, results[1].Error)
+	assert.Equal(t, int64(20), results[1].Transaction.Nonce.Int64())
+	assert.Equal(t, "tx1", results[2].ID)
+	assert.Regexp(t, "FF21065", results[2].Error)
+	assert.Nil(t, results[2].Transaction)
+	assert.Equal(t, "tx3", results[3].ID)
+	assert.Regexp(t, "pop", results[3].Error)
+	assert.Empty(t, results[4].Error)
+	assert.NotEmpty(t, results[4].ID)
+	assert.Equal(t, results[4].ID, results[4].Transaction.ID)
+	assert.Equal(t, "0xabcdef", results[4].Transaction.TransactionData)
+	assert.Equal(t, int64(11), results[4].Transaction.Nonce.Int64())
+	assert.Regexp(t, "FF21128.*tx5", results[5].Error)
+	assert.Empty(t, results[6].Error)
+	assert.Equal(t, int64(12), results[6].Transaction.Nonce.Int64())
+
+	// Resubmitting an ID that already exists fails just that request
+	results = sth.HandleNewTransactionBatch(sth.ctx, []*apitypes.TransactionBatchItem{
+		batchTX("tx1", "0xaaaaa"),
+		batchTX("tx6", "0xaaaaa"),
+	})
+	assert.Regexp(t, "FF21065", results[0].Error)
+	assert.Empty(t, results[1].Error)
+	assert.Equal(t, int64(13), results[1].Transaction.Nonce.Int64())
+
+	stored, err := sth.toolkit.TXPersistence.GetTransactionByID(sth.ctx, "tx6")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(13), stored.Nonce.Int64())
+}
+
+func TestHandleNewTransactionBatchAllFail(t *testing.T) {
+	sth, mfc, _, cleanup := newTestReplacementHandler(t)
+	defer cleanup()
+	sth.batchConcurrency = 0
+
+	mockBatchPrepare(mfc)
+
+	results := sth.HandleNewTransactionBatch(sth.ctx, []*apitypes.TransactionBatchItem{
+		batchTX("tx1", "0xbad"),
+		batchTX("tx2", "0xbad"),
+	})
+	assert.Len(t, results, 2)
+	assert.Regexp(t, "pop", results[0].Error)
+	assert.Regexp(t, "pop", results[1].Error)
+}
+
+func TestHandleNewTransactionBatchPreCheckFail(t *testing.T) {
+	f, tk, _, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+	th.Init(context.Background(), tk)
+	sth := th.(*simpleTransactionHandler)
+
+	mp := tk.TXPersistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionByID", mock.Anything, "tx1").Return(nil, fmt.Errorf("pop"))
+
+	results := sth.HandleNewTransactionBatch(context.Background(), []*apitypes.TransactionBatchItem{
+		batchTX("tx1", "0xaaaaa"),
+	})
+	assert.Regexp(t, "pop", results[0].Error)
+}
+
+func TestHandleNewTransactionBatchDeployPrepareFail(t *testing.T) {
+	f, tk, mfc, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+	th.Init(context.Background(), tk)
+	sth := th.(*simpleTransactionHandler)
+
+	mfc.On("DeployContractPrepare", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	results := sth.HandleNewTransactionBatch(context.Background(), []*apitypes.TransactionBatchItem{
+		batchDeploy("", "0xaaaaa"),
+	})
+	assert.Regexp(t, "pop", results[0].Error)
+}
+
+func TestHandleNewTransactionBatchInsertFail(t *testing.T) {
+	f, tk, mfc, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+	th.Init(context.Background(), tk)
+	sth := th.(*simpleTransactionHandler)
+
+	mockBatchPrepare(mfc)
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+	mp := tk.TXPersistence.(*persistencemocks.Persistence)
+	insertMock := mp.On("InsertTransactionsWithNextNonce", mock.Anything, mock.Anything, mock.Anything)
+	insertMock.Run(func(args mock.Arguments) {
+		ctx := args[0].(context.Context)
+		txs := args[1].([]*apitypes.ManagedTX)
+		nextNonceCB := args[2].(persistence.NextNonceCallback)
+		_, err := nextNonceCB(ctx, txs[0].From)
+		txs[1].Nonce = fftypes.NewFFBigInt(12345)
+		insertMock.Return([]error{err, nil})
+	})
+	mp.On("AddSubStatusAction", mock.Anything, mock.Anything, apitypes.TxSubStatusReceived, apitypes.TxActionAssignNonce, mock.Anything, mock.Anything).Return(fmt.Errorf("pop2"))
+
+	results := sth.HandleNewTransactionBatch(context.Background(), []*apitypes.TransactionBatchItem{
+		batchTX("", "0xaaaaa"),
+		batchTX("", "0xaaaaa"),
+	})
+	assert.Regexp(t, "pop", results[0].Error)
+	assert.Regexp(t, "pop2", results[1].Error)
+	assert.Nil(t, results[1].Transaction)
+}
+
+func TestHandleNewTransactionBatchSpendPolicy(t *testing.T) {
+	sth, mfc, _, cleanup := newTestReplacementHandler(t)
+	defer cleanup()
+	sth.toolkit.SpendPolicy = spendpolicy.NewSpendPolicy(&spendpolicy.Options{
+		WindowMaxValue: big.NewInt(1000),
+	})
+
+	mockBatchPrepare(mfc)
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{Nonce: fftypes.NewFFBigInt(10)}, ffcapi.ErrorReason(""), nil)
+
+	withValue := func(item *apitypes.TransactionBatchItem, value int64) *apitypes.TransactionBatchItem {
+		item.Transaction.Value = fftypes.NewFFBigInt(value)
+		return item
+	}
+	results := sth.HandleNewTransactionBatch(sth.ctx, []*apitypes.TransactionBatchItem{
+		withValue(batchTX("tx1", "0xaaaaa"), 600),
+		withValue(batchTX("tx2", "0xaaaaa"), 600),
+		withValue(batchTX("tx3", "0xaaaaa"), 400),
+	})
+	assert.Empty(t, results[0].Error)
+	assert.Equal(t, int64(10), results[0].Transaction.Nonce.Int64())
+	assert.Regexp(t, "FF21146", results[1].Error)
+	assert.Empty(t, results[2].Error)
+	assert.Equal(t, int64(11), results[2].Transaction.Nonce.Int64())
+}
+
+func TestHandleNewTransactionBatchInsertFailReleasesSpend(t *testing.T) {
+	f, tk, mfc, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+	tk.SpendPolicy = spendpolicy.NewSpendPolicy(&spendpolicy.Options{
+		WindowMaxValue: big.NewInt(1000),
+	})
+	th.Init(context.Background(), tk)
+	sth := th.(*simpleTransactionHandler)
+
+	mockBatchPrepare(mfc)
+	mp := tk.TXPersistence.(*persistencemocks.Persistence)
+	mp.On("InsertTransactionsWithNextNonce", mock.Anything, mock.Anything, mock.Anything).Return([]error{fmt.Errorf("pop")})
+
+	item := batchTX("", "0xaaaaa")
+	item.Transaction.Value = fftypes.NewFFBigInt(1000)
+	results := sth.HandleNewTransactionBatch(context.Background(), []*apitypes.TransactionBatchItem{item})
+	assert.Regexp(t, "pop", results[0].Error)
+
+	// The full limit is available again
+	err = tk.SpendPolicy.Admit(context.Background(), &apitypes.ManagedTX{
+		ID:                 "tx2",
+		TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaaa", Value: fftypes.NewFFBigInt(1000)},
+	})
+	assert.NoError(t, err)
+}
+
+func TestHandleNewTransactionBatchDependencyFailed(t *testing.T) {
+	sth, mfc, _, cleanup := newTestReplacementHandler(t)
+	defer cleanup()
+
+	mockBatchPrepare(mfc)
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{Nonce: fftypes.NewFFBigInt(10)}, ffcapi.ErrorReason(""), nil)
+
+	results := sth.HandleNewTransactionBatch(sth.ctx, []*apitypes.TransactionBatchItem{
+		batchTX("tx1", "0xbad"),
+		batchTX("tx2", "0xaaaaa", "tx1"),
+		batchTX("tx3", "0xbbbbb", "tx2"), // fails with the request it depends on
+		batchTX("tx4", "0xaaaaa"),
+	})
+	assert.Regexp(t, "pop", results[0].Error)
+	assert.Regexp(t, "FF21152.*tx2.*tx1", results[1].Error)
+	assert.Nil(t, results[1].Transaction)
+	assert.Regexp(t, "FF21152.*tx3.*tx2", results[2].Error)
+	assert.Empty(t, results[3].Error)
+	assert.Equal(t, int64(10), results[3].Transaction.Nonce.Int64())
+
+	stored, err := sth.toolkit.TXPersistence.GetTransactionByID(sth.ctx, "tx2")
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+}
+
+func TestHandleNewTransactionBatchDependencyInsertFail(t *testing.T) {
+	f, tk, mfc, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+	tk.SpendPolicy = spendpolicy.NewSpendPolicy(&spendpolicy.Options{
+		WindowMaxValue: big.NewInt(1000),
+	})
+	th.Init(context.Background(), tk)
+	sth := th.(*simpleTransactionHandler)
+
+	mockBatchPrepare(mfc)
+	mp := tk.TXPersistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionByID", mock.Anything, mock.Anything).Return(nil, nil)
+	mp.On("AddSubStatusAction", mock.Anything, mock.Anything, apitypes.TxSubStatusReceived, apitypes.TxActionAssignNonce, mock.Anything, mock.Anything).Return(nil)
+	insertedIDs := func(ids ...string) interface{} {
+		return mock.MatchedBy(func(txs []*apitypes.ManagedTX) bool {
+			if len(txs) != len(ids) {
+				return false
+			}
+			for i, tx := range txs {
+				if tx.ID != ids[i] {
+					return false
+				}
+			}
+			return true
+		})
+	}
+	// The request that depends on another is inserted after it, along with the later request from the same signer
+	mp.On("InsertTransactionsWithNextNonce", mock.Anything, insertedIDs("tx1", "tx4"), mock.Anything).Return([]error{fmt.Errorf("pop"), nil}).Once()
+	mp.On("InsertTransactionsWithNextNonce", mock.Anything, insertedIDs("tx3"), mock.Anything).Return([]error{nil}).Once()
+
+	tx2 := batchTX("tx2", "0xbbbbb", "tx1")
+	tx2.Transaction.Value = fftypes.NewFFBigInt(1000)
+	results := sth.HandleNewTransactionBatch(context.Background(), []*apitypes.TransactionBatchItem{
+		batchTX("tx1", "0xaaaaa"),
+		tx2,
+		batchTX("tx3", "0xbbbbb"),
+		batchTX("tx4", "0xccccc"),
+	})
+	assert.Regexp(t, "pop", results[0].Error)
+	assert.Regexp(t, "FF21152.*tx2.*tx1", results[1].Error)
+	assert.Empty(t, results[2].Error)
+	assert.Empty(t, results[3].Error)
+
+	// The spend of the request that was not inserted is released
+	err = tk.SpendPolicy.Admit(context.Background(), &apitypes.ManagedTX{
+		ID:                 "tx5",
+		TransactionHeaders: ffcapi.TransactionHeaders{From: "0xbbbbb", Value: fftypes.NewFFBigInt(1000)},
+	})
+	assert.NoError(t, err)
+
+	mp.AssertExpectations(t)
+}
+
+func TestHandleNewTransactionBatchDependencyRoundIgnoresSignerCase(t *testing.T) {
+	f, tk, mfc, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+	th.Init(context.Background(), tk)
+	sth := th.(*simpleTransactionHandler)
+
+	mockBatchPrepare(mfc)
+	mp := tk.TXPersistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionByID", mock.Anything, mock.Anything).Return(nil, nil)
+	mp.On("AddSubStatusAction", mock.Anything, mock.Anything, apitypes.TxSubStatusReceived, apitypes.TxActionAssignNonce, mock.Anything, mock.Anything).Return(nil)
+	// The later request from the same signer follows the dependent request into the later round, whatever its casing
+	mp.On("InsertTransactionsWithNextNonce", mock.Anything, mock.MatchedBy(func(txs []*apitypes.ManagedTX) bool {
+		return len(txs) == 1 && txs[0].ID == "tx1"
+	}), mock.Anything).Return([]error{nil}).Once()
+	mp.On("InsertTransactionsWithNextNonce", mock.Anything, mock.MatchedBy(func(txs []*apitypes.ManagedTX) bool {
+		return len(txs) == 2 && txs[0].ID == "tx2" && txs[1].ID == "tx3"
+	}), mock.Anything).Return([]error{nil, nil}).Once()
+
+	results := sth.HandleNewTransactionBatch(context.Background(), []*apitypes.TransactionBatchItem{
+		batchTX("tx1", "0xaaaaa"),
+		batchTX("tx2", "0xbbbbb", "tx1"),
+		batchTX("tx3", "0xBBBBB"),
+	})
+	for _, result := range results {
+		assert.Empty(t, result.Error)
+	}
+
+	mp.AssertExpectations(t)
+}
@@ -18,6 +18,7 @@ package simple
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/hyperledger/firefly-common/pkg/config"
 	"github.com/hyperledger/firefly-common/pkg/ffresty"
@@ -25,57 +26,155 @@ import (
 )
 
 const (
-	MaxInFlight = "maxInFlight"
+	MaxInFlight          = "maxInFlight"
+	MaxInFlightPerSigner = "maxInFlightPerSigner" // 0 for no per-signer limit
+	SignerSelection      = "signerSelection"      // how pending transactions are selected across signers when filling the in-flight set
+	SignerWeights        = "signerWeights"        // map of lower case signer address to weight, for weighted selection
+	DependencyFailure    = "dependencyFailure"    // what happens to a transaction when a transaction it depends on does not succeed
+	Simulate             = "simulate"             // whether transactions are simulated before first submission, so those that would revert are failed without sending
+	BatchConcurrency     = "batchConcurrency"     // the number of requests in a batch that are prepared concurrently
+	PolicyWorkers        = "policyWorkers"        // the number of workers the in-flight transactions are partitioned across by signer
+
+	FundsCheckInterval = "insufficientFunds.balanceCheckInterval" // how often the balance of a signer with transactions suspended for insufficient funds is checked
+	FundsSuspendLater  = "insufficientFunds.suspendLaterNonces"   // whether unsubmitted transactions with later nonces from the same signer are suspended too
+
+	PriorityEnabled       = "priority.enabled"       // whether the priority in the request headers is used when filling the in-flight set
+	PriorityGasPriceTiers = "priority.gasPriceTiers" // map of minimum priority to the percentage increase in gas price for transactions at or above that priority
 
 	Interval       = "interval"
 	RetryInitDelay = "retry.initialDelay"
 	RetryMaxDelay  = "retry.maxDelay"
 	RetryFactor    = "retry.factor"
 
+	SubmitBackoffInitDelay  = "submitBackoff.initialDelay" // backoff applied to submission when the connector returns rate_limited or nonce_too_high
+	SubmitBackoffMaxDelay   = "submitBackoff.maxDelay"
+	SubmitBackoffFactor     = "submitBackoff.factor"
+	ReplacementGasPriceBump = "replacementGasPriceBump" // percentage increase in gas price when the connector returns replacement_underpriced
+	MaxDroppedResubmits     = "maxDroppedResubmits"     // number of times a transaction dropped by the node is resubmitted before it is marked failed (0 for no limit)
+
+	GasEscalationPercentage        = "gasEscalation.percentage"        // percentage increase in gas price for each stale resubmission (0 to disable)
+	GasEscalationMinimumPercentage = "gasEscalation.minimumPercentage" // minimum increase the node requires to accept a replacement transaction
+	GasEscalationMaxGasPrice       = "gasEscalation.maxGasPrice"       // raw JSON cap on the escalated gas price, applied to each numeric field
+
 	FixedGasPrice          = "fixedGasPrice"    // when not using a gas station - will be treated as a raw JSON string, so can be numeric 123, or string "123", or object {"maxPriorityFeePerGas":123})
 	ResubmitInterval       = "resubmitInterval" // warnings will be written to the log at this interval if mining has not occurred, and the TX will be resubmitted
+	StaleBlocks            = "staleBlocks"      // number of new blocks without a receipt after which the TX is treated the same as after the resubmitInterval (0 to disable)
 	GasOracleConfig        = "gasOracle"
 	GasOracleMode          = "mode"
 	GasOracleMethod        = "method"
 	GasOracleTemplate      = "template"
 	GasOracleQueryInterval = "queryInterval"
+
+	GasOracleAggregation  = "aggregate.function"     // how the gas prices from the sources are combined
+	GasOraclePercentile   = "aggregate.percentile"   // the percentile, when the function is percentile
+	GasOracleMaxDeviation = "aggregate.maxDeviation" // percentage difference from the median beyond which a gas price is discarded as an outlier (0 to disable)
+	GasOracleMinSources   = "aggregate.minSources"   // the number of sources that must return a gas price
+	GasOracleConnector    = "aggregate.connector"    // whether the gas price estimate of the connector is one of the sources
+	GasOracleSources      = "aggregate.sources"      // array of REST API gas oracles, each with method, template and http config
+	GasOracleSourceHTTP   = "http"
 )
 
 const (
 	GasOracleModeDisabled  = "disabled"
 	GasOracleModeRESTAPI   = "restapi"
 	GasOracleModeConnector = "connector"
+	GasOracleModeAggregate = "aggregate"
+
+	GasOracleAggregationMedian     = "median"
+	GasOracleAggregationMean       = "mean"
+	GasOracleAggregationMin        = "min"
+	GasOracleAggregationMax        = "max"
+	GasOracleAggregationPercentile = "percentile"
+
+	SignerSelectionSequence   = "sequence"   // global sequence order, subject to maxInFlightPerSigner
+	SignerSelectionRoundRobin = "roundRobin" // one transaction from each signer in turn
+	SignerSelectionWeighted   = "weighted"   // a number of transactions from each signer in turn, according to signerWeights
 
-	defaultMaxInFlight    = 100
-	defaultInterval       = "10s"
-	defaultRetryInitDelay = "250ms"
-	defaultRetryMaxDelay  = "30s"
-	defaultRetryFactor    = 2.0
+	DependencyFailureFail = "fail" // cancel the nonce and fail the transaction
+	DependencyFailureHold = "hold" // suspend the transaction for intervention
+
+	defaultMaxInFlight          = 100
+	defaultMaxInFlightPerSigner = 0
+	defaultSignerSelection      = SignerSelectionSequence
+	defaultPriorityEnabled      = false
+	defaultDependencyFailure    = DependencyFailureFail
+	defaultSimulate             = false
+	defaultBatchConcurrency     = 10
+	defaultPolicyWorkers        = 1
+	defaultFundsCheckInterval   = 1 * time.Minute
+	defaultFundsSuspendLater    = false
+	defaultInterval             = "10s"
+	defaultRetryInitDelay       = "250ms"
+	defaultRetryMaxDelay        = "30s"
+	defaultRetryFactor          = 2.0
+
+	defaultSubmitBackoffInitDelay  = 5 * time.Second
+	defaultSubmitBackoffMaxDelay   = 5 * time.Minute
+	defaultSubmitBackoffFactor     = 2.0
+	defaultReplacementGasPriceBump = 10
+	defaultMaxDroppedResubmits     = 0
+
+	defaultGasEscalationPercentage        = 0
+	defaultGasEscalationMinimumPercentage = 10
 )
 
 const (
 	defaultResubmitInterval       = "5m"
+	defaultStaleBlocks            = 0
 	defaultGasOracleQueryInterval = "5m"
 	defaultGasOracleMethod        = http.MethodGet
 	defaultGasOracleMode          = GasOracleModeConnector
+	defaultGasOracleAggregation   = GasOracleAggregationMedian
+	defaultGasOraclePercentile    = 50
+	defaultGasOracleMaxDeviation  = 50
+	defaultGasOracleMinSources    = 1
+	defaultGasOracleConnector     = true
 )
 
 func (f *TransactionHandlerFactory) InitConfig(conf config.Section) {
 	conf.AddKnownKey(FixedGasPrice)
 	conf.AddKnownKey(ResubmitInterval, defaultResubmitInterval)
+	conf.AddKnownKey(StaleBlocks, defaultStaleBlocks)
 
 	conf.AddKnownKey(MaxInFlight, defaultMaxInFlight)
+	conf.AddKnownKey(MaxInFlightPerSigner, defaultMaxInFlightPerSigner)
+	conf.AddKnownKey(SignerSelection, defaultSignerSelection)
+	conf.AddKnownKey(SignerWeights)
+	conf.AddKnownKey(DependencyFailure, defaultDependencyFailure)
+	conf.AddKnownKey(Simulate, defaultSimulate)
+	conf.AddKnownKey(BatchConcurrency, defaultBatchConcurrency)
+	conf.AddKnownKey(PolicyWorkers, defaultPolicyWorkers)
+	conf.AddKnownKey(FundsCheckInterval, defaultFundsCheckInterval)
+	conf.AddKnownKey(FundsSuspendLater, defaultFundsSuspendLater)
+	conf.AddKnownKey(PriorityEnabled, defaultPriorityEnabled)
+	conf.AddKnownKey(PriorityGasPriceTiers)
 	conf.AddKnownKey(Interval, defaultInterval)
 	conf.AddKnownKey(RetryInitDelay, defaultRetryInitDelay)
 	conf.AddKnownKey(RetryMaxDelay, defaultRetryMaxDelay)
 	conf.AddKnownKey(RetryFactor, defaultRetryFactor)
 
+	conf.AddKnownKey(SubmitBackoffInitDelay, defaultSubmitBackoffInitDelay)
+	conf.AddKnownKey(SubmitBackoffMaxDelay, defaultSubmitBackoffMaxDelay)
+	conf.AddKnownKey(SubmitBackoffFactor, defaultSubmitBackoffFactor)
+	conf.AddKnownKey(ReplacementGasPriceBump, defaultReplacementGasPriceBump)
+	conf.AddKnownKey(MaxDroppedResubmits, defaultMaxDroppedResubmits)
+
+	conf.AddKnownKey(GasEscalationPercentage, defaultGasEscalationPercentage)
+	conf.AddKnownKey(GasEscalationMinimumPercentage, defaultGasEscalationMinimumPercentage)
+	conf.AddKnownKey(GasEscalationMaxGasPrice)
+
 	gasOracleConfig := conf.SubSection(GasOracleConfig)
 	ffresty.InitConfig(gasOracleConfig)
 	gasOracleConfig.AddKnownKey(GasOracleMethod, defaultGasOracleMethod)
 	gasOracleConfig.AddKnownKey(GasOracleMode, defaultGasOracleMode)
 	gasOracleConfig.AddKnownKey(GasOracleQueryInterval, defaultGasOracleQueryInterval)
 	gasOracleConfig.AddKnownKey(GasOracleTemplate)
+	gasOracleConfig.AddKnownKey(GasOracleAggregation, defaultGasOracleAggregation)
+	gasOracleConfig.AddKnownKey(GasOraclePercentile, defaultGasOraclePercentile)
+	gasOracleConfig.AddKnownKey(GasOracleMaxDeviation, defaultGasOracleMaxDeviation)
+	gasOracleConfig.AddKnownKey(GasOracleMinSources, defaultGasOracleMinSources)
+	gasOracleConfig.AddKnownKey(GasOracleConnector, defaultGasOracleConnector)
+	initGasOracleSourcesConfig(gasOracleConfig)
 
 	// Init the deprecated policy engine config in case people are still using them
 	legacyConfig := tmconfig.DeprecatedPolicyEngineBaseConfig.SubSection(f.Name())
@@ -89,3 +188,13 @@ func (f *TransactionHandlerFactory) InitConfig(conf config.Section) {
 	legacyGasOracleConfig.AddKnownKey(GasOracleQueryInterval, defaultGasOracleQueryInterval)
 	legacyGasOracleConfig.AddKnownKey(GasOracleTemplate)
 }
+
+// initGasOracleSourcesConfig returns the array of gas oracle sources for aggregation. The keys of the entries are
+// only known to the array they are added to, so this is called again to read the entries.
+func initGasOracleSourcesConfig(gasOracleConfig config.Section) config.ArraySection {
+	sources := gasOracleConfig.SubArray(GasOracleSources)
+	sources.AddKnownKey(GasOracleMethod, defaultGasOracleMethod)
+	sources.AddKnownKey(GasOracleTemplate)
+	ffresty.InitConfig(sources.SubSection(GasOracleSourceHTTP))
+	return sources
+}
@@ -0,0 +1,136 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simple
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+// dependencyCheck is the reason a transaction cannot be submitted yet, because of a transaction it depends on
+type dependencyCheck struct {
+	id     string // the transaction being waited for
+	failed bool   // the transaction did not succeed, so never will
+}
+
+// checkDependenciesExist is called on submission, as a transaction that depends on one we do not know about could never be submitted
+func (sth *simpleTransactionHandler) checkDependenciesExist(ctx context.Context, txID string, dependsOn []string) error {
+	for _, depID := range dependsOn {
+		dep, err := sth.toolkit.TXPersistence.GetTransactionByID(ctx, depID)
+		if err != nil {
+			return err
+		}
+		if dep == nil {
+			return i18n.NewError(ctx, tmmsgs.MsgDependencyNotFound, txID, depID)
+		}
+	}
+	return nil
+}
+
+// isDependencyHeld returns true if we suspended the transaction for intervention, as a transaction it depends on did not succeed
+func isDependencyHeld(mtx *apitypes.ManagedTX, info *simplePolicyInfo) bool {
+	return mtx.Status == apitypes.TxStatusSuspended && info != nil && info.DependencyHeld
+}
+
+// checkDependencies returns nil if a transaction can be submitted as far as its dependencies are concerned.
+// Must be called for the in-flight transactions in order, as it records the signers that are waiting in the supplied map.
+// Later transactions from the same signer have later nonces, so could not be mined before a waiting transaction
+// even if submitted - so they wait too.
+func (sth *simpleTransactionHandler) checkDependencies(ctx context.Context, pending *pendingState, waitingSigners map[string]string) *dependencyCheck {
+	mtx := pending.mtx
+	if mtx.FirstSubmit != nil {
+		return nil
+	}
+	signer := strings.ToLower(mtx.From)
+	if !pending.dependenciesMet {
+		// The transactions depended on are looked up at most once per policy loop interval
+		if pending.waitingFor == nil || time.Since(pending.dependencyChecked) > sth.policyLoopInterval {
+			pending.waitingFor = sth.checkDependencyStatus(ctx, mtx)
+			pending.dependencyChecked = time.Now()
+		}
+		if pending.waitingFor != nil {
+			waitingSigners[signer] = mtx.ID
+			return pending.waitingFor
+		}
+		pending.dependenciesMet = true
+	}
+	if waitingFor := waitingSigners[signer]; waitingFor != "" {
+		return &dependencyCheck{id: waitingFor}
+	}
+	return nil
+}
+
+// checkDependencyStatus looks up the status of each transaction the supplied transaction depends on, returning the first that has not succeeded
+func (sth *simpleTransactionHandler) checkDependencyStatus(ctx context.Context, mtx *apitypes.ManagedTX) *dependencyCheck {
+	for _, depID := range mtx.DependsOn {
+		dep, err := sth.toolkit.TXPersistence.GetTransactionByID(ctx, depID)
+		switch {
+		case err != nil:
+			// We check again on the next cycle
+			log.L(ctx).Errorf("Failed to look up transaction %s, which transaction %s depends on: %s", depID, mtx.ID, err)
+			return &dependencyCheck{id: depID}
+		case dep == nil || dep.Status == apitypes.TxStatusFailed:
+			// A dependency that has been deleted will never succeed
+			return &dependencyCheck{id: depID, failed: true}
+		case dep.Status != apitypes.TxStatusSucceeded:
+			return &dependencyCheck{id: depID}
+		}
+	}
+	return nil
+}
+
+// processDependency is called for a transaction that cannot be submitted yet because of a dependency, and returns
+// true if it should be submitted anyway. If the dependency failed, depending on configuration we either cancel and
+// fail this transaction, or suspend it for intervention. If an operator resumes it, we submit it regardless.
+func (sth *simpleTransactionHandler) processDependency(ctx *RunContext) (submit bool, err error) {
+	mtx := ctx.TX
+	if !ctx.Dependency.failed {
+		log.L(ctx).Debugf("Transaction %s at nonce %s / %d waiting for transaction %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), ctx.Dependency.id)
+		ctx.SetSubStatus(apitypes.TxSubStatusWaiting)
+		return false, nil
+	}
+	if sth.dependencyFailure == DependencyFailureHold && ctx.Info.DependencyHeld {
+		if mtx.Status == apitypes.TxStatusSuspended {
+			// Held in the loop until an operator resumes it, so later transactions from the signer wait behind it
+			log.L(ctx).Debugf("Transaction %s at nonce %s / %d held as transaction %s did not succeed", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), ctx.Dependency.id)
+			ctx.SetSubStatus(apitypes.TxSubStatusWaiting)
+			return false, nil
+		}
+		// An operator resumed the transaction after we suspended it
+		log.L(ctx).Infof("Transaction %s at nonce %s / %d resumed after transaction %s did not succeed", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), ctx.Dependency.id)
+		return true, nil
+	}
+	failErr := i18n.NewError(ctx, tmmsgs.MsgDependencyFailed, mtx.ID, ctx.Dependency.id)
+	ctx.AddSubStatusAction(apitypes.TxActionCheckDependencies, fftypes.JSONAnyPtr(`{"dependency":"`+ctx.Dependency.id+`","mode":"`+sth.dependencyFailure+`"}`), fftypes.JSONAnyPtr(`{"error":"`+failErr.Error()+`"}`))
+	if sth.dependencyFailure == DependencyFailureFail {
+		return false, sth.cancelTransaction(ctx, failErr)
+	}
+	log.L(ctx).Warnf("Transaction %s at nonce %s / %d suspended: %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), failErr)
+	mtx.Status = apitypes.TxStatusSuspended
+	ctx.Info.DependencyHeld = true
+	ctx.UpdateType = Update
+	ctx.UpdatedInfo = true
+	ctx.TXUpdates.Status = &mtx.Status
+	return false, nil
+}
@@ -0,0 +1,353 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simple
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/txhandlermocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestDependenciesHandler(t *testing.T, dependencyFailure string) (*simpleTransactionHandler, func()) {
+	f, tk, mfc, conf, cleanup := newTestTransactionHandlerFactoryWithFilePersistence(t)
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(DependencyFailure, dependencyFailure)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+	sth.Init(sth.ctx, tk)
+
+	meh := tk.EventHandler.(*txhandlermocks.ManagedTxEventHandler)
+	meh.On("HandleEvent", mock.Anything, mock.Anything).Return(nil)
+	mfc.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(func(_ context.Context, req *ffcapi.TransactionSendRequest) *ffcapi.TransactionSendResponse {
+		return &ffcapi.TransactionSendResponse{TransactionHash: fmt.Sprintf("0x%d", req.Nonce.Int64())}
+	}, ffcapi.ErrorReason(""), nil)
+	return sth, cleanup
+}
+
+func setTestTXStatus(t *testing.T, sth *simpleTransactionHandler, txID string, status apitypes.TxStatus) {
+	err := sth.toolkit.TXPersistence.UpdateTransaction(sth.ctx, txID, &apitypes.TXUpdates{Status: &status})
+	assert.NoError(t, err)
+	resetTestPolicyCycles(sth)
+}
+
+func resetTestPolicyCycles(sth *simpleTransactionHandler) {
+	for _, p := range sth.inflight {
+		p.lastPolicyCycle = time.Time{}
+		p.dependencyChecked = time.Time{}
+	}
+}
+
+func TestPolicyLoopDependencies(t *testing.T) {
+	sth, cleanup := newTestDependenciesHandler(t, DependencyFailureFail)
+	defer cleanup()
+
+	// The second transaction from 0xbbbb is held behind the one waiting for its dependency, but 0xcccc is not
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	mtx := sendSampleTXWithHeaders(t, sth, "0xbbbb", 2000, apitypes.RequestHeaders{ID: "ns1:tx2", DependsOn: []string{"ns1:tx1"}})
+	assert.Equal(t, fftypes.FFStringArray{"ns1:tx1"}, mtx.DependsOn)
+	sendSampleTX(t, sth, "0xbbbb", 2001, "ns1:tx3")
+	sendSampleTX(t, sth, "0xcccc", 3000, "ns1:tx4")
+
+	runTestPolicyCycle(sth, true)
+	assert.Len(t, sth.inflight, 4)
+	for i, expectedHash := range []string{"0x1000", "", "", "0x3000"} {
+		assert.Equal(t, expectedHash, sth.inflight[i].mtx.TransactionHash)
+	}
+	assert.Equal(t, apitypes.TxSubStatusWaiting, sth.inflight[1].subStatus)
+	assert.Equal(t, &dependencyCheck{id: "ns1:tx1"}, sth.inflight[1].dependency)
+	assert.Equal(t, apitypes.TxSubStatusWaiting, sth.inflight[2].subStatus)
+	assert.Equal(t, &dependencyCheck{id: "ns1:tx2"}, sth.inflight[2].dependency)
+
+	// Once the dependency succeeds, both are submitted
+	setTestTXStatus(t, sth, "ns1:tx1", apitypes.TxStatusSucceeded)
+	runTestPolicyCycle(sth, false)
+	for i, expectedHash := range []string{"0x1000", "0x2000", "0x2001", "0x3000"} {
+		assert.Equal(t, expectedHash, sth.inflight[i].mtx.TransactionHash)
+		assert.Nil(t, sth.inflight[i].dependency)
+	}
+	assert.True(t, sth.inflight[1].dependenciesMet)
+}
+
+func TestPolicyLoopDependencyFailedCancel(t *testing.T) {
+	sth, cleanup := newTestDependenciesHandler(t, DependencyFailureFail)
+	defer cleanup()
+
+	mfc := sth.toolkit.Connector.(*ffcapimocks.ExtendedAPI)
+	mfc.On("TransactionCancel", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionCancelRequest) bool {
+		return req.From == "0xbbbb" && req.Nonce.Int64() == 2000
+	})).Return(&ffcapi.TransactionCancelResponse{TransactionHash: "0xcancel"}, ffcapi.ErrorReason(""), nil).Once()
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	sendSampleTXWithHeaders(t, sth, "0xbbbb", 2000, apitypes.RequestHeaders{ID: "ns1:tx2", DependsOn: []string{"ns1:tx1"}})
+	runTestPolicyCycle(sth, true)
+	assert.Equal(t, apitypes.TxSubStatusWaiting, sth.inflight[1].subStatus)
+
+	setTestTXStatus(t, sth, "ns1:tx1", apitypes.TxStatusFailed)
+	runTestPolicyCycle(sth, false)
+	assert.True(t, sth.inflight[1].remove)
+
+	rtx, err := sth.toolkit.TXPersistence.GetTransactionByIDWithStatus(sth.ctx, "ns1:tx2", true)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusFailed, rtx.Status)
+	assert.Regexp(t, "FF21127", rtx.ErrorMessage)
+	assert.Empty(t, rtx.TransactionHash)
+
+	mfc.AssertExpectations(t)
+}
+
+func TestPolicyLoopDependencyFailedHold(t *testing.T) {
+	sth, cleanup := newTestDependenciesHandler(t, DependencyFailureHold)
+	defer cleanup()
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	sendSampleTXWithHeaders(t, sth, "0xbbbb", 2000, apitypes.RequestHeaders{ID: "ns1:tx2", DependsOn: []string{"ns1:tx1"}})
+	sendSampleTX(t, sth, "0xbbbb", 2001, "ns1:tx3")
+	runTestPolicyCycle(sth, true)
+
+	// The dependency is deleted, so will never succeed
+	err := sth.toolkit.TXPersistence.DeleteTransaction(sth.ctx, "ns1:tx1")
+	assert.NoError(t, err)
+	sth.inflight[0].remove = true
+	resetTestPolicyCycles(sth)
+	runTestPolicyCycle(sth, false)
+	assert.False(t, sth.inflight[1].remove)
+
+	rtx, err := sth.toolkit.TXPersistence.GetTransactionByIDWithStatus(sth.ctx, "ns1:tx2", true)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusSuspended, rtx.Status)
+	assert.JSONEq(t, `{"lastWarnTime":null,"dependencyHeld":true}`, rtx.PolicyInfo.String())
+	assert.Equal(t, apitypes.TxActionCheckDependencies, rtx.History[len(rtx.History)-1].Actions[0].Action)
+
+	// The held transaction stays in flight, and the later transaction from the signer waits behind it
+	runTestPolicyCycle(sth, true)
+	resetTestPolicyCycles(sth)
+	runTestPolicyCycle(sth, false)
+	assert.Equal(t, []string{"ns1:tx2", "ns1:tx3"}, inflightIDs(sth))
+	held, waiting := sth.inflight[0], sth.inflight[1]
+	assert.Equal(t, apitypes.TxStatusSuspended, held.mtx.Status)
+	assert.Equal(t, apitypes.TxSubStatusWaiting, held.subStatus)
+	assert.Empty(t, held.mtx.TransactionHash)
+	assert.Equal(t, &dependencyCheck{id: "ns1:tx2"}, waiting.dependency)
+	assert.Empty(t, waiting.mtx.TransactionHash)
+
+	// Once an operator resumes it, it is submitted - followed by the later transaction
+	resume := ActionResume
+	err = sth.execPolicy(sth.ctx, held, &resume)
+	assert.NoError(t, err)
+	resetTestPolicyCycles(sth)
+	runTestPolicyCycle(sth, false)
+	assert.Equal(t, apitypes.TxStatusPending, held.mtx.Status)
+	assert.Equal(t, "0x2000", held.mtx.TransactionHash)
+	resetTestPolicyCycles(sth)
+	runTestPolicyCycle(sth, false)
+	assert.Equal(t, "0x2001", waiting.mtx.TransactionHash)
+}
+
+func TestPolicyLoopDependencyHeldSuspended(t *testing.T) {
+	sth, cleanup := newTestDependenciesHandler(t, DependencyFailureHold)
+	defer cleanup()
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	sendSampleTXWithHeaders(t, sth, "0xbbbb", 2000, apitypes.RequestHeaders{ID: "ns1:tx2", DependsOn: []string{"ns1:tx1"}})
+	runTestPolicyCycle(sth, true)
+	err := sth.toolkit.TXPersistence.DeleteTransaction(sth.ctx, "ns1:tx1")
+	assert.NoError(t, err)
+	sth.inflight[0].remove = true
+	resetTestPolicyCycles(sth)
+	runTestPolicyCycle(sth, true)
+	held := sth.inflight[0]
+	assert.Equal(t, apitypes.TxStatusSuspended, held.mtx.Status)
+
+	// An operator suspending a held transaction takes it out of the loop
+	suspend := ActionSuspend
+	err = sth.execPolicy(sth.ctx, held, &suspend)
+	assert.NoError(t, err)
+	assert.True(t, held.remove)
+}
+
+func TestPolicyLoopSuspendedNotHeldDropsOut(t *testing.T) {
+	sth, cleanup := newTestDependenciesHandler(t, DependencyFailureHold)
+	defer cleanup()
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	assert.True(t, sth.updateInflightSet(sth.ctx))
+
+	// Only transactions we held for a dependency, or suspended for insufficient funds, stay in the loop once suspended
+	sth.inflight[0].mtx.Status = apitypes.TxStatusSuspended
+	runTestPolicyCycle(sth, false)
+	assert.Equal(t, "0x1000", sth.inflight[0].mtx.TransactionHash)
+	assert.True(t, sth.inflight[0].remove)
+}
+
+func TestPolicyLoopDependencyWaitingMarksInflightStale(t *testing.T) {
+	sth, cleanup := newTestDependenciesHandler(t, DependencyFailureFail)
+	defer cleanup()
+	sth.maxInFlightPerSigner = 10
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	sendSampleTXWithHeaders(t, sth, "0xbbbb", 2000, apitypes.RequestHeaders{ID: "ns1:tx2", DependsOn: []string{"ns1:tx1"}})
+	<-sth.inflightStale
+
+	// With per-signer selection, a transaction starting to wait frees up space in the in-flight set
+	runTestPolicyCycle(sth, true)
+	assert.Equal(t, &dependencyCheck{id: "ns1:tx1"}, sth.inflight[1].dependency)
+	assert.Len(t, sth.inflightStale, 1)
+}
+
+func TestPolicyLoopDependencyLookupThrottled(t *testing.T) {
+	sth, cleanup := newTestDependenciesHandler(t, DependencyFailureFail)
+	defer cleanup()
+	sth.policyLoopInterval = 1 * time.Hour
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	sendSampleTXWithHeaders(t, sth, "0xbbbb", 2000, apitypes.RequestHeaders{ID: "ns1:tx2", DependsOn: []string{"ns1:tx1"}})
+	runTestPolicyCycle(sth, true)
+	waiting := sth.inflight[1]
+	checked := waiting.dependencyChecked
+	assert.False(t, checked.IsZero())
+
+	// The dependency is not looked up again until the policy loop interval has passed
+	setTestTXStatus(t, sth, "ns1:tx1", apitypes.TxStatusSucceeded)
+	waiting.dependencyChecked = checked
+	runTestPolicyCycle(sth, false)
+	assert.Equal(t, &dependencyCheck{id: "ns1:tx1"}, waiting.dependency)
+	assert.Equal(t, checked, waiting.dependencyChecked)
+
+	resetTestPolicyCycles(sth)
+	runTestPolicyCycle(sth, false)
+	assert.Nil(t, waiting.dependency)
+	assert.Equal(t, "0x2000", waiting.mtx.TransactionHash)
+}
+
+func TestSignerSelectionWaitingForDependency(t *testing.T) {
+
+	sth, mp := newTestSignerSelectionHandler(t, 2, func(conf map[string]interface{}) {
+		conf[PriorityEnabled] = true
+	})
+
+	// The in-flight set is full, but the transactions waiting for a dependency do not use up space - and
+	// no more are taken from the signer waiting, so the transaction it depends on can be selected
+	a1, a2, a3, b1 := newTestPendingTX("0xaaaa", 1), newTestPendingTX("0xaaaa", 2), newTestPendingTX("0xaaaa", 3), newTestPendingTX("0xbbbb", 4)
+	a3.Priority = 10
+	sth.inflight = []*pendingState{
+		{mtx: a1, dependency: &dependencyCheck{id: b1.ID}},
+		{mtx: a2, dependency: &dependencyCheck{id: a1.ID}},
+	}
+	mp.On("ListTransactionsPending", sth.ctx, "", 2, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a1, a2}, nil).Once()
+	mp.On("ListTransactionsPending", sth.ctx, a2.SequenceID, 2, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a3, b1}, nil).Once()
+	mp.On("ListTransactionsPending", sth.ctx, b1.SequenceID, 2, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xaaaa", nonceAfter(0), 2, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a1, a2}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xaaaa", nonceAfter(2), 2, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a3}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xbbbb", nonceAfter(3), 2, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{b1}, nil).Once()
+
+	assert.True(t, sth.updateInflightSet(sth.ctx))
+	assert.Equal(t, []string{a1.ID, a2.ID, b1.ID}, inflightIDs(sth))
+	assert.Equal(t, map[string]int{"0xaaaa": 1, "0xbbbb": 0}, sth.signerQueueDepth)
+
+	mp.AssertExpectations(t)
+}
+
+func TestDependencyHeldResumed(t *testing.T) {
+	f, tk, mfc, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(DependencyFailure, DependencyFailureHold)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+	sth := th.(*simpleTransactionHandler)
+	sth.Init(context.Background(), tk)
+
+	mfc.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{TransactionHash: "0x2000"}, ffcapi.ErrorReason(""), nil).Once()
+
+	// Having been suspended, an operator resumed the transaction - so it is submitted regardless of the dependency
+	mtx := &apitypes.ManagedTX{ID: "ns1:tx2", Status: apitypes.TxStatusPending, DependsOn: fftypes.FFStringArray{"ns1:tx1"}}
+	rc := newTestRunContext(mtx, nil)
+	rc.Info.DependencyHeld = true
+	rc.Dependency = &dependencyCheck{id: "ns1:tx1", failed: true}
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, "0x2000", mtx.TransactionHash)
+	assert.Equal(t, apitypes.TxStatusPending, mtx.Status)
+
+	mfc.AssertExpectations(t)
+}
+
+func TestDependenciesNotFound(t *testing.T) {
+	f, tk, _, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+	sth := th.(*simpleTransactionHandler)
+	sth.Init(context.Background(), tk)
+
+	mp := tk.TXPersistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionByID", mock.Anything, "ns1:tx1").Return(&apitypes.ManagedTX{}, nil)
+	mp.On("GetTransactionByID", mock.Anything, "ns1:unknown").Return(nil, nil)
+	mp.On("GetTransactionByID", mock.Anything, "ns1:error").Return(nil, fmt.Errorf("pop"))
+
+	_, err = sth.createManagedTx(context.Background(), "ns1:tx2", &apitypes.RequestHeaders{DependsOn: []string{"ns1:tx1", "ns1:unknown"}}, &ffcapi.TransactionHeaders{}, nil, "")
+	assert.Regexp(t, "FF21128.*ns1:unknown", err)
+
+	_, err = sth.createManagedTx(context.Background(), "ns1:tx2", &apitypes.RequestHeaders{DependsOn: []string{"ns1:error"}}, &ffcapi.TransactionHeaders{}, nil, "")
+	assert.Regexp(t, "pop", err)
+}
+
+func TestDependencyLookupFailWaits(t *testing.T) {
+	f, tk, _, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+	sth := th.(*simpleTransactionHandler)
+	sth.Init(context.Background(), tk)
+
+	mp := tk.TXPersistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionByID", mock.Anything, "ns1:tx1").Return(nil, fmt.Errorf("pop"))
+
+	pending := &pendingState{mtx: &apitypes.ManagedTX{ID: "ns1:tx2", DependsOn: fftypes.FFStringArray{"ns1:tx1"}}}
+	check := sth.checkDependencies(context.Background(), pending, map[string]string{})
+	assert.Equal(t, &dependencyCheck{id: "ns1:tx1"}, check)
+	assert.False(t, pending.dependenciesMet)
+}
+
+func TestDependencyFailureInvalid(t *testing.T) {
+	f, _, _, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(DependencyFailure, "wrong")
+	_, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.Regexp(t, "FF21126", err)
+}
@@ -0,0 +1,190 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simple
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// underfundedInfo records when a transaction was suspended because its signer could not pay for it, and what we estimate it will cost
+type underfundedInfo struct {
+	Since *fftypes.FFTime   `json:"since"`
+	Cost  *fftypes.FFBigInt `json:"cost"`
+}
+
+// isUnderfunded returns true if the transaction was suspended for insufficient funds, and has not been resumed since
+func isUnderfunded(mtx *apitypes.ManagedTX, info *simplePolicyInfo) bool {
+	return mtx.Status == apitypes.TxStatusSuspended && info != nil && info.InsufficientFunds != nil
+}
+
+// estimatedCost is the most we expect a transaction to cost its signer - the gas limit at the highest
+// numeric field of the gas price, plus the value transferred
+func estimatedCost(mtx *apitypes.ManagedTX, gasPrice *fftypes.JSONAny) *big.Int {
+	cost := new(big.Int)
+	if parsed, ok := parseGasPrice(gasPrice); ok {
+		mapGasPrice(parsed, func(i *big.Int) *big.Int {
+			if i.Cmp(cost) > 0 {
+				cost.Set(i)
+			}
+			return i
+		})
+	}
+	if mtx.Gas != nil {
+		cost.Mul(cost, mtx.Gas.Int())
+	}
+	if mtx.Value != nil {
+		cost.Add(cost, mtx.Value.Int())
+	}
+	return cost
+}
+
+// suspendUnderfunded suspends a transaction whose signer cannot pay for it. It stays in the in-flight set, so that
+// it can be resumed automatically once the balance of the signer covers its estimated cost.
+func (sth *simpleTransactionHandler) suspendUnderfunded(ctx *RunContext, suspendErr error) error {
+	mtx := ctx.TX
+	gasPrice := mtx.GasPrice
+	if gasPrice.IsNil() {
+		// A transaction suspended before it was submitted does not have a gas price yet, so we use the current one
+		var err error
+		if gasPrice, err = sth.getGasPrice(ctx, sth.toolkit.Connector); err != nil {
+			log.L(ctx).Warnf("Unable to retrieve gas price to estimate the cost of transaction %s: %s", mtx.ID, err)
+		}
+	}
+	cost := estimatedCost(mtx, gasPrice)
+	log.L(ctx).Warnf("Transaction %s at nonce %s / %d suspended for insufficient funds (cost=%s): %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), cost, suspendErr)
+	mtx.Status = apitypes.TxStatusSuspended
+	ctx.Info.InsufficientFunds = &underfundedInfo{
+		Since: fftypes.Now(),
+		Cost:  (*fftypes.FFBigInt)(cost),
+	}
+	ctx.UpdateType = Update
+	ctx.UpdatedInfo = true
+	ctx.TXUpdates.Status = &mtx.Status
+	ctx.SetSubStatus(apitypes.TxSubStatusUnderfunded)
+	ctx.AddSubStatusAction(apitypes.TxActionInsufficientFunds, fftypes.JSONAnyPtr(`{"cost":"`+cost.String()+`"}`), fftypes.JSONAnyPtr(`{"error":"`+suspendErr.Error()+`"}`))
+	sth.incTransactionOperationCounter(ctx, mtx.Namespace(ctx), "underfunded")
+	return suspendErr
+}
+
+// underfundedBy returns the ID of an earlier transaction from the same signer that is suspended for insufficient funds,
+// if this transaction should be suspended with it - which is only done for transactions that have not been submitted,
+// when configured to do so. Must be called for the in-flight transactions in order, with the map of underfunded
+// signers updated after each is processed.
+func (sth *simpleTransactionHandler) underfundedBy(pending *pendingState, underfundedSigners map[string]string) string {
+	mtx := pending.mtx
+	if !sth.fundsSuspendLater || mtx.FirstSubmit != nil || mtx.Status != apitypes.TxStatusPending {
+		return ""
+	}
+	return underfundedSigners[strings.ToLower(mtx.From)]
+}
+
+// processUnderfunded is called for a transaction we suspended for insufficient funds, and returns true if it has been
+// resumed - either because the balance of the signer now covers its cost, or because an operator resumed it.
+func (sth *simpleTransactionHandler) processUnderfunded(ctx *RunContext) bool {
+	mtx := ctx.TX
+	if mtx.Status == apitypes.TxStatusSuspended {
+		if ctx.FundedBalance == nil {
+			log.L(ctx).Debugf("Transaction %s at nonce %s / %d suspended for insufficient funds since %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), ctx.Info.InsufficientFunds.Since)
+			ctx.SetSubStatus(apitypes.TxSubStatusUnderfunded)
+			return false
+		}
+		mtx.Status = apitypes.TxStatusPending
+		ctx.TXUpdates.Status = &mtx.Status
+	}
+	underfundedSecs := time.Since(*ctx.Info.InsufficientFunds.Since.Time()).Seconds()
+	actionInfo := fmt.Sprintf(`{"cost":"%s","underfundedSeconds":%.2f}`, ctx.Info.InsufficientFunds.Cost, underfundedSecs)
+	if ctx.FundedBalance != nil {
+		actionInfo = fmt.Sprintf(`{"cost":"%s","balance":"%s","underfundedSeconds":%.2f}`, ctx.Info.InsufficientFunds.Cost, ctx.FundedBalance, underfundedSecs)
+	}
+	log.L(ctx).Infof("Transaction %s at nonce %s / %d resumed after %.2fs suspended for insufficient funds", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), underfundedSecs)
+	ctx.AddSubStatusAction(apitypes.TxActionFundsAvailable, fftypes.JSONAnyPtr(actionInfo), nil)
+	sth.recordTransactionOperationDuration(ctx, mtx.Namespace(ctx), "underfunded", underfundedSecs)
+	ctx.Info.InsufficientFunds = nil
+	ctx.UpdateType = Update
+	ctx.UpdatedInfo = true
+	return true
+}
+
+// checkSignerBalances queries the balance of each signer of the policy worker with transactions suspended for
+// insufficient funds, at most once per check interval, returning the balances that were retrieved.
+func (sth *simpleTransactionHandler) checkSignerBalances(ctx context.Context, w *policyWorker, partition []*pendingState) map[string]*big.Int {
+	balances := make(map[string]*big.Int)
+	lastChecked := make(map[string]time.Time)
+	for _, pending := range partition {
+		pending.execMux.Lock()
+		mtx := pending.mtx
+		underfunded := isUnderfunded(mtx, pending.info)
+		pending.execMux.Unlock()
+		signer := strings.ToLower(mtx.From)
+		if _, seen := lastChecked[signer]; seen || !underfunded {
+			continue
+		}
+		lastChecked[signer] = w.fundsLastChecked[signer]
+		if time.Since(lastChecked[signer]) < sth.fundsCheckInterval || sth.connectorUnavailable() {
+			continue
+		}
+		lastChecked[signer] = time.Now()
+		res, reason, err := sth.toolkit.Connector.AddressBalance(ctx, &ffcapi.AddressBalanceRequest{
+			Address:  mtx.From,
+			BlockTag: "latest",
+		})
+		sth.incTransactionOperationCounter(ctx, mtx.Namespace(ctx), "balance_check")
+		if err != nil {
+			// We check again after the next interval
+			log.L(ctx).Warnf("Failed to check the balance of signer %s (reason=%s): %s", mtx.From, reason, err)
+			continue
+		}
+		log.L(ctx).Debugf("Balance of underfunded signer %s is %s", mtx.From, res.Balance)
+		balances[signer] = new(big.Int).Set(res.Balance.Int())
+	}
+	// Signers no longer underfunded are forgotten, so they are checked straight away if they are again
+	w.fundsLastChecked = lastChecked
+	return balances
+}
+
+// checkFunded determines whether a transaction suspended for insufficient funds can be resumed, against the balances
+// just retrieved. Must be called for the in-flight transactions in order, as the cost of each transaction resumed is
+// deducted from the balance of the signer - and once one cannot be afforded, the transactions with later nonces stay
+// suspended too. The result is kept until the transaction is next processed.
+func checkFunded(pending *pendingState, balances map[string]*big.Int) {
+	if !isUnderfunded(pending.mtx, pending.info) {
+		pending.fundedBalance = nil
+		return
+	}
+	signer := strings.ToLower(pending.mtx.From)
+	remaining := balances[signer]
+	if remaining == nil {
+		return
+	}
+	cost := pending.info.InsufficientFunds.Cost.Int()
+	if remaining.Cmp(cost) < 0 {
+		delete(balances, signer)
+		pending.fundedBalance = nil
+		return
+	}
+	pending.fundedBalance = new(big.Int).Set(remaining)
+	remaining.Sub(remaining, cost)
+}
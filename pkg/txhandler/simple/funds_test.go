@@ -0,0 +1,231 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simple
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/txhandlermocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testTXCost = 100000 * 12345
+
+func newTestFundsHandler(t *testing.T, suspendLater bool, checkInterval string) (*simpleTransactionHandler, *ffcapimocks.ExtendedAPI, func()) {
+	f, tk, mfc, conf, cleanup := newTestTransactionHandlerFactoryWithFilePersistence(t)
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(FundsSuspendLater, suspendLater)
+	conf.Set(FundsCheckInterval, checkInterval)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+	sth.Init(sth.ctx, tk)
+
+	meh := tk.EventHandler.(*txhandlermocks.ManagedTxEventHandler)
+	meh.On("HandleEvent", mock.Anything, mock.Anything).Return(nil)
+	mfc.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{}, ffcapi.ErrorReason(""), nil)
+	return sth, mfc, cleanup
+}
+
+func mockBalance(mfc *ffcapimocks.ExtendedAPI, balance int64) {
+	mfc.On("AddressBalance", mock.Anything, &ffcapi.AddressBalanceRequest{Address: "0xaaaa", BlockTag: "latest"}).
+		Return(&ffcapi.AddressBalanceResponse{Balance: fftypes.NewFFBigInt(balance)}, ffcapi.ErrorReason(""), nil).Once()
+}
+
+func txActions(t *testing.T, sth *simpleTransactionHandler, txID string) []apitypes.TxAction {
+	rtx, err := sth.toolkit.TXPersistence.GetTransactionByIDWithStatus(sth.ctx, txID, true)
+	assert.NoError(t, err)
+	var actions []apitypes.TxAction
+	for _, h := range rtx.History {
+		for _, a := range h.Actions {
+			actions = append(actions, a.Action)
+		}
+	}
+	return actions
+}
+
+func TestPolicyLoopInsufficientFundsSuspendAndResume(t *testing.T) {
+	sth, mfc, cleanup := newTestFundsHandler(t, true, "0")
+	defer cleanup()
+
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonInsufficientFunds, fmt.Errorf("insufficient funds")).Once()
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(func(_ context.Context, req *ffcapi.TransactionSendRequest) *ffcapi.TransactionSendResponse {
+		return &ffcapi.TransactionSendResponse{TransactionHash: fmt.Sprintf("0x%d", req.Nonce.Int64())}
+	}, ffcapi.ErrorReason(""), nil)
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	sendSampleTX(t, sth, "0xaaaa", 1001, "ns1:tx2")
+
+	// The first transaction is suspended, and the later nonce with it - both stay in flight
+	runTestPolicyCycle(sth, true)
+	assert.Len(t, sth.inflight, 2)
+	for i, p := range sth.inflight {
+		assert.False(t, p.remove)
+		assert.Equal(t, apitypes.TxStatusSuspended, p.mtx.Status)
+		assert.Equal(t, apitypes.TxSubStatusUnderfunded, p.subStatus)
+		assert.Equal(t, int64(testTXCost), p.info.InsufficientFunds.Cost.Int64(), i)
+	}
+	rtx, err := sth.toolkit.TXPersistence.GetTransactionByID(sth.ctx, "ns1:tx1")
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusSuspended, rtx.Status)
+	assert.Regexp(t, "FF21130.*0xaaaa.*insufficient funds", rtx.ErrorMessage)
+	rtx, err = sth.toolkit.TXPersistence.GetTransactionByID(sth.ctx, "ns1:tx2")
+	assert.NoError(t, err)
+	assert.Regexp(t, "FF21131.*ns1:tx1", rtx.ErrorMessage)
+	assert.Contains(t, txActions(t, sth, "ns1:tx2"), apitypes.TxActionInsufficientFunds)
+
+	// The balance only covers the first transaction
+	mockBalance(mfc, testTXCost+1)
+	runTestPolicyCycle(sth, false)
+	assert.Equal(t, apitypes.TxStatusPending, sth.inflight[0].mtx.Status)
+	assert.Equal(t, "0x1000", sth.inflight[0].mtx.TransactionHash)
+	assert.Nil(t, sth.inflight[0].info.InsufficientFunds)
+	assert.Equal(t, apitypes.TxStatusSuspended, sth.inflight[1].mtx.Status)
+	assert.Contains(t, txActions(t, sth, "ns1:tx1"), apitypes.TxActionFundsAvailable)
+
+	// Once topped up, the second transaction is resumed too
+	mockBalance(mfc, testTXCost)
+	sth.inflight[1].lastPolicyCycle = time.Time{}
+	runTestPolicyCycle(sth, false)
+	assert.Equal(t, apitypes.TxStatusPending, sth.inflight[1].mtx.Status)
+	assert.Equal(t, "0x1001", sth.inflight[1].mtx.TransactionHash)
+	rtx, err = sth.toolkit.TXPersistence.GetTransactionByID(sth.ctx, "ns1:tx2")
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusPending, rtx.Status)
+	assert.Contains(t, txActions(t, sth, "ns1:tx2"), apitypes.TxActionFundsAvailable)
+
+	// Nothing left to check
+	runTestPolicyCycle(sth, false)
+	assert.Empty(t, sth.workers[0].fundsLastChecked)
+
+	mfc.AssertNumberOfCalls(t, "AddressBalance", 2)
+	mfc.AssertNumberOfCalls(t, "TransactionSend", 3)
+}
+
+func TestPolicyLoopInsufficientFundsLaterNonceSubmitted(t *testing.T) {
+	sth, mfc, cleanup := newTestFundsHandler(t, false, "1h")
+	defer cleanup()
+
+	mfc.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.Nonce.Int64() == 1000
+	})).Return(nil, ffcapi.ErrorReasonInsufficientFunds, fmt.Errorf("insufficient funds"))
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{TransactionHash: "0x1001"}, ffcapi.ErrorReason(""), nil)
+	mfc.On("AddressBalance", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	sendSampleTX(t, sth, "0xaaaa", 1001, "ns1:tx2")
+
+	runTestPolicyCycle(sth, true)
+	assert.Equal(t, apitypes.TxStatusSuspended, sth.inflight[0].mtx.Status)
+	assert.Equal(t, "0x1001", sth.inflight[1].mtx.TransactionHash)
+
+	// The balance check fails, and is not retried until the interval has passed
+	runTestPolicyCycle(sth, false)
+	runTestPolicyCycle(sth, false)
+	assert.Equal(t, apitypes.TxStatusSuspended, sth.inflight[0].mtx.Status)
+	assert.Contains(t, sth.workers[0].fundsLastChecked, "0xaaaa")
+
+	mfc.AssertNumberOfCalls(t, "AddressBalance", 1)
+}
+
+func TestPolicyLoopInsufficientFundsManualResumeAndSuspend(t *testing.T) {
+	sth, mfc, cleanup := newTestFundsHandler(t, false, "1h")
+	defer cleanup()
+
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonInsufficientFunds, fmt.Errorf("insufficient funds")).Once()
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{TransactionHash: "0x1000"}, ffcapi.ErrorReason(""), nil)
+	mfc.On("AddressBalance", mock.Anything, mock.Anything).Return(&ffcapi.AddressBalanceResponse{Balance: fftypes.NewFFBigInt(0)}, ffcapi.ErrorReason(""), nil)
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	runTestPolicyCycle(sth, true)
+	pending := sth.inflight[0]
+	assert.Equal(t, apitypes.TxStatusSuspended, pending.mtx.Status)
+
+	// An operator resuming it means we submit regardless of the balance
+	resume := ActionResume
+	err := sth.execPolicy(sth.ctx, pending, &resume)
+	assert.NoError(t, err)
+	runTestPolicyCycle(sth, false)
+	assert.Equal(t, apitypes.TxStatusPending, pending.mtx.Status)
+	assert.Equal(t, "0x1000", pending.mtx.TransactionHash)
+	assert.Nil(t, pending.info.InsufficientFunds)
+
+	// An operator suspending one we suspended for insufficient funds means it is no longer resumed automatically
+	pending.mtx.Status = apitypes.TxStatusSuspended
+	pending.info.InsufficientFunds = &underfundedInfo{Since: fftypes.Now(), Cost: fftypes.NewFFBigInt(1)}
+	suspend := ActionSuspend
+	err = sth.execPolicy(sth.ctx, pending, &suspend)
+	assert.NoError(t, err)
+	assert.True(t, pending.remove)
+	assert.Nil(t, pending.info.InsufficientFunds)
+}
+
+func TestSuspendUnderfundedNoGasPrice(t *testing.T) {
+	sth, mfc, cleanup := newTestFundsHandler(t, true, "1h")
+	defer cleanup()
+	sth.gasOracleMode = GasOracleModeConnector
+	mfc.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	ctx := &RunContext{
+		Context: sth.ctx,
+		TX: &apitypes.ManagedTX{
+			ID: "ns1:tx1",
+			TransactionHeaders: ffcapi.TransactionHeaders{
+				From:  "0xaaaa",
+				Value: fftypes.NewFFBigInt(100),
+			},
+		},
+		Info: &simplePolicyInfo{},
+	}
+	err := sth.suspendUnderfunded(ctx, fmt.Errorf("underfunded"))
+	assert.Regexp(t, "underfunded", err)
+	assert.Equal(t, int64(100), ctx.Info.InsufficientFunds.Cost.Int64())
+}
+
+func TestEstimatedCost(t *testing.T) {
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			Gas:   fftypes.NewFFBigInt(10),
+			Value: fftypes.NewFFBigInt(5),
+		},
+	}
+	assert.Equal(t, int64(5), estimatedCost(mtx, nil).Int64())
+	assert.Equal(t, int64(1005), estimatedCost(mtx, fftypes.JSONAnyPtr(`100`)).Int64())
+	assert.Equal(t, int64(2005), estimatedCost(mtx, fftypes.JSONAnyPtr(`{"maxPriorityFeePerGas":"0x64","maxFeePerGas":"200"}`)).Int64())
+}
+
+func TestCheckFundedResetWhenNotUnderfunded(t *testing.T) {
+	pending := &pendingState{
+		mtx:           &apitypes.ManagedTX{Status: apitypes.TxStatusPending},
+		fundedBalance: fftypes.NewFFBigInt(1).Int(),
+	}
+	checkFunded(pending, nil)
+	assert.Nil(t, pending.fundedBalance)
+
+	sth := &simpleTransactionHandler{fundsCheckInterval: time.Hour}
+	assert.Empty(t, sth.underfundedBy(pending, map[string]string{}))
+}
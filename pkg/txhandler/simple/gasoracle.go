@@ -0,0 +1,264 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simple
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"html/template"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs" // replace with your own messages if you are developing a customized transaction handler
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// gasOracleSource is a REST API gas oracle, with a Go template to extract the gas price from its response
+type gasOracleSource struct {
+	client   *resty.Client
+	method   string
+	template *template.Template
+}
+
+func newGasOracleSource(ctx context.Context, conf config.Section, httpConf config.Section) (*gasOracleSource, error) {
+	client, err := ffresty.New(ctx, httpConf)
+	if err != nil {
+		return nil, err
+	}
+	templateString := conf.GetString(GasOracleTemplate)
+	if templateString == "" {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgMissingGOTemplate)
+	}
+	template, err := template.New("").Funcs(sprig.FuncMap()).Parse(templateString)
+	if err != nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgBadGOTemplate, err)
+	}
+	return &gasOracleSource{
+		client:   client,
+		method:   conf.GetString(GasOracleMethod),
+		template: template,
+	}, nil
+}
+
+func (s *gasOracleSource) query(ctx context.Context) (gasPrice *fftypes.JSONAny, err error) {
+	res, err := s.client.R().
+		Execute(s.method, "")
+	if err != nil {
+		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgErrorQueryingGasOracleAPI, -1, err.Error())
+	}
+	if res.IsError() {
+		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgErrorQueryingGasOracleAPI, res.StatusCode(), res.RawResponse)
+	}
+	// Parse the response body as JSON
+	var data map[string]interface{}
+	err = json.Unmarshal(res.Body(), &data)
+	if err != nil {
+		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgInvalidJSONGasObject)
+	}
+	buff := new(bytes.Buffer)
+	err = s.template.Execute(buff, data)
+	if err != nil {
+		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgGasOracleResultError)
+	}
+	return fftypes.JSONAnyPtr(buff.String()), nil
+}
+
+func (sth *simpleTransactionHandler) initGasOracleAggregate(ctx context.Context, gasOracleConfig config.Section) error {
+	sth.gasOracleAggregation = gasOracleConfig.GetString(GasOracleAggregation)
+	sth.gasOraclePercentile = gasOracleConfig.GetInt(GasOraclePercentile)
+	sth.gasOracleMaxDeviation = gasOracleConfig.GetInt(GasOracleMaxDeviation)
+	sth.gasOracleMinSources = gasOracleConfig.GetInt(GasOracleMinSources)
+	sth.gasOracleConnector = gasOracleConfig.GetBool(GasOracleConnector)
+
+	switch sth.gasOracleAggregation {
+	case GasOracleAggregationMedian, GasOracleAggregationMean, GasOracleAggregationMin, GasOracleAggregationMax, GasOracleAggregationPercentile:
+	default:
+		return i18n.NewError(ctx, tmmsgs.MsgInvalidGasOracleAggregation, sth.gasOracleAggregation)
+	}
+	if sth.gasOraclePercentile < 0 || sth.gasOraclePercentile > 100 {
+		return i18n.NewError(ctx, tmmsgs.MsgInvalidGasOraclePercentile, sth.gasOraclePercentile)
+	}
+
+	// The size must be read before the entries, as reading an entry sets the defaults within the array
+	sourcesConfig := initGasOracleSourcesConfig(gasOracleConfig)
+	sourceCount := sourcesConfig.ArraySize()
+	for i := 0; i < sourceCount; i++ {
+		sourceConfig := sourcesConfig.ArrayEntry(i)
+		source, err := newGasOracleSource(ctx, sourceConfig, sourceConfig.SubSection(GasOracleSourceHTTP))
+		if err != nil {
+			return err
+		}
+		sth.gasOracleSources = append(sth.gasOracleSources, source)
+	}
+	if len(sth.gasOracleSources) == 0 && !sth.gasOracleConnector {
+		return i18n.NewError(ctx, tmmsgs.MsgNoGasOracleSources)
+	}
+	return nil
+}
+
+// getGasPriceAggregate queries all the gas oracle sources concurrently, and combines the gas prices that are returned
+func (sth *simpleTransactionHandler) getGasPriceAggregate(ctx context.Context, cAPI ffcapi.API) (*fftypes.JSONAny, error) {
+	results := make([]*fftypes.JSONAny, len(sth.gasOracleSources)+1)
+	var wg sync.WaitGroup
+	for i, source := range sth.gasOracleSources {
+		wg.Add(1)
+		go func(i int, source *gasOracleSource) {
+			defer wg.Done()
+			gasPrice, err := source.query(ctx)
+			if err != nil {
+				log.L(ctx).Warnf("Gas oracle source %d failed: %s", i, err)
+				return
+			}
+			results[i] = gasPrice
+		}(i, source)
+	}
+	if sth.gasOracleConnector {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, _, err := cAPI.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+			if err != nil {
+				log.L(ctx).Warnf("Gas price estimate from connector failed: %s", err)
+				return
+			}
+			results[len(results)-1] = res.GasPrice
+		}()
+	}
+	wg.Wait()
+	return sth.aggregateGasPrices(ctx, results)
+}
+
+// aggregateGasPrices combines the gas prices returned by the sources. Gas prices can be a single number, or an object
+// such as the EIP-1559 maxFeePerGas and maxPriorityFeePerGas - each numeric field of which is combined separately.
+// The form returned by most sources is used, and results in the other form (or that are not numeric) are discarded.
+func (sth *simpleTransactionHandler) aggregateGasPrices(ctx context.Context, results []*fftypes.JSONAny) (*fftypes.JSONAny, error) {
+	var numbers []interface{}
+	var objects []map[string]interface{}
+	sources := 0
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		sources++
+		parsed, _ := parseGasPrice(result)
+		if obj, ok := parsed.(map[string]interface{}); ok {
+			objects = append(objects, obj)
+		} else if _, ok := gasPriceInt(parsed); ok {
+			numbers = append(numbers, parsed)
+		} else {
+			log.L(ctx).Warnf("Discarding gas price that is not numeric: %s", result)
+		}
+	}
+
+	if len(objects) > 0 && len(objects) >= len(numbers) {
+		if len(objects) < sth.gasOracleMinSources {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgGasOracleAggregateInsufficient, len(objects), sources, sth.gasOracleMinSources)
+		}
+		fields := make(map[string][]interface{})
+		for _, obj := range objects {
+			for k, v := range obj {
+				if _, ok := gasPriceInt(v); ok {
+					fields[k] = append(fields[k], v)
+				}
+			}
+		}
+		combined := make(map[string]interface{}, len(fields))
+		for k, values := range fields {
+			combined[k] = sth.aggregateGasPriceValues(values)
+		}
+		return serializeGasPrice(combined), nil
+	}
+	if len(numbers) == 0 || len(numbers) < sth.gasOracleMinSources {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgGasOracleAggregateInsufficient, len(numbers), sources, sth.gasOracleMinSources)
+	}
+	return serializeGasPrice(sth.aggregateGasPriceValues(numbers)), nil
+}
+
+// aggregateGasPriceValues combines numeric values with the configured function, after discarding outliers,
+// returning the result in the same form as the first value
+func (sth *simpleTransactionHandler) aggregateGasPriceValues(values []interface{}) interface{} {
+	sorted := make([]*big.Int, len(values))
+	for i, v := range values {
+		sorted[i], _ = gasPriceInt(v)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+	sorted = discardGasPriceOutliers(sorted, sth.gasOracleMaxDeviation)
+
+	n := len(sorted)
+	var result *big.Int
+	switch sth.gasOracleAggregation {
+	case GasOracleAggregationMin:
+		result = sorted[0]
+	case GasOracleAggregationMax:
+		result = sorted[n-1]
+	case GasOracleAggregationMean:
+		sum := new(big.Int)
+		for _, v := range sorted {
+			sum.Add(sum, v)
+		}
+		result = sum.Div(sum, big.NewInt(int64(n)))
+	case GasOracleAggregationPercentile:
+		// Nearest rank
+		rank := (sth.gasOraclePercentile*n + 99) / 100
+		if rank < 1 {
+			rank = 1
+		}
+		result = sorted[rank-1]
+	default:
+		result = medianGasPrice(sorted)
+	}
+	return gasPriceValue(values[0], result)
+}
+
+func medianGasPrice(sorted []*big.Int) *big.Int {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	median := new(big.Int).Add(sorted[n/2-1], sorted[n/2])
+	return median.Div(median, big.NewInt(2))
+}
+
+// discardGasPriceOutliers removes the values that differ from the median by more than the maximum deviation percentage.
+// We need at least three values to tell which are outliers, and if every value would be discarded we keep them all.
+func discardGasPriceOutliers(sorted []*big.Int, maxDeviation int) []*big.Int {
+	if maxDeviation <= 0 || len(sorted) < 3 {
+		return sorted
+	}
+	median := medianGasPrice(sorted)
+	limit := new(big.Int).Mul(median, big.NewInt(int64(maxDeviation)))
+	kept := make([]*big.Int, 0, len(sorted))
+	for _, v := range sorted {
+		deviation := new(big.Int).Sub(v, median)
+		if deviation.Abs(deviation).Mul(deviation, big.NewInt(100)).Cmp(limit) <= 0 {
+			kept = append(kept, v)
+		}
+	}
+	if len(kept) == 0 {
+		return sorted
+	}
+	return kept
+}
@@ -0,0 +1,230 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simple
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestGasOracleServer(t *testing.T, body string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func gasOracleSourceConf(url, template string) map[string]interface{} {
+	return map[string]interface{}{
+		GasOracleTemplate: template,
+		GasOracleSourceHTTP: map[string]interface{}{
+			"url": url,
+		},
+	}
+}
+
+func setGasOracleSources(t *testing.T, sources ...interface{}) {
+	// Array entries can only be read from loaded config, rather than values set directly
+	b, err := json.Marshal(map[string]interface{}{
+		"unittest": map[string]interface{}{
+			"simple": map[string]interface{}{
+				GasOracleConfig: map[string]interface{}{
+					"aggregate": map[string]interface{}{
+						"sources": sources,
+					},
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	viper.SetConfigType("json")
+	err = viper.ReadConfig(bytes.NewReader(b))
+	assert.NoError(t, err)
+}
+
+func newTestAggregateHandler(t *testing.T, setup func(gasOracleConf config.Section)) (*simpleTransactionHandler, *ffcapimocks.ExtendedAPI, error) {
+	f, tk, mfc, conf := newTestTransactionHandlerFactory(t)
+	gasOracleConf := conf.SubSection(GasOracleConfig)
+	gasOracleConf.Set(GasOracleMode, GasOracleModeAggregate)
+	setup(gasOracleConf)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	if err != nil {
+		return nil, mfc, err
+	}
+	sth := th.(*simpleTransactionHandler)
+	sth.Init(context.Background(), tk)
+	return sth, mfc, nil
+}
+
+func TestGasOracleAggregateEIP1559(t *testing.T) {
+	template := `{"maxFeePerGas":{{.fast.maxFee}},"maxPriorityFeePerGas":{{.fast.maxPriorityFee}}}`
+	s1 := newTestGasOracleServer(t, `{"fast":{"maxFee":100,"maxPriorityFee":10}}`)
+	s2 := newTestGasOracleServer(t, `{"fast":{"maxFee":110,"maxPriorityFee":12}}`)
+	s3 := newTestGasOracleServer(t, `{"fast":{"maxFee":1000,"maxPriorityFee":11}}`)
+	s4 := newTestGasOracleServer(t, `{}`)
+	sth, mfc, err := newTestAggregateHandler(t, func(gasOracleConf config.Section) {
+		setGasOracleSources(t,
+			gasOracleSourceConf(s1.URL, template),
+			gasOracleSourceConf(s2.URL, template),
+			gasOracleSourceConf(s3.URL, template),
+			gasOracleSourceConf(s4.URL, `{{.missing}`+`}`),
+		)
+	})
+	assert.NoError(t, err)
+	assert.Len(t, sth.gasOracleSources, 4)
+	mfc.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`{"maxFeePerGas":"0x78","maxPriorityFeePerGas":"0xd"}`),
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	// The fourth source returns a gas price that is not numeric, and the maxFeePerGas from the third is an outlier
+	gasPrice, err := sth.getGasPrice(context.Background(), mfc)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"maxFeePerGas":110,"maxPriorityFeePerGas":11}`, gasPrice.String())
+
+	// The result is cached
+	gasPrice, err = sth.getGasPrice(context.Background(), mfc)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"maxFeePerGas":110,"maxPriorityFeePerGas":11}`, gasPrice.String())
+	mfc.AssertExpectations(t)
+}
+
+func TestGasOracleAggregateFailures(t *testing.T) {
+	s1 := newTestGasOracleServer(t, `{"price":"200"}`)
+	sth, mfc, err := newTestAggregateHandler(t, func(gasOracleConf config.Section) {
+		gasOracleConf.Set(GasOracleMinSources, 2)
+		setGasOracleSources(t,
+			gasOracleSourceConf(s1.URL, `{{.price}}`),
+			gasOracleSourceConf("http://localhost:0", `{{.price}}`),
+		)
+	})
+	assert.NoError(t, err)
+	assert.Len(t, sth.gasOracleSources, 2)
+	mfc.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	mfc.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`100`),
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	_, err = sth.getGasPrice(context.Background(), mfc)
+	assert.Regexp(t, "FF21135.*1 of the 1", err)
+
+	// With two results, there are no outliers
+	gasPrice, err := sth.getGasPrice(context.Background(), mfc)
+	assert.NoError(t, err)
+	assert.Equal(t, `150`, gasPrice.String())
+}
+
+func TestGasOracleAggregateNumbersMajority(t *testing.T) {
+	sth := &simpleTransactionHandler{
+		gasOracleAggregation: GasOracleAggregationMax,
+		gasOracleMinSources:  1,
+	}
+	gasPrice, err := sth.aggregateGasPrices(context.Background(), []*fftypes.JSONAny{
+		fftypes.JSONAnyPtr(`"0x10"`),
+		fftypes.JSONAnyPtr(`{"maxFeePerGas":100}`),
+		fftypes.JSONAnyPtr(`20`),
+		nil,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `"0x14"`, gasPrice.String())
+
+	_, err = sth.aggregateGasPrices(context.Background(), []*fftypes.JSONAny{nil})
+	assert.Regexp(t, "FF21135.*0 of the 0", err)
+
+	sth.gasOracleMinSources = 2
+	_, err = sth.aggregateGasPrices(context.Background(), []*fftypes.JSONAny{fftypes.JSONAnyPtr(`{"maxFeePerGas":100}`)})
+	assert.Regexp(t, "FF21135.*1 of the 1", err)
+}
+
+func TestGasOracleAggregateFunctions(t *testing.T) {
+	values := []interface{}{"100", "10", "40", "30"}
+	for function, expected := range map[string]string{
+		GasOracleAggregationMedian:     "35",
+		GasOracleAggregationMean:       "45",
+		GasOracleAggregationMin:        "10",
+		GasOracleAggregationMax:        "100",
+		GasOracleAggregationPercentile: "40",
+	} {
+		sth := &simpleTransactionHandler{
+			gasOracleAggregation: function,
+			gasOraclePercentile:  75,
+		}
+		assert.Equal(t, expected, sth.aggregateGasPriceValues(values), function)
+	}
+
+	sth := &simpleTransactionHandler{
+		gasOracleAggregation: GasOracleAggregationPercentile,
+		gasOraclePercentile:  0,
+	}
+	assert.Equal(t, "10", sth.aggregateGasPriceValues(values))
+	sth.gasOracleAggregation = GasOracleAggregationMedian
+	assert.Equal(t, "40", sth.aggregateGasPriceValues([]interface{}{"100", "10", "40"}))
+}
+
+func TestDiscardGasPriceOutliers(t *testing.T) {
+	ints := func(values ...int64) []*big.Int {
+		r := make([]*big.Int, len(values))
+		for i, v := range values {
+			r[i] = big.NewInt(v)
+		}
+		return r
+	}
+	assert.Equal(t, ints(90, 100, 110), discardGasPriceOutliers(ints(10, 90, 100, 110, 300), 50))
+	assert.Equal(t, ints(10, 300), discardGasPriceOutliers(ints(10, 300), 50))
+	assert.Equal(t, ints(10, 100, 300), discardGasPriceOutliers(ints(10, 100, 300), 0))
+	// Every value deviates from the median, so all are kept
+	assert.Equal(t, ints(1, 1, 100, 100), discardGasPriceOutliers(ints(1, 1, 100, 100), 50))
+}
+
+func TestGasOracleAggregateConfigErrors(t *testing.T) {
+	_, _, err := newTestAggregateHandler(t, func(gasOracleConf config.Section) {
+		gasOracleConf.Set(GasOracleAggregation, "wrong")
+	})
+	assert.Regexp(t, "FF21132", err)
+
+	_, _, err = newTestAggregateHandler(t, func(gasOracleConf config.Section) {
+		gasOracleConf.Set(GasOraclePercentile, 101)
+	})
+	assert.Regexp(t, "FF21133", err)
+
+	_, _, err = newTestAggregateHandler(t, func(gasOracleConf config.Section) {
+		gasOracleConf.Set(GasOracleConnector, false)
+	})
+	assert.Regexp(t, "FF21134", err)
+
+	_, _, err = newTestAggregateHandler(t, func(gasOracleConf config.Section) {
+		setGasOracleSources(t,
+			gasOracleSourceConf("http://localhost:0", ""),
+		)
+	})
+	assert.Regexp(t, "FF21024", err)
+}
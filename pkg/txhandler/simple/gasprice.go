@@ -0,0 +1,189 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simple
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+)
+
+// GasPricer calculates gas prices in place of the fixed gas price or gas oracle of the simple transaction handler,
+// so that other transaction handlers can reuse the simple handler with their own gas model.
+// Implementations must be safe for concurrent use, as the policy workers call them in parallel.
+type GasPricer interface {
+	// GasPrice returns the gas price to use for a new submission
+	GasPrice(ctx context.Context) (*fftypes.JSONAny, error)
+	// EscalateGasPrice returns the minimum gas price for the resubmission of a stale transaction, given the gas price
+	// it was last submitted with. Returning nil leaves the gas price to GasPrice.
+	EscalateGasPrice(ctx context.Context, previous *fftypes.JSONAny) *fftypes.JSONAny
+}
+
+// The gas price is an opaque JSON value/structure that is interpreted by the connector. It might be a simple
+// number, a decimal or hex string, or an object such as {"maxFeePerGas":...,"maxPriorityFeePerGas":...}.
+// These helpers operate on every numeric value found in that structure, and leave everything else intact.
+
+// BumpGasPrice increases every numeric value in the gas price by the given percentage, rounding up
+// so that the result is always strictly greater than the input. Transaction handlers that provide a GasPricer
+// can use it to escalate their own gas prices.
+func BumpGasPrice(gasPrice *fftypes.JSONAny, percent int) *fftypes.JSONAny {
+	parsed, ok := parseGasPrice(gasPrice)
+	if !ok {
+		return gasPrice
+	}
+	return serializeGasPrice(mapGasPrice(parsed, func(v *big.Int) *big.Int {
+		bumped := new(big.Int).Mul(v, big.NewInt(int64(100+percent)))
+		bumped.Add(bumped, big.NewInt(99))
+		bumped.Div(bumped, big.NewInt(100))
+		if bumped.Cmp(v) <= 0 {
+			bumped.Add(v, big.NewInt(1))
+		}
+		return bumped
+	}))
+}
+
+// maxGasPrice returns the latest gas price, with each numeric value raised to at least the value
+// of the same field in the floor. If the two cannot be compared, the floor is used.
+func maxGasPrice(latest, floor *fftypes.JSONAny) *fftypes.JSONAny {
+	parsedLatest, ok := parseGasPrice(latest)
+	if !ok {
+		return floor
+	}
+	parsedFloor, ok := parseGasPrice(floor)
+	if !ok {
+		return latest
+	}
+	merged, ok := mergeGasPrice(parsedLatest, parsedFloor, true)
+	if !ok {
+		return floor
+	}
+	return serializeGasPrice(merged)
+}
+
+// minGasPrice returns the gas price, with each numeric value reduced to at most the value
+// of the same field in the cap. If the two cannot be compared, the cap is used.
+func minGasPrice(gasPrice, cap *fftypes.JSONAny) *fftypes.JSONAny {
+	parsedGasPrice, ok := parseGasPrice(gasPrice)
+	if !ok {
+		return cap
+	}
+	parsedCap, ok := parseGasPrice(cap)
+	if !ok {
+		return gasPrice
+	}
+	merged, ok := mergeGasPrice(parsedGasPrice, parsedCap, false)
+	if !ok {
+		return cap
+	}
+	return serializeGasPrice(merged)
+}
+
+func parseGasPrice(gasPrice *fftypes.JSONAny) (interface{}, bool) {
+	if gasPrice.IsNil() {
+		return nil, false
+	}
+	var parsed interface{}
+	d := json.NewDecoder(bytes.NewReader(gasPrice.Bytes()))
+	d.UseNumber()
+	if err := d.Decode(&parsed); err != nil {
+		return nil, false
+	}
+	return parsed, true
+}
+
+func serializeGasPrice(v interface{}) *fftypes.JSONAny {
+	b, _ := json.Marshal(v)
+	return fftypes.JSONAnyPtrBytes(b)
+}
+
+// gasPriceInt extracts an integer from a JSON number, or a decimal/hex string
+func gasPriceInt(v interface{}) (*big.Int, bool) {
+	switch vt := v.(type) {
+	case json.Number:
+		return new(big.Int).SetString(vt.String(), 10)
+	case string:
+		return new(big.Int).SetString(vt, 0)
+	default:
+		return nil, false
+	}
+}
+
+// gasPriceValue formats an updated integer in the same form as the original value
+func gasPriceValue(original interface{}, i *big.Int) interface{} {
+	switch vt := original.(type) {
+	case string:
+		if strings.HasPrefix(vt, "0x") || strings.HasPrefix(vt, "0X") {
+			return fmt.Sprintf("0x%x", i)
+		}
+		return i.String()
+	default:
+		return json.Number(i.String())
+	}
+}
+
+func mapGasPrice(v interface{}, fn func(*big.Int) *big.Int) interface{} {
+	if obj, ok := v.(map[string]interface{}); ok {
+		for k, fv := range obj {
+			obj[k] = mapGasPrice(fv, fn)
+		}
+		return obj
+	}
+	if i, ok := gasPriceInt(v); ok {
+		return gasPriceValue(v, fn(i))
+	}
+	return v
+}
+
+// mergeGasPrice combines each numeric value in the latest gas price with the same field in the other,
+// keeping the higher of the two when raising, and the lower otherwise. Fields only in the other gas
+// price are only added when raising.
+func mergeGasPrice(latest, other interface{}, raise bool) (interface{}, bool) {
+	latestObj, latestIsObj := latest.(map[string]interface{})
+	otherObj, otherIsObj := other.(map[string]interface{})
+	switch {
+	case latestIsObj && otherIsObj:
+		for k, ov := range otherObj {
+			lv, ok := latestObj[k]
+			if !ok {
+				if raise {
+					latestObj[k] = ov
+				}
+				continue
+			}
+			if latestObj[k], ok = mergeGasPrice(lv, ov, raise); !ok {
+				return nil, false
+			}
+		}
+		return latestObj, true
+	case latestIsObj || otherIsObj:
+		return nil, false
+	}
+	li, lok := gasPriceInt(latest)
+	oi, ook := gasPriceInt(other)
+	if !lok || !ook {
+		return latest, lok == ook
+	}
+	if cmp := oi.Cmp(li); (raise && cmp > 0) || (!raise && cmp < 0) {
+		return gasPriceValue(latest, oi), true
+	}
+	return latest, true
+}
@@ -0,0 +1,65 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simple
+
+import (
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBumpGasPrice(t *testing.T) {
+	assert.Equal(t, `110`, BumpGasPrice(fftypes.JSONAnyPtr(`100`), 10).String())
+	assert.Equal(t, `"112"`, BumpGasPrice(fftypes.JSONAnyPtr(`"101"`), 10).String())
+	assert.Equal(t, `"0x6e"`, BumpGasPrice(fftypes.JSONAnyPtr(`"0x64"`), 10).String())
+	assert.Equal(t, `2`, BumpGasPrice(fftypes.JSONAnyPtr(`1`), 0).String())
+	assert.JSONEq(t, `{"maxFeePerGas":"0x6e","maxPriorityFeePerGas":11,"other":"abc","flag":true}`,
+		BumpGasPrice(fftypes.JSONAnyPtr(`{"maxFeePerGas":"0x64","maxPriorityFeePerGas":10,"other":"abc","flag":true}`), 10).String())
+	assert.Equal(t, `1.5`, BumpGasPrice(fftypes.JSONAnyPtr(`1.5`), 10).String())
+	assert.Equal(t, `!bad`, BumpGasPrice(fftypes.JSONAnyPtr(`!bad`), 10).String())
+	assert.Nil(t, BumpGasPrice(nil, 10))
+}
+
+func TestMaxGasPrice(t *testing.T) {
+	assert.Equal(t, `110`, maxGasPrice(fftypes.JSONAnyPtr(`100`), fftypes.JSONAnyPtr(`110`)).String())
+	assert.Equal(t, `120`, maxGasPrice(fftypes.JSONAnyPtr(`120`), fftypes.JSONAnyPtr(`110`)).String())
+	assert.Equal(t, `"0x6e"`, maxGasPrice(fftypes.JSONAnyPtr(`"0x64"`), fftypes.JSONAnyPtr(`110`)).String())
+	assert.JSONEq(t, `{"maxFeePerGas":200,"maxPriorityFeePerGas":"11","extra":5}`,
+		maxGasPrice(fftypes.JSONAnyPtr(`{"maxFeePerGas":200,"maxPriorityFeePerGas":"10"}`), fftypes.JSONAnyPtr(`{"maxFeePerGas":110,"maxPriorityFeePerGas":11,"extra":5}`)).String())
+	assert.Equal(t, `"abc"`, maxGasPrice(fftypes.JSONAnyPtr(`"abc"`), fftypes.JSONAnyPtr(`"def"`)).String())
+
+	// Cannot compare, so the floor wins
+	assert.Equal(t, `110`, maxGasPrice(fftypes.JSONAnyPtr(`"abc"`), fftypes.JSONAnyPtr(`110`)).String())
+	assert.Equal(t, `110`, maxGasPrice(fftypes.JSONAnyPtr(`{"a":1}`), fftypes.JSONAnyPtr(`110`)).String())
+	assert.Equal(t, `{"a":"x"}`, maxGasPrice(fftypes.JSONAnyPtr(`{"a":{"b":1}}`), fftypes.JSONAnyPtr(`{"a":"x"}`)).String())
+	assert.Equal(t, `110`, maxGasPrice(fftypes.JSONAnyPtr(`!bad`), fftypes.JSONAnyPtr(`110`)).String())
+	assert.Equal(t, `100`, maxGasPrice(fftypes.JSONAnyPtr(`100`), fftypes.JSONAnyPtr(`!bad`)).String())
+}
+
+func TestMinGasPrice(t *testing.T) {
+	assert.Equal(t, `100`, minGasPrice(fftypes.JSONAnyPtr(`100`), fftypes.JSONAnyPtr(`110`)).String())
+	assert.Equal(t, `110`, minGasPrice(fftypes.JSONAnyPtr(`120`), fftypes.JSONAnyPtr(`110`)).String())
+	assert.Equal(t, `"0x6e"`, minGasPrice(fftypes.JSONAnyPtr(`"0x78"`), fftypes.JSONAnyPtr(`110`)).String())
+	assert.JSONEq(t, `{"maxFeePerGas":110,"maxPriorityFeePerGas":"10"}`,
+		minGasPrice(fftypes.JSONAnyPtr(`{"maxFeePerGas":200,"maxPriorityFeePerGas":"10"}`), fftypes.JSONAnyPtr(`{"maxFeePerGas":110,"maxPriorityFeePerGas":11,"extra":5}`)).String())
+
+	// Cannot compare, so the cap wins
+	assert.Equal(t, `110`, minGasPrice(fftypes.JSONAnyPtr(`{"a":1}`), fftypes.JSONAnyPtr(`110`)).String())
+	assert.Equal(t, `110`, minGasPrice(fftypes.JSONAnyPtr(`!bad`), fftypes.JSONAnyPtr(`110`)).String())
+	assert.Equal(t, `100`, minGasPrice(fftypes.JSONAnyPtr(`100`), fftypes.JSONAnyPtr(`!bad`)).String())
+}
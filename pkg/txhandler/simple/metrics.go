@@ -18,6 +18,7 @@ package simple
 
 import (
 	"context"
+	"strconv"
 
 	"github.com/hyperledger/firefly-common/pkg/metric"
 )
@@ -27,6 +28,8 @@ func (sth *simpleTransactionHandler) initSimpleHandlerMetrics(ctx context.Contex
 	sth.toolkit.MetricsManager.InitTxHandlerHistogramMetricWithLabels(ctx, metricsHistogramTransactionProcessOperationsDuration, metricsHistogramTransactionProcessOperationsDurationDescription, []float64{} /*fallback to default buckets*/, []string{metricsLabelNameOperation}, true)
 	sth.toolkit.MetricsManager.InitTxHandlerGaugeMetric(ctx, metricsGaugeTransactionsInflightUsed, metricsGaugeTransactionsInflightUsedDescription, false)
 	sth.toolkit.MetricsManager.InitTxHandlerGaugeMetric(ctx, metricsGaugeTransactionsInflightFree, metricsGaugeTransactionsInflightFreeDescription, false)
+	sth.toolkit.MetricsManager.InitTxHandlerGaugeMetricWithLabels(ctx, metricsGaugeSignerQueueDepth, metricsGaugeSignerQueueDepthDescription, []string{metricsLabelNameSigner}, false)
+	sth.toolkit.MetricsManager.InitTxHandlerHistogramMetricWithLabels(ctx, metricsHistogramPolicyWorkerCycleDuration, metricsHistogramPolicyWorkerCycleDurationDescription, []float64{} /*fallback to default buckets*/, []string{metricsLabelNameWorker}, false)
 }
 
 func (sth *simpleTransactionHandler) setTransactionInflightQueueMetrics(ctx context.Context) {
@@ -41,3 +44,7 @@ func (sth *simpleTransactionHandler) incTransactionOperationCounter(ctx context.
 func (sth *simpleTransactionHandler) recordTransactionOperationDuration(ctx context.Context, fireflyNamespace string, operationName string, durationInSeconds float64) {
 	sth.toolkit.MetricsManager.ObserveTxHandlerHistogramMetricWithLabels(ctx, metricsHistogramTransactionProcessOperationsDuration, durationInSeconds, map[string]string{metricsLabelNameOperation: operationName}, &metric.FireflyDefaultLabels{Namespace: fireflyNamespace})
 }
+
+func (sth *simpleTransactionHandler) recordPolicyWorkerCycleDuration(ctx context.Context, worker int, durationInSeconds float64) {
+	sth.toolkit.MetricsManager.ObserveTxHandlerHistogramMetricWithLabels(ctx, metricsHistogramPolicyWorkerCycleDuration, durationInSeconds, map[string]string{metricsLabelNameWorker: strconv.Itoa(worker)}, nil)
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
@@ -43,11 +44,14 @@ const (
 	ActionDelete
 	ActionSuspend
 	ActionResume
+	ActionUpdate
+	ActionCancel
 )
 
 type policyEngineAPIRequest struct {
 	requestType policyEngineAPIRequestType
 	txID        string
+	update      *apitypes.TransactionUpdateRequest
 	startTime   time.Time
 	response    chan policyEngineAPIResponse
 }
@@ -62,6 +66,7 @@ type policyEngineAPIResponse struct {
 func (sth *simpleTransactionHandler) policyLoop() {
 	defer close(sth.policyLoopDone)
 	ctx := log.WithLogField(sth.ctx, "role", "policyloop")
+	defer waitPolicyWorkers(sth.startPolicyWorkers(ctx))
 	ticker := time.NewTicker(sth.policyLoopInterval)
 
 	for {
@@ -108,27 +113,46 @@ func (sth *simpleTransactionHandler) updateInflightSet(ctx context.Context) bool
 	sth.inflight = make([]*pendingState, 0, len(oldInflight))
 
 	// Run through removing those that are removed
+	sth.mux.Lock()
+	var removed []*pendingState
 	for _, p := range oldInflight {
 		if !p.remove {
 			sth.inflight = append(sth.inflight, p)
 		} else {
-			sth.incTransactionOperationCounter(ctx, p.mtx.Namespace(ctx), "removed")
+			removed = append(removed, p)
 		}
 	}
+	sth.mux.Unlock()
+	for _, p := range removed {
+		sth.incTransactionOperationCounter(ctx, p.mtx.Namespace(ctx), "removed")
+	}
 
 	// If we are not at maximum, then query if there are more candidates now
 	spaces := sth.maxInFlight - len(sth.inflight)
+	if sth.selectBySigner() {
+		// Transactions waiting for a dependency do not use up space, as with per-signer selection the transactions
+		// they depend on might not be next in line - so could otherwise never join them in flight.
+		// In sequence order the transactions depended on are always ahead of those that depend on them.
+		spaces = sth.maxInFlight - sth.inflightNotWaiting()
+	}
 	if spaces > 0 {
 		var after string
 		if len(sth.inflight) > 0 {
 			after = sth.inflight[len(sth.inflight)-1].mtx.SequenceID
 		}
 		var additional []*apitypes.ManagedTX
-		// We retry the get from persistence indefinitely (until the context cancels)
-		err := sth.retry.Do(ctx, "get pending transactions", func(attempt int) (retry bool, err error) {
-			additional, err = sth.toolkit.TXPersistence.ListTransactionsPending(ctx, after, spaces, 0)
-			return true, err
-		})
+		var err error
+		if sth.selectBySigner() {
+			// We need to look beyond the head of the pending queue, so that one signer with
+			// a large backlog does not starve the others
+			additional, err = sth.selectPendingBySigner(ctx, spaces)
+		} else {
+			// We retry the get from persistence indefinitely (until the context cancels)
+			err = sth.retry.Do(ctx, "get pending transactions", func(attempt int) (retry bool, err error) {
+				additional, err = sth.toolkit.TXPersistence.ListTransactionsPending(ctx, after, spaces, 0)
+				return true, err
+			})
+		}
 		if err != nil {
 			log.L(ctx).Infof("Policy loop context cancelled while retrying")
 			return false
@@ -160,6 +184,19 @@ func (sth *simpleTransactionHandler) updateInflightSet(ctx context.Context) bool
 
 }
 
+// inflightNotWaiting returns the number of in-flight transactions that are not waiting for a dependency
+func (sth *simpleTransactionHandler) inflightNotWaiting() int {
+	sth.mux.Lock()
+	defer sth.mux.Unlock()
+	count := 0
+	for _, p := range sth.inflight {
+		if p.dependency == nil {
+			count++
+		}
+	}
+	return count
+}
+
 func (sth *simpleTransactionHandler) policyLoopCycle(ctx context.Context, inflightStale bool) {
 
 	// Process any synchronous commands first - these might not be in our inflight set
@@ -170,15 +207,33 @@ func (sth *simpleTransactionHandler) policyLoopCycle(ctx context.Context, inflig
 			return
 		}
 	}
-	// Go through executing the policy engine against them
+	// Wake the policy workers to execute the policy engine against them
+	// Transactions from different signers are processed concurrently by the policy workers
+	sth.assignPolicyWorkers()
 
-	for _, pending := range sth.inflight {
-		err := sth.execPolicy(ctx, pending, nil)
-		if err != nil {
-			log.L(ctx).Errorf("Failed policy cycle transaction=%s operation=%s: %s", pending.mtx.TransactionHash, pending.mtx.ID, err)
-		}
+}
+
+// scheduledNotBefore returns the time before which a transaction must not be submitted, or nil if it can be submitted now.
+// Must be called for the in-flight transactions in order, as it records the signers that are held in the supplied map.
+func scheduledNotBefore(mtx *apitypes.ManagedTX, heldSigners map[string]*fftypes.FFTime) *fftypes.FFTime {
+	if mtx.FirstSubmit != nil {
+		return nil
 	}
+	signer := strings.ToLower(mtx.From)
+	notBefore := mtx.NotBefore
+	if held := heldSigners[signer]; held != nil && (notBefore == nil || held.Time().After(*notBefore.Time())) {
+		notBefore = held
+	}
+	if notBefore == nil || !time.Now().Before(*notBefore.Time()) {
+		return nil
+	}
+	heldSigners[signer] = notBefore
+	return notBefore
+}
 
+// connectorUnavailable returns true if the circuit breaker in front of the connector is open
+func (sth *simpleTransactionHandler) connectorUnavailable() bool {
+	return sth.toolkit.CircuitBreaker != nil && sth.toolkit.CircuitBreaker.IsOpen()
 }
 
 func (sth *simpleTransactionHandler) getTransactionByID(ctx context.Context, txID string) (transaction *apitypes.ManagedTX, err error) {
@@ -219,27 +274,49 @@ func (sth *simpleTransactionHandler) processPolicyAPIRequests(ctx context.Contex
 			}
 			// This transaction was valid, but outside of our in-flight set - we still evaluate the policy engine in-line for it.
 			// This does NOT cause it to be added to the in-flight set
-			pending = &pendingState{mtx: mtx, subStatus: apitypes.TxSubStatusReceived}
+			var info simplePolicyInfo
+			_ = json.Unmarshal(mtx.PolicyInfo.Bytes(), &info)
+			pending = &pendingState{mtx: mtx, info: &info, subStatus: apitypes.TxSubStatusReceived}
 		}
 
-		switch request.requestType {
-		case ActionDelete, ActionSuspend, ActionResume:
-			if err := sth.execPolicy(ctx, pending, &request.requestType); err != nil {
-				request.response <- policyEngineAPIResponse{err: err}
-			} else {
-				res := policyEngineAPIResponse{tx: pending.mtx, status: http.StatusAccepted}
-				if pending.remove || request.requestType == ActionResume /* always sync */ {
-					res.status = http.StatusOK // synchronously completed
-				}
-				request.response <- res
-			}
-		default:
-			request.response <- policyEngineAPIResponse{
-				err: i18n.NewError(ctx, tmmsgs.MsgTransactionHandlerRequestInvalid, request.requestType),
+		// The policy worker that owns the transaction might be executing the policy engine against it
+		pending.execMux.Lock()
+		sth.execPolicyAPIRequest(ctx, pending, request)
+		pending.execMux.Unlock()
+	}
+
+}
+
+// execPolicyAPIRequest executes the policy engine for an API request. Must be called with the exec lock of the transaction held.
+func (sth *simpleTransactionHandler) execPolicyAPIRequest(ctx context.Context, pending *pendingState, request *policyEngineAPIRequest) {
+	switch request.requestType {
+	case ActionDelete, ActionSuspend, ActionResume:
+		if err := sth.execPolicy(ctx, pending, &request.requestType); err != nil {
+			request.response <- policyEngineAPIResponse{err: err}
+		} else {
+			res := policyEngineAPIResponse{tx: pending.mtx, status: http.StatusAccepted}
+			sth.mux.Lock()
+			removed := pending.remove
+			sth.mux.Unlock()
+			if removed || request.requestType == ActionResume /* always sync */ {
+				res.status = http.StatusOK // synchronously completed
 			}
+			request.response <- res
+		}
+	case ActionUpdate, ActionCancel:
+		sth.mux.Lock()
+		pending.update = request.update
+		sth.mux.Unlock()
+		if err := sth.execPolicy(ctx, pending, &request.requestType); err != nil {
+			request.response <- policyEngineAPIResponse{err: err}
+		} else {
+			request.response <- policyEngineAPIResponse{tx: pending.mtx, status: http.StatusOK /* always sync */}
+		}
+	default:
+		request.response <- policyEngineAPIResponse{
+			err: i18n.NewError(ctx, tmmsgs.MsgTransactionHandlerRequestInvalid, request.requestType),
 		}
 	}
-
 }
 
 func (sth *simpleTransactionHandler) pendingToRunContext(baseCtx context.Context, pending *pendingState, syncRequest *policyEngineAPIRequestType) (ctx *RunContext, err error) {
@@ -254,9 +331,17 @@ func (sth *simpleTransactionHandler) pendingToRunContext(baseCtx context.Context
 		Confirmations: pending.confirmations,
 		Receipt:       pending.receipt,
 		Info:          pending.info,
+		NotBefore:     pending.notBefore,
+		Dependency:    pending.dependency,
+		FundedBalance: pending.fundedBalance,
+		UnderfundedBy: pending.underfundedBy,
+		Update:        pending.update,
+		HighestBlock:  sth.highestBlock,
 	}
+	pending.update = nil
 	confirmNotify := pending.confirmNotify
 	receiptNotify := pending.receiptNotify
+	receiptHash := pending.receiptHash
 	if syncRequest != nil {
 		ctx.SyncAction = *syncRequest
 	}
@@ -270,6 +355,13 @@ func (sth *simpleTransactionHandler) pendingToRunContext(baseCtx context.Context
 
 	// Process any state updates that were queued to us from notifications from the confirmation manager
 	if receiptNotify != nil {
+		if receiptHash != "" && receiptHash != mtx.TransactionHash {
+			// An earlier submission at the same nonce (or the cancellation of the transaction) was mined, rather than the latest
+			log.L(ctx).Infof("Receipt received for transaction %s at nonce %s / %d with hash %s, which replaces %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), receiptHash, mtx.TransactionHash)
+			mtx.TransactionHash = receiptHash
+			ctx.UpdateType = Update
+			ctx.TXUpdates.TransactionHash = &mtx.TransactionHash
+		}
 		log.L(ctx).Debugf("Receipt received for transaction %s at nonce %s / %d - hash: %s", pending.mtx.ID, pending.mtx.TransactionHeaders.From, pending.mtx.Nonce.Int64(), pending.mtx.TransactionHash)
 		if err := sth.toolkit.TXPersistence.SetTransactionReceipt(ctx, mtx.ID, ctx.Receipt); err != nil {
 			return nil, err
@@ -315,24 +407,49 @@ func (sth *simpleTransactionHandler) execPolicy(baseCtx context.Context, pending
 	mtx := ctx.TX
 
 	completed := false
+	var syncErr error
 	switch {
+	case ctx.SyncAction == ActionUpdate || ctx.SyncAction == ActionCancel:
+		// Replacements requested through the API are made straight away, and any error is returned to the caller
+		// rather than being stored on the transaction
+		if syncErr = sth.replaceTransaction(ctx); syncErr == nil {
+			completed = mtx.Status == apitypes.TxStatusFailed
+			sth.trackSubmittedHashes(ctx, pending)
+		}
 	case ctx.Confirmed && ctx.SyncAction != ActionDelete:
 		completed = true
 		ctx.UpdateType = Update
-		if ctx.Receipt != nil && ctx.Receipt.Success {
+		if ctx.Receipt != nil && ctx.Receipt.Success && !isCancelled(mtx, ctx.Info) {
 			mtx.Status = apitypes.TxStatusSucceeded
 			ctx.TXUpdates.Status = &mtx.Status
 		} else {
 			mtx.Status = apitypes.TxStatusFailed
 			ctx.TXUpdates.Status = &mtx.Status
+			if isCancelled(mtx, ctx.Info) {
+				// The cancellation records why it was made, if it was not requested through the API
+				errMsg := ctx.Info.CancelError
+				if errMsg == "" {
+					errMsg = i18n.NewError(ctx, tmmsgs.MsgTransactionCancelled, mtx.ID).Error()
+				}
+				mtx.ErrorMessage = errMsg
+				ctx.TXUpdates.ErrorMessage = &errMsg
+			}
 		}
+		// The hashes of any other submissions at the same nonce can no longer be mined
+		sth.untrackHashes(ctx, pending, mtx.TransactionHash)
 	case ctx.SyncAction == ActionSuspend:
-		// Whole cycle is a no-op if we're not pending
-		if mtx.Status == apitypes.TxStatusPending {
+		// Whole cycle is a no-op if we're not pending, unless we suspended it for insufficient funds - in which
+		// case it will no longer be resumed automatically - or held it in the loop as a dependency did not succeed
+		underfunded := isUnderfunded(mtx, ctx.Info)
+		if mtx.Status == apitypes.TxStatusPending || underfunded || isDependencyHeld(mtx, ctx.Info) {
 			ctx.UpdateType = Update
 			completed = true // drop it out of the loop
 			mtx.Status = apitypes.TxStatusSuspended
 			ctx.TXUpdates.Status = &mtx.Status
+			if underfunded {
+				ctx.Info.InsufficientFunds = nil
+				ctx.UpdatedInfo = true
+			}
 		}
 	case ctx.SyncAction == ActionResume:
 		// Whole cycle is a no-op if we're not suspended
@@ -346,11 +463,29 @@ func (sth *simpleTransactionHandler) execPolicy(baseCtx context.Context, pending
 		// to drive the policy engine at regular intervals.
 		// So we track the last time we ran the policy engine against each pending item.
 		// We always call the policy engine on every loop, when deletion has been requested.
-		if ctx.SyncAction == ActionDelete || time.Since(pending.lastPolicyCycle) > sth.policyLoopInterval {
+		// While the connector circuit breaker is open we skip the policy engine, as any calls it makes to the
+		// connector would be rejected (we still process receipts and confirmations above).
+		runPolicy := ctx.SyncAction == ActionDelete || time.Since(pending.lastPolicyCycle) > sth.policyLoopInterval
+		if runPolicy && ctx.SyncAction != ActionDelete && sth.connectorUnavailable() {
+			log.L(ctx).Debugf("Connector circuit breaker open - skipping policy engine for transaction %s", mtx.ID)
+			runPolicy = false
+		}
+		if runPolicy {
 			// Pass the state to the pluggable policy engine to potentially perform more actions against it,
 			// such as submitting for the first time, or raising the gas etc.
 
 			policyError := sth.processTransaction(ctx)
+			if mtx.Status == apitypes.TxStatusSuspended && !isUnderfunded(mtx, ctx.Info) && !isDependencyHeld(mtx, ctx.Info) {
+				// The policy engine has suspended the transaction for intervention, so it drops out of the loop.
+				// Transactions suspended for insufficient funds stay in the loop, to be resumed once the signer has been topped up.
+				// Transactions held as a dependency did not succeed stay in the loop, so later transactions from the signer wait behind them.
+				completed = true
+			}
+			if mtx.Status == apitypes.TxStatusFailed {
+				// The policy engine has determined the transaction can never succeed, so it drops out of the loop
+				completed = true
+				sth.untrackHashes(ctx, pending, "")
+			}
 			if policyError != nil {
 				log.L(ctx).Errorf("Policy engine returned error for transaction %s: %s", mtx.ID, policyError)
 				ctx.UpdateType = Update
@@ -360,39 +495,18 @@ func (sth *simpleTransactionHandler) execPolicy(baseCtx context.Context, pending
 				ctx.TXUpdates.ErrorMessage = &errMsg
 			} else {
 				log.L(ctx).Debugf("Policy engine executed for tx %s (update=%d,status=%s,hash=%s)", mtx.ID, ctx.UpdateType, mtx.Status, mtx.TransactionHash)
-				if mtx.FirstSubmit != nil && pending.trackingTransactionHash != mtx.TransactionHash {
-
-					if pending.trackingTransactionHash != "" {
-						// if had a previous transaction hash, emit an event to for transaction hash removal
-						eventTX := *mtx
-						eventTX.TransactionHash = pending.trackingTransactionHash // using the previous hash
-						if err = sth.toolkit.EventHandler.HandleEvent(ctx, apitypes.ManagedTransactionEvent{
-							Type: apitypes.ManagedTXTransactionHashRemoved,
-							Tx:   &eventTX,
-						}); err != nil {
-							log.L(ctx).Infof("Error detected notifying confirmation manager to remove old transaction hash: %s", err.Error())
-						}
-					}
-
+				if mtx.FirstSubmit != nil {
 					// If now submitted, add to confirmations manager for receipt checking
-					err = sth.toolkit.EventHandler.HandleEvent(ctx, apitypes.ManagedTransactionEvent{
-						Type: apitypes.ManagedTXTransactionHashAdded,
-						Tx:   mtx,
-					})
-					if err != nil {
-						log.L(ctx).Infof("Error detected notifying confirmation manager to add new transaction hash: %s", err.Error())
-
-						sth.incTransactionOperationCounter(ctx, mtx.Namespace(ctx), "tracking_failed")
-					} else {
-						pending.trackingTransactionHash = mtx.TransactionHash
-						sth.incTransactionOperationCounter(ctx, mtx.Namespace(ctx), "tracking")
-					}
+					sth.trackSubmittedHashes(ctx, pending)
 				}
 				pending.lastPolicyCycle = time.Now()
 			}
 		}
 	}
-	return sth.flushChanges(ctx, pending, completed)
+	if err := sth.flushChanges(ctx, pending, completed); err != nil {
+		return err
+	}
+	return syncErr
 }
 
 func (sth *simpleTransactionHandler) flushChanges(ctx *RunContext, pending *pendingState, completed bool) (err error) {
@@ -419,9 +533,12 @@ func (sth *simpleTransactionHandler) flushChanges(ctx *RunContext, pending *pend
 		}
 		if ctx.SyncAction == ActionResume {
 			log.L(ctx).Infof("Transaction %s resumed", mtx.ID)
-			sth.markInflightStale() // this won't be in the in-flight set, so we need to pull it in if there's space
+			sth.addPendingSigner(mtx) // created before the last scan for new signers, so we need to query its signer again
+			sth.markInflightStale()   // this won't be in the in-flight set, so we need to pull it in if there's space
 		} else if completed {
+			sth.mux.Lock()
 			pending.remove = true // for the next time round the loop
+			sth.mux.Unlock()
 			log.L(ctx).Infof("Transaction %s removed from tracking (status=%s): %s", mtx.ID, mtx.Status, err)
 			sth.markInflightStale()
 
@@ -448,7 +565,9 @@ func (sth *simpleTransactionHandler) flushChanges(ctx *RunContext, pending *pend
 			log.L(ctx).Errorf("Failed to delete transaction %s (status=%s): %s", mtx.ID, mtx.Status, err)
 			return err
 		}
+		sth.mux.Lock()
 		pending.remove = true // for the next time round the loop
+		sth.mux.Unlock()
 		sth.markInflightStale()
 		// dispatch an event to event handler
 		// and discard any handling errors
@@ -462,12 +581,12 @@ func (sth *simpleTransactionHandler) flushChanges(ctx *RunContext, pending *pend
 }
 
 func (sth *simpleTransactionHandler) policyEngineAPIRequest(ctx context.Context, req *policyEngineAPIRequest) policyEngineAPIResponse {
+	req.response = make(chan policyEngineAPIResponse, 1)
+	req.startTime = time.Now()
 	sth.mux.Lock()
 	sth.policyEngineAPIRequests = append(sth.policyEngineAPIRequests, req)
 	sth.mux.Unlock()
 	sth.markInflightUpdate()
-	req.response = make(chan policyEngineAPIResponse, 1)
-	req.startTime = time.Now()
 	select {
 	case res := <-req.response:
 		return res
@@ -498,10 +617,28 @@ func (sth *simpleTransactionHandler) HandleTransactionConfirmations(ctx context.
 	log.L(ctx).Infof("Received %d confirmations (resync=%t)", len(notification.Confirmations), notification.NewFork)
 	sth.mux.Unlock()
 
-	sth.markInflightUpdate()
+	sth.wakePolicyWorker(pending.mtx.From)
 	return
 }
+
+// HandleNewBlock records the new highest block, and if transactions can become stale after a number of
+// blocks, wakes up the policy loop to check
+func (sth *simpleTransactionHandler) HandleNewBlock(ctx context.Context, blockNumber uint64) {
+	sth.mux.Lock()
+	if blockNumber > sth.highestBlock {
+		sth.highestBlock = blockNumber
+	}
+	sth.mux.Unlock()
+	if sth.staleBlocks > 0 {
+		sth.markInflightUpdate()
+	}
+}
+
 func (sth *simpleTransactionHandler) HandleTransactionReceiptReceived(ctx context.Context, txID string, receipt *ffcapi.TransactionReceiptResponse) (err error) {
+	return sth.handleReceipt(ctx, txID, "", receipt)
+}
+
+func (sth *simpleTransactionHandler) handleReceipt(ctx context.Context, txID, hash string, receipt *ffcapi.TransactionReceiptResponse) (err error) {
 	var pending *pendingState
 	for _, p := range sth.inflight {
 		if p.mtx.ID == txID {
@@ -517,7 +654,8 @@ func (sth *simpleTransactionHandler) HandleTransactionReceiptReceived(ctx contex
 	sth.mux.Lock()
 	pending.receiptNotify = fftypes.Now()
 	pending.receipt = receipt
+	pending.receiptHash = hash
 	sth.mux.Unlock()
-	sth.markInflightUpdate()
+	sth.wakePolicyWorker(pending.mtx.From)
 	return
 }
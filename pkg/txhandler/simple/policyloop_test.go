@@ -32,6 +32,7 @@ import (
 	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/metricsmocks"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/txhandlermocks"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/wsmocks"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
@@ -40,7 +41,19 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
+// runTestPolicyCycle runs a cycle of the policy loop, followed by a cycle of each policy worker
+func runTestPolicyCycle(sth *simpleTransactionHandler, inflightStale bool) {
+	sth.policyLoopCycle(sth.ctx, inflightStale)
+	for _, w := range sth.workers {
+		sth.policyWorkerCycle(sth.ctx, w)
+	}
+}
+
 func sendSampleTX(t *testing.T, sth *simpleTransactionHandler, signer string, nonce int64, txID string) *apitypes.ManagedTX {
+	return sendSampleTXWithHeaders(t, sth, signer, nonce, apitypes.RequestHeaders{ID: txID})
+}
+
+func sendSampleTXWithHeaders(t *testing.T, sth *simpleTransactionHandler, signer string, nonce int64, headers apitypes.RequestHeaders) *apitypes.ManagedTX {
 
 	txInput := ffcapi.TransactionInput{
 		TransactionHeaders: ffcapi.TransactionHeaders{
@@ -48,7 +61,7 @@ func sendSampleTX(t *testing.T, sth *simpleTransactionHandler, signer string, no
 		},
 	}
 	ctx := context.Background()
-	mfc := sth.toolkit.Connector.(*ffcapimocks.API)
+	mfc := sth.toolkit.Connector.(*ffcapimocks.ExtendedAPI)
 	mfc.On("NextNonceForSigner", ctx, &ffcapi.NextNonceForSignerRequest{
 		Signer: signer,
 	}).Return(&ffcapi.NextNonceForSignerResponse{
@@ -62,9 +75,7 @@ func sendSampleTX(t *testing.T, sth *simpleTransactionHandler, signer string, no
 	}, ffcapi.ErrorReason(""), nil).Once()
 
 	mtx, err := sth.HandleNewTransaction(ctx, &apitypes.TransactionRequest{
-		Headers: apitypes.RequestHeaders{
-			ID: txID,
-		},
+		Headers:          headers,
 		TransactionInput: txInput,
 	})
 	assert.NoError(t, err)
@@ -74,7 +85,7 @@ func sendSampleTX(t *testing.T, sth *simpleTransactionHandler, signer string, no
 func sendSampleDeployment(t *testing.T, sth *simpleTransactionHandler, signer string, nonce int64) *apitypes.ManagedTX {
 
 	ctx := context.Background()
-	mfc := sth.toolkit.Connector.(*ffcapimocks.API)
+	mfc := sth.toolkit.Connector.(*ffcapimocks.ExtendedAPI)
 	contractDeployPrepareRequest := ffcapi.ContractDeployPrepareRequest{
 		TransactionHeaders: ffcapi.TransactionHeaders{
 			From: signer,
@@ -111,7 +122,12 @@ func TestPolicyLoopE2EOk(t *testing.T) {
 	sth.Init(sth.ctx, tk)
 	txHash := "0x" + fftypes.NewRandB32().String()
 
-	mfc := sth.toolkit.Connector.(*ffcapimocks.API)
+	mfc := sth.toolkit.Connector.(*ffcapimocks.ExtendedAPI)
+	mfc.On("TransactionSign", mock.AnythingOfType("*simple.RunContext"), mock.MatchedBy(func(r *ffcapi.TransactionSignRequest) bool {
+		return r.Nonce.Equals(fftypes.NewFFBigInt(12345))
+	})).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: txHash,
+	}, ffcapi.ErrorReason(""), nil)
 	mfc.On("TransactionSend", mock.AnythingOfType("*simple.RunContext"), mock.MatchedBy(func(r *ffcapi.TransactionSendRequest) bool {
 		return r.Nonce.Equals(fftypes.NewFFBigInt(12345))
 	})).Return(&ffcapi.TransactionSendResponse{
@@ -148,15 +164,15 @@ func TestPolicyLoopE2EOk(t *testing.T) {
 	mtx := sendSampleTX(t, sth, "0xaaaaa", 12345, "")
 	// Run the policy once to do the send
 	<-sth.inflightStale // from sending the TX
-	sth.policyLoopCycle(sth.ctx, true)
+	runTestPolicyCycle(sth, true)
 	assert.Equal(t, mtx.ID, sth.inflight[0].mtx.ID)
 	assert.Equal(t, apitypes.TxStatusPending, sth.inflight[0].mtx.Status)
 
 	// A second time will mark it complete for flush
-	sth.policyLoopCycle(sth.ctx, false)
+	runTestPolicyCycle(sth, false)
 
 	<-sth.inflightStale // policy loop should have marked us stale, to clean up the TX
-	sth.policyLoopCycle(sth.ctx, true)
+	runTestPolicyCycle(sth, true)
 	assert.Empty(t, sth.inflight)
 
 	// Check the update is persisted
@@ -181,7 +197,12 @@ func TestPolicyLoopIgnoreTransactionInformationalEventHandlingErrors(t *testing.
 	sth.Init(sth.ctx, tk)
 	txHash := "0x" + fftypes.NewRandB32().String()
 
-	mfc := sth.toolkit.Connector.(*ffcapimocks.API)
+	mfc := sth.toolkit.Connector.(*ffcapimocks.ExtendedAPI)
+	mfc.On("TransactionSign", mock.AnythingOfType("*simple.RunContext"), mock.MatchedBy(func(r *ffcapi.TransactionSignRequest) bool {
+		return r.Nonce.Equals(fftypes.NewFFBigInt(12345))
+	})).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: txHash,
+	}, ffcapi.ErrorReason(""), nil)
 	mfc.On("TransactionSend", mock.AnythingOfType("*simple.RunContext"), mock.MatchedBy(func(r *ffcapi.TransactionSendRequest) bool {
 		return r.Nonce.Equals(fftypes.NewFFBigInt(12345))
 	})).Return(&ffcapi.TransactionSendResponse{
@@ -219,7 +240,7 @@ func TestPolicyLoopIgnoreTransactionInformationalEventHandlingErrors(t *testing.
 	_ = sendSampleDeployment(t, sth, "0xaaaaa", 12345)
 	// Run the policy once to do the send
 	<-sth.inflightStale // from sending the TX
-	sth.policyLoopCycle(sth.ctx, true)
+	runTestPolicyCycle(sth, true)
 	assert.Equal(t, 0, len(sth.inflight))
 
 	mc.AssertExpectations(t)
@@ -237,7 +258,7 @@ func TestTransactionPreparationErrors(t *testing.T) {
 	sth := th.(*simpleTransactionHandler)
 	sth.ctx = context.Background()
 	sth.Init(sth.ctx, tk)
-	mfc := sth.toolkit.Connector.(*ffcapimocks.API)
+	mfc := sth.toolkit.Connector.(*ffcapimocks.ExtendedAPI)
 
 	txInput := ffcapi.TransactionInput{
 		TransactionHeaders: ffcapi.TransactionHeaders{
@@ -280,12 +301,15 @@ func TestPolicyLoopE2EReverted(t *testing.T) {
 	mmm := &metricsmocks.TransactionHandlerMetrics{}
 	mmm.On("InitTxHandlerGaugeMetric", mock.Anything, metricsGaugeTransactionsInflightUsed, metricsGaugeTransactionsInflightUsedDescription, false).Return(nil).Maybe()
 	mmm.On("InitTxHandlerGaugeMetric", mock.Anything, metricsGaugeTransactionsInflightFree, metricsGaugeTransactionsInflightFreeDescription, false).Return(nil).Maybe()
+	mmm.On("InitTxHandlerGaugeMetricWithLabels", mock.Anything, metricsGaugeSignerQueueDepth, metricsGaugeSignerQueueDepthDescription, []string{metricsLabelNameSigner}, false).Return(nil).Maybe()
 	mmm.On("InitTxHandlerCounterMetricWithLabels", mock.Anything, metricsCounterTransactionProcessOperationsTotal, metricsCounterTransactionProcessOperationsTotalDescription, []string{metricsLabelNameOperation}, true).Return(nil).Maybe()
 	mmm.On("InitTxHandlerHistogramMetricWithLabels", mock.Anything, metricsHistogramTransactionProcessOperationsDuration, metricsHistogramTransactionProcessOperationsDurationDescription, []float64{}, []string{metricsLabelNameOperation}, true).Return(nil).Maybe()
+	mmm.On("InitTxHandlerHistogramMetricWithLabels", mock.Anything, metricsHistogramPolicyWorkerCycleDuration, metricsHistogramPolicyWorkerCycleDurationDescription, []float64{}, []string{metricsLabelNameWorker}, false).Return(nil).Maybe()
 	mmm.On("SetTxHandlerGaugeMetric", mock.Anything, metricsGaugeTransactionsInflightUsed, mock.Anything, mock.Anything).Return().Maybe()
 	mmm.On("SetTxHandlerGaugeMetric", mock.Anything, metricsGaugeTransactionsInflightFree, mock.Anything, mock.Anything).Return().Maybe()
 	mmm.On("IncTxHandlerCounterMetricWithLabels", mock.Anything, metricsCounterTransactionProcessOperationsTotal, mock.Anything, mock.Anything, mock.Anything).Return().Maybe()
 	mmm.On("ObserveTxHandlerHistogramMetricWithLabels", mock.Anything, metricsHistogramTransactionProcessOperationsDuration, mock.Anything, mock.Anything, mock.Anything).Return().Maybe()
+	mmm.On("ObserveTxHandlerHistogramMetricWithLabels", mock.Anything, metricsHistogramPolicyWorkerCycleDuration, mock.Anything, mock.Anything, mock.Anything).Return().Maybe()
 
 	tk.MetricsManager = mmm
 
@@ -295,7 +319,12 @@ func TestPolicyLoopE2EReverted(t *testing.T) {
 
 	txHash := "0x" + fftypes.NewRandB32().String()
 
-	mfc := sth.toolkit.Connector.(*ffcapimocks.API)
+	mfc := sth.toolkit.Connector.(*ffcapimocks.ExtendedAPI)
+	mfc.On("TransactionSign", mock.AnythingOfType("*simple.RunContext"), mock.MatchedBy(func(r *ffcapi.TransactionSignRequest) bool {
+		return r.Nonce.Equals(fftypes.NewFFBigInt(12345))
+	})).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: txHash,
+	}, ffcapi.ErrorReason(""), nil)
 	mfc.On("TransactionSend", mock.AnythingOfType("*simple.RunContext"), mock.MatchedBy(func(r *ffcapi.TransactionSendRequest) bool {
 		return r.Nonce.Equals(fftypes.NewFFBigInt(12345))
 	})).Return(&ffcapi.TransactionSendResponse{
@@ -332,15 +361,15 @@ func TestPolicyLoopE2EReverted(t *testing.T) {
 	assert.Equal(t, txID, mtx.ID)
 	// Run the policy once to do the send
 	<-sth.inflightStale // from sending the TX
-	sth.policyLoopCycle(sth.ctx, true)
+	runTestPolicyCycle(sth, true)
 	assert.Equal(t, mtx.ID, sth.inflight[0].mtx.ID)
 	assert.Equal(t, apitypes.TxStatusPending, sth.inflight[0].mtx.Status)
 
 	// A second time will mark it complete for flush
-	sth.policyLoopCycle(sth.ctx, false)
+	runTestPolicyCycle(sth, false)
 
 	<-sth.inflightStale // policy loop should have marked us stale, to clean up the TX
-	sth.policyLoopCycle(sth.ctx, true)
+	runTestPolicyCycle(sth, true)
 	assert.Empty(t, sth.inflight)
 
 	// Check the update is persisted
@@ -366,8 +395,14 @@ func TestPolicyLoopResubmitNewTXID(t *testing.T) {
 
 	txHash1 := "0x" + fftypes.NewRandB32().String()
 	txHash2 := "0x" + fftypes.NewRandB32().String()
-	mfc := sth.toolkit.Connector.(*ffcapimocks.API)
+	mfc := sth.toolkit.Connector.(*ffcapimocks.ExtendedAPI)
 
+	mfc.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: txHash1,
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: txHash2,
+	}, ffcapi.ErrorReason(""), nil).Once()
 	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
 		TransactionHash: txHash1,
 	}, ffcapi.ErrorReason(""), nil).Once()
@@ -386,7 +421,7 @@ func TestPolicyLoopResubmitNewTXID(t *testing.T) {
 			n.Transaction.TransactionHash == txHash1
 	})).Return(nil)
 	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
-		// Then we get notified to remove the old TX hash
+		// Then once the new TX hash is confirmed, we get notified to remove the old TX hash
 		return n.NotificationType == confirmations.RemovedTransaction &&
 			n.Transaction.TransactionHash == txHash1
 	})).Return(nil)
@@ -416,14 +451,14 @@ func TestPolicyLoopResubmitNewTXID(t *testing.T) {
 
 	// Run the policy once to do the send with the first hash
 	<-sth.inflightStale // from sending the TX
-	sth.policyLoopCycle(sth.ctx, true)
+	runTestPolicyCycle(sth, true)
 	assert.Len(t, sth.inflight, 1)
 	assert.Equal(t, mtx.ID, sth.inflight[0].mtx.ID)
 	assert.Equal(t, apitypes.TxStatusPending, sth.inflight[0].mtx.Status)
 	assert.Equal(t, txHash1, sth.inflight[0].mtx.TransactionHash)
 
 	// Run again to confirm it does not change anything, when the state is the same
-	sth.policyLoopCycle(sth.ctx, true)
+	runTestPolicyCycle(sth, true)
 	assert.Len(t, sth.inflight, 1)
 	assert.Equal(t, mtx.ID, sth.inflight[0].mtx.ID)
 	assert.Equal(t, apitypes.TxStatusPending, sth.inflight[0].mtx.Status)
@@ -431,10 +466,16 @@ func TestPolicyLoopResubmitNewTXID(t *testing.T) {
 
 	// Reset the transaction so the policy manager resubmits it
 	sth.inflight[0].mtx.FirstSubmit = nil
-	sth.policyLoopCycle(sth.ctx, false)
+	runTestPolicyCycle(sth, false)
 	assert.Equal(t, mtx.ID, sth.inflight[0].mtx.ID)
 	assert.Equal(t, apitypes.TxStatusPending, sth.inflight[0].mtx.Status)
 	assert.Equal(t, txHash2, sth.inflight[0].mtx.TransactionHash)
+	assert.Equal(t, []string{txHash1, txHash2}, sth.inflight[0].trackedHashes)
+
+	// Run again to process the confirmation of the new TX hash
+	runTestPolicyCycle(sth, false)
+	assert.Equal(t, apitypes.TxStatusSucceeded, sth.inflight[0].mtx.Status)
+	assert.Empty(t, sth.inflight[0].trackedHashes)
 
 	mc.AssertExpectations(t)
 	mfc.AssertExpectations(t)
@@ -452,12 +493,15 @@ func TestNotifyConfirmationMgrFail(t *testing.T) {
 	mmm := &metricsmocks.TransactionHandlerMetrics{}
 	mmm.On("InitTxHandlerGaugeMetric", mock.Anything, metricsGaugeTransactionsInflightUsed, metricsGaugeTransactionsInflightUsedDescription, false).Return(nil).Maybe()
 	mmm.On("InitTxHandlerGaugeMetric", mock.Anything, metricsGaugeTransactionsInflightFree, metricsGaugeTransactionsInflightFreeDescription, false).Return(nil).Maybe()
+	mmm.On("InitTxHandlerGaugeMetricWithLabels", mock.Anything, metricsGaugeSignerQueueDepth, metricsGaugeSignerQueueDepthDescription, []string{metricsLabelNameSigner}, false).Return(nil).Maybe()
 	mmm.On("InitTxHandlerCounterMetricWithLabels", mock.Anything, metricsCounterTransactionProcessOperationsTotal, metricsCounterTransactionProcessOperationsTotalDescription, []string{metricsLabelNameOperation}, true).Return(nil).Maybe()
 	mmm.On("InitTxHandlerHistogramMetricWithLabels", mock.Anything, metricsHistogramTransactionProcessOperationsDuration, metricsHistogramTransactionProcessOperationsDurationDescription, []float64{}, []string{metricsLabelNameOperation}, true).Return(nil).Maybe()
+	mmm.On("InitTxHandlerHistogramMetricWithLabels", mock.Anything, metricsHistogramPolicyWorkerCycleDuration, metricsHistogramPolicyWorkerCycleDurationDescription, []float64{}, []string{metricsLabelNameWorker}, false).Return(nil).Maybe()
 	mmm.On("SetTxHandlerGaugeMetric", mock.Anything, metricsGaugeTransactionsInflightUsed, mock.Anything, mock.Anything).Return().Maybe()
 	mmm.On("SetTxHandlerGaugeMetric", mock.Anything, metricsGaugeTransactionsInflightFree, mock.Anything, mock.Anything).Return().Maybe()
 	mmm.On("IncTxHandlerCounterMetricWithLabels", mock.Anything, metricsCounterTransactionProcessOperationsTotal, mock.Anything, mock.Anything, mock.Anything).Return().Maybe()
 	mmm.On("ObserveTxHandlerHistogramMetricWithLabels", mock.Anything, metricsHistogramTransactionProcessOperationsDuration, mock.Anything, mock.Anything, mock.Anything).Return().Maybe()
+	mmm.On("ObserveTxHandlerHistogramMetricWithLabels", mock.Anything, metricsHistogramPolicyWorkerCycleDuration, mock.Anything, mock.Anything, mock.Anything).Return().Maybe()
 
 	tk.MetricsManager = mmm
 	sth := th.(*simpleTransactionHandler)
@@ -467,9 +511,12 @@ func TestNotifyConfirmationMgrFail(t *testing.T) {
 
 	txHash := "0x" + fftypes.NewRandB32().String()
 
-	removedTxHash := "0x" + fftypes.NewRandB32().String()
+	previousTxHash := "0x" + fftypes.NewRandB32().String()
 
-	mfc := sth.toolkit.Connector.(*ffcapimocks.API)
+	mfc := sth.toolkit.Connector.(*ffcapimocks.ExtendedAPI)
+	mfc.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: txHash,
+	}, ffcapi.ErrorReason(""), nil).Once()
 	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
 		TransactionHash: txHash,
 	}, ffcapi.ErrorReason(""), nil).Once()
@@ -487,17 +534,12 @@ func TestNotifyConfirmationMgrFail(t *testing.T) {
 		return n.NotificationType == confirmations.NewTransaction &&
 			n.Transaction.TransactionHash == txHash
 	})).Return(fmt.Errorf("pop")).Once()
-	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
-		// Then we get notified to remove the old TX hash
-		return n.NotificationType == confirmations.RemovedTransaction &&
-			n.Transaction.TransactionHash == removedTxHash
-	})).Return(fmt.Errorf("pop")).Once()
 	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
 		close(confirmation2Complete)
-		// Then we get the new TX hash, which we confirm
+		// Then we get the new TX hash again, which we confirm - the previous TX hash stays tracked
 		return n.NotificationType == confirmations.NewTransaction &&
 			n.Transaction.TransactionHash == txHash
-	})).Return(fmt.Errorf("pop")).Once()
+	})).Return(nil).Once()
 	eh.ConfirmationManager = mc
 	mws := &wsmocks.WebSocketServer{}
 	mws.On("SendReply", mock.Anything).Return(nil).Maybe()
@@ -508,22 +550,126 @@ func TestNotifyConfirmationMgrFail(t *testing.T) {
 	_ = sendSampleTX(t, sth, "0xaaaaa", 12345, "")
 
 	// should emit 1 event to confirmation manager
-	sth.policyLoopCycle(sth.ctx, true)
+	runTestPolicyCycle(sth, true)
 
 	<-confirmation1Complete
 
-	// set a tracking transaction hash to test removal
-	sth.inflight[0].trackingTransactionHash = removedTxHash
+	// set a previously submitted transaction hash that is already tracked, to check it is not removed
+	assert.Equal(t, []string{txHash}, sth.inflight[0].info.SubmittedHashes)
+	sth.inflight[0].info.SubmittedHashes = []string{previousTxHash, txHash}
+	sth.inflight[0].trackedHashes = []string{previousTxHash}
 
-	// should emit 2 events to confirmation manager
-	sth.policyLoopCycle(sth.ctx, false)
+	// should emit 1 event to confirmation manager
+	runTestPolicyCycle(sth, false)
 	<-confirmation2Complete
+	assert.Equal(t, []string{previousTxHash, txHash}, sth.inflight[0].trackedHashes)
 
 	mc.AssertExpectations(t)
 	mfc.AssertExpectations(t)
 
 }
 
+func TestPolicyLoopScheduledTransactions(t *testing.T) {
+	f, tk, _, conf, cleanup := newTestTransactionHandlerFactoryWithFilePersistence(t)
+	defer cleanup()
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+	sth.Init(sth.ctx, tk)
+
+	meh := tk.EventHandler.(*txhandlermocks.ManagedTxEventHandler)
+	meh.On("HandleEvent", mock.Anything, mock.Anything).Return(nil)
+
+	mfc := sth.toolkit.Connector.(*ffcapimocks.ExtendedAPI)
+	for _, nonce := range []int64{1000, 1001, 2000} {
+		txHash := fmt.Sprintf("0x%d", nonce)
+		n := fftypes.NewFFBigInt(nonce)
+		mfc.On("TransactionSign", mock.Anything, mock.MatchedBy(func(r *ffcapi.TransactionSignRequest) bool {
+			return r.Nonce.Equals(n)
+		})).Return(&ffcapi.TransactionSignResponse{TransactionHash: txHash}, ffcapi.ErrorReason(""), nil).Once()
+		mfc.On("TransactionSend", mock.Anything, mock.MatchedBy(func(r *ffcapi.TransactionSendRequest) bool {
+			return r.Nonce.Equals(n)
+		})).Return(&ffcapi.TransactionSendResponse{TransactionHash: txHash}, ffcapi.ErrorReason(""), nil).Once()
+	}
+
+	// The second transaction from the same signer is held behind the scheduled one, but the other signer is not
+	notBefore := fftypes.FFTime(time.Now().Add(1 * time.Hour))
+	sendSampleTXWithHeaders(t, sth, "0xaaaa", 1000, apitypes.RequestHeaders{ID: "ns1:tx1", NotBefore: &notBefore})
+	sendSampleTX(t, sth, "0xaaaa", 1001, "ns1:tx2")
+	sendSampleTX(t, sth, "0xbbbb", 2000, "ns1:tx3")
+
+	runTestPolicyCycle(sth, true)
+	assert.Len(t, sth.inflight, 3)
+	for i, expectedHash := range []string{"", "", "0x2000"} {
+		assert.Equal(t, expectedHash, sth.inflight[i].mtx.TransactionHash)
+	}
+	assert.Equal(t, apitypes.TxSubStatusScheduled, sth.inflight[0].subStatus)
+	assert.Equal(t, apitypes.TxSubStatusScheduled, sth.inflight[1].subStatus)
+	assert.Equal(t, notBefore.String(), sth.inflight[1].notBefore.String())
+
+	rtx, err := sth.toolkit.TXPersistence.GetTransactionByIDWithStatus(sth.ctx, "ns1:tx1", true)
+	assert.NoError(t, err)
+	assert.Equal(t, notBefore.String(), rtx.NotBefore.String())
+	assert.Equal(t, apitypes.TxSubStatusScheduled, rtx.History[len(rtx.History)-1].Status)
+	assert.Equal(t, apitypes.TxActionSchedule, rtx.History[len(rtx.History)-1].Actions[0].Action)
+
+	// Once the time has passed, both are submitted
+	passed := fftypes.FFTime(time.Now().Add(-1 * time.Second))
+	sth.inflight[0].mtx.NotBefore = &passed
+	for _, p := range sth.inflight {
+		p.lastPolicyCycle = time.Time{}
+	}
+	runTestPolicyCycle(sth, false)
+	for i, expectedHash := range []string{"0x1000", "0x1001", "0x2000"} {
+		assert.Equal(t, expectedHash, sth.inflight[i].mtx.TransactionHash)
+		assert.Nil(t, sth.inflight[i].notBefore)
+	}
+
+	// The nonce for the second transaction from 0xaaaa comes from the nonce cache, rather than the connector
+	nonceLookups := map[string]int{}
+	for _, call := range mfc.Calls {
+		if call.Method == "NextNonceForSigner" {
+			nonceLookups[call.Arguments[1].(*ffcapi.NextNonceForSignerRequest).Signer]++
+		}
+	}
+	assert.Equal(t, map[string]int{"0xaaaa": 1, "0xbbbb": 1}, nonceLookups)
+	mfc.AssertNumberOfCalls(t, "TransactionSend", 3)
+}
+
+func TestPolicyLoopReloadWithExpectedHashNotSubmitted(t *testing.T) {
+	f, tk, _, conf, cleanup := newTestTransactionHandlerFactoryWithFilePersistence(t)
+	defer cleanup()
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+	sth.Init(sth.ctx, tk)
+
+	mfc := sth.toolkit.Connector.(*ffcapimocks.ExtendedAPI)
+	mfc.On("TransactionSign", mock.Anything, mock.Anything).
+		Return(&ffcapi.TransactionSignResponse{TransactionHash: "0x1000"}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("timeout")).Once()
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	runTestPolicyCycle(sth, true)
+
+	// Reloading the in-flight set (as after a restart) does not treat the expected hash as submitted
+	sth.inflight = nil
+	sth.updateInflightSet(sth.ctx)
+	assert.Len(t, sth.inflight, 1)
+	assert.Empty(t, sth.inflight[0].mtx.TransactionHash)
+	assert.Equal(t, "0x1000", sth.inflight[0].info.ExpectedHash)
+	assert.Equal(t, apitypes.TxSubStatusReceived, sth.inflight[0].subStatus)
+
+	mfc.AssertExpectations(t)
+}
+
 func TestInflightSetListFailCancel(t *testing.T) {
 
 	f, tk, _, conf := newTestTransactionHandlerFactory(t)
@@ -541,7 +687,7 @@ func TestInflightSetListFailCancel(t *testing.T) {
 	mp.On("ListTransactionsPending", sth.ctx, "", sth.maxInFlight, persistence.SortDirectionAscending).
 		Return(nil, fmt.Errorf("pop"))
 
-	sth.policyLoopCycle(sth.ctx, true)
+	runTestPolicyCycle(sth, true)
 
 	mp.AssertExpectations(t)
 
@@ -561,7 +707,7 @@ func TestPolicyLoopUpdateFail(t *testing.T) {
 
 	txHash := "0x" + fftypes.NewRandB32().String()
 
-	mfc := sth.toolkit.Connector.(*ffcapimocks.API)
+	mfc := sth.toolkit.Connector.(*ffcapimocks.ExtendedAPI)
 	mfc.On("TransactionSend", mock.AnythingOfType("*simple.RunContext"), mock.MatchedBy(func(r *ffcapi.TransactionSendRequest) bool {
 		return r.Nonce.Equals(fftypes.NewFFBigInt(1000))
 	})).Return(&ffcapi.TransactionSendResponse{
@@ -616,7 +762,7 @@ func TestPolicyLoopUpdateFail(t *testing.T) {
 	mp.On("UpdateTransaction", mock.AnythingOfType("*simple.RunContext"), txID, mock.Anything).Return(fmt.Errorf("pop"))
 	mp.On("Close", mock.Anything).Return(nil).Maybe()
 
-	sth.policyLoopCycle(sth.ctx, false)
+	runTestPolicyCycle(sth, false)
 
 	mp.AssertExpectations(t)
 
@@ -636,7 +782,7 @@ func TestPolicyLoopUpdateEventHandlerError(t *testing.T) {
 
 	txHash := "0x" + fftypes.NewRandB32().String()
 
-	mfc := sth.toolkit.Connector.(*ffcapimocks.API)
+	mfc := sth.toolkit.Connector.(*ffcapimocks.ExtendedAPI)
 	mfc.On("TransactionSend", mock.AnythingOfType("*simple.RunContext"), mock.MatchedBy(func(r *ffcapi.TransactionSendRequest) bool {
 		return r.Nonce.Equals(fftypes.NewFFBigInt(1000))
 	})).Return(&ffcapi.TransactionSendResponse{
@@ -691,7 +837,7 @@ func TestPolicyLoopUpdateEventHandlerError(t *testing.T) {
 	mp.On("UpdateTransaction", mock.AnythingOfType("*simple.RunContext"), txID, mock.Anything).Return(nil)
 	mp.On("Close", mock.Anything).Return(nil).Maybe()
 
-	sth.policyLoopCycle(sth.ctx, false)
+	runTestPolicyCycle(sth, false)
 
 	mp.AssertExpectations(t)
 
@@ -707,12 +853,15 @@ func TestPolicyEngineFailStaleThenUpdated(t *testing.T) {
 	mmm := &metricsmocks.TransactionHandlerMetrics{}
 	mmm.On("InitTxHandlerGaugeMetric", mock.Anything, metricsGaugeTransactionsInflightUsed, metricsGaugeTransactionsInflightUsedDescription, false).Return(nil).Maybe()
 	mmm.On("InitTxHandlerGaugeMetric", mock.Anything, metricsGaugeTransactionsInflightFree, metricsGaugeTransactionsInflightFreeDescription, false).Return(nil).Maybe()
+	mmm.On("InitTxHandlerGaugeMetricWithLabels", mock.Anything, metricsGaugeSignerQueueDepth, metricsGaugeSignerQueueDepthDescription, []string{metricsLabelNameSigner}, false).Return(nil).Maybe()
 	mmm.On("InitTxHandlerCounterMetricWithLabels", mock.Anything, metricsCounterTransactionProcessOperationsTotal, metricsCounterTransactionProcessOperationsTotalDescription, []string{metricsLabelNameOperation}, true).Return(nil).Maybe()
 	mmm.On("InitTxHandlerHistogramMetricWithLabels", mock.Anything, metricsHistogramTransactionProcessOperationsDuration, metricsHistogramTransactionProcessOperationsDurationDescription, []float64{}, []string{metricsLabelNameOperation}, true).Return(nil).Maybe()
+	mmm.On("InitTxHandlerHistogramMetricWithLabels", mock.Anything, metricsHistogramPolicyWorkerCycleDuration, metricsHistogramPolicyWorkerCycleDurationDescription, []float64{}, []string{metricsLabelNameWorker}, false).Return(nil).Maybe()
 	mmm.On("SetTxHandlerGaugeMetric", mock.Anything, metricsGaugeTransactionsInflightUsed, mock.Anything, mock.Anything).Return().Maybe()
 	mmm.On("SetTxHandlerGaugeMetric", mock.Anything, metricsGaugeTransactionsInflightFree, mock.Anything, mock.Anything).Return().Maybe()
 	mmm.On("IncTxHandlerCounterMetricWithLabels", mock.Anything, metricsCounterTransactionProcessOperationsTotal, mock.Anything, mock.Anything, mock.Anything).Return().Maybe()
 	mmm.On("ObserveTxHandlerHistogramMetricWithLabels", mock.Anything, metricsHistogramTransactionProcessOperationsDuration, mock.Anything, mock.Anything, mock.Anything).Return().Maybe()
+	mmm.On("ObserveTxHandlerHistogramMetricWithLabels", mock.Anything, metricsHistogramPolicyWorkerCycleDuration, mock.Anything, mock.Anything, mock.Anything).Return().Maybe()
 
 	tk.MetricsManager = mmm
 	sth := th.(*simpleTransactionHandler)
@@ -968,6 +1117,7 @@ func TestExecPolicyResumeSync(t *testing.T) {
 	mp.On("AddSubStatusAction", sth.ctx, mock.Anything, apitypes.TxSubStatusReceived, apitypes.TxActionAssignNonce, mock.Anything, mock.Anything).Return(nil)
 	tx := sendSampleTX(t, sth, "0xaaaaa", 12345, "")
 	tx.Status = apitypes.TxStatusSuspended
+	tx.Nonce = fftypes.NewFFBigInt(12345)
 	mp.On("UpdateTransaction", mock.AnythingOfType("*simple.RunContext"), tx.ID, mock.MatchedBy(func(updates *apitypes.TXUpdates) bool {
 		tx.Status = apitypes.TxStatusPending
 		return updates.Status != nil && *updates.Status == apitypes.TxStatusPending
@@ -986,6 +1136,8 @@ func TestExecPolicyResumeSync(t *testing.T) {
 	res := <-req.response
 	assert.NoError(t, res.err)
 	assert.Equal(t, http.StatusOK, res.status)
+	// The signer is queried again from before the resumed nonce
+	assert.Equal(t, int64(12344), sth.pendingSigners["0xaaaaa"]["0xaaaaa"].Int64())
 
 	mp.AssertExpectations(t)
 
@@ -1367,7 +1519,7 @@ func TestPolicyStoreReceiptFail(t *testing.T) {
 	mp := sth.toolkit.TXPersistence.(*persistencemocks.Persistence)
 	mp.On("SetTransactionReceipt", mock.Anything, txID, mock.Anything).Return(fmt.Errorf("pop"))
 
-	sth.policyLoopCycle(sth.ctx, false)
+	runTestPolicyCycle(sth, false)
 
 	mp.AssertExpectations(t)
 
@@ -1412,7 +1564,7 @@ func TestPolicyStoreConfirmationsFail(t *testing.T) {
 	mp := sth.toolkit.TXPersistence.(*persistencemocks.Persistence)
 	mp.On("AddTransactionConfirmations", mock.Anything, txID, true, mock.Anything).Return(fmt.Errorf("pop"))
 
-	sth.policyLoopCycle(sth.ctx, false)
+	runTestPolicyCycle(sth, false)
 
 	mp.AssertExpectations(t)
 
@@ -1480,3 +1632,105 @@ func TestUpdateFailHistory(t *testing.T) {
 	mp.AssertExpectations(t)
 
 }
+
+type testCircuitBreaker struct {
+	open bool
+}
+
+func (cb *testCircuitBreaker) IsOpen() bool {
+	return cb.open
+}
+
+func TestPolicyLoopCycleSkipsSubmissionWhileCircuitBreakerOpen(t *testing.T) {
+	f, tk, mockFFCAPI, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	cb := &testCircuitBreaker{open: true}
+	tk.CircuitBreaker = cb
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+	sth.Init(sth.ctx, tk)
+
+	pending := &pendingState{
+		mtx: &apitypes.ManagedTX{
+			ID:     "id1",
+			Status: apitypes.TxStatusPending,
+		},
+		info:      &simplePolicyInfo{},
+		subStatus: apitypes.TxSubStatusReceived,
+	}
+	sth.inflight = []*pendingState{pending}
+
+	// No calls to the connector are made while the breaker is open
+	runTestPolicyCycle(sth, false)
+	assert.True(t, pending.lastPolicyCycle.IsZero())
+	mockFFCAPI.AssertExpectations(t)
+
+	// Once closed, the policy engine runs
+	cb.open = false
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonInvalidInputs, fmt.Errorf("pop")).Once()
+	mp := tk.TXPersistence.(*persistencemocks.Persistence)
+	mp.On("AddSubStatusAction", mock.Anything, "id1", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	mp.On("SetSubStatus", mock.Anything, "id1", mock.Anything).Return(nil).Maybe()
+	mp.On("UpdateTransaction", mock.Anything, "id1", mock.Anything).Return(nil).Maybe()
+	runTestPolicyCycle(sth, false)
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestExecPolicyGasLimitExceededFailsTrackedTransaction(t *testing.T) {
+	f, tk, mockFFCAPI, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+	sth.Init(sth.ctx, tk)
+	meh := &txhandlermocks.ManagedTxEventHandler{}
+	tk.EventHandler = meh
+
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	pending := &pendingState{
+		mtx: &apitypes.ManagedTX{
+			ID:              "id1",
+			Status:          apitypes.TxStatusPending,
+			TransactionHash: "0x01020304",
+			FirstSubmit:     &submitTime,
+			LastSubmit:      &submitTime,
+		},
+		info:          &simplePolicyInfo{},
+		subStatus:     apitypes.TxSubStatusTracking,
+		trackedHashes: []string{"0x01020304"},
+	}
+	sth.inflight = []*pendingState{pending}
+
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: "0x01020304",
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mockFFCAPI.On("TransactionByHash", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found")).Once()
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonGasLimitExceeded, fmt.Errorf("exceeds block gas limit")).Once()
+	mp := tk.TXPersistence.(*persistencemocks.Persistence)
+	mp.On("AddSubStatusAction", mock.Anything, "id1", apitypes.TxSubStatusFailed, apitypes.TxActionFailTransaction, mock.Anything, mock.Anything).Return(nil).Once()
+	mp.On("AddSubStatusAction", mock.Anything, "id1", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mp.On("UpdateTransaction", mock.Anything, "id1", mock.MatchedBy(func(updates *apitypes.TXUpdates) bool {
+		return *updates.Status == apitypes.TxStatusFailed && *updates.ErrorMessage == "exceeds block gas limit"
+	})).Return(nil).Once()
+	meh.On("HandleEvent", mock.Anything, mock.MatchedBy(func(e apitypes.ManagedTransactionEvent) bool {
+		return e.Type == apitypes.ManagedTXTransactionHashRemoved && e.Tx.TransactionHash == "0x01020304"
+	})).Return(fmt.Errorf("pop")).Once()
+	meh.On("HandleEvent", mock.Anything, mock.MatchedBy(func(e apitypes.ManagedTransactionEvent) bool {
+		return e.Type == apitypes.ManagedTXProcessFailed
+	})).Return(nil).Once()
+
+	err = sth.execPolicy(sth.ctx, pending, nil)
+	assert.NoError(t, err)
+	assert.True(t, pending.remove)
+	assert.Equal(t, apitypes.TxSubStatusFailed, pending.subStatus)
+
+	mockFFCAPI.AssertExpectations(t)
+	mp.AssertExpectations(t)
+	meh.AssertExpectations(t)
+}
@@ -0,0 +1,181 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simple
+
+import (
+	"context"
+	"hash/fnv"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
+)
+
+const metricsHistogramPolicyWorkerCycleDuration = "tx_policy_worker_cycle_seconds"
+const metricsHistogramPolicyWorkerCycleDurationDescription = "Duration of each cycle of a policy worker over the in-flight transactions of its signers, grouped by worker"
+const metricsLabelNameWorker = "worker"
+
+// policyWorker is a long-lived routine that executes the policy engine against the in-flight transactions of the
+// signers it owns. Each worker cycles independently, so a slow submission only delays the transactions of its own signers.
+type policyWorker struct {
+	index            int
+	wakeup           chan bool
+	partition        []*pendingState      // protected by the handler mux - replaced by the policy loop each cycle
+	fundsLastChecked map[string]time.Time // signers with transactions suspended for insufficient funds, and when we last checked their balance
+}
+
+func (sth *simpleTransactionHandler) newPolicyWorkers() {
+	sth.workers = make([]*policyWorker, sth.policyWorkers)
+	for i := range sth.workers {
+		sth.workers[i] = &policyWorker{
+			index:            i,
+			wakeup:           make(chan bool, 1),
+			fundsLastChecked: make(map[string]time.Time),
+		}
+	}
+}
+
+// policyWorkerIndex returns the policy worker that owns the signer. All the transactions from a signer are processed
+// by the same worker, so they are processed one at a time in nonce order.
+func (sth *simpleTransactionHandler) policyWorkerIndex(signer string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(strings.ToLower(signer)))
+	return int(h.Sum32() % uint32(sth.policyWorkers))
+}
+
+// startPolicyWorkers starts a routine for each policy worker, returning a channel for each that is closed once it exits
+func (sth *simpleTransactionHandler) startPolicyWorkers(ctx context.Context) []chan struct{} {
+	workersDone := make([]chan struct{}, len(sth.workers))
+	for i, w := range sth.workers {
+		workersDone[i] = make(chan struct{})
+		go sth.policyWorkerLoop(ctx, w, workersDone[i])
+	}
+	return workersDone
+}
+
+func waitPolicyWorkers(workersDone []chan struct{}) {
+	for _, done := range workersDone {
+		<-done
+	}
+}
+
+func (sth *simpleTransactionHandler) policyWorkerLoop(ctx context.Context, w *policyWorker, done chan struct{}) {
+	defer close(done)
+	for {
+		select {
+		case <-w.wakeup:
+		case <-ctx.Done():
+			log.L(ctx).Debugf("Policy worker %d exiting", w.index)
+			return
+		}
+		sth.policyWorkerCycle(ctx, w)
+	}
+}
+
+// assignPolicyWorkers partitions the in-flight transactions across the policy workers by a hash of the signer, and
+// wakes each worker to process its partition. Does not wait for the workers, so a slow submission by one worker
+// does not hold up the policy loop, or the other workers.
+func (sth *simpleTransactionHandler) assignPolicyWorkers() {
+	sth.mux.Lock()
+	partitions := make([][]*pendingState, len(sth.workers))
+	for _, pending := range sth.inflight {
+		worker := sth.policyWorkerIndex(pending.mtx.From)
+		partitions[worker] = append(partitions[worker], pending)
+	}
+	for i, w := range sth.workers {
+		w.partition = partitions[i]
+	}
+	sth.mux.Unlock()
+	for _, w := range sth.workers {
+		w.wake()
+	}
+}
+
+func (w *policyWorker) wake() {
+	select {
+	case w.wakeup <- true:
+	default:
+	}
+}
+
+// wakePolicyWorker wakes only the policy worker that owns the signer
+func (sth *simpleTransactionHandler) wakePolicyWorker(signer string) {
+	sth.workers[sth.policyWorkerIndex(signer)].wake()
+}
+
+// policyWorkerCycle executes the policy engine against the in-flight transactions owned by one policy worker
+func (sth *simpleTransactionHandler) policyWorkerCycle(ctx context.Context, w *policyWorker) {
+	ctx = log.WithLogField(ctx, "worker", strconv.Itoa(w.index))
+	startTime := time.Now()
+
+	sth.mux.Lock()
+	partition := w.partition
+	sth.mux.Unlock()
+	balances := sth.checkSignerBalances(ctx, w, partition)
+
+	// Transactions with a notBefore time are held until that time. Later transactions from the same signer have
+	// later nonces, so could not be mined before it even if submitted - so they are held until the same time.
+	// The same applies to transactions waiting for the transactions they depend on.
+	// Transactions suspended for insufficient funds are resumed in nonce order, as far as the balance of the signer allows.
+	// As each signer is owned by a single worker, these only need to be tracked across the transactions of the worker.
+	heldSigners := make(map[string]*fftypes.FFTime)
+	waitingSigners := make(map[string]string)
+	underfundedSigners := make(map[string]string)
+	for _, pending := range partition {
+		pending.execMux.Lock()
+		sth.policyWorkerExec(ctx, pending, balances, heldSigners, waitingSigners, underfundedSigners)
+		pending.execMux.Unlock()
+	}
+
+	sth.recordPolicyWorkerCycleDuration(ctx, w.index, time.Since(startTime).Seconds())
+}
+
+// policyWorkerExec executes the policy engine against one in-flight transaction. Must be called with its exec lock held,
+// as policy API requests for the transaction are executed by the policy loop.
+func (sth *simpleTransactionHandler) policyWorkerExec(ctx context.Context, pending *pendingState, balances map[string]*big.Int, heldSigners map[string]*fftypes.FFTime, waitingSigners, underfundedSigners map[string]string) {
+	sth.mux.Lock()
+	removed := pending.remove
+	sth.mux.Unlock()
+	if removed {
+		// Completed, and waiting for the policy loop to drop it from the in-flight set
+		return
+	}
+	checkFunded(pending, balances)
+	notBefore := scheduledNotBefore(pending.mtx, heldSigners)
+	dependency := sth.checkDependencies(ctx, pending, waitingSigners)
+	underfundedBy := sth.underfundedBy(pending, underfundedSigners)
+	sth.mux.Lock()
+	wasWaiting := pending.dependency != nil
+	pending.notBefore = notBefore
+	pending.dependency = dependency
+	pending.underfundedBy = underfundedBy
+	sth.mux.Unlock()
+	if wasWaiting != (dependency != nil) && sth.selectBySigner() {
+		// Waiting transactions do not use up space in the in-flight set
+		sth.markInflightStale()
+	}
+	err := sth.execPolicy(ctx, pending, nil)
+	if err != nil {
+		log.L(ctx).Errorf("Failed policy cycle transaction=%s operation=%s: %s", pending.mtx.TransactionHash, pending.mtx.ID, err)
+	}
+	if isUnderfunded(pending.mtx, pending.info) {
+		underfundedSigners[strings.ToLower(pending.mtx.From)] = pending.mtx.ID
+	}
+}
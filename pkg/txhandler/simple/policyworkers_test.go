@@ -0,0 +1,144 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simple
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-transaction-manager/mocks/txhandlermocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPolicyWorkersMinimumOne(t *testing.T) {
+	f, _, _, conf, cleanup := newTestTransactionHandlerFactoryWithFilePersistence(t)
+	defer cleanup()
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(PolicyWorkers, 0)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, th.(*simpleTransactionHandler).policyWorkers)
+}
+
+func TestPolicyWorkerIndex(t *testing.T) {
+	sth := &simpleTransactionHandler{policyWorkers: 4}
+	for i := 0; i < 100; i++ {
+		signer := fmt.Sprintf("0x%04X", i)
+		worker := sth.policyWorkerIndex(signer)
+		assert.GreaterOrEqual(t, worker, 0)
+		assert.Less(t, worker, 4)
+		assert.Equal(t, worker, sth.policyWorkerIndex(signer))
+	}
+	assert.Equal(t, sth.policyWorkerIndex("0xABCD"), sth.policyWorkerIndex("0xabcd"))
+}
+
+func TestPolicyWorkersProcessSignersConcurrently(t *testing.T) {
+	f, tk, mfc, conf, cleanup := newTestTransactionHandlerFactoryWithFilePersistence(t)
+	defer cleanup()
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(PolicyWorkers, 2)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+	sth.Init(sth.ctx, tk)
+
+	// Find two signers owned by different workers
+	slowSigner := "0xaaaa"
+	var fastSigner string
+	for i := 0; fastSigner == ""; i++ {
+		signer := fmt.Sprintf("0x%04x", i)
+		if sth.policyWorkerIndex(signer) != sth.policyWorkerIndex(slowSigner) {
+			fastSigner = signer
+		}
+	}
+
+	meh := tk.EventHandler.(*txhandlermocks.ManagedTxEventHandler)
+	meh.On("HandleEvent", mock.Anything, mock.Anything).Return(nil)
+	mfc.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{}, ffcapi.ErrorReason(""), nil)
+
+	// The first submission of the slow signer only completes once the fast signer has submitted,
+	// which would never happen if the signers were processed one after the other
+	fastSent := make(chan struct{})
+	var sendMux sync.Mutex
+	var slowNonces []int64
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		req := args[1].(*ffcapi.TransactionSendRequest)
+		if req.From == fastSigner {
+			close(fastSent)
+			return
+		}
+		if req.Nonce.Int64() == 1000 {
+			select {
+			case <-fastSent:
+			case <-time.After(5 * time.Second):
+				assert.Fail(t, "signers not processed concurrently")
+			}
+		}
+		sendMux.Lock()
+		slowNonces = append(slowNonces, req.Nonce.Int64())
+		sendMux.Unlock()
+	}).Return(func(_ context.Context, req *ffcapi.TransactionSendRequest) *ffcapi.TransactionSendResponse {
+		return &ffcapi.TransactionSendResponse{TransactionHash: fmt.Sprintf("0x%d", req.Nonce.Int64())}
+	}, ffcapi.ErrorReason(""), nil)
+
+	sendSampleTX(t, sth, slowSigner, 1000, "ns1:tx1")
+	sendSampleTX(t, sth, slowSigner, 1001, "ns1:tx2")
+	sendSampleTX(t, sth, fastSigner, 2000, "ns1:tx3")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	workersDone := sth.startPolicyWorkers(ctx)
+	sth.policyLoopCycle(ctx, true)
+	assert.Len(t, sth.inflight, 3)
+
+	// The policy loop does not wait for the workers, which each keep cycling over their own signers
+	assert.Eventually(t, func() bool {
+		sendMux.Lock()
+		defer sendMux.Unlock()
+		return len(slowNonces) == 2
+	}, 5*time.Second, time.Millisecond)
+	cancel()
+	waitPolicyWorkers(workersDone)
+
+	for i, expectedHash := range []string{"0x1000", "0x1001", "0x2000"} {
+		assert.Equal(t, expectedHash, sth.inflight[i].mtx.TransactionHash)
+	}
+
+	// The transactions from each signer are still submitted in nonce order
+	assert.Equal(t, []int64{1000, 1001}, slowNonces)
+}
+
+func TestPolicyWorkerWokenByReceipt(t *testing.T) {
+	f, _, _, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+	sth := th.(*simpleTransactionHandler)
+	sth.inflight = []*pendingState{{mtx: &apitypes.ManagedTX{ID: "ns1:tx1", TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa"}}}}
+
+	err = sth.HandleTransactionReceiptReceived(context.Background(), "ns1:tx1", &ffcapi.TransactionReceiptResponse{})
+	assert.NoError(t, err)
+	assert.Len(t, sth.workers[0].wakeup, 1)
+	assert.Empty(t, sth.inflightUpdate)
+}
@@ -0,0 +1,63 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simple
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+)
+
+type priorityGasPriceTier struct {
+	minPriority int
+	percent     int
+}
+
+// parsePriorityGasPriceTiers returns the tiers ordered from the highest minimum priority to the lowest
+func parsePriorityGasPriceTiers(ctx context.Context, tiersConf fftypes.JSONObject) ([]*priorityGasPriceTier, error) {
+	tiers := make([]*priorityGasPriceTier, 0, len(tiersConf))
+	for k := range tiersConf {
+		minPriority, err := strconv.Atoi(k)
+		if err != nil {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidPriorityGasPriceTier, k, tiersConf[k])
+		}
+		percent, err := strconv.Atoi(tiersConf.GetString(k))
+		if err != nil || percent <= 0 {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidPriorityGasPriceTier, k, tiersConf[k])
+		}
+		tiers = append(tiers, &priorityGasPriceTier{minPriority: minPriority, percent: percent})
+	}
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].minPriority > tiers[j].minPriority
+	})
+	return tiers, nil
+}
+
+// priorityGasPrice increases the gas price according to the highest tier the priority of the transaction reaches
+func (sth *simpleTransactionHandler) priorityGasPrice(priority int, gasPrice *fftypes.JSONAny) *fftypes.JSONAny {
+	for _, tier := range sth.priorityGasPriceTiers {
+		if priority >= tier.minPriority {
+			// Like an escalated price, the increased price is subject to any cap - but never below the price we started with
+			return maxGasPrice(gasPrice, sth.capGasPrice(BumpGasPrice(gasPrice, tier.percent)))
+		}
+	}
+	return gasPrice
+}
@@ -0,0 +1,94 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simple
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPriorityGasPriceTiers(t *testing.T) {
+	f, _, _, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `100`)
+	conf.Set(PriorityGasPriceTiers, map[string]interface{}{"1": "10", "5": 25})
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+	sth := th.(*simpleTransactionHandler)
+	assert.Len(t, sth.priorityGasPriceTiers, 2)
+
+	assert.Equal(t, `100`, sth.priorityGasPrice(0, fftypes.JSONAnyPtr(`100`)).String())
+	assert.Equal(t, `110`, sth.priorityGasPrice(1, fftypes.JSONAnyPtr(`100`)).String())
+	assert.Equal(t, `110`, sth.priorityGasPrice(4, fftypes.JSONAnyPtr(`100`)).String())
+	assert.Equal(t, `125`, sth.priorityGasPrice(5, fftypes.JSONAnyPtr(`100`)).String())
+
+	// Subject to the cap, but never reduced below the price we started with
+	sth.gasEscalationMaxGasPrice = fftypes.JSONAnyPtr(`{"maxFeePerGas":120}`)
+	assert.JSONEq(t, `{"maxFeePerGas":120,"maxPriorityFeePerGas":13}`, sth.priorityGasPrice(10, fftypes.JSONAnyPtr(`{"maxFeePerGas":100,"maxPriorityFeePerGas":10}`)).String())
+	assert.JSONEq(t, `{"maxFeePerGas":200}`, sth.priorityGasPrice(10, fftypes.JSONAnyPtr(`{"maxFeePerGas":200}`)).String())
+}
+
+func TestPriorityGasPriceTiersInvalid(t *testing.T) {
+	for _, tiers := range []map[string]interface{}{
+		{"high": "10"},
+		{"1": "lots"},
+		{"1": 0},
+	} {
+		f, _, _, conf := newTestTransactionHandlerFactory(t)
+		conf.Set(FixedGasPrice, `100`)
+		conf.Set(PriorityGasPriceTiers, tiers)
+		_, err := f.NewTransactionHandler(context.Background(), conf)
+		assert.Regexp(t, "FF21124", err)
+	}
+}
+
+func TestPrioritySubmitUsesGasPriceTier(t *testing.T) {
+	f, tk, mockFFCAPI, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `100`)
+	conf.Set(PriorityGasPriceTiers, map[string]interface{}{"1": "10"})
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == `110`
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x01020304",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	th.Init(context.Background(), tk)
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+
+	mtx := &apitypes.ManagedTX{
+		ID:              "ns1:tx1",
+		Priority:        3,
+		TransactionData: "SOME_RAW_TX_BYTES",
+	}
+	rc := newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, "0x01020304", mtx.TransactionHash)
+	assert.Equal(t, `110`, mtx.GasPrice.String())
+
+	mockFFCAPI.AssertExpectations(t)
+}
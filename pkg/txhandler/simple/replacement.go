@@ -0,0 +1,192 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simple
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs" // replace with your own messages if you are developing a customized transaction handler
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// isCancelled returns true if the transaction was replaced with a cancellation, and it is the cancellation that was mined
+func isCancelled(mtx *apitypes.ManagedTX, info *simplePolicyInfo) bool {
+	return info != nil && info.CancelHash != "" && mtx.TransactionHash == info.CancelHash
+}
+
+func containsHash(hashes []string, hash string) bool {
+	for _, h := range hashes {
+		if h == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceTransaction applies a change requested through the API to a pending transaction. A transaction that has already
+// been submitted is replaced straight away by a new submission at the same nonce.
+func (sth *simpleTransactionHandler) replaceTransaction(ctx *RunContext) error {
+	mtx := ctx.TX
+	if mtx.Status != apitypes.TxStatusPending {
+		return i18n.NewError(ctx, tmmsgs.MsgTransactionNotPending, mtx.ID, mtx.Status)
+	}
+	if ctx.Receipt != nil {
+		return i18n.NewError(ctx, tmmsgs.MsgTransactionAlreadyMined, mtx.ID, mtx.TransactionHash)
+	}
+	if ctx.Info.CancelHash != "" {
+		return i18n.NewError(ctx, tmmsgs.MsgTransactionNotPending, mtx.ID, "cancelled")
+	}
+	if ctx.SyncAction == ActionCancel {
+		return sth.cancelByReplacement(ctx)
+	}
+	return sth.updateTransaction(ctx)
+}
+
+// updateTransaction changes the gas limit and/or gas price of the transaction. The gas price set through the API
+// is used in place of the one from the gas oracle, for this and all later submissions of the transaction.
+func (sth *simpleTransactionHandler) updateTransaction(ctx *RunContext) error {
+	mtx := ctx.TX
+	update := ctx.Update
+	if update.Gas != nil {
+		mtx.Gas = update.Gas
+		ctx.TXUpdates.Gas = update.Gas
+	}
+	if !update.GasPrice.IsNil() {
+		ctx.Info.GasPriceOverride = update.GasPrice
+	}
+	ctx.UpdateType = Update
+	ctx.UpdatedInfo = true
+	log.L(ctx).Infof("Transaction %s at nonce %s / %d updated (gas=%s gasPrice=%s)", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.Gas, ctx.Info.GasPriceOverride)
+	ctx.AddSubStatusAction(apitypes.TxActionUpdateTransaction, fftypes.JSONAnyPtr(`{"gas":"`+mtx.Gas.String()+`","gasPrice":`+ctx.Info.GasPriceOverride.String()+`}`), nil)
+	if mtx.FirstSubmit == nil {
+		// The changes are used when the transaction is first submitted
+		return nil
+	}
+
+	if !mtx.GasPrice.IsNil() {
+		// We need to outbid the submission that is still in the transaction pool
+		ctx.Info.ReplacementGasPrice = sth.capGasPrice(BumpGasPrice(mtx.GasPrice, sth.replacementGasPriceBump))
+	}
+	if reason, err := sth.submitTX(ctx); err != nil && reason != ffcapi.ErrorKnownTransaction {
+		return err
+	}
+	return nil
+}
+
+// cancelByReplacement replaces a submitted transaction with one that does nothing at the same nonce. Whichever of the
+// submissions is mined completes the transaction - as failed, if it is the cancellation. If the transaction has not
+// been submitted, the nonce is filled and the transaction failed straight away.
+func (sth *simpleTransactionHandler) cancelByReplacement(ctx *RunContext) error {
+	mtx := ctx.TX
+	if mtx.FirstSubmit == nil {
+		err := sth.cancelTransaction(ctx, i18n.NewError(ctx, tmmsgs.MsgTransactionCancelled, mtx.ID))
+		if mtx.Status != apitypes.TxStatusFailed {
+			return err
+		}
+		errMsg := err.Error()
+		mtx.ErrorMessage = errMsg
+		ctx.TXUpdates.ErrorMessage = &errMsg
+		return nil
+	}
+
+	cancelTX, err := sth.cancelRequest(ctx)
+	if err != nil {
+		return err
+	}
+	log.L(ctx).Infof("Replacing transaction %s at nonce %s / %d with a cancellation", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64())
+	res, reason, err := sth.sendCancel(ctx, cancelTX)
+	if err != nil {
+		log.L(ctx).Warnf("Failed to cancel transaction %s at nonce %s / %d (reason=%s): %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), reason, err)
+		ctx.AddSubStatusAction(apitypes.TxActionCancelTransaction, fftypes.JSONAnyPtr(`{"reason":"`+string(reason)+`"}`), fftypes.JSONAnyPtr(`{"error":"`+err.Error()+`"}`))
+		if reason == ffcapi.ErrorReasonNonceTooLow || reason == ffcapi.ErrorKnownTransaction {
+			return i18n.NewError(ctx, tmmsgs.MsgTransactionNonceUsed, mtx.ID, mtx.Nonce, mtx.TransactionHash)
+		}
+		return err
+	}
+	log.L(ctx).Infof("Transaction %s at nonce %s / %d replaced by cancellation with hash: %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), res.TransactionHash)
+	ctx.AddSubStatusAction(apitypes.TxActionCancelTransaction, fftypes.JSONAnyPtr(`{"hash":"`+res.TransactionHash+`","gasPrice":`+cancelTX.GasPrice.String()+`}`), nil)
+	ctx.Info.CancelHash = res.TransactionHash
+	ctx.UpdateType = Update
+	ctx.UpdatedInfo = true
+	return nil
+}
+
+// trackSubmittedHashes adds every hash the transaction has been submitted with to the confirmation manager. Any of the
+// submissions at the nonce might be the one that is mined, so a receipt for any of them completes the transaction.
+// The hashes are recorded in the policy info, so they are all tracked again after a restart.
+func (sth *simpleTransactionHandler) trackSubmittedHashes(ctx *RunContext, pending *pendingState) {
+	mtx := ctx.TX
+	if ctx.Info == nil {
+		ctx.Info = &simplePolicyInfo{}
+		pending.info = ctx.Info
+	}
+	for _, hash := range []string{mtx.TransactionHash, ctx.Info.CancelHash} {
+		if hash != "" && !containsHash(ctx.Info.SubmittedHashes, hash) {
+			ctx.Info.SubmittedHashes = append(ctx.Info.SubmittedHashes, hash)
+			ctx.UpdateType = Update
+			ctx.UpdatedInfo = true
+		}
+	}
+	for _, hash := range ctx.Info.SubmittedHashes {
+		if containsHash(pending.trackedHashes, hash) {
+			continue
+		}
+		eventTX := *mtx
+		eventTX.TransactionHash = hash
+		err := sth.toolkit.EventHandler.HandleEvent(ctx, apitypes.ManagedTransactionEvent{
+			Type:           apitypes.ManagedTXTransactionHashAdded,
+			Tx:             &eventTX,
+			ReceiptHandler: sth.hashReceiptHandler(hash),
+		})
+		if err != nil {
+			log.L(ctx).Infof("Error detected notifying confirmation manager to add new transaction hash: %s", err.Error())
+			sth.incTransactionOperationCounter(ctx, mtx.Namespace(ctx), "tracking_failed")
+		} else {
+			pending.trackedHashes = append(pending.trackedHashes, hash)
+			sth.incTransactionOperationCounter(ctx, mtx.Namespace(ctx), "tracking")
+		}
+	}
+}
+
+// untrackHashes removes the tracked hashes from the confirmation manager, apart from the one to keep (if any)
+func (sth *simpleTransactionHandler) untrackHashes(ctx *RunContext, pending *pendingState, keep string) {
+	for _, hash := range pending.trackedHashes {
+		if hash == keep {
+			continue
+		}
+		eventTX := *ctx.TX
+		eventTX.TransactionHash = hash
+		if err := sth.toolkit.EventHandler.HandleEvent(ctx, apitypes.ManagedTransactionEvent{
+			Type: apitypes.ManagedTXTransactionHashRemoved,
+			Tx:   &eventTX,
+		}); err != nil {
+			log.L(ctx).Infof("Error detected notifying confirmation manager to remove transaction hash %s: %s", hash, err.Error())
+		}
+	}
+	pending.trackedHashes = nil
+}
+
+// hashReceiptHandler returns a receipt handler that records which of the hashes of the transaction was mined
+func (sth *simpleTransactionHandler) hashReceiptHandler(hash string) func(ctx context.Context, txID string, receipt *ffcapi.TransactionReceiptResponse) error {
+	return func(ctx context.Context, txID string, receipt *ffcapi.TransactionReceiptResponse) error {
+		return sth.handleReceipt(ctx, txID, hash, receipt)
+	}
+}
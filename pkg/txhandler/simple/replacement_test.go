@@ -0,0 +1,414 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simple
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/txhandlermocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type testEvents struct {
+	mux    sync.Mutex
+	events []apitypes.ManagedTransactionEvent
+}
+
+func (te *testEvents) find(eventType apitypes.ManagedTransactionEventType, hash string) *apitypes.ManagedTransactionEvent {
+	te.mux.Lock()
+	defer te.mux.Unlock()
+	for _, e := range te.events {
+		if e.Type == eventType && e.Tx.TransactionHash == hash {
+			return &e
+		}
+	}
+	return nil
+}
+
+func newTestReplacementHandler(t *testing.T) (*simpleTransactionHandler, *ffcapimocks.ExtendedAPI, *testEvents, func()) {
+	f, tk, mfc, conf, cleanup := newTestTransactionHandlerFactoryWithFilePersistence(t)
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(Interval, "0")
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+	sth.Init(sth.ctx, tk)
+
+	te := &testEvents{}
+	meh := tk.EventHandler.(*txhandlermocks.ManagedTxEventHandler)
+	meh.On("HandleEvent", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		te.mux.Lock()
+		defer te.mux.Unlock()
+		te.events = append(te.events, args[1].(apitypes.ManagedTransactionEvent))
+	}).Return(nil)
+	mfc.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{}, ffcapi.ErrorReason(""), nil).Maybe()
+	return sth, mfc, te, cleanup
+}
+
+func mockSendByGasPrice(mfc *ffcapimocks.ExtendedAPI) {
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(func(_ context.Context, req *ffcapi.TransactionSendRequest) *ffcapi.TransactionSendResponse {
+		return &ffcapi.TransactionSendResponse{TransactionHash: fmt.Sprintf("0x%s", req.GasPrice)}
+	}, ffcapi.ErrorReason(""), nil)
+}
+
+// syncRequest runs an API request to the policy engine, processing it as the policy loop would
+func syncRequest(sth *simpleTransactionHandler, fn func() (*apitypes.ManagedTX, error)) (*apitypes.ManagedTX, error) {
+	type result struct {
+		mtx *apitypes.ManagedTX
+		err error
+	}
+	results := make(chan result)
+	go func() {
+		mtx, err := fn()
+		results <- result{mtx, err}
+	}()
+	for {
+		sth.mux.Lock()
+		queued := len(sth.policyEngineAPIRequests)
+		sth.mux.Unlock()
+		if queued > 0 {
+			break
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+	sth.processPolicyAPIRequests(sth.ctx)
+	res := <-results
+	return res.mtx, res.err
+}
+
+func updateTX(sth *simpleTransactionHandler, txID string, update *apitypes.TransactionUpdateRequest) (*apitypes.ManagedTX, error) {
+	return syncRequest(sth, func() (*apitypes.ManagedTX, error) {
+		return sth.HandleUpdateTransaction(sth.ctx, txID, update)
+	})
+}
+
+func cancelTX(sth *simpleTransactionHandler, txID string) (*apitypes.ManagedTX, error) {
+	return syncRequest(sth, func() (*apitypes.ManagedTX, error) {
+		return sth.HandleCancelByReplacement(sth.ctx, txID)
+	})
+}
+
+func storedPolicyInfo(t *testing.T, sth *simpleTransactionHandler, txID string) (*apitypes.ManagedTX, *simplePolicyInfo) {
+	mtx, err := sth.toolkit.TXPersistence.GetTransactionByID(sth.ctx, txID)
+	assert.NoError(t, err)
+	var info simplePolicyInfo
+	err = json.Unmarshal(mtx.PolicyInfo.Bytes(), &info)
+	assert.NoError(t, err)
+	return mtx, &info
+}
+
+func mineHash(t *testing.T, sth *simpleTransactionHandler, te *testEvents, hash string) {
+	added := te.find(apitypes.ManagedTXTransactionHashAdded, hash)
+	assert.NotNil(t, added)
+	err := added.ReceiptHandler(sth.ctx, added.Tx.ID, &ffcapi.TransactionReceiptResponse{Success: true})
+	assert.NoError(t, err)
+	err = sth.HandleTransactionConfirmations(sth.ctx, added.Tx.ID, &apitypes.ConfirmationsNotification{Confirmed: true})
+	assert.NoError(t, err)
+}
+
+func TestUpdateTransactionBeforeSubmit(t *testing.T) {
+	sth, mfc, _, cleanup := newTestReplacementHandler(t)
+	defer cleanup()
+	mfc.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == `99999` && req.Gas.Int64() == 200000
+	})).Return(&ffcapi.TransactionSendResponse{TransactionHash: "0x1000"}, ffcapi.ErrorReason(""), nil).Once()
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+
+	// The transaction is not yet in-flight
+	mtx, err := updateTX(sth, "ns1:tx1", &apitypes.TransactionUpdateRequest{
+		GasPrice: fftypes.JSONAnyPtr(`99999`),
+		Gas:      fftypes.NewFFBigInt(200000),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(200000), mtx.Gas.Int64())
+	stored, info := storedPolicyInfo(t, sth, "ns1:tx1")
+	assert.Equal(t, int64(200000), stored.Gas.Int64())
+	assert.Equal(t, `99999`, info.GasPriceOverride.String())
+	assert.Nil(t, stored.FirstSubmit)
+
+	// The changes are used for the first submission
+	runTestPolicyCycle(sth, true)
+	assert.Equal(t, "0x1000", sth.inflight[0].mtx.TransactionHash)
+	assert.Contains(t, txActions(t, sth, "ns1:tx1"), apitypes.TxActionUpdateTransaction)
+	mfc.AssertExpectations(t)
+}
+
+func TestUpdateTransactionReplacesSubmitted(t *testing.T) {
+	sth, mfc, te, cleanup := newTestReplacementHandler(t)
+	defer cleanup()
+	mockSendByGasPrice(mfc)
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	runTestPolicyCycle(sth, true)
+	assert.Equal(t, "0x12345", sth.inflight[0].mtx.TransactionHash)
+	assert.Equal(t, []string{"0x12345"}, sth.inflight[0].trackedHashes)
+
+	// A gas price lower than needed to replace the submission is raised
+	mtx, err := updateTX(sth, "ns1:tx1", &apitypes.TransactionUpdateRequest{
+		GasPrice: fftypes.JSONAnyPtr(`10000`),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "0x13580", mtx.TransactionHash)
+	assert.Equal(t, []string{"0x12345", "0x13580"}, sth.inflight[0].trackedHashes)
+	_, info := storedPolicyInfo(t, sth, "ns1:tx1")
+	assert.Equal(t, []string{"0x12345", "0x13580"}, info.SubmittedHashes)
+	assert.Nil(t, info.ReplacementGasPrice)
+
+	// The next is taken as it is
+	mtx, err = updateTX(sth, "ns1:tx1", &apitypes.TransactionUpdateRequest{
+		GasPrice: fftypes.JSONAnyPtr(`20000`),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "0x20000", mtx.TransactionHash)
+
+	// The original submission is mined
+	mineHash(t, sth, te, "0x12345")
+	runTestPolicyCycle(sth, false)
+	assert.Equal(t, apitypes.TxStatusSucceeded, sth.inflight[0].mtx.Status)
+	stored, _ := storedPolicyInfo(t, sth, "ns1:tx1")
+	assert.Equal(t, apitypes.TxStatusSucceeded, stored.Status)
+	assert.Equal(t, "0x12345", stored.TransactionHash)
+	assert.NotNil(t, te.find(apitypes.ManagedTXTransactionHashRemoved, "0x13580"))
+	assert.NotNil(t, te.find(apitypes.ManagedTXTransactionHashRemoved, "0x20000"))
+	assert.Nil(t, te.find(apitypes.ManagedTXTransactionHashRemoved, "0x12345"))
+}
+
+func TestUpdateTransactionReplacementFails(t *testing.T) {
+	sth, mfc, _, cleanup := newTestReplacementHandler(t)
+	defer cleanup()
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{TransactionHash: "0x1000"}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	runTestPolicyCycle(sth, true)
+
+	// The update is kept, for the next submission
+	_, err := updateTX(sth, "ns1:tx1", &apitypes.TransactionUpdateRequest{
+		Gas: fftypes.NewFFBigInt(200000),
+	})
+	assert.Regexp(t, "pop", err)
+	stored, info := storedPolicyInfo(t, sth, "ns1:tx1")
+	assert.Equal(t, int64(200000), stored.Gas.Int64())
+	assert.Equal(t, "0x1000", stored.TransactionHash)
+	assert.Nil(t, info.GasPriceOverride)
+	mfc.AssertExpectations(t)
+}
+
+func TestCancelByReplacement(t *testing.T) {
+	sth, mfc, te, cleanup := newTestReplacementHandler(t)
+	defer cleanup()
+	mockSendByGasPrice(mfc)
+	mfc.On("TransactionCancel", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionCancelRequest) bool {
+		return req.Nonce.Int64() == 1000 && req.GasPrice.String() == "13580"
+	})).Return(&ffcapi.TransactionCancelResponse{TransactionHash: "0xcancel"}, ffcapi.ErrorReason(""), nil).Once()
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	runTestPolicyCycle(sth, true)
+
+	mtx, err := cancelTX(sth, "ns1:tx1")
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusPending, mtx.Status)
+	assert.Equal(t, []string{"0x12345", "0xcancel"}, sth.inflight[0].trackedHashes)
+	_, info := storedPolicyInfo(t, sth, "ns1:tx1")
+	assert.Equal(t, "0xcancel", info.CancelHash)
+
+	// No further changes can be made, and the transaction is not resubmitted while we wait for a receipt
+	_, err = updateTX(sth, "ns1:tx1", &apitypes.TransactionUpdateRequest{Gas: fftypes.NewFFBigInt(200000)})
+	assert.Regexp(t, "FF21137.*cancelled", err)
+	sth.resubmitInterval = 0
+	runTestPolicyCycle(sth, false)
+	assert.Equal(t, "0x12345", sth.inflight[0].mtx.TransactionHash)
+
+	// The cancellation is mined
+	mineHash(t, sth, te, "0xcancel")
+	runTestPolicyCycle(sth, false)
+	stored, _ := storedPolicyInfo(t, sth, "ns1:tx1")
+	assert.Equal(t, apitypes.TxStatusFailed, stored.Status)
+	assert.Equal(t, "0xcancel", stored.TransactionHash)
+	assert.Regexp(t, "FF21140", stored.ErrorMessage)
+	assert.NotNil(t, te.find(apitypes.ManagedTXTransactionHashRemoved, "0x12345"))
+	assert.Contains(t, txActions(t, sth, "ns1:tx1"), apitypes.TxActionCancelTransaction)
+
+	_, err = cancelTX(sth, "ns1:tx1")
+	assert.Regexp(t, "FF21137.*Failed", err)
+	mfc.AssertExpectations(t)
+}
+
+func TestExpiredTransactionMinedAfterCancel(t *testing.T) {
+	sth, mfc, te, cleanup := newTestReplacementHandler(t)
+	defer cleanup()
+	mockSendByGasPrice(mfc)
+	mfc.On("TransactionCancel", mock.Anything, mock.Anything).Return(&ffcapi.TransactionCancelResponse{TransactionHash: "0xcancel"}, ffcapi.ErrorReason(""), nil).Once()
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	runTestPolicyCycle(sth, true)
+
+	// The transaction expires after it has been submitted
+	expiry := fftypes.FFTime(time.Now().Add(-1 * time.Hour))
+	sth.inflight[0].mtx.Expiry = &expiry
+	runTestPolicyCycle(sth, false)
+	assert.Equal(t, apitypes.TxStatusPending, sth.inflight[0].mtx.Status)
+	assert.Equal(t, []string{"0x12345", "0xcancel"}, sth.inflight[0].trackedHashes)
+	_, info := storedPolicyInfo(t, sth, "ns1:tx1")
+	assert.Equal(t, "0xcancel", info.CancelHash)
+
+	// Our submission is mined ahead of the cancellation
+	mineHash(t, sth, te, "0x12345")
+	runTestPolicyCycle(sth, false)
+	stored, _ := storedPolicyInfo(t, sth, "ns1:tx1")
+	assert.Equal(t, apitypes.TxStatusSucceeded, stored.Status)
+	assert.Equal(t, "0x12345", stored.TransactionHash)
+	assert.NotNil(t, te.find(apitypes.ManagedTXTransactionHashRemoved, "0xcancel"))
+	mfc.AssertExpectations(t)
+}
+
+func TestExpiredTransactionCancellationMined(t *testing.T) {
+	sth, mfc, te, cleanup := newTestReplacementHandler(t)
+	defer cleanup()
+	mockSendByGasPrice(mfc)
+	mfc.On("TransactionCancel", mock.Anything, mock.Anything).Return(&ffcapi.TransactionCancelResponse{TransactionHash: "0xcancel"}, ffcapi.ErrorReason(""), nil).Once()
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	runTestPolicyCycle(sth, true)
+
+	expiry := fftypes.FFTime(time.Now().Add(-1 * time.Hour))
+	sth.inflight[0].mtx.Expiry = &expiry
+	runTestPolicyCycle(sth, false)
+
+	// The cancellation is mined, so the transaction fails as expired
+	mineHash(t, sth, te, "0xcancel")
+	runTestPolicyCycle(sth, false)
+	stored, _ := storedPolicyInfo(t, sth, "ns1:tx1")
+	assert.Equal(t, apitypes.TxStatusFailed, stored.Status)
+	assert.Equal(t, "0xcancel", stored.TransactionHash)
+	assert.Regexp(t, "FF21125", stored.ErrorMessage)
+	mfc.AssertExpectations(t)
+}
+
+func TestCancelByReplacementNotSubmitted(t *testing.T) {
+	sth, mfc, _, cleanup := newTestReplacementHandler(t)
+	defer cleanup()
+	mfc.On("TransactionCancel", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	mfc.On("TransactionCancel", mock.Anything, mock.Anything).Return(&ffcapi.TransactionCancelResponse{TransactionHash: "0xcancel"}, ffcapi.ErrorReason(""), nil).Once()
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	// An expected hash has been recorded, but we have no confirmation the transaction was submitted
+	err := sth.toolkit.TXPersistence.UpdateTransaction(sth.ctx, "ns1:tx1", &apitypes.TXUpdates{
+		PolicyInfo: fftypes.JSONAnyPtr(`{"expectedHash":"0x1000"}`),
+	})
+	assert.NoError(t, err)
+
+	_, err = cancelTX(sth, "ns1:tx1")
+	assert.Regexp(t, "pop", err)
+
+	// The nonce is filled, and the transaction failed straight away
+	mtx, err := cancelTX(sth, "ns1:tx1")
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusFailed, mtx.Status)
+	stored, _ := storedPolicyInfo(t, sth, "ns1:tx1")
+	assert.Equal(t, apitypes.TxStatusFailed, stored.Status)
+	assert.Regexp(t, "FF21140", stored.ErrorMessage)
+	mfc.AssertExpectations(t)
+}
+
+func TestCancelByReplacementFails(t *testing.T) {
+	sth, mfc, _, cleanup := newTestReplacementHandler(t)
+	defer cleanup()
+	mockSendByGasPrice(mfc)
+	mfc.On("TransactionCancel", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNonceTooLow, fmt.Errorf("nonce too low")).Once()
+	mfc.On("TransactionCancel", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNotSupported, fmt.Errorf("not supported")).Once()
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	runTestPolicyCycle(sth, true)
+
+	_, err := cancelTX(sth, "ns1:tx1")
+	assert.Regexp(t, "FF21139.*1000.*0x12345", err)
+	_, err = cancelTX(sth, "ns1:tx1")
+	assert.Regexp(t, "not supported", err)
+	assert.Equal(t, apitypes.TxStatusPending, sth.inflight[0].mtx.Status)
+	assert.Equal(t, []string{"0x12345"}, sth.inflight[0].trackedHashes)
+	mfc.AssertExpectations(t)
+}
+
+func TestCancelByReplacementGasPriceFail(t *testing.T) {
+	sth, mfc, _, cleanup := newTestReplacementHandler(t)
+	defer cleanup()
+	sth.gasOracleMode = GasOracleModeConnector
+	mfc.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	txHash := "0x1000"
+	err := sth.toolkit.TXPersistence.UpdateTransaction(sth.ctx, "ns1:tx1", &apitypes.TXUpdates{
+		TransactionHash: &txHash,
+		FirstSubmit:     fftypes.Now(),
+	})
+	assert.NoError(t, err)
+
+	_, err = cancelTX(sth, "ns1:tx1")
+	assert.Regexp(t, "pop", err)
+	mfc.AssertExpectations(t)
+}
+
+func TestReplaceTransactionErrors(t *testing.T) {
+	sth, _, _, cleanup := newTestReplacementHandler(t)
+	defer cleanup()
+
+	_, err := sth.HandleUpdateTransaction(sth.ctx, "ns1:tx1", &apitypes.TransactionUpdateRequest{})
+	assert.Regexp(t, "FF21136", err)
+
+	_, err = updateTX(sth, "ns1:unknown", &apitypes.TransactionUpdateRequest{Gas: fftypes.NewFFBigInt(200000)})
+	assert.Regexp(t, "FF21067", err)
+
+	err = sth.replaceTransaction(&RunContext{
+		Context: sth.ctx,
+		TX:      &apitypes.ManagedTX{ID: "ns1:tx1", Status: apitypes.TxStatusPending, TransactionHash: "0x1000"},
+		Receipt: &ffcapi.TransactionReceiptResponse{},
+		Info:    &simplePolicyInfo{},
+	})
+	assert.Regexp(t, "FF21138.*0x1000", err)
+
+	err = sth.HandleTransactionReceiptReceived(sth.ctx, "ns1:unknown", &ffcapi.TransactionReceiptResponse{})
+	assert.Regexp(t, "FF21067", err)
+}
+
+func TestUntrackHashesFail(t *testing.T) {
+	sth, _, _, cleanup := newTestReplacementHandler(t)
+	defer cleanup()
+	meh := &txhandlermocks.ManagedTxEventHandler{}
+	meh.On("HandleEvent", mock.Anything, mock.MatchedBy(func(e apitypes.ManagedTransactionEvent) bool {
+		return e.Type == apitypes.ManagedTXTransactionHashRemoved && e.Tx.TransactionHash == "0x1000"
+	})).Return(fmt.Errorf("pop")).Once()
+	sth.toolkit.EventHandler = meh
+
+	pending := &pendingState{trackedHashes: []string{"0x1000", "0x2000"}}
+	sth.untrackHashes(&RunContext{Context: sth.ctx, TX: &apitypes.ManagedTX{ID: "ns1:tx1"}}, pending, "0x2000")
+	assert.Empty(t, pending.trackedHashes)
+	meh.AssertExpectations(t)
+}
@@ -0,0 +1,332 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simple
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+const metricsGaugeSignerQueueDepth = "tx_signer_queue_depth"
+const metricsGaugeSignerQueueDepthDescription = "Number of pending transactions for each signer waiting to be added to the in flight queue, counting up to the maximum in flight"
+const metricsLabelNameSigner = "signer"
+
+func parseSignerWeights(weights fftypes.JSONObject) map[string]int {
+	signerWeights := make(map[string]int, len(weights))
+	for signer := range weights {
+		signerWeights[strings.ToLower(signer)] = int(weights.GetInt64(signer))
+	}
+	return signerWeights
+}
+
+type pendingCandidate struct {
+	mtx      *apitypes.ManagedTX
+	priority int // includes the priority of any later transactions from the same signer, as they cannot be submitted before this one
+}
+
+// selectBySigner is true when the in-flight set cannot simply be filled from the head
+// of the pending queue, because we need to apply per-signer limits, fairness or priority
+func (sth *simpleTransactionHandler) selectBySigner() bool {
+	return sth.signerSelection != SignerSelectionSequence || sth.maxInFlightPerSigner > 0 || sth.priorityEnabled
+}
+
+func (sth *simpleTransactionHandler) signerWeight(signer string) int {
+	if sth.signerSelection != SignerSelectionWeighted {
+		return 1
+	}
+	if weight := sth.signerWeights[signer]; weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+func (sth *simpleTransactionHandler) txPriority(mtx *apitypes.ManagedTX) int {
+	if !sth.priorityEnabled {
+		return 0
+	}
+	return mtx.Priority
+}
+
+// selectPendingBySigner reads the pending transactions that are not already in flight for each signer, and selects
+// up to the requested number of them:
+//   - never exceeding maxInFlightPerSigner for any signer
+//   - not taking any more from a signer with a transaction in flight waiting for a dependency
+//   - preserving the nonce order of transactions from the same signer, so nonces are submitted in order
+//   - when priority is enabled, taking the signers with the highest priority transactions first
+//   - in sequence mode, taking the eligible transactions in the order they were created
+//   - in roundRobin/weighted mode, taking 1 or `weight` transactions from each signer in turn,
+//     continuing from the signer that was last served, so that no signer is starved across cycles
+//
+// Rather than reading the whole pending table on every refresh, only the pending transactions created since the
+// last refresh are scanned to find new signers, and then each signer is queried by nonce for up to maxInFlight of
+// its queued transactions. Priority inheritance and the queue depth metric are limited to those transactions.
+func (sth *simpleTransactionHandler) selectPendingBySigner(ctx context.Context, spaces int) ([]*apitypes.ManagedTX, error) {
+	inflightIDs := make(map[string]bool, len(sth.inflight))
+	signerCounts := make(map[string]int)
+	waitingSigners := make(map[string]bool)
+	sth.mux.Lock()
+	for _, p := range sth.inflight {
+		inflightIDs[p.mtx.ID] = true
+		signerCounts[strings.ToLower(p.mtx.From)]++
+		if p.dependency != nil {
+			waitingSigners[strings.ToLower(p.mtx.From)] = true
+		}
+	}
+	sth.mux.Unlock()
+	signerSpaces := func(signer string) int {
+		if waitingSigners[signer] {
+			// Any more transactions from the signer would wait behind the one waiting for a dependency
+			return 0
+		}
+		if sth.maxInFlightPerSigner > 0 && sth.maxInFlightPerSigner-signerCounts[signer] < spaces {
+			return sth.maxInFlightPerSigner - signerCounts[signer]
+		}
+		return spaces
+	}
+
+	if err := sth.scanPendingSigners(ctx); err != nil {
+		return nil, err
+	}
+	pendingSigners := make([]string, 0, len(sth.pendingSigners))
+	for signer := range sth.pendingSigners {
+		pendingSigners = append(pendingSigners, signer)
+	}
+	sort.Strings(pendingSigners)
+
+	queues := make(map[string][]*pendingCandidate)
+	queueDepth := make(map[string]int)
+	tailPriority := make(map[string]int)
+	for _, signer := range pendingSigners {
+		pending, err := sth.listSignerPending(ctx, signer, inflightIDs)
+		if err != nil {
+			return nil, err
+		}
+		if len(pending) == 0 {
+			// Found again by the scan if it has more transactions created, or one is resumed
+			delete(sth.pendingSigners, signer)
+			continue
+		}
+		for _, mtx := range pending {
+			queueDepth[signer]++
+			if len(queues[signer]) < signerSpaces(signer) {
+				queues[signer] = append(queues[signer], &pendingCandidate{mtx: mtx, priority: sth.txPriority(mtx)})
+			} else if p, ok := tailPriority[signer]; !ok || sth.txPriority(mtx) > p {
+				tailPriority[signer] = sth.txPriority(mtx)
+			}
+		}
+	}
+
+	signers := make([]string, 0, len(queues))
+	for signer, queue := range queues {
+		signers = append(signers, signer)
+		// Transactions inherit the highest priority of those queued behind them for the same signer
+		for i := len(queue) - 1; i >= 0; i-- {
+			if p, ok := tailPriority[signer]; ok && p > queue[i].priority {
+				queue[i].priority = p
+			}
+			tailPriority[signer] = queue[i].priority
+		}
+	}
+	sort.Strings(signers)
+
+	selected := make([]*apitypes.ManagedTX, 0, spaces)
+	for len(selected) < spaces {
+		signer := sth.nextSigner(signers, queues)
+		if signer == "" {
+			break
+		}
+		selected = append(selected, queues[signer][0].mtx)
+		queues[signer] = queues[signer][1:]
+		queueDepth[signer]--
+	}
+
+	sth.setSignerQueueDepthMetrics(ctx, queueDepth)
+	return selected, nil
+}
+
+// scanPendingSigners reads the pending transactions created since the last scan, to find any new signers
+func (sth *simpleTransactionHandler) scanPendingSigners(ctx context.Context) error {
+	for {
+		var page []*apitypes.ManagedTX
+		// We retry the get from persistence indefinitely (until the context cancels)
+		err := sth.retry.Do(ctx, "get pending transactions", func(attempt int) (retry bool, err error) {
+			page, err = sth.toolkit.TXPersistence.ListTransactionsPending(ctx, sth.pendingScanned, sth.maxInFlight, persistence.SortDirectionAscending)
+			return true, err
+		})
+		if err != nil {
+			return err
+		}
+		for _, mtx := range page {
+			sth.addPendingSigner(mtx)
+		}
+		if len(page) > 0 {
+			sth.pendingScanned = page[len(page)-1].SequenceID
+		}
+		if len(page) < sth.maxInFlight {
+			return nil
+		}
+	}
+}
+
+// addPendingSigner records that the signer has a pending transaction, so that the next signer query starts no
+// later than its nonce. Only called on the policy loop.
+func (sth *simpleTransactionHandler) addPendingSigner(mtx *apitypes.ManagedTX) {
+	if mtx.Nonce == nil {
+		return
+	}
+	signer := strings.ToLower(mtx.From)
+	if sth.pendingSigners[signer] == nil {
+		sth.pendingSigners[signer] = make(map[string]*fftypes.FFBigInt)
+	}
+	after := fftypes.NewFFBigInt(mtx.Nonce.Int64() - 1)
+	if existing, ok := sth.pendingSigners[signer][mtx.From]; !ok || existing.Int().Cmp(after.Int()) > 0 {
+		sth.pendingSigners[signer][mtx.From] = after
+	}
+}
+
+// listSignerPending returns the transactions of the signer that are pending and not already in flight, in nonce order.
+// Signers are queried by the exact address of their transactions, so the results for each casing of the signer seen
+// are merged - and a casing with nothing pending is dropped.
+func (sth *simpleTransactionHandler) listSignerPending(ctx context.Context, signer string, inflightIDs map[string]bool) ([]*apitypes.ManagedTX, error) {
+	froms := make([]string, 0, len(sth.pendingSigners[signer]))
+	for from := range sth.pendingSigners[signer] {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+	pending := []*apitypes.ManagedTX{}
+	for _, from := range froms {
+		fromPending, err := sth.listFromPending(ctx, signer, from, inflightIDs)
+		if err != nil {
+			return nil, err
+		}
+		if len(fromPending) == 0 {
+			delete(sth.pendingSigners[signer], from)
+		}
+		pending = append(pending, fromPending...)
+	}
+	if len(froms) > 1 {
+		sort.SliceStable(pending, func(i, j int) bool {
+			return pending[i].Nonce.Int().Cmp(pending[j].Nonce.Int()) < 0
+		})
+	}
+	return pending, nil
+}
+
+// listFromPending queries the transactions of one casing of the signer in nonce order, returning up to maxInFlight of
+// those that are pending and not already in flight. The nonce the next query starts after is moved past any leading
+// completed transactions, but never past one that is in flight or suspended - as it might return to the queue.
+func (sth *simpleTransactionHandler) listFromPending(ctx context.Context, signer, from string, inflightIDs map[string]bool) ([]*apitypes.ManagedTX, error) {
+	after := sth.pendingSigners[signer][from]
+	completedHead := true
+	pending := []*apitypes.ManagedTX{}
+	for len(pending) < sth.maxInFlight {
+		var page []*apitypes.ManagedTX
+		err := sth.retry.Do(ctx, "get transactions for signer", func(attempt int) (retry bool, err error) {
+			page, err = sth.toolkit.TXPersistence.ListTransactionsByNonce(ctx, from, after, sth.maxInFlight, persistence.SortDirectionAscending)
+			return true, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, mtx := range page {
+			switch {
+			case mtx.Status == apitypes.TxStatusPending && !inflightIDs[mtx.ID]:
+				pending = append(pending, mtx)
+				completedHead = false
+			case mtx.Status == apitypes.TxStatusSucceeded || mtx.Status == apitypes.TxStatusFailed:
+				if completedHead {
+					sth.pendingSigners[signer][from] = mtx.Nonce
+				}
+			default:
+				completedHead = false
+			}
+		}
+		if len(page) < sth.maxInFlight {
+			break
+		}
+		after = page[len(page)-1].Nonce
+	}
+	return pending, nil
+}
+
+// nextSigner chooses the signer to take the next transaction from, out of those with a transaction
+// of the highest priority at the head of their queue. Returns "" when all queues are empty.
+func (sth *simpleTransactionHandler) nextSigner(signers []string, queues map[string][]*pendingCandidate) string {
+	found := false
+	maxPriority := 0
+	for _, signer := range signers {
+		if queue := queues[signer]; len(queue) > 0 && (!found || queue[0].priority > maxPriority) {
+			found = true
+			maxPriority = queue[0].priority
+		}
+	}
+	if !found {
+		return ""
+	}
+	eligible := func(signer string) bool {
+		queue := queues[signer]
+		return len(queue) > 0 && queue[0].priority == maxPriority
+	}
+
+	if sth.signerSelection == SignerSelectionSequence {
+		next := ""
+		for _, signer := range signers {
+			if eligible(signer) && (next == "" || queues[signer][0].mtx.Created.Time().Before(*queues[next][0].mtx.Created.Time())) {
+				next = signer
+			}
+		}
+		return next
+	}
+
+	// Continue with the signer we last served, if it has not used up its weight
+	if sth.signerCredit > 0 && eligible(sth.signerCursor) {
+		sth.signerCredit--
+		return sth.signerCursor
+	}
+	// Otherwise move on to the next signer after it
+	start := sort.SearchStrings(signers, sth.signerCursor)
+	if start < len(signers) && signers[start] == sth.signerCursor {
+		start++
+	}
+	// This always terminates, as the signer with the highest priority is eligible
+	for i := 0; ; i++ {
+		signer := signers[(start+i)%len(signers)]
+		if eligible(signer) {
+			sth.signerCursor = signer
+			sth.signerCredit = sth.signerWeight(signer) - 1
+			return signer
+		}
+	}
+}
+
+func (sth *simpleTransactionHandler) setSignerQueueDepthMetrics(ctx context.Context, queueDepth map[string]int) {
+	// Signers that no longer have anything queued are reset to zero, rather than left at their last value
+	for signer := range sth.signerQueueDepth {
+		if _, ok := queueDepth[signer]; !ok {
+			sth.toolkit.MetricsManager.SetTxHandlerGaugeMetricWithLabels(ctx, metricsGaugeSignerQueueDepth, 0, map[string]string{metricsLabelNameSigner: signer}, nil)
+		}
+	}
+	for signer, depth := range queueDepth {
+		sth.toolkit.MetricsManager.SetTxHandlerGaugeMetricWithLabels(ctx, metricsGaugeSignerQueueDepth, float64(depth), map[string]string{metricsLabelNameSigner: signer}, nil)
+	}
+	sth.signerQueueDepth = queueDepth
+}
@@ -0,0 +1,404 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simple
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestPendingTX(signer string, seq int) *apitypes.ManagedTX {
+	created := fftypes.FFTime(time.Unix(0, int64(seq)))
+	return &apitypes.ManagedTX{
+		ID:         fmt.Sprintf("ns1:%s-%d", signer, seq),
+		SequenceID: fmt.Sprintf("%.12d", seq),
+		Created:    &created,
+		Status:     apitypes.TxStatusPending,
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From:  signer,
+			Nonce: fftypes.NewFFBigInt(int64(seq)),
+		},
+	}
+}
+
+func nonceAfter(nonce int64) interface{} {
+	return mock.MatchedBy(func(after *fftypes.FFBigInt) bool {
+		return after != nil && after.Int64() == nonce
+	})
+}
+
+func newTestSignerSelectionHandler(t *testing.T, maxInFlight int, setConf func(conf map[string]interface{})) (*simpleTransactionHandler, *persistencemocks.Persistence) {
+	f, tk, _, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(MaxInFlight, maxInFlight)
+	settings := map[string]interface{}{}
+	setConf(settings)
+	for k, v := range settings {
+		conf.Set(k, v)
+	}
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+	sth.Init(sth.ctx, tk)
+	return sth, sth.toolkit.TXPersistence.(*persistencemocks.Persistence)
+}
+
+func inflightIDs(sth *simpleTransactionHandler) []string {
+	ids := make([]string, len(sth.inflight))
+	for i, p := range sth.inflight {
+		ids[i] = p.mtx.ID
+	}
+	return ids
+}
+
+func TestSignerSelectionRoundRobin(t *testing.T) {
+
+	sth, mp := newTestSignerSelectionHandler(t, 3, func(conf map[string]interface{}) {
+		conf[SignerSelection] = SignerSelectionRoundRobin
+	})
+
+	// One noisy signer at the head of the queue
+	a1, a2, a3, a4, a5 := newTestPendingTX("0xaaaa", 1), newTestPendingTX("0xaaaa", 2), newTestPendingTX("0xaaaa", 3), newTestPendingTX("0xaaaa", 4), newTestPendingTX("0xaaaa", 5)
+	b1, c1 := newTestPendingTX("0xBBBB", 6), newTestPendingTX("0xcccc", 7)
+	mp.On("ListTransactionsPending", sth.ctx, "", 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a1, a2, a3}, nil).Once()
+	mp.On("ListTransactionsPending", sth.ctx, a3.SequenceID, 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a4, a5, b1}, nil).Once()
+	mp.On("ListTransactionsPending", sth.ctx, b1.SequenceID, 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{c1}, nil).Once()
+	// Only enough of the noisy signer's transactions are read to fill the in-flight set
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xaaaa", nonceAfter(0), 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a1, a2, a3}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xBBBB", nonceAfter(5), 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{b1}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xcccc", nonceAfter(6), 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{c1}, nil).Once()
+
+	assert.True(t, sth.updateInflightSet(sth.ctx))
+	assert.Equal(t, []string{a1.ID, b1.ID, c1.ID}, inflightIDs(sth))
+	assert.Equal(t, apitypes.TxSubStatusReceived, sth.inflight[0].subStatus)
+	assert.Equal(t, map[string]int{"0xaaaa": 2, "0xbbbb": 0, "0xcccc": 0}, sth.signerQueueDepth)
+	assert.Equal(t, "0xcccc", sth.signerCursor)
+
+	// Once the first completes, the next from the noisy signer is picked up,
+	// as the last signer served was 0xcccc. Only the transaction created since
+	// the last refresh is scanned, and the completed transaction is not read again.
+	c2 := newTestPendingTX("0xcccc", 8)
+	a1.Status = apitypes.TxStatusSucceeded
+	sth.inflight[0].remove = true
+	mp.On("ListTransactionsPending", sth.ctx, c1.SequenceID, 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{c2}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xaaaa", nonceAfter(0), 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a1, a2, a3}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xaaaa", nonceAfter(3), 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a4, a5}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xBBBB", nonceAfter(5), 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{b1}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xcccc", nonceAfter(6), 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{c1, c2}, nil).Once()
+
+	assert.True(t, sth.updateInflightSet(sth.ctx))
+	assert.Equal(t, []string{b1.ID, c1.ID, a2.ID}, inflightIDs(sth))
+	assert.Equal(t, map[string]int{"0xaaaa": 3, "0xcccc": 1}, sth.signerQueueDepth)
+	// The signer with nothing queued is dropped until it has a new transaction
+	assert.Len(t, sth.pendingSigners, 2)
+	assert.Equal(t, int64(1), sth.pendingSigners["0xaaaa"]["0xaaaa"].Int64())
+	assert.Equal(t, int64(6), sth.pendingSigners["0xcccc"]["0xcccc"].Int64())
+
+	mp.AssertExpectations(t)
+}
+
+func TestSignerSelectionMergesSignerCasings(t *testing.T) {
+
+	sth, mp := newTestSignerSelectionHandler(t, 3, func(conf map[string]interface{}) {
+		conf[SignerSelection] = SignerSelectionRoundRobin
+	})
+
+	// The same signer with two casings is queried for each, and queued once in nonce order
+	a1, a2, a3 := newTestPendingTX("0xaaaa", 1), newTestPendingTX("0xAAAA", 2), newTestPendingTX("0xaaaa", 3)
+	mp.On("ListTransactionsPending", sth.ctx, "", 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a1, a2, a3}, nil).Once()
+	mp.On("ListTransactionsPending", sth.ctx, a3.SequenceID, 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xAAAA", nonceAfter(1), 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a2}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xaaaa", nonceAfter(0), 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a1, a3}, nil).Once()
+
+	assert.True(t, sth.updateInflightSet(sth.ctx))
+	assert.Equal(t, []string{a1.ID, a2.ID, a3.ID}, inflightIDs(sth))
+	assert.Equal(t, map[string]int{"0xaaaa": 0}, sth.signerQueueDepth)
+	assert.Len(t, sth.pendingSigners, 1)
+	assert.Len(t, sth.pendingSigners["0xaaaa"], 2)
+
+	// A casing with nothing more pending is dropped, leaving the signer queued under the other
+	a4 := newTestPendingTX("0xaaaa", 4)
+	a2.Status = apitypes.TxStatusSucceeded
+	sth.inflight[1].remove = true
+	mp.On("ListTransactionsPending", sth.ctx, a3.SequenceID, 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a4}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xAAAA", nonceAfter(1), 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a2}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xaaaa", nonceAfter(0), 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a1, a3, a4}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xaaaa", nonceAfter(4), 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{}, nil).Once()
+
+	assert.True(t, sth.updateInflightSet(sth.ctx))
+	assert.Equal(t, []string{a1.ID, a3.ID, a4.ID}, inflightIDs(sth))
+	assert.Equal(t, map[string]*fftypes.FFBigInt{"0xaaaa": fftypes.NewFFBigInt(0)}, sth.pendingSigners["0xaaaa"])
+
+	mp.AssertExpectations(t)
+}
+
+func TestSignerSelectionWeightedWithSignerLimit(t *testing.T) {
+
+	sth, mp := newTestSignerSelectionHandler(t, 5, func(conf map[string]interface{}) {
+		conf[SignerSelection] = SignerSelectionWeighted
+		conf[MaxInFlightPerSigner] = 2
+		conf[SignerWeights] = map[string]interface{}{"0xAAAA": "3", "0xbbbb": 0}
+	})
+	assert.Equal(t, map[string]int{"0xaaaa": 3, "0xbbbb": 0}, sth.signerWeights)
+
+	a1, a2, a3 := newTestPendingTX("0xaaaa", 1), newTestPendingTX("0xaaaa", 2), newTestPendingTX("0xaaaa", 3)
+	b1, b2 := newTestPendingTX("0xbbbb", 4), newTestPendingTX("0xbbbb", 5)
+	mp.On("ListTransactionsPending", sth.ctx, "", 5, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a1, a2, a3, b1, b2}, nil).Once()
+	mp.On("ListTransactionsPending", sth.ctx, b2.SequenceID, 5, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xaaaa", nonceAfter(0), 5, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a1, a2, a3}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xbbbb", nonceAfter(3), 5, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{b1, b2}, nil).Once()
+
+	assert.True(t, sth.updateInflightSet(sth.ctx))
+	assert.Equal(t, []string{a1.ID, a2.ID, b1.ID, b2.ID}, inflightIDs(sth))
+	assert.Equal(t, map[string]int{"0xaaaa": 1, "0xbbbb": 0}, sth.signerQueueDepth)
+
+	mp.AssertExpectations(t)
+}
+
+func TestSignerSelectionSequenceWithSignerLimit(t *testing.T) {
+
+	sth, mp := newTestSignerSelectionHandler(t, 3, func(conf map[string]interface{}) {
+		conf[MaxInFlightPerSigner] = 1
+	})
+
+	// b1 was created first, so is taken first
+	a1, a2, b1, b2 := newTestPendingTX("0xaaaa", 2), newTestPendingTX("0xaaaa", 3), newTestPendingTX("0xbbbb", 1), newTestPendingTX("0xbbbb", 4)
+	mp.On("ListTransactionsPending", sth.ctx, "", 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{b1, a1, a2}, nil).Once()
+	mp.On("ListTransactionsPending", sth.ctx, a2.SequenceID, 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{b2}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xaaaa", nonceAfter(1), 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a1, a2}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xbbbb", nonceAfter(0), 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{b1, b2}, nil).Once()
+
+	assert.True(t, sth.updateInflightSet(sth.ctx))
+	assert.Equal(t, []string{b1.ID, a1.ID}, inflightIDs(sth))
+	assert.Equal(t, map[string]int{"0xaaaa": 1, "0xbbbb": 1}, sth.signerQueueDepth)
+
+	mp.AssertExpectations(t)
+}
+
+func TestSignerSelectionPriority(t *testing.T) {
+
+	sth, mp := newTestSignerSelectionHandler(t, 3, func(conf map[string]interface{}) {
+		conf[PriorityEnabled] = true
+	})
+
+	a1, a2, b1, b2 := newTestPendingTX("0xaaaa", 1), newTestPendingTX("0xaaaa", 2), newTestPendingTX("0xbbbb", 3), newTestPendingTX("0xbbbb", 4)
+	c1, d1 := newTestPendingTX("0xcccc", 5), newTestPendingTX("0xdddd", 6)
+	b2.Priority = 5 // b1 must be submitted first, so inherits this
+	c1.Priority = 1
+	d1.Priority = -1
+	mp.On("ListTransactionsPending", sth.ctx, "", 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a1, a2, b1}, nil).Once()
+	mp.On("ListTransactionsPending", sth.ctx, b1.SequenceID, 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{b2, c1, d1}, nil).Once()
+	mp.On("ListTransactionsPending", sth.ctx, d1.SequenceID, 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xaaaa", nonceAfter(0), 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a1, a2}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xbbbb", nonceAfter(2), 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{b1, b2}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xcccc", nonceAfter(4), 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{c1}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xdddd", nonceAfter(5), 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{d1}, nil).Once()
+
+	assert.True(t, sth.updateInflightSet(sth.ctx))
+	assert.Equal(t, []string{b1.ID, b2.ID, c1.ID}, inflightIDs(sth))
+	assert.Equal(t, map[string]int{"0xaaaa": 2, "0xbbbb": 0, "0xcccc": 0, "0xdddd": 1}, sth.signerQueueDepth)
+
+	mp.AssertExpectations(t)
+}
+
+func TestSignerSelectionPriorityBeyondSignerLimit(t *testing.T) {
+
+	sth, mp := newTestSignerSelectionHandler(t, 2, func(conf map[string]interface{}) {
+		conf[PriorityEnabled] = true
+		conf[MaxInFlightPerSigner] = 1
+	})
+
+	// b2 cannot be selected, as b is limited to one in-flight transaction, but it still gives b1 priority
+	a1, b1, b2 := newTestPendingTX("0xaaaa", 1), newTestPendingTX("0xbbbb", 2), newTestPendingTX("0xbbbb", 3)
+	b2.Priority = 9
+	mp.On("ListTransactionsPending", sth.ctx, "", 2, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a1, b1}, nil).Once()
+	mp.On("ListTransactionsPending", sth.ctx, b1.SequenceID, 2, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{b2}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xaaaa", nonceAfter(0), 2, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a1}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xbbbb", nonceAfter(1), 2, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{b1, b2}, nil).Once()
+
+	assert.True(t, sth.updateInflightSet(sth.ctx))
+	assert.Equal(t, []string{b1.ID, a1.ID}, inflightIDs(sth))
+
+	mp.AssertExpectations(t)
+}
+
+func TestSignerSelectionSkipsInflightAndSuspended(t *testing.T) {
+
+	sth, mp := newTestSignerSelectionHandler(t, 2, func(conf map[string]interface{}) {
+		conf[SignerSelection] = SignerSelectionRoundRobin
+	})
+
+	// The query for the signer only moves past the completed transaction at the head, as the suspended
+	// one might be resumed, and continues past those that cannot be selected until it has enough
+	a1, a2, a3, a4, a5, a6 := newTestPendingTX("0xaaaa", 1), newTestPendingTX("0xaaaa", 2), newTestPendingTX("0xaaaa", 3), newTestPendingTX("0xaaaa", 4), newTestPendingTX("0xaaaa", 5), newTestPendingTX("0xaaaa", 6)
+	a1.Status = apitypes.TxStatusFailed
+	a2.Status = apitypes.TxStatusSuspended
+	a3.Status = apitypes.TxStatusSucceeded
+	sth.inflight = []*pendingState{{mtx: a4}}
+	sth.pendingSigners["0xaaaa"] = map[string]*fftypes.FFBigInt{"0xaaaa": fftypes.NewFFBigInt(0)}
+	mp.On("ListTransactionsPending", sth.ctx, "", 2, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a5}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xaaaa", nonceAfter(0), 2, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a1, a2}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xaaaa", nonceAfter(2), 2, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a3, a4}, nil).Once()
+	mp.On("ListTransactionsByNonce", sth.ctx, "0xaaaa", nonceAfter(4), 2, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a5, a6}, nil).Once()
+
+	assert.True(t, sth.updateInflightSet(sth.ctx))
+	assert.Equal(t, []string{a4.ID, a5.ID}, inflightIDs(sth))
+	assert.Equal(t, map[string]int{"0xaaaa": 1}, sth.signerQueueDepth)
+	assert.Equal(t, int64(1), sth.pendingSigners["0xaaaa"]["0xaaaa"].Int64())
+
+	// A transaction without a nonce cannot be queried by signer
+	sth.addPendingSigner(&apitypes.ManagedTX{TransactionHeaders: ffcapi.TransactionHeaders{From: "0xbbbb"}})
+	assert.NotContains(t, sth.pendingSigners, "0xbbbb")
+
+	mp.AssertExpectations(t)
+}
+
+func TestSignerSelectionPriorityPersisted(t *testing.T) {
+
+	f, tk, _, conf, cleanup := newTestTransactionHandlerFactoryWithFilePersistence(t)
+	defer cleanup()
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(MaxInFlight, 1)
+	conf.Set(PriorityEnabled, true)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+	sth.Init(sth.ctx, tk)
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	mtx := sendSampleTXWithHeaders(t, sth, "0xbbbb", 2000, apitypes.RequestHeaders{ID: "ns1:tx2", Priority: 10})
+	assert.Equal(t, 10, mtx.Priority)
+
+	persisted, err := sth.toolkit.TXPersistence.GetTransactionByID(sth.ctx, "ns1:tx2")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, persisted.Priority)
+
+	assert.True(t, sth.updateInflightSet(sth.ctx))
+	assert.Equal(t, []string{"ns1:tx2"}, inflightIDs(sth))
+}
+
+func TestSignerSelectionListFailCancel(t *testing.T) {
+
+	sth, mp := newTestSignerSelectionHandler(t, 3, func(conf map[string]interface{}) {
+		conf[SignerSelection] = SignerSelectionRoundRobin
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	mp.On("ListTransactionsPending", ctx, "", 3, persistence.SortDirectionAscending).
+		Return(nil, fmt.Errorf("pop"))
+
+	assert.False(t, sth.updateInflightSet(ctx))
+
+	mp.AssertExpectations(t)
+}
+
+func TestSignerSelectionListSignerFailCancel(t *testing.T) {
+
+	sth, mp := newTestSignerSelectionHandler(t, 3, func(conf map[string]interface{}) {
+		conf[SignerSelection] = SignerSelectionRoundRobin
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a1 := newTestPendingTX("0xaaaa", 1)
+	mp.On("ListTransactionsPending", ctx, "", 3, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a1}, nil)
+	mp.On("ListTransactionsByNonce", ctx, "0xaaaa", nonceAfter(0), 3, persistence.SortDirectionAscending).
+		Return(nil, fmt.Errorf("pop"))
+
+	assert.False(t, sth.updateInflightSet(ctx))
+
+	mp.AssertExpectations(t)
+}
+
+func TestSignerSelectionInvalid(t *testing.T) {
+	f, _, _, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(SignerSelection, "wrong")
+	_, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.Regexp(t, "FF21123.*wrong", err)
+}
+
+func TestSignerSelectionDeprecatedConfigDefaults(t *testing.T) {
+	f, _, _, conf := newTestTransactionHandlerFactory(t)
+	viper.SetDefault(string(tmconfig.TransactionsHandlerName), "")
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	sth := th.(*simpleTransactionHandler)
+	assert.Equal(t, SignerSelectionSequence, sth.signerSelection)
+	assert.Zero(t, sth.maxInFlightPerSigner)
+	assert.False(t, sth.selectBySigner())
+}
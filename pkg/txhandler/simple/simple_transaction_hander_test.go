@@ -46,12 +46,13 @@ import (
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/spendpolicy"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
-func newTestTransactionHandlerFactory(t *testing.T) (*TransactionHandlerFactory, *txhandler.Toolkit, *ffcapimocks.API, config.Section) {
+func newTestTransactionHandlerFactory(t *testing.T) (*TransactionHandlerFactory, *txhandler.Toolkit, *ffcapimocks.ExtendedAPI, config.Section) {
 	tmconfig.Reset()
 	conf := config.RootSection("unittest.simple")
 	viper.SetDefault(string(tmconfig.TransactionsHandlerName), "simple")
@@ -63,7 +64,7 @@ func newTestTransactionHandlerFactory(t *testing.T) (*TransactionHandlerFactory,
 
 	mockPersistence := &persistencemocks.Persistence{}
 
-	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI := &ffcapimocks.ExtendedAPI{}
 
 	return f, &txhandler.Toolkit{
 		Connector:      mockFFCAPI,
@@ -82,7 +83,7 @@ func newTestRunContext(mtx *apitypes.ManagedTX, receipt *ffcapi.TransactionRecei
 	}
 }
 
-func newTestTransactionHandlerFactoryWithFilePersistence(t *testing.T) (*TransactionHandlerFactory, *txhandler.Toolkit, *ffcapimocks.API, config.Section, func()) {
+func newTestTransactionHandlerFactoryWithFilePersistence(t *testing.T) (*TransactionHandlerFactory, *txhandler.Toolkit, *ffcapimocks.ExtendedAPI, config.Section, func()) {
 	tmconfig.Reset()
 	conf := config.RootSection("unittest.simple")
 	viper.SetDefault(string(tmconfig.TransactionsHandlerName), "simple")
@@ -100,7 +101,7 @@ func newTestTransactionHandlerFactoryWithFilePersistence(t *testing.T) (*Transac
 
 	mockEventHandler := &txhandlermocks.ManagedTxEventHandler{}
 
-	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI := &ffcapimocks.ExtendedAPI{}
 
 	return f, &txhandler.Toolkit{
 			Connector:      mockFFCAPI,
@@ -160,6 +161,9 @@ func TestFixedGasPriceOK(t *testing.T) {
 		TransactionData: "SOME_RAW_TX_BYTES",
 	}
 
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: mtx.TransactionHash,
+	}, ffcapi.ErrorReason(""), nil)
 	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
 		return req.GasPrice.JSONObject().GetString("maxPriorityFee") == "32.146027800733336" &&
 			req.GasPrice.JSONObject().GetString("maxFee") == "32.14602781673334" &&
@@ -172,10 +176,13 @@ func TestFixedGasPriceOK(t *testing.T) {
 	mmm := &metricsmocks.TransactionHandlerMetrics{}
 	mmm.On("InitTxHandlerGaugeMetric", mock.Anything, metricsGaugeTransactionsInflightUsed, metricsGaugeTransactionsInflightUsedDescription, false).Return(fmt.Errorf("fail")).Once()
 	mmm.On("InitTxHandlerGaugeMetric", mock.Anything, metricsGaugeTransactionsInflightFree, metricsGaugeTransactionsInflightFreeDescription, false).Return(fmt.Errorf("fail")).Once()
+	mmm.On("InitTxHandlerGaugeMetricWithLabels", mock.Anything, metricsGaugeSignerQueueDepth, metricsGaugeSignerQueueDepthDescription, []string{metricsLabelNameSigner}, false).Return(fmt.Errorf("fail")).Once()
 	mmm.On("InitTxHandlerCounterMetricWithLabels", mock.Anything, metricsCounterTransactionProcessOperationsTotal, metricsCounterTransactionProcessOperationsTotalDescription, []string{metricsLabelNameOperation}, true).Return(fmt.Errorf("fail")).Once()
 	mmm.On("InitTxHandlerHistogramMetricWithLabels", mock.Anything, metricsHistogramTransactionProcessOperationsDuration, metricsHistogramTransactionProcessOperationsDurationDescription, []float64{}, []string{metricsLabelNameOperation}, true).Return(fmt.Errorf("fail")).Once()
+	mmm.On("InitTxHandlerHistogramMetricWithLabels", mock.Anything, metricsHistogramPolicyWorkerCycleDuration, metricsHistogramPolicyWorkerCycleDurationDescription, []float64{}, []string{metricsLabelNameWorker}, false).Return(fmt.Errorf("fail")).Once()
 	mmm.On("IncTxHandlerCounterMetricWithLabels", mock.Anything, metricsCounterTransactionProcessOperationsTotal, mock.Anything, mock.Anything, mock.Anything).Return().Maybe()
 	mmm.On("ObserveTxHandlerHistogramMetricWithLabels", mock.Anything, metricsHistogramTransactionProcessOperationsDuration, mock.Anything, mock.Anything, mock.Anything).Return().Maybe()
+	mmm.On("ObserveTxHandlerHistogramMetricWithLabels", mock.Anything, metricsHistogramPolicyWorkerCycleDuration, mock.Anything, mock.Anything, mock.Anything).Return().Maybe()
 
 	tk.MetricsManager = mmm
 
@@ -201,6 +208,22 @@ func TestFixedGasPriceOK(t *testing.T) {
 	mockFFCAPI.AssertExpectations(t)
 }
 
+func TestFixedGasPriceBypassesOracleCache(t *testing.T) {
+	f, _, _, conf := newTestTransactionHandlerFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeDisabled)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	// The fixed price is returned directly, rather than from the cache or a shared oracle query
+	sth := th.(*simpleTransactionHandler)
+	sth.gasOracleQueryValue = fftypes.JSONAnyPtr(`99999`)
+	sth.gasOracleLastQueryTime = fftypes.Now()
+	gasPrice, err := sth.getGasPrice(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `12345`, gasPrice.String())
+}
+
 func TestGasOracleSendOK(t *testing.T) {
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -243,6 +266,9 @@ func TestGasOracleSendOK(t *testing.T) {
 		TransactionData: "SOME_RAW_TX_BYTES",
 	}
 
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: mtx.TransactionHash,
+	}, ffcapi.ErrorReason(""), nil)
 	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
 		return req.GasPrice.JSONObject().GetInteger("maxPriorityFeePerGas").Cmp(big.NewInt(32146027800)) == 0 &&
 			req.GasPrice.JSONObject().GetInteger("maxFeePerGas").Cmp(big.NewInt(32247127816)) == 0 &&
@@ -314,6 +340,9 @@ func TestConnectorGasOracleSendOK(t *testing.T) {
 	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
 		GasPrice: fftypes.JSONAnyPtr(`"12345"`),
 	}, ffcapi.ErrorReason(""), nil).Once()
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: mtx.TransactionHash,
+	}, ffcapi.ErrorReason(""), nil)
 	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
 		return req.From == "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712" &&
 			req.TransactionData == "SOME_RAW_TX_BYTES"
@@ -395,6 +424,7 @@ func TestConnectorGasOracleFailStale(t *testing.T) {
 		LastSubmit:      (*fftypes.FFTime)(&longAgo),
 	}
 
+	mockFFCAPI.On("TransactionByHash", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNotSupported, fmt.Errorf("not supported"))
 	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
 		GasPrice: fftypes.JSONAnyPtr(`"12345"`),
 	}, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
@@ -590,6 +620,9 @@ func TestTXSendFail(t *testing.T) {
 		TransactionData: "SOME_RAW_TX_BYTES",
 	}
 
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: mtx.TransactionHash,
+	}, ffcapi.ErrorReason(""), nil)
 	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonInvalidInputs, fmt.Errorf("pop"))
 	ctx := context.Background()
 	th.Init(ctx, tk)
@@ -622,6 +655,9 @@ func TestWarnStaleWarningCannotParse(t *testing.T) {
 	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
 		GasPrice: fftypes.JSONAnyPtr(`"12345"`),
 	}, ffcapi.ErrorReason(""), nil).Once()
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: mtx.TransactionHash,
+	}, ffcapi.ErrorReason(""), nil)
 	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
 		Return(nil, ffcapi.ErrorKnownTransaction, fmt.Errorf("Known transaction"))
 
@@ -656,6 +692,9 @@ func TestKnownTransactionHashKnown(t *testing.T) {
 		TransactionHash: "0x01020304",
 	}
 
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: mtx.TransactionHash,
+	}, ffcapi.ErrorReason(""), nil)
 	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
 		Return(nil, ffcapi.ErrorKnownTransaction, fmt.Errorf("Known transaction"))
 
@@ -673,6 +712,234 @@ func TestKnownTransactionHashKnown(t *testing.T) {
 	mockFFCAPI.AssertExpectations(t)
 }
 
+func TestExpectedHashPersistedBeforeAmbiguousSend(t *testing.T) {
+	f, tk, mockFFCAPI, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := &apitypes.ManagedTX{
+		ID:              "ns1:tx1",
+		TransactionData: "SOME_RAW_TX_BYTES",
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+	}
+
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSignRequest) bool {
+		return req.TransactionData == "SOME_RAW_TX_BYTES" && req.GasPrice.String() == "12345"
+	})).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: "0x01020304",
+	}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("timeout")).Once()
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReasonNonceTooLow, fmt.Errorf("nonce too low")).Once()
+	mockFFCAPI.On("TransactionByHash", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionByHashRequest) bool {
+		return req.TransactionHash == "0x01020304"
+	})).Return(&ffcapi.TransactionByHashResponse{
+		State: ffcapi.TransactionStatePending,
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mp := tk.TXPersistence.(*persistencemocks.Persistence)
+	mp.On("UpdateTransaction", mock.Anything, "ns1:tx1", mock.MatchedBy(func(updates *apitypes.TXUpdates) bool {
+		var info simplePolicyInfo
+		_ = json.Unmarshal(updates.PolicyInfo.Bytes(), &info)
+		return updates.TransactionHash == nil && info.ExpectedHash == "0x01020304"
+	})).Return(nil).Once()
+
+	ctx := context.Background()
+	th.Init(ctx, tk)
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+
+	// The first submission times out, but we've already stored the hash we expect - without treating it as submitted
+	rc := newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.Regexp(t, "timeout", err)
+	assert.Empty(t, mtx.TransactionHash)
+	assert.Equal(t, "0x01020304", rc.Info.ExpectedHash)
+	assert.Nil(t, mtx.FirstSubmit)
+
+	// The second submission finds the nonce consumed, and the node knows the expected hash, so we reconcile to it
+	info := rc.Info
+	rc = newTestRunContext(mtx, nil)
+	rc.Info = info
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxSubStatusTracking, rc.SubStatus)
+	assert.NotNil(t, mtx.FirstSubmit)
+	assert.Equal(t, "0x01020304", mtx.TransactionHash)
+	assert.Equal(t, "0x01020304", *rc.TXUpdates.TransactionHash)
+	assert.Empty(t, rc.Info.ExpectedHash)
+	assert.True(t, rc.UpdatedInfo)
+
+	mockFFCAPI.AssertExpectations(t)
+	mp.AssertExpectations(t)
+}
+
+func TestExpectedHashUnknownNonceTooLow(t *testing.T) {
+	f, tk, mockFFCAPI, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := &apitypes.ManagedTX{
+		ID:              "ns1:tx1",
+		TransactionData: "SOME_RAW_TX_BYTES",
+	}
+
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: "0x01020304",
+	}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReasonNonceTooLow, fmt.Errorf("nonce too low")).Once()
+	mockFFCAPI.On("TransactionByHash", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found")).Once()
+
+	ctx := context.Background()
+	th.Init(ctx, tk)
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+
+	// The nonce was used by another transaction, so this must not be treated as submitted
+	rc := newTestRunContext(mtx, nil)
+	rc.Info.ExpectedHash = "0x01020304"
+	err = sth.processTransaction(rc)
+	assert.Regexp(t, "nonce too low", err)
+	assert.Empty(t, mtx.TransactionHash)
+	assert.Nil(t, mtx.FirstSubmit)
+	assert.NotEqual(t, apitypes.TxSubStatusTracking, rc.SubStatus)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestExpectedHashClearedOnSuccessfulSend(t *testing.T) {
+	f, tk, mockFFCAPI, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := &apitypes.ManagedTX{
+		ID:              "ns1:tx1",
+		TransactionData: "SOME_RAW_TX_BYTES",
+	}
+
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: "0x01020304",
+	}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x01020304",
+	}, ffcapi.ErrorReason(""), nil)
+
+	ctx := context.Background()
+	th.Init(ctx, tk)
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+
+	rc := newTestRunContext(mtx, nil)
+	rc.Info.ExpectedHash = "0x01020304"
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, "0x01020304", mtx.TransactionHash)
+	assert.Empty(t, rc.Info.ExpectedHash)
+	assert.True(t, rc.UpdatedInfo)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestExpectedHashPersistFail(t *testing.T) {
+	f, tk, mockFFCAPI, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := &apitypes.ManagedTX{
+		ID:              "ns1:tx1",
+		TransactionData: "SOME_RAW_TX_BYTES",
+	}
+
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: "0x01020304",
+	}, ffcapi.ErrorReason(""), nil)
+	mp := tk.TXPersistence.(*persistencemocks.Persistence)
+	mp.On("UpdateTransaction", mock.Anything, "ns1:tx1", mock.Anything).Return(fmt.Errorf("pop"))
+
+	ctx := context.Background()
+	th.Init(ctx, tk)
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+
+	rc := newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.Regexp(t, "pop", err)
+	assert.Empty(t, rc.Info.ExpectedHash)
+
+	mockFFCAPI.AssertExpectations(t)
+	mp.AssertExpectations(t)
+}
+
+func TestExpectedHashSignFailContinuesToSend(t *testing.T) {
+	f, tk, mockFFCAPI, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := &apitypes.ManagedTX{
+		ID:              "ns1:tx1",
+		TransactionData: "SOME_RAW_TX_BYTES",
+	}
+
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("not supported"))
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x01020304",
+	}, ffcapi.ErrorReason(""), nil)
+
+	ctx := context.Background()
+	th.Init(ctx, tk)
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+
+	rc := newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, "0x01020304", mtx.TransactionHash)
+	assert.Equal(t, apitypes.TxSubStatusTracking, rc.SubStatus)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestExpectedHashSkippedWithoutSigner(t *testing.T) {
+	f, tk, _, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := &apitypes.ManagedTX{
+		ID:              "ns1:tx1",
+		TransactionData: "SOME_RAW_TX_BYTES",
+	}
+
+	// A connector that does not implement the optional TransactionSigner interface
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x01020304",
+	}, ffcapi.ErrorReason(""), nil)
+	tk.Connector = mockFFCAPI
+
+	ctx := context.Background()
+	th.Init(ctx, tk)
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+
+	rc := newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, "0x01020304", mtx.TransactionHash)
+	assert.Equal(t, apitypes.TxSubStatusTracking, rc.SubStatus)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
 func TestWarnStaleAdditionalWarningResubmitFail(t *testing.T) {
 	f, tk, mockFFCAPI, conf := newTestTransactionHandlerFactory(t)
 	conf.Set(FixedGasPrice, `12345`)
@@ -694,6 +961,9 @@ func TestWarnStaleAdditionalWarningResubmitFail(t *testing.T) {
 	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
 		GasPrice: fftypes.JSONAnyPtr(`"12345"`),
 	}, ffcapi.ErrorReason(""), nil).Once()
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: mtx.TransactionHash,
+	}, ffcapi.ErrorReason(""), nil)
 	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
 		Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
 
@@ -846,9 +1116,35 @@ func TestSendTXPersistFail(t *testing.T) {
 	err = json.Unmarshal([]byte(sampleSendTX), &txReq)
 	assert.NoError(t, err)
 
-	_, err = sth.createManagedTx(sth.ctx, "id1", &txReq.TransactionHeaders, fftypes.NewFFBigInt(12345), "0x123456")
+	_, err = sth.createManagedTx(sth.ctx, "id1", &apitypes.RequestHeaders{}, &txReq.TransactionHeaders, fftypes.NewFFBigInt(12345), "0x123456")
+	assert.Regexp(t, "pop", err)
+
+}
+
+func TestSendTXSpendPolicy(t *testing.T) {
+
+	f, tk, _, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+	tk.SpendPolicy = spendpolicy.NewSpendPolicy(&spendpolicy.Options{
+		WindowMaxGasLimit: big.NewInt(20000),
+	})
+
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+	mp := tk.TXPersistence.(*persistencemocks.Persistence)
+	mp.On("InsertTransactionWithNextNonce", sth.ctx, mock.Anything, mock.Anything).Return(fmt.Errorf("pop")).Once()
+	sth.Init(sth.ctx, tk)
+
+	// The spend of a transaction that is not persisted is released
+	_, err = sth.createManagedTx(sth.ctx, "id1", &apitypes.RequestHeaders{}, &ffcapi.TransactionHeaders{From: "0xaaaaa"}, fftypes.NewFFBigInt(12345), "0x123456")
 	assert.Regexp(t, "pop", err)
 
+	_, err = sth.createManagedTx(sth.ctx, "id2", &apitypes.RequestHeaders{}, &ffcapi.TransactionHeaders{From: "0xaaaaa"}, fftypes.NewFFBigInt(20001), "0x123456")
+	assert.Regexp(t, "FF21147", err)
+
+	mp.AssertExpectations(t)
 }
 
 func TestSendGetNextNonceFail(t *testing.T) {
@@ -882,7 +1178,7 @@ func TestSendGetNextNonceFail(t *testing.T) {
 	err = json.Unmarshal([]byte(sampleSendTX), &txReq)
 	assert.NoError(t, err)
 
-	_, err = sth.createManagedTx(sth.ctx, "id1", &txReq.TransactionHeaders, fftypes.NewFFBigInt(12345), "0x123456")
+	_, err = sth.createManagedTx(sth.ctx, "id1", &apitypes.RequestHeaders{}, &txReq.TransactionHeaders, fftypes.NewFFBigInt(12345), "0x123456")
 	assert.Regexp(t, "pop", err)
 
 }
@@ -969,3 +1265,755 @@ func TestIdempotencyIDPreCheckDuplicateDeploy(t *testing.T) {
 	})
 	assert.Regexp(t, "FF21065", err)
 }
+
+func timeFromNow(d time.Duration) *fftypes.FFTime {
+	t := fftypes.FFTime(time.Now().Add(d))
+	return &t
+}
+
+func TestSubmitBackoffOnRateLimitedAndNonceTooHigh(t *testing.T) {
+	f, tk, mockFFCAPI, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(SubmitBackoffInitDelay, "1s")
+	conf.Set(SubmitBackoffMaxDelay, "3s")
+	conf.Set(SubmitBackoffFactor, 2.0)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := &apitypes.ManagedTX{
+		ID:              "ns1:tx1",
+		TransactionData: "SOME_RAW_TX_BYTES",
+	}
+
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReasonRateLimited, fmt.Errorf("too many requests")).Once()
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReasonNonceTooHigh, fmt.Errorf("nonce too high")).Twice()
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x01020304",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	ctx := context.Background()
+	th.Init(ctx, tk)
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+
+	rc := newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.Regexp(t, "too many requests", err)
+	assert.Equal(t, Update, rc.UpdateType)
+	assert.True(t, rc.UpdatedInfo)
+	assert.Equal(t, 1, rc.Info.SubmitBackoffCount)
+	backoff := time.Until(*rc.Info.SubmitBackoffUntil.Time())
+	assert.True(t, backoff > 0 && backoff <= 1*time.Second)
+	assert.Len(t, rc.HistoryUpdates, 4) // gas price, sign, submit, backoff
+
+	// While backing off we do not submit
+	rc = newTestRunContext(mtx, nil)
+	rc.Info.SubmitBackoffCount = 1
+	rc.Info.SubmitBackoffUntil = timeFromNow(1 * time.Hour)
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, None, rc.UpdateType)
+
+	// Each subsequent rejection increases the delay, up to the maximum
+	rc.Info.SubmitBackoffUntil = timeFromNow(-1 * time.Second)
+	err = sth.processTransaction(rc)
+	assert.Regexp(t, "nonce too high", err)
+	assert.Equal(t, 2, rc.Info.SubmitBackoffCount)
+	backoff = time.Until(*rc.Info.SubmitBackoffUntil.Time())
+	assert.True(t, backoff > 1*time.Second && backoff <= 2*time.Second)
+
+	rc.Info.SubmitBackoffCount = 5
+	rc.Info.SubmitBackoffUntil = timeFromNow(-1 * time.Second)
+	err = sth.processTransaction(rc)
+	assert.Regexp(t, "nonce too high", err)
+	assert.Equal(t, 6, rc.Info.SubmitBackoffCount)
+	backoff = time.Until(*rc.Info.SubmitBackoffUntil.Time())
+	assert.True(t, backoff > 2*time.Second && backoff <= 3*time.Second)
+
+	// Once accepted, the backoff is cleared
+	rc.Info.SubmitBackoffUntil = timeFromNow(-1 * time.Second)
+	rc.UpdatedInfo = false
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.True(t, rc.UpdatedInfo)
+	assert.Nil(t, rc.Info.SubmitBackoffUntil)
+	assert.Zero(t, rc.Info.SubmitBackoffCount)
+	assert.Equal(t, "0x01020304", mtx.TransactionHash)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestResubmitBackoffRetriesBeforeResubmitInterval(t *testing.T) {
+	f, tk, mockFFCAPI, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	submitTime := fftypes.Now()
+	mtx := &apitypes.ManagedTX{
+		ID:              "ns1:tx1",
+		TransactionData: "SOME_RAW_TX_BYTES",
+		TransactionHash: "0x01020304",
+		FirstSubmit:     submitTime,
+		LastSubmit:      submitTime,
+	}
+
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: "0x01020304",
+	}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReasonRateLimited, fmt.Errorf("too many requests")).Once()
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorKnownTransaction, fmt.Errorf("known transaction")).Once()
+
+	ctx := context.Background()
+	th.Init(ctx, tk)
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+
+	rc := newTestRunContext(mtx, nil)
+	rc.Info.SubmitBackoffCount = 1
+	rc.Info.SubmitBackoffUntil = timeFromNow(-1 * time.Second)
+	err = sth.processTransaction(rc)
+	assert.Regexp(t, "too many requests", err)
+	assert.Equal(t, 2, rc.Info.SubmitBackoffCount)
+
+	rc.Info.SubmitBackoffUntil = timeFromNow(-1 * time.Second)
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Nil(t, rc.Info.SubmitBackoffUntil)
+	assert.Nil(t, rc.Info.LastWarnTime)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestResubmitReplacementUnderpricedBumpsGasPrice(t *testing.T) {
+	f, tk, mockFFCAPI, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `{"maxFeePerGas":"0x64","maxPriorityFeePerGas":10}`)
+	conf.Set(ReplacementGasPriceBump, 20)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx := &apitypes.ManagedTX{
+		ID:              "ns1:tx1",
+		TransactionData: "SOME_RAW_TX_BYTES",
+		TransactionHash: "0x01020304",
+		FirstSubmit:     &submitTime,
+		LastSubmit:      &submitTime,
+	}
+
+	mockFFCAPI.On("TransactionByHash", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found")).Once()
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: "0x01020304",
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReasonReplacementUnderpriced, fmt.Errorf("replacement transaction underpriced")).Once()
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: "0x05060708",
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.JSONObject().GetString("maxFeePerGas") == "0x78" &&
+			req.GasPrice.JSONObject().GetInteger("maxPriorityFeePerGas").Int64() == 12
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x05060708",
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mp := tk.TXPersistence.(*persistencemocks.Persistence)
+	mp.On("UpdateTransaction", mock.Anything, "ns1:tx1", mock.Anything).Return(nil).Once()
+
+	ctx := context.Background()
+	th.Init(ctx, tk)
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+
+	// The stale resubmission is rejected, so we record a bumped price
+	rc := newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.Regexp(t, "replacement transaction underpriced", err)
+	assert.Equal(t, apitypes.TxSubStatusDropped, rc.SubStatus)
+	assert.True(t, rc.UpdatedInfo)
+	assert.JSONEq(t, `{"maxFeePerGas":"0x78","maxPriorityFeePerGas":12}`, rc.Info.ReplacementGasPrice.String())
+	assert.Len(t, rc.HistoryUpdates, 6) // timeout, lookup, gas price, sign, submit, bump
+
+	// The next cycle resubmits straight away at the bumped price
+	rc = &RunContext{Context: ctx, TX: mtx, Info: rc.Info}
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Nil(t, rc.Info.ReplacementGasPrice)
+	assert.Equal(t, "0x05060708", mtx.TransactionHash)
+	assert.JSONEq(t, `{"maxFeePerGas":"0x78","maxPriorityFeePerGas":12}`, rc.TXUpdates.GasPrice.String())
+
+	mockFFCAPI.AssertExpectations(t)
+	mp.AssertExpectations(t)
+}
+
+func TestSubmitChainIDMismatchFailsTransaction(t *testing.T) {
+	f, tk, mockFFCAPI, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := &apitypes.ManagedTX{
+		ID:              "ns1:tx1",
+		Status:          apitypes.TxStatusPending,
+		TransactionData: "SOME_RAW_TX_BYTES",
+	}
+
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReasonChainIDMismatch, fmt.Errorf("invalid chain id")).Once()
+
+	ctx := context.Background()
+	th.Init(ctx, tk)
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+
+	rc := newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.Regexp(t, "invalid chain id", err)
+	assert.Equal(t, apitypes.TxStatusFailed, mtx.Status)
+	assert.Equal(t, apitypes.TxStatusFailed, *rc.TXUpdates.Status)
+	assert.Equal(t, apitypes.TxSubStatusFailed, rc.SubStatus)
+	assert.Equal(t, Update, rc.UpdateType)
+	assert.Len(t, rc.HistoryUpdates, 4) // gas price, sign, submit, fail
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestStaleTransactionLookup(t *testing.T) {
+	f, tk, mockFFCAPI, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(MaxDroppedResubmits, 1)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx := &apitypes.ManagedTX{
+		ID:              "ns1:tx1",
+		Status:          apitypes.TxStatusPending,
+		TransactionData: "SOME_RAW_TX_BYTES",
+		TransactionHash: "0x01020304",
+		FirstSubmit:     &submitTime,
+		LastSubmit:      &submitTime,
+	}
+
+	ctx := context.Background()
+	th.Init(ctx, tk)
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+
+	// Still pending on the node, so no resubmission
+	mockFFCAPI.On("TransactionByHash", mock.Anything, &ffcapi.TransactionByHashRequest{TransactionHash: "0x01020304"}).Return(&ffcapi.TransactionByHashResponse{
+		TransactionHash: "0x01020304",
+		State:           ffcapi.TransactionStatePending,
+	}, ffcapi.ErrorReason(""), nil).Once()
+	rc := newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxSubStatusStale, rc.SubStatus)
+	assert.Len(t, rc.HistoryUpdates, 2) // timeout, lookup
+
+	// Lookup fails, so we fall back to resubmitting
+	mockFFCAPI.On("TransactionByHash", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{
+		TransactionHash: "0x01020304",
+	}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorKnownTransaction, fmt.Errorf("known transaction")).Once()
+	rc = newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Len(t, rc.HistoryUpdates, 5) // timeout, lookup, gas price, sign, submit
+	assert.Zero(t, rc.Info.DroppedCount)
+
+	// Dropped, so we resubmit
+	mockFFCAPI.On("TransactionByHash", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found")).Once()
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x01020304",
+	}, ffcapi.ErrorReason(""), nil).Once()
+	rc = newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rc.Info.DroppedCount)
+	assert.True(t, rc.UpdatedInfo)
+	assert.Equal(t, apitypes.TxSubStatusTracking, rc.SubStatus)
+
+	// Dropped again, and we've reached the limit, so the transaction is lost
+	mockFFCAPI.On("TransactionByHash", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found")).Once()
+	rc = newTestRunContext(mtx, nil)
+	rc.Info.DroppedCount = 1
+	err = sth.processTransaction(rc)
+	assert.Regexp(t, "FF21122", err)
+	assert.Equal(t, apitypes.TxStatusFailed, mtx.Status)
+	assert.Equal(t, apitypes.TxSubStatusFailed, rc.SubStatus)
+	assert.Len(t, rc.HistoryUpdates, 3) // timeout, lookup, fail
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestStaleTransactionLookupNotImplemented(t *testing.T) {
+	f, tk, _, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx := &apitypes.ManagedTX{
+		ID:              "ns1:tx1",
+		Status:          apitypes.TxStatusPending,
+		TransactionData: "SOME_RAW_TX_BYTES",
+		TransactionHash: "0x01020304",
+		FirstSubmit:     &submitTime,
+		LastSubmit:      &submitTime,
+	}
+
+	// A connector that cannot look up transactions, so we resubmit without a lookup
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorKnownTransaction, fmt.Errorf("known transaction")).Once()
+	tk.Connector = mockFFCAPI
+
+	ctx := context.Background()
+	th.Init(ctx, tk)
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+
+	rc := newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Len(t, rc.HistoryUpdates, 3) // timeout, gas price, submit
+
+	// Nor can we reconcile an expected hash when the nonce has been used
+	mtx.TransactionHash = ""
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReasonNonceTooLow, fmt.Errorf("nonce too low")).Once()
+	rc = newTestRunContext(mtx, nil)
+	rc.Info.ExpectedHash = "0x01020304"
+	reason, err := sth.submitTX(rc)
+	assert.Regexp(t, "nonce too low", err)
+	assert.Equal(t, ffcapi.ErrorReasonNonceTooLow, reason)
+	assert.Empty(t, mtx.TransactionHash)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestStaleResubmitEscalatesGasPrice(t *testing.T) {
+	f, tk, mockFFCAPI, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `{"maxFeePerGas":100,"maxPriorityFeePerGas":"0x0a"}`)
+	conf.Set(GasEscalationPercentage, 5)
+	conf.Set(GasEscalationMinimumPercentage, 10)
+	conf.Set(GasEscalationMaxGasPrice, `{"maxFeePerGas":120}`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx := &apitypes.ManagedTX{
+		ID:              "ns1:tx1",
+		TransactionData: "SOME_RAW_TX_BYTES",
+		TransactionHash: "0x01020304",
+		GasPrice:        fftypes.JSONAnyPtr(`{"maxFeePerGas":100,"maxPriorityFeePerGas":"0x0a"}`),
+		FirstSubmit:     &submitTime,
+		LastSubmit:      &submitTime,
+	}
+
+	mockFFCAPI.On("TransactionByHash", mock.Anything, mock.Anything).Return(&ffcapi.TransactionByHashResponse{
+		TransactionHash: "0x01020304",
+		State:           ffcapi.TransactionStatePending,
+	}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.JSONObject().GetInteger("maxFeePerGas").Int64() == 110 &&
+			req.GasPrice.JSONObject().GetString("maxPriorityFeePerGas") == "0xb"
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x05060708",
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.JSONObject().GetInteger("maxFeePerGas").Int64() == 120 &&
+			req.GasPrice.JSONObject().GetString("maxPriorityFeePerGas") == "0xd"
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x090a0b0c",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	ctx := context.Background()
+	th.Init(ctx, tk)
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+
+	// The first escalation uses the minimum percentage, as the configured percentage is lower
+	rc := newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rc.Info.GasEscalations)
+	assert.True(t, rc.UpdatedInfo)
+	assert.Equal(t, "0x05060708", mtx.TransactionHash)
+	assert.Len(t, rc.HistoryUpdates, 6) // timeout, lookup, escalate, gas price, sign, submit
+
+	// The second escalation is capped on maxFeePerGas
+	mtx.LastSubmit = &submitTime
+	rc = newTestRunContext(mtx, nil)
+	rc.Info.GasEscalations = 1
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, rc.Info.GasEscalations)
+	assert.JSONEq(t, `{"maxFeePerGas":120,"maxPriorityFeePerGas":"0xd"}`, rc.Info.EscalatedGasPrice.String())
+	assert.Equal(t, "0x090a0b0c", mtx.TransactionHash)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestStaleResubmitNoEscalationWithoutGasPrice(t *testing.T) {
+	f, tk, mockFFCAPI, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(GasEscalationPercentage, 20)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx := &apitypes.ManagedTX{
+		ID:              "ns1:tx1",
+		TransactionData: "SOME_RAW_TX_BYTES",
+		TransactionHash: "0x01020304",
+		FirstSubmit:     &submitTime,
+		LastSubmit:      &submitTime,
+	}
+
+	mockFFCAPI.On("TransactionByHash", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNotSupported, fmt.Errorf("not supported"))
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "12345"
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x01020304",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	ctx := context.Background()
+	th.Init(ctx, tk)
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+
+	rc := newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Zero(t, rc.Info.GasEscalations)
+	assert.Nil(t, rc.Info.EscalatedGasPrice)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestStaleByBlocks(t *testing.T) {
+	f, tk, mockFFCAPI, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(StaleBlocks, 3)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := &apitypes.ManagedTX{
+		ID:              "ns1:tx1",
+		TransactionData: "SOME_RAW_TX_BYTES",
+	}
+
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x01020304",
+	}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("TransactionByHash", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found")).Once()
+
+	ctx := context.Background()
+	th.Init(ctx, tk)
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+
+	// The block is recorded on first submission
+	rc := newTestRunContext(mtx, nil)
+	rc.HighestBlock = 100
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), rc.Info.LastWarnBlock)
+	assert.True(t, rc.UpdatedInfo)
+
+	// Not stale until enough blocks have passed, even though the resubmit interval has not
+	info := rc.Info
+	rc = newTestRunContext(mtx, nil)
+	rc.Info = info
+	rc.HighestBlock = 102
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.False(t, rc.UpdatedInfo)
+
+	rc = newTestRunContext(mtx, nil)
+	rc.Info = info
+	rc.HighestBlock = 103
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(103), rc.Info.LastWarnBlock)
+	assert.Equal(t, 1, rc.Info.DroppedCount)
+	assert.Equal(t, apitypes.TxSubStatusTracking, rc.SubStatus)
+
+	// A transaction submitted before any blocks were notified counts from the first block it sees
+	rc = newTestRunContext(mtx, nil)
+	rc.HighestBlock = 200
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(200), rc.Info.LastWarnBlock)
+	assert.True(t, rc.UpdatedInfo)
+
+	// No blocks notified
+	rc = newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.False(t, rc.UpdatedInfo)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestHandleNewBlock(t *testing.T) {
+	f, tk, _, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+	th.Init(context.Background(), tk)
+	sth := th.(*simpleTransactionHandler)
+
+	// Without staleBlocks, the policy loop is not woken up
+	sth.HandleNewBlock(context.Background(), 10)
+	assert.Equal(t, uint64(10), sth.highestBlock)
+	assert.Len(t, sth.inflightUpdate, 0)
+
+	sth.staleBlocks = 5
+	sth.HandleNewBlock(context.Background(), 9)
+	assert.Equal(t, uint64(10), sth.highestBlock)
+	assert.Len(t, sth.inflightUpdate, 1)
+
+	rc, err := sth.pendingToRunContext(context.Background(), &pendingState{mtx: &apitypes.ManagedTX{ID: "ns1:tx1"}, info: &simplePolicyInfo{}}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), rc.HighestBlock)
+}
+
+type testGasPricer struct {
+	gasPrice  *fftypes.JSONAny
+	escalated *fftypes.JSONAny
+	err       error
+}
+
+func (gp *testGasPricer) GasPrice(ctx context.Context) (*fftypes.JSONAny, error) {
+	return gp.gasPrice, gp.err
+}
+
+func (gp *testGasPricer) EscalateGasPrice(ctx context.Context, previous *fftypes.JSONAny) *fftypes.JSONAny {
+	return gp.escalated
+}
+
+func TestStaleResubmitWithGasPricer(t *testing.T) {
+	_, tk, mockFFCAPI, conf := newTestTransactionHandlerFactory(t)
+	// No fixed gas price or gas oracle is needed with a gas pricer
+	gp := &testGasPricer{
+		gasPrice:  fftypes.JSONAnyPtr(`{"maxFeePerGas":100,"maxPriorityFeePerGas":10}`),
+		escalated: fftypes.JSONAnyPtr(`{"maxFeePerGas":90,"maxPriorityFeePerGas":12}`),
+	}
+	th, err := NewTransactionHandlerWithGasPricer(context.Background(), conf, gp)
+	assert.NoError(t, err)
+
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx := &apitypes.ManagedTX{
+		ID:              "ns1:tx1",
+		TransactionData: "SOME_RAW_TX_BYTES",
+		TransactionHash: "0x01020304",
+		GasPrice:        fftypes.JSONAnyPtr(`{"maxFeePerGas":80,"maxPriorityFeePerGas":10}`),
+		FirstSubmit:     &submitTime,
+		LastSubmit:      &submitTime,
+	}
+
+	// Pending transactions are resubmitted, as the gas pricer always escalates
+	mockFFCAPI.On("TransactionByHash", mock.Anything, mock.Anything).Return(&ffcapi.TransactionByHashResponse{
+		TransactionHash: "0x01020304",
+		State:           ffcapi.TransactionStatePending,
+	}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.JSONObject().GetInteger("maxFeePerGas").Int64() == 100 &&
+			req.GasPrice.JSONObject().GetInteger("maxPriorityFeePerGas").Int64() == 12
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x05060708",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	ctx := context.Background()
+	th.Init(ctx, tk)
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+
+	rc := newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rc.Info.GasEscalations)
+	assert.Equal(t, "0x05060708", mtx.TransactionHash)
+
+	// Without an escalation from the gas pricer, the current gas price is used
+	gp.escalated = nil
+	gp.err = fmt.Errorf("pop")
+	mtx.LastSubmit = &submitTime
+	rc = newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.Regexp(t, "pop", err)
+	assert.Zero(t, rc.Info.GasEscalations)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestExpiredTransactionCancelled(t *testing.T) {
+	f, tk, mockFFCAPI, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `100`)
+	conf.Set(ReplacementGasPriceBump, 20)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	expiry := fftypes.FFTime(time.Now().Add(-1 * time.Hour))
+	mtx := &apitypes.ManagedTX{
+		ID:     "ns1:tx1",
+		Status: apitypes.TxStatusPending,
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From:  "0xaaaa",
+			Nonce: fftypes.NewFFBigInt(12),
+			Gas:   fftypes.NewFFBigInt(50000),
+		},
+		GasPrice:        fftypes.JSONAnyPtr(`100`),
+		TransactionData: "SOME_RAW_TX_BYTES",
+		TransactionHash: "0x01020304",
+		FirstSubmit:     &submitTime,
+		LastSubmit:      &submitTime,
+		Expiry:          &expiry,
+	}
+
+	mockFFCAPI.On("TransactionCancel", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionCancelRequest) bool {
+		return req.From == "0xaaaa" &&
+			req.Nonce.Int64() == 12 &&
+			req.Gas.Int64() == 50000 &&
+			req.GasPrice.String() == "120"
+	})).Return(&ffcapi.TransactionCancelResponse{
+		TransactionHash: "0x05060708",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	ctx := context.Background()
+	th.Init(ctx, tk)
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+
+	// We wait for a receipt for the submission or the cancellation, whichever is mined
+	rc := newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusPending, mtx.Status)
+	assert.Equal(t, "0x01020304", mtx.TransactionHash)
+	assert.Equal(t, "0x05060708", rc.Info.CancelHash)
+	assert.Regexp(t, "FF21125", rc.Info.CancelError)
+	assert.True(t, rc.UpdatedInfo)
+	assert.Len(t, rc.HistoryUpdates, 1) // cancel
+
+	// Not cancelled again on the next cycle
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestExpiredTransactionCancelOutcomes(t *testing.T) {
+	f, tk, mockFFCAPI, conf := newTestTransactionHandlerFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeConnector)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	expiry := fftypes.FFTime(time.Now().Add(-1 * time.Hour))
+	newExpiredTX := func(txHash string) *apitypes.ManagedTX {
+		return &apitypes.ManagedTX{
+			ID:     "ns1:tx1",
+			Status: apitypes.TxStatusPending,
+			TransactionHeaders: ffcapi.TransactionHeaders{
+				From:  "0xaaaa",
+				Nonce: fftypes.NewFFBigInt(12),
+			},
+			TransactionData: "SOME_RAW_TX_BYTES",
+			TransactionHash: txHash,
+			Expiry:          &expiry,
+		}
+	}
+
+	ctx := context.Background()
+	th.Init(ctx, tk)
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+
+	// Gas price lookup fails, so we retry on the next cycle
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	mtx := newExpiredTX("")
+	rc := newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.Regexp(t, "pop", err)
+	assert.Equal(t, apitypes.TxStatusPending, mtx.Status)
+
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`"12345"`),
+	}, ffcapi.ErrorReason(""), nil)
+
+	// Cancellation fails, so we retry on the next cycle
+	mockFFCAPI.On("TransactionCancel", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	rc = newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.Regexp(t, "pop", err)
+	assert.Equal(t, apitypes.TxStatusPending, mtx.Status)
+	assert.Len(t, rc.HistoryUpdates, 1) // cancel
+
+	// The nonce has been used, and we have a hash, so we wait for the receipt
+	mockFFCAPI.On("TransactionCancel", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNonceTooLow, fmt.Errorf("nonce too low")).Once()
+	mtx = newExpiredTX("0x01020304")
+	mtx.FirstSubmit = fftypes.Now()
+	rc = newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusPending, mtx.Status)
+
+	// No further action once we have the receipt
+	rc = newTestRunContext(mtx, &ffcapi.TransactionReceiptResponse{})
+	err = sth.processTransaction(rc)
+	assert.NoError(t, err)
+	assert.Empty(t, rc.HistoryUpdates)
+
+	// The nonce has been used by something other than this transaction
+	mockFFCAPI.On("TransactionCancel", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNonceTooLow, fmt.Errorf("nonce too low")).Once()
+	mtx = newExpiredTX("")
+	rc = newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.Regexp(t, "FF21125", err)
+	assert.Equal(t, apitypes.TxStatusFailed, mtx.Status)
+
+	// The connector cannot cancel, so we fail without filling the nonce
+	mockFFCAPI.On("TransactionCancel", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNotSupported, fmt.Errorf("not supported")).Once()
+	mtx = newExpiredTX("0x01020304")
+	rc = newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.Regexp(t, "FF21125", err)
+	assert.Equal(t, apitypes.TxStatusFailed, mtx.Status)
+	assert.Len(t, rc.HistoryUpdates, 2) // cancel, fail
+
+	// An expected hash is not a submission of ours, so we do not wait for a receipt for it
+	mockFFCAPI.On("TransactionCancel", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorKnownTransaction, fmt.Errorf("known transaction")).Once()
+	mtx = newExpiredTX("")
+	rc = newTestRunContext(mtx, nil)
+	rc.Info.ExpectedHash = "0x01020304"
+	err = sth.processTransaction(rc)
+	assert.Regexp(t, "FF21125", err)
+	assert.Equal(t, apitypes.TxStatusFailed, mtx.Status)
+
+	mockFFCAPI.AssertExpectations(t)
+
+	// The connector does not implement cancellation, so we fail without filling the nonce
+	strictFFCAPI := &ffcapimocks.API{}
+	tk.Connector = strictFFCAPI
+	mtx = newExpiredTX("")
+	rc = newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.Regexp(t, "FF21125", err)
+	assert.Equal(t, apitypes.TxStatusFailed, mtx.Status)
+	strictFFCAPI.AssertExpectations(t)
+}
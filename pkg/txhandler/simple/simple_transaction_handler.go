@@ -17,17 +17,14 @@
 package simple
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"html/template"
+	"fmt"
+	"math/big"
 	"sync"
 	"time"
 
-	"github.com/Masterminds/sprig/v3"
-	"github.com/go-resty/resty/v2"
 	"github.com/hyperledger/firefly-common/pkg/config"
-	"github.com/hyperledger/firefly-common/pkg/ffresty"
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/i18n"
 	"github.com/hyperledger/firefly-common/pkg/log"
@@ -37,6 +34,7 @@ import (
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler"
+	"golang.org/x/sync/singleflight"
 )
 
 const metricsCounterTransactionProcessOperationsTotal = "tx_process_operation_total"
@@ -67,6 +65,12 @@ type RunContext struct {
 	Confirmations *apitypes.ConfirmationsNotification
 	Confirmed     bool
 	SyncAction    policyEngineAPIRequestType
+	NotBefore     *fftypes.FFTime                    // not submitted before this time - the later of the notBefore of this transaction, and of any earlier scheduled transaction from the same signer
+	Dependency    *dependencyCheck                   // set if this transaction cannot be submitted yet because of a dependency, of this transaction or of an earlier transaction from the same signer
+	FundedBalance *big.Int                           // set if this transaction is suspended for insufficient funds, and the balance of the signer now covers its cost
+	UnderfundedBy string                             // set if an earlier transaction from the same signer is suspended for insufficient funds
+	HighestBlock  uint64                             // the highest block notified to the handler, or 0 if none has been
+	Update        *apitypes.TransactionUpdateRequest // set for an ActionUpdate request
 	// Input/output
 	SubStatus apitypes.TxSubStatus
 	Info      *simplePolicyInfo // must be updated in-place and set UpdatedInfo to true as well as UpdateType = Update
@@ -98,17 +102,27 @@ func (f *TransactionHandlerFactory) Name() string {
 // - It offers three ways of calculating gas price: use a fixed number, use the built-in API of a ethereum connector, use a RESTful gas oracle
 // - It resubmits the transaction based on a configured interval until it succeed or fail
 func (f *TransactionHandlerFactory) NewTransactionHandler(ctx context.Context, conf config.Section) (txhandler.TransactionHandler, error) {
+	return newSimpleTransactionHandler(ctx, conf, nil)
+}
+
+// NewTransactionHandlerWithGasPricer creates a simple transaction handler that uses the supplied gas pricer,
+// in place of the fixed gas price or gas oracle in its configuration
+func NewTransactionHandlerWithGasPricer(ctx context.Context, conf config.Section, gasPricer GasPricer) (txhandler.ExtendedTransactionHandler, error) {
+	return newSimpleTransactionHandler(ctx, conf, gasPricer)
+}
+
+func newSimpleTransactionHandler(ctx context.Context, conf config.Section, gasPricer GasPricer) (txhandler.ExtendedTransactionHandler, error) {
 	gasOracleConfig := conf.SubSection(GasOracleConfig)
 	sth := &simpleTransactionHandler{
 		resubmitInterval: conf.GetDuration(ResubmitInterval),
 		fixedGasPrice:    fftypes.JSONAnyPtr(conf.GetString(FixedGasPrice)),
 
-		gasOracleMethod:        gasOracleConfig.GetString(GasOracleMethod),
 		gasOracleQueryInterval: gasOracleConfig.GetDuration(GasOracleQueryInterval),
 		gasOracleMode:          gasOracleConfig.GetString(GasOracleMode),
 
 		inflightStale:  make(chan bool, 1),
 		inflightUpdate: make(chan bool, 1),
+		pendingSigners: make(map[string]map[string]*fftypes.FFBigInt),
 	}
 
 	// check whether we are using deprecated configuration
@@ -121,6 +135,26 @@ func (f *TransactionHandlerFactory) NewTransactionHandler(ctx context.Context, c
 			MaximumDelay: config.GetDuration(tmconfig.DeprecatedPolicyLoopRetryMaxDelay),
 			Factor:       config.GetFloat64(tmconfig.DeprecatedPolicyLoopRetryFactor),
 		}
+		// there is no deprecated configuration for the submission backoff and replacement gas price
+		sth.submitBackoff = &retry.Retry{
+			InitialDelay: defaultSubmitBackoffInitDelay,
+			MaximumDelay: defaultSubmitBackoffMaxDelay,
+			Factor:       defaultSubmitBackoffFactor,
+		}
+		sth.replacementGasPriceBump = defaultReplacementGasPriceBump
+		sth.maxDroppedResubmits = defaultMaxDroppedResubmits
+		sth.gasEscalationPercentage = defaultGasEscalationPercentage
+		sth.gasEscalationMinimumPercentage = defaultGasEscalationMinimumPercentage
+		sth.maxInFlightPerSigner = defaultMaxInFlightPerSigner
+		sth.signerSelection = defaultSignerSelection
+		sth.priorityEnabled = defaultPriorityEnabled
+		sth.dependencyFailure = defaultDependencyFailure
+		sth.simulate = defaultSimulate
+		sth.batchConcurrency = defaultBatchConcurrency
+		sth.policyWorkers = defaultPolicyWorkers
+		sth.fundsCheckInterval = defaultFundsCheckInterval
+		sth.fundsSuspendLater = defaultFundsSuspendLater
+		sth.staleBlocks = defaultStaleBlocks
 	} else {
 		// if not, use the new transaction handler configurations
 		sth.maxInFlight = conf.GetInt(MaxInFlight)
@@ -130,26 +164,72 @@ func (f *TransactionHandlerFactory) NewTransactionHandler(ctx context.Context, c
 			MaximumDelay: conf.GetDuration(RetryMaxDelay),
 			Factor:       conf.GetFloat64(RetryFactor),
 		}
+		sth.submitBackoff = &retry.Retry{
+			InitialDelay: conf.GetDuration(SubmitBackoffInitDelay),
+			MaximumDelay: conf.GetDuration(SubmitBackoffMaxDelay),
+			Factor:       conf.GetFloat64(SubmitBackoffFactor),
+		}
+		sth.replacementGasPriceBump = conf.GetInt(ReplacementGasPriceBump)
+		sth.maxDroppedResubmits = conf.GetInt(MaxDroppedResubmits)
+		sth.gasEscalationPercentage = conf.GetInt(GasEscalationPercentage)
+		sth.gasEscalationMinimumPercentage = conf.GetInt(GasEscalationMinimumPercentage)
+		if maxGasPrice := fftypes.JSONAnyPtr(conf.GetString(GasEscalationMaxGasPrice)); !maxGasPrice.IsNil() {
+			sth.gasEscalationMaxGasPrice = maxGasPrice
+		}
+		sth.maxInFlightPerSigner = conf.GetInt(MaxInFlightPerSigner)
+		sth.signerSelection = conf.GetString(SignerSelection)
+		sth.signerWeights = parseSignerWeights(conf.GetObject(SignerWeights))
+		sth.priorityEnabled = conf.GetBool(PriorityEnabled)
+		tiers, err := parsePriorityGasPriceTiers(ctx, conf.GetObject(PriorityGasPriceTiers))
+		if err != nil {
+			return nil, err
+		}
+		sth.priorityGasPriceTiers = tiers
+		sth.dependencyFailure = conf.GetString(DependencyFailure)
+		sth.simulate = conf.GetBool(Simulate)
+		sth.batchConcurrency = conf.GetInt(BatchConcurrency)
+		sth.policyWorkers = conf.GetInt(PolicyWorkers)
+		sth.fundsCheckInterval = conf.GetDuration(FundsCheckInterval)
+		sth.fundsSuspendLater = conf.GetBool(FundsSuspendLater)
+		sth.staleBlocks = conf.GetInt(StaleBlocks)
+	}
+
+	if sth.policyWorkers < 1 {
+		sth.policyWorkers = 1
+	}
+	sth.newPolicyWorkers()
+
+	switch sth.signerSelection {
+	case SignerSelectionSequence, SignerSelectionRoundRobin, SignerSelectionWeighted:
+	default:
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidSignerSelection, sth.signerSelection)
+	}
+
+	switch sth.dependencyFailure {
+	case DependencyFailureFail, DependencyFailureHold:
+	default:
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidDependencyFailure, sth.dependencyFailure)
+	}
+
+	if gasPricer != nil {
+		// The gas pricer replaces the fixed gas price and gas oracle
+		sth.gasPricer = gasPricer
+		return sth, nil
 	}
 
 	switch sth.gasOracleMode {
 	case GasOracleModeConnector:
 		// No initialization required
 	case GasOracleModeRESTAPI:
-		goc, err := ffresty.New(ctx, gasOracleConfig)
+		source, err := newGasOracleSource(ctx, gasOracleConfig, gasOracleConfig)
 		if err != nil {
 			return nil, err
 		}
-		sth.gasOracleClient = goc
-		templateString := gasOracleConfig.GetString(GasOracleTemplate)
-		if templateString == "" {
-			return nil, i18n.NewError(ctx, tmmsgs.MsgMissingGOTemplate)
-		}
-		template, err := template.New("").Funcs(sprig.FuncMap()).Parse(templateString)
-		if err != nil {
-			return nil, i18n.NewError(ctx, tmmsgs.MsgBadGOTemplate, err)
+		sth.gasOracleAPI = source
+	case GasOracleModeAggregate:
+		if err := sth.initGasOracleAggregate(ctx, gasOracleConfig); err != nil {
+			return nil, err
 		}
-		sth.gasOracleTemplate = template
 	default:
 		if sth.fixedGasPrice.IsNil() {
 			return nil, i18n.NewError(ctx, tmmsgs.MsgNoGasConfigSetForTransactionHandler)
@@ -163,14 +243,32 @@ type simpleTransactionHandler struct {
 	toolkit          *txhandler.Toolkit
 	fixedGasPrice    *fftypes.JSONAny
 	resubmitInterval time.Duration
+	staleBlocks      int
+	highestBlock     uint64 // the highest block notified, protected by mux
 
+	gasPricer              GasPricer
 	gasOracleMode          string
-	gasOracleClient        *resty.Client
-	gasOracleMethod        string
-	gasOracleTemplate      *template.Template
+	gasOracleAPI           *gasOracleSource
 	gasOracleQueryInterval time.Duration
 	gasOracleQueryValue    *fftypes.JSONAny
 	gasOracleLastQueryTime *fftypes.FFTime
+	gasOracleMux           sync.Mutex         // protects the cached gas price, as the policy workers run concurrently
+	gasOracleQuery         singleflight.Group // shares a gas oracle query between the policy workers
+
+	gasOracleSources      []*gasOracleSource
+	gasOracleConnector    bool
+	gasOracleAggregation  string
+	gasOraclePercentile   int
+	gasOracleMaxDeviation int
+	gasOracleMinSources   int
+
+	submitBackoff           *retry.Retry
+	replacementGasPriceBump int
+	maxDroppedResubmits     int
+
+	gasEscalationPercentage        int
+	gasEscalationMinimumPercentage int
+	gasEscalationMaxGasPrice       *fftypes.JSONAny
 
 	policyLoopInterval      time.Duration
 	policyLoopDone          chan struct{}
@@ -181,28 +279,79 @@ type simpleTransactionHandler struct {
 	policyEngineAPIRequests []*policyEngineAPIRequest
 	maxInFlight             int
 	retry                   *retry.Retry
+	policyWorkers           int
+
+	maxInFlightPerSigner int
+	signerSelection      string
+	signerWeights        map[string]int
+	signerCursor         string
+	signerCredit         int
+	signerQueueDepth     map[string]int
+	pendingSigners       map[string]map[string]*fftypes.FFBigInt // lowercased signers with pending transactions, to each casing seen and the nonce its next query starts after
+	pendingScanned       string                                  // sequence of the last pending transaction scanned for new signers
+
+	priorityEnabled       bool
+	priorityGasPriceTiers []*priorityGasPriceTier
+
+	dependencyFailure string
+
+	simulate bool
+
+	batchConcurrency int
+
+	fundsCheckInterval time.Duration
+	fundsSuspendLater  bool
+
+	workers []*policyWorker
 }
 
 type pendingState struct {
-	mtx                     *apitypes.ManagedTX
-	trackingTransactionHash string
-	lastPolicyCycle         time.Time
-	receipt                 *ffcapi.TransactionReceiptResponse
-	info                    *simplePolicyInfo
-	confirmed               bool
-	confirmations           *apitypes.ConfirmationsNotification
-	receiptNotify           *fftypes.FFTime
-	confirmNotify           *fftypes.FFTime
-	remove                  bool
-	subStatus               apitypes.TxSubStatus
+	mtx               *apitypes.ManagedTX
+	execMux           sync.Mutex // held while the policy engine executes against the transaction
+	trackedHashes     []string   // the hashes added to the confirmation manager
+	lastPolicyCycle   time.Time
+	receipt           *ffcapi.TransactionReceiptResponse
+	info              *simplePolicyInfo
+	confirmed         bool
+	confirmations     *apitypes.ConfirmationsNotification
+	receiptNotify     *fftypes.FFTime
+	confirmNotify     *fftypes.FFTime
+	remove            bool // protected by the handler mux, as the policy loop reads it to drop the transaction from the in-flight set
+	subStatus         apitypes.TxSubStatus
+	notBefore         *fftypes.FFTime
+	dependenciesMet   bool
+	waitingFor        *dependencyCheck // the last check of the transactions this transaction depends on
+	dependencyChecked time.Time
+	dependency        *dependencyCheck
+	fundedBalance     *big.Int
+	underfundedBy     string
+	receiptHash       string // the hash the receipt is for, if known
+	update            *apitypes.TransactionUpdateRequest
 }
 
 type simplePolicyInfo struct {
-	LastWarnTime *fftypes.FFTime `json:"lastWarnTime"`
+	LastWarnTime        *fftypes.FFTime  `json:"lastWarnTime"`
+	LastWarnBlock       uint64           `json:"lastWarnBlock,omitempty"`
+	SubmitBackoffUntil  *fftypes.FFTime  `json:"submitBackoffUntil,omitempty"`
+	SubmitBackoffCount  int              `json:"submitBackoffCount,omitempty"`
+	ReplacementGasPrice *fftypes.JSONAny `json:"replacementGasPrice,omitempty"`
+	DroppedCount        int              `json:"droppedCount,omitempty"`
+	DependencyHeld      bool             `json:"dependencyHeld,omitempty"`
+	GasEscalations      int              `json:"gasEscalations,omitempty"`
+	EscalatedGasPrice   *fftypes.JSONAny `json:"escalatedGasPrice,omitempty"`
+	InsufficientFunds   *underfundedInfo `json:"insufficientFunds,omitempty"`
+	GasPriceOverride    *fftypes.JSONAny `json:"gasPriceOverride,omitempty"`
+	SubmittedHashes     []string         `json:"submittedHashes,omitempty"`
+	CancelHash          string           `json:"cancelHash,omitempty"`
+	CancelError         string           `json:"cancelError,omitempty"`
+	ExpectedHash        string           `json:"expectedHash,omitempty"`
 }
 
 func (sth *simpleTransactionHandler) Init(ctx context.Context, toolkit *txhandler.Toolkit) {
 	sth.toolkit = toolkit
+	if _, ok := toolkit.Connector.(ffcapi.TransactionSimulator); sth.simulate && !ok {
+		log.L(ctx).Warnf("Simulation is enabled, but the connector does not support simulating transactions - they will be submitted without simulation")
+	}
 
 	// init metrics
 	sth.initSimpleHandlerMetrics(ctx)
@@ -257,7 +406,7 @@ func (sth *simpleTransactionHandler) HandleNewTransaction(ctx context.Context, t
 		return nil, err
 	}
 
-	return sth.createManagedTx(ctx, txID, &txReq.TransactionHeaders, prepared.Gas, prepared.TransactionData)
+	return sth.createManagedTx(ctx, txID, &txReq.Headers, &txReq.TransactionHeaders, prepared.Gas, prepared.TransactionData)
 }
 
 func (sth *simpleTransactionHandler) HandleNewContractDeployment(ctx context.Context, txReq *apitypes.ContractDeployRequest) (mtx *apitypes.ManagedTX, err error) {
@@ -274,7 +423,7 @@ func (sth *simpleTransactionHandler) HandleNewContractDeployment(ctx context.Con
 		return nil, err
 	}
 
-	return sth.createManagedTx(ctx, txID, &txReq.TransactionHeaders, prepared.Gas, prepared.TransactionData)
+	return sth.createManagedTx(ctx, txID, &txReq.Headers, &txReq.TransactionHeaders, prepared.Gas, prepared.TransactionData)
 }
 
 func (sth *simpleTransactionHandler) HandleCancelTransaction(ctx context.Context, txID string) (mtx *apitypes.ManagedTX, err error) {
@@ -301,53 +450,132 @@ func (sth *simpleTransactionHandler) HandleResumeTransaction(ctx context.Context
 	return res.tx, res.err
 }
 
-func (sth *simpleTransactionHandler) createManagedTx(ctx context.Context, txID string, txHeaders *ffcapi.TransactionHeaders, gas *fftypes.FFBigInt, transactionData string) (*apitypes.ManagedTX, error) {
+func (sth *simpleTransactionHandler) HandleUpdateTransaction(ctx context.Context, txID string, update *apitypes.TransactionUpdateRequest) (mtx *apitypes.ManagedTX, err error) {
+	if update.GasPrice.IsNil() && update.Gas == nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgTransactionUpdateEmpty)
+	}
+	res := sth.policyEngineAPIRequest(ctx, &policyEngineAPIRequest{
+		requestType: ActionUpdate,
+		txID:        txID,
+		update:      update,
+	})
+	return res.tx, res.err
+}
+
+func (sth *simpleTransactionHandler) HandleCancelByReplacement(ctx context.Context, txID string) (mtx *apitypes.ManagedTX, err error) {
+	res := sth.policyEngineAPIRequest(ctx, &policyEngineAPIRequest{
+		requestType: ActionCancel,
+		txID:        txID,
+	})
+	return res.tx, res.err
+}
+
+func (sth *simpleTransactionHandler) createManagedTx(ctx context.Context, txID string, reqHeaders *apitypes.RequestHeaders, txHeaders *ffcapi.TransactionHeaders, gas *fftypes.FFBigInt, transactionData string) (*apitypes.ManagedTX, error) {
+
+	if err := sth.checkDependenciesExist(ctx, txID, reqHeaders.DependsOn); err != nil {
+		return nil, err
+	}
+	mtx := newManagedTx(txID, reqHeaders, txHeaders, gas, transactionData)
+	if err := sth.admitNewTx(ctx, mtx); err != nil {
+		return nil, err
+	}
+
+	// Sequencing ID will be added as part of persistence logic - so we have a deterministic order of transactions
+	// Note: We must ensure persistence happens this within the nonce lock, to ensure that the nonce sequence and the
+	//       global transaction sequence line up.
+	err := sth.toolkit.TXPersistence.InsertTransactionWithNextNonce(ctx, mtx, sth.nextNonceForSigner)
+	if err != nil {
+		sth.releaseNewTx(ctx, mtx)
+	} else {
+		err = sth.trackNewTx(ctx, mtx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sth.markInflightStale()
+
+	return mtx, nil
+}
 
+func newManagedTx(txID string, reqHeaders *apitypes.RequestHeaders, txHeaders *ffcapi.TransactionHeaders, gas *fftypes.FFBigInt, transactionData string) *apitypes.ManagedTX {
 	if gas != nil {
 		txHeaders.Gas = gas
 	}
 	now := fftypes.Now()
-	mtx := &apitypes.ManagedTX{
+	return &apitypes.ManagedTX{
 		ID:                 txID, // on input the request ID must be the namespaced operation ID
 		Created:            now,
 		Updated:            now,
 		TransactionHeaders: *txHeaders,
 		TransactionData:    transactionData,
+		Priority:           reqHeaders.Priority,
+		NotBefore:          reqHeaders.NotBefore,
+		Expiry:             reqHeaders.Expiry,
+		DependsOn:          fftypes.NewFFStringArray(reqHeaders.DependsOn...),
+		Simulate:           reqHeaders.Simulate,
 		Status:             apitypes.TxStatusPending,
 		PolicyInfo:         fftypes.JSONAnyPtr(`{}`),
 	}
+}
 
-	// Sequencing ID will be added as part of persistence logic - so we have a deterministic order of transactions
-	// Note: We must ensure persistence happens this within the nonce lock, to ensure that the nonce sequence and the
-	//       global transaction sequence line up.
-	err := sth.toolkit.TXPersistence.InsertTransactionWithNextNonce(ctx, mtx, func(ctx context.Context, signer string) (uint64, error) {
-		nextNonceRes, _, err := sth.toolkit.Connector.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{
-			Signer: signer,
-		})
-		if err != nil {
-			return 0, err
-		}
-		return nextNonceRes.Nonce.Uint64(), nil
+// admitNewTx checks a new transaction against the spend policy, if one is enabled
+func (sth *simpleTransactionHandler) admitNewTx(ctx context.Context, mtx *apitypes.ManagedTX) error {
+	if sth.toolkit.SpendPolicy == nil {
+		return nil
+	}
+	return sth.toolkit.SpendPolicy.Admit(ctx, mtx)
+}
+
+// releaseNewTx removes a transaction that was admitted by the spend policy, but could not be persisted
+func (sth *simpleTransactionHandler) releaseNewTx(ctx context.Context, mtx *apitypes.ManagedTX) {
+	if sth.toolkit.SpendPolicy != nil {
+		sth.toolkit.SpendPolicy.Release(ctx, mtx)
+	}
+}
+
+func (sth *simpleTransactionHandler) nextNonceForSigner(ctx context.Context, signer string) (uint64, error) {
+	nextNonceRes, _, err := sth.toolkit.Connector.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{
+		Signer: signer,
 	})
-	if err == nil {
-		err = sth.toolkit.TXHistory.AddSubStatusAction(ctx, txID, apitypes.TxSubStatusReceived, apitypes.TxActionAssignNonce, fftypes.JSONAnyPtr(`{"nonce":"`+mtx.Nonce.String()+`"}`), nil)
+	if err != nil {
+		return 0, err
+	}
+	return nextNonceRes.Nonce.Uint64(), nil
+}
+
+// trackNewTx records the history of a transaction that has been inserted with its nonce
+func (sth *simpleTransactionHandler) trackNewTx(ctx context.Context, mtx *apitypes.ManagedTX) error {
+	err := sth.toolkit.TXHistory.AddSubStatusAction(ctx, mtx.ID, apitypes.TxSubStatusReceived, apitypes.TxActionAssignNonce, fftypes.JSONAnyPtr(`{"nonce":"`+mtx.Nonce.String()+`"}`), nil)
+	if err == nil && mtx.NotBefore != nil {
+		err = sth.toolkit.TXHistory.AddSubStatusAction(ctx, mtx.ID, apitypes.TxSubStatusScheduled, apitypes.TxActionSchedule, fftypes.JSONAnyPtr(`{"notBefore":"`+mtx.NotBefore.String()+`"}`), nil)
 	}
 	if err != nil {
-		return nil, err
+		return err
 	}
 	log.L(ctx).Infof("Tracking transaction %s at nonce %s / %d", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64())
-	sth.markInflightStale()
-
-	return mtx, nil
+	return nil
 }
 
 func (sth *simpleTransactionHandler) submitTX(ctx *RunContext) (reason ffcapi.ErrorReason, err error) {
 
 	mtx := ctx.TX
-	mtx.GasPrice, err = sth.getGasPrice(ctx, sth.toolkit.Connector)
-	if err != nil {
-		ctx.AddSubStatusAction(apitypes.TxActionRetrieveGasPrice, nil, fftypes.JSONAnyPtr(`{"error":"`+err.Error()+`"}`))
-		return "", err
+	if ctx.Info.GasPriceOverride != nil {
+		// The gas price has been set through the API
+		mtx.GasPrice = ctx.Info.GasPriceOverride
+	} else {
+		mtx.GasPrice, err = sth.getGasPrice(ctx, sth.toolkit.Connector)
+		if err != nil {
+			ctx.AddSubStatusAction(apitypes.TxActionRetrieveGasPrice, nil, fftypes.JSONAnyPtr(`{"error":"`+err.Error()+`"}`))
+			return "", err
+		}
+		mtx.GasPrice = sth.priorityGasPrice(mtx.Priority, mtx.GasPrice)
+	}
+	// If a previous submission was rejected as underpriced against the transaction already in the pool,
+	// or we have escalated the price of a stale transaction, we must not go below those prices (up to any cap)
+	for _, floor := range []*fftypes.JSONAny{ctx.Info.ReplacementGasPrice, ctx.Info.EscalatedGasPrice} {
+		if floor != nil {
+			mtx.GasPrice = maxGasPrice(mtx.GasPrice, sth.capGasPrice(floor))
+		}
 	}
 	ctx.AddSubStatusAction(apitypes.TxActionRetrieveGasPrice, fftypes.JSONAnyPtr(`{"gasPrice":`+string(*mtx.GasPrice)+`}`), nil)
 
@@ -358,6 +586,15 @@ func (sth *simpleTransactionHandler) submitTX(ctx *RunContext) (reason ffcapi.Er
 	}
 	sendTX.TransactionHeaders.Nonce = (*fftypes.FFBigInt)(mtx.Nonce.Int())
 	sendTX.TransactionHeaders.Gas = (*fftypes.FFBigInt)(mtx.Gas.Int())
+
+	// Calculate and persist the hash we expect the transaction to have, before we submit it.
+	// Then if the outcome of the submission is ambiguous (such as a timeout), a later submission
+	// that returns known_transaction or nonce_too_low can be reconciled with the hash, once the
+	// blockchain node confirms it knows a transaction with that hash.
+	if err := sth.persistExpectedHash(ctx, sendTX); err != nil {
+		return "", err
+	}
+
 	log.L(ctx).Debugf("Sending transaction %s at nonce %s / %d (lastSubmit=%s)", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.LastSubmit)
 	transactionSendStartTime := time.Now()
 	res, reason, err := sth.toolkit.Connector.TransactionSend(ctx, sendTX)
@@ -369,9 +606,14 @@ func (sth *simpleTransactionHandler) submitTX(ctx *RunContext) (reason ffcapi.Er
 		mtx.LastSubmit = fftypes.Now()
 		// Need to persist back as we've successfully submitted
 		ctx.UpdateType = Update
+		if ctx.Info.ExpectedHash != "" {
+			ctx.Info.ExpectedHash = ""
+			ctx.UpdatedInfo = true
+		}
 		ctx.TXUpdates.TransactionHash = &res.TransactionHash
 		ctx.TXUpdates.LastSubmit = mtx.LastSubmit
 		ctx.TXUpdates.GasPrice = mtx.GasPrice
+		sth.resetSubmitRetryState(ctx)
 	} else {
 		ctx.AddSubStatusAction(apitypes.TxActionSubmitTransaction, fftypes.JSONAnyPtr(`{"reason":"`+string(reason)+`"}`), fftypes.JSONAnyPtr(`{"error":"`+err.Error()+`"}`))
 		// We have some simple rules for handling reasons from the connector, which could be enhanced by extending the connector.
@@ -380,12 +622,29 @@ func (sth *simpleTransactionHandler) submitTX(ctx *RunContext) (reason ffcapi.Er
 			// If we already have a transaction hash, this is fine - we just return as if we submitted it
 			if mtx.TransactionHash != "" {
 				log.L(ctx).Debugf("Transaction %s at nonce %s / %d known with hash: %s (%s)", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.TransactionHash, err)
+				sth.resetSubmitRetryState(ctx)
+				return "", nil
+			}
+			// We never got a successful response to a submission, but an earlier one might have reached the node
+			if sth.reconcileExpectedHash(ctx) {
+				sth.resetSubmitRetryState(ctx)
 				return "", nil
 			}
-			// Note: to cover the edge case where we had a timeout or other failure during the initial TransactionSend,
-			//       a policy engine implementation would need to be able to re-calculate the hash that we would expect for the transaction.
-			//       This would require a new FFCAPI API to calculate that hash, which requires the connector to perform the signing
-			//       without submission to the node. For example using `eth_signTransaction` for EVM JSON/RPC.
+			return reason, err
+		case ffcapi.ErrorReasonRateLimited, ffcapi.ErrorReasonNonceTooHigh:
+			// The node might accept the transaction later, once it is less busy or the preceding nonces have arrived
+			sth.backoffSubmission(ctx, reason)
+			return reason, err
+		case ffcapi.ErrorReasonReplacementUnderpriced:
+			// There is a transaction with this nonce in the pool already, so we need to outbid it
+			sth.bumpReplacementGasPrice(ctx, reason)
+			return reason, err
+		case ffcapi.ErrorReasonInsufficientFunds:
+			// Resubmitting cannot succeed until the signer has been topped up, so we suspend until it has
+			return reason, sth.suspendUnderfunded(ctx, i18n.NewError(ctx, tmmsgs.MsgTransactionUnderfunded, mtx.ID, mtx.From, err))
+		case ffcapi.ErrorReasonChainIDMismatch, ffcapi.ErrorReasonGasLimitExceeded:
+			// No amount of resubmitting will make these succeed
+			sth.failTransaction(ctx, reason, err)
 			return reason, err
 		default:
 			return reason, err
@@ -396,6 +655,294 @@ func (sth *simpleTransactionHandler) submitTX(ctx *RunContext) (reason ffcapi.Er
 	return "", nil
 }
 
+// backoffSubmission records in the policy info when we should next attempt to submit the transaction,
+// increasing the delay each time the connector asks us to back off
+func (sth *simpleTransactionHandler) backoffSubmission(ctx *RunContext, reason ffcapi.ErrorReason) {
+	mtx := ctx.TX
+	ctx.Info.SubmitBackoffCount++
+	delay := sth.submitBackoff.InitialDelay
+	for i := 1; i < ctx.Info.SubmitBackoffCount && delay < sth.submitBackoff.MaximumDelay; i++ {
+		delay = time.Duration(float64(delay) * sth.submitBackoff.Factor)
+	}
+	if delay > sth.submitBackoff.MaximumDelay {
+		delay = sth.submitBackoff.MaximumDelay
+	}
+	retryAfter := fftypes.FFTime(time.Now().Add(delay))
+	ctx.Info.SubmitBackoffUntil = &retryAfter
+	ctx.UpdateType = Update
+	ctx.UpdatedInfo = true
+	log.L(ctx).Warnf("Transaction %s at nonce %s / %d submission backing off for %s (reason=%s attempt=%d)", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), delay, reason, ctx.Info.SubmitBackoffCount)
+	ctx.AddSubStatusAction(apitypes.TxActionSubmitBackoff, fftypes.JSONAnyPtr(fmt.Sprintf(`{"reason":"%s","attempt":%d,"retryAfter":"%s"}`, reason, ctx.Info.SubmitBackoffCount, ctx.Info.SubmitBackoffUntil)), nil)
+}
+
+// bumpReplacementGasPrice records in the policy info a gas price higher than the one just rejected,
+// to be used as the minimum for the next submission
+func (sth *simpleTransactionHandler) bumpReplacementGasPrice(ctx *RunContext, reason ffcapi.ErrorReason) {
+	mtx := ctx.TX
+	ctx.Info.ReplacementGasPrice = sth.capGasPrice(BumpGasPrice(mtx.GasPrice, sth.replacementGasPriceBump))
+	ctx.UpdateType = Update
+	ctx.UpdatedInfo = true
+	log.L(ctx).Warnf("Transaction %s at nonce %s / %d gas price bumped from %s to %s (reason=%s)", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.GasPrice, ctx.Info.ReplacementGasPrice, reason)
+	ctx.AddSubStatusAction(apitypes.TxActionBumpGasPrice, fftypes.JSONAnyPtr(`{"reason":"`+string(reason)+`","gasPrice":`+mtx.GasPrice.String()+`,"newGasPrice":`+ctx.Info.ReplacementGasPrice.String()+`}`), nil)
+}
+
+// escalateGasPrice records in the policy info a gas price increased from the last one submitted,
+// to be used as the minimum for the resubmission of a stale transaction
+func (sth *simpleTransactionHandler) escalateGasPrice(ctx *RunContext) {
+	mtx := ctx.TX
+	if mtx.GasPrice.IsNil() {
+		return
+	}
+	var escalated *fftypes.JSONAny
+	if sth.gasPricer != nil {
+		escalated = sth.gasPricer.EscalateGasPrice(ctx, mtx.GasPrice)
+	} else if sth.gasEscalationPercentage > 0 {
+		percent := sth.gasEscalationPercentage
+		if percent < sth.gasEscalationMinimumPercentage {
+			percent = sth.gasEscalationMinimumPercentage
+		}
+		escalated = BumpGasPrice(mtx.GasPrice, percent)
+	}
+	if escalated.IsNil() {
+		return
+	}
+	ctx.Info.EscalatedGasPrice = sth.capGasPrice(escalated)
+	ctx.Info.GasEscalations++
+	ctx.UpdateType = Update
+	ctx.UpdatedInfo = true
+	log.L(ctx).Infof("Transaction %s at nonce %s / %d gas price escalated from %s to %s (escalations=%d)", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.GasPrice, ctx.Info.EscalatedGasPrice, ctx.Info.GasEscalations)
+	ctx.AddSubStatusAction(apitypes.TxActionBumpGasPrice, fftypes.JSONAnyPtr(fmt.Sprintf(`{"escalation":%d,"gasPrice":%s,"newGasPrice":%s}`, ctx.Info.GasEscalations, mtx.GasPrice, ctx.Info.EscalatedGasPrice)), nil)
+}
+
+// gasEscalationEnabled is true if stale transactions are resubmitted with an escalated gas price, which is
+// always the case with a gas pricer
+func (sth *simpleTransactionHandler) gasEscalationEnabled() bool {
+	return sth.gasPricer != nil || sth.gasEscalationPercentage > 0
+}
+
+// capGasPrice applies the configured maximum gas price, if there is one
+func (sth *simpleTransactionHandler) capGasPrice(gasPrice *fftypes.JSONAny) *fftypes.JSONAny {
+	if sth.gasEscalationMaxGasPrice == nil {
+		return gasPrice
+	}
+	return minGasPrice(gasPrice, sth.gasEscalationMaxGasPrice)
+}
+
+// failTransaction moves the transaction to the failed state, after which it is no longer processed
+func (sth *simpleTransactionHandler) failTransaction(ctx *RunContext, reason ffcapi.ErrorReason, err error) {
+	mtx := ctx.TX
+	log.L(ctx).Errorf("Transaction %s at nonce %s / %d failed (reason=%s): %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), reason, err)
+	mtx.Status = apitypes.TxStatusFailed
+	ctx.UpdateType = Update
+	ctx.TXUpdates.Status = &mtx.Status
+	ctx.SetSubStatus(apitypes.TxSubStatusFailed)
+	ctx.AddSubStatusAction(apitypes.TxActionFailTransaction, fftypes.JSONAnyPtr(`{"reason":"`+string(reason)+`"}`), fftypes.JSONAnyPtr(`{"error":"`+err.Error()+`"}`))
+}
+
+// resetSubmitRetryState clears any backoff or gas price bump, once a submission has been accepted
+func (sth *simpleTransactionHandler) resetSubmitRetryState(ctx *RunContext) {
+	if ctx.Info.SubmitBackoffUntil != nil || ctx.Info.ReplacementGasPrice != nil {
+		ctx.Info.SubmitBackoffUntil = nil
+		ctx.Info.SubmitBackoffCount = 0
+		ctx.Info.ReplacementGasPrice = nil
+		ctx.UpdateType = Update
+		ctx.UpdatedInfo = true
+	}
+}
+
+// checkDropped looks up a stale transaction on the node, to determine whether it needs resubmitting.
+// A transaction the node still knows about is left alone, unless it is pending and gas price escalation
+// is enabled. A transaction the node
+// does not know about has been dropped, and is resubmitted - unless it has been dropped too many times,
+// in which case it is marked failed as lost. If the connector cannot tell us, we resubmit.
+func (sth *simpleTransactionHandler) checkDropped(ctx *RunContext) (resubmit bool, err error) {
+	mtx := ctx.TX
+	finder, ok := sth.toolkit.Connector.(ffcapi.TransactionFinder)
+	if mtx.TransactionHash == "" || !ok {
+		return true, nil
+	}
+	res, reason, err := finder.TransactionByHash(ctx, &ffcapi.TransactionByHashRequest{
+		TransactionHash: mtx.TransactionHash,
+	})
+	switch {
+	case err == nil:
+		log.L(ctx).Infof("Transaction %s at nonce %s / %d is %s on the blockchain node with hash: %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), res.State, mtx.TransactionHash)
+		ctx.AddSubStatusAction(apitypes.TxActionLookupTransaction, fftypes.JSONAnyPtr(`{"hash":"`+mtx.TransactionHash+`","state":"`+string(res.State)+`"}`), nil)
+		// Resubmitting a pending transaction only helps if we are going to escalate the gas price
+		return res.State == ffcapi.TransactionStatePending && sth.gasEscalationEnabled(), nil
+	case reason == ffcapi.ErrorReasonNotFound:
+		ctx.SetSubStatus(apitypes.TxSubStatusDropped)
+		ctx.AddSubStatusAction(apitypes.TxActionLookupTransaction, fftypes.JSONAnyPtr(`{"hash":"`+mtx.TransactionHash+`","reason":"`+string(reason)+`"}`), fftypes.JSONAnyPtr(`{"error":"`+err.Error()+`"}`))
+		if sth.maxDroppedResubmits > 0 && ctx.Info.DroppedCount >= sth.maxDroppedResubmits {
+			lostErr := i18n.NewError(ctx, tmmsgs.MsgTransactionLost, mtx.TransactionHash, ctx.Info.DroppedCount)
+			sth.failTransaction(ctx, reason, lostErr)
+			return false, lostErr
+		}
+		ctx.Info.DroppedCount++
+		ctx.UpdatedInfo = true
+		log.L(ctx).Warnf("Transaction %s at nonce %s / %d with hash %s was dropped by the blockchain node (dropped=%d)", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.TransactionHash, ctx.Info.DroppedCount)
+		return true, nil
+	default:
+		if reason != ffcapi.ErrorReasonNotSupported {
+			log.L(ctx).Warnf("Unable to look up transaction %s at nonce %s / %d with hash %s (reason=%s): %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.TransactionHash, reason, err)
+			ctx.AddSubStatusAction(apitypes.TxActionLookupTransaction, fftypes.JSONAnyPtr(`{"hash":"`+mtx.TransactionHash+`","reason":"`+string(reason)+`"}`), fftypes.JSONAnyPtr(`{"error":"`+err.Error()+`"}`))
+		}
+		return true, nil
+	}
+}
+
+// expireTransaction is called when a transaction has passed its expiry without a receipt
+func (sth *simpleTransactionHandler) expireTransaction(ctx *RunContext) error {
+	mtx := ctx.TX
+	return sth.cancelTransaction(ctx, i18n.NewError(ctx, tmmsgs.MsgTransactionExpired, mtx.ID, mtx.Expiry))
+}
+
+// cancelTransaction is called when a transaction that has not been mined is no longer wanted. The nonce is filled
+// with a no-op transaction where the connector supports it, so later transactions from the same signer are not
+// blocked, and then the transaction is marked failed with the supplied error. If we have submitted the transaction,
+// our submission might still be mined instead - so we wait for a receipt for one or the other, and only fail the
+// transaction with the supplied error if it is the cancellation that is mined. Likewise if the nonce has already
+// been used, it is likely to have been by our submission, so we keep waiting for its receipt rather than failing it.
+func (sth *simpleTransactionHandler) cancelTransaction(ctx *RunContext, failErr error) error {
+	mtx := ctx.TX
+	cancelTX, err := sth.cancelRequest(ctx)
+	if err != nil {
+		return err
+	}
+	gasPrice := cancelTX.GasPrice
+	log.L(ctx).Infof("Cancelling transaction %s at nonce %s / %d: %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), failErr)
+	res, reason, err := sth.sendCancel(ctx, cancelTX)
+	switch {
+	case err == nil:
+		log.L(ctx).Infof("Transaction %s at nonce %s / %d cancelled with hash: %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), res.TransactionHash)
+		ctx.AddSubStatusAction(apitypes.TxActionCancelTransaction, fftypes.JSONAnyPtr(`{"hash":"`+res.TransactionHash+`","gasPrice":`+gasPrice.String()+`}`), nil)
+		if mtx.FirstSubmit != nil {
+			ctx.Info.CancelHash = res.TransactionHash
+			ctx.Info.CancelError = failErr.Error()
+			ctx.UpdateType = Update
+			ctx.UpdatedInfo = true
+			return nil
+		}
+	case (reason == ffcapi.ErrorReasonNonceTooLow || reason == ffcapi.ErrorKnownTransaction) && mtx.FirstSubmit != nil && mtx.TransactionHash != "":
+		log.L(ctx).Infof("Transaction %s at nonce %s / %d cannot be cancelled, as the nonce has been used - waiting for receipt of %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.TransactionHash)
+		ctx.AddSubStatusAction(apitypes.TxActionCancelTransaction, fftypes.JSONAnyPtr(`{"reason":"`+string(reason)+`"}`), fftypes.JSONAnyPtr(`{"error":"`+err.Error()+`"}`))
+		return nil
+	case reason == ffcapi.ErrorReasonNotSupported || reason == ffcapi.ErrorReasonNonceTooLow || reason == ffcapi.ErrorKnownTransaction:
+		// We have no submission of our own that could have used the nonce, so we cannot wait for a receipt
+		log.L(ctx).Warnf("Transaction %s at nonce %s / %d could not be cancelled - there might be a gap in the nonces of the signer (reason=%s): %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), reason, err)
+		ctx.AddSubStatusAction(apitypes.TxActionCancelTransaction, fftypes.JSONAnyPtr(`{"reason":"`+string(reason)+`"}`), fftypes.JSONAnyPtr(`{"error":"`+err.Error()+`"}`))
+	default:
+		// We try again on the next policy cycle
+		log.L(ctx).Warnf("Failed to cancel transaction %s at nonce %s / %d (reason=%s): %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), reason, err)
+		ctx.AddSubStatusAction(apitypes.TxActionCancelTransaction, fftypes.JSONAnyPtr(`{"reason":"`+string(reason)+`"}`), fftypes.JSONAnyPtr(`{"error":"`+err.Error()+`"}`))
+		return err
+	}
+	sth.failTransaction(ctx, reason, failErr)
+	return failErr
+}
+
+// sendCancel submits the cancellation, if the connector supports it
+func (sth *simpleTransactionHandler) sendCancel(ctx *RunContext, cancelTX *ffcapi.TransactionCancelRequest) (*ffcapi.TransactionCancelResponse, ffcapi.ErrorReason, error) {
+	canceller, ok := sth.toolkit.Connector.(ffcapi.TransactionCanceller)
+	if !ok {
+		return nil, ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionCancel")
+	}
+	return canceller.TransactionCancel(ctx, cancelTX)
+}
+
+// cancelRequest builds the request to fill the nonce of the transaction, with a gas price high enough
+// to outbid any submission that is still in the transaction pool
+func (sth *simpleTransactionHandler) cancelRequest(ctx *RunContext) (*ffcapi.TransactionCancelRequest, error) {
+	mtx := ctx.TX
+	gasPrice, err := sth.getGasPrice(ctx, sth.toolkit.Connector)
+	if err != nil {
+		ctx.AddSubStatusAction(apitypes.TxActionRetrieveGasPrice, nil, fftypes.JSONAnyPtr(`{"error":"`+err.Error()+`"}`))
+		return nil, err
+	}
+	if !mtx.GasPrice.IsNil() {
+		gasPrice = maxGasPrice(gasPrice, sth.capGasPrice(BumpGasPrice(mtx.GasPrice, sth.replacementGasPriceBump)))
+	}
+	return &ffcapi.TransactionCancelRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From:  mtx.From,
+			Nonce: (*fftypes.FFBigInt)(mtx.Nonce.Int()),
+			Gas:   (*fftypes.FFBigInt)(mtx.Gas.Int()),
+		},
+		GasPrice: gasPrice,
+	}, nil
+}
+
+// persistExpectedHash asks the connector for the hash the transaction will have, and persists it in the
+// policy info before submission. Not all connectors are able to sign without submitting, so failure to get
+// the hash is not fatal - the submission continues without it.
+func (sth *simpleTransactionHandler) persistExpectedHash(ctx *RunContext, sendTX *ffcapi.TransactionSendRequest) error {
+	mtx := ctx.TX
+	signer, ok := sth.toolkit.Connector.(ffcapi.TransactionSigner)
+	if !ok {
+		return nil
+	}
+	res, reason, err := signer.TransactionSign(ctx, &ffcapi.TransactionSignRequest{
+		TransactionHeaders: sendTX.TransactionHeaders,
+		GasPrice:           sendTX.GasPrice,
+		TransactionData:    sendTX.TransactionData,
+	})
+	if err != nil {
+		log.L(ctx).Warnf("Unable to calculate expected hash for transaction %s at nonce %s / %d (reason=%s): %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), reason, err)
+		ctx.AddSubStatusAction(apitypes.TxActionSignTransaction, fftypes.JSONAnyPtr(`{"reason":"`+string(reason)+`"}`), fftypes.JSONAnyPtr(`{"error":"`+err.Error()+`"}`))
+		return nil
+	}
+	ctx.AddSubStatusAction(apitypes.TxActionSignTransaction, fftypes.JSONAnyPtr(`{"hash":"`+res.TransactionHash+`"}`), nil)
+	if res.TransactionHash == "" || res.TransactionHash == mtx.TransactionHash || res.TransactionHash == ctx.Info.ExpectedHash {
+		return nil
+	}
+	// We write this immediately, rather than waiting for the end of the policy cycle, as we cannot
+	// know what happens between here and the end of the cycle (including a crash of this process)
+	info := *ctx.Info
+	info.ExpectedHash = res.TransactionHash
+	infoBytes, _ := json.Marshal(&info)
+	if err := sth.toolkit.TXPersistence.UpdateTransaction(ctx, mtx.ID, &apitypes.TXUpdates{
+		PolicyInfo: fftypes.JSONAnyPtrBytes(infoBytes),
+	}); err != nil {
+		log.L(ctx).Errorf("Failed to persist expected hash %s for transaction %s: %s", res.TransactionHash, mtx.ID, err)
+		return err
+	}
+	ctx.Info.ExpectedHash = res.TransactionHash
+	return nil
+}
+
+// reconcileExpectedHash is called when the node tells us the nonce of a transaction we have no successful submission
+// for has been used. If the node knows a transaction with the hash we expected the transaction to have, an earlier
+// submission reached the node and we move to tracking it. Otherwise the nonce has been used by another transaction.
+// If the connector cannot look up transactions, we cannot tell the difference - so we do not reconcile.
+func (sth *simpleTransactionHandler) reconcileExpectedHash(ctx *RunContext) bool {
+	mtx := ctx.TX
+	expectedHash := ctx.Info.ExpectedHash
+	finder, ok := sth.toolkit.Connector.(ffcapi.TransactionFinder)
+	if expectedHash == "" || !ok {
+		return false
+	}
+	res, reason, err := finder.TransactionByHash(ctx, &ffcapi.TransactionByHashRequest{
+		TransactionHash: expectedHash,
+	})
+	if err != nil {
+		log.L(ctx).Warnf("Transaction %s at nonce %s / %d not found with expected hash %s (reason=%s): %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), expectedHash, reason, err)
+		ctx.AddSubStatusAction(apitypes.TxActionLookupTransaction, fftypes.JSONAnyPtr(`{"hash":"`+expectedHash+`","reason":"`+string(reason)+`"}`), fftypes.JSONAnyPtr(`{"error":"`+err.Error()+`"}`))
+		return false
+	}
+	ctx.AddSubStatusAction(apitypes.TxActionLookupTransaction, fftypes.JSONAnyPtr(`{"hash":"`+expectedHash+`","state":"`+string(res.State)+`"}`), nil)
+	log.L(ctx).Infof("Transaction %s at nonce %s / %d reconciled with expected hash: %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), expectedHash)
+	mtx.TransactionHash = expectedHash
+	mtx.LastSubmit = fftypes.Now()
+	ctx.Info.ExpectedHash = ""
+	ctx.UpdateType = Update
+	ctx.UpdatedInfo = true
+	ctx.TXUpdates.TransactionHash = &mtx.TransactionHash
+	ctx.TXUpdates.LastSubmit = mtx.LastSubmit
+	ctx.TXUpdates.GasPrice = mtx.GasPrice
+	ctx.SetSubStatus(apitypes.TxSubStatusTracking)
+	return true
+}
+
 func (sth *simpleTransactionHandler) processTransaction(ctx *RunContext) (err error) {
 
 	// Simply policy engine allows deletion of the transaction without additional checks ( ensuring the TX has not been submitted / gap filling the nonce etc. )
@@ -405,17 +952,71 @@ func (sth *simpleTransactionHandler) processTransaction(ctx *RunContext) (err er
 		return nil
 	}
 
+	if ctx.Info != nil && ctx.Info.CancelHash != "" {
+		// The transaction has been replaced with a cancellation, so we wait for a receipt for one or the other
+		return nil
+	}
+
+	if ctx.Receipt == nil && mtx.Expiry != nil && time.Now().After(*mtx.Expiry.Time()) {
+		return sth.expireTransaction(ctx)
+	}
+
+	if ctx.Info != nil && ctx.Info.InsufficientFunds != nil {
+		if resumed := sth.processUnderfunded(ctx); !resumed {
+			return nil
+		}
+	} else if mtx.FirstSubmit == nil && ctx.UnderfundedBy != "" {
+		return sth.suspendUnderfunded(ctx, i18n.NewError(ctx, tmmsgs.MsgSignerUnderfunded, mtx.ID, ctx.UnderfundedBy, mtx.From))
+	}
+
+	if mtx.FirstSubmit == nil && ctx.Dependency != nil {
+		if submit, err := sth.processDependency(ctx); !submit {
+			return err
+		}
+	}
+
+	if mtx.FirstSubmit == nil && ctx.NotBefore != nil && time.Now().Before(*ctx.NotBefore.Time()) {
+		log.L(ctx).Debugf("Transaction %s at nonce %s / %d scheduled for submission after %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), ctx.NotBefore)
+		ctx.SetSubStatus(apitypes.TxSubStatusScheduled)
+		return nil
+	}
+
+	if ctx.Receipt == nil && ctx.Info.SubmitBackoffUntil != nil && time.Now().Before(*ctx.Info.SubmitBackoffUntil.Time()) {
+		log.L(ctx).Debugf("Transaction %s at nonce %s / %d backing off submission until %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), ctx.Info.SubmitBackoffUntil)
+		return nil
+	}
+
 	if mtx.FirstSubmit == nil {
+		if sth.simulateEnabled(mtx) {
+			if submit, err := sth.simulateTX(ctx); !submit {
+				return err
+			}
+		}
 		// Submit the first time
 		if _, err := sth.submitTX(ctx); err != nil {
 			return err
 		}
 		mtx.FirstSubmit = mtx.LastSubmit
 		ctx.TXUpdates.FirstSubmit = mtx.FirstSubmit
+		if sth.staleBlocks > 0 && ctx.HighestBlock > 0 {
+			ctx.Info.LastWarnBlock = ctx.HighestBlock
+			ctx.UpdatedInfo = true
+		}
 		return nil
 
 	} else if ctx.Receipt == nil {
 
+		if ctx.Info.SubmitBackoffUntil != nil || ctx.Info.ReplacementGasPrice != nil {
+			// The last resubmission was rejected for a reason we can act on, so we try again
+			// without waiting for the resubmit interval
+			if reason, err := sth.submitTX(ctx); err != nil {
+				if reason != ffcapi.ErrorKnownTransaction {
+					return err
+				}
+			}
+			return nil
+		}
+
 		// A more sophisticated policy engine would look at the reason for the lack of a receipt, and consider taking progressive
 		// action such as increasing the gas cost slowly over time. This simple example shows how the policy engine
 		// can use the FireFly core operation as a store for its historical state/decisions (in this case the last time we warned).
@@ -424,15 +1025,22 @@ func (sth *simpleTransactionHandler) processTransaction(ctx *RunContext) (err er
 			lastWarnTime = mtx.FirstSubmit
 		}
 		now := fftypes.Now()
-		if now.Time().Sub(*lastWarnTime.Time()) > sth.resubmitInterval {
+		if now.Time().Sub(*lastWarnTime.Time()) > sth.resubmitInterval || sth.staleByBlocks(ctx) {
 			secsSinceSubmit := float64(now.Time().Sub(*mtx.FirstSubmit.Time())) / float64(time.Second)
 			log.L(ctx).Infof("Transaction %s at nonce %s / %d has not been mined after %.2fs", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), secsSinceSubmit)
 			ctx.UpdateType = Update
 			ctx.UpdatedInfo = true
 			ctx.Info.LastWarnTime = now
-			// We do a resubmit at this point - as it might no longer be in the TX pool
+			if sth.staleBlocks > 0 {
+				ctx.Info.LastWarnBlock = ctx.HighestBlock
+			}
+			// We do a resubmit at this point if it is no longer in the TX pool
 			ctx.AddSubStatusAction(apitypes.TxActionTimeout, nil, nil)
 			ctx.SetSubStatus(apitypes.TxSubStatusStale)
+			if resubmit, err := sth.checkDropped(ctx); !resubmit {
+				return err
+			}
+			sth.escalateGasPrice(ctx)
 			if reason, err := sth.submitTX(ctx); err != nil {
 				if reason != ffcapi.ErrorKnownTransaction {
 					return err
@@ -446,31 +1054,30 @@ func (sth *simpleTransactionHandler) processTransaction(ctx *RunContext) (err er
 	return nil
 }
 
-// getGasPrice either uses a fixed gas price, or invokes a gas station API
+// staleByBlocks is true if staleBlocks new blocks have been notified since the transaction was first submitted,
+// or last found stale. If we do not know the block the transaction was submitted at, such as when it was
+// submitted before we were notified of any blocks, we start counting from the current block.
+func (sth *simpleTransactionHandler) staleByBlocks(ctx *RunContext) bool {
+	if sth.staleBlocks <= 0 || ctx.HighestBlock == 0 {
+		return false
+	}
+	if ctx.Info.LastWarnBlock == 0 {
+		ctx.Info.LastWarnBlock = ctx.HighestBlock
+		ctx.UpdateType = Update
+		ctx.UpdatedInfo = true
+		return false
+	}
+	return ctx.HighestBlock >= ctx.Info.LastWarnBlock+uint64(sth.staleBlocks)
+}
+
+// getGasPrice either uses the gas pricer the handler was created with, a fixed gas price, or invokes a gas station API
 func (sth *simpleTransactionHandler) getGasPrice(ctx context.Context, cAPI ffcapi.API) (gasPrice *fftypes.JSONAny, err error) {
-	if sth.gasOracleQueryValue != nil && sth.gasOracleLastQueryTime != nil &&
-		time.Since(*sth.gasOracleLastQueryTime.Time()) < sth.gasOracleQueryInterval {
-		return sth.gasOracleQueryValue, nil
+	if sth.gasPricer != nil {
+		return sth.gasPricer.GasPrice(ctx)
 	}
 	switch sth.gasOracleMode {
-	case GasOracleModeRESTAPI:
-		// Make a REST call against an endpoint, and extract a value/structure to pass to the connector
-		gasPrice, err := sth.getGasPriceAPI(ctx)
-		if err != nil {
-			return nil, err
-		}
-		sth.gasOracleQueryValue = gasPrice
-		sth.gasOracleLastQueryTime = fftypes.Now()
-		return sth.gasOracleQueryValue, nil
-	case GasOracleModeConnector:
-		// Call the connector
-		res, _, err := cAPI.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
-		if err != nil {
-			return nil, err
-		}
-		sth.gasOracleQueryValue = res.GasPrice
-		sth.gasOracleLastQueryTime = fftypes.Now()
-		return sth.gasOracleQueryValue, nil
+	case GasOracleModeRESTAPI, GasOracleModeAggregate, GasOracleModeConnector:
+		return sth.getOracleGasPrice(ctx, cAPI)
 	default:
 		// Disabled - just a fixed value - note that the fixed value can be any JSON structure,
 		// as interpreted by the connector. For example EVMConnect support a simple value, or a
@@ -479,25 +1086,50 @@ func (sth *simpleTransactionHandler) getGasPrice(ctx context.Context, cAPI ffcap
 	}
 }
 
-func (sth *simpleTransactionHandler) getGasPriceAPI(ctx context.Context) (gasPrice *fftypes.JSONAny, err error) {
-	res, err := sth.gasOracleClient.R().
-		Execute(sth.gasOracleMethod, "")
-	if err != nil {
-		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgErrorQueryingGasOracleAPI, -1, err.Error())
+// getOracleGasPrice returns the gas price from the configured gas oracle, which is cached for the query interval
+func (sth *simpleTransactionHandler) getOracleGasPrice(ctx context.Context, cAPI ffcapi.API) (gasPrice *fftypes.JSONAny, err error) {
+	sth.gasOracleMux.Lock()
+	if sth.gasOracleQueryValue != nil && sth.gasOracleLastQueryTime != nil &&
+		time.Since(*sth.gasOracleLastQueryTime.Time()) < sth.gasOracleQueryInterval {
+		gasPrice = sth.gasOracleQueryValue
 	}
-	if res.IsError() {
-		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgErrorQueryingGasOracleAPI, res.StatusCode(), res.RawResponse)
+	sth.gasOracleMux.Unlock()
+	if gasPrice != nil {
+		return gasPrice, nil
 	}
-	// Parse the response body as JSON
-	var data map[string]interface{}
-	err = json.Unmarshal(res.Body(), &data)
+	// The policy workers that need a new gas price at the same time share a single query, which is made without the lock held
+	res, err, _ := sth.gasOracleQuery.Do(sth.gasOracleMode, func() (interface{}, error) {
+		return sth.queryGasPrice(ctx, cAPI)
+	})
 	if err != nil {
-		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgInvalidJSONGasObject)
+		return nil, err
+	}
+	return res.(*fftypes.JSONAny), nil
+}
+
+// queryGasPrice queries the configured gas oracle, and caches the result
+func (sth *simpleTransactionHandler) queryGasPrice(ctx context.Context, cAPI ffcapi.API) (gasPrice *fftypes.JSONAny, err error) {
+	switch sth.gasOracleMode {
+	case GasOracleModeRESTAPI:
+		// Make a REST call against an endpoint, and extract a value/structure to pass to the connector
+		gasPrice, err = sth.gasOracleAPI.query(ctx)
+	case GasOracleModeAggregate:
+		// Query all the configured sources, and combine the results
+		gasPrice, err = sth.getGasPriceAggregate(ctx, cAPI)
+	default:
+		// Call the connector
+		var res *ffcapi.GasPriceEstimateResponse
+		res, _, err = cAPI.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+		if err == nil {
+			gasPrice = res.GasPrice
+		}
 	}
-	buff := new(bytes.Buffer)
-	err = sth.gasOracleTemplate.Execute(buff, data)
 	if err != nil {
-		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgGasOracleResultError)
+		return nil, err
 	}
-	return fftypes.JSONAnyPtr(buff.String()), nil
+	sth.gasOracleMux.Lock()
+	sth.gasOracleQueryValue = gasPrice
+	sth.gasOracleLastQueryTime = fftypes.Now()
+	sth.gasOracleMux.Unlock()
+	return gasPrice, nil
 }
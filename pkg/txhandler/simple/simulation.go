@@ -0,0 +1,89 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simple
+
+import (
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// simulateEnabled returns whether a transaction is simulated before it is first submitted, where the
+// setting in the request headers overrides the configuration of the handler
+func (sth *simpleTransactionHandler) simulateEnabled(mtx *apitypes.ManagedTX) bool {
+	if mtx.Simulate != nil {
+		return *mtx.Simulate
+	}
+	return sth.simulate
+}
+
+// simulateTX executes the prepared transaction against the current state of the chain before it is first
+// submitted, and returns true if it should be submitted. A transaction that reverts is failed with the revert
+// reason without being sent. Its nonce is released to be used by the next transaction from the signer if it is
+// the latest nonce allocated, and otherwise is filled by cancelling the transaction. Being unable to simulate
+// for any other reason, including the connector not supporting simulation, does not stop the submission.
+func (sth *simpleTransactionHandler) simulateTX(ctx *RunContext) (submit bool, err error) {
+	mtx := ctx.TX
+	_, reason, err := sth.sendSimulate(ctx, &ffcapi.TransactionSimulateRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From:  mtx.From,
+			To:    mtx.To,
+			Gas:   mtx.Gas,
+			Value: mtx.Value,
+		},
+		TransactionData: mtx.TransactionData,
+	})
+	switch {
+	case err == nil:
+		ctx.AddSubStatusAction(apitypes.TxActionSimulateTransaction, nil, nil)
+		return true, nil
+	case reason != ffcapi.ErrorReasonTransactionReverted:
+		log.L(ctx).Warnf("Unable to simulate transaction %s at nonce %s / %d - submitting without simulation (reason=%s): %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), reason, err)
+		ctx.AddSubStatusAction(apitypes.TxActionSimulateTransaction, fftypes.JSONAnyPtr(`{"reason":"`+string(reason)+`"}`), fftypes.JSONAnyPtr(`{"error":"`+err.Error()+`"}`))
+		return true, nil
+	}
+	revertErr := i18n.NewError(ctx, tmmsgs.MsgTransactionSimulationReverted, mtx.ID, err)
+	ctx.AddSubStatusAction(apitypes.TxActionSimulateTransaction, fftypes.JSONAnyPtr(`{"reason":"`+string(reason)+`"}`), fftypes.JSONAnyPtr(`{"error":"`+err.Error()+`"}`))
+
+	released, err := sth.toolkit.TXPersistence.ReleaseTransactionNonce(ctx, mtx.From, mtx.ID)
+	if err != nil {
+		// We try again on the next policy cycle
+		log.L(ctx).Errorf("Failed to release nonce of transaction %s at nonce %s / %d: %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), err)
+		return false, err
+	}
+	if !released {
+		// There are later nonces allocated for the signer, so we need to fill the nonce to avoid a gap
+		return false, sth.cancelTransaction(ctx, revertErr)
+	}
+	log.L(ctx).Infof("Released nonce %s / %d of transaction %s", mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.ID)
+	ctx.AddSubStatusAction(apitypes.TxActionReleaseNonce, fftypes.JSONAnyPtr(`{"nonce":"`+mtx.Nonce.String()+`"}`), nil)
+	sth.failTransaction(ctx, reason, revertErr)
+	mtx.Nonce = nil
+	return false, revertErr
+}
+
+// sendSimulate executes the prepared transaction, if the connector supports it
+func (sth *simpleTransactionHandler) sendSimulate(ctx *RunContext, simulateTX *ffcapi.TransactionSimulateRequest) (*ffcapi.TransactionSimulateResponse, ffcapi.ErrorReason, error) {
+	simulator, ok := sth.toolkit.Connector.(ffcapi.TransactionSimulator)
+	if !ok {
+		return nil, ffcapi.ErrorReasonNotSupported, i18n.NewError(ctx, tmmsgs.MsgConnectorNotSupported, "TransactionSimulate")
+	}
+	return simulator.TransactionSimulate(ctx, simulateTX)
+}
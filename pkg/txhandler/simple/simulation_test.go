@@ -0,0 +1,192 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simple
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/txhandlermocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestSimulationHandler(t *testing.T, simulate bool) (*simpleTransactionHandler, *ffcapimocks.ExtendedAPI, func()) {
+	f, tk, mfc, conf, cleanup := newTestTransactionHandlerFactoryWithFilePersistence(t)
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(Simulate, simulate)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+
+	sth := th.(*simpleTransactionHandler)
+	sth.ctx = context.Background()
+	sth.Init(sth.ctx, tk)
+
+	meh := tk.EventHandler.(*txhandlermocks.ManagedTxEventHandler)
+	meh.On("HandleEvent", mock.Anything, mock.Anything).Return(nil)
+	mfc.On("TransactionSign", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSignResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(func(_ context.Context, req *ffcapi.TransactionSendRequest) *ffcapi.TransactionSendResponse {
+		return &ffcapi.TransactionSendResponse{TransactionHash: fmt.Sprintf("0x%d", req.Nonce.Int64())}
+	}, ffcapi.ErrorReason(""), nil)
+	return sth, mfc, cleanup
+}
+
+func TestPolicyLoopSimulationRevertReleasesNonce(t *testing.T) {
+	sth, mfc, cleanup := newTestSimulationHandler(t, true)
+	defer cleanup()
+
+	mfc.On("TransactionSimulate", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSimulateRequest) bool {
+		return req.From == "0xaaaa" && req.TransactionData == "0xabce1234" && req.Gas.Int64() == 100000
+	})).Return(&ffcapi.TransactionSimulateResponse{}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("TransactionSimulate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonTransactionReverted, fmt.Errorf("Execution reverted: pop")).Once()
+
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	sendSampleTX(t, sth, "0xaaaa", 1001, "ns1:tx2")
+	runTestPolicyCycle(sth, true)
+	assert.Equal(t, "0x1000", sth.inflight[0].mtx.TransactionHash)
+	assert.True(t, sth.inflight[1].remove)
+
+	rtx, err := sth.toolkit.TXPersistence.GetTransactionByIDWithStatus(sth.ctx, "ns1:tx2", true)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusFailed, rtx.Status)
+	assert.Regexp(t, "FF21129.*pop", rtx.ErrorMessage)
+	assert.Nil(t, rtx.Nonce)
+	assert.Empty(t, rtx.TransactionHash)
+	var actions []apitypes.TxAction
+	for _, h := range rtx.History {
+		for _, a := range h.Actions {
+			actions = append(actions, a.Action)
+		}
+	}
+	assert.Contains(t, actions, apitypes.TxActionSimulateTransaction)
+	assert.Contains(t, actions, apitypes.TxActionReleaseNonce)
+
+	// The nonce is allocated to the next transaction from the signer
+	mtx := sendSampleTX(t, sth, "0xaaaa", 9999, "ns1:tx3")
+	assert.Equal(t, int64(1001), mtx.Nonce.Int64())
+
+	mfc.AssertNumberOfCalls(t, "TransactionSend", 1)
+}
+
+func TestPolicyLoopSimulationRevertCancelsNonce(t *testing.T) {
+	sth, mfc, cleanup := newTestSimulationHandler(t, false)
+	defer cleanup()
+
+	mfc.On("TransactionSimulate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonTransactionReverted, fmt.Errorf("Execution reverted: pop")).Once()
+	mfc.On("TransactionCancel", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionCancelRequest) bool {
+		return req.From == "0xaaaa" && req.Nonce.Int64() == 1000
+	})).Return(&ffcapi.TransactionCancelResponse{TransactionHash: "0xcancel"}, ffcapi.ErrorReason(""), nil).Once()
+
+	// Only the first transaction is simulated, and there is a later nonce so it cannot be released
+	simulate := true
+	sendSampleTXWithHeaders(t, sth, "0xaaaa", 1000, apitypes.RequestHeaders{ID: "ns1:tx1", Simulate: &simulate})
+	sendSampleTX(t, sth, "0xaaaa", 1001, "ns1:tx2")
+	runTestPolicyCycle(sth, true)
+	assert.True(t, sth.inflight[0].remove)
+	assert.Equal(t, "0x1001", sth.inflight[1].mtx.TransactionHash)
+
+	rtx, err := sth.toolkit.TXPersistence.GetTransactionByIDWithStatus(sth.ctx, "ns1:tx1", true)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusFailed, rtx.Status)
+	assert.Regexp(t, "FF21129.*pop", rtx.ErrorMessage)
+	assert.Equal(t, int64(1000), rtx.Nonce.Int64())
+
+	mfc.AssertNumberOfCalls(t, "TransactionSimulate", 1)
+	mfc.AssertNumberOfCalls(t, "TransactionCancel", 1)
+}
+
+func TestPolicyLoopSimulationNotPossible(t *testing.T) {
+	sth, mfc, cleanup := newTestSimulationHandler(t, true)
+	defer cleanup()
+
+	mfc.On("TransactionSimulate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNotSupported, fmt.Errorf("not supported")).Once()
+
+	// The second transaction opts out of simulation
+	sendSampleTX(t, sth, "0xaaaa", 1000, "ns1:tx1")
+	simulate := false
+	sendSampleTXWithHeaders(t, sth, "0xbbbb", 2000, apitypes.RequestHeaders{ID: "ns1:tx2", Simulate: &simulate})
+	runTestPolicyCycle(sth, true)
+	assert.Equal(t, "0x1000", sth.inflight[0].mtx.TransactionHash)
+	assert.Equal(t, "0x2000", sth.inflight[1].mtx.TransactionHash)
+
+	mfc.AssertExpectations(t)
+}
+
+func TestSimulationReleaseNonceFail(t *testing.T) {
+	f, tk, mfc, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(Simulate, true)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+	sth := th.(*simpleTransactionHandler)
+	sth.Init(context.Background(), tk)
+
+	mfc.On("TransactionSimulate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonTransactionReverted, fmt.Errorf("Execution reverted: pop"))
+	mp := tk.TXPersistence.(*persistencemocks.Persistence)
+	mp.On("ReleaseTransactionNonce", mock.Anything, "0xaaaa", "ns1:tx1").Return(false, fmt.Errorf("pop"))
+
+	// We retry on the next cycle
+	mtx := &apitypes.ManagedTX{
+		ID:     "ns1:tx1",
+		Status: apitypes.TxStatusPending,
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From:  "0xaaaa",
+			Nonce: fftypes.NewFFBigInt(1000),
+		},
+		TransactionData: "0xabce1234",
+	}
+	rc := newTestRunContext(mtx, nil)
+	err = sth.processTransaction(rc)
+	assert.Regexp(t, "pop", err)
+	assert.Equal(t, apitypes.TxStatusPending, mtx.Status)
+	assert.Equal(t, int64(1000), mtx.Nonce.Int64())
+}
+
+func TestSimulationNotSupportedByConnector(t *testing.T) {
+	f, tk, _, conf := newTestTransactionHandlerFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(Simulate, true)
+	th, err := f.NewTransactionHandler(context.Background(), conf)
+	assert.NoError(t, err)
+	sth := th.(*simpleTransactionHandler)
+	strictFFCAPI := &ffcapimocks.API{}
+	tk.Connector = strictFFCAPI
+	sth.Init(context.Background(), tk)
+
+	// The transaction is submitted without simulation
+	mtx := &apitypes.ManagedTX{
+		ID:     "ns1:tx1",
+		Status: apitypes.TxStatusPending,
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From:  "0xaaaa",
+			Nonce: fftypes.NewFFBigInt(1000),
+		},
+		TransactionData: "0xabce1234",
+	}
+	rc := newTestRunContext(mtx, nil)
+	submit, err := sth.simulateTX(rc)
+	assert.NoError(t, err)
+	assert.True(t, submit)
+	assert.Len(t, rc.HistoryUpdates, 1)
+	strictFFCAPI.AssertExpectations(t)
+}
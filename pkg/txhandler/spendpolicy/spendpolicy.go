@@ -0,0 +1,225 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package spendpolicy checks each new transaction against configured rules before it is accepted,
+// so that a misbehaving upstream cannot drain the funds of a signer through the transaction manager.
+package spendpolicy
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+type Rule string
+
+const (
+	// RuleSigner rejects signers that are not in the allow-list for the namespace of the transaction
+	RuleSigner Rule = "signer"
+	// RuleDestination rejects transactions to addresses that are not in the allow-list of destinations
+	RuleDestination Rule = "destination"
+	// RuleMaxValue rejects transactions with a value above the maximum for a single transaction
+	RuleMaxValue Rule = "max_value"
+	// RuleWindowValue rejects transactions that would take the value sent by the signer within the window above the limit
+	RuleWindowValue Rule = "window_value"
+	// RuleWindowGasLimit rejects transactions that would take the gas limit of the transactions of the signer within the window above the limit
+	RuleWindowGasLimit Rule = "window_gas_limit"
+)
+
+// AnyNamespace is the key in NamespaceSigners for the allow-list that applies to namespaces without their own entry
+const AnyNamespace = "*"
+
+// SpendPolicy is checked by the transaction handler for each new transaction, once it has been prepared
+// (so its gas limit is known) but before it is persisted.
+//
+// The spend of each signer within the rolling window is held in memory, so starts again from zero after a restart,
+// and is not shared between multiple instances.
+type SpendPolicy interface {
+	// Admit returns an error if the transaction breaks any of the rules. Otherwise the transaction counts towards
+	// the spend of its signer within the window.
+	Admit(ctx context.Context, mtx *apitypes.ManagedTX) error
+	// Release removes an admitted transaction from the spend of its signer, as it was not accepted after all
+	Release(ctx context.Context, mtx *apitypes.ManagedTX)
+}
+
+// Options configures the rules. Any rule left empty is not applied.
+type Options struct {
+	NamespaceSigners    map[string][]string                  // allow-list of signers for each namespace - the namespace is the prefix of the transaction ID before the first ':'
+	AllowedDestinations []string                             // allow-list of the addresses that transactions can be sent to - contract deployments have no destination
+	MaxValue            *big.Int                             // maximum value of a single transaction
+	Window              time.Duration                        // the rolling window for the cumulative limits (defaults to 1h)
+	WindowMaxValue      *big.Int                             // maximum total value sent by a signer within the window
+	WindowMaxGasLimit   *big.Int                             // maximum total gas limit of the transactions of a signer within the window
+	OnRejected          func(ctx context.Context, rule Rule) // optional callback for each transaction that is rejected
+}
+
+const defaultWindow = 1 * time.Hour
+
+type spend struct {
+	txID     string
+	time     time.Time
+	value    *big.Int
+	gasLimit *big.Int
+}
+
+type spendPolicy struct {
+	options             Options
+	namespaceSigners    map[string]map[string]bool
+	allowedDestinations map[string]bool
+
+	mux           sync.Mutex
+	spendBySigner map[string][]*spend
+}
+
+func NewSpendPolicy(options *Options) SpendPolicy {
+	sp := &spendPolicy{
+		options:       *options,
+		spendBySigner: make(map[string][]*spend),
+	}
+	if sp.options.Window <= 0 {
+		sp.options.Window = defaultWindow
+	}
+	if len(sp.options.NamespaceSigners) > 0 {
+		sp.namespaceSigners = make(map[string]map[string]bool)
+		for ns, signers := range sp.options.NamespaceSigners {
+			sp.namespaceSigners[ns] = addressSet(signers)
+		}
+	}
+	if len(sp.options.AllowedDestinations) > 0 {
+		sp.allowedDestinations = addressSet(sp.options.AllowedDestinations)
+	}
+	return sp
+}
+
+func addressSet(addresses []string) map[string]bool {
+	set := make(map[string]bool, len(addresses))
+	for _, a := range addresses {
+		set[strings.ToLower(a)] = true
+	}
+	return set
+}
+
+func namespaceOf(txID string) string {
+	if idx := strings.Index(txID, ":"); idx >= 0 {
+		return txID[0:idx]
+	}
+	return ""
+}
+
+func (sp *spendPolicy) Admit(ctx context.Context, mtx *apitypes.ManagedTX) error {
+	rule, err := sp.check(ctx, mtx)
+	if err != nil {
+		log.L(ctx).Warnf("Transaction %s from %s rejected by spend policy rule %s: %s", mtx.ID, mtx.From, rule, err)
+		if sp.options.OnRejected != nil {
+			sp.options.OnRejected(ctx, rule)
+		}
+		return err
+	}
+	return nil
+}
+
+func (sp *spendPolicy) check(ctx context.Context, mtx *apitypes.ManagedTX) (Rule, error) {
+	signer := strings.ToLower(mtx.From)
+	if sp.namespaceSigners != nil {
+		ns := namespaceOf(mtx.ID)
+		allowed, ok := sp.namespaceSigners[ns]
+		if !ok {
+			allowed, ok = sp.namespaceSigners[AnyNamespace]
+		}
+		if ok && !allowed[signer] {
+			return RuleSigner, i18n.NewError(ctx, tmmsgs.MsgSpendPolicySignerNotAllowed, mtx.From, ns)
+		}
+	}
+	if sp.allowedDestinations != nil && mtx.To != "" && !sp.allowedDestinations[strings.ToLower(mtx.To)] {
+		return RuleDestination, i18n.NewError(ctx, tmmsgs.MsgSpendPolicyDestinationNotAllowed, mtx.To)
+	}
+
+	value := new(big.Int)
+	if mtx.Value != nil {
+		value = mtx.Value.Int()
+	}
+	if sp.options.MaxValue != nil && value.Cmp(sp.options.MaxValue) > 0 {
+		return RuleMaxValue, i18n.NewError(ctx, tmmsgs.MsgSpendPolicyMaxValue, value.String(), sp.options.MaxValue.String())
+	}
+	if sp.options.WindowMaxValue == nil && sp.options.WindowMaxGasLimit == nil {
+		return "", nil
+	}
+
+	gasLimit := new(big.Int)
+	if mtx.Gas != nil {
+		gasLimit = mtx.Gas.Int()
+	}
+	sp.mux.Lock()
+	defer sp.mux.Unlock()
+	totalValue, totalGasLimit := sp.windowSpend(signer)
+	totalValue.Add(totalValue, value)
+	totalGasLimit.Add(totalGasLimit, gasLimit)
+	if sp.options.WindowMaxValue != nil && totalValue.Cmp(sp.options.WindowMaxValue) > 0 {
+		return RuleWindowValue, i18n.NewError(ctx, tmmsgs.MsgSpendPolicyWindowValue, mtx.From, totalValue.String(), sp.options.Window.String(), sp.options.WindowMaxValue.String())
+	}
+	if sp.options.WindowMaxGasLimit != nil && totalGasLimit.Cmp(sp.options.WindowMaxGasLimit) > 0 {
+		return RuleWindowGasLimit, i18n.NewError(ctx, tmmsgs.MsgSpendPolicyWindowGasLimit, mtx.From, totalGasLimit.String(), sp.options.Window.String(), sp.options.WindowMaxGasLimit.String())
+	}
+	sp.spendBySigner[signer] = append(sp.spendBySigner[signer], &spend{
+		txID:     mtx.ID,
+		time:     time.Now(),
+		value:    value,
+		gasLimit: gasLimit,
+	})
+	return "", nil
+}
+
+// windowSpend must be called with the lock held. Spend that has dropped out of the window is discarded.
+func (sp *spendPolicy) windowSpend(signer string) (totalValue, totalGasLimit *big.Int) {
+	totalValue, totalGasLimit = new(big.Int), new(big.Int)
+	cutoff := time.Now().Add(-sp.options.Window)
+	spends := sp.spendBySigner[signer]
+	inWindow := spends[:0]
+	for _, s := range spends {
+		if s.time.After(cutoff) {
+			inWindow = append(inWindow, s)
+			totalValue.Add(totalValue, s.value)
+			totalGasLimit.Add(totalGasLimit, s.gasLimit)
+		}
+	}
+	if len(inWindow) == 0 {
+		delete(sp.spendBySigner, signer)
+	} else {
+		sp.spendBySigner[signer] = inWindow
+	}
+	return totalValue, totalGasLimit
+}
+
+func (sp *spendPolicy) Release(ctx context.Context, mtx *apitypes.ManagedTX) {
+	signer := strings.ToLower(mtx.From)
+	sp.mux.Lock()
+	defer sp.mux.Unlock()
+	spends := sp.spendBySigner[signer]
+	for i, s := range spends {
+		if s.txID == mtx.ID {
+			log.L(ctx).Debugf("Released spend of transaction %s from %s", mtx.ID, mtx.From)
+			sp.spendBySigner[signer] = append(spends[0:i], spends[i+1:]...)
+			return
+		}
+	}
+}
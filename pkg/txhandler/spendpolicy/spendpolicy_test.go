@@ -0,0 +1,141 @@
+// Copyright © 2023 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spendpolicy
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTX(id, from, to string, value, gas int64) *apitypes.ManagedTX {
+	return &apitypes.ManagedTX{
+		ID: id,
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From:  from,
+			To:    to,
+			Value: fftypes.NewFFBigInt(value),
+			Gas:   fftypes.NewFFBigInt(gas),
+		},
+	}
+}
+
+func TestNewSpendPolicyDefaults(t *testing.T) {
+	sp := NewSpendPolicy(&Options{}).(*spendPolicy)
+	assert.Equal(t, defaultWindow, sp.options.Window)
+	assert.Nil(t, sp.namespaceSigners)
+	assert.Nil(t, sp.allowedDestinations)
+
+	// No rules, so anything is allowed - and nothing is recorded
+	err := sp.Admit(context.Background(), &apitypes.ManagedTX{ID: "tx1"})
+	assert.NoError(t, err)
+	assert.Empty(t, sp.spendBySigner)
+}
+
+func TestNamespaceSigners(t *testing.T) {
+	var rejected []Rule
+	sp := NewSpendPolicy(&Options{
+		NamespaceSigners: map[string][]string{
+			"ns1":        {"0xAAAA", "0xbbbb"},
+			AnyNamespace: {"0xcccc"},
+		},
+		OnRejected: func(ctx context.Context, rule Rule) { rejected = append(rejected, rule) },
+	})
+	ctx := context.Background()
+
+	assert.NoError(t, sp.Admit(ctx, newTestTX("ns1:tx1", "0xaaaa", "", 0, 0)))
+	assert.NoError(t, sp.Admit(ctx, newTestTX("ns1:tx2", "0xBBBB", "", 0, 0)))
+	assert.Regexp(t, "FF21143.*0xcccc.*ns1", sp.Admit(ctx, newTestTX("ns1:tx3", "0xcccc", "", 0, 0)))
+	assert.NoError(t, sp.Admit(ctx, newTestTX("ns2:tx4", "0xcccc", "", 0, 0)))
+	assert.Regexp(t, "FF21143.*0xaaaa.*ns2", sp.Admit(ctx, newTestTX("ns2:tx5", "0xaaaa", "", 0, 0)))
+	assert.Regexp(t, "FF21143", sp.Admit(ctx, newTestTX("tx6", "0xaaaa", "", 0, 0)))
+	assert.Equal(t, []Rule{RuleSigner, RuleSigner, RuleSigner}, rejected)
+}
+
+func TestNamespaceSignersNoDefault(t *testing.T) {
+	sp := NewSpendPolicy(&Options{
+		NamespaceSigners: map[string][]string{
+			"ns1": {"0xaaaa"},
+		},
+	})
+	ctx := context.Background()
+
+	assert.Regexp(t, "FF21143", sp.Admit(ctx, newTestTX("ns1:tx1", "0xbbbb", "", 0, 0)))
+	assert.NoError(t, sp.Admit(ctx, newTestTX("ns2:tx2", "0xbbbb", "", 0, 0)))
+}
+
+func TestAllowedDestinations(t *testing.T) {
+	sp := NewSpendPolicy(&Options{
+		AllowedDestinations: []string{"0xDDDD"},
+	})
+	ctx := context.Background()
+
+	assert.NoError(t, sp.Admit(ctx, newTestTX("ns1:tx1", "0xaaaa", "0xdddd", 0, 0)))
+	assert.NoError(t, sp.Admit(ctx, newTestTX("ns1:tx2", "0xaaaa", "", 0, 0))) // deployment
+	assert.Regexp(t, "FF21144.*0xeeee", sp.Admit(ctx, newTestTX("ns1:tx3", "0xaaaa", "0xeeee", 0, 0)))
+}
+
+func TestMaxValue(t *testing.T) {
+	sp := NewSpendPolicy(&Options{
+		MaxValue: big.NewInt(100),
+	})
+	ctx := context.Background()
+
+	assert.NoError(t, sp.Admit(ctx, newTestTX("ns1:tx1", "0xaaaa", "0xdddd", 100, 0)))
+	assert.NoError(t, sp.Admit(ctx, &apitypes.ManagedTX{ID: "ns1:tx2"}))
+	assert.Regexp(t, "FF21145.*101.*100", sp.Admit(ctx, newTestTX("ns1:tx3", "0xaaaa", "0xdddd", 101, 0)))
+}
+
+func TestWindowLimits(t *testing.T) {
+	var rejected []Rule
+	sp := NewSpendPolicy(&Options{
+		WindowMaxValue:    big.NewInt(1000),
+		WindowMaxGasLimit: big.NewInt(50000),
+		OnRejected:        func(ctx context.Context, rule Rule) { rejected = append(rejected, rule) },
+	}).(*spendPolicy)
+	ctx := context.Background()
+
+	assert.NoError(t, sp.Admit(ctx, newTestTX("ns1:tx1", "0xaaaa", "0xdddd", 600, 20000)))
+	assert.NoError(t, sp.Admit(ctx, newTestTX("ns1:tx2", "0xbbbb", "0xdddd", 600, 20000))) // other signers are separate
+	assert.Regexp(t, "FF21146.*0xAAAA.*1200.*1000", sp.Admit(ctx, newTestTX("ns1:tx3", "0xAAAA", "0xdddd", 600, 20000)))
+	assert.NoError(t, sp.Admit(ctx, newTestTX("ns1:tx4", "0xAAAA", "0xdddd", 400, 20000)))
+	assert.Regexp(t, "FF21147.*60000.*50000", sp.Admit(ctx, newTestTX("ns1:tx5", "0xaaaa", "0xdddd", 0, 20000)))
+	assert.NoError(t, sp.Admit(ctx, &apitypes.ManagedTX{ID: "ns1:tx6", TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaa"}}))
+	assert.Equal(t, []Rule{RuleWindowValue, RuleWindowGasLimit}, rejected)
+
+	// Releasing a transaction frees up its spend
+	sp.Release(ctx, newTestTX("ns1:tx4", "0xaaaa", "0xdddd", 400, 20000))
+	sp.Release(ctx, newTestTX("ns1:unknown", "0xaaaa", "0xdddd", 400, 20000))
+	assert.NoError(t, sp.Admit(ctx, newTestTX("ns1:tx7", "0xaaaa", "0xdddd", 400, 20000)))
+
+	// Spend drops out of the window
+	for _, s := range sp.spendBySigner["0xaaaa"] {
+		s.time = time.Now().Add(-2 * time.Hour)
+	}
+	assert.NoError(t, sp.Admit(ctx, newTestTX("ns1:tx8", "0xaaaa", "0xdddd", 1000, 50000)))
+	assert.Len(t, sp.spendBySigner["0xaaaa"], 1)
+
+	sp.spendBySigner["0xaaaa"][0].time = time.Now().Add(-2 * time.Hour)
+	assert.Regexp(t, "FF21146", sp.Admit(ctx, newTestTX("ns1:tx9", "0xaaaa", "0xdddd", 1001, 0)))
+	assert.NotContains(t, sp.spendBySigner, "0xaaaa")
+}
@@ -60,6 +60,28 @@ type Toolkit struct {
 
 	// Event Handler toolkit contains methods to handle a defined set of events when processing managed transactions
 	EventHandler ManagedTxEventHandler
+
+	// When the connector circuit breaker is enabled, this allows the transaction handler to check if calls to the connector
+	// are currently being rejected - so it can avoid work that is certain to fail. If not enabled, this will be nil.
+	CircuitBreaker CircuitBreaker
+
+	// When a spend policy is enabled, the transaction handler must admit each new transaction through it before the
+	// transaction is persisted - and release it if it is not persisted after all. If not enabled, this will be nil.
+	SpendPolicy SpendPolicy
+}
+
+// CircuitBreaker provides the state of the circuit breaker in front of the connector
+type CircuitBreaker interface {
+	// IsOpen returns true while calls to the connector are being rejected, because the downstream node is unavailable
+	IsOpen() bool
+}
+
+// SpendPolicy checks new transactions against the configured rules for signers, destinations and values
+type SpendPolicy interface {
+	// Admit returns an error if the transaction is not allowed, otherwise counts it towards the spend of its signer
+	Admit(ctx context.Context, mtx *apitypes.ManagedTX) error
+	// Release removes an admitted transaction from the spend of its signer
+	Release(ctx context.Context, mtx *apitypes.ManagedTX)
 }
 
 // Handler checks received transaction process events and dispatch them to an event
@@ -118,3 +140,37 @@ type TransactionHandler interface {
 	// HandleTransactionReceiptReceived - handles receipt of blockchain transactions for a managed transaction
 	HandleTransactionReceiptReceived(ctx context.Context, txID string, receipt *ffcapi.TransactionReceiptResponse) (err error)
 }
+
+// TransactionBatcher is an optional interface for transaction handlers that can handle a batch of new transactions in one
+// request. Batch requests are rejected if the transaction handler does not implement it.
+type TransactionBatcher interface {
+	// HandleNewTransactionBatch - handles event of adding a batch of new transactions and/or smart contract deployments,
+	//                             returning a result for each in the same order
+	HandleNewTransactionBatch(ctx context.Context, txReqs []*apitypes.TransactionBatchItem) []*apitypes.TransactionBatchResult
+}
+
+// TransactionReplacer is an optional interface for transaction handlers that can replace a pending managed transaction
+// at the same nonce. Requests to update or cancel a transaction are rejected if the transaction handler does not implement it.
+type TransactionReplacer interface {
+	// HandleUpdateTransaction - handles event of changing the gas price or gas limit of a pending managed transaction,
+	//                           replacing it at the same nonce if it has already been submitted
+	HandleUpdateTransaction(ctx context.Context, txID string, update *apitypes.TransactionUpdateRequest) (mtx *apitypes.ManagedTX, err error)
+	// HandleCancelByReplacement - handles event of cancelling a pending managed transaction, by replacing it at the same nonce
+	//                             with a transaction that does nothing
+	HandleCancelByReplacement(ctx context.Context, txID string) (mtx *apitypes.ManagedTX, err error)
+}
+
+// BlockHandler is an optional interface for transaction handlers that act on new blocks. Only transaction handlers that
+// implement it are informed of each new highest block seen by the confirmation manager.
+type BlockHandler interface {
+	// HandleNewBlock - handles a new highest block seen on the blockchain. Must not block, as it is called on the block notification path
+	HandleNewBlock(ctx context.Context, blockNumber uint64)
+}
+
+// ExtendedTransactionHandler is implemented by transaction handlers that implement all of the optional interfaces
+type ExtendedTransactionHandler interface {
+	TransactionHandler
+	TransactionBatcher
+	TransactionReplacer
+	BlockHandler
+}